@@ -0,0 +1,158 @@
+// bot.go
+package chatclient
+
+import (
+	"errors"
+
+	"chat/logger"
+	"chat/models"
+	"chat/network"
+	"chat/utils"
+)
+
+// handler gets message of another user and bot which received it
+type MessageHandler func(bot *Bot, msg models.SavedMessage)
+
+// client for programs which answer messages without gui. Handlers are
+// called one by one in event loop of bot, so they can change bot and its
+// subscriptions without locks. Messages of bot itself aren't passed
+type Bot struct {
+	Loop     *utils.EventLoop
+	Client   *Client     // client of current connection
+	User     models.User // logged in account of bot
+	authData models.AuthRequest
+	stop     func(err error)
+
+	onLogin       func(bot *Bot)
+	onError       func(bot *Bot, serverError models.Error)
+	onMessage     []MessageHandler
+	subscriptions map[int64][]MessageHandler // map: channel id -> handlers
+}
+
+func NewBot(authData models.AuthRequest) *Bot {
+	// handlers have to be set before Run or inside other handlers
+	bot := &Bot{Loop: utils.NewEventLoop(), authData: authData,
+		stop: func(error) {}, subscriptions: make(map[int64][]MessageHandler)}
+	go bot.Loop.Run()
+	return bot
+}
+
+func (bot *Bot) OnLogin(onLogin func(bot *Bot)) {
+	// called after each connection, e.g. to send greeting
+	bot.onLogin = onLogin
+}
+
+func (bot *Bot) OnError(onError func(bot *Bot, serverError models.Error)) {
+	// errors of login stop Run, other errors are only passed to handler
+	bot.onError = onError
+}
+
+func (bot *Bot) OnMessage(handler MessageHandler) {
+	// handler gets messages of all channels
+	bot.onMessage = append(bot.onMessage, handler)
+}
+
+func (bot *Bot) Subscribe(channelId int64, handler MessageHandler) {
+	// handler gets messages of one channel. Channel of personal messages
+	// has id of user who writes to bot
+	bot.subscriptions[channelId] = append(bot.subscriptions[channelId], handler)
+}
+
+func (bot *Bot) Unsubscribe(channelId int64) {
+	delete(bot.subscriptions, channelId)
+}
+
+func (bot *Bot) SendMessage(channelId int64, text string) error {
+	if bot.Client == nil {
+		return ErrNotConnected
+	}
+	return bot.Client.SendMessage(channelId, text)
+}
+
+func (bot *Bot) Reply(msg models.SavedMessage, text string) error {
+	// answers in channel where message was received
+	if bot.Client == nil {
+		return ErrNotConnected
+	}
+	return bot.Client.SendReply(bot.GetChannelId(msg), text, msg.Id)
+}
+
+func (bot *Bot) GetChannelId(msg models.SavedMessage) int64 {
+	return GetMessageChannelId(msg.Message, bot.User.Id)
+}
+
+func (bot *Bot) Stop() {
+	// finishes Run, can be called from any goroutine
+	bot.Loop.Post(func() {
+		bot.stop(nil)
+	})
+}
+
+func (bot *Bot) Run(hostData utils.HostData) error {
+	// connects and logs in, then waits until Stop is called. Returns
+	// ErrNotConnected if server is unavailable or connection is lost, so caller
+	// can connect again. Other errors mean that bot can't log in
+	finished := make(chan error, 1)
+	finish := func(err error) {
+		select {
+		case finished <- err:
+		default:
+		}
+	}
+	client := NewClient()
+	client.SetDispatcher(bot.Loop.Post)
+	client.SetOnConnection(func() {
+		if err := client.Login(bot.authData); utils.IsError(err) {
+			finish(err)
+		}
+	})
+	client.SetOnDisconnection(func() {
+		finish(ErrNotConnected)
+	})
+	client.SetOnError(func(serverError models.Error) {
+		if serverError.IsAuthError() {
+			finish(errors.New(serverError.Description))
+			return
+		}
+		logger.Warning("Server error: " + serverError.Description)
+		if bot.onError != nil {
+			bot.onError(bot, serverError)
+		}
+	})
+	client.SetOnTwoFactorRequired(func(models.TwoFactorRequired) {
+		// code can't be entered by bot
+		finish(errors.New("Two-factor authentication can't be used by bot."))
+	})
+	client.SetOnLogin(func(authData models.SuccessfulAuth) {
+		bot.User = authData.User
+		if bot.onLogin != nil {
+			bot.onLogin(bot)
+		}
+	})
+	client.SetOnMessage(bot.processMessage)
+	// callbacks of previous connection don't see new client
+	bot.Loop.Invoke(func() {
+		bot.Client = client
+		bot.stop = finish
+	})
+
+	if err := client.Connect(network.ResolveHost(hostData)); utils.IsError(err) {
+		logger.Warning("Can't connect: " + err.Error())
+		return ErrNotConnected
+	}
+	defer client.Close()
+	return <-finished
+}
+
+func (bot *Bot) processMessage(msg models.SavedMessage) {
+	// answers of bot would be processed again
+	if msg.User.Id == bot.User.Id {
+		return
+	}
+	for _, handler := range bot.onMessage {
+		handler(bot, msg)
+	}
+	for _, handler := range bot.subscriptions[bot.GetChannelId(msg)] {
+		handler(bot, msg)
+	}
+}
@@ -0,0 +1,859 @@
+// chat_client.go
+package chatclient
+
+import (
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/graarh/golang-socketio/transport"
+	"github.com/satori/go.uuid"
+
+	"chat/codec"
+	"chat/encrypt"
+	"chat/logger"
+	"chat/models"
+	"chat/network"
+	"chat/socket"
+	"chat/utils"
+)
+
+var ErrNotConnected = errors.New("client is not connected")
+
+// connection to chat server. Data is encrypted by common key until login
+// and by secret key of user after it. Callbacks are called with decrypted data
+type Client struct {
+	socket    socket.Client
+	transport *network.TlsWebsocketTransport
+	CommonKey uuid.UUID
+	SecretKey uuid.UUID    // key for personal channels, received after login
+	User      models.User  // logged in user
+	Server    models.Hello // protocol version and features of server
+
+	payloadCodec codec.Codec // MessagePack if server supports it
+
+	dispatch func(f func()) // nil if handlers are called in goroutines of socket
+
+	onConnection     func()
+	onHello          func(serverHello models.Hello)
+	onDisconnection  func()
+	onLogin          func(authData models.SuccessfulAuth)
+	onError          func(serverError models.Error)
+	onLogout         func(logout models.Logout)
+	onMessage        func(msg models.SavedMessage)
+	onMessageEdited  func(msg models.SavedMessage)
+	onMessageDeleted func(msg models.SavedMessage)
+	onReactions      func(msg models.SavedMessage)
+	onMessagePinned  func(msg models.SavedMessage)
+	onMessageStatus  func(statusUpdate models.MessageStatusUpdate)
+	onTyping         func(typing models.Typing)
+	onPresence       func(presence models.Presence)
+	onPong           func(ping models.Ping)
+	onMessages       func(messagesPack models.SavedMessagesPack)
+	onSearchResult   func(result models.MessagesSearchResult)
+	onPinnedMessages func(pinnedPack models.PinnedMessagesPack)
+	onChannels       func(channelsPack models.ChannelsPack)
+	onChannelCreated func(channel models.Channel)
+	onChannelMembers func(membersPack models.ChannelMembersPack)
+	onFileChunk      func(chunk models.FileDownloadChunk)
+	onAvatar         func(avatar models.Avatar)
+	onAvatarUpdated  func(avatar models.Avatar)
+	onProfile        func(profile models.Profile)
+	onBlockedUsers   func(blockedUsers models.BlockedUsersPack)
+	onContacts       func(contactsPack models.ContactsPack)
+	onUsersFound     func(result models.UsersSearchResult)
+	onInvitations    func(invitationsPack models.InvitationsPack)
+	onChannelRenamed func(channel models.Channel)
+	onChannelRemoved func(channel models.Channel)
+	onRateLimited    func(limited models.RateLimited)
+	onMessageAck     func(ack models.MessageAck)
+
+	onTwoFactorRequired func(required models.TwoFactorRequired)
+	onTwoFactorSecret   func(secret models.TwoFactorSecret)
+	onTwoFactorStatus   func(status models.TwoFactorStatus)
+	onChallenge         func(challenge models.RegistrationChallenge)
+}
+
+func NewClient() *Client {
+	// callbacks have to be set before Connect
+	return &Client{CommonKey: uuid.FromBytesOrNil([]byte(utils.COMMON_SECRET_KEY)),
+		payloadCodec: codec.JSON}
+}
+
+func getTransport(hostData utils.HostData) (*network.TlsWebsocketTransport, error) {
+	// returns wss:// transport if secure connection is enabled.
+	// Transport of network package is used for proxy and compression
+	proxyUrl, err := network.GetProxyUrl(hostData.Proxy)
+	if utils.IsError(err) {
+		return nil, err
+	}
+	wsTransport := &network.TlsWebsocketTransport{
+		WebsocketTransport: *transport.GetDefaultWebsocketTransport()}
+	if hostData.Secure {
+		wsTransport, err = network.GetTlsWebsocketTransport(hostData.CaCertFile)
+		if utils.IsError(err) {
+			return nil, err
+		}
+	}
+	wsTransport.ProxyUrl = proxyUrl
+	return wsTransport, nil
+}
+
+func (c *Client) Connect(hostData utils.HostData) error {
+	wsTransport, err := getTransport(hostData)
+	if utils.IsError(err) {
+		return err
+	}
+	c.transport = wsTransport
+	c.payloadCodec = codec.JSON // until server tells its features
+	// handlers can be called as soon as connection is opened
+	c.socket = socket.NewClient(hostData.Transport, wsTransport)
+	c.initSocketCallbacks()
+	err = c.socket.Connect(socket.GetUrl(hostData.Transport, hostData.Host,
+		hostData.Port, hostData.Secure))
+	if utils.IsError(err) {
+		c.socket = nil
+		return err
+	}
+	return nil
+}
+
+func (c *Client) Close() {
+	if c.socket != nil {
+		c.socket.Close()
+	}
+}
+
+func (c *Client) emit(event string, data interface{}) error {
+	if c.socket == nil {
+		return ErrNotConnected
+	}
+	logger.Debug("-> " + event)
+	return c.socket.Emit(event, data)
+}
+
+func (c *Client) emitEncrypted(event string, data interface{}) error {
+	// sends data encrypted by secret key of logged in user
+	return c.emit(event, encrypt.EncryptWith(c.payloadCodec, c.SecretKey, data))
+}
+
+func (c *Client) on(event string, handler interface{}) {
+	// logs received event in verbose mode. Handler keeps its signature,
+	// because socket decodes arguments by it. Handlers don't return values,
+	// so they can be passed to dispatcher
+	handlerValue := reflect.ValueOf(handler)
+	logged := reflect.MakeFunc(handlerValue.Type(), func(args []reflect.Value) []reflect.Value {
+		logger.Debug("<- " + event)
+		if c.dispatch == nil {
+			return handlerValue.Call(args)
+		}
+		c.dispatch(func() {
+			handlerValue.Call(args)
+		})
+		return nil
+	})
+	c.socket.On(event, logged.Interface())
+}
+
+func (c *Client) initSocketCallbacks() {
+	// decrypts data of socket.io events and passes it to set callbacks
+
+	c.on(socket.EVENT_CONNECTION, func() {
+		// features stay disabled until server answers. Old servers don't answer
+		c.emit(EVENT_HELLO, models.Hello{Version: models.PROTOCOL_VERSION,
+			Features: models.GetAllFeatures()})
+		if c.onConnection != nil {
+			c.onConnection()
+		}
+	})
+	c.on(socket.EVENT_DISCONNECTION, func() {
+		if c.onDisconnection != nil {
+			c.onDisconnection()
+		}
+	})
+
+	c.on(EVENT_HELLO, func(serverHello models.Hello) {
+		c.Server = serverHello
+		c.transport.SetCompression(serverHello.HasFeature(models.FEATURE_COMPRESSION))
+		if serverHello.HasFeature(models.FEATURE_MSGPACK) {
+			c.payloadCodec = codec.MessagePack
+		}
+		if c.onHello != nil {
+			c.onHello(serverHello)
+		}
+	})
+
+	c.on(EVENT_LOGIN, func(encryptedAuthData string) {
+		authData := models.SuccessfulAuth{}
+		encrypt.Decrypt(c.CommonKey, encryptedAuthData, &authData)
+		c.User = authData.User
+		c.SecretKey = authData.SecretKey
+		if c.onLogin != nil {
+			c.onLogin(authData)
+		}
+	})
+	c.on(EVENT_ERROR, func(serverError models.Error) {
+		if c.onError != nil {
+			c.onError(serverError)
+		}
+	})
+	c.on(EVENT_LOGOUT, func(logout models.Logout) {
+		c.User = models.User{}
+		c.SecretKey = uuid.UUID{}
+		if c.onLogout != nil {
+			c.onLogout(logout)
+		}
+	})
+
+	c.on(EVENT_2FA_REQUIRED, func(encryptedRequired string) {
+		required := models.TwoFactorRequired{}
+		encrypt.Decrypt(c.CommonKey, encryptedRequired, &required)
+		if c.onTwoFactorRequired != nil {
+			c.onTwoFactorRequired(required)
+		}
+	})
+	c.on(EVENT_2FA_SECRET, func(encryptedSecret string) {
+		secret := models.TwoFactorSecret{}
+		encrypt.Decrypt(c.SecretKey, encryptedSecret, &secret)
+		if c.onTwoFactorSecret != nil {
+			c.onTwoFactorSecret(secret)
+		}
+	})
+	c.on(EVENT_2FA_STATUS, func(encryptedStatus string) {
+		status := models.TwoFactorStatus{}
+		encrypt.Decrypt(c.SecretKey, encryptedStatus, &status)
+		if c.onTwoFactorStatus != nil {
+			c.onTwoFactorStatus(status)
+		}
+	})
+	c.on(EVENT_REGISTRATION_CHALLENGE, func(challenge models.RegistrationChallenge) {
+		if c.onChallenge != nil {
+			c.onChallenge(challenge)
+		}
+	})
+
+	c.on(EVENT_MESSAGE, func(encryptedMessage string) {
+		msg := models.SavedMessage{}
+		encrypt.Decrypt(c.SecretKey, encryptedMessage, &msg)
+		if c.onMessage != nil {
+			c.onMessage(msg)
+		}
+	})
+	c.on(EVENT_EDIT_MESSAGE, func(encryptedMessage string) {
+		msg := models.SavedMessage{}
+		encrypt.Decrypt(c.SecretKey, encryptedMessage, &msg)
+		if c.onMessageEdited != nil {
+			c.onMessageEdited(msg)
+		}
+	})
+	c.on(EVENT_MESSAGE_REACTIONS, func(encryptedMessage string) {
+		msg := models.SavedMessage{}
+		encrypt.Decrypt(c.SecretKey, encryptedMessage, &msg)
+		if c.onReactions != nil {
+			c.onReactions(msg)
+		}
+	})
+	c.on(EVENT_MESSAGE_PINNED, func(encryptedMessage string) {
+		msg := models.SavedMessage{}
+		encrypt.Decrypt(c.SecretKey, encryptedMessage, &msg)
+		if c.onMessagePinned != nil {
+			c.onMessagePinned(msg)
+		}
+	})
+	c.on(EVENT_DELETE_MESSAGE, func(encryptedMessage string) {
+		msg := models.SavedMessage{}
+		encrypt.Decrypt(c.SecretKey, encryptedMessage, &msg)
+		if c.onMessageDeleted != nil {
+			c.onMessageDeleted(msg)
+		}
+	})
+	c.on(EVENT_MESSAGE_STATUS, func(encryptedStatus string) {
+		statusUpdate := models.MessageStatusUpdate{}
+		encrypt.Decrypt(c.SecretKey, encryptedStatus, &statusUpdate)
+		if c.onMessageStatus != nil {
+			c.onMessageStatus(statusUpdate)
+		}
+	})
+	c.on(EVENT_TYPING, func(encryptedTyping string) {
+		typing := models.Typing{}
+		encrypt.Decrypt(c.SecretKey, encryptedTyping, &typing)
+		if c.onTyping != nil {
+			c.onTyping(typing)
+		}
+	})
+	c.on(EVENT_PRESENCE, func(encryptedPresence string) {
+		presence := models.Presence{}
+		encrypt.Decrypt(c.SecretKey, encryptedPresence, &presence)
+		if c.onPresence != nil {
+			c.onPresence(presence)
+		}
+	})
+	c.on(EVENT_PONG, func(ping models.Ping) {
+		if c.onPong != nil {
+			c.onPong(ping)
+		}
+	})
+
+	c.on(EVENT_GET_MESSAGES, func(encryptedPack string) {
+		messagesPack := models.SavedMessagesPack{}
+		encrypt.Decrypt(c.SecretKey, encryptedPack, &messagesPack)
+		if c.onMessages != nil {
+			c.onMessages(messagesPack)
+		}
+	})
+	c.on(EVENT_GET_PINNED, func(encryptedPack string) {
+		pinnedPack := models.PinnedMessagesPack{}
+		encrypt.Decrypt(c.SecretKey, encryptedPack, &pinnedPack)
+		if c.onPinnedMessages != nil {
+			c.onPinnedMessages(pinnedPack)
+		}
+	})
+	c.on(EVENT_SEARCH_MESSAGES, func(encryptedResult string) {
+		result := models.MessagesSearchResult{}
+		encrypt.Decrypt(c.SecretKey, encryptedResult, &result)
+		if c.onSearchResult != nil {
+			c.onSearchResult(result)
+		}
+	})
+	c.on(EVENT_GET_CHANNELS, func(encryptedPack string) {
+		channelsPack := models.ChannelsPack{}
+		encrypt.Decrypt(c.SecretKey, encryptedPack, &channelsPack)
+		if c.onChannels != nil {
+			c.onChannels(channelsPack)
+		}
+	})
+	c.on(EVENT_CHANNEL_CREATED, func(encryptedChannel string) {
+		channel := models.Channel{}
+		encrypt.Decrypt(c.SecretKey, encryptedChannel, &channel)
+		if c.onChannelCreated != nil {
+			c.onChannelCreated(channel)
+		}
+	})
+	c.on(EVENT_CHANNEL_RENAMED, func(encryptedChannel string) {
+		channel := models.Channel{}
+		encrypt.Decrypt(c.SecretKey, encryptedChannel, &channel)
+		if c.onChannelRenamed != nil {
+			c.onChannelRenamed(channel)
+		}
+	})
+	c.on(EVENT_CHANNEL_REMOVED, func(encryptedChannel string) {
+		channel := models.Channel{}
+		encrypt.Decrypt(c.SecretKey, encryptedChannel, &channel)
+		if c.onChannelRemoved != nil {
+			c.onChannelRemoved(channel)
+		}
+	})
+	c.on(EVENT_GET_CHANNEL_MEMBERS, func(encryptedPack string) {
+		membersPack := models.ChannelMembersPack{}
+		encrypt.Decrypt(c.SecretKey, encryptedPack, &membersPack)
+		if c.onChannelMembers != nil {
+			c.onChannelMembers(membersPack)
+		}
+	})
+	c.on(EVENT_FILE_DOWNLOAD, func(encryptedChunk string) {
+		chunk := models.FileDownloadChunk{}
+		encrypt.Decrypt(c.SecretKey, encryptedChunk, &chunk)
+		if c.onFileChunk != nil {
+			c.onFileChunk(chunk)
+		}
+	})
+
+	c.on(EVENT_GET_AVATAR, func(encryptedAvatar string) {
+		avatar := models.Avatar{}
+		encrypt.Decrypt(c.SecretKey, encryptedAvatar, &avatar)
+		if c.onAvatar != nil {
+			c.onAvatar(avatar)
+		}
+	})
+	c.on(EVENT_AVATAR_UPDATED, func(encryptedAvatar string) {
+		avatar := models.Avatar{}
+		encrypt.Decrypt(c.SecretKey, encryptedAvatar, &avatar)
+		if c.onAvatarUpdated != nil {
+			c.onAvatarUpdated(avatar)
+		}
+	})
+	processProfile := func(encryptedProfile string) {
+		profile := models.Profile{}
+		encrypt.Decrypt(c.SecretKey, encryptedProfile, &profile)
+		if c.onProfile != nil {
+			c.onProfile(profile)
+		}
+	}
+	c.on(EVENT_GET_PROFILE, processProfile)
+	c.on(EVENT_PROFILE_UPDATED, processProfile)
+	c.on(EVENT_BLOCKED_USERS, func(encryptedUsers string) {
+		blockedUsers := models.BlockedUsersPack{}
+		encrypt.Decrypt(c.SecretKey, encryptedUsers, &blockedUsers)
+		if c.onBlockedUsers != nil {
+			c.onBlockedUsers(blockedUsers)
+		}
+	})
+	c.on(EVENT_GET_CONTACTS, func(encryptedPack string) {
+		contactsPack := models.ContactsPack{}
+		encrypt.Decrypt(c.SecretKey, encryptedPack, &contactsPack)
+		if c.onContacts != nil {
+			c.onContacts(contactsPack)
+		}
+	})
+	c.on(EVENT_SEARCH_USERS, func(encryptedResult string) {
+		result := models.UsersSearchResult{}
+		encrypt.Decrypt(c.SecretKey, encryptedResult, &result)
+		if c.onUsersFound != nil {
+			c.onUsersFound(result)
+		}
+	})
+	c.on(EVENT_RATE_LIMITED, func(encryptedLimited string) {
+		limited := models.RateLimited{}
+		encrypt.Decrypt(c.SecretKey, encryptedLimited, &limited)
+		if c.onRateLimited != nil {
+			c.onRateLimited(limited)
+		}
+	})
+	c.on(EVENT_MESSAGE_ACK, func(encryptedAck string) {
+		ack := models.MessageAck{}
+		encrypt.Decrypt(c.SecretKey, encryptedAck, &ack)
+		if c.onMessageAck != nil {
+			c.onMessageAck(ack)
+		}
+	})
+	c.on(EVENT_GET_INVITATIONS, func(encryptedPack string) {
+		invitationsPack := models.InvitationsPack{}
+		encrypt.Decrypt(c.SecretKey, encryptedPack, &invitationsPack)
+		if c.onInvitations != nil {
+			c.onInvitations(invitationsPack)
+		}
+	})
+}
+
+func (c *Client) HasFeature(feature string) bool {
+	// returns true if server said that it supports feature
+	return c.Server.HasFeature(feature)
+}
+
+func GetAuthRequest(username string, password string) models.AuthRequest {
+	// returns credentials with hashed password. Server hashes it again with salt
+	return models.AuthRequest{Username: username, PasswordHash: encrypt.GetPasswordHash(password),
+		Scheme: models.AUTH_SCHEME_SHA256}
+}
+
+func GetMessageChannelId(msg models.Message, userId int64) int64 {
+	// returns id of channel in which message is displayed for user
+	if msg.GetChatType() == "group" {
+		return msg.ChatId
+	}
+	if msg.User.Id == userId { // user's message or notes
+		return msg.ChatId
+	}
+	return msg.User.Id
+}
+
+func (c *Client) Login(authData models.AuthRequest) error {
+	return c.emit(EVENT_LOGIN, encrypt.Encrypt(c.CommonKey, authData))
+}
+
+func (c *Client) LoginByToken(token string) error {
+	// logs in by session token received after previous login
+	authData := models.TokenAuthRequest{Token: token}
+	return c.emit(EVENT_TOKEN_LOGIN, encrypt.Encrypt(c.CommonKey, authData))
+}
+
+func (c *Client) LoginByOAuth(login models.OAuthLogin) error {
+	// sends code which browser got from provider listed in hello
+	return c.emit(EVENT_OAUTH_LOGIN, encrypt.Encrypt(c.CommonKey, login))
+}
+
+func (c *Client) Logout(sessionToken string) error {
+	// closes session on server. Socket stays connected for next login
+	err := c.emitEncrypted(EVENT_LOGOUT, models.LogoutRequest{SessionToken: sessionToken})
+	c.User = models.User{}
+	c.SecretKey = uuid.UUID{}
+	return err
+}
+
+func (c *Client) Register(authData models.AuthRequest) error {
+	return c.emit(EVENT_REGISTER, encrypt.Encrypt(c.CommonKey, authData))
+}
+
+func (c *Client) RequestRegistrationChallenge() error {
+	// answer of challenge is sent in request of registration
+	return c.emit(EVENT_REGISTRATION_CHALLENGE, models.RegistrationChallenge{})
+}
+
+func (c *Client) ChangePassword(oldPassword string, newPassword string) error {
+	// all sessions of user are closed by server after change
+	change := models.PasswordChange{OldPasswordHash: encrypt.GetPasswordHash(oldPassword),
+		NewPasswordHash: encrypt.GetPasswordHash(newPassword), Scheme: models.AUTH_SCHEME_SHA256}
+	return c.emitEncrypted(EVENT_CHANGE_PASSWORD, change)
+}
+
+func (c *Client) DeleteAccount(password string) error {
+	deletion := models.AccountDeletion{PasswordHash: encrypt.GetPasswordHash(password),
+		Scheme: models.AUTH_SCHEME_SHA256}
+	return c.emitEncrypted(EVENT_DELETE_ACCOUNT, deletion)
+}
+
+func (c *Client) EnableTwoFactor(code string) error {
+	// empty code requests new secret, code of app made from it confirms secret
+	return c.emitEncrypted(EVENT_ENABLE_2FA, models.TwoFactorEnabling{Code: code})
+}
+
+func (c *Client) DisableTwoFactor(password string) error {
+	disabling := models.TwoFactorDisabling{PasswordHash: encrypt.GetPasswordHash(password),
+		Scheme: models.AUTH_SCHEME_SHA256}
+	return c.emitEncrypted(EVENT_DISABLE_2FA, disabling)
+}
+
+func (c *Client) SendMessage(chatId int64, text string) error {
+	return c.SendReply(chatId, text, 0)
+}
+
+func (c *Client) SendReply(chatId int64, text string, replyToId int64) error {
+	// replied message must be in same chat, otherwise server sends plain message
+	msg := models.Message{User: c.User, ChatId: chatId, Text: text, ReplyToId: replyToId}
+	return c.emitEncrypted(EVENT_MESSAGE, msg)
+}
+
+func (c *Client) SendQueuedMessage(msg models.QueuedMessage) error {
+	// server acknowledges message by its local id
+	ackedMsg := models.Message{User: c.User, ChatId: msg.ChatId, Text: msg.Text,
+		ReplyToId: msg.ReplyToId, LocalId: msg.LocalId}
+	return c.emitEncrypted(EVENT_MESSAGE, ackedMsg)
+}
+
+func (c *Client) ForwardMessage(chatId int64, messageId int64) error {
+	// server copies text of message and shows its author
+	msg := models.Message{User: c.User, ChatId: chatId, ForwardId: messageId}
+	return c.emitEncrypted(EVENT_MESSAGE, msg)
+}
+
+func (c *Client) SendSticker(chatId int64, stickerUrl string) error {
+	// stickers of local pack are sent as files by UploadFileChunk
+	msg := models.Message{User: c.User, ChatId: chatId, Sticker: stickerUrl}
+	return c.emitEncrypted(EVENT_MESSAGE, msg)
+}
+
+func (c *Client) EditMessage(messageId int64, text string) error {
+	// replaces text of own message
+	return c.emitEncrypted(EVENT_EDIT_MESSAGE, models.MessageEditing{Id: messageId, Text: text})
+}
+
+func (c *Client) DeleteMessage(messageId int64) error {
+	return c.emitEncrypted(EVENT_DELETE_MESSAGE, models.MessageDeletion{Id: messageId})
+}
+
+func (c *Client) React(messageId int64, emoji string) error {
+	// adds reaction or removes it if it was added before.
+	// Message with new reactions is passed to OnReactions callback
+	return c.emitEncrypted(EVENT_REACT, models.MessageReaction{MessageId: messageId, Emoji: emoji})
+}
+
+func (c *Client) PinMessage(messageId int64, isPinned bool) error {
+	// only owner can pin messages of created group
+	pin := models.MessagePin{MessageId: messageId, Pinned: isPinned}
+	return c.emitEncrypted(EVENT_PIN_MESSAGE, pin)
+}
+
+func (c *Client) Report(report models.Report) error {
+	// report with category goes to moderation queue of server, it isn't answered
+	return c.emitEncrypted(EVENT_REPORT, report)
+}
+
+func (c *Client) SendReadReceipt(partnerId int64, lastMessageId int64) error {
+	// notifies partner that his messages up to lastMessageId were read
+	messageRead := models.MessageRead{ChatId: partnerId, LastMessageId: lastMessageId}
+	return c.emitEncrypted(EVENT_MESSAGE_READ, messageRead)
+}
+
+func (c *Client) SendTyping(chatId int64) error {
+	return c.emitEncrypted(EVENT_TYPING, models.Typing{User: c.User, ChatId: chatId})
+}
+
+func (c *Client) SendPresence(state string) error {
+	return c.emitEncrypted(EVENT_PRESENCE, models.Presence{User: c.User, State: state})
+}
+
+func (c *Client) Ping(pingId int64) error {
+	// server answers with pong containing same ping
+	ping := models.Ping{Id: pingId, SentOn: time.Now().UnixNano() / int64(time.Millisecond)}
+	return c.emit(EVENT_PING, ping)
+}
+
+func (c *Client) RequestMessages(request models.MessagesRequest) error {
+	// requests page of chat history. Response is passed to OnMessages callback
+	request.User = c.User
+	return c.emit(EVENT_GET_MESSAGES, request)
+}
+
+func (c *Client) SearchMessages(query string, limit int) error {
+	return c.emit(EVENT_SEARCH_MESSAGES, models.MessagesSearchRequest{Query: query, Limit: limit})
+}
+
+func (c *Client) RequestPinnedMessages(chatId int64) error {
+	return c.emit(EVENT_GET_PINNED, models.PinnedMessagesRequest{ChatId: chatId})
+}
+
+func (c *Client) RequestChannels() error {
+	return c.emit(EVENT_GET_CHANNELS, models.ChannelsRequest{User: c.User})
+}
+
+func (c *Client) CreateChannel(title string, memberIds []int64) error {
+	creation := models.ChannelCreation{Title: title, MemberIds: memberIds}
+	return c.emitEncrypted(EVENT_CREATE_CHANNEL, creation)
+}
+
+func (c *Client) RequestChannelMembers(chatId int64) error {
+	return c.emit(EVENT_GET_CHANNEL_MEMBERS, models.ChannelMembersRequest{ChatId: chatId})
+}
+
+func (c *Client) KickMember(chatId int64, user models.User) error {
+	// server sends changed members to group
+	return c.emitEncrypted(EVENT_KICK_MEMBER, models.MemberKick{ChatId: chatId, User: user})
+}
+
+func (c *Client) SetMemberRole(chatId int64, user models.User, role string) error {
+	return c.emitEncrypted(EVENT_SET_MEMBER_ROLE,
+		models.MemberRoleChange{ChatId: chatId, User: user, Role: role})
+}
+
+func (c *Client) RenameChannel(chatId int64, title string) error {
+	return c.emitEncrypted(EVENT_RENAME_CHANNEL, models.ChannelRenaming{ChatId: chatId, Title: title})
+}
+
+func (c *Client) UploadFileChunk(chunk models.FileUploadChunk) error {
+	// server creates message with attachment after last chunk
+	return c.emitEncrypted(EVENT_FILE_UPLOAD, chunk)
+}
+
+func (c *Client) RequestAttachment(attachmentId int64) error {
+	// requests attached file. Its chunks are passed to OnFileChunk callback
+	return c.emit(EVENT_FILE_DOWNLOAD, models.FileDownloadRequest{AttachmentId: attachmentId})
+}
+
+func (c *Client) SetAvatar(data []byte) error {
+	// data is png image of AVATAR_SIZE, see network.EncodeAvatar
+	return c.emitEncrypted(EVENT_SET_AVATAR, models.AvatarUpload{Data: data})
+}
+
+func (c *Client) RequestAvatar(userId int64, cachedHash string) error {
+	// avatar is passed to OnAvatar callback. Its data is empty
+	// if cachedHash is hash of current avatar
+	return c.emit(EVENT_GET_AVATAR, models.AvatarRequest{UserId: userId, Hash: cachedHash})
+}
+
+func (c *Client) SetProfile(displayName string, statusText string) error {
+	// new profile is sent to all clients including this one
+	profile := models.Profile{User: c.User, DisplayName: displayName, StatusText: statusText}
+	return c.emitEncrypted(EVENT_SET_PROFILE, profile)
+}
+
+func (c *Client) RequestProfile(userId int64) error {
+	return c.emit(EVENT_GET_PROFILE, models.ProfileRequest{UserId: userId})
+}
+
+func (c *Client) BlockUser(user models.User, isBlocked bool) error {
+	// server sends new list of blocked users to all clients of user
+	return c.emitEncrypted(EVENT_BLOCK_USER, models.UserFilter{User: user, Blocked: isBlocked})
+}
+
+func (c *Client) RequestContacts() error {
+	return c.emit(EVENT_GET_CONTACTS, models.ContactsRequest{User: c.User})
+}
+
+func (c *Client) AddContact(user models.User) error {
+	// server sends new contacts list to all clients of user
+	return c.emitEncrypted(EVENT_ADD_CONTACT, models.ContactChange{User: user})
+}
+
+func (c *Client) RemoveContact(user models.User) error {
+	return c.emitEncrypted(EVENT_REMOVE_CONTACT, models.ContactChange{User: user})
+}
+
+func (c *Client) SearchUsers(query string, limit int) error {
+	return c.emit(EVENT_SEARCH_USERS, models.UsersSearchRequest{Query: query, Limit: limit})
+}
+
+func (c *Client) InviteToChannel(chatId int64, user models.User) error {
+	// invited user gets invitation in inbox
+	return c.emitEncrypted(EVENT_INVITE_TO_CHANNEL, models.ChannelInvitation{ChatId: chatId, User: user})
+}
+
+func (c *Client) RequestToJoin(title string) error {
+	// owner of group gets request in inbox
+	return c.emitEncrypted(EVENT_JOIN_REQUEST, models.JoinRequest{Title: title})
+}
+
+func (c *Client) RequestInvitations() error {
+	return c.emit(EVENT_GET_INVITATIONS, models.InvitationsRequest{User: c.User})
+}
+
+func (c *Client) AnswerInvitation(invitationId int64, isAccepted bool) error {
+	// accepted group is sent to new member as created channel
+	return c.emitEncrypted(EVENT_ANSWER_INVITATION,
+		models.InvitationAnswer{Id: invitationId, Accepted: isAccepted})
+}
+
+func (c *Client) SetDispatcher(dispatch func(f func())) {
+	// socket.io calls handlers of events in separate goroutines. Dispatcher
+	// gets them instead, so callbacks can be called one by one
+	c.dispatch = dispatch
+}
+
+func (c *Client) SetOnConnection(onConnection func()) {
+	c.onConnection = onConnection
+}
+
+func (c *Client) SetOnHello(onHello func(serverHello models.Hello)) {
+	c.onHello = onHello
+}
+
+func (c *Client) SetOnDisconnection(onDisconnection func()) {
+	c.onDisconnection = onDisconnection
+}
+
+func (c *Client) SetOnLogin(onLogin func(authData models.SuccessfulAuth)) {
+	c.onLogin = onLogin
+}
+
+func (c *Client) SetOnError(onError func(serverError models.Error)) {
+	// called with failed login, registration, account or channel change
+	c.onError = onError
+}
+
+func (c *Client) SetOnTwoFactorRequired(
+	onTwoFactorRequired func(required models.TwoFactorRequired)) {
+	// called instead of login when password is correct but code is needed
+	c.onTwoFactorRequired = onTwoFactorRequired
+}
+
+func (c *Client) SetOnTwoFactorSecret(onTwoFactorSecret func(secret models.TwoFactorSecret)) {
+	c.onTwoFactorSecret = onTwoFactorSecret
+}
+
+func (c *Client) SetOnTwoFactorStatus(onTwoFactorStatus func(status models.TwoFactorStatus)) {
+	// called when two-factor auth is enabled or disabled by any client of user
+	c.onTwoFactorStatus = onTwoFactorStatus
+}
+
+func (c *Client) SetOnChallenge(onChallenge func(challenge models.RegistrationChallenge)) {
+	c.onChallenge = onChallenge
+}
+
+func (c *Client) SetOnLogout(onLogout func(logout models.Logout)) {
+	c.onLogout = onLogout
+}
+
+func (c *Client) SetOnMessage(onMessage func(msg models.SavedMessage)) {
+	c.onMessage = onMessage
+}
+
+func (c *Client) SetOnMessageEdited(onMessageEdited func(msg models.SavedMessage)) {
+	c.onMessageEdited = onMessageEdited
+}
+
+func (c *Client) SetOnMessageDeleted(onMessageDeleted func(msg models.SavedMessage)) {
+	c.onMessageDeleted = onMessageDeleted
+}
+
+func (c *Client) SetOnReactions(onReactions func(msg models.SavedMessage)) {
+	c.onReactions = onReactions
+}
+
+func (c *Client) SetOnMessagePinned(onMessagePinned func(msg models.SavedMessage)) {
+	c.onMessagePinned = onMessagePinned
+}
+
+func (c *Client) SetOnMessageStatus(onMessageStatus func(statusUpdate models.MessageStatusUpdate)) {
+	c.onMessageStatus = onMessageStatus
+}
+
+func (c *Client) SetOnTyping(onTyping func(typing models.Typing)) {
+	c.onTyping = onTyping
+}
+
+func (c *Client) SetOnPresence(onPresence func(presence models.Presence)) {
+	c.onPresence = onPresence
+}
+
+func (c *Client) SetOnPong(onPong func(ping models.Ping)) {
+	c.onPong = onPong
+}
+
+func (c *Client) SetOnMessages(onMessages func(messagesPack models.SavedMessagesPack)) {
+	c.onMessages = onMessages
+}
+
+func (c *Client) SetOnSearchResult(onSearchResult func(result models.MessagesSearchResult)) {
+	c.onSearchResult = onSearchResult
+}
+
+func (c *Client) SetOnPinnedMessages(onPinnedMessages func(pinnedPack models.PinnedMessagesPack)) {
+	c.onPinnedMessages = onPinnedMessages
+}
+
+func (c *Client) SetOnChannels(onChannels func(channelsPack models.ChannelsPack)) {
+	c.onChannels = onChannels
+}
+
+func (c *Client) SetOnChannelCreated(onChannelCreated func(channel models.Channel)) {
+	c.onChannelCreated = onChannelCreated
+}
+
+func (c *Client) SetOnChannelRenamed(onChannelRenamed func(channel models.Channel)) {
+	c.onChannelRenamed = onChannelRenamed
+}
+
+func (c *Client) SetOnChannelRemoved(onChannelRemoved func(channel models.Channel)) {
+	// called when user is removed from group by its moderator
+	c.onChannelRemoved = onChannelRemoved
+}
+
+func (c *Client) SetOnChannelMembers(onChannelMembers func(membersPack models.ChannelMembersPack)) {
+	c.onChannelMembers = onChannelMembers
+}
+
+func (c *Client) SetOnFileChunk(onFileChunk func(chunk models.FileDownloadChunk)) {
+	c.onFileChunk = onFileChunk
+}
+
+func (c *Client) SetOnAvatar(onAvatar func(avatar models.Avatar)) {
+	c.onAvatar = onAvatar
+}
+
+func (c *Client) SetOnAvatarUpdated(onAvatarUpdated func(avatar models.Avatar)) {
+	// called without data when user changes avatar
+	c.onAvatarUpdated = onAvatarUpdated
+}
+
+func (c *Client) SetOnProfile(onProfile func(profile models.Profile)) {
+	// called with requested profiles and with changed ones
+	c.onProfile = onProfile
+}
+
+func (c *Client) SetOnBlockedUsers(onBlockedUsers func(blockedUsers models.BlockedUsersPack)) {
+	// called after login and when user is blocked or unblocked by other client
+	c.onBlockedUsers = onBlockedUsers
+}
+
+func (c *Client) SetOnContacts(onContacts func(contactsPack models.ContactsPack)) {
+	// called with requested contacts and after changes of contacts by any client of user
+	c.onContacts = onContacts
+}
+
+func (c *Client) SetOnUsersFound(onUsersFound func(result models.UsersSearchResult)) {
+	c.onUsersFound = onUsersFound
+}
+
+func (c *Client) SetOnRateLimited(onRateLimited func(limited models.RateLimited)) {
+	// called when server rejects message because user sends too often
+	c.onRateLimited = onRateLimited
+}
+
+func (c *Client) SetOnMessageAck(onMessageAck func(ack models.MessageAck)) {
+	// called when server saves message sent with local id
+	c.onMessageAck = onMessageAck
+}
+
+func (c *Client) SetOnInvitations(onInvitations func(invitationsPack models.InvitationsPack)) {
+	// called with requested inbox and when invitation or join request is added or answered
+	c.onInvitations = onInvitations
+}
@@ -0,0 +1,196 @@
+// chat_client_test.go
+package chatclient_test
+
+import (
+	"testing"
+	"time"
+
+	"chat/chatclient"
+	"chat/models"
+	"chat/testserver"
+	"chat/utils"
+)
+
+const EVENT_TIMEOUT = 5 * time.Second
+
+// client whose callbacks pass events to test in order of receiving
+type testClient struct {
+	*chatclient.Client
+	events chan interface{}
+}
+
+func connect(t *testing.T, server *testserver.TestServer) *testClient {
+	client := &testClient{Client: chatclient.NewClient(), events: make(chan interface{}, 100)}
+	connected := make(chan bool, 1)
+	client.SetOnConnection(func() { connected <- true })
+	client.SetOnLogin(func(authData models.SuccessfulAuth) { client.events <- authData })
+	client.SetOnError(func(serverError models.Error) { client.events <- serverError })
+	client.SetOnMessage(func(msg models.SavedMessage) { client.events <- msg })
+	client.SetOnMessages(func(pack models.SavedMessagesPack) { client.events <- pack })
+	client.SetOnChannels(func(pack models.ChannelsPack) { client.events <- pack })
+	if err := client.Connect(server.HostData); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-connected:
+	case <-time.After(EVENT_TIMEOUT):
+		t.Fatal("client isn't connected")
+	}
+	return client
+}
+
+func (client *testClient) next(t *testing.T) interface{} {
+	t.Helper()
+	select {
+	case event := <-client.events:
+		return event
+	case <-time.After(EVENT_TIMEOUT):
+		t.Fatal("event isn't received")
+	}
+	return nil
+}
+
+func (client *testClient) login(t *testing.T, username string) models.User {
+	t.Helper()
+	client.Login(chatclient.GetAuthRequest(username, "secret"))
+	authData, ok := client.next(t).(models.SuccessfulAuth)
+	if !ok {
+		t.Fatal(username + " isn't logged in")
+	}
+	// client keeps session of login
+	if client.User.Id != authData.User.Id || client.SecretKey != authData.SecretKey {
+		t.Fatalf("client has user %+v after login of %+v", client.User, authData.User)
+	}
+	return authData.User
+}
+
+func (client *testClient) nextMessage(t *testing.T) models.SavedMessage {
+	t.Helper()
+	msg, ok := client.next(t).(models.SavedMessage)
+	if !ok {
+		t.Fatal("message isn't received")
+	}
+	return msg
+}
+
+func (client *testClient) getMessages(t *testing.T, chatId int64) []models.SavedMessage {
+	t.Helper()
+	client.RequestMessages(models.MessagesRequest{ChatId: chatId})
+	pack, ok := client.next(t).(models.SavedMessagesPack)
+	if !ok || pack.ChatId != chatId {
+		t.Fatalf("messages of chat %d aren't received", chatId)
+	}
+	return pack.Messages
+}
+
+func TestClientMessages(t *testing.T) {
+	// login, message in main channel, then switch to private chat
+	server, err := testserver.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	alice, bob := connect(t, server), connect(t, server)
+	defer alice.Close()
+	defer bob.Close()
+	aliceUser := alice.login(t, "alice")
+	bobUser := bob.login(t, "bob")
+
+	alice.SendMessage(utils.GROUP_CHAT_ID, "hello all")
+	for _, client := range []*testClient{alice, bob} {
+		msg := client.nextMessage(t)
+		if msg.Text != "hello all" || msg.User.Id != aliceUser.Id ||
+			chatclient.GetMessageChannelId(msg.Message, client.User.Id) != utils.GROUP_CHAT_ID {
+			t.Errorf("%s got %+v instead of message of main channel", client.User.Username, msg)
+		}
+	}
+
+	// personal channel of users has id of partner
+	alice.SendMessage(bobUser.Id, "hi bob")
+	msg := alice.nextMessage(t)
+	if chatclient.GetMessageChannelId(msg.Message, aliceUser.Id) != bobUser.Id {
+		t.Errorf("alice shows own message in channel %d", msg.ChatId)
+	}
+	msg = bob.nextMessage(t)
+	if msg.Text != "hi bob" || chatclient.GetMessageChannelId(msg.Message, bobUser.Id) != aliceUser.Id {
+		t.Errorf("bob got %+v instead of personal message", msg)
+	}
+
+	bob.RequestChannels()
+	pack, ok := bob.next(t).(models.ChannelsPack)
+	if !ok || len(pack.Channels) != 1 || pack.Channels[0].Id != aliceUser.Id {
+		t.Errorf("channels of bob = %+v, want chat with alice", pack)
+	}
+	if messages := bob.getMessages(t, aliceUser.Id); len(messages) != 1 ||
+		messages[0].Text != "hi bob" {
+		t.Errorf("history of chat with alice = %+v", messages)
+	}
+	if messages := bob.getMessages(t, utils.GROUP_CHAT_ID); len(messages) != 1 ||
+		messages[0].Text != "hello all" {
+		t.Errorf("history of main channel = %+v", messages)
+	}
+	if len(server.Messages()) != 2 {
+		t.Errorf("server saved %d messages, want 2", len(server.Messages()))
+	}
+}
+
+func TestClientWrongPassword(t *testing.T) {
+	server, err := testserver.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client := connect(t, server)
+	defer client.Close()
+	client.login(t, "alice")
+
+	other := connect(t, server)
+	defer other.Close()
+	other.Login(chatclient.GetAuthRequest("alice", "wrong"))
+	serverError, ok := other.next(t).(models.Error)
+	if !ok || !serverError.IsAuthError() {
+		t.Errorf("login by wrong password got %+v", serverError)
+	}
+}
+
+func TestBotAnswers(t *testing.T) {
+	// bot gets message of subscribed channel and answers in it
+	server, err := testserver.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	bot := chatclient.NewBot(chatclient.GetAuthRequest("bot", "secret"))
+	bot.Subscribe(utils.GROUP_CHAT_ID, func(bot *chatclient.Bot, msg models.SavedMessage) {
+		bot.Reply(msg, "echo: "+msg.Text)
+	})
+	loggedIn := make(chan bool, 1)
+	bot.OnLogin(func(*chatclient.Bot) { loggedIn <- true })
+	finished := make(chan error, 1)
+	go func() { finished <- bot.Run(server.HostData) }()
+
+	client := connect(t, server)
+	defer client.Close()
+	client.login(t, "alice")
+	select {
+	case <-loggedIn:
+	case <-time.After(EVENT_TIMEOUT):
+		t.Fatal("bot isn't logged in")
+	}
+	client.SendMessage(utils.GROUP_CHAT_ID, "ping")
+	client.nextMessage(t) // own message
+	answer := client.nextMessage(t)
+	if answer.Text != "echo: ping" || answer.ReplyToId == 0 {
+		t.Errorf("answer of bot = %+v", answer)
+	}
+
+	bot.Stop()
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Errorf("bot finished by %v", err)
+		}
+	case <-time.After(EVENT_TIMEOUT):
+		t.Error("bot isn't stopped")
+	}
+}
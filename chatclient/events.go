@@ -0,0 +1,69 @@
+// events.go
+package chatclient
+
+// names of socket.io events of chat protocol
+const EVENT_HELLO = "/hello"
+const EVENT_ERROR = "/error"
+const EVENT_LOGIN = "/login"
+const EVENT_TOKEN_LOGIN = "/token-login"
+const EVENT_OAUTH_LOGIN = "/oauth-login"
+const EVENT_REGISTER = "/register"
+const EVENT_REGISTRATION_CHALLENGE = "/registration-challenge"
+const EVENT_CHANGE_PASSWORD = "/change-password"
+const EVENT_DELETE_ACCOUNT = "/delete-account"
+const EVENT_LOGOUT = "/logout"
+const EVENT_2FA_REQUIRED = "/2fa-required"
+const EVENT_ENABLE_2FA = "/enable-2fa"
+const EVENT_2FA_SECRET = "/2fa-secret"
+const EVENT_DISABLE_2FA = "/disable-2fa"
+const EVENT_2FA_STATUS = "/2fa-status"
+
+const EVENT_MESSAGE = "/message"
+const EVENT_RATE_LIMITED = "/rate-limited"
+const EVENT_MESSAGE_ACK = "/message-ack"
+const EVENT_EDIT_MESSAGE = "/edit-message"
+const EVENT_DELETE_MESSAGE = "/delete-message"
+const EVENT_REACT = "/react"
+const EVENT_MESSAGE_REACTIONS = "/message-reactions"
+const EVENT_PIN_MESSAGE = "/pin-message"
+const EVENT_MESSAGE_PINNED = "/message-pinned"
+const EVENT_REPORT = "/report"
+const EVENT_MESSAGE_READ = "/message-read"
+const EVENT_MESSAGE_STATUS = "/message-status"
+const EVENT_TYPING = "/typing"
+const EVENT_PRESENCE = "/presence"
+const EVENT_PING = "/ping"
+const EVENT_PONG = "/pong"
+
+const EVENT_GET_MESSAGES = "/get-messages"
+const EVENT_SEARCH_MESSAGES = "/search-messages"
+const EVENT_GET_PINNED = "/get-pinned"
+const EVENT_GET_CHANNELS = "/get-channels"
+const EVENT_CREATE_CHANNEL = "/create-channel"
+const EVENT_CHANNEL_CREATED = "/channel-created"
+const EVENT_GET_CHANNEL_MEMBERS = "/get-channel-members"
+const EVENT_KICK_MEMBER = "/kick-member"
+const EVENT_SET_MEMBER_ROLE = "/set-member-role"
+const EVENT_RENAME_CHANNEL = "/rename-channel"
+const EVENT_CHANNEL_RENAMED = "/channel-renamed"
+const EVENT_CHANNEL_REMOVED = "/channel-removed"
+
+const EVENT_FILE_UPLOAD = "/file-upload"
+const EVENT_FILE_DOWNLOAD = "/file-download"
+
+const EVENT_SET_AVATAR = "/set-avatar"
+const EVENT_GET_AVATAR = "/get-avatar"
+const EVENT_AVATAR_UPDATED = "/avatar-updated"
+const EVENT_SET_PROFILE = "/set-profile"
+const EVENT_GET_PROFILE = "/get-profile"
+const EVENT_PROFILE_UPDATED = "/profile-updated"
+const EVENT_BLOCK_USER = "/block-user"
+const EVENT_BLOCKED_USERS = "/blocked-users"
+const EVENT_ADD_CONTACT = "/add-contact"
+const EVENT_REMOVE_CONTACT = "/remove-contact"
+const EVENT_GET_CONTACTS = "/get-contacts"
+const EVENT_SEARCH_USERS = "/search-users"
+const EVENT_INVITE_TO_CHANNEL = "/invite-to-channel"
+const EVENT_JOIN_REQUEST = "/join-request"
+const EVENT_GET_INVITATIONS = "/get-invitations"
+const EVENT_ANSWER_INVITATION = "/answer-invitation"
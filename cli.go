@@ -0,0 +1,588 @@
+// cli.go
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"chat/chatclient"
+	"chat/models"
+	"chat/network"
+	"chat/utils"
+)
+
+const CLI_HISTORY_SIZE int = 20
+const CLI_RESPONSE_TIMEOUT = 15 * time.Second
+const CLI_MAIN_CHANNEL = "main"
+const CLI_NOTES_CHANNEL = "notes"
+const CLI_TIME_FORMAT = "2006-01-02 15:04"
+
+// terminal client for ssh sessions and scripts
+type CliApplication struct {
+	Client      *chatclient.Client
+	Channels    []models.Channel
+	ChatId      int64 // opened channel
+	Interactive bool  // messages of not opened channels are announced
+	Quiet       bool  // messages aren't printed
+	Closed      bool
+
+	ready        chan error // result of login and loading of channels
+	history      chan bool
+	sent         chan bool // own message came back from server
+	disconnected chan bool
+}
+
+func newCliApplication() *CliApplication {
+	return &CliApplication{
+		Client:       chatclient.NewClient(),
+		ChatId:       utils.GROUP_CHAT_ID,
+		ready:        make(chan error, 1),
+		history:      make(chan bool, 1),
+		sent:         make(chan bool, 1),
+		disconnected: make(chan bool, 1)}
+}
+
+func notify(event chan bool) {
+	// signals event without blocking if previous signal wasn't received
+	select {
+	case event <- true:
+	default:
+	}
+}
+
+func wait(event chan bool, errorText string) error {
+	select {
+	case <-event:
+		return nil
+	case <-time.After(CLI_RESPONSE_TIMEOUT):
+		return errors.New(errorText)
+	}
+}
+
+func (cli *CliApplication) finishStart(err error) {
+	select {
+	case cli.ready <- err:
+	default:
+	}
+}
+
+func (cli *CliApplication) start(hostData utils.HostData, authData models.AuthRequest) error {
+	// connects to server, logs in and loads channels list
+	hostData = network.ResolveHost(hostData)
+	client := cli.Client
+	client.SetOnConnection(func() {
+		client.Login(authData)
+	})
+	client.SetOnDisconnection(func() {
+		if cli.Closed {
+			return
+		}
+		cli.finishStart(errors.New("Connection was lost."))
+		notify(cli.disconnected)
+	})
+	client.SetOnError(func(serverError models.Error) {
+		if serverError.IsAuthError() {
+			cli.finishStart(errors.New(serverError.Description))
+		}
+	})
+	client.SetOnTwoFactorRequired(func(models.TwoFactorRequired) {
+		cli.finishStart(errors.New("Two-factor code is required, pass it with -code."))
+	})
+	client.SetOnLogin(func(models.SuccessfulAuth) {
+		client.RequestChannels()
+	})
+	client.SetOnChannels(func(channelsPack models.ChannelsPack) {
+		cli.Channels = channelsPack.Channels
+		cli.finishStart(nil)
+	})
+	client.SetOnChannelCreated(func(channel models.Channel) {
+		cli.Channels = append(cli.Channels, channel)
+	})
+	client.SetOnMessages(cli.processMessages)
+	client.SetOnMessage(cli.processNewMessage)
+
+	err := client.Connect(hostData)
+	if utils.IsError(err) {
+		return err
+	}
+	select {
+	case err = <-cli.ready:
+		return err
+	case <-time.After(CLI_RESPONSE_TIMEOUT):
+		return errors.New("Server doesn't respond.")
+	}
+}
+
+func (cli *CliApplication) close() {
+	cli.Closed = true
+	cli.Client.Close()
+}
+
+func (cli *CliApplication) processMessages(messagesPack models.SavedMessagesPack) {
+	// prints last messages of opened channel
+	if messagesPack.ChatId != cli.ChatId || messagesPack.BeforeId != 0 ||
+		messagesPack.AroundId != 0 {
+		return
+	}
+	if !cli.Quiet {
+		for _, msg := range messagesPack.Messages {
+			printMessage(msg)
+		}
+	}
+	notify(cli.history)
+}
+
+func (cli *CliApplication) processNewMessage(msg models.SavedMessage) {
+	currentUserId := cli.Client.User.Id
+	channelId := chatclient.GetMessageChannelId(msg.Message, currentUserId)
+	if channelId == cli.ChatId {
+		if !cli.Quiet {
+			printMessage(msg)
+		}
+		if msg.User.Id == currentUserId {
+			notify(cli.sent)
+		}
+	} else if cli.Interactive && msg.User.Id != currentUserId {
+		fmt.Printf("* New message in %s from %s\n",
+			cli.getChannelTitle(channelId), msg.User.Username)
+	}
+}
+
+func formatMessageText(msg models.SavedMessage) string {
+	// files and stickers are named, they can't be shown in terminal
+	text := msg.Text
+	if msg.HasAttachment() {
+		text = "[file] " + msg.Attachment.FileName
+	}
+	if msg.IsSticker() {
+		text = "[sticker] " + msg.Sticker
+	}
+	if msg.IsForwarded() {
+		text = "[forwarded from " + msg.ForwardedFrom + "] " + text
+	}
+	return text
+}
+
+func printMessage(msg models.SavedMessage) {
+	text := formatMessageText(msg)
+	createdOn := time.Unix(msg.CreatedOn, 0).Format(CLI_TIME_FORMAT)
+	fmt.Printf("[%s] %s: %s\n", createdOn, msg.User.Username, text)
+}
+
+func (cli *CliApplication) getChannelId(name string) (int64, error) {
+	// returns id of channel by title. Main and notes channels have fixed names
+	switch strings.ToLower(name) {
+	case CLI_MAIN_CHANNEL:
+		return utils.GROUP_CHAT_ID, nil
+	case CLI_NOTES_CHANNEL:
+		return cli.Client.User.Id, nil
+	}
+	for _, channel := range cli.Channels {
+		if channel.Title == name {
+			return channel.Id, nil
+		}
+	}
+	return 0, errors.New("Unknown channel: " + name)
+}
+
+func (cli *CliApplication) getChannelTitle(channelId int64) string {
+	if channelId == utils.GROUP_CHAT_ID {
+		return CLI_MAIN_CHANNEL
+	}
+	if channelId == cli.Client.User.Id {
+		return CLI_NOTES_CHANNEL
+	}
+	for _, channel := range cli.Channels {
+		if channel.Id == channelId {
+			return channel.Title
+		}
+	}
+	return fmt.Sprintf("#%d", channelId)
+}
+
+func (cli *CliApplication) printChannels() {
+	fmt.Println(CLI_MAIN_CHANNEL)
+	fmt.Println(CLI_NOTES_CHANNEL)
+	for _, channel := range cli.Channels {
+		if channel.Id == cli.Client.User.Id {
+			continue
+		}
+		chatType := "private"
+		if channel.Id <= 0 {
+			chatType = "group"
+		}
+		fmt.Printf("%s (%s)\n", channel.Title, chatType)
+	}
+}
+
+func (cli *CliApplication) openChannel(name string) error {
+	// makes channel opened and prints its last messages
+	chatId, err := cli.getChannelId(name)
+	if utils.IsError(err) {
+		return err
+	}
+	cli.ChatId = chatId
+	err = cli.Client.RequestMessages(models.MessagesRequest{
+		ChatId: chatId,
+		Limit:  CLI_HISTORY_SIZE})
+	if utils.IsError(err) {
+		return err
+	}
+	return wait(cli.history, "Messages were not received.")
+}
+
+func (cli *CliApplication) sendMessage(name string, text string) error {
+	// sends message and waits until server saves it
+	chatId, err := cli.getChannelId(name)
+	if utils.IsError(err) {
+		return err
+	}
+	cli.ChatId = chatId
+	err = cli.Client.SendMessage(chatId, text)
+	if utils.IsError(err) {
+		return err
+	}
+	return wait(cli.sent, "Message was not confirmed by server.")
+}
+
+func (cli *CliApplication) runInteractive() error {
+	// reads messages for opened channel and commands from stdin
+	// until /quit or end of input
+	cli.Interactive = true
+	printInteractiveHelp()
+	fmt.Println("Opened channel: " + CLI_MAIN_CHANNEL)
+	err := cli.openChannel(CLI_MAIN_CHANNEL)
+	if utils.IsError(err) {
+		return err
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "/") {
+			err = cli.Client.SendMessage(cli.ChatId, line)
+		} else {
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "/quit":
+				return nil
+			case "/channels":
+				cli.printChannels()
+			case "/open":
+				name := strings.TrimSpace(strings.TrimPrefix(line, "/open"))
+				err = cli.openChannel(name)
+			case "/help":
+				printInteractiveHelp()
+			default:
+				err = errors.New("Unknown command: " + fields[0])
+			}
+		}
+		if utils.IsError(err) {
+			fmt.Println(err.Error())
+		}
+	}
+	return scanner.Err()
+}
+
+func printInteractiveHelp() {
+	fmt.Println("Commands: /channels, /open <channel>, /help, /quit. " +
+		"Other lines are sent to opened channel.")
+}
+
+// relays messages between group channels and IRC channels. Private chats
+// with account of bridge are relayed as IRC queries: "nick: text" written
+// to bridge starts query with IRC user, "username: text" of IRC user starts
+// private chat. Answers go to last partner without prefix
+type IrcBridge struct {
+	Cli         *CliApplication
+	Irc         *network.IrcConnection
+	Loop        *utils.EventLoop
+	Nick        string                 // nick accepted by IRC server
+	ChatIds     map[string]int64       // map: lowercase IRC channel -> chat id
+	IrcChannels map[int64]string       // map: chat id -> IRC channel
+	Users       map[string]models.User // map: lowercase username -> user seen by bridge
+	Queries     map[int64]string       // map: user id -> nick of IRC partner
+	Partners    map[string]int64       // map: lowercase nick -> user id of chat partner
+
+	waitingUsers map[string][]ircQuery // map: searched username -> messages to user
+}
+
+// message of IRC user to chat user who isn't known yet
+type ircQuery struct {
+	Nick string
+	Text string
+}
+
+func newIrcBridge(cli *CliApplication) *IrcBridge {
+	return &IrcBridge{
+		Cli:          cli,
+		Loop:         utils.NewEventLoop(),
+		ChatIds:      make(map[string]int64),
+		IrcChannels:  make(map[int64]string),
+		Users:        make(map[string]models.User),
+		Queries:      make(map[int64]string),
+		Partners:     make(map[string]int64),
+		waitingUsers: make(map[string][]ircQuery)}
+}
+
+func getIrcChannelName(title string) string {
+	// IRC channels can't contain spaces, commas and colons
+	name := strings.NewReplacer(" ", "-", ",", "", ":", "", "\x07", "").Replace(title)
+	return "#" + strings.ToLower(strings.TrimPrefix(name, "#"))
+}
+
+func splitAddressedText(text string) (string, string, bool) {
+	// returns name and text of "name: text"
+	fields := strings.SplitN(text, ": ", 2)
+	if len(fields) != 2 || fields[0] == "" || strings.ContainsAny(fields[0], " \t\n") {
+		return "", "", false
+	}
+	return strings.TrimPrefix(fields[0], "@"), strings.TrimSpace(fields[1]), true
+}
+
+func (bridge *IrcBridge) mapChannels(mappings []string) error {
+	// mappings are "#irc-channel=chat channel". Without them all group
+	// channels are bridged to channels with same names
+	if len(mappings) == 0 {
+		mappings = append(mappings, getIrcChannelName(CLI_MAIN_CHANNEL)+"="+CLI_MAIN_CHANNEL)
+		for _, channel := range bridge.Cli.Channels {
+			if channel.Id < 0 {
+				mappings = append(mappings, getIrcChannelName(channel.Title)+"="+channel.Title)
+			}
+		}
+	}
+	for _, mapping := range mappings {
+		fields := strings.SplitN(mapping, "=", 2)
+		if len(fields) != 2 {
+			return errors.New("Channels are mapped as #irc-channel=chat-channel: " + mapping)
+		}
+		chatId, err := bridge.Cli.getChannelId(fields[1])
+		if utils.IsError(err) {
+			return err
+		}
+		ircChannel := getIrcChannelName(fields[0])
+		bridge.ChatIds[strings.ToLower(ircChannel)] = chatId
+		bridge.IrcChannels[chatId] = ircChannel
+	}
+	return nil
+}
+
+func (bridge *IrcBridge) run(address string, secure bool, nick string, mappings []string) error {
+	// relays messages until one of connections is lost
+	if err := bridge.mapChannels(mappings); utils.IsError(err) {
+		return err
+	}
+	irc, err := network.DialIrc(address, secure)
+	if utils.IsError(err) {
+		return err
+	}
+	defer irc.Close()
+	bridge.Irc = irc
+	go bridge.Loop.Run()
+	client := bridge.Cli.Client
+	client.SetDispatcher(bridge.Loop.Post)
+	client.SetOnMessage(bridge.processChatMessage)
+	client.SetOnUsersFound(bridge.processUsersFound)
+	if err := irc.Register(nick, "golang-chat bridge"); utils.IsError(err) {
+		return err
+	}
+	return irc.ReadMessages(func(msg network.IrcMessage) {
+		bridge.Loop.Post(func() { bridge.processIrcMessage(msg) })
+	})
+}
+
+func (bridge *IrcBridge) processChatMessage(msg models.SavedMessage) {
+	// messages of bridge account were relayed from IRC
+	bridgeUser := bridge.Cli.Client.User
+	if msg.User.Id == bridgeUser.Id {
+		return
+	}
+	bridge.Users[strings.ToLower(msg.User.Username)] = msg.User
+	text := formatMessageText(msg)
+	if msg.GetChatType() == "group" {
+		if ircChannel, ok := bridge.IrcChannels[msg.ChatId]; ok {
+			bridge.sendToIrc(ircChannel, "<"+msg.User.Username+"> "+text)
+		}
+		return
+	}
+	nick, addressedText, isAddressed := splitAddressedText(text)
+	if isAddressed {
+		bridge.linkQuery(msg.User, nick)
+		text = addressedText
+	} else if nick = bridge.Queries[msg.User.Id]; nick == "" {
+		bridge.Cli.Client.SendMessage(msg.User.Id, "Write \"nick: text\" to message IRC user.")
+		return
+	}
+	bridge.sendToIrc(nick, "<"+msg.User.Username+"> "+text)
+}
+
+func (bridge *IrcBridge) sendToIrc(target string, text string) {
+	if err := bridge.Irc.SendMessage(target, text); utils.IsError(err) {
+		log.Println("Can't send to IRC: " + err.Error())
+	}
+}
+
+func (bridge *IrcBridge) linkQuery(user models.User, nick string) {
+	// answers of both partners go to each other without prefix
+	bridge.Queries[user.Id] = nick
+	bridge.Partners[strings.ToLower(nick)] = user.Id
+}
+
+func (bridge *IrcBridge) processIrcMessage(msg network.IrcMessage) {
+	switch msg.Command {
+	case network.IRC_RPL_WELCOME:
+		bridge.Nick = msg.GetParam(0)
+		for _, ircChannel := range bridge.IrcChannels {
+			bridge.Irc.Send("JOIN", ircChannel)
+		}
+	case "PRIVMSG":
+		nick, target, text := msg.GetNick(), msg.GetParam(0), msg.GetParam(1)
+		if strings.HasPrefix(text, "\x01ACTION ") { // /me of IRC client
+			text = "* " + nick + " " + strings.Trim(text[len("\x01ACTION "):], "\x01")
+		} else if strings.HasPrefix(text, "\x01") { // other CTCP requests aren't relayed
+			return
+		} else {
+			text = "<" + nick + "> " + text
+		}
+		if chatId, ok := bridge.ChatIds[strings.ToLower(target)]; ok {
+			bridge.Cli.Client.SendMessage(chatId, text)
+		} else if strings.EqualFold(target, bridge.Nick) {
+			bridge.processIrcQuery(nick, msg.GetParam(1), text)
+		}
+	}
+}
+
+func (bridge *IrcBridge) processIrcQuery(nick string, rawText string, text string) {
+	// query to bridge is relayed to chat user addressed by it or to last partner
+	username, addressedText, isAddressed := splitAddressedText(rawText)
+	if !isAddressed {
+		userId, ok := bridge.Partners[strings.ToLower(nick)]
+		if !ok {
+			bridge.Irc.Send("NOTICE", nick, "Write \"username: text\" to message chat user.")
+			return
+		}
+		bridge.Cli.Client.SendMessage(userId, text)
+		return
+	}
+	text = "<" + nick + "> " + addressedText
+	user, ok := bridge.Users[strings.ToLower(username)]
+	if !ok {
+		// user is searched on server, messages wait for result
+		query := strings.ToLower(username)
+		bridge.waitingUsers[query] = append(bridge.waitingUsers[query], ircQuery{nick, text})
+		bridge.Cli.Client.SearchUsers(query, 10)
+		return
+	}
+	bridge.linkQuery(user, nick)
+	bridge.Cli.Client.SendMessage(user.Id, text)
+}
+
+func (bridge *IrcBridge) processUsersFound(result models.UsersSearchResult) {
+	query := strings.ToLower(result.Query)
+	queries := bridge.waitingUsers[query]
+	delete(bridge.waitingUsers, query)
+	for _, user := range result.Users {
+		if strings.ToLower(user.Username) == query {
+			bridge.Users[query] = user
+		}
+	}
+	user, ok := bridge.Users[query]
+	for _, waiting := range queries {
+		if !ok {
+			bridge.Irc.Send("NOTICE", waiting.Nick, "User "+result.Query+" is not found.")
+			continue
+		}
+		bridge.linkQuery(user, waiting.Nick)
+		bridge.Cli.Client.SendMessage(user.Id, waiting.Text)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: cli [options] [command]")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  channels                 print channels")
+	fmt.Fprintln(os.Stderr, "  tail <channel>           print last messages and new ones")
+	fmt.Fprintln(os.Stderr, "  send <channel> <text>    send message")
+	fmt.Fprintln(os.Stderr, "  bridge <host:port> [#irc-channel=channel...]")
+	fmt.Fprintln(os.Stderr, "                           relay messages to IRC server")
+	fmt.Fprintln(os.Stderr, "Without command messages are read from stdin. Options:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	profileName := flag.String("profile", "", "server profile (active profile by default)")
+	username := flag.String("user", "", "username")
+	password := flag.String("password", "",
+		"password (CHAT_PASSWORD environment variable is used by default)")
+	code := flag.String("code", "", "one-time code of authenticator app if two-factor "+
+		"authentication is enabled")
+	ircNick := flag.String("irc-nick", "", "nick of bridge on IRC server (username by default)")
+	ircPlain := flag.Bool("irc-plain", false, "connect to IRC server without TLS")
+	flag.Usage = printUsage
+	flag.Parse()
+	args := flag.Args()
+
+	settings := utils.GetSettingsFromFile()
+	if *profileName != "" {
+		err := settings.SelectProfile(*profileName)
+		if utils.IsError(err) {
+			log.Fatal(err)
+		}
+	}
+	if *password == "" {
+		*password = os.Getenv("CHAT_PASSWORD")
+	}
+	if *username == "" || *password == "" {
+		fmt.Fprintln(os.Stderr, "Username and password are required.")
+		os.Exit(2)
+	}
+
+	cli := newCliApplication()
+	if len(args) > 0 && (args[0] == "send" || args[0] == "bridge") {
+		cli.Quiet = true
+	}
+	authData := chatclient.GetAuthRequest(*username, *password)
+	authData.Code = *code
+	err := cli.start(settings.HostData, authData)
+	if utils.IsError(err) {
+		log.Fatal(err)
+	}
+	defer cli.close()
+	go func() {
+		<-cli.disconnected
+		log.Fatal("Connection was lost.")
+	}()
+
+	switch {
+	case len(args) == 0:
+		err = cli.runInteractive()
+	case args[0] == "channels":
+		cli.printChannels()
+	case args[0] == "tail" && len(args) == 2:
+		err = cli.openChannel(args[1])
+		if !utils.IsError(err) {
+			select {} // new messages are printed until connection is lost
+		}
+	case args[0] == "send" && len(args) >= 3:
+		err = cli.sendMessage(args[1], strings.Join(args[2:], " "))
+	case args[0] == "bridge" && len(args) >= 2:
+		if *ircNick == "" {
+			*ircNick = *username
+		}
+		err = newIrcBridge(cli).run(args[1], !*ircPlain, *ircNick, args[2:])
+	default:
+		printUsage()
+		os.Exit(2)
+	}
+	if utils.IsError(err) {
+		log.Fatal(err)
+	}
+}
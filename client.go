@@ -2,37 +2,191 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-
-	"log"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/graarh/golang-socketio"
-	"github.com/graarh/golang-socketio/transport"
 	"github.com/satori/go.uuid"
 
+	"chat/chatclient"
+	"chat/db"
 	"chat/encrypt"
 	"chat/gui"
+	"chat/i18n"
+	"chat/logger"
 	"chat/models"
+	"chat/network"
 	"chat/utils"
 )
 
+const TYPING_SEND_INTERVAL = 3 * time.Second
+const MESSAGES_PAGE_SIZE int = 50
+const IMAGES_CACHE_DIR = "images_cache"
+const IDLE_TIMEOUT = 5 * time.Minute
+const MAX_SEARCH_RESULTS int = 100
+const PRESENCE_CHECK_INTERVAL = 30 * time.Second
+const RECONNECT_MIN_DELAY = 2 * time.Second
+const RECONNECT_MAX_DELAY = 2 * time.Minute
+const MAX_RECONNECT_ATTEMPTS int = 20
+const PING_INTERVAL = 15 * time.Second
+const REMEMBER_ERROR = "Keyring is not available, session isn't remembered after exit."
+const SHRUG = `¯\_(ツ)_/¯`
+const PONG_TIMEOUT = 45 * time.Second // connection is dead if there is no answer
+const MIN_VOICE_DURATION = time.Second
+const SCHEDULE_CHECK_INTERVAL = 10 * time.Second
+const TEST_CONNECTION_TIMEOUT = 5 * time.Second // waiting for hello of server
+const ACK_MIN_TIMEOUT = 5 * time.Second
+const ACK_MAX_TIMEOUT = time.Minute
+const MAX_SEND_ATTEMPTS int = 4
+const OUTGOING_CHECK_INTERVAL = time.Second
+
+// state is changed only in event loop: callbacks of socket, gui and timers
+// are posted to it. Goroutines which wait for network post their results
 type ChatApplication struct {
-	Client        *gosocketio.Client
+	Loop          *utils.EventLoop
+	Client        *chatclient.Client
 	Connected     bool
 	CurrentUser   models.User
 	CommonKey     uuid.UUID
-	SecretKey     uuid.UUID // key for personal channels
 	LoggedIn      bool
 	CurrentChatId int64
 	Channels      []models.Channel
+	Invitations   []models.Invitation // inbox shown by gui
 	Gui           *gui.ChatGui
+	OutgoingQueue []*outgoingMessage // messages without ack of server
+	LastLocalId   int64
+	MessagesCache db.MessagesStorage
+	Notifications utils.NotificationSettings
+	ProfileName   string              // active server profile
+	AccountName   string              // active account of profile, empty before first login
+	LastAuthData  *models.AuthRequest // credentials for login after reconnection
+
+	RememberSession bool   // token of session is saved in keyring for login after restart
+	SessionToken    string // token of session which isn't remembered, kept until exit
+
+	Challenge models.RegistrationChallenge // last challenge of registration from server
+
+	Sounds utils.SoundSettings // sounds of incoming messages
+
+	LastConnectionError string // shown in status bar while disconnected
+	cancelReconnection  context.CancelFunc
+	LastPingId          int64
+	LastPongTime        time.Time
+
+	LastTypingTime   time.Time
+	LastTypingChatId int64
+
+	Presence         string    // state reported to server
+	LastActivityTime time.Time // last user input
+
+	OldestMessageId  int64 // id of first displayed message
+	JumpMessageId    int64 // message which history is loaded around
+	HasOlderMessages bool
+	IsLoadingOlder   bool
+
+	Downloads    map[int64]*attachmentDownload // map: attachment id -> download
+	ImagesCache  *network.ImagesCache
+	AvatarHashes map[int64]string // map: user id -> hash of cached avatar. Requested users only
+	Profiles     map[int64]bool   // users whose profiles were requested
+
+	UserFilters          map[int64]models.UserFilter          // map: user id -> blocked or muted user
+	ChannelNotifications map[int64]utils.ChannelNotifications // map: chat id -> options of chat
+	MessageFilters       *utils.MessageMatcher                // words of hidden or collapsed messages
+
+	Drafts map[int64]string // map: chat id -> unsent text of chat
+
+	Commands *utils.CommandRegistry // slash commands of input box
+	Plugins  *utils.Plugins         // programs of plugins dir adding commands and renderers
+
+	RestoreChatId int64 // chat opened on previous exit, selected once channels are loaded
+	LinkPreviews  bool  // pages of links in messages are requested for previews
+
+	StickersDir string // local sticker pack, empty if there is no pack
+	StickersUrl string // provider of stickers, empty if there is no provider
+
+	VoiceRecorder *utils.VoiceRecorder // nil if voice message isn't recorded
+	VoicePlayer   *exec.Cmd            // player of voice message, nil if nothing is played
+
+	OAuthCallback     *network.OAuthCallback // nil if login at provider isn't waited
+	OAuthLoginPending bool                   // OAuth login of user with two-factor auth waits for code
+}
+
+// queued message is sent again until server acknowledges it
+type outgoingMessage struct {
+	models.QueuedMessage
+	Backoff *network.Backoff
+	RetryOn time.Time // zero if message wasn't sent yet
+}
+
+// attachment which is being received from server
+type attachmentDownload struct {
+	Writer   io.WriteCloser
+	Chunks   *utils.ChunkWriter // writes chunks to Writer in order of offsets
+	OnFinish func(err error)    // err is nil if whole file was received
 }
 
-func (chatApp *ChatApplication) init() {
-	// Creates main window
-	chatApp.Gui = gui.NewChatGui()
+func (chatApp *ChatApplication) init(newGui func() *gui.ChatGui) {
+	// Creates main window by newGui. Language is set before, because texts
+	// of gui are translated when widgets are created
+	settings := utils.GetSettingsFromFile()
+	err := i18n.SetLanguage(settings.Language)
+	if utils.IsError(err) {
+		logger.Warning("Can't load language: " + err.Error())
+	}
+	chatApp.Gui = newGui()
+	chatApp.Loop = utils.NewEventLoop()
+	chatApp.Gui.SetDispatcher(chatApp.Loop.Post)
 	chatApp.CommonKey = uuid.FromBytesOrNil([]byte(utils.COMMON_SECRET_KEY))
+	chatApp.ProfileName = settings.ActiveProfile
+	chatApp.AccountName = settings.GetActiveAccount()
+	chatApp.RememberSession = true // saved session of account is used at start
+	chatApp.MessagesCache.ConnectSqlite(utils.GetMessagesCacheFile(chatApp.ProfileName,
+		chatApp.AccountName))
+	chatApp.Notifications = settings.NotificationSettings
+	chatApp.ChannelNotifications = settings.GetChannelNotifications()
+	chatApp.Sounds = settings.SoundSettings
+	chatApp.LinkPreviews = settings.LinkPreviews
+	chatApp.StickersDir, chatApp.StickersUrl = settings.StickersDir, settings.StickersUrl
+	chatApp.setMessageFilters(settings.MessageFilters)
+	chatApp.loadSpellChecker(settings.SpellCheckLanguage)
+	chatApp.Gui.ApplyAppearance(settings.Theme, settings.AccentColor, settings.FontSize)
+	if !settings.Window.Forget {
+		chatApp.Gui.RestoreWindowState(settings.Window)
+		chatApp.RestoreChatId = settings.GetLastChannel()
+	}
+	chatApp.Gui.SetOnSaveWindowState(func(state utils.WindowState) {
+		chatApp.Loop.Invoke(func() { chatApp.saveWindowState(state) })
+	})
+	chatApp.Gui.SetOnSaveSettings(chatApp.saveSettings)
+	chatApp.Gui.SetOnSetDoNotDisturb(chatApp.setDoNotDisturb)
+	chatApp.showDoNotDisturb()
+	chatApp.Gui.SetOnScheduleMessage(chatApp.scheduleMessage)
+	chatApp.Gui.SetOnCancelScheduled(chatApp.cancelScheduled)
+	chatApp.Gui.SetOnTestConnection(chatApp.testConnection)
+	chatApp.Gui.SetOnSwitchServer(chatApp.switchServer)
+	chatApp.Gui.SetOnSwitchAccount(chatApp.switchAccount)
+	chatApp.Gui.SetOnRemoveAccount(chatApp.removeAccount)
+	chatApp.Gui.SetAccounts(settings.GetAccounts(), chatApp.AccountName)
+	chatApp.Gui.SetOnRetryConnection(chatApp.retryConnection)
+	chatApp.Downloads = make(map[int64]*attachmentDownload)
+	chatApp.ImagesCache = network.NewImagesCache(IMAGES_CACHE_DIR)
+	chatApp.AvatarHashes = make(map[int64]string)
+	chatApp.Profiles = make(map[int64]bool)
+	chatApp.UserFilters = make(map[int64]models.UserFilter)
+	chatApp.Drafts = make(map[int64]string)
+	chatApp.Plugins = utils.LoadPlugins(utils.PLUGINS_DIR)
+	chatApp.Gui.SetOnRenderMessage(chatApp.renderMessage)
+	chatApp.registerCommands()
 
 	chatApp.CurrentChatId = 0 // main channel
 	chatApp.Connected = false
@@ -40,56 +194,296 @@ func (chatApp *ChatApplication) init() {
 }
 
 func (chatApp *ChatApplication) startReconnectionTrying() {
-	// if connection was lost this function
-	// will try reconnect after 10s, 1m, 2m, 3m, etc...
-	time.Sleep(10 * time.Second)
-	for i := 0; i < 5; i++ {
-		if i != 0 {
-			sleepDuration, _ := time.ParseDuration(fmt.Sprintf("%dm", i))
-			time.Sleep(sleepDuration)
-		}
-		host, port := utils.GetHostDataFromSettingsFile()
-		fmt.Printf("Try reconnect to: %s:%d\n", host, port)
-		if chatApp.connect(host, port, true) {
-			chatApp.Gui.ShowInfo("Connection restored!")
+	// if connection was lost this function will try reconnect
+	// with growing delays. Previous trying is canceled
+	chatApp.stopReconnectionTrying()
+	ctx, cancel := context.WithCancel(context.Background())
+	chatApp.cancelReconnection = cancel
+	go chatApp.tryReconnect(ctx)
+}
+
+func (chatApp *ChatApplication) stopReconnectionTrying() {
+	if chatApp.cancelReconnection != nil {
+		chatApp.cancelReconnection()
+		chatApp.cancelReconnection = nil
+	}
+}
+
+func (chatApp *ChatApplication) tryReconnect(ctx context.Context) {
+	backoff := network.NewBackoff(RECONNECT_MIN_DELAY, RECONNECT_MAX_DELAY)
+	for backoff.Attempt() < MAX_RECONNECT_ATTEMPTS {
+		if !chatApp.waitReconnection(ctx, backoff.Next()) {
+			return // canceled
+		}
+		hostData := utils.GetHostSettingsFromFile()
+		logger.Infof("Try reconnect to: %s:%d", hostData.Host, hostData.Port)
+		if chatApp.connect(hostData, true) {
 			return
-		} else {
-			fmt.Printf("Unsuccess reconnect to: %s:%d\nNext try after %d minutes\n",
-				host, port, i+1)
 		}
 	}
-	chatApp.Gui.ShowInfo("We couldn't restore connection :(\n" +
-		"Please, try change host information in settings file " +
-		"and restart application.")
+	chatApp.Loop.Post(func() {
+		chatApp.Gui.SetOffline(chatApp.LastConnectionError)
+	})
+}
+
+func (chatApp *ChatApplication) waitReconnection(ctx context.Context,
+	delay time.Duration) bool {
+	// shows countdown before next attempt. Returns false if trying was canceled
+	deadline := time.Now().Add(delay)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return true
+		}
+		chatApp.Loop.Post(func() {
+			chatApp.Gui.SetReconnecting(remaining, chatApp.LastConnectionError)
+		})
+		tick := time.Second
+		if remaining < tick {
+			tick = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(tick):
+		}
+	}
+}
+
+func (chatApp *ChatApplication) retryConnection() {
+	// connects immediately instead of waiting for next attempt
+	chatApp.stopReconnectionTrying()
+	go func() {
+		if !chatApp.connect(utils.GetHostSettingsFromFile(), true) {
+			chatApp.Loop.Post(chatApp.startReconnectionTrying)
+		}
+	}()
 }
 
-func (chatApp *ChatApplication) connect(host string, port int, isReconnect bool) bool {
-	client, err := gosocketio.Dial(
-		gosocketio.GetUrl(host, port, false),
-		transport.GetDefaultWebsocketTransport())
+func (chatApp *ChatApplication) connect(hostData utils.HostData, isReconnect bool) bool {
+	// waits for connection outside of event loop, so it's called in goroutine.
+	// Returns true if connection is established
+	hostData = network.ResolveHost(hostData)
+	address := fmt.Sprintf("%s:%d", hostData.Host, hostData.Port)
+	var client *chatclient.Client
+	chatApp.Loop.Invoke(func() {
+		chatApp.Gui.SetConnecting(address)
+		// client is set before connection in order to be known to its callbacks
+		client = chatclient.NewClient()
+		client.SetDispatcher(chatApp.Loop.Post)
+		chatApp.Client = client
+		chatApp.Gui.SetServerFeatures(nil) // until server tells its features
+		chatApp.initClientCallbacks(client)
+	})
+	proxyUrl, err := network.GetProxyUrl(hostData.Proxy)
+	if !utils.IsError(err) {
+		chatApp.ImagesCache.SetProxy(proxyUrl)
+		err = client.Connect(hostData)
+	}
+	isConnected := false
+	chatApp.Loop.Invoke(func() {
+		isConnected = chatApp.finishConnection(address, isReconnect, err)
+	})
+	return isConnected
+}
 
+func (chatApp *ChatApplication) finishConnection(address string, isReconnect bool,
+	err error) bool {
 	if utils.IsError(err) {
+		logger.Warningf("Can't connect to host \"%s\": %s", address, err.Error())
+		chatApp.LastConnectionError = err.Error()
+		chatApp.Gui.SetOffline(chatApp.LastConnectionError)
 		if !isReconnect {
-			info := fmt.Sprintf("Can't connect to host \"%s:%d\"\n"+
-				"Next try after: 10 sec\n"+
-				"Description: %s\n", host, port, err.Error())
-
-			chatApp.Gui.ShowError(info)
-			go chatApp.startReconnectionTrying()
+			chatApp.startReconnectionTrying()
 		}
 		return false
 	}
 
-	chatApp.Client = client
+	chatApp.LastConnectionError = ""
+	chatApp.LastPongTime = time.Now()
+	chatApp.Gui.SetConnected(address)
 
 	chatApp.Connected = true
-	chatApp.Gui.SetOnClose(chatApp.Client.Close)
+	if isReconnect {
+		// new socket has no session on server
+		chatApp.LoggedIn = false
+		chatApp.Gui.DisableSend()
+	}
+	chatApp.Gui.SetOnClose(func() {
+		chatApp.Loop.Invoke(func() {
+			chatApp.sendPresence(models.PRESENCE_OFFLINE)
+			if chatApp.Client != nil {
+				chatApp.Client.Close()
+			}
+		})
+	})
 
 	chatApp.initGuiCallbacks()
-	chatApp.initClientCallbacks()
 	return true
 }
 
+func (chatApp *ChatApplication) reconnect(hostData utils.HostData) {
+	// closes current connection and connects to host from settings.
+	// User has to log in again
+	chatApp.stopReconnectionTrying()
+	oldClient := chatApp.Client
+	chatApp.Client = nil
+	chatApp.Connected = false
+	chatApp.LoggedIn = false
+	chatApp.cancelDownloads()
+	chatApp.Gui.DisableLoginButtons()
+	chatApp.Gui.DisableSend()
+	if oldClient != nil {
+		oldClient.Close()
+	}
+	logger.Infof("Connect to: %s:%d", hostData.Host, hostData.Port)
+	go chatApp.connect(hostData, false)
+}
+
+func (chatApp *ChatApplication) saveSessionToken(token string) {
+	// remembered token is saved in keyring for login after restart. Other
+	// token is used for reconnections only
+	if !chatApp.RememberSession {
+		utils.RemoveSessionToken(chatApp.ProfileName, chatApp.AccountName)
+		chatApp.SessionToken = token
+		return
+	}
+	chatApp.SessionToken = "" // saved token is read again, notifier can replace it
+	err := utils.SaveSessionToken(chatApp.ProfileName, chatApp.AccountName, token)
+	if utils.IsError(err) {
+		// token isn't written anywhere else, session ends on exit
+		logger.Error(err)
+		chatApp.SessionToken = token
+		chatApp.RememberSession = false
+		chatApp.Gui.SetRememberError(REMEMBER_ERROR)
+		chatApp.Gui.ShowError(REMEMBER_ERROR)
+	}
+}
+
+func (chatApp *ChatApplication) loadSessionToken() string {
+	// returns token of current or saved session or empty string
+	if chatApp.SessionToken != "" {
+		return chatApp.SessionToken
+	}
+	return utils.LoadSessionToken(chatApp.ProfileName, chatApp.AccountName)
+}
+
+func (chatApp *ChatApplication) removeSessionToken() {
+	chatApp.SessionToken = ""
+	utils.RemoveSessionToken(chatApp.ProfileName, chatApp.AccountName)
+}
+
+func (chatApp *ChatApplication) openMessagesCache() {
+	// cache of active account replaces cache of previous one
+	chatApp.MessagesCache.Close()
+	chatApp.MessagesCache.ConnectSqlite(utils.GetMessagesCacheFile(chatApp.ProfileName,
+		chatApp.AccountName))
+}
+
+func (chatApp *ChatApplication) switchServer(profileName string) {
+	// disconnects, forgets data of current server and connects to profile host
+	settings := utils.GetSettingsFromFile()
+	err := settings.SelectProfile(profileName)
+	if !utils.IsError(err) {
+		err = utils.SaveSettings(settings)
+	}
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(err.Error())
+		return
+	}
+
+	chatApp.CurrentUser = models.User{}
+	chatApp.CurrentChatId = utils.GROUP_CHAT_ID
+	chatApp.RestoreChatId = utils.GROUP_CHAT_ID
+	chatApp.Channels = nil
+	chatApp.OutgoingQueue = nil
+	chatApp.JumpMessageId = 0
+	chatApp.Drafts = make(map[int64]string)
+	chatApp.ProfileName = profileName
+	chatApp.AccountName = settings.GetActiveAccount()
+	chatApp.LastAuthData = nil
+	chatApp.AvatarHashes = make(map[int64]string) // ids of users of another server
+	chatApp.Profiles = make(map[int64]bool)
+	chatApp.ChannelNotifications = settings.GetChannelNotifications()
+	chatApp.Gui.ClearSession()
+	chatApp.openMessagesCache()
+	chatApp.showAccounts()
+	chatApp.reconnect(settings.HostData)
+}
+
+func (chatApp *ChatApplication) saveSettings(settings utils.Settings, reconnect bool) {
+	// writes settings file and applies new settings.
+	// Do not disturb mode could be toggled while settings were opened
+	settings.DoNotDisturb = chatApp.Notifications.DoNotDisturb
+	err := utils.SaveSettings(settings)
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(i18n.Tf("Can't save settings: %s", err.Error()))
+		return
+	}
+	chatApp.Notifications = settings.NotificationSettings
+	chatApp.ChannelNotifications = settings.GetChannelNotifications()
+	chatApp.Sounds = settings.SoundSettings
+	chatApp.LinkPreviews = settings.LinkPreviews
+	chatApp.StickersDir, chatApp.StickersUrl = settings.StickersDir, settings.StickersUrl
+	chatApp.setMessageFilters(settings.MessageFilters)
+	chatApp.loadSpellChecker(settings.SpellCheckLanguage)
+	chatApp.showChannelNotifications()
+	chatApp.showDoNotDisturb()
+	chatApp.updatePresence()
+	chatApp.Gui.ApplyAppearance(settings.Theme, settings.AccentColor, settings.FontSize)
+	if reconnect { // host of profile could be changed
+		chatApp.switchServer(settings.ActiveProfile)
+	} else if chatApp.LoggedIn { // displayed messages are filtered again
+		chatApp.showCachedMessages(chatApp.CurrentChatId)
+	}
+}
+
+func (chatApp *ChatApplication) saveWindowState(state utils.WindowState) {
+	// remembers window and opened chat on exit
+	settings := utils.GetSettingsFromFile()
+	if settings.Window.Forget {
+		return
+	}
+	settings.Window = state
+	if chatApp.LoggedIn {
+		settings.SetLastChannel(chatApp.CurrentChatId)
+	}
+	if err := utils.SaveSettings(settings); utils.IsError(err) {
+		logger.Warning("Can't save window state: " + err.Error())
+	}
+}
+
+func (chatApp *ChatApplication) testConnection(hostData utils.HostData,
+	onResult func(string, error)) {
+	// connects to host by separate client, current connection stays opened
+	go func() {
+		hostData = network.ResolveHost(hostData)
+		address := fmt.Sprintf("%s:%d", hostData.Host, hostData.Port)
+		hello := make(chan models.Hello, 1)
+		client := chatclient.NewClient()
+		client.SetOnHello(func(serverHello models.Hello) {
+			select {
+			case hello <- serverHello:
+			default:
+			}
+		})
+		err := client.Connect(hostData)
+		if utils.IsError(err) {
+			logger.Infof("Test connection to %s failed: %s", address, err.Error())
+			onResult("", err)
+			return
+		}
+		defer client.Close()
+		select {
+		case serverHello := <-hello:
+			onResult(i18n.Tf("Server %s answered, protocol version %d.", address,
+				serverHello.Version), nil)
+		case <-time.After(TEST_CONNECTION_TIMEOUT):
+			onResult(i18n.Tf("Connected to %s, server doesn't tell its version.", address), nil)
+		}
+	}()
+}
+
 func (chatApp *ChatApplication) initGuiCallbacks() {
 	chatApp.Gui.SetCallbacks(
 		chatApp.sendMessage,
@@ -103,203 +497,2507 @@ func (chatApp *ChatApplication) initGuiCallbacks() {
 			channelId := chatApp.getChannelId(title)
 			chatApp.openChannel(channelId)
 		},
-		func(user models.User) {
-			if user.Id == chatApp.CurrentUser.Id { // NOTES CHANNEL
-				chatApp.Gui.SelectChannel(gui.NOTES_CHANNEL_TITLE)
-				return
-			}
-
-			if !chatApp.isChannelInList(user.Id) {
-				newChannel := models.Channel{user.Id, user.Username}
-				chatApp.Gui.AppendChannel(newChannel.Title)
-				chatApp.Channels = append(chatApp.Channels, newChannel)
-			}
-			chatApp.Gui.SelectChannel(user.Username)
-		},
+		chatApp.openChannelByUser,
 		chatApp.sendLoginData,
 		chatApp.sendRegisterData)
+	chatApp.Gui.SetOnTyping(chatApp.sendTyping)
+	chatApp.Gui.SetOnLoadOlderMessages(chatApp.loadOlderMessages)
+	chatApp.Gui.SetOnAttachFile(chatApp.sendFile)
+	chatApp.Gui.SetOnDownloadAttachment(chatApp.downloadAttachment)
+	chatApp.Gui.SetOnLoadImagePreview(chatApp.loadImagePreview)
+	chatApp.Gui.SetOnLoadLinkPreview(chatApp.loadLinkPreview)
+	chatApp.Gui.SetOnLoadStickers(chatApp.loadStickers)
+	chatApp.Gui.SetOnSendSticker(chatApp.sendSticker)
+	chatApp.Gui.SetOnEditMessage(chatApp.editMessage)
+	chatApp.Gui.SetOnDeleteMessage(chatApp.deleteMessage)
+	chatApp.Gui.SetOnReact(chatApp.react)
+	chatApp.Gui.SetOnPinMessage(chatApp.pinMessage)
+	chatApp.Gui.SetOnForwardMessage(chatApp.forwardMessage)
+	chatApp.Gui.SetOnRetryMessage(chatApp.retryMessage)
+	chatApp.Gui.SetOnReport(chatApp.report)
+	chatApp.Gui.SetOnCreateChannel(chatApp.createChannel)
+	chatApp.Gui.SetOnSearchMessages(chatApp.searchMessages)
+	chatApp.Gui.SetOnSearchResultSelect(chatApp.jumpToMessage)
+	chatApp.Gui.SetOnSendReply(chatApp.sendReply)
+	chatApp.Gui.SetOnChangePassword(chatApp.changePassword)
+	chatApp.Gui.SetOnTwoFactorCodeSubmit(chatApp.sendLoginCode)
+	chatApp.Gui.SetOnOAuthLogin(chatApp.loginByOAuth)
+	chatApp.Gui.SetOnRequestChallenge(chatApp.requestChallenge)
+	chatApp.Gui.SetOnEnableTwoFactor(chatApp.enableTwoFactor)
+	chatApp.Gui.SetOnDisableTwoFactor(chatApp.disableTwoFactor)
+	chatApp.Gui.SetOnLogout(chatApp.logout)
+	chatApp.Gui.SetOnDeleteAccount(chatApp.deleteAccount)
+	chatApp.Gui.SetOnSetAvatar(chatApp.setAvatar)
+	chatApp.Gui.SetOnSetProfile(chatApp.setProfile)
+	chatApp.Gui.SetOnUserShown(chatApp.loadUserInfo)
+	chatApp.Gui.SetOnSetUserFilter(chatApp.setUserFilter)
+	chatApp.Gui.SetOnSetChannelNotifications(chatApp.setChannelNotifications)
+	chatApp.Gui.SetOnExportChat(chatApp.exportChat)
+	chatApp.Gui.SetOnImportChat(chatApp.importChat)
+	chatApp.Gui.SetOnStartRecording(func() bool {
+		isStarted := false
+		chatApp.Loop.Invoke(func() { isStarted = chatApp.startRecording() })
+		return isStarted
+	})
+	chatApp.Gui.SetOnStopRecording(chatApp.stopRecording)
+	chatApp.Gui.SetOnPlayVoice(chatApp.playVoice)
+	chatApp.Gui.SetOnStopVoice(chatApp.stopVoice)
+	chatApp.Gui.SetOnAddContact(func(user models.User) {
+		chatApp.setContact(user, true)
+	})
+	chatApp.Gui.SetOnRemoveContact(func(user models.User) {
+		chatApp.setContact(user, false)
+	})
+	chatApp.Gui.SetOnSearchUsers(chatApp.searchUsers)
+	chatApp.Gui.SetOnInviteToChannel(chatApp.inviteToChannel)
+	chatApp.Gui.SetOnJoinChannel(chatApp.requestToJoin)
+	chatApp.Gui.SetOnAnswerInvitation(chatApp.answerInvitation)
+	chatApp.Gui.SetOnKickMember(chatApp.kickMember)
+	chatApp.Gui.SetOnSetMemberRole(chatApp.setMemberRole)
+	chatApp.Gui.SetOnRenameChannel(chatApp.renameChannel)
 }
 
-func (chatApp *ChatApplication) initClientCallbacks() {
-	// sets callbacks to socket.io client
-	client := chatApp.Client
-
-	client.On(gosocketio.OnDisconnection, func(h *gosocketio.Channel) {
+func (chatApp *ChatApplication) initClientCallbacks(client *chatclient.Client) {
+	// sets callbacks to chat client
+	client.SetOnDisconnection(func() {
+		// send stays enabled: new messages are queued until reconnection
+		if client != chatApp.Client { // connection was closed by reconnect
+			return
+		}
+		chatApp.Connected = false
+		chatApp.cancelDownloads()
 		chatApp.Gui.DisableLoginButtons()
-		chatApp.Gui.DisableSend()
-		chatApp.Gui.ShowError("Disconnected!")
+		if chatApp.LastConnectionError == "" { // not detected by heartbeat
+			chatApp.LastConnectionError = "connection was lost"
+		}
+		chatApp.startReconnectionTrying()
 	})
 
-	client.On(gosocketio.OnConnection, func(h *gosocketio.Channel) {
-		log.Println("Connected")
+	client.SetOnConnection(func() {
+		logger.Info("Connected")
+		chatApp.LastPingId++
+		client.Ping(chatApp.LastPingId) // latency is shown before next heartbeat
 		chatApp.Gui.EnableLoginButtons()
+		chatApp.sendSavedLoginData()
 	})
 
-	client.On("/failed-login", chatApp.processFailedAuth)
-	client.On("/failed-registeration", chatApp.processFailedAuth)
+	client.SetOnHello(chatApp.processHello)
+	client.SetOnError(chatApp.processError)
+	client.SetOnLogin(chatApp.processSuccessfulLogin)
+	client.SetOnLogout(chatApp.processLogout)
+	client.SetOnTwoFactorRequired(func(models.TwoFactorRequired) {
+		chatApp.Gui.ShowTwoFactorCodeDialog(i18n.T("Enter code from authenticator app."))
+	})
+	client.SetOnTwoFactorSecret(chatApp.Gui.ShowTwoFactorSecret)
+	client.SetOnChallenge(func(challenge models.RegistrationChallenge) {
+		chatApp.Challenge = challenge
+		chatApp.Gui.SetRegistrationChallenge(challenge)
+	})
+	client.SetOnTwoFactorStatus(func(status models.TwoFactorStatus) {
+		chatApp.Gui.ShowTwoFactorStatus(status.Enabled)
+	})
 
-	client.On("/login", chatApp.processSuccessfulLogin)
+	client.SetOnMessage(chatApp.processNewMessage)
+	client.SetOnMessageEdited(chatApp.processMessageEditing)
+	client.SetOnMessageDeleted(chatApp.processMessageDeletion)
+	client.SetOnReactions(chatApp.processMessageEditing)
+	client.SetOnMessagePinned(chatApp.processMessageEditing)
+	client.SetOnTyping(chatApp.processTyping)
+	client.SetOnPresence(chatApp.processPresence)
+	client.SetOnPong(chatApp.processPong)
+	client.SetOnMessageStatus(chatApp.processMessageStatus)
+	client.SetOnRateLimited(chatApp.processRateLimit)
+	client.SetOnMessageAck(chatApp.processMessageAck)
 
-	client.On("/message", chatApp.processNewMessage)
+	client.SetOnMessages(chatApp.processMessagesReceiving)
+	client.SetOnSearchResult(chatApp.processMessagesSearch)
+	client.SetOnPinnedMessages(chatApp.processPinnedMessages)
+	client.SetOnChannels(chatApp.processChannelsReceiving)
+	client.SetOnChannelCreated(chatApp.processChannelCreated)
+	client.SetOnChannelRenamed(chatApp.processChannelRenamed)
+	client.SetOnChannelRemoved(chatApp.processChannelRemoval)
+	client.SetOnChannelMembers(chatApp.processChannelMembersReceiving)
+	client.SetOnFileChunk(chatApp.processFileDownload)
+	client.SetOnAvatar(chatApp.processAvatar)
+	client.SetOnAvatarUpdated(chatApp.processAvatarUpdate)
+	client.SetOnProfile(chatApp.Gui.SetProfile)
+	client.SetOnBlockedUsers(chatApp.processBlockedUsers)
+	client.SetOnContacts(chatApp.processContacts)
+	client.SetOnUsersFound(chatApp.Gui.SetUserSearchResult)
+	client.SetOnInvitations(chatApp.processInvitations)
+}
 
-	client.On("/get-messages", chatApp.processMessagesReceiving)
-	client.On("/get-channels", chatApp.processChannelsReceiving)
+func (chatApp *ChatApplication) processHello(serverHello models.Hello) {
+	// disables actions which server doesn't support
+	logger.Infof("Server uses protocol version %d", serverHello.Version)
+	chatApp.Gui.SetServerFeatures(serverHello.Features)
+	chatApp.Gui.SetOAuthProviders(serverHello.OAuthProviders)
 }
 
-func (chatApp *ChatApplication) processSuccessfulLogin(h *gosocketio.Channel,
-	encryptedAuthData string) {
-	log.Println("LOGIN")
-	authData := models.SuccessfulAuth{}
-	encrypt.Decrypt(chatApp.CommonKey, encryptedAuthData, &authData)
+func (chatApp *ChatApplication) processSuccessfulLogin(authData models.SuccessfulAuth) {
+	logger.Info("LOGIN")
+	// after reconnection same user stays in opened channel
+	isSameUser := chatApp.CurrentUser.Id == authData.User.Id
 	chatApp.CurrentUser = authData.User
-	chatApp.SecretKey = authData.SecretKey
 	chatApp.LoggedIn = true
-	chatApp.CurrentChatId = utils.GROUP_CHAT_ID
+	chatApp.OAuthLoginPending = false
+	if chatApp.AccountName != authData.User.Username {
+		chatApp.setAccount(authData.User.Username)
+	}
+	if authData.SessionToken != "" {
+		chatApp.saveSessionToken(authData.SessionToken)
+	}
+	chatApp.JumpMessageId = 0
+	if !isSameUser {
+		chatApp.CurrentChatId = utils.GROUP_CHAT_ID
+	}
+	chatApp.Presence = models.PRESENCE_ACTIVE // set by server after login
+	chatApp.LastActivityTime = time.Now()
 
 	chatApp.Gui.SetProfileInfo(authData.User.Username)
+	chatApp.Gui.SetCurrentUser(authData.User)
+	chatApp.Gui.SetTwoFactorEnabled(authData.TwoFactor)
 
+	chatApp.loadUserFilters()
 	chatApp.Gui.ClearMessages()
+	chatApp.showCachedMessages(chatApp.CurrentChatId)
 	chatApp.loadChannels()
+	chatApp.loadContacts()
+	chatApp.loadInvitations()
 	chatApp.loadMessages(chatApp.CurrentChatId)
+	chatApp.loadChannelMembers(chatApp.CurrentChatId)
+	chatApp.reloadUserInfo()
+	chatApp.loadUserInfo(authData.User) // display name of current user
 	chatApp.Gui.EnableSend()
+	chatApp.flushMessageQueue()
+	chatApp.loadScheduledMessages()
+	chatApp.sendScheduledMessages() // messages which were due while offline
+	chatApp.updatePresence()
+}
+
+func (chatApp *ChatApplication) processError(serverError models.Error) {
+	// shows localized error and recovers by failed request
+	logger.Warning(serverError.Code + ": " + serverError.Description)
+	text := gui.GetErrorText(serverError)
+	if serverError.IsAuthError() {
+		chatApp.processFailedAuth(serverError, text)
+		return
+	}
+	isChannelOutdated := serverError.Code == models.ERROR_PERMISSION_DENIED ||
+		serverError.Code == models.ERROR_CHANNEL_NOT_FOUND ||
+		serverError.Code == models.ERROR_ALREADY_MEMBER
+	if isChannelOutdated && chatApp.Connected && chatApp.LoggedIn {
+		// channels or roles were changed by others meanwhile
+		chatApp.loadChannels()
+		chatApp.loadChannelMembers(chatApp.CurrentChatId)
+	}
+	chatApp.Gui.ShowError(text)
 }
 
-func (chatApp *ChatApplication) processFailedAuth(h *gosocketio.Channel,
-	errorData models.AuthError) {
-	log.Println(errorData.Description)
-	if errorData.Process == "login" {
-		chatApp.Gui.ShowLoginDialog(errorData.Description)
+func (chatApp *ChatApplication) processFailedAuth(serverError models.Error, text string) {
+	if serverError.Process == models.PROCESS_TOKEN_LOGIN {
+		chatApp.removeSessionToken()
+		if chatApp.LastAuthData != nil { // password is known since last login
+			chatApp.sendSavedLoginData()
+			return
+		}
+		chatApp.Gui.ShowLoginDialog(text)
+	} else if serverError.Code == models.ERROR_WRONG_CODE &&
+		(chatApp.LastAuthData != nil || chatApp.OAuthLoginPending) {
+		// password was accepted, only code is asked again
+		chatApp.Gui.ShowTwoFactorCodeDialog(text)
+	} else if serverError.Process == models.PROCESS_LOGIN ||
+		serverError.Process == models.PROCESS_OAUTH_LOGIN {
+		chatApp.LastAuthData = nil
+		chatApp.OAuthLoginPending = false
+		chatApp.Gui.ShowLoginDialog(text)
 	} else {
-		chatApp.Gui.ShowRegisterDialog(errorData.Description)
+		chatApp.LastAuthData = nil
+		chatApp.Gui.ShowRegisterDialog(text)
 	}
 	chatApp.LoggedIn = false
 }
 
-func (chatApp *ChatApplication) processNewMessage(h *gosocketio.Channel,
-	encryptedMessage string) {
-	// adds new message to list after obtaing data from server
-	msg := models.SavedMessage{}
-	encrypt.Decrypt(chatApp.SecretKey, encryptedMessage, &msg)
+func (chatApp *ChatApplication) setAccount(username string) {
+	// logged in user becomes active account with own token and cache.
+	// Cache used before first login is moved to account
+	isFirstLogin := chatApp.AccountName == ""
+	oldCacheFile := utils.GetMessagesCacheFile(chatApp.ProfileName, chatApp.AccountName)
+	if isFirstLogin {
+		chatApp.removeSessionToken() // token of account is saved after login
+	}
+	chatApp.AccountName = username
+	settings := utils.GetSettingsFromFile()
+	settings.SetActiveAccount(username)
+	if err := utils.SaveSettings(settings); utils.IsError(err) {
+		logger.Error(err)
+	}
 
-	if chatApp.canDisplayNewMessage(msg) {
-		chatApp.Gui.AddMessage(msg)
+	cacheFile := utils.GetMessagesCacheFile(chatApp.ProfileName, username)
+	chatApp.MessagesCache.Close()
+	if _, err := os.Stat(cacheFile); isFirstLogin && os.IsNotExist(err) {
+		os.Rename(oldCacheFile, cacheFile)
 	}
+	chatApp.MessagesCache.ConnectSqlite(cacheFile)
+	chatApp.showAccounts()
 }
 
-func (chatApp *ChatApplication) processMessagesReceiving(h *gosocketio.Channel,
-	encryptedPack string) {
-	messagesPack := models.SavedMessagesPack{}
-	encrypt.Decrypt(chatApp.SecretKey, encryptedPack, &messagesPack)
-	messages := messagesPack.Messages
-	fmt.Printf("Got Messages. count = %d\n", len(messages))
-	chatApp.Gui.SetMessages(messages)
+func (chatApp *ChatApplication) switchAccount(username string) {
+	// closes session without revoking its token, so account can be switched back.
+	// Empty username adds new account by login
+	if username == chatApp.AccountName && chatApp.LoggedIn {
+		return
+	}
+	logger.Info("Switch account to " + username)
+	isOnline := chatApp.Connected && chatApp.LoggedIn
+	canLogout := isOnline && chatApp.Client.HasFeature(models.FEATURE_LOGOUT)
+	if canLogout {
+		chatApp.Client.Logout("")
+	}
+	chatApp.resetSession()
+	chatApp.AccountName = username
+	if username != "" {
+		settings := utils.GetSettingsFromFile()
+		settings.SetActiveAccount(username)
+		if err := utils.SaveSettings(settings); utils.IsError(err) {
+			logger.Error(err)
+		}
+	}
+	chatApp.openMessagesCache()
+	chatApp.showAccounts()
+
+	if isOnline && !canLogout {
+		chatApp.reconnect(utils.GetHostSettingsFromFile()) // token is sent after connection
+	} else if chatApp.Connected {
+		chatApp.sendSavedLoginData()
+	}
+	if chatApp.loadSessionToken() == "" {
+		title := i18n.T("Add account")
+		if username != "" {
+			title = i18n.Tf("Log in as %s", username)
+		}
+		chatApp.Gui.ShowLoginDialog(title)
+	}
 }
 
-func (chatApp *ChatApplication) processChannelsReceiving(h *gosocketio.Channel,
-	encryptedPack string) {
-	channelsPack := models.ChannelsPack{}
-	encrypt.Decrypt(chatApp.SecretKey, encryptedPack, &channelsPack)
-	channels := channelsPack.Channels
-	fmt.Printf("Got channels. count = %d\n", len(channels))
-	chatApp.Channels = channels
-	chatApp.Gui.SetChannels(channels)
+func (chatApp *ChatApplication) removeAccount(username string) {
+	// active account is logged out. Token of other account stays valid
+	// on server until it expires
+	if username == chatApp.AccountName {
+		chatApp.logout()
+		return
+	}
+	utils.RemoveSessionToken(chatApp.ProfileName, username)
+	os.Remove(utils.GetMessagesCacheFile(chatApp.ProfileName, username))
+	chatApp.forgetAccount(username)
 }
 
-func (chatApp *ChatApplication) sendLoginData(username string, password string) {
-	// sends new login data to server
-	authData := models.AuthRequest{username, encrypt.GetPasswordHash(password)}
-	chatApp.Client.Emit("/login", encrypt.Encrypt(chatApp.CommonKey, authData))
+func (chatApp *ChatApplication) forgetAccount(username string) {
+	settings := utils.GetSettingsFromFile()
+	settings.RemoveAccount(username)
+	if err := utils.SaveSettings(settings); utils.IsError(err) {
+		logger.Error(err)
+	}
+	chatApp.showAccounts()
 }
 
-func (chatApp *ChatApplication) sendRegisterData(username string, password string) {
-	// sends new registration data to server
-	authData := models.AuthRequest{username, encrypt.GetPasswordHash(password)}
-	chatApp.Client.Emit("/register", encrypt.Encrypt(chatApp.CommonKey, authData))
+func (chatApp *ChatApplication) showAccounts() {
+	settings := utils.GetSettingsFromFile()
+	chatApp.Gui.SetAccounts(settings.GetAccounts(), chatApp.AccountName)
 }
 
-func (chatApp *ChatApplication) sendMessage(text string) {
-	// sends new message data to server
-	user := chatApp.CurrentUser
-	if chatApp.Connected && chatApp.LoggedIn {
-		msg := models.Message{user, chatApp.CurrentChatId, text}
-		chatApp.Client.Emit("/message", encrypt.Encrypt(chatApp.SecretKey, msg))
-	} else if !chatApp.LoggedIn {
-		chatApp.Gui.ShowError("You are not logged in.")
-	} else {
-		chatApp.Gui.ShowError("You are not connected to the server.")
+func (chatApp *ChatApplication) processLogout(logout models.Logout) {
+	// server closed session after password change or account deletion.
+	// Saved token and password are not valid anymore
+	logger.Info("LOGOUT")
+	chatApp.clearSession()
+	chatApp.Gui.ShowLoginDialog(logout.Reason)
+}
+
+func (chatApp *ChatApplication) logout() {
+	// closes session on server, socket stays connected for next login.
+	// Old servers keep session of socket, so client connects again
+	logger.Info("Logout")
+	isOnline := chatApp.Connected && chatApp.LoggedIn
+	canLogout := isOnline && chatApp.Client.HasFeature(models.FEATURE_LOGOUT)
+	if canLogout {
+		chatApp.Client.Logout(chatApp.loadSessionToken())
+	}
+	chatApp.clearSession() // before reconnection, so token isn't sent again
+	chatApp.forgetAccount(chatApp.AccountName)
+	chatApp.AccountName = ""
+	if isOnline && !canLogout {
+		chatApp.reconnect(utils.GetHostSettingsFromFile())
 	}
 }
 
-func (chatApp *ChatApplication) loadMessages(chatId int64) {
-	// sends getting messages request to server
-	if !chatApp.Connected || !chatApp.LoggedIn {
+func (chatApp *ChatApplication) clearSession() {
+	// forgets user, his channels and credentials
+	chatApp.removeSessionToken()
+	chatApp.resetSession()
+}
+
+func (chatApp *ChatApplication) resetSession() {
+	// forgets user and his channels. Saved token stays for next login
+	chatApp.LastAuthData = nil
+	chatApp.OAuthLoginPending = false
+	chatApp.SessionToken = ""
+	chatApp.RememberSession = true
+	chatApp.LoggedIn = false
+	chatApp.CurrentUser = models.User{}
+	chatApp.CurrentChatId = utils.GROUP_CHAT_ID
+	chatApp.Channels = nil
+	chatApp.OutgoingQueue = nil
+	chatApp.JumpMessageId = 0
+	chatApp.Drafts = make(map[int64]string)
+	chatApp.RestoreChatId = utils.GROUP_CHAT_ID // chat of another account or server
+	chatApp.cancelDownloads()
+	chatApp.Gui.ClearSession()
+	chatApp.Gui.DisableSend()
+}
+
+func (chatApp *ChatApplication) processNewMessage(msg models.SavedMessage) {
+	// adds new message to list after obtaing data from server
+	chatApp.Gui.SetLastSync(time.Now())
+	chatApp.MessagesCache.SaveMessage(chatApp.CurrentUser.Id,
+		chatApp.getMessageChannelId(msg.Message), msg)
+	// hidden messages are cached to keep history in sync with server
+	if chatApp.isBlocked(msg.User) || chatApp.isHiddenByFilter(msg) {
 		return
 	}
-	fmt.Printf("Load messages from chatId = %d\n", chatId)
-	chatApp.Client.Emit("/get-messages",
-		models.MessagesRequest{chatId, chatApp.CurrentUser})
+
+	if chatApp.canDisplayNewMessage(msg) {
+		chatApp.Gui.AddMessage(msg)
+		if msg.GetChatType() == "private" && msg.User.Id != chatApp.CurrentUser.Id {
+			chatApp.sendReadReceipt(msg.User.Id, msg.Id)
+		}
+		if chatApp.canNotifyInOpenChat(msg) {
+			chatApp.Gui.ShowNotification(msg.User.Username, msg.Text)
+			chatApp.Plugins.Notify(msg)
+		}
+	} else if msg.User.Id != chatApp.CurrentUser.Id {
+		channelId := chatApp.getMessageChannelId(msg.Message)
+		if msg.GetChatType() == "private" && !chatApp.isChannelInList(channelId) {
+			// first message of private chat, channels list is loaded before it
+			chatApp.Channels = append(chatApp.Channels, models.Channel{Id: channelId,
+				Title: msg.User.Username})
+			chatApp.Gui.AppendChannel(msg.User.Username)
+		}
+		if !chatApp.ChannelNotifications[channelId].HideUnread {
+			chatApp.Gui.IncrementUnread(chatApp.getChannelTitle(channelId))
+		}
+		if chatApp.canNotify(msg) {
+			chatApp.Gui.ShowNotification(msg.User.Username, msg.Text)
+			chatApp.Plugins.Notify(msg)
+		}
+	}
+	chatApp.playMessageSound(msg)
 }
 
-func (chatApp *ChatApplication) loadChannels() {
-	// sends gettings channels list request to server
-	log.Println("Load channels")
-	chatApp.Client.Emit("/get-channels", models.ChannelsRequest{chatApp.CurrentUser})
+func (chatApp *ChatApplication) loadSpellChecker(language string) {
+	// reads dictionary of language. Empty language disables spell checking
+	if language == "" {
+		chatApp.Gui.SetSpellChecker(nil)
+		return
+	}
+	checker, err := utils.LoadSpellChecker(language)
+	if utils.IsError(err) {
+		logger.Warning("Can't load dictionary: " + err.Error())
+		chatApp.Gui.SetSpellChecker(nil)
+		return
+	}
+	chatApp.Gui.SetSpellChecker(checker)
 }
 
-func (chatApp *ChatApplication) canDisplayNewMessage(msg models.SavedMessage) bool {
-	// returns true if obtained message suit for displayed channel
-	currentChatId := chatApp.CurrentChatId
-	currentUserId := chatApp.CurrentUser.Id
-	chatType := msg.GetChatType()
+func (chatApp *ChatApplication) playMessageSound(msg models.SavedMessage) {
+	// plays sound of incoming message if sounds aren't muted for chat
+	// and notification mode of chat allows it
+	channelId := chatApp.getMessageChannelId(msg.Message)
+	if chatApp.Sounds.SoundsMuted || msg.User.Id == chatApp.CurrentUser.Id ||
+		chatApp.UserFilters[msg.User.Id].Muted || chatApp.ChannelNotifications[channelId].NoSound ||
+		chatApp.Sounds.IsQuietTime(time.Now()) {
+		return
+	}
+	isMentioned := utils.IsMentioned(msg.Text, chatApp.CurrentUser.Username)
+	switch chatApp.getNotificationsMode(channelId) {
+	case utils.NOTIFICATIONS_OFF:
+		return
+	case utils.NOTIFICATIONS_MENTIONS:
+		if !isMentioned {
+			return
+		}
+	}
+	path := chatApp.Sounds.GetSound(isMentioned)
+	if path == "" {
+		return
+	}
+	go func() {
+		err := utils.PlaySound(path)
+		if utils.IsError(err) {
+			logger.Warning("Can't play sound: " + err.Error())
+		}
+	}()
+}
 
-	isMessageFromMe := msg.User.Id == chatApp.CurrentUser.Id
-	isMessageToMe := msg.ChatId == chatApp.CurrentUser.Id
-	// notes message: recipient and sender is same person
-	isNotesMessage := isMessageFromMe && isMessageToMe
+func (chatApp *ChatApplication) processMessageEditing(msg models.SavedMessage) {
+	// replaces edited, pinned or unpinned message or message with
+	// changed reactions in cache and message list
+	chatApp.MessagesCache.SaveMessage(chatApp.CurrentUser.Id,
+		chatApp.getMessageChannelId(msg.Message), msg)
 
-	if chatType == "private" {
-		return isNotesMessage && currentChatId == currentUserId ||
-			isMessageFromMe && currentChatId == msg.ChatId ||
-			isMessageToMe && currentChatId == msg.User.Id
-	} else { // message to group chat
-		return chatApp.CurrentChatId == utils.GROUP_CHAT_ID
+	if chatApp.canDisplayNewMessage(msg) {
+		chatApp.Gui.UpdateMessage(msg)
 	}
 }
 
-func (chatApp *ChatApplication) isChannelInList(channelId int64) bool {
-	// returns true if obtained channelId is in channels list
-	list := chatApp.Channels
-	for _, c := range list {
-		if c.Id == channelId {
-			return true
-		}
+func (chatApp *ChatApplication) processMessageDeletion(msg models.SavedMessage) {
+	// removes deleted message from cache and message list
+	chatApp.MessagesCache.DeleteMessage(chatApp.CurrentUser.Id, msg.Id)
+
+	if chatApp.canDisplayNewMessage(msg) {
+		chatApp.Gui.RemoveMessage(msg.Id)
 	}
-	return false
 }
 
-func (chatApp *ChatApplication) getChannelId(title string) int64 {
-	// returns channelId by title saved in channels list
-	for _, channel := range chatApp.Channels {
-		if channel.Title == title {
-			return channel.Id
+func (chatApp *ChatApplication) canNotify(msg models.SavedMessage) bool {
+	// returns true if notification settings allow to notify about
+	// message from not opened chat
+	if chatApp.UserFilters[msg.User.Id].Muted {
+		return false
+	}
+	isMentioned := utils.IsMentioned(msg.Text, chatApp.CurrentUser.Username)
+	switch chatApp.getNotificationsMode(chatApp.getMessageChannelId(msg.Message)) {
+	case utils.NOTIFICATIONS_OFF:
+		return false
+	case utils.NOTIFICATIONS_MENTIONS:
+		return isMentioned
+	}
+	return msg.GetChatType() == "private" || isMentioned
+}
+
+func (chatApp *ChatApplication) canNotifyInOpenChat(msg models.SavedMessage) bool {
+	// returns true if user asked to be notified about mentions in opened chat
+	mode := chatApp.getNotificationsMode(chatApp.getMessageChannelId(msg.Message))
+	return chatApp.Notifications.MentionsInOpenChat && mode != utils.NOTIFICATIONS_OFF &&
+		msg.User.Id != chatApp.CurrentUser.Id && !chatApp.UserFilters[msg.User.Id].Muted &&
+		utils.IsMentioned(msg.Text, chatApp.CurrentUser.Username)
+}
+
+func (chatApp *ChatApplication) getNotificationsMode(channelId int64) string {
+	// chat uses mode from settings if it has no own one.
+	// Do not disturb mode turns off notifications of all chats
+	if chatApp.Notifications.IsDoNotDisturb(time.Now()) {
+		return utils.NOTIFICATIONS_OFF
+	}
+	mode := chatApp.ChannelNotifications[channelId].Notifications
+	if mode == "" {
+		return chatApp.Notifications.Notifications
+	}
+	return mode
+}
+
+func (chatApp *ChatApplication) showChannelNotifications() {
+	// passes options of displayed channels to gui which knows channels by titles
+	options := make(map[string]utils.ChannelNotifications)
+	for channelId, channelOptions := range chatApp.ChannelNotifications {
+		if title := chatApp.getChannelTitle(channelId); title != "" {
+			options[title] = channelOptions
 		}
 	}
-	return -1
+	chatApp.Gui.SetChannelNotifications(options)
 }
 
-func (chatApp *ChatApplication) openChannel(chatId int64) {
-	chatApp.CurrentChatId = chatId
-	chatApp.loadMessages(chatId)
+func (chatApp *ChatApplication) setChannelNotifications(title string,
+	options utils.ChannelNotifications) {
+	// saves options of chat to settings of active profile
+	channelId, ok := chatApp.getListedChannelId(title)
+	if !ok {
+		return
+	}
+	settings := utils.GetSettingsFromFile()
+	settings.SetChannelNotifications(channelId, options)
+	err := utils.SaveSettings(settings)
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(i18n.Tf("Can't save settings: %s", err.Error()))
+		return
+	}
+	chatApp.ChannelNotifications = settings.GetChannelNotifications()
+	chatApp.showChannelNotifications()
 }
 
-func (chatApp *ChatApplication) openNotesChannel() {
-	chatApp.openChannel(chatApp.CurrentUser.Id)
+func (chatApp *ChatApplication) getListedChannelId(title string) (int64, bool) {
+	// returns chatId of channel shown in channels list, including main and notes
+	if title == gui.GROUP_CHANNEL_TITLE {
+		return utils.GROUP_CHAT_ID, true
+	} else if title == gui.NOTES_CHANNEL_TITLE {
+		return chatApp.CurrentUser.Id, true
+	}
+	channelId := chatApp.getChannelId(title)
+	return channelId, chatApp.isChannelInList(channelId)
 }
 
-func main() {
-	chatApp := ChatApplication{}
-	chatApp.init()
-	host, port := utils.GetHostDataFromSettingsFile()
-	go chatApp.connect(host, port, false)
+func (chatApp *ChatApplication) exportChat(title string, format string,
+	writer io.WriteCloser) {
+	// writes cached history of channel. Cache is synced with server
+	// when channel is opened, so older messages should be loaded before
+	defer writer.Close()
+	channelId, ok := chatApp.getListedChannelId(title)
+	if !ok {
+		chatApp.Gui.ShowError("Chat is not found.")
+		return
+	}
+	messages := chatApp.filterBlocked(
+		chatApp.MessagesCache.GetMessages(chatApp.CurrentUser.Id, channelId))
+	if len(messages) == 0 {
+		chatApp.Gui.ShowInfo("There are no saved messages in this chat. Open it to load history.")
+		return
+	}
+	err := utils.ExportChat(writer, format, title, channelId, messages)
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(i18n.Tf("Can't export chat: %s", err.Error()))
+		return
+	}
+	chatApp.Gui.ShowInfo(i18n.Tf("%d messages exported.", len(messages)))
+}
+
+func (chatApp *ChatApplication) importChat(title string, reader io.ReadCloser, toNotes bool) {
+	// adds messages of exported json file to cached history of channel.
+	// Messages which are already cached aren't changed
+	defer reader.Close()
+	channelId, ok := chatApp.getListedChannelId(title)
+	if !ok {
+		chatApp.Gui.ShowError("Chat is not found.")
+		return
+	}
+	chat, err := utils.ImportChat(reader)
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(i18n.Tf("Can't import chat: %s", err.Error()))
+		return
+	}
+	if chat.ChatId != channelId {
+		chatApp.Gui.ShowError(i18n.Tf("File contains history of another chat (%s).", chat.Title))
+		return
+	}
+	count, err := chatApp.MessagesCache.ImportMessages(chatApp.CurrentUser.Id, channelId,
+		chat.GetSavedMessages(channelId))
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(i18n.Tf("Can't import chat: %s", err.Error()))
+		return
+	}
+	if channelId == chatApp.CurrentChatId {
+		chatApp.showCachedMessages(channelId)
+	}
+	chatApp.Gui.ShowInfo(i18n.Tf("%d messages imported, %d were already saved.",
+		count, len(chat.Messages)-count))
+	if toNotes {
+		chatApp.copyToNotes(chat.Messages)
+	}
+}
+
+func (chatApp *ChatApplication) copyToNotes(messages []utils.ExportedMessage) {
+	// sends imported messages to notes with their authors and time
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Messages can be copied to notes only while connected.")
+		return
+	}
+	for _, msg := range messages {
+		err := chatApp.Client.SendMessage(chatApp.CurrentUser.Id, msg.GetNoteText())
+		if utils.IsError(err) {
+			chatApp.Gui.ShowError(i18n.Tf("Can't copy messages to notes: %s", err.Error()))
+			return
+		}
+	}
+}
+
+func (chatApp *ChatApplication) processPong(ping models.Ping) {
+	// latency is shown by answer to last ping. Older pongs are late
+	chatApp.LastPongTime = time.Now()
+	if ping.Id == chatApp.LastPingId {
+		sentOn := time.Unix(0, ping.SentOn*int64(time.Millisecond))
+		chatApp.Gui.SetLatency(chatApp.LastPongTime.Sub(sentOn))
+	}
+}
+
+func (chatApp *ChatApplication) processTyping(typing models.Typing) {
+	// shows that somebody is typing in displayed channel
+	if typing.User.Id == chatApp.CurrentUser.Id || chatApp.isBlocked(typing.User) {
+		return
+	}
+
+	isGroupOpened := chatApp.CurrentChatId == typing.ChatId
+	if typing.GetChatType() == "group" && isGroupOpened ||
+		typing.GetChatType() == "private" && typing.User.Id == chatApp.CurrentChatId {
+		chatApp.Gui.ShowTyping(typing.User.Username)
+	}
+}
+
+func (chatApp *ChatApplication) processPresence(presence models.Presence) {
+	chatApp.Gui.SetPresence(presence.User.Username, presence.State)
+}
+
+func (chatApp *ChatApplication) processMessageStatus(statusUpdate models.MessageStatusUpdate) {
+	// updates status of own messages in private chat
+	chatApp.MessagesCache.UpdateMessagesStatus(chatApp.CurrentUser.Id, statusUpdate.ChatId,
+		statusUpdate.LastMessageId, statusUpdate.Status)
+	if statusUpdate.ChatId == chatApp.CurrentChatId {
+		chatApp.Gui.UpdateMessagesStatus(statusUpdate.LastMessageId, statusUpdate.Status)
+	}
+}
+
+func (chatApp *ChatApplication) processMessagesReceiving(messagesPack models.SavedMessagesPack) {
+	messages := messagesPack.Messages
+	chatId := messagesPack.ChatId
+	logger.Debugf("Got Messages. count = %d", len(messages))
+	chatApp.Gui.SetLastSync(time.Now())
+	if messagesPack.AroundId != 0 {
+		chatApp.processMessagesAround(messagesPack)
+		return
+	}
+
+	// server has no messages before page if it isn't full
+	hasOlderMessages := len(messages) == MESSAGES_PAGE_SIZE
+	var fromId int64 = 0
+	if hasOlderMessages {
+		fromId = messages[0].Id
+	}
+	chatApp.MessagesCache.SyncMessages(chatApp.CurrentUser.Id, chatId,
+		fromId, messagesPack.BeforeId, messages)
+
+	if chatId != chatApp.CurrentChatId { // skip outdated response
+		return
+	}
+	if messagesPack.BeforeId == 0 {
+		chatApp.showCachedMessages(chatId)
+		chatApp.HasOlderMessages = chatApp.HasOlderMessages && hasOlderMessages
+		if chatId > 0 && len(messages) > 0 { // private chat was opened
+			chatApp.sendReadReceipt(chatId, messages[len(messages)-1].Id)
+		}
+	} else if messagesPack.BeforeId == chatApp.OldestMessageId {
+		chatApp.IsLoadingOlder = false
+		chatApp.HasOlderMessages = hasOlderMessages
+		if len(messages) > 0 {
+			chatApp.OldestMessageId = messages[0].Id
+			chatApp.Gui.PrependMessages(chatApp.filterHidden(messages))
+		}
+	}
+}
+
+func (chatApp *ChatApplication) processMessagesAround(messagesPack models.SavedMessagesPack) {
+	// shows history around found message and scrolls to it
+	messages := messagesPack.Messages
+	if len(messages) == 0 {
+		return
+	}
+	chatApp.MessagesCache.SyncMessages(chatApp.CurrentUser.Id, messagesPack.ChatId,
+		messages[0].Id, messages[len(messages)-1].Id+1, messages)
+
+	if messagesPack.ChatId != chatApp.CurrentChatId ||
+		messagesPack.AroundId != chatApp.JumpMessageId { // skip outdated response
+		return
+	}
+	chatApp.JumpMessageId = 0
+	chatApp.displayMessages(messages)
+	chatApp.Gui.ScrollToMessage(messagesPack.AroundId)
+}
+
+func (chatApp *ChatApplication) processMessagesSearch(result models.MessagesSearchResult) {
+	// adds messages found by server to locally found ones
+	messages := chatApp.MessagesCache.SearchMessages(chatApp.CurrentUser.Id,
+		result.Query, MAX_SEARCH_RESULTS)
+
+	foundIds := make(map[int64]bool)
+	for _, msg := range messages {
+		foundIds[msg.Id] = true
+	}
+	for _, msg := range result.Messages {
+		if !foundIds[msg.Id] {
+			messages = append(messages, msg)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].Id > messages[j].Id
+	})
+	chatApp.Gui.ShowSearchResults(result.Query, chatApp.filterBlocked(messages))
+}
+
+func (chatApp *ChatApplication) processFileDownload(chunk models.FileDownloadChunk) {
+	// writes received part of attachment to file chosen by user.
+	// Download is finished when all parts are written
+	download, ok := chatApp.Downloads[chunk.AttachmentId]
+	if !ok { // download was canceled
+		return
+	}
+	if chunk.Error != "" {
+		chatApp.finishDownload(chunk.AttachmentId, errors.New(chunk.Error))
+		return
+	}
+	isFinished, err := download.Chunks.Write(chunk.Offset, chunk.Data, chunk.IsLast)
+	if utils.IsError(err) {
+		chatApp.finishDownload(chunk.AttachmentId, err)
+		return
+	}
+	if isFinished {
+		chatApp.finishDownload(chunk.AttachmentId, nil)
+	}
+}
+
+func (chatApp *ChatApplication) finishDownload(attachmentId int64, err error) {
+	download := chatApp.Downloads[attachmentId]
+	delete(chatApp.Downloads, attachmentId)
+	download.OnFinish(err)
+}
+
+func (chatApp *ChatApplication) cancelDownloads() {
+	// stops downloads which can't be finished after disconnection
+	for attachmentId := range chatApp.Downloads {
+		chatApp.finishDownload(attachmentId, errors.New("Connection was lost"))
+	}
+}
+
+func (chatApp *ChatApplication) showCachedMessages(chatId int64) {
+	// shows saved history without waiting for server response
+	messages := chatApp.MessagesCache.GetMessages(chatApp.CurrentUser.Id, chatId)
+	logger.Debugf("Got cached messages. count = %d", len(messages))
+	chatApp.displayMessages(messages)
+}
+
+func (chatApp *ChatApplication) displayMessages(messages []models.SavedMessage) {
+	// replaces displayed messages. Queued messages are shown at the end
+	chatApp.Gui.SetMessages(chatApp.filterHidden(messages))
+	chatApp.IsLoadingOlder = false
+	chatApp.HasOlderMessages = len(messages) > 0
+	if len(messages) > 0 {
+		chatApp.OldestMessageId = messages[0].Id
+	}
+	for _, outgoing := range chatApp.OutgoingQueue {
+		if chatApp.canDisplayNewMessage(models.SavedMessage{Message: outgoing.Message}) {
+			chatApp.Gui.AddQueuedMessage(outgoing.QueuedMessage)
+		}
+	}
+}
+
+func (chatApp *ChatApplication) processChannelsReceiving(channelsPack models.ChannelsPack) {
+	channels := channelsPack.Channels
+	logger.Debugf("Got channels. count = %d", len(channels))
+	chatApp.Gui.SetLastSync(time.Now())
+	if chatApp.CurrentChatId > 0 && chatApp.CurrentChatId != chatApp.CurrentUser.Id &&
+		chatApp.isChannelInList(chatApp.CurrentChatId) {
+		// opened private channel without messages isn't saved on server
+		isSaved := false
+		for _, channel := range channels {
+			isSaved = isSaved || channel.Id == chatApp.CurrentChatId
+		}
+		if !isSaved {
+			channels = append(channels, models.Channel{Id: chatApp.CurrentChatId,
+				Title: chatApp.getChannelTitle(chatApp.CurrentChatId)})
+		}
+	}
+	chatApp.Channels = channels
+	chatApp.Gui.SetChannels(channels)
+	chatApp.showChannelNotifications()
+	if chatApp.RestoreChatId != utils.GROUP_CHAT_ID {
+		// chat of previous run is opened if it's still available
+		title := chatApp.getChannelTitle(chatApp.RestoreChatId)
+		chatApp.RestoreChatId = utils.GROUP_CHAT_ID
+		if title != "" && chatApp.CurrentChatId == utils.GROUP_CHAT_ID {
+			chatApp.Gui.SelectChannel(title)
+		}
+	}
+	chatApp.Gui.SetCanPin(chatApp.canPin(chatApp.CurrentChatId)) // owners of groups are known
+	for _, channel := range channels {
+		if channel.Id > 0 && channel.Id != chatApp.CurrentUser.Id { // private chat
+			chatApp.loadUserInfo(models.User{Id: channel.Id, Username: channel.Title})
+		}
+	}
+}
+
+func (chatApp *ChatApplication) processAvatar(avatar models.Avatar) {
+	// saves changed avatar to cache. Data is empty if cached avatar is actual
+	user := avatar.User
+	if avatar.Hash == "" { // user has no avatar
+		chatApp.AvatarHashes[user.Id] = ""
+		chatApp.Gui.SetAvatar(user.Username, "")
+		return
+	}
+	if len(avatar.Data) == 0 { // list of avatars could be cleared after logout
+		path := chatApp.ImagesCache.GetAvatarPath(user.Id, avatar.Hash)
+		if chatApp.ImagesCache.IsCached(path) {
+			chatApp.Gui.SetAvatar(user.Username, path)
+		}
+		return
+	}
+	path, err := chatApp.ImagesCache.SaveAvatar(avatar)
+	if utils.IsError(err) {
+		logger.Error(err)
+		return
+	}
+	chatApp.AvatarHashes[user.Id] = avatar.Hash
+	chatApp.Gui.SetAvatar(user.Username, path)
+}
+
+func (chatApp *ChatApplication) processAvatarUpdate(avatar models.Avatar) {
+	// downloads new avatar if user is displayed
+	hash, isRequested := chatApp.AvatarHashes[avatar.User.Id]
+	if isRequested && hash != avatar.Hash {
+		chatApp.Client.RequestAvatar(avatar.User.Id, hash)
+	}
+}
+
+func (chatApp *ChatApplication) processChannelCreated(channel models.Channel) {
+	// adds group channel to which user was invited
+	logger.Infof("Channel created: %s", channel.Title)
+	if !chatApp.isChannelInList(channel.Id) {
+		chatApp.Channels = append(chatApp.Channels, channel)
+		chatApp.Gui.AppendChannel(channel.Title)
+		chatApp.showChannelNotifications()
+	}
+}
+
+func (chatApp *ChatApplication) processChannelRenamed(channel models.Channel) {
+	for i, listedChannel := range chatApp.Channels {
+		if listedChannel.Id == channel.Id {
+			chatApp.Channels[i].Title = channel.Title
+			chatApp.Gui.RenameChannel(listedChannel.Title, channel.Title)
+		}
+	}
+	chatApp.showChannelNotifications()
+}
+
+func (chatApp *ChatApplication) processChannelRemoval(channel models.Channel) {
+	// user was removed from group by its moderator
+	logger.Infof("Removed from channel: %s", channel.Title)
+	var channels []models.Channel
+	for _, listedChannel := range chatApp.Channels {
+		if listedChannel.Id != channel.Id {
+			channels = append(channels, listedChannel)
+		}
+	}
+	if chatApp.CurrentChatId == channel.Id {
+		chatApp.Gui.SelectChannel(gui.GROUP_CHANNEL_TITLE)
+	}
+	chatApp.Channels = channels
+	chatApp.Gui.SetChannels(channels)
+	chatApp.Gui.ShowInfo(i18n.Tf("You were removed from %s.", channel.Title))
+}
+
+func (chatApp *ChatApplication) processChannelMembersReceiving(membersPack models.ChannelMembersPack) {
+	logger.Debugf("Got channel members. count = %d", len(membersPack.Members))
+	if membersPack.ChatId == chatApp.CurrentChatId { // skip outdated response
+		chatApp.Gui.SetChannelMembers(membersPack.Members)
+	}
+}
+
+func (chatApp *ChatApplication) sendLoginData(username string, password string, remember bool) {
+	// sends new login data to server
+	authData := chatclient.GetAuthRequest(username, password)
+	chatApp.LastAuthData = &authData
+	chatApp.RememberSession = remember
+	chatApp.Client.Login(authData)
+}
+
+func (chatApp *ChatApplication) loginByOAuth(provider models.OAuthProvider, remember bool) {
+	// user logs in at provider in browser, which passes code to local port
+	if !chatApp.Connected {
+		chatApp.Gui.ShowError("Login is possible only while connected.")
+		return
+	}
+	callback, err := network.StartOAuthCallback()
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(err.Error())
+		return
+	}
+	err = chatApp.Gui.OpenUrl(callback.GetAuthUrl(provider))
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(err.Error())
+		callback.Close()
+		return
+	}
+	if chatApp.OAuthCallback != nil {
+		chatApp.OAuthCallback.Close() // only last flow is finished
+	}
+	chatApp.OAuthCallback = callback
+	go func() {
+		code, err := callback.Wait(network.OAUTH_LOGIN_TIMEOUT)
+		chatApp.Loop.Post(func() {
+			if chatApp.OAuthCallback != callback {
+				return
+			}
+			chatApp.OAuthCallback = nil
+			if utils.IsError(err) {
+				chatApp.Gui.ShowLoginDialog(i18n.T(err.Error()))
+				return
+			}
+			chatApp.LastAuthData = nil // reconnection uses session token
+			chatApp.OAuthLoginPending = true
+			chatApp.RememberSession = remember
+			chatApp.Client.LoginByOAuth(models.OAuthLogin{Provider: provider.Name, Code: code,
+				CodeVerifier: callback.Verifier, RedirectUri: callback.RedirectUri})
+		})
+	}()
+}
+
+func (chatApp *ChatApplication) sendLoginCode(code string) {
+	// repeats login with one-time code. Code isn't saved for reconnection
+	if chatApp.LastAuthData == nil && chatApp.OAuthLoginPending {
+		// server keeps account of OAuth login until code is sent
+		chatApp.Client.LoginByOAuth(models.OAuthLogin{TotpCode: code})
+		return
+	}
+	if chatApp.LastAuthData == nil {
+		chatApp.Gui.ShowLoginDialog(i18n.T("Please, log in again."))
+		return
+	}
+	authData := *chatApp.LastAuthData
+	authData.Code = code
+	chatApp.Client.Login(authData)
+}
+
+func (chatApp *ChatApplication) sendSavedLoginData() {
+	// logs in by token of previous session or by credentials of last login
+	if chatApp.LoggedIn {
+		return
+	}
+	token := chatApp.loadSessionToken()
+	if token != "" {
+		chatApp.Client.LoginByToken(token)
+	} else if chatApp.LastAuthData != nil {
+		chatApp.Client.Login(*chatApp.LastAuthData)
+	}
+}
+
+func (chatApp *ChatApplication) sendRegisterData(username string, password string,
+	answer string) {
+	// sends new registration data to server with answer of its challenge
+	authData := chatclient.GetAuthRequest(username, password)
+	chatApp.LastAuthData = &authData
+	chatApp.RememberSession = true
+	challenge := chatApp.Challenge
+	chatApp.Challenge = models.RegistrationChallenge{} // each challenge is answered once
+	if challenge.Kind != models.CHALLENGE_POW {
+		authData.Answer = answer
+		chatApp.Client.Register(authData)
+		return
+	}
+	// proof of work takes a few seconds, so loop isn't blocked by it
+	client := chatApp.Client
+	go func() {
+		authData.Answer = encrypt.SolveProofOfWork(challenge.Nonce, challenge.Difficulty)
+		chatApp.Loop.Post(func() {
+			if client == chatApp.Client { // challenge belongs to connection
+				client.Register(authData)
+			}
+		})
+	}()
+}
+
+func (chatApp *ChatApplication) requestChallenge() {
+	// challenge is shown in registration form when it's received
+	chatApp.Challenge = models.RegistrationChallenge{}
+	if !chatApp.Connected {
+		chatApp.Gui.ShowError("Registration is possible only while connected.")
+		return
+	}
+	chatApp.Client.RequestRegistrationChallenge()
+}
+
+func (chatApp *ChatApplication) changePassword(oldPassword string, newPassword string) {
+	// client is logged out by server after successful change
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Password can be changed only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_ACCOUNT) {
+		chatApp.Gui.ShowError("Server doesn't support password change.")
+		return
+	}
+	chatApp.Client.ChangePassword(oldPassword, newPassword)
+}
+
+func (chatApp *ChatApplication) enableTwoFactor(code string) {
+	// empty code requests new secret, then code made from it confirms enabling
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Two-factor authentication can be changed only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_TWO_FACTOR) {
+		chatApp.Gui.ShowError("Server doesn't support two-factor authentication.")
+		return
+	}
+	err := chatApp.Client.EnableTwoFactor(code)
+	if utils.IsError(err) {
+		logger.Error(err)
+	}
+}
+
+func (chatApp *ChatApplication) disableTwoFactor(password string) {
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Two-factor authentication can be changed only while connected.")
+		return
+	}
+	err := chatApp.Client.DisableTwoFactor(password)
+	if utils.IsError(err) {
+		logger.Error(err)
+	}
+}
+
+func (chatApp *ChatApplication) deleteAccount(password string) {
+	// client is logged out by server after deletion
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Account can be deleted only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_ACCOUNT) {
+		chatApp.Gui.ShowError("Server doesn't support account deletion.")
+		return
+	}
+	chatApp.Client.DeleteAccount(password)
+}
+
+func (chatApp *ChatApplication) setAvatar(reader io.ReadCloser) {
+	// resizes chosen image and uploads it as avatar of current user
+	defer reader.Close()
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Avatar can be set only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_AVATARS) {
+		chatApp.Gui.ShowError("Server doesn't support avatars.")
+		return
+	}
+	data, err := network.EncodeAvatar(reader)
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(i18n.Tf("Can't set avatar: %s", err.Error()))
+		return
+	}
+	chatApp.Client.SetAvatar(data)
+}
+
+func (chatApp *ChatApplication) setProfile(displayName string, statusText string) {
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Profile can be changed only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_PROFILES) {
+		chatApp.Gui.ShowError("Server doesn't support profiles.")
+		return
+	}
+	chatApp.Client.SetProfile(displayName, statusText)
+}
+
+func (chatApp *ChatApplication) loadUserInfo(user models.User) {
+	// loads data of user who is shown in messages or channels
+	chatApp.loadAvatar(user)
+	chatApp.loadProfile(user)
+}
+
+func (chatApp *ChatApplication) loadProfile(user models.User) {
+	// profile is requested once, then it's updated by notifications
+	if chatApp.Profiles[user.Id] || !chatApp.Connected || !chatApp.LoggedIn ||
+		!chatApp.Client.HasFeature(models.FEATURE_PROFILES) {
+		return
+	}
+	chatApp.Profiles[user.Id] = true
+	chatApp.Client.RequestProfile(user.Id)
+}
+
+func (chatApp *ChatApplication) loadAvatar(user models.User) {
+	// shows cached avatar and asks server whether it was changed.
+	// Avatar of each user is requested once, then it's updated by notifications
+	if _, isRequested := chatApp.AvatarHashes[user.Id]; isRequested {
+		return
+	}
+	if !chatApp.Connected || !chatApp.LoggedIn ||
+		!chatApp.Client.HasFeature(models.FEATURE_AVATARS) {
+		return
+	}
+	path, hash := chatApp.ImagesCache.FindAvatar(user.Id)
+	chatApp.AvatarHashes[user.Id] = hash
+	if path != "" {
+		chatApp.Gui.SetAvatar(user.Username, path)
+	}
+	chatApp.Client.RequestAvatar(user.Id, hash)
+}
+
+func (chatApp *ChatApplication) reloadUserInfo() {
+	// avatars and profiles could be changed while client was offline
+	if chatApp.Client.HasFeature(models.FEATURE_AVATARS) {
+		for userId, hash := range chatApp.AvatarHashes {
+			chatApp.Client.RequestAvatar(userId, hash)
+		}
+	}
+	if chatApp.Client.HasFeature(models.FEATURE_PROFILES) {
+		for userId := range chatApp.Profiles {
+			chatApp.Client.RequestProfile(userId)
+		}
+	}
+}
+
+func (chatApp *ChatApplication) loadUserFilters() {
+	// filters are saved locally for each user of server
+	chatApp.UserFilters = make(map[int64]models.UserFilter)
+	for _, filter := range chatApp.MessagesCache.GetUserFilters(chatApp.CurrentUser.Id) {
+		chatApp.UserFilters[filter.User.Id] = filter
+	}
+	chatApp.Gui.SetUserFilters(chatApp.UserFilters)
+}
+
+func (chatApp *ChatApplication) isBlocked(user models.User) bool {
+	return chatApp.UserFilters[user.Id].Blocked
+}
+
+func (chatApp *ChatApplication) filterBlocked(messages []models.SavedMessage) []models.SavedMessage {
+	// returns messages without messages of blocked users
+	var result []models.SavedMessage
+	for _, msg := range messages {
+		if !chatApp.isBlocked(msg.User) {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+func (chatApp *ChatApplication) setMessageFilters(filters []utils.MessageFilter) {
+	// collapsed messages are marked by gui, hidden ones aren't passed to it
+	chatApp.MessageFilters = utils.NewMessageMatcher(filters)
+	chatApp.Gui.SetMessageFilters(chatApp.MessageFilters)
+}
+
+func (chatApp *ChatApplication) isHiddenByFilter(msg models.SavedMessage) bool {
+	// own messages aren't filtered
+	return msg.User.Id != chatApp.CurrentUser.Id &&
+		chatApp.MessageFilters.Match(msg.Text) == utils.FILTER_HIDE
+}
+
+func (chatApp *ChatApplication) filterHidden(messages []models.SavedMessage) []models.SavedMessage {
+	// returns displayed messages: without blocked users and hidden by filters
+	var result []models.SavedMessage
+	for _, msg := range chatApp.filterBlocked(messages) {
+		if !chatApp.isHiddenByFilter(msg) {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+func (chatApp *ChatApplication) saveUserFilter(filter models.UserFilter) {
+	chatApp.MessagesCache.SaveUserFilter(chatApp.CurrentUser.Id, filter)
+	if filter.Blocked || filter.Muted {
+		chatApp.UserFilters[filter.User.Id] = filter
+	} else {
+		delete(chatApp.UserFilters, filter.User.Id)
+	}
+}
+
+func (chatApp *ChatApplication) setUserFilter(filter models.UserFilter) {
+	// blocked users are also sent to server if it keeps them
+	if !chatApp.LoggedIn || filter.User.Id == chatApp.CurrentUser.Id {
+		return
+	}
+	isBlockChanged := chatApp.isBlocked(filter.User) != filter.Blocked
+	chatApp.saveUserFilter(filter)
+	if !isBlockChanged {
+		return
+	}
+	if chatApp.Connected && chatApp.Client.HasFeature(models.FEATURE_BLOCKING) {
+		chatApp.Client.BlockUser(filter.User, filter.Blocked)
+	}
+	chatApp.showCachedMessages(chatApp.CurrentChatId)
+}
+
+func (chatApp *ChatApplication) processBlockedUsers(blockedUsers models.BlockedUsersPack) {
+	// list of server replaces local one, so users blocked by other
+	// clients of same account are blocked here too
+	isBlockedByServer := make(map[int64]bool)
+	isChanged := false
+	for _, user := range blockedUsers.Users {
+		isBlockedByServer[user.Id] = true
+		if !chatApp.isBlocked(user) {
+			filter := chatApp.UserFilters[user.Id]
+			filter.User = user
+			filter.Blocked = true
+			chatApp.saveUserFilter(filter)
+			isChanged = true
+		}
+	}
+	for userId, filter := range chatApp.UserFilters {
+		if filter.Blocked && !isBlockedByServer[userId] {
+			filter.Blocked = false
+			chatApp.saveUserFilter(filter)
+			isChanged = true
+		}
+	}
+	if isChanged {
+		chatApp.showCachedMessages(chatApp.CurrentChatId)
+	}
+}
+
+func (chatApp *ChatApplication) loadContacts() {
+	// contacts are kept by server only
+	if chatApp.Client.HasFeature(models.FEATURE_CONTACTS) {
+		chatApp.Client.RequestContacts()
+	}
+}
+
+func (chatApp *ChatApplication) processContacts(contactsPack models.ContactsPack) {
+	chatApp.Gui.SetContacts(contactsPack.Contacts)
+}
+
+func (chatApp *ChatApplication) setContact(user models.User, isContact bool) {
+	// server sends changed contacts list to all clients of user
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Contacts can be changed only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_CONTACTS) {
+		chatApp.Gui.ShowError("Server doesn't support contacts.")
+		return
+	}
+	if isContact {
+		chatApp.Client.AddContact(user)
+	} else {
+		chatApp.Client.RemoveContact(user)
+	}
+}
+
+func (chatApp *ChatApplication) searchUsers(query string) {
+	// found users are shown by gui when server answers
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Users can be found only while connected.")
+		return
+	}
+	chatApp.Client.SearchUsers(query, MAX_SEARCH_RESULTS)
+}
+
+func (chatApp *ChatApplication) loadInvitations() {
+	if chatApp.Client.HasFeature(models.FEATURE_INVITATIONS) {
+		chatApp.Client.RequestInvitations()
+	}
+}
+
+func (chatApp *ChatApplication) processInvitations(invitationsPack models.InvitationsPack) {
+	chatApp.Invitations = invitationsPack.Invitations
+	chatApp.Gui.SetInvitations(invitationsPack.Invitations)
+}
+
+func (chatApp *ChatApplication) canSendInvitations() bool {
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Invitations can be sent only while connected.")
+		return false
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_INVITATIONS) {
+		chatApp.Gui.ShowError("Server doesn't support invitations.")
+		return false
+	}
+	return true
+}
+
+func (chatApp *ChatApplication) inviteToChannel(title string, user models.User) {
+	// only created groups have members which can be invited
+	if !chatApp.canSendInvitations() {
+		return
+	}
+	chatId, ok := chatApp.getListedChannelId(title)
+	if !ok || chatId >= 0 {
+		chatApp.Gui.ShowError("Users can be invited only to created groups.")
+		return
+	}
+	chatApp.Client.InviteToChannel(chatId, user)
+}
+
+func (chatApp *ChatApplication) requestToJoin(title string) {
+	if !chatApp.canSendInvitations() {
+		return
+	}
+	chatApp.Client.RequestToJoin(title)
+	chatApp.Gui.ShowInfo(i18n.Tf("Request to join %s is sent to its owner.", title))
+}
+
+func (chatApp *ChatApplication) answerInvitation(invitationId int64, isAccepted bool) {
+	// accepted group is added to channels list when server sends it
+	if !chatApp.canSendInvitations() {
+		return
+	}
+	chatApp.Client.AnswerInvitation(invitationId, isAccepted)
+	for _, invitation := range chatApp.Invitations {
+		isJoined := isAccepted && invitation.Kind == models.INVITATION_JOIN
+		if invitation.Id == invitationId && isJoined &&
+			invitation.Channel.Id == chatApp.CurrentChatId {
+			chatApp.loadChannelMembers(chatApp.CurrentChatId) // new member is shown
+		}
+	}
+}
+
+func (chatApp *ChatApplication) sendMessage(text string) {
+	chatApp.sendReply(text, 0)
+}
+
+func (chatApp *ChatApplication) sendReply(text string, replyToId int64) {
+	// sends new message data to server. replyToId is 0 for plain message.
+	// Slash command is run instead, it can send message itself
+	text, ok := chatApp.runCommand(text)
+	if ok {
+		chatApp.sendToChat(chatApp.CurrentChatId, text, replyToId)
+	}
+}
+
+func (chatApp *ChatApplication) sendToChat(chatId int64, text string, replyToId int64) {
+	// message is queued until server acknowledges it or connection is restored
+	chatApp.markActivity()
+	user := chatApp.CurrentUser
+	msg := models.Message{User: user, ChatId: chatId, Text: text, ReplyToId: replyToId}
+	if !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("You are not logged in.")
+	} else if chatApp.Connected && !chatApp.Client.HasFeature(models.FEATURE_MESSAGE_ACKS) {
+		chatApp.Client.SendReply(msg.ChatId, msg.Text, msg.ReplyToId)
+	} else {
+		outgoing := chatApp.queueMessage(msg)
+		if chatApp.Connected {
+			chatApp.trySendMessage(outgoing)
+		}
+	}
+}
+
+func (chatApp *ChatApplication) runCommand(text string) (string, bool) {
+	// returns text of message which is sent after command. Unknown command
+	// isn't sent as text, "//" at start is sent as one slash
+	name, args, isCommand := utils.ParseSlashCommand(text)
+	if !isCommand {
+		if strings.HasPrefix(text, "//") {
+			text = text[1:]
+		}
+		return text, true
+	}
+	command, ok := chatApp.Commands.Find(name)
+	if !ok {
+		chatApp.Gui.ShowError(i18n.Tf("Unknown command /%s. Type /help to see commands.", name))
+		return "", false
+	}
+	text, err := command.Run(args)
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(err.Error())
+		return "", false
+	}
+	return text, text != ""
+}
+
+func (chatApp *ChatApplication) registerCommands() {
+	// commands which are suggested in input box. Messages of commands are
+	// sent to opened chat. Built-in commands replace commands of plugins
+	chatApp.Commands = utils.NewCommandRegistry()
+	for _, command := range chatApp.Plugins.GetCommands() {
+		chatApp.Commands.Register(command)
+	}
+	chatApp.Commands.Register(utils.SlashCommand{Name: "me", Usage: "<action>",
+		Description: "Send action in third person",
+		Run: func(args string) (string, error) {
+			if args == "" {
+				return "", errors.New("Type action after /me.")
+			}
+			return "* " + chatApp.CurrentUser.Username + " " + args, nil
+		}})
+	chatApp.Commands.Register(utils.SlashCommand{Name: "shrug", Usage: "[text]",
+		Description: "Send text with " + SHRUG,
+		Run: func(args string) (string, error) {
+			return strings.TrimSpace(args + " " + SHRUG), nil
+		}})
+	chatApp.Commands.Register(utils.SlashCommand{Name: "nick", Usage: "[display name]",
+		Description: "Change display name, empty name shows username",
+		Run: func(args string) (string, error) {
+			profile := chatApp.Gui.GetProfile(chatApp.CurrentUser.Username)
+			if err := utils.ValidateProfile(args, profile.StatusText); utils.IsError(err) {
+				return "", err
+			}
+			chatApp.setProfile(args, profile.StatusText)
+			return "", nil
+		}})
+	chatApp.Commands.Register(utils.SlashCommand{Name: "msg", Usage: "<username> <text>",
+		Description: "Send private message and open its chat",
+		Run: func(args string) (string, error) {
+			fields := strings.SplitN(args, " ", 2)
+			if len(fields) < 2 || strings.TrimSpace(fields[1]) == "" {
+				return "", errors.New("Type username and text after /msg.")
+			}
+			user, ok := chatApp.Gui.FindKnownUser(strings.TrimPrefix(fields[0], "@"))
+			if !ok {
+				return "", errors.New(i18n.Tf("User %s is not found in chats.", fields[0]))
+			}
+			chatApp.openChannelByUser(user)
+			chatApp.sendToChat(user.Id, strings.TrimSpace(fields[1]), 0)
+			return "", nil
+		}})
+	chatApp.Commands.Register(utils.SlashCommand{Name: "clear",
+		Description: "Clear messages of opened chat until it's opened again",
+		Run: func(string) (string, error) {
+			chatApp.Gui.SetMessages(nil) // history stays in cache
+			chatApp.HasOlderMessages = false
+			return "", nil
+		}})
+	chatApp.Commands.Register(utils.SlashCommand{Name: "help", Description: "Show commands",
+		Run: func(string) (string, error) {
+			var lines []string
+			for _, command := range chatApp.Commands.Complete("") {
+				lines = append(lines, strings.TrimSpace("/"+command.Name+" "+command.Usage)+
+					" — "+i18n.T(command.Description))
+			}
+			chatApp.Gui.ShowInfo(strings.Join(lines, "\n"))
+			return "", nil
+		}})
+	chatApp.Gui.SetCommands(chatApp.Commands.Complete(""))
+}
+
+func (chatApp *ChatApplication) renderMessage(msg models.SavedMessage) (string, bool) {
+	// called by gui. Message which isn't rendered yet is shown again after plugin answers
+	return chatApp.Plugins.Render(msg.Text, func() {
+		chatApp.Loop.Post(func() { chatApp.Gui.RefreshMessage(msg.Id) })
+	})
+}
+
+func (chatApp *ChatApplication) scheduleMessage(text string, replyToId int64, sendOn time.Time) {
+	// saves message to local cache. It's sent by client at sendOn if it's online then,
+	// otherwise after next login
+	if !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("You are not logged in.")
+		return
+	}
+	msg := models.ScheduledMessage{
+		Message: models.Message{User: chatApp.CurrentUser, ChatId: chatApp.CurrentChatId,
+			Text: text, ReplyToId: replyToId},
+		ChatTitle: chatApp.getChannelTitle(chatApp.CurrentChatId),
+		SendOn:    sendOn.Unix()}
+	err := chatApp.MessagesCache.AddScheduledMessage(chatApp.CurrentUser.Id, msg)
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(i18n.Tf("Can't schedule message: %s", err.Error()))
+		return
+	}
+	chatApp.loadScheduledMessages()
+}
+
+func (chatApp *ChatApplication) cancelScheduled(id int64) {
+	chatApp.MessagesCache.DeleteScheduledMessage(chatApp.CurrentUser.Id, id)
+	chatApp.loadScheduledMessages()
+}
+
+func (chatApp *ChatApplication) loadScheduledMessages() {
+	chatApp.Gui.SetScheduledMessages(
+		chatApp.MessagesCache.GetScheduledMessages(chatApp.CurrentUser.Id))
+}
+
+func (chatApp *ChatApplication) trackScheduledMessages() {
+	for range time.Tick(SCHEDULE_CHECK_INTERVAL) {
+		chatApp.Loop.Post(chatApp.sendScheduledMessages)
+	}
+}
+
+func (chatApp *ChatApplication) sendScheduledMessages() {
+	// sends messages whose time has come. Messages stay saved while client is offline
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		return
+	}
+	now := utils.GetTimestampNow()
+	isSent := false
+	for _, msg := range chatApp.MessagesCache.GetScheduledMessages(chatApp.CurrentUser.Id) {
+		if msg.SendOn > now { // messages are ordered by time
+			break
+		}
+		logger.Infof("Send scheduled message %d to chat %d", msg.Id, msg.ChatId)
+		err := chatApp.Client.SendReply(msg.ChatId, msg.Text, msg.ReplyToId)
+		if utils.IsError(err) { // retried on next check
+			logger.Warning("Can't send scheduled message: " + err.Error())
+			return
+		}
+		chatApp.MessagesCache.DeleteScheduledMessage(chatApp.CurrentUser.Id, msg.Id)
+		isSent = true
+	}
+	if isSent {
+		chatApp.loadScheduledMessages()
+	}
+}
+
+func (chatApp *ChatApplication) sendFile(reader io.ReadCloser, fileName string) {
+	// uploads file to current chat in background
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		reader.Close()
+		chatApp.Gui.ShowError("Files can be sent only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_ATTACHMENTS) {
+		reader.Close()
+		chatApp.Gui.ShowError("Server doesn't support files.")
+		return
+	}
+	msg := models.Message{User: chatApp.CurrentUser, ChatId: chatApp.CurrentChatId,
+		Text: fileName}
+	go chatApp.uploadFile(chatApp.Client, reader, msg)
+}
+
+func (chatApp *ChatApplication) uploadFile(client *chatclient.Client, reader io.ReadCloser,
+	msg models.Message) {
+	// sends file by chunks in goroutine. Server creates message after last chunk
+	defer reader.Close()
+	uploadId := uuid.NewV4().String()
+	buffer := make([]byte, utils.FILE_CHUNK_SIZE)
+	var offset int64 = 0
+	for {
+		n, err := io.ReadFull(reader, buffer)
+		isLast := err == io.EOF || err == io.ErrUnexpectedEOF
+		if utils.IsError(err) && !isLast {
+			chatApp.Gui.ShowError(i18n.Tf("Can't read file: %s", err.Error()))
+			return
+		}
+		if offset+int64(n) > utils.MAX_ATTACHMENT_SIZE {
+			chatApp.Gui.ShowError(i18n.Tf("File is bigger than %d MB.",
+				utils.MAX_ATTACHMENT_SIZE/(1024*1024)))
+			return
+		}
+		chunk := models.FileUploadChunk{
+			UploadId: uploadId,
+			Message:  msg,
+			Offset:   offset,
+			Data:     buffer[:n],
+			IsLast:   isLast}
+		if err := client.UploadFileChunk(chunk); utils.IsError(err) {
+			chatApp.Gui.ShowError(i18n.Tf("Connection was lost. File %s is not sent.", msg.Text))
+			return
+		}
+		if isLast {
+			return
+		}
+		offset += int64(n)
+	}
+}
+
+func (chatApp *ChatApplication) downloadAttachment(attachment models.Attachment,
+	writer io.WriteCloser) {
+	// saves attached file to file chosen by user
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		writer.Close()
+		chatApp.Gui.ShowError("Files can be downloaded only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_ATTACHMENTS) {
+		writer.Close()
+		chatApp.Gui.ShowError("Server doesn't support files.")
+		return
+	}
+	if _, ok := chatApp.Downloads[attachment.Id]; ok {
+		writer.Close()
+		chatApp.Gui.ShowInfo("File " + attachment.FileName + " is already downloading.")
+		return
+	}
+	chatApp.requestAttachment(attachment.Id, &attachmentDownload{
+		Writer: writer,
+		OnFinish: func(err error) {
+			writer.Close()
+			if utils.IsError(err) {
+				chatApp.Gui.ShowError(i18n.Tf("Can't download file: %s", err.Error()))
+			} else {
+				chatApp.Gui.ShowInfo("File saved!")
+			}
+		}})
+}
+
+func (chatApp *ChatApplication) requestAttachment(attachmentId int64,
+	download *attachmentDownload) {
+	// requests attached file. Chunks are written to download writer
+	download.Chunks = utils.NewChunkWriter(download.Writer)
+	chatApp.Downloads[attachmentId] = download
+	logger.Debugf("Download attachment id = %d", attachmentId)
+	chatApp.Client.RequestAttachment(attachmentId)
+}
+
+func (chatApp *ChatApplication) loadImagePreview(ctx context.Context, msg models.SavedMessage,
+	onLoaded func(path string)) {
+	// loads image attachment or image by url from message text to cache.
+	// onLoaded is called with path of cached image. Message row is
+	// hidden already if ctx is canceled
+	if ctx.Err() != nil {
+		return
+	}
+	attachment := msg.Attachment
+	if msg.HasAttachment() && network.IsImageFileName(attachment.FileName) {
+		chatApp.loadAttachmentPreview(attachment, onLoaded)
+		return
+	}
+	url := network.FindImageUrl(msg.Text)
+	if msg.IsSticker() {
+		url = msg.Sticker
+	}
+	if url == "" {
+		return
+	}
+	go func() {
+		path, err := chatApp.ImagesCache.DownloadUrl(ctx, url)
+		if ctx.Err() != nil {
+			logger.Debugf("Loading of %s is canceled", url)
+			return
+		}
+		if utils.IsError(err) {
+			logger.Error(err)
+			return
+		}
+		onLoaded(path)
+	}()
+}
+
+func (chatApp *ChatApplication) loadLinkPreview(ctx context.Context, msg models.SavedMessage,
+	onLoaded func(models.LinkPreview)) {
+	// loads metadata of first link in message text if previews are enabled
+	if !chatApp.LinkPreviews || msg.HasAttachment() || ctx.Err() != nil {
+		return
+	}
+	url := network.FindLinkUrl(msg.Text)
+	if url == "" {
+		return
+	}
+	go func() {
+		preview, err := chatApp.ImagesCache.LoadLinkPreview(ctx, url)
+		if ctx.Err() != nil {
+			logger.Debugf("Loading of %s is canceled", url)
+			return
+		}
+		if utils.IsError(err) {
+			logger.Warning(err)
+			return
+		}
+		onLoaded(preview)
+	}()
+}
+
+func (chatApp *ChatApplication) loadAttachmentPreview(attachment models.Attachment,
+	onLoaded func(path string)) {
+	if attachment.Size > network.MAX_IMAGE_PREVIEW_SIZE {
+		return
+	}
+	chatApp.loadCachedAttachment(attachment, onLoaded, func(err error) {
+		logger.Error(err)
+	})
+}
+
+func (chatApp *ChatApplication) loadCachedAttachment(attachment models.Attachment,
+	onLoaded func(path string), onError func(err error)) {
+	// downloads attachment to cache if it isn't cached yet
+	path := chatApp.ImagesCache.GetAttachmentPath(attachment)
+	if chatApp.ImagesCache.IsCached(path) {
+		onLoaded(path)
+		return
+	}
+	if _, isDownloading := chatApp.Downloads[attachment.Id]; isDownloading {
+		onError(errors.New("file is already downloading"))
+		return
+	}
+	if !chatApp.Connected || !chatApp.LoggedIn ||
+		!chatApp.Client.HasFeature(models.FEATURE_ATTACHMENTS) {
+		onError(errors.New("files can be downloaded only while connected"))
+		return
+	}
+	file, err := chatApp.ImagesCache.CreateFile(path)
+	if utils.IsError(err) {
+		onError(err)
+		return
+	}
+	chatApp.requestAttachment(attachment.Id, &attachmentDownload{
+		Writer: file,
+		OnFinish: func(err error) {
+			err = file.Finish(err)
+			if utils.IsError(err) {
+				onError(err)
+				return
+			}
+			onLoaded(path)
+		}})
+}
+
+func (chatApp *ChatApplication) startRecording() bool {
+	// records voice message to temporary file until recording is stopped
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Files can be sent only while connected.")
+		return false
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("chat-voice-%d.wav", time.Now().UnixNano()))
+	recorder, err := utils.StartRecording(path)
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(i18n.Tf("Can't record voice message: %s", err.Error()))
+		return false
+	}
+	chatApp.VoiceRecorder = recorder
+	return true
+}
+
+func (chatApp *ChatApplication) stopRecording() {
+	// sends recorded voice message to current chat. Duration is kept in file name
+	recorder := chatApp.VoiceRecorder
+	if recorder == nil {
+		return
+	}
+	chatApp.VoiceRecorder = nil
+	duration, err := recorder.Stop()
+	defer os.Remove(recorder.Path)
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(i18n.Tf("Can't record voice message: %s", err.Error()))
+		return
+	}
+	if duration < MIN_VOICE_DURATION {
+		chatApp.Gui.ShowInfo("Voice message is too short.")
+		return
+	}
+	data, err := ioutil.ReadFile(recorder.Path)
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(i18n.Tf("Can't read file: %s", err.Error()))
+		return
+	}
+	chatApp.sendFile(ioutil.NopCloser(bytes.NewReader(data)),
+		utils.GetVoiceFileName(recorder.StartTime, duration))
+}
+
+func (chatApp *ChatApplication) playVoice(attachment models.Attachment, onFinished func()) {
+	// plays one voice message at a time. Playing message is stopped
+	chatApp.stopVoice()
+	chatApp.loadCachedAttachment(attachment, func(path string) {
+		cmd, err := utils.StartSound(path)
+		if utils.IsError(err) {
+			chatApp.Gui.ShowError(i18n.Tf("Can't play voice message: %s", err.Error()))
+			onFinished()
+			return
+		}
+		chatApp.VoicePlayer = cmd
+		go func() {
+			cmd.Wait()
+			chatApp.Loop.Post(func() {
+				if chatApp.VoicePlayer == cmd {
+					chatApp.VoicePlayer = nil
+				}
+				onFinished()
+			})
+		}()
+	}, func(err error) {
+		chatApp.Gui.ShowError(i18n.Tf("Can't play voice message: %s", err.Error()))
+		onFinished()
+	})
+}
+
+func (chatApp *ChatApplication) stopVoice() {
+	// finished callback is called after player is closed
+	if chatApp.VoicePlayer != nil {
+		chatApp.VoicePlayer.Process.Kill()
+	}
+}
+
+func (chatApp *ChatApplication) editMessage(messageId int64, text string) {
+	// sends new text of own message to server
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Messages can be edited only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_EDITS) {
+		chatApp.Gui.ShowError("Server doesn't support editing of messages.")
+		return
+	}
+	chatApp.Client.EditMessage(messageId, text)
+}
+
+func (chatApp *ChatApplication) deleteMessage(messageId int64) {
+	// asks server to delete own message
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Messages can be deleted only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_EDITS) {
+		chatApp.Gui.ShowError("Server doesn't support deletion of messages.")
+		return
+	}
+	chatApp.Client.DeleteMessage(messageId)
+}
+
+func (chatApp *ChatApplication) react(messageId int64, emoji string) {
+	// adds reaction to message or removes own one
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Reactions can be added only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_REACTIONS) {
+		chatApp.Gui.ShowError("Server doesn't support reactions.")
+		return
+	}
+	chatApp.Client.React(messageId, emoji)
+}
+
+func (chatApp *ChatApplication) pinMessage(messageId int64, isPinned bool) {
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Messages can be pinned only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_PINS) {
+		chatApp.Gui.ShowError("Server doesn't support pinned messages.")
+		return
+	}
+	chatApp.Client.PinMessage(messageId, isPinned)
+}
+
+func (chatApp *ChatApplication) forwardMessage(messageId int64, title string) {
+	// sends copy of message to chat of channels list
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Messages can be forwarded only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_FORWARDING) {
+		chatApp.Gui.ShowError("Server doesn't support forwarding of messages.")
+		return
+	}
+	chatId := chatApp.getChannelId(title)
+	if title == gui.GROUP_CHANNEL_TITLE {
+		chatId = utils.GROUP_CHAT_ID
+	} else if title == gui.NOTES_CHANNEL_TITLE {
+		chatId = chatApp.CurrentUser.Id
+	}
+	chatApp.markActivity()
+	chatApp.Client.ForwardMessage(chatId, messageId)
+}
+
+func (chatApp *ChatApplication) loadStickers(query string,
+	onLoaded func([]models.Sticker)) {
+	// stickers of local pack go before stickers of provider
+	stickersDir, stickersUrl := chatApp.StickersDir, chatApp.StickersUrl
+	go func() {
+		var stickers []models.Sticker
+		if stickersDir != "" {
+			packStickers, err := network.ListStickerPack(stickersDir, query)
+			if utils.IsError(err) {
+				logger.Warning("Can't read sticker pack: " + err.Error())
+			}
+			stickers = append(stickers, packStickers...)
+		}
+		if stickersUrl != "" {
+			providerStickers, err := chatApp.ImagesCache.SearchStickers(stickersUrl, query)
+			if utils.IsError(err) {
+				logger.Warning(err)
+			}
+			stickers = append(stickers, providerStickers...)
+		}
+		onLoaded(stickers)
+	}()
+}
+
+func (chatApp *ChatApplication) sendSticker(sticker models.Sticker) {
+	// sticker of provider is sent by url, sticker of local pack is uploaded
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Stickers can be sent only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_STICKERS) {
+		chatApp.Gui.ShowError("Server doesn't support stickers.")
+		return
+	}
+	chatApp.markActivity()
+	if !sticker.IsLocal() {
+		chatApp.Client.SendSticker(chatApp.CurrentChatId, sticker.Url)
+		return
+	}
+	file, err := os.Open(sticker.Path)
+	if utils.IsError(err) {
+		chatApp.Gui.ShowError(i18n.Tf("Can't read file: %s", err.Error()))
+		return
+	}
+	fileName := filepath.Base(sticker.Path)
+	msg := models.Message{User: chatApp.CurrentUser, ChatId: chatApp.CurrentChatId,
+		Text: fileName, Sticker: fileName}
+	go chatApp.uploadFile(chatApp.Client, file, msg)
+}
+
+func (chatApp *ChatApplication) report(report models.Report) {
+	// sends complaint about message or user to moderators of server
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Reports can be sent only while connected.")
+		return
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_USER_REPORTS) {
+		chatApp.Gui.ShowError("Server doesn't support reports.")
+		return
+	}
+	chatApp.Client.Report(report)
+	if report.MessageId != 0 {
+		chatApp.Gui.ShowInfo("Message was reported.")
+	} else {
+		chatApp.Gui.ShowInfo("User was reported.")
+	}
+}
+
+func (chatApp *ChatApplication) canPin(chatId int64) bool {
+	// messages are pinned by partners of private chats and owners of groups.
+	// Main channel has no pinned messages
+	if chatId > 0 {
+		return true
+	}
+	for _, channel := range chatApp.Channels {
+		if channel.Id == chatId && chatId != utils.GROUP_CHAT_ID {
+			return channel.OwnerId == chatApp.CurrentUser.Id
+		}
+	}
+	return false
+}
+
+func (chatApp *ChatApplication) processPinnedMessages(pinnedPack models.PinnedMessagesPack) {
+	if pinnedPack.ChatId != chatApp.CurrentChatId { // skip outdated response
+		return
+	}
+	chatApp.Gui.SetPinnedMessages(chatApp.filterBlocked(pinnedPack.Messages))
+}
+
+func (chatApp *ChatApplication) createChannel(title string, members []models.User) {
+	// sends new group channel data to server
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Channels can be created only while connected.")
+		return
+	}
+	var memberIds []int64
+	for _, member := range members {
+		memberIds = append(memberIds, member.Id)
+	}
+	chatApp.Client.CreateChannel(title, memberIds)
+}
+
+func (chatApp *ChatApplication) canModerate() bool {
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.Gui.ShowError("Groups can be moderated only while connected.")
+		return false
+	}
+	if !chatApp.Client.HasFeature(models.FEATURE_ROLES) {
+		chatApp.Gui.ShowError("Server doesn't support roles.")
+		return false
+	}
+	return true
+}
+
+func (chatApp *ChatApplication) kickMember(user models.User) {
+	// members panel shows members of opened group
+	if chatApp.canModerate() {
+		chatApp.Client.KickMember(chatApp.CurrentChatId, user)
+	}
+}
+
+func (chatApp *ChatApplication) setMemberRole(user models.User, role string) {
+	if chatApp.canModerate() {
+		chatApp.Client.SetMemberRole(chatApp.CurrentChatId, user, role)
+	}
+}
+
+func (chatApp *ChatApplication) renameChannel(title string, newTitle string) {
+	// new title is sent to members when server saves it
+	if !chatApp.canModerate() {
+		return
+	}
+	chatId, ok := chatApp.getListedChannelId(title)
+	if !ok || chatId >= 0 {
+		chatApp.Gui.ShowError("Only created groups can be renamed.")
+		return
+	}
+	chatApp.Client.RenameChannel(chatId, newTitle)
+}
+
+func (chatApp *ChatApplication) processRateLimit(limited models.RateLimited) {
+	// rejected text is returned to empty input of same channel
+	logger.Infof("Message is rate limited for %d seconds", limited.RetryAfter)
+	chatApp.Gui.ShowRateLimit(time.Duration(limited.RetryAfter) * time.Second)
+	msg := limited.Message
+	if msg.LocalId > 0 { // queued message isn't retried, its text is returned to input
+		chatApp.removeOutgoingMessage(msg.LocalId)
+	}
+	if msg.ChatId == chatApp.CurrentChatId && chatApp.Gui.GetInputText() == "" {
+		chatApp.Gui.SetInputText(msg.Text)
+	}
+}
+
+func (chatApp *ChatApplication) queueMessage(msg models.Message) *outgoingMessage {
+	// saves message locally until server acknowledges it
+	chatApp.LastLocalId++
+	msg.LocalId = chatApp.LastLocalId
+	outgoing := &outgoingMessage{
+		QueuedMessage: models.QueuedMessage{Message: msg, State: models.MESSAGE_STATE_PENDING},
+		Backoff:       network.NewBackoff(ACK_MIN_TIMEOUT, ACK_MAX_TIMEOUT)}
+	chatApp.OutgoingQueue = append(chatApp.OutgoingQueue, outgoing)
+	logger.Debugf("Message queued. count = %d", len(chatApp.OutgoingQueue))
+	chatApp.Gui.AddQueuedMessage(outgoing.QueuedMessage)
+	return outgoing
+}
+
+func (chatApp *ChatApplication) trySendMessage(outgoing *outgoingMessage) {
+	// sends queued message. It's sent again if ack doesn't come before
+	// timeout, which grows with every attempt
+	outgoing.RetryOn = time.Now().Add(outgoing.Backoff.Next())
+	err := chatApp.Client.SendQueuedMessage(outgoing.QueuedMessage)
+	if utils.IsError(err) {
+		logger.Warning("Can't send message: " + err.Error())
+	}
+}
+
+func (chatApp *ChatApplication) trackOutgoingMessages() {
+	for range time.Tick(OUTGOING_CHECK_INTERVAL) {
+		chatApp.Loop.Post(chatApp.resendOutgoingMessages)
+	}
+}
+
+func (chatApp *ChatApplication) resendOutgoingMessages() {
+	// resends messages without ack. Message fails after last attempt
+	if !chatApp.Connected || !chatApp.LoggedIn ||
+		!chatApp.Client.HasFeature(models.FEATURE_MESSAGE_ACKS) {
+		return // queue is flushed after next login
+	}
+	now := time.Now()
+	for _, outgoing := range chatApp.OutgoingQueue {
+		if outgoing.State != models.MESSAGE_STATE_PENDING || outgoing.RetryOn.IsZero() ||
+			now.Before(outgoing.RetryOn) {
+			continue
+		}
+		if outgoing.Backoff.Attempt() >= MAX_SEND_ATTEMPTS {
+			logger.Warningf("Message isn't acknowledged after %d attempts",
+				outgoing.Backoff.Attempt())
+			outgoing.State = models.MESSAGE_STATE_FAILED
+			chatApp.Gui.UpdateQueuedMessage(outgoing.QueuedMessage)
+			continue
+		}
+		chatApp.trySendMessage(outgoing)
+	}
+}
+
+func (chatApp *ChatApplication) processMessageAck(ack models.MessageAck) {
+	// saved message is shown at once. Broadcast of it updates displayed one
+	logger.Debugf("Message %d is acknowledged, id = %d", ack.LocalId, ack.Id)
+	for _, outgoing := range chatApp.OutgoingQueue {
+		if outgoing.LocalId == ack.LocalId && ack.Id != 0 {
+			msg := models.SavedMessage{Message: outgoing.Message, Id: ack.Id,
+				CreatedOn: ack.CreatedOn, Status: models.MESSAGE_STATE_SENT}
+			msg.LocalId = 0
+			chatApp.Gui.ConfirmQueuedMessage(ack.LocalId, msg)
+		}
+	}
+	chatApp.removeOutgoingMessage(ack.LocalId)
+}
+
+func (chatApp *ChatApplication) removeOutgoingMessage(localId int64) {
+	// removes queued message and its entry in displayed messages
+	for i, outgoing := range chatApp.OutgoingQueue {
+		if outgoing.LocalId == localId {
+			chatApp.OutgoingQueue = append(chatApp.OutgoingQueue[:i], chatApp.OutgoingQueue[i+1:]...)
+			outgoing.State = models.MESSAGE_STATE_SENT
+			chatApp.Gui.UpdateQueuedMessage(outgoing.QueuedMessage)
+			return
+		}
+	}
+}
+
+func (chatApp *ChatApplication) retryMessage(localId int64) {
+	// failed message gets new attempts
+	for _, outgoing := range chatApp.OutgoingQueue {
+		if outgoing.LocalId != localId {
+			continue
+		}
+		outgoing.State = models.MESSAGE_STATE_PENDING
+		outgoing.Backoff.Reset()
+		outgoing.RetryOn = time.Time{}
+		chatApp.Gui.UpdateQueuedMessage(outgoing.QueuedMessage)
+		if chatApp.Connected && chatApp.LoggedIn {
+			chatApp.trySendMessage(outgoing)
+		}
+		return
+	}
+}
+
+func (chatApp *ChatApplication) flushMessageQueue() {
+	// sends queued messages in order after successful login.
+	// Messages of another account are dropped. Without acks of server
+	// messages are considered sent, failed ones wait for retry
+	hasAcks := chatApp.Client.HasFeature(models.FEATURE_MESSAGE_ACKS)
+	queue := chatApp.OutgoingQueue
+	chatApp.OutgoingQueue = nil
+	for _, outgoing := range queue {
+		if outgoing.User.Id != chatApp.CurrentUser.Id {
+			outgoing.State = models.MESSAGE_STATE_SENT
+			chatApp.Gui.UpdateQueuedMessage(outgoing.QueuedMessage)
+			continue
+		}
+		if !hasAcks {
+			chatApp.Client.SendReply(outgoing.ChatId, outgoing.Text, outgoing.ReplyToId)
+			outgoing.State = models.MESSAGE_STATE_SENT
+			chatApp.Gui.UpdateQueuedMessage(outgoing.QueuedMessage)
+			continue
+		}
+		chatApp.OutgoingQueue = append(chatApp.OutgoingQueue, outgoing)
+		if outgoing.State == models.MESSAGE_STATE_PENDING {
+			chatApp.trySendMessage(outgoing)
+		}
+	}
+}
+
+func (chatApp *ChatApplication) markActivity() {
+	// remembers user input. Idle user becomes active
+	chatApp.LastActivityTime = time.Now()
+	if chatApp.Presence == models.PRESENCE_IDLE {
+		chatApp.updatePresence()
+	}
+}
+
+func (chatApp *ChatApplication) trackPresence() {
+	// schedule of do not disturb mode is checked with presence
+	for range time.Tick(PRESENCE_CHECK_INTERVAL) {
+		chatApp.Loop.Post(func() {
+			chatApp.showDoNotDisturb()
+			chatApp.updatePresence()
+		})
+	}
+}
+
+func (chatApp *ChatApplication) updatePresence() {
+	// reports busy state in do not disturb mode and idle state
+	// if there was no input during IDLE_TIMEOUT
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		return
+	}
+	state := models.PRESENCE_ACTIVE
+	if time.Since(chatApp.LastActivityTime) > IDLE_TIMEOUT {
+		state = models.PRESENCE_IDLE
+	}
+	if chatApp.Notifications.IsDoNotDisturb(time.Now()) &&
+		chatApp.Client.HasFeature(models.FEATURE_BUSY_PRESENCE) {
+		state = models.PRESENCE_BUSY
+	}
+	if state != chatApp.Presence {
+		chatApp.sendPresence(state)
+	}
+}
+
+func (chatApp *ChatApplication) setDoNotDisturb(enabled bool) {
+	// mode toggled in profile area is kept after restart
+	settings := utils.GetSettingsFromFile()
+	settings.DoNotDisturb = enabled
+	if err := utils.SaveSettings(settings); utils.IsError(err) {
+		chatApp.Gui.ShowError(i18n.Tf("Can't save settings: %s", err.Error()))
+	}
+	chatApp.Notifications.DoNotDisturb = enabled
+	chatApp.showDoNotDisturb()
+	chatApp.updatePresence()
+}
+
+func (chatApp *ChatApplication) showDoNotDisturb() {
+	chatApp.Gui.SetDoNotDisturb(chatApp.Notifications.DoNotDisturb,
+		chatApp.Notifications.IsDoNotDisturb(time.Now()))
+}
+
+func (chatApp *ChatApplication) trackConnection() {
+	for range time.Tick(PING_INTERVAL) {
+		chatApp.Loop.Post(chatApp.checkConnection)
+	}
+}
+
+func (chatApp *ChatApplication) checkConnection() {
+	// sends ping and closes connection if server stopped answering.
+	// Websocket can stay open when network is lost without notice
+	client := chatApp.Client
+	if !chatApp.Connected || client == nil {
+		return
+	}
+	err := errors.New("server doesn't respond")
+	if time.Since(chatApp.LastPongTime) < PONG_TIMEOUT {
+		chatApp.LastPingId++
+		err = client.Ping(chatApp.LastPingId)
+	}
+	if utils.IsError(err) {
+		logger.Warning("Connection is dead: " + err.Error())
+		chatApp.LastConnectionError = err.Error()
+		client.Close() // reconnection is started by disconnection callback
+	}
+}
+
+func (chatApp *ChatApplication) sendPresence(state string) {
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		return
+	}
+	chatApp.Presence = state
+	chatApp.Client.SendPresence(state)
+}
+
+func (chatApp *ChatApplication) searchMessages(query string) {
+	// shows cached messages which contain query and asks server
+	// to search in whole history
+	chatApp.markActivity()
+	messages := chatApp.MessagesCache.SearchMessages(chatApp.CurrentUser.Id,
+		query, MAX_SEARCH_RESULTS)
+	chatApp.Gui.ShowSearchResults(query, messages)
+	if !chatApp.Connected || !chatApp.LoggedIn ||
+		!chatApp.Client.HasFeature(models.FEATURE_SEARCH) {
+		return
+	}
+	chatApp.Client.SearchMessages(query, MAX_SEARCH_RESULTS)
+}
+
+func (chatApp *ChatApplication) jumpToMessage(msg models.SavedMessage) {
+	// opens channel of message and loads history around it
+	channelId := chatApp.getMessageChannelId(msg.Message)
+	title := chatApp.getChannelTitle(channelId)
+	if title == "" {
+		chatApp.Gui.ShowError("Channel of this message is not available.")
+		return
+	}
+	chatApp.JumpMessageId = msg.Id
+	if channelId == chatApp.CurrentChatId {
+		chatApp.loadMessagesAround(channelId, msg.Id)
+	} else {
+		chatApp.Gui.SelectChannel(title) // history is loaded by openChannel
+	}
+}
+
+func (chatApp *ChatApplication) sendReadReceipt(partnerId int64, lastMessageId int64) {
+	// notifies partner that his messages up to lastMessageId were read
+	if !chatApp.Connected || !chatApp.LoggedIn || partnerId == chatApp.CurrentUser.Id ||
+		!chatApp.Client.HasFeature(models.FEATURE_READ_RECEIPTS) {
+		return
+	}
+	chatApp.Client.SendReadReceipt(partnerId, lastMessageId)
+}
+
+func (chatApp *ChatApplication) sendTyping() {
+	// notifies recipients that user is editing message.
+	// Sends not more than once per TYPING_SEND_INTERVAL for one chat
+	chatApp.markActivity()
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		return
+	}
+	isSameChat := chatApp.LastTypingChatId == chatApp.CurrentChatId
+	if isSameChat && time.Since(chatApp.LastTypingTime) < TYPING_SEND_INTERVAL {
+		return
+	}
+	chatApp.LastTypingTime = time.Now()
+	chatApp.LastTypingChatId = chatApp.CurrentChatId
+
+	chatApp.Client.SendTyping(chatApp.CurrentChatId)
+}
+
+func (chatApp *ChatApplication) loadMessages(chatId int64) {
+	// sends getting messages request to server
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		return
+	}
+	logger.Debugf("Load messages from chatId = %d", chatId)
+	chatApp.Client.RequestMessages(models.MessagesRequest{
+		ChatId: chatId,
+		Limit:  MESSAGES_PAGE_SIZE})
+}
+
+func (chatApp *ChatApplication) loadMessagesAround(chatId int64, messageId int64) {
+	// requests page of messages with found message in the middle.
+	// Offline only cached messages are shown
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		chatApp.JumpMessageId = 0
+		chatApp.showCachedMessages(chatId)
+		chatApp.Gui.ScrollToMessage(messageId)
+		return
+	}
+	logger.Debugf("Load messages around id = %d", messageId)
+	chatApp.Client.RequestMessages(models.MessagesRequest{
+		ChatId:   chatId,
+		AroundId: messageId,
+		Limit:    MESSAGES_PAGE_SIZE})
+}
+
+func (chatApp *ChatApplication) loadOlderMessages() {
+	// requests page of messages before first displayed message
+	if !chatApp.Connected || !chatApp.LoggedIn ||
+		!chatApp.HasOlderMessages || chatApp.IsLoadingOlder {
+		return
+	}
+	chatApp.IsLoadingOlder = true
+	logger.Debugf("Load messages before id = %d", chatApp.OldestMessageId)
+	chatApp.Client.RequestMessages(models.MessagesRequest{
+		ChatId:   chatApp.CurrentChatId,
+		BeforeId: chatApp.OldestMessageId,
+		Limit:    MESSAGES_PAGE_SIZE})
+}
+
+func (chatApp *ChatApplication) loadChannelMembers(chatId int64) {
+	// requests members of group channel. Members panel is hidden for private chats
+	if chatId > 0 {
+		chatApp.Gui.HideChannelMembers()
+		return
+	}
+	if !chatApp.Connected || !chatApp.LoggedIn {
+		return
+	}
+	chatApp.Client.RequestChannelMembers(chatId)
+}
+
+func (chatApp *ChatApplication) loadPinnedMessages(chatId int64) {
+	// cached pinned messages are replaced by list of server
+	if !chatApp.Connected || !chatApp.LoggedIn ||
+		!chatApp.Client.HasFeature(models.FEATURE_PINS) {
+		return
+	}
+	chatApp.Client.RequestPinnedMessages(chatId)
+}
+
+func (chatApp *ChatApplication) loadChannels() {
+	// sends gettings channels list request to server
+	logger.Debug("Load channels")
+	chatApp.Client.RequestChannels()
+}
+
+func (chatApp *ChatApplication) canDisplayNewMessage(msg models.SavedMessage) bool {
+	// returns true if obtained message suit for displayed channel
+	currentChatId := chatApp.CurrentChatId
+	currentUserId := chatApp.CurrentUser.Id
+	chatType := msg.GetChatType()
+
+	isMessageFromMe := msg.User.Id == chatApp.CurrentUser.Id
+	isMessageToMe := msg.ChatId == chatApp.CurrentUser.Id
+	// notes message: recipient and sender is same person
+	isNotesMessage := isMessageFromMe && isMessageToMe
+
+	if chatType == "private" {
+		return isNotesMessage && currentChatId == currentUserId ||
+			isMessageFromMe && currentChatId == msg.ChatId ||
+			isMessageToMe && currentChatId == msg.User.Id
+	} else { // message to main or created group chat
+		return chatApp.CurrentChatId == msg.ChatId
+	}
+}
+
+func (chatApp *ChatApplication) getMessageChannelId(msg models.Message) int64 {
+	// returns id of channel in which message is displayed for current user
+	return chatclient.GetMessageChannelId(msg, chatApp.CurrentUser.Id)
+}
+
+func (chatApp *ChatApplication) isChannelInList(channelId int64) bool {
+	// returns true if obtained channelId is in channels list
+	list := chatApp.Channels
+	for _, c := range list {
+		if c.Id == channelId {
+			return true
+		}
+	}
+	return false
+}
+
+func (chatApp *ChatApplication) getChannelId(title string) int64 {
+	// returns channelId by title saved in channels list
+	for _, channel := range chatApp.Channels {
+		if channel.Title == title {
+			return channel.Id
+		}
+	}
+	return -1
+}
+
+func (chatApp *ChatApplication) getChannelTitle(channelId int64) string {
+	// returns title displayed in channels list
+	if channelId == utils.GROUP_CHAT_ID {
+		return gui.GROUP_CHANNEL_TITLE
+	}
+	if channelId == chatApp.CurrentUser.Id {
+		return gui.NOTES_CHANNEL_TITLE
+	}
+	for _, channel := range chatApp.Channels {
+		if channel.Id == channelId {
+			return channel.Title
+		}
+	}
+	return ""
+}
+
+func (chatApp *ChatApplication) openChannel(chatId int64) {
+	chatApp.markActivity()
+	chatApp.saveDraft()
+	chatApp.CurrentChatId = chatId
+	chatApp.Gui.SetInputText(chatApp.Drafts[chatId])
+	chatApp.Gui.ClearTyping()
+	chatApp.showCachedMessages(chatId)
+	if chatApp.JumpMessageId != 0 {
+		chatApp.loadMessagesAround(chatId, chatApp.JumpMessageId)
+	} else {
+		chatApp.loadMessages(chatId)
+	}
+	chatApp.loadChannelMembers(chatId)
+	chatApp.Gui.SetCanPin(chatApp.canPin(chatId))
+	chatApp.Gui.SetPinnedMessages(chatApp.filterBlocked(
+		chatApp.MessagesCache.GetPinnedMessages(chatApp.CurrentUser.Id, chatId)))
+	chatApp.loadPinnedMessages(chatId)
+}
+
+func (chatApp *ChatApplication) showChat(chatId int64) {
+	// opens chat asked by notifier. Chat is selected after loading
+	// of channels if they aren't loaded yet
+	title := chatApp.getChannelTitle(chatId)
+	if chatApp.LoggedIn && title != "" {
+		chatApp.Gui.SelectChannel(title)
+	} else {
+		chatApp.RestoreChatId = chatId
+	}
+	chatApp.Gui.RaiseWindow()
+}
+
+func (chatApp *ChatApplication) saveDraft() {
+	// remembers unsent text of opened channel before switching to another one
+	text := chatApp.Gui.GetInputText()
+	if text == "" {
+		delete(chatApp.Drafts, chatApp.CurrentChatId)
+		return
+	}
+	chatApp.Drafts[chatApp.CurrentChatId] = text
+}
+
+func (chatApp *ChatApplication) openChannelByUser(user models.User) {
+	// selects private channel with user. Creates it if it doesn't exist
+	if user.Id == chatApp.CurrentUser.Id { // NOTES CHANNEL
+		chatApp.Gui.SelectChannel(gui.NOTES_CHANNEL_TITLE)
+		return
+	}
+
+	if !chatApp.isChannelInList(user.Id) {
+		newChannel := models.Channel{Id: user.Id, Title: user.Username}
+		chatApp.Gui.AppendChannel(newChannel.Title)
+		chatApp.Channels = append(chatApp.Channels, newChannel)
+	}
+	chatApp.Gui.SelectChannel(user.Username)
+}
+
+func (chatApp *ChatApplication) openNotesChannel() {
+	chatApp.openChannel(chatApp.CurrentUser.Id)
+}
+
+func main() {
+	verbose := flag.Bool("verbose", false, "write debug lines and all socket events to log")
+	chatId := flag.Int64("chat", utils.GROUP_CHAT_ID, "id of chat opened after login")
+	flag.Parse()
+	if err := logger.Init("client", *verbose); utils.IsError(err) {
+		logger.Warning("Can't open log file: " + err.Error())
+	}
+	if network.IsClientRunning() {
+		// closed window of running client is shown instead of starting second one
+		if err := network.OpenChatInClient(*chatId); !utils.IsError(err) {
+			return
+		}
+	}
+	chatApp := ChatApplication{}
+	chatApp.init(gui.NewChatGui)
+	defer chatApp.MessagesCache.Close()
+	defer chatApp.Plugins.Close()
+	if *chatId != utils.GROUP_CHAT_ID {
+		chatApp.RestoreChatId = *chatId
+	}
+	listener, err := network.ListenControl(func(chatId int64) {
+		chatApp.Loop.Post(func() { chatApp.showChat(chatId) })
+	})
+	if utils.IsError(err) {
+		logger.Warning("Can't listen for commands of notifier: " + err.Error())
+	} else {
+		defer listener.Close()
+	}
+	go chatApp.Loop.Run()
+	settings := utils.GetSettingsFromFile()
+	if len(settings.Profiles) > 1 {
+		chatApp.Gui.ShowServerPicker(i18n.T("Choose server"), chatApp.Gui.OnSwitchServer)
+	} else {
+		go chatApp.connect(settings.HostData, false)
+	}
+	go chatApp.trackPresence()
+	go chatApp.trackConnection()
+	go chatApp.trackScheduledMessages()
+	go chatApp.trackOutgoingMessages()
 	chatApp.Gui.ShowWindow()
 }
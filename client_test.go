@@ -0,0 +1,171 @@
+// client_test.go
+package main
+
+// root directory has several programs, so tests of desktop client
+// are run with its file: go test client.go client_test.go. Test driver
+// of fyne applies theme in own goroutine, so -race reports it for widgets
+// updated by event loop
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/test"
+
+	"chat/chatclient"
+	"chat/gui"
+	"chat/models"
+	"chat/testserver"
+)
+
+const EVENT_TIMEOUT = 5 * time.Second
+
+func startChatApp(t *testing.T, server *testserver.TestServer) *ChatApplication {
+	// client with gui of fyne test driver. Settings and caches are
+	// written to temporary dir which is working dir of test
+	workDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(workDir) })
+
+	chatApp := &ChatApplication{}
+	chatApp.init(func() *gui.ChatGui { return gui.NewChatGuiWithApp(test.NewApp()) })
+	t.Cleanup(func() {
+		chatApp.Loop.Invoke(func() {
+			if chatApp.Client != nil {
+				chatApp.Client.Close()
+			}
+			chatApp.MessagesCache.Close()
+			chatApp.Plugins.Close()
+		})
+	})
+	go chatApp.Loop.Run()
+	if !chatApp.connect(server.HostData, false) {
+		t.Fatal("client isn't connected")
+	}
+	return chatApp
+}
+
+func waitFor(t *testing.T, chatApp *ChatApplication, description string,
+	condition func() bool) {
+	// checks state of client in its event loop until condition is met
+	t.Helper()
+	deadline := time.Now().Add(EVENT_TIMEOUT)
+	for {
+		isMet := false
+		chatApp.Loop.Invoke(func() { isMet = condition() })
+		if isMet {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(description)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func isMessageShown(chatApp *ChatApplication, id int64) bool {
+	_, ok := chatApp.Gui.MessagesList.GetMessageObject(id)
+	return ok
+}
+
+func loginBot(t *testing.T, server *testserver.TestServer,
+	username string) (*chatclient.Client, chan models.SavedMessage) {
+	// another user who writes to user of client. Messages are
+	// passed to test when server sends them back
+	client := chatclient.NewClient()
+	loggedIn := make(chan bool, 1)
+	messages := make(chan models.SavedMessage, 10)
+	client.SetOnConnection(func() { client.Login(chatclient.GetAuthRequest(username, "secret")) })
+	client.SetOnLogin(func(models.SuccessfulAuth) { loggedIn <- true })
+	client.SetOnMessage(func(msg models.SavedMessage) { messages <- msg })
+	if err := client.Connect(server.HostData); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	select {
+	case <-loggedIn:
+	case <-time.After(EVENT_TIMEOUT):
+		t.Fatal(username + " isn't logged in")
+	}
+	return client, messages
+}
+
+func nextMessage(t *testing.T, messages chan models.SavedMessage) models.SavedMessage {
+	t.Helper()
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(EVENT_TIMEOUT):
+		t.Fatal("message isn't sent")
+	}
+	return models.SavedMessage{}
+}
+
+func TestChatAppChannels(t *testing.T) {
+	server, err := testserver.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	chatApp := startChatApp(t, server)
+	chatApp.Loop.Invoke(func() { chatApp.sendLoginData("alice", "secret", false) })
+	waitFor(t, chatApp, "alice isn't logged in", func() bool { return chatApp.LoggedIn })
+	var alice models.User
+	chatApp.Loop.Invoke(func() { alice = chatApp.CurrentUser })
+	bob, bobMessages := loginBot(t, server, "bob")
+
+	// main channel is opened after login
+	bob.SendMessage(0, "hello all")
+	groupMessage := nextMessage(t, bobMessages)
+	waitFor(t, chatApp, "message of main channel isn't shown", func() bool {
+		return isMessageShown(chatApp, groupMessage.Id)
+	})
+
+	// first private message adds channel of its author
+	bob.SendMessage(alice.Id, "hello alice")
+	privateMessage := nextMessage(t, bobMessages)
+	waitFor(t, chatApp, "private message isn't counted in channel of bob", func() bool {
+		return chatApp.Gui.ChannelsList.Unread["bob"] == 1 && chatApp.isChannelInList(bob.User.Id)
+	})
+	chatApp.Loop.Invoke(func() {
+		if isMessageShown(chatApp, privateMessage.Id) {
+			t.Error("private message is shown in main channel")
+		}
+		chatApp.openChannelByUser(bob.User)
+	})
+	waitFor(t, chatApp, "private channel isn't opened", func() bool {
+		return chatApp.CurrentChatId == bob.User.Id && isMessageShown(chatApp, privateMessage.Id)
+	})
+	chatApp.Loop.Invoke(func() {
+		if isMessageShown(chatApp, groupMessage.Id) {
+			t.Error("message of main channel is shown in private channel")
+		}
+	})
+
+	// message of closed channel increments its unread counter
+	bob.SendMessage(0, "are you here?")
+	unreadMessage := nextMessage(t, bobMessages)
+	waitFor(t, chatApp, "unread message isn't counted", func() bool {
+		return chatApp.Gui.ChannelsList.Unread[gui.GROUP_CHANNEL_TITLE] == 1
+	})
+	chatApp.Loop.Invoke(func() {
+		if isMessageShown(chatApp, unreadMessage.Id) {
+			t.Error("message of main channel is shown in private channel")
+		}
+		chatApp.Gui.SelectChannel(gui.GROUP_CHANNEL_TITLE)
+	})
+	waitFor(t, chatApp, "main channel isn't opened", func() bool {
+		return chatApp.CurrentChatId == 0 && isMessageShown(chatApp, unreadMessage.Id)
+	})
+	chatApp.Loop.Invoke(func() {
+		if chatApp.Gui.ChannelsList.GetUnreadCount() != 0 {
+			t.Errorf("unread counters %v aren't cleared", chatApp.Gui.ChannelsList.Unread)
+		}
+	})
+}
@@ -0,0 +1,2031 @@
+// main.go
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/satori/go.uuid"
+
+	"chat/codec"
+	"chat/db"
+	"chat/encrypt"
+	"chat/models"
+	"chat/network"
+	"chat/socket"
+	"chat/utils"
+)
+
+const FAILED_LOGIN_LIMIT int = 5
+const FAILED_LOGIN_TIME_LIMIT int = 2 * 60           // 2 minutes
+const SESSION_TOKEN_LIFETIME int = 30 * 24 * 60 * 60 // 30 days
+const MAX_MESSAGES_PAGE_SIZE int = 500
+const MAX_SEARCH_RESULTS int = 100
+const MESSAGE_RATE_LIMIT int = 10    // messages which can be sent during rate interval
+const MESSAGE_RATE_INTERVAL int = 10 // seconds
+const FILE_UPLOADS_DIR = "uploads"
+const AVATARS_DIR = "avatars"
+const TOTP_ISSUER = "Chat" // name of account in authenticator apps
+const CHALLENGE_LIFETIME = 10 * time.Minute
+const POW_DIFFICULTY int = 20 // about million hashes, less than second for client
+const OUTDATED_CLIENT_ERROR = "Client is outdated: its password scheme is not supported. " +
+	"Please, update the client."
+
+// length of password is checked by client, server gets only its hash
+const INVALID_PASSWORD_HASH_ERROR = "Password hash is not valid. Please, update the client."
+
+type ServerApp struct {
+	Server    *socket.Server
+	Transport *network.ServerWebsocketTransport
+	Sessions  map[string]models.Session // map: socket ID -> Session data
+	Codecs    map[string]codec.Codec    // map: socket ID -> codec of payloads from client's hello
+	Uploads   map[string]*fileUpload    // map: upload ID -> receiving file
+	SentTimes map[int64][]int64         // map: user ID -> times of recently sent messages
+	CommonKey uuid.UUID
+	DB        db.DatabaseAdapter
+
+	TotpSecrets    map[string]string             // map: socket ID -> secret of two-factor auth being enabled
+	OAuthProviders []utils.OAuthProviderSettings // login providers from settings file
+
+	RegistrationChallenge string                           // kind of challenge from settings file
+	Challenges            map[string]registrationChallenge // map: socket ID -> last issued challenge
+	OAuthLogins           map[string]models.User           // map: socket ID -> OAuth user waiting for code
+
+	mutex sync.Mutex // handlers of events are called concurrently
+}
+
+// challenge sent to client with its answer
+type registrationChallenge struct {
+	models.RegistrationChallenge
+	Answer    string // digits of captcha
+	CreatedOn time.Time
+}
+
+// file which is being received from client
+type fileUpload struct {
+	SocketId string
+	File     *os.File
+	Chunks   *utils.ChunkWriter // writes chunks to File in order of offsets
+}
+
+func getRemainedLoginAttemps(userId int64, db db.DatabaseAdapter) int {
+	// returns remained attemps count.
+	// And clears unsuccessful login count if it is no longer relevant
+
+	countFailedLogin := db.CountFailedLogin(userId)
+	if countFailedLogin == 0 {
+		return FAILED_LOGIN_LIMIT
+	}
+
+	lastFailedLoginDate := db.GetLastFailedLoginDate(userId)
+	if lastFailedLoginDate+int64(FAILED_LOGIN_TIME_LIMIT) < utils.GetTimestampNow() {
+		db.ClearFailedLogin(userId)
+		return FAILED_LOGIN_LIMIT
+	}
+	return FAILED_LOGIN_LIMIT - countFailedLogin
+}
+
+func (app *ServerApp) Init() {
+	// Connects to db. Generate utils key (for group channel)
+	// And sets callbacks to socket.io server
+	app.Sessions = make(map[string]models.Session)
+	app.Codecs = make(map[string]codec.Codec)
+	app.Uploads = make(map[string]*fileUpload)
+	app.SentTimes = make(map[int64][]int64)
+	app.TotpSecrets = make(map[string]string)
+	app.Challenges = make(map[string]registrationChallenge)
+	app.OAuthLogins = make(map[string]models.User)
+	settings := utils.GetSettingsFromFile()
+	app.OAuthProviders = settings.OAuthProviders
+	app.RegistrationChallenge = settings.RegistrationChallenge
+	app.CommonKey = uuid.FromBytesOrNil([]byte(utils.COMMON_SECRET_KEY))
+	db := db.DatabaseAdapter{}
+	db.ConnectSqlite("app.db")
+	app.DB = db
+	for _, dir := range []string{FILE_UPLOADS_DIR, AVATARS_DIR} {
+		err := os.MkdirAll(dir, 0755)
+		if utils.IsError(err) {
+			panic(err)
+		}
+	}
+
+	app.Transport = network.GetServerWebsocketTransport()
+	server := socket.NewServer(app.Transport)
+
+	server.On(socket.EVENT_CONNECTION, app.processConnection)
+	server.On(socket.EVENT_DISCONNECTION, app.processDisconnection)
+
+	server.On("/login", app.processNewLogin)
+	server.On("/token-login", app.processTokenLogin)
+	server.On("/oauth-login", app.processOAuthLogin)
+	server.On("/registration-challenge", app.processChallengeRequest)
+	server.On("/logout", app.processLogout)
+	server.On("/register", app.processNewRegistration)
+	server.On("/change-password", app.processPasswordChange)
+	server.On("/delete-account", app.processAccountDeletion)
+	server.On("/enable-2fa", app.processTwoFactorEnabling)
+	server.On("/disable-2fa", app.processTwoFactorDisabling)
+	server.On("/message", app.processNewMessage)
+	server.On("/edit-message", app.processMessageEditing)
+	server.On("/delete-message", app.processMessageDeletion)
+	server.On("/react", app.processReaction)
+	server.On("/pin-message", app.processMessagePin)
+	server.On("/report", app.processReport)
+	server.On("/typing", app.processTyping)
+	server.On("/hello", app.processHello)
+	server.On("/ping", app.processPing)
+	server.On("/presence", app.processPresence)
+	server.On("/message-read", app.processMessageRead)
+	server.On("/get-messages", app.processMessagesRequest)
+	server.On("/search-messages", app.processMessagesSearch)
+	server.On("/get-pinned", app.processPinnedMessagesRequest)
+	server.On("/get-channels", app.processChannelsRequest)
+	server.On("/create-channel", app.processChannelCreation)
+	server.On("/get-channel-members", app.processChannelMembersRequest)
+	server.On("/kick-member", app.processMemberKick)
+	server.On("/set-member-role", app.processMemberRoleChange)
+	server.On("/rename-channel", app.processChannelRenaming)
+	server.On("/file-upload", app.processFileUpload)
+	server.On("/file-download", app.processFileDownload)
+	server.On("/set-avatar", app.processAvatarUpload)
+	server.On("/get-avatar", app.processAvatarRequest)
+	server.On("/set-profile", app.processProfileUpdate)
+	server.On("/get-profile", app.processProfileRequest)
+	server.On("/block-user", app.processUserBlocking)
+	server.On("/add-contact", app.processContactAdding)
+	server.On("/remove-contact", app.processContactRemoval)
+	server.On("/get-contacts", app.processContactsRequest)
+	server.On("/search-users", app.processUsersSearch)
+	server.On("/invite-to-channel", app.processChannelInvitation)
+	server.On("/join-request", app.processJoinRequest)
+	server.On("/get-invitations", app.processInvitationsRequest)
+	server.On("/answer-invitation", app.processInvitationAnswer)
+
+	app.Server = server
+}
+
+func (app *ServerApp) Run(hostData utils.HostData) {
+	// serves https if secure connection is enabled in settings
+	if hostData.Port == 0 { // port is looked up by clients only
+		hostData.Port = utils.DEFAULT_PORT
+	}
+	host := fmt.Sprintf("%s:%d", hostData.Host, hostData.Port)
+	serveMux := http.NewServeMux()
+	app.Server.Handle(serveMux) // socket.io and plain websocket
+
+	log.Println("Starting server at " + host)
+	if hostData.Secure {
+		log.Panic(http.ListenAndServeTLS(host, hostData.CertFile,
+			hostData.KeyFile, serveMux))
+	}
+	log.Panic(http.ListenAndServe(host, serveMux))
+}
+
+func (app *ServerApp) CloseDB() {
+	app.DB.Close()
+}
+
+func (app *ServerApp) processConnection(c socket.Channel) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	log.Println("Connected " + c.Id())
+}
+
+func (app *ServerApp) processDisconnection(c socket.Channel) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	log.Println("Disconnected " + c.Id())
+	session, isLoggedIn := app.Sessions[c.Id()]
+	app.removeSession(c.Id())
+	delete(app.Codecs, c.Id())
+	delete(app.TotpSecrets, c.Id())
+	delete(app.Challenges, c.Id())
+	delete(app.OAuthLogins, c.Id())
+	if isLoggedIn {
+		app.broadcastPresence(session.User)
+	}
+	for uploadId, upload := range app.Uploads {
+		if upload.SocketId == c.Id() {
+			app.cancelFileUpload(uploadId)
+		}
+	}
+}
+
+func (app *ServerApp) processNewLogin(c socket.Channel, encryptedAuthData string) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	authData := models.AuthRequest{}
+	encrypt.Decrypt(app.CommonKey, encryptedAuthData, &authData)
+	if authData.Scheme != models.AUTH_SCHEME_SHA256 {
+		c.Emit("/error", models.NewError(models.PROCESS_LOGIN, models.ERROR_OUTDATED_CLIENT,
+			OUTDATED_CLIENT_ERROR))
+		return
+	}
+	user, err := app.DB.GetUserByName(authData.Username)
+	isUsernameValid := !utils.IsError(err)
+
+	remainedLoginAttempts := getRemainedLoginAttemps(user.Id, app.DB)
+	savedPasswordHash := app.DB.GetUserPasswordHash(authData.Username)
+	isPasswordCorrect := encrypt.CheckPassword(authData.PasswordHash, savedPasswordHash)
+
+	isLoginSuccessful := isPasswordCorrect && isUsernameValid &&
+		remainedLoginAttempts > 0
+
+	if isLoginSuccessful {
+		if encrypt.IsLegacyPasswordHash(savedPasswordHash) {
+			app.upgradePasswordHash(user, authData.PasswordHash)
+		}
+		if !app.checkLoginCode(c, user, authData.Code, remainedLoginAttempts) {
+			return
+		}
+		app.processSuccessfulLogin(c, user)
+	} else {
+		app.processUnsuccessfulLogin(c, user, isUsernameValid,
+			remainedLoginAttempts)
+	}
+}
+
+func (app *ServerApp) checkLoginCode(c socket.Channel, user models.User, code string,
+	remainedLoginAttempts int) bool {
+	// asks one-time code if user has two-factor auth. Wrong codes
+	// are counted like wrong passwords
+	secret := app.DB.GetUserTotpSecret(user.Id)
+	if secret == "" {
+		return true
+	}
+	if code == "" {
+		c.Emit("/2fa-required", encrypt.Encrypt(app.CommonKey,
+			models.TwoFactorRequired{Username: user.Username}))
+		return false
+	}
+	if !encrypt.CheckTotpCode(secret, code, time.Now()) {
+		app.DB.AddNewFailedLogin(user.Id)
+		c.Emit("/error", models.NewError(models.PROCESS_LOGIN, models.ERROR_WRONG_CODE,
+			fmt.Sprintf("Code is not correct. You can try again: %d times",
+				remainedLoginAttempts-1), strconv.Itoa(remainedLoginAttempts-1)))
+		return false
+	}
+	return true
+}
+
+func (app *ServerApp) upgradePasswordHash(user models.User, clientHash string) {
+	// replaces unsalted hash of user registered on old server
+	passwordHash, err := encrypt.HashPassword(clientHash)
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	app.DB.UpdateUserPasswordHash(user.Id, passwordHash)
+	log.Println("Password hash of " + user.Username + " was upgraded")
+}
+
+func (app *ServerApp) processSuccessfulLogin(c socket.Channel, user models.User) {
+	log.Println("New login " + user.Username)
+	delete(app.OAuthLogins, c.Id())
+	newSession := app.createSession(c.Id(), user)
+
+	app.DB.ClearFailedLogin(user.Id)
+	// only hash of token is stored like password hash
+	sessionToken := uuid.NewV4().String()
+	app.DB.AddNewSessionToken(user.Id, encrypt.GetPasswordHash(sessionToken))
+	authData := models.SuccessfulAuth{User: user, SecretKey: newSession.SecretKey,
+		SessionToken: sessionToken, TwoFactor: app.DB.GetUserTotpSecret(user.Id) != ""}
+	c.Emit("/login", encrypt.Encrypt(app.CommonKey, authData))
+
+	app.broadcastPresence(user)
+	for _, session := range app.Sessions { // presence of users online
+		presence := models.Presence{User: session.User,
+			State: app.getUserPresence(session.User.Id)}
+		c.Emit("/presence", app.encryptFor(c.Id(), newSession.SecretKey, presence))
+	}
+	blockedUsers := models.BlockedUsersPack{Users: app.DB.GetBlockedUsers(user.Id)}
+	c.Emit("/blocked-users", app.encryptFor(c.Id(), newSession.SecretKey, blockedUsers))
+	app.deliverMessages(user)
+}
+
+func (app *ServerApp) deliverMessages(user models.User) {
+	// marks messages sent while user was offline as delivered
+	// and notifies senders
+	for senderId, lastMessageId := range app.DB.GetUndeliveredMessages(user.Id) {
+		app.DB.UpdateMessagesStatus(senderId, user.Id, lastMessageId,
+			models.MESSAGE_STATE_DELIVERED)
+		statusUpdate := models.MessageStatusUpdate{ChatId: user.Id,
+			LastMessageId: lastMessageId, Status: models.MESSAGE_STATE_DELIVERED}
+		app.EmitToUser(senderId, "/message-status", statusUpdate)
+	}
+}
+
+func (app *ServerApp) processTokenLogin(c socket.Channel, encryptedAuthData string) {
+	// logs in by token of previous session. Used token is replaced by new one
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	authData := models.TokenAuthRequest{}
+	encrypt.Decrypt(app.CommonKey, encryptedAuthData, &authData)
+	tokenHash := encrypt.GetPasswordHash(authData.Token)
+	minCreatedOn := utils.GetTimestampNow() - int64(SESSION_TOKEN_LIFETIME)
+	user, err := app.DB.GetUserBySessionToken(tokenHash, minCreatedOn)
+	if utils.IsError(err) {
+		log.Println(err)
+		c.Emit("/error", models.NewError(models.PROCESS_TOKEN_LOGIN, models.ERROR_SESSION_EXPIRED,
+			"Session has expired. Please, log in again."))
+		return
+	}
+	app.DB.DeleteSessionToken(tokenHash)
+	app.processSuccessfulLogin(c, user)
+}
+
+func (app *ServerApp) processOAuthLogin(c socket.Channel, encryptedLogin string) {
+	// exchanges code which client got from provider. User is registered
+	// with name from provider on first login
+	login := models.OAuthLogin{}
+	encrypt.Decrypt(app.CommonKey, encryptedLogin, &login)
+	if login.TotpCode != "" {
+		app.mutex.Lock()
+		defer app.mutex.Unlock()
+		user, ok := app.OAuthLogins[c.Id()]
+		if !ok {
+			c.Emit("/error", models.NewError(models.PROCESS_OAUTH_LOGIN, models.ERROR_OAUTH_FAILED,
+				"Please, log in again."))
+			return
+		}
+		app.finishOAuthLogin(c, user, login.TotpCode)
+		return
+	}
+	var provider *utils.OAuthProviderSettings
+	for i := range app.OAuthProviders {
+		if app.OAuthProviders[i].Name == login.Provider {
+			provider = &app.OAuthProviders[i]
+		}
+	}
+	if provider == nil {
+		c.Emit("/error", models.NewError(models.PROCESS_OAUTH_LOGIN, models.ERROR_OAUTH_FAILED,
+			"Login provider "+login.Provider+" is not configured."))
+		return
+	}
+	identity, err := network.ExchangeOAuthCode(*provider, login)
+	if utils.IsError(err) {
+		log.Println("OAuth login failed: " + err.Error())
+		c.Emit("/error", models.NewError(models.PROCESS_OAUTH_LOGIN, models.ERROR_OAUTH_FAILED,
+			"Can't log in by "+provider.Name+": "+err.Error()))
+		return
+	}
+	// state is locked after request to provider, so it doesn't wait for it
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	user, err := app.DB.GetUserByOAuthAccount(provider.Name, identity.Subject)
+	if utils.IsError(err) {
+		// account has no password, random one is hashed to keep column filled
+		passwordHash, err := encrypt.HashPassword(uuid.NewV4().String())
+		if utils.IsError(err) {
+			log.Println(err)
+			c.Emit("/error", models.NewError(models.PROCESS_OAUTH_LOGIN, models.ERROR_INTERNAL,
+				"Can't register user. Please, try again."))
+			return
+		}
+		user = models.User{Username: app.getFreeUsername(identity)}
+		app.DB.AddNewUser(&user, passwordHash)
+		app.DB.AddOAuthAccount(provider.Name, identity.Subject, user.Id)
+		log.Println("New user " + user.Username + " from " + provider.Name)
+	}
+	app.finishOAuthLogin(c, user, "")
+}
+
+func (app *ServerApp) finishOAuthLogin(c socket.Channel, user models.User, code string) {
+	// provider doesn't replace second factor, so account with two-factor
+	// auth waits for one-time code like after password
+	remainedLoginAttempts := getRemainedLoginAttemps(user.Id, app.DB)
+	if remainedLoginAttempts <= 0 {
+		delete(app.OAuthLogins, c.Id())
+		app.processUnsuccessfulLogin(c, user, true, remainedLoginAttempts)
+		return
+	}
+	if !app.checkLoginCode(c, user, code, remainedLoginAttempts) {
+		app.OAuthLogins[c.Id()] = user
+		return
+	}
+	app.processSuccessfulLogin(c, user)
+}
+
+func (app *ServerApp) getFreeUsername(identity network.OAuthIdentity) string {
+	// name from provider without forbidden characters. Number is added
+	// if it's used already
+	name := identity.PreferredUsername
+	if name == "" {
+		name = strings.Split(identity.Email, "@")[0]
+	}
+	if name == "" {
+		name = identity.Name
+	}
+	name = strings.Map(func(c rune) rune {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("_-.", c) {
+			return c
+		} else if unicode.IsSpace(c) {
+			return '_'
+		}
+		return -1
+	}, name)
+	if runes := []rune(name); len(runes) > utils.MAX_USERNAME_LENGTH-4 {
+		name = string(runes[:utils.MAX_USERNAME_LENGTH-4]) // place for number
+	}
+	if utils.IsError(utils.ValidateUsername(name)) {
+		name = "user"
+	}
+	username := name
+	for i := 2; app.DB.IsUserExist(username) || app.DB.IsGroupExist(username); i++ {
+		username = name + strconv.Itoa(i)
+	}
+	return username
+}
+
+func (app *ServerApp) processUnsuccessfulLogin(c socket.Channel,
+	user models.User, isUsernameValid bool, remainedLoginAttempts int) {
+	var serverError models.Error
+	if !isUsernameValid {
+		serverError = models.NewError(models.PROCESS_LOGIN, models.ERROR_WRONG_USERNAME,
+			"Username is not correct.")
+	} else if remainedLoginAttempts > 0 {
+		app.DB.AddNewFailedLogin(user.Id)
+		serverError = models.NewError(models.PROCESS_LOGIN, models.ERROR_WRONG_PASSWORD,
+			fmt.Sprintf("Password is not correct. You can try again: %d times",
+				remainedLoginAttempts), strconv.Itoa(remainedLoginAttempts))
+	} else {
+		serverError = models.NewError(models.PROCESS_LOGIN, models.ERROR_TOO_MANY_ATTEMPTS,
+			fmt.Sprintf("You have tried login more than %d times. "+
+				"This username was blocked for 2 minutes.", FAILED_LOGIN_LIMIT),
+			strconv.Itoa(FAILED_LOGIN_LIMIT))
+	}
+	c.Emit("/error", serverError)
+}
+
+func (app *ServerApp) processNewRegistration(c socket.Channel, encryptedAuthData string) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	authData := models.AuthRequest{}
+	encrypt.Decrypt(app.CommonKey, encryptedAuthData, &authData)
+	process := models.PROCESS_REGISTRATION
+	if authData.Scheme != models.AUTH_SCHEME_SHA256 {
+		c.Emit("/error", models.NewError(process, models.ERROR_OUTDATED_CLIENT,
+			OUTDATED_CLIENT_ERROR))
+	} else if !app.checkChallengeAnswer(c, authData.Answer) {
+		c.Emit("/error", models.NewError(process, models.ERROR_WRONG_ANSWER,
+			"Answer to registration challenge is not correct. Please, try again."))
+	} else if err := utils.ValidateUsername(authData.Username); utils.IsError(err) {
+		c.Emit("/error", models.NewError(process, models.ERROR_INVALID_USERNAME, err.Error()))
+	} else if !encrypt.IsClientPasswordHash(authData.PasswordHash) {
+		c.Emit("/error", models.NewError(process, models.ERROR_INVALID_PASSWORD,
+			INVALID_PASSWORD_HASH_ERROR))
+	} else if app.DB.IsUserExist(authData.Username) || app.DB.IsGroupExist(authData.Username) {
+		c.Emit("/error", models.NewError(process, models.ERROR_USERNAME_EXISTS,
+			"Username "+authData.Username+" already exists.", authData.Username))
+	} else {
+		passwordHash, err := encrypt.HashPassword(authData.PasswordHash)
+		if utils.IsError(err) {
+			log.Println(err)
+			c.Emit("/error", models.NewError(process, models.ERROR_INTERNAL,
+				"Can't register user. Please, try again."))
+			return
+		}
+		user := models.User{Username: authData.Username}
+		app.DB.AddNewUser(&user, passwordHash)
+		app.processSuccessfulLogin(c, user)
+	}
+}
+
+func (app *ServerApp) processChallengeRequest(c socket.Channel) {
+	// new challenge replaces previous one of client
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	challenge := registrationChallenge{CreatedOn: time.Now()}
+	challenge.Kind = app.RegistrationChallenge
+	switch challenge.Kind {
+	case models.CHALLENGE_POW:
+		challenge.Nonce = uuid.NewV4().String()
+		challenge.Difficulty = POW_DIFFICULTY
+	case models.CHALLENGE_CAPTCHA:
+		answer, image, err := utils.GenerateCaptcha()
+		if utils.IsError(err) {
+			log.Println(err)
+			c.Emit("/error", models.NewError(models.PROCESS_REGISTRATION, models.ERROR_INTERNAL,
+				"Can't create registration challenge. Please, try again."))
+			return
+		}
+		challenge.Answer = answer
+		challenge.Image = image
+	}
+	app.Challenges[c.Id()] = challenge
+	c.Emit("/registration-challenge", challenge.RegistrationChallenge)
+}
+
+func (app *ServerApp) checkChallengeAnswer(c socket.Channel, answer string) bool {
+	// each challenge can be answered once
+	if app.RegistrationChallenge == "" {
+		return true
+	}
+	challenge, ok := app.Challenges[c.Id()]
+	delete(app.Challenges, c.Id())
+	if !ok || challenge.Kind != app.RegistrationChallenge ||
+		time.Since(challenge.CreatedOn) > CHALLENGE_LIFETIME {
+		return false
+	}
+	if challenge.Kind == models.CHALLENGE_POW {
+		return encrypt.CheckProofOfWork(challenge.Nonce, answer, challenge.Difficulty)
+	}
+	return strings.TrimSpace(answer) == challenge.Answer
+}
+
+func (app *ServerApp) checkAccountPassword(user models.User, passwordHash string,
+	process string) (models.Error, bool) {
+	// password is confirmed before changes of account. Wrong attempts
+	// are counted like failed logins
+	remainedLoginAttempts := getRemainedLoginAttemps(user.Id, app.DB)
+	if remainedLoginAttempts <= 0 {
+		return models.NewError(process, models.ERROR_TOO_MANY_ATTEMPTS,
+			fmt.Sprintf("You have entered wrong password more than %d times. "+
+				"Please, try again in 2 minutes.", FAILED_LOGIN_LIMIT),
+			strconv.Itoa(FAILED_LOGIN_LIMIT)), false
+	}
+	savedPasswordHash := app.DB.GetUserPasswordHash(user.Username)
+	if !encrypt.CheckPassword(passwordHash, savedPasswordHash) {
+		app.DB.AddNewFailedLogin(user.Id)
+		return models.NewError(process, models.ERROR_WRONG_PASSWORD,
+			fmt.Sprintf("Password is not correct. You can try again: %d times",
+				remainedLoginAttempts-1), strconv.Itoa(remainedLoginAttempts-1)), false
+	}
+	app.DB.ClearFailedLogin(user.Id)
+	return models.Error{}, true
+}
+
+func (app *ServerApp) processPasswordChange(c socket.Channel, encryptedChange string) {
+	// saves hash of new password and logs out all clients of user
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	change := models.PasswordChange{}
+	encrypt.Decrypt(session.SecretKey, encryptedChange, &change)
+	process := models.PROCESS_CHANGE_PASSWORD
+	if change.Scheme != models.AUTH_SCHEME_SHA256 {
+		c.Emit("/error", models.NewError(process, models.ERROR_OUTDATED_CLIENT,
+			OUTDATED_CLIENT_ERROR))
+	} else if serverError, ok := app.checkAccountPassword(session.User, change.OldPasswordHash,
+		process); !ok {
+		c.Emit("/error", serverError)
+	} else if !encrypt.IsClientPasswordHash(change.NewPasswordHash) {
+		c.Emit("/error", models.NewError(process, models.ERROR_INVALID_PASSWORD,
+			INVALID_PASSWORD_HASH_ERROR))
+	} else {
+		passwordHash, err := encrypt.HashPassword(change.NewPasswordHash)
+		if !utils.IsError(err) {
+			app.DB.UpdateUserPasswordHash(session.User.Id, passwordHash)
+			app.DB.DeleteUserSessionTokens(session.User.Id)
+			log.Println("Password of " + session.User.Username + " was changed")
+			app.logoutUser(session.User, "Password was changed. Please, log in with new password.")
+			return
+		}
+		log.Println(err)
+		c.Emit("/error", models.NewError(process, models.ERROR_INTERNAL,
+			"Can't change password. Please, try again."))
+	}
+}
+
+func (app *ServerApp) processAccountDeletion(c socket.Channel, encryptedDeletion string) {
+	// deletes user with his messages and files after password confirmation
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	deletion := models.AccountDeletion{}
+	encrypt.Decrypt(session.SecretKey, encryptedDeletion, &deletion)
+	process := models.PROCESS_DELETE_ACCOUNT
+	if deletion.Scheme != models.AUTH_SCHEME_SHA256 {
+		c.Emit("/error", models.NewError(process, models.ERROR_OUTDATED_CLIENT,
+			OUTDATED_CLIENT_ERROR))
+		return
+	}
+	if serverError, ok := app.checkAccountPassword(session.User, deletion.PasswordHash,
+		process); !ok {
+		c.Emit("/error", serverError)
+		return
+	}
+	app.logoutUser(session.User, "Account was deleted.")
+	for _, path := range app.DB.DeleteUser(session.User.Id) {
+		os.Remove(path)
+	}
+	os.Remove(getAvatarPath(session.User.Id))
+	log.Println("Account of " + session.User.Username + " was deleted")
+}
+
+func (app *ServerApp) processTwoFactorEnabling(c socket.Channel, encryptedEnabling string) {
+	// sends new secret or saves secret sent before if code made from it is correct
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	enabling := models.TwoFactorEnabling{}
+	encrypt.Decrypt(session.SecretKey, encryptedEnabling, &enabling)
+	process := models.PROCESS_ENABLE_2FA
+	secret, isSent := app.TotpSecrets[c.Id()]
+	if enabling.Code == "" || !isSent {
+		newSecret, err := encrypt.GenerateTotpSecret()
+		if utils.IsError(err) {
+			log.Println(err)
+			c.Emit("/error", models.NewError(process, models.ERROR_INTERNAL,
+				"Can't enable two-factor authentication. Please, try again."))
+			return
+		}
+		app.TotpSecrets[c.Id()] = newSecret
+		totpSecret := models.TwoFactorSecret{Secret: newSecret,
+			Uri: encrypt.GetTotpUri(TOTP_ISSUER, session.User.Username, newSecret)}
+		c.Emit("/2fa-secret", app.encryptFor(c.Id(), session.SecretKey, totpSecret))
+		return
+	}
+	if !encrypt.CheckTotpCode(secret, enabling.Code, time.Now()) {
+		c.Emit("/error", models.NewError(process, models.ERROR_WRONG_CODE,
+			"Code is not correct. Check time of device and try again."))
+		return
+	}
+	delete(app.TotpSecrets, c.Id())
+	app.DB.SetUserTotpSecret(session.User.Id, secret)
+	log.Println("Two-factor authentication of " + session.User.Username + " was enabled")
+	app.EmitToUser(session.User.Id, "/2fa-status", models.TwoFactorStatus{Enabled: true})
+}
+
+func (app *ServerApp) processTwoFactorDisabling(c socket.Channel, encryptedDisabling string) {
+	// removes secret after password confirmation
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	disabling := models.TwoFactorDisabling{}
+	encrypt.Decrypt(session.SecretKey, encryptedDisabling, &disabling)
+	process := models.PROCESS_DISABLE_2FA
+	if disabling.Scheme != models.AUTH_SCHEME_SHA256 {
+		c.Emit("/error", models.NewError(process, models.ERROR_OUTDATED_CLIENT,
+			OUTDATED_CLIENT_ERROR))
+		return
+	}
+	if serverError, ok := app.checkAccountPassword(session.User, disabling.PasswordHash,
+		process); !ok {
+		c.Emit("/error", serverError)
+		return
+	}
+	app.DB.SetUserTotpSecret(session.User.Id, "")
+	log.Println("Two-factor authentication of " + session.User.Username + " was disabled")
+	app.EmitToUser(session.User.Id, "/2fa-status", models.TwoFactorStatus{Enabled: false})
+}
+
+func (app *ServerApp) processLogout(c socket.Channel, encryptedRequest string) {
+	// closes session of client. Socket stays connected for next login
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	request := models.LogoutRequest{}
+	encrypt.Decrypt(session.SecretKey, encryptedRequest, &request)
+	if request.SessionToken != "" {
+		app.DB.DeleteSessionToken(encrypt.GetPasswordHash(request.SessionToken))
+	}
+	app.removeSession(c.Id())
+	for uploadId, upload := range app.Uploads {
+		if upload.SocketId == c.Id() {
+			app.cancelFileUpload(uploadId)
+		}
+	}
+	app.broadcastPresence(session.User)
+	log.Println("User " + session.User.Username + " logged out")
+}
+
+func (app *ServerApp) logoutUser(user models.User, reason string) {
+	// closes sessions of user on all his clients. Sockets stay connected
+	for socketId, session := range app.Sessions {
+		if session.User.Id != user.Id {
+			continue
+		}
+		app.removeSession(socketId)
+		for uploadId, upload := range app.Uploads {
+			if upload.SocketId == socketId {
+				app.cancelFileUpload(uploadId)
+			}
+		}
+		channel, err := app.Server.GetChannel(socketId)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		channel.Emit("/logout", models.Logout{Reason: reason})
+	}
+	app.broadcastPresence(user)
+}
+
+func (app *ServerApp) processNewMessage(c socket.Channel, encryptedMessage string) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	secretKey := session.SecretKey
+	msg := models.Message{}
+	encrypt.Decrypt(secretKey, encryptedMessage, &msg)
+	msg.User = session.User // sender is known by session, not by message
+	if ack, ok := session.Acks[msg.LocalId]; ok && msg.LocalId != 0 {
+		// message is sent again because ack was late, it's saved already.
+		// Check and saving are under one lock, so retries aren't saved twice
+		c.Emit("/message-ack", app.encryptFor(c.Id(), secretKey, ack))
+		return
+	}
+	msg.Attachment = models.Attachment{} // files are sent only by /file-upload
+	if !app.isChatMember(msg.User, msg.ChatId) {
+		log.Println("User " + msg.User.Username + " is not member of chat")
+		return
+	}
+	if msg.IsReply() && !app.canReplyTo(msg, msg.ReplyToId) {
+		log.Printf("Message %d can't be replied in this chat\n", msg.ReplyToId)
+		msg.ReplyToId = 0
+	}
+	if msg.IsSticker() {
+		if err := utils.ValidateStickerUrl(msg.Sticker); utils.IsError(err) {
+			c.Emit("/error", models.NewError(models.PROCESS_SEND_STICKER,
+				models.ERROR_INVALID_STICKER, err.Error()))
+			return
+		}
+		msg.Text = "" // sticker is shown instead of text
+	}
+	msg.ForwardedFrom = "" // author of original is known only to server
+	if msg.ForwardId != 0 && !app.fillForwarded(session.User, &msg) {
+		c.Emit("/error", models.NewError(models.PROCESS_FORWARD_MESSAGE,
+			models.ERROR_PERMISSION_DENIED, "Message can't be forwarded."))
+		return
+	}
+	if retryAfter := app.getRateLimitDelay(session.User.Id); retryAfter > 0 {
+		limited := models.RateLimited{RetryAfter: retryAfter, Message: msg}
+		c.Emit("/rate-limited", app.encryptFor(c.Id(), secretKey, limited))
+		return
+	}
+
+	localId := msg.LocalId
+	msg.LocalId = 0 // id of sender isn't sent to others
+	savedMessage := app.DB.AddNewMessage(msg)
+	if localId != 0 {
+		// ack goes before message itself, so sender replaces its pending message
+		ack := models.MessageAck{LocalId: localId, ChatId: msg.ChatId,
+			Id: savedMessage.Id, CreatedOn: savedMessage.CreatedOn}
+		session.Acks[localId] = ack
+		c.Emit("/message-ack", app.encryptFor(c.Id(), secretKey, ack))
+	}
+	app.sendNewMessage(c, secretKey, savedMessage)
+}
+
+func (app *ServerApp) fillForwarded(user models.User, msg *models.Message) bool {
+	// forwarded message gets text and author of original one.
+	// Returns false if user of session can't read original
+	original, err := app.DB.GetMessageById(msg.ForwardId)
+	if utils.IsError(err) || !app.canReadMessage(user, original.Message) {
+		log.Printf("Message %d can't be forwarded by this user\n", msg.ForwardId)
+		return false
+	}
+	msg.Text = original.Text
+	msg.Sticker = ""
+	if !original.HasAttachment() { // files aren't forwarded, so only stickers by url
+		msg.Sticker = original.Sticker
+	}
+	msg.ForwardedFrom = original.User.Username
+	if original.IsForwarded() { // chain of forwards keeps first author
+		msg.ForwardedFrom = original.ForwardedFrom
+	}
+	return true
+}
+
+func (app *ServerApp) getRateLimitDelay(userId int64) int {
+	// returns seconds after which user can send next message.
+	// 0 if message can be sent now, then it is counted
+	now := utils.GetTimestampNow()
+	var sentTimes []int64
+	for _, sentTime := range app.SentTimes[userId] {
+		if sentTime+int64(MESSAGE_RATE_INTERVAL) > now {
+			sentTimes = append(sentTimes, sentTime)
+		}
+	}
+	if len(sentTimes) >= MESSAGE_RATE_LIMIT {
+		app.SentTimes[userId] = sentTimes
+		return int(sentTimes[0] + int64(MESSAGE_RATE_INTERVAL) - now)
+	}
+	app.SentTimes[userId] = append(sentTimes, now)
+	return 0
+}
+
+func (app *ServerApp) canReplyTo(msg models.Message, repliedId int64) bool {
+	// replied message must be in same group or private chat as reply
+	replied, err := app.DB.GetMessageById(repliedId)
+	if utils.IsError(err) {
+		return false
+	}
+	if msg.GetChatType() == "group" {
+		return replied.ChatId == msg.ChatId
+	}
+	return replied.User.Id == msg.User.Id && replied.ChatId == msg.ChatId ||
+		replied.User.Id == msg.ChatId && replied.ChatId == msg.User.Id
+}
+
+func (app *ServerApp) sendNewMessage(c socket.Channel, secretKey uuid.UUID,
+	savedMessage models.SavedMessage) {
+	// sends saved message to sender and recipients
+	msg := savedMessage.Message
+	if msg.GetChatType() == "group" {
+		app.emitToGroup(msg.ChatId, "/message", savedMessage)
+		return
+	}
+	if msg.ChatId != msg.User.Id { // Personal message
+		if app.trySaveNewChannel(msg) {
+			// send new channels to recipient (if he is online).
+			pack := models.ChannelsPack{Channels: app.DB.GetChannels(msg.ChatId)}
+			app.EmitToUser(msg.ChatId, "/get-channels", pack)
+		}
+		if app.isOnline(msg.ChatId) {
+			app.DB.UpdateMessagesStatus(msg.User.Id, msg.ChatId, savedMessage.Id,
+				models.MESSAGE_STATE_DELIVERED)
+			savedMessage.Status = models.MESSAGE_STATE_DELIVERED
+		}
+		app.EmitToUser(msg.ChatId, "/message", savedMessage)
+	}
+	c.Emit("/message", app.encryptFor(c.Id(), secretKey, savedMessage))
+}
+
+func (app *ServerApp) processMessageEditing(c socket.Channel, encryptedEditing string) {
+	// saves new text of message and sends it to chat members.
+	// Only sender can edit message
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	editing := models.MessageEditing{}
+	encrypt.Decrypt(session.SecretKey, encryptedEditing, &editing)
+
+	savedMessage, err := app.DB.GetMessageById(editing.Id)
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	if savedMessage.User.Id != session.User.Id || savedMessage.HasAttachment() ||
+		savedMessage.IsSticker() || editing.Text == "" {
+		log.Println("User " + session.User.Username + " can't edit message")
+		return
+	}
+	savedMessage.Text = editing.Text
+	savedMessage.EditedOn = app.DB.UpdateMessageText(editing.Id, editing.Text)
+	app.emitToChatMembers("/edit-message", savedMessage)
+}
+
+func (app *ServerApp) processMessageDeletion(c socket.Channel, encryptedDeletion string) {
+	// deletes message with attached files and notifies chat members.
+	// Only sender can delete message. Moderators of group delete any message
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	deletion := models.MessageDeletion{}
+	encrypt.Decrypt(session.SecretKey, encryptedDeletion, &deletion)
+
+	savedMessage, err := app.DB.GetMessageById(deletion.Id)
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	isModerator := models.IsModeratorRole(app.getMemberRole(savedMessage.ChatId, session.User.Id))
+	if savedMessage.User.Id != session.User.Id && !isModerator {
+		log.Println("User " + session.User.Username + " can't delete message")
+		return
+	}
+	for _, path := range app.DB.DeleteMessage(deletion.Id) {
+		os.Remove(path)
+	}
+	app.emitToChatMembers("/delete-message", savedMessage)
+}
+
+func (app *ServerApp) processReaction(c socket.Channel, encryptedReaction string) {
+	// adds or removes reaction of user and sends reactions of message to chat members
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	reaction := models.MessageReaction{}
+	encrypt.Decrypt(session.SecretKey, encryptedReaction, &reaction)
+	if err := utils.ValidateReaction(reaction.Emoji); utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	savedMessage, err := app.DB.GetMessageById(reaction.MessageId)
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	if !app.canReadMessage(session.User, savedMessage.Message) {
+		log.Println("User " + session.User.Username + " can't react to message")
+		return
+	}
+	app.DB.ToggleReaction(reaction.MessageId, session.User.Id, reaction.Emoji)
+	savedMessage, err = app.DB.GetMessageById(reaction.MessageId)
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	app.emitToChatMembers("/message-reactions", savedMessage)
+}
+
+func (app *ServerApp) processMessagePin(c socket.Channel, encryptedPin string) {
+	// pins or unpins message and sends it to chat members
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	pin := models.MessagePin{}
+	encrypt.Decrypt(session.SecretKey, encryptedPin, &pin)
+
+	savedMessage, err := app.DB.GetMessageById(pin.MessageId)
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	if !app.canPinMessage(session.User, savedMessage.Message) {
+		log.Println("User " + session.User.Username + " can't pin message")
+		return
+	}
+	if savedMessage.Pinned == pin.Pinned {
+		return
+	}
+	chatId := savedMessage.ChatId
+	if savedMessage.GetChatType() == "private" && savedMessage.ChatId == session.User.Id {
+		chatId = savedMessage.User.Id // message from partner
+	}
+	pinned := app.DB.GetPinnedMessages(session.User.Id, chatId)
+	if pin.Pinned && len(pinned) >= models.MAX_PINNED_MESSAGES {
+		log.Printf("Chat %d has too many pinned messages\n", chatId)
+		return
+	}
+	app.DB.SetMessagePinned(pin.MessageId, session.User.Id, pin.Pinned)
+	savedMessage.Pinned = pin.Pinned
+	app.emitToChatMembers("/message-pinned", savedMessage)
+}
+
+func (app *ServerApp) canPinMessage(user models.User, msg models.Message) bool {
+	// partners pin messages of private chats. In created groups only owner
+	// can pin messages. Main channel has no owner, so it has no pinned messages
+	if !app.canReadMessage(user, msg) || msg.ChatId == utils.GROUP_CHAT_ID {
+		return false
+	}
+	if msg.GetChatType() == "group" {
+		return app.DB.GetGroupOwnerId(msg.ChatId) == user.Id
+	}
+	return true
+}
+
+func (app *ServerApp) processReport(c socket.Channel, encryptedReport string) {
+	// adds report of message or user to moderation queue. Reported user
+	// is author of message, so clients can't blame others by message id
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	report := models.Report{}
+	encrypt.Decrypt(session.SecretKey, encryptedReport, &report)
+	if err := utils.ValidateReport(report); utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	if report.MessageId != 0 {
+		savedMessage, err := app.DB.GetMessageById(report.MessageId)
+		if utils.IsError(err) {
+			log.Println(err)
+			return
+		}
+		if !app.canReadMessage(session.User, savedMessage.Message) {
+			log.Println("User " + session.User.Username + " can't report message")
+			return
+		}
+		report.UserId = savedMessage.User.Id
+	} else if _, err := app.DB.GetUserById(int(report.UserId)); utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	if report.UserId == session.User.Id {
+		log.Println("User " + session.User.Username + " can't report own account")
+		return
+	}
+	app.DB.AddNewReport(session.User.Id, report)
+	log.Printf("User %s reported user %d for %s\n", session.User.Username, report.UserId,
+		report.Category)
+}
+
+func (app *ServerApp) processFileUpload(c socket.Channel, encryptedChunk string) {
+	// writes chunk to uploaded file. After all chunks
+	// file is sent to chat as message with attachment
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	chunk := models.FileUploadChunk{}
+	encrypt.Decrypt(session.SecretKey, encryptedChunk, &chunk)
+
+	upload, err := app.getFileUpload(c.Id(), chunk)
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	if chunk.Offset+int64(len(chunk.Data)) > utils.MAX_ATTACHMENT_SIZE {
+		log.Println("Upload " + chunk.UploadId + " is too big")
+		app.cancelFileUpload(chunk.UploadId)
+		return
+	}
+	isFinished, err := upload.Chunks.Write(chunk.Offset, chunk.Data, chunk.IsLast)
+	if utils.IsError(err) {
+		log.Println(err)
+		app.cancelFileUpload(chunk.UploadId)
+		return
+	}
+	if !isFinished {
+		return
+	}
+	size := upload.Chunks.Size
+	upload.File.Close()
+	delete(app.Uploads, chunk.UploadId)
+
+	msg := chunk.Message
+	msg.User = session.User
+	if !app.isChatMember(msg.User, msg.ChatId) {
+		os.Remove(upload.File.Name())
+		return
+	}
+	msg.Text = filepath.Base(msg.Text)
+	msg.ForwardId, msg.ForwardedFrom, msg.LocalId = 0, "", 0
+	if msg.IsReply() && !app.canReplyTo(msg, msg.ReplyToId) {
+		log.Printf("Message %d can't be replied in this chat\n", msg.ReplyToId)
+		msg.ReplyToId = 0
+	}
+	if msg.IsSticker() {
+		// sticker of local pack is file which is shown as image
+		if err := utils.ValidateStickerFile(msg.Text, size); utils.IsError(err) {
+			os.Remove(upload.File.Name())
+			c.Emit("/error", models.NewError(models.PROCESS_SEND_STICKER,
+				models.ERROR_INVALID_STICKER, err.Error()))
+			return
+		}
+		msg.Sticker = msg.Text
+	}
+	msg.Attachment = models.Attachment{FileName: msg.Text, Size: size}
+	savedMessage := app.DB.AddNewMessage(msg)
+	app.DB.AddNewAttachment(savedMessage.Id, &savedMessage.Attachment, upload.File.Name())
+	app.sendNewMessage(c, session.SecretKey, savedMessage)
+}
+
+func (app *ServerApp) getFileUpload(socketId string,
+	chunk models.FileUploadChunk) (*fileUpload, error) {
+	// returns started upload. New upload is created by chunk which
+	// comes first, it isn't always first chunk of file
+	upload, ok := app.Uploads[chunk.UploadId]
+	if ok {
+		if upload.SocketId != socketId {
+			return nil, errors.New("Upload " + chunk.UploadId + " belongs to another client")
+		}
+		return upload, nil
+	}
+	file, err := os.Create(filepath.Join(FILE_UPLOADS_DIR, uuid.NewV4().String()))
+	if utils.IsError(err) {
+		return nil, err
+	}
+	upload = &fileUpload{SocketId: socketId, File: file, Chunks: utils.NewChunkWriter(file)}
+	app.Uploads[chunk.UploadId] = upload
+	return upload, nil
+}
+
+func (app *ServerApp) cancelFileUpload(uploadId string) {
+	// removes partially received file
+	upload, ok := app.Uploads[uploadId]
+	if !ok {
+		return
+	}
+	upload.File.Close()
+	os.Remove(upload.File.Name())
+	delete(app.Uploads, uploadId)
+}
+
+func (app *ServerApp) processFileDownload(c socket.Channel,
+	requestData models.FileDownloadRequest) {
+	// sends attached file by chunks if user has access to its chat
+	file, payloadCodec, secretKey, ok := app.openAttachment(c, requestData.AttachmentId)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	buffer := make([]byte, utils.FILE_CHUNK_SIZE)
+	var offset int64 = 0
+	for {
+		n, err := io.ReadFull(file, buffer)
+		isLast := err == io.EOF || err == io.ErrUnexpectedEOF
+		chunk := models.FileDownloadChunk{
+			AttachmentId: requestData.AttachmentId,
+			Offset:       offset,
+			Data:         buffer[:n],
+			IsLast:       isLast}
+		if utils.IsError(err) && !isLast {
+			log.Println(err)
+			chunk = models.FileDownloadChunk{
+				AttachmentId: requestData.AttachmentId,
+				IsLast:       true,
+				Error:        "File is not available"}
+		}
+		c.Emit("/file-download", encrypt.EncryptWith(payloadCodec, secretKey, chunk))
+		if chunk.IsLast {
+			return
+		}
+		offset += int64(n)
+	}
+}
+
+func (app *ServerApp) openAttachment(c socket.Channel,
+	attachmentId int64) (*os.File, codec.Codec, uuid.UUID, bool) {
+	// file is read by chunks without lock, so other handlers don't wait for it
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return nil, nil, uuid.UUID{}, false
+	}
+	msg, path, err := app.DB.GetAttachment(attachmentId)
+	if !utils.IsError(err) && !app.canReadMessage(session.User, msg) {
+		err = errors.New("User " + session.User.Username + " can't read attachment")
+	}
+	var file *os.File
+	if !utils.IsError(err) {
+		file, err = os.Open(path)
+	}
+	if utils.IsError(err) {
+		log.Println(err)
+		chunk := models.FileDownloadChunk{
+			AttachmentId: attachmentId,
+			IsLast:       true,
+			Error:        "File is not available"}
+		c.Emit("/file-download", app.encryptFor(c.Id(), session.SecretKey, chunk))
+		return nil, nil, uuid.UUID{}, false
+	}
+	payloadCodec, ok := app.Codecs[c.Id()]
+	if !ok {
+		payloadCodec = codec.JSON
+	}
+	return file, payloadCodec, session.SecretKey, true
+}
+
+func getAvatarPath(userId int64) string {
+	return filepath.Join(AVATARS_DIR, fmt.Sprintf("%d.png", userId))
+}
+
+func checkAvatar(data []byte) error {
+	// client sends png image resized to AVATAR_SIZE
+	if len(data) > models.MAX_AVATAR_DATA_SIZE {
+		return errors.New("Avatar is too big.")
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if utils.IsError(err) || format != "png" ||
+		config.Width != models.AVATAR_SIZE || config.Height != models.AVATAR_SIZE {
+		return fmt.Errorf("Avatar must be png image of %dx%d pixels.",
+			models.AVATAR_SIZE, models.AVATAR_SIZE)
+	}
+	return nil
+}
+
+func (app *ServerApp) processAvatarUpload(c socket.Channel, encryptedUpload string) {
+	// saves avatar of user and tells all clients that it was changed
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	upload := models.AvatarUpload{}
+	encrypt.Decrypt(session.SecretKey, encryptedUpload, &upload)
+	if err := checkAvatar(upload.Data); utils.IsError(err) {
+		c.Emit("/error", models.NewError(models.PROCESS_SET_AVATAR, models.ERROR_INVALID_AVATAR,
+			err.Error()))
+		return
+	}
+	err := ioutil.WriteFile(getAvatarPath(session.User.Id), upload.Data, 0644)
+	if utils.IsError(err) {
+		log.Println(err)
+		c.Emit("/error", models.NewError(models.PROCESS_SET_AVATAR, models.ERROR_INTERNAL,
+			"Can't save avatar. Please, try again."))
+		return
+	}
+	hash := sha1.Sum(upload.Data)
+	avatar := models.Avatar{User: session.User, Hash: hex.EncodeToString(hash[:])}
+	app.DB.UpdateUserAvatarHash(session.User.Id, avatar.Hash)
+	app.EmitToAll("/avatar-updated", avatar) // clients request changed avatars they show
+}
+
+func (app *ServerApp) processAvatarRequest(c socket.Channel, request models.AvatarRequest) {
+	// sends avatar of user if client has no cached avatar with same hash
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	user, err := app.DB.GetUserById(int(request.UserId))
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	avatar := models.Avatar{User: user, Hash: app.DB.GetUserAvatarHash(user.Id)}
+	if avatar.Hash != "" && avatar.Hash != request.Hash {
+		avatar.Data, err = ioutil.ReadFile(getAvatarPath(user.Id))
+		if utils.IsError(err) {
+			log.Println(err)
+			return
+		}
+	}
+	c.Emit("/get-avatar", app.encryptFor(c.Id(), session.SecretKey, avatar))
+}
+
+func (app *ServerApp) processProfileUpdate(c socket.Channel, encryptedProfile string) {
+	// saves display name and status of user and sends them to all clients
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	profile := models.Profile{}
+	encrypt.Decrypt(session.SecretKey, encryptedProfile, &profile)
+	profile.User = session.User
+	if err := utils.ValidateProfile(profile.DisplayName, profile.StatusText); utils.IsError(err) {
+		c.Emit("/error", models.NewError(models.PROCESS_SET_PROFILE, models.ERROR_INVALID_PROFILE,
+			err.Error()))
+		return
+	}
+	app.DB.UpdateUserProfile(profile)
+	app.EmitToAll("/profile-updated", profile)
+}
+
+func (app *ServerApp) processProfileRequest(c socket.Channel,
+	request models.ProfileRequest) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	profile, err := app.DB.GetUserProfile(request.UserId)
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	c.Emit("/get-profile", app.encryptFor(c.Id(), session.SecretKey, profile))
+}
+
+func (app *ServerApp) processUserBlocking(c socket.Channel, encryptedFilter string) {
+	// saves blocked user and sends new list to all clients of user.
+	// Messages of blocked users are still delivered, clients hide them
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	filter := models.UserFilter{}
+	encrypt.Decrypt(session.SecretKey, encryptedFilter, &filter)
+	if filter.User.Id == session.User.Id {
+		return
+	}
+	if _, err := app.DB.GetUserById(int(filter.User.Id)); utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	app.DB.UpdateBlockedUser(session.User.Id, filter.User.Id, filter.Blocked)
+	blockedUsers := models.BlockedUsersPack{Users: app.DB.GetBlockedUsers(session.User.Id)}
+	app.EmitToUser(session.User.Id, "/blocked-users", blockedUsers)
+}
+
+func (app *ServerApp) processContactAdding(c socket.Channel, encryptedChange string) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	app.updateContact(c, encryptedChange, true)
+}
+
+func (app *ServerApp) processContactRemoval(c socket.Channel, encryptedChange string) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	app.updateContact(c, encryptedChange, false)
+}
+
+func (app *ServerApp) updateContact(c socket.Channel, encryptedChange string, isContact bool) {
+	// saves contact and sends new list to all clients of user
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	change := models.ContactChange{}
+	encrypt.Decrypt(session.SecretKey, encryptedChange, &change)
+	if change.User.Id == session.User.Id {
+		return
+	}
+	if _, err := app.DB.GetUserById(int(change.User.Id)); utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	app.DB.UpdateContact(session.User.Id, change.User.Id, isContact)
+	contacts := models.ContactsPack{Contacts: app.DB.GetContacts(session.User.Id)}
+	app.EmitToUser(session.User.Id, "/get-contacts", contacts)
+}
+
+func (app *ServerApp) processContactsRequest(c socket.Channel,
+	requestData models.ContactsRequest) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	contacts := models.ContactsPack{Contacts: app.DB.GetContacts(session.User.Id)}
+	c.Emit("/get-contacts", app.encryptFor(c.Id(), session.SecretKey, contacts))
+}
+
+func (app *ServerApp) processUsersSearch(c socket.Channel,
+	requestData models.UsersSearchRequest) {
+	// sends users found by username or display name. Current user isn't found
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok || requestData.Query == "" {
+		return
+	}
+	limit := requestData.Limit
+	if limit <= 0 || limit > MAX_SEARCH_RESULTS {
+		limit = MAX_SEARCH_RESULTS
+	}
+	result := models.UsersSearchResult{Query: requestData.Query, Users: []models.User{}}
+	for _, user := range app.DB.SearchUsers(requestData.Query, limit+1) {
+		if user.Id != session.User.Id && len(result.Users) < limit {
+			result.Users = append(result.Users, user)
+		}
+	}
+	c.Emit("/search-users", app.encryptFor(c.Id(), session.SecretKey, result))
+}
+
+func (app *ServerApp) processTyping(c socket.Channel, encryptedTyping string) {
+	// resends typing event to chat recipients. Typing user is user of session
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	typing := models.Typing{}
+	encrypt.Decrypt(session.SecretKey, encryptedTyping, &typing)
+	typing.User = session.User
+
+	if typing.GetChatType() == "group" {
+		if app.isChatMember(typing.User, typing.ChatId) {
+			app.emitToGroup(typing.ChatId, "/typing", typing)
+		}
+	} else if typing.ChatId != typing.User.Id { // nobody to notify in notes
+		app.EmitToUser(typing.ChatId, "/typing", typing)
+	}
+}
+
+func (app *ServerApp) processHello(c socket.Channel, clientHello models.Hello) {
+	// tells client protocol version and features which server supports
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	log.Printf("Client %s uses protocol version %d\n", c.Id(), clientHello.Version)
+	app.Transport.SetCompression(c.RequestHeader(),
+		clientHello.HasFeature(models.FEATURE_COMPRESSION))
+	if clientHello.HasFeature(models.FEATURE_MSGPACK) {
+		app.Codecs[c.Id()] = codec.MessagePack
+	}
+	var providers []models.OAuthProvider
+	for _, provider := range app.OAuthProviders {
+		providers = append(providers, provider.OAuthProvider)
+	}
+	c.Emit("/hello", models.Hello{Version: models.PROTOCOL_VERSION,
+		Features: models.GetAllFeatures(), OAuthProviders: providers})
+}
+
+func (app *ServerApp) processPing(c socket.Channel, ping models.Ping) {
+	// answers client checking that connection is alive
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	c.Emit("/pong", ping)
+}
+
+func (app *ServerApp) processPresence(c socket.Channel, encryptedPresence string) {
+	// saves presence reported by client and notifies all users
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	presence := models.Presence{}
+	encrypt.Decrypt(session.SecretKey, encryptedPresence, &presence)
+	switch presence.State {
+	case models.PRESENCE_ACTIVE, models.PRESENCE_IDLE, models.PRESENCE_OFFLINE,
+		models.PRESENCE_BUSY:
+		session.Presence = presence.State
+	default:
+		return
+	}
+	app.Sessions[c.Id()] = session
+	app.broadcastPresence(session.User)
+}
+
+func (app *ServerApp) processMessageRead(c socket.Channel, encryptedRead string) {
+	// marks messages of private chat partner as read
+	// and notifies him. ChatId is id of partner
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	messageRead := models.MessageRead{}
+	encrypt.Decrypt(session.SecretKey, encryptedRead, &messageRead)
+	if messageRead.ChatId <= 0 || messageRead.ChatId == session.User.Id {
+		return // read receipts are only for private chats
+	}
+
+	app.DB.UpdateMessagesStatus(messageRead.ChatId, session.User.Id,
+		messageRead.LastMessageId, models.MESSAGE_STATE_READ)
+	statusUpdate := models.MessageStatusUpdate{ChatId: session.User.Id,
+		LastMessageId: messageRead.LastMessageId, Status: models.MESSAGE_STATE_READ}
+	app.EmitToUser(messageRead.ChatId, "/message-status", statusUpdate)
+}
+
+func (app *ServerApp) getUserPresence(userId int64) string {
+	// user is busy if any of his clients is in do not disturb mode,
+	// otherwise active if any client is active
+	result := models.PRESENCE_OFFLINE
+	for _, session := range app.Sessions {
+		if session.User.Id != userId {
+			continue
+		}
+		if session.Presence == models.PRESENCE_BUSY {
+			return models.PRESENCE_BUSY
+		}
+		if session.Presence == models.PRESENCE_ACTIVE {
+			result = models.PRESENCE_ACTIVE
+		}
+		if session.Presence == models.PRESENCE_IDLE && result != models.PRESENCE_ACTIVE {
+			result = models.PRESENCE_IDLE
+		}
+	}
+	return result
+}
+
+func (app *ServerApp) broadcastPresence(user models.User) {
+	presence := models.Presence{User: user, State: app.getUserPresence(user.Id)}
+	app.EmitToAll("/presence", presence)
+}
+
+func (app *ServerApp) processMessagesRequest(c socket.Channel,
+	requestData models.MessagesRequest) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	secretKey := session.SecretKey
+	user := session.User // user of request can be anyone
+	chatId := requestData.ChatId
+	limit := requestData.Limit
+	if limit <= 0 || limit > MAX_MESSAGES_PAGE_SIZE {
+		limit = MAX_MESSAGES_PAGE_SIZE
+	}
+	if !app.isChatMember(user, chatId) {
+		return
+	}
+	var messages []models.SavedMessage
+	if requestData.AroundId > 0 {
+		messages = app.DB.GetMessagesAround(user.Id, chatId, requestData.AroundId, limit)
+	} else {
+		messages = app.DB.GetMessagesFromChat(user.Id, chatId, requestData.BeforeId, limit)
+	}
+	pack := models.SavedMessagesPack{
+		Messages: messages,
+		ChatId:   chatId,
+		BeforeId: requestData.BeforeId,
+		AroundId: requestData.AroundId}
+	c.Emit("/get-messages", app.encryptFor(c.Id(), secretKey, pack))
+}
+
+func (app *ServerApp) processMessagesSearch(c socket.Channel,
+	requestData models.MessagesSearchRequest) {
+	// sends messages available for user which contain query
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok || requestData.Query == "" {
+		return
+	}
+	limit := requestData.Limit
+	if limit <= 0 || limit > MAX_SEARCH_RESULTS {
+		limit = MAX_SEARCH_RESULTS
+	}
+	result := models.MessagesSearchResult{
+		Query:    requestData.Query,
+		Messages: app.DB.SearchMessages(session.User.Id, requestData.Query, limit)}
+	c.Emit("/search-messages", app.encryptFor(c.Id(), session.SecretKey, result))
+}
+
+func (app *ServerApp) processPinnedMessagesRequest(c socket.Channel,
+	requestData models.PinnedMessagesRequest) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok || !app.isChatMember(session.User, requestData.ChatId) {
+		return
+	}
+	pack := models.PinnedMessagesPack{
+		ChatId:   requestData.ChatId,
+		Messages: app.DB.GetPinnedMessages(session.User.Id, requestData.ChatId)}
+	c.Emit("/get-pinned", app.encryptFor(c.Id(), session.SecretKey, pack))
+}
+
+func (app *ServerApp) processChannelsRequest(c socket.Channel,
+	requestData models.ChannelsRequest) {
+	// channels of user of session, user of request isn't trusted
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	pack := models.ChannelsPack{Channels: app.DB.GetChannels(session.User.Id)}
+	c.Emit("/get-channels", app.encryptFor(c.Id(), session.SecretKey, pack))
+}
+
+func (app *ServerApp) processChannelCreation(c socket.Channel,
+	encryptedCreation string) {
+	// creates group channel and sends it to all invited members
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	creation := models.ChannelCreation{}
+	encrypt.Decrypt(session.SecretKey, encryptedCreation, &creation)
+
+	title := creation.Title
+	if channelError, ok := app.checkChannelTitle(models.PROCESS_CREATE_CHANNEL, title); !ok {
+		c.Emit("/error", channelError)
+		return
+	}
+
+	var memberIds []int64
+	for _, memberId := range creation.MemberIds {
+		_, err := app.DB.GetUserById(int(memberId))
+		if !utils.IsError(err) {
+			memberIds = append(memberIds, memberId)
+		}
+	}
+	channel := app.DB.AddNewGroup(title, session.User.Id, memberIds)
+	log.Println("New channel " + title)
+	app.emitToGroup(channel.Id, "/channel-created", channel)
+}
+
+func (app *ServerApp) checkChannelTitle(process string, title string) (models.Error, bool) {
+	// title of group is unique among groups and usernames
+	if title == "" || !isValid(title) {
+		return models.NewError(process, models.ERROR_INVALID_CHANNEL_NAME,
+			"Channel name "+title+" is not valid.", title), false
+	} else if app.DB.IsGroupExist(title) || app.DB.IsUserExist(title) {
+		return models.NewError(process, models.ERROR_CHANNEL_NAME_USED,
+			"Channel name "+title+" is already used.", title), false
+	}
+	return models.Error{}, true
+}
+
+func (app *ServerApp) processChannelMembersRequest(c socket.Channel,
+	requestData models.ChannelMembersRequest) {
+	// sends members of group channel with online status
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok || !app.isChatMember(session.User, requestData.ChatId) {
+		return
+	}
+	pack := app.getChannelMembers(requestData.ChatId)
+	c.Emit("/get-channel-members", app.encryptFor(c.Id(), session.SecretKey, pack))
+}
+
+func (app *ServerApp) getChannelMembers(chatId int64) models.ChannelMembersPack {
+	// members of created groups have roles, main channel has no moderators
+	pack := models.ChannelMembersPack{ChatId: chatId}
+	roles := make(map[int64]string)
+	if chatId != utils.GROUP_CHAT_ID {
+		roles = app.DB.GetGroupMemberRoles(chatId)
+	}
+	for _, user := range app.DB.GetGroupMembers(chatId) {
+		member := models.ChannelMember{User: user, Online: app.isOnline(user.Id),
+			Role: roles[user.Id]}
+		pack.Members = append(pack.Members, member)
+	}
+	return pack
+}
+
+func (app *ServerApp) getMemberRole(chatId int64, userId int64) string {
+	// returns empty role if user isn't member of created group
+	if chatId >= 0 {
+		return ""
+	}
+	return app.DB.GetGroupMemberRoles(chatId)[userId]
+}
+
+func (app *ServerApp) processMemberKick(c socket.Channel, encryptedKick string) {
+	// owner can remove anybody, admin only plain members.
+	// Removed user loses group from channels list
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	kick := models.MemberKick{}
+	encrypt.Decrypt(session.SecretKey, encryptedKick, &kick)
+
+	role := app.getMemberRole(kick.ChatId, session.User.Id)
+	kickedRole := app.getMemberRole(kick.ChatId, kick.User.Id)
+	canKick := kickedRole == models.ROLE_MEMBER && models.IsModeratorRole(role) ||
+		kickedRole == models.ROLE_ADMIN && role == models.ROLE_OWNER
+	if !canKick {
+		log.Println("User " + session.User.Username + " can't remove member")
+		return
+	}
+	app.DB.RemoveGroupMember(kick.ChatId, kick.User.Id)
+	channel, err := app.DB.GetGroupById(kick.ChatId)
+	if !utils.IsError(err) {
+		app.EmitToUser(kick.User.Id, "/channel-removed", channel)
+	}
+	app.emitToGroup(kick.ChatId, "/get-channel-members", app.getChannelMembers(kick.ChatId))
+}
+
+func (app *ServerApp) processMemberRoleChange(c socket.Channel, encryptedChange string) {
+	// only owner assigns admins. Owner's own role can't be changed
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	change := models.MemberRoleChange{}
+	encrypt.Decrypt(session.SecretKey, encryptedChange, &change)
+
+	isRoleValid := change.Role == models.ROLE_ADMIN || change.Role == models.ROLE_MEMBER
+	changedRole := app.getMemberRole(change.ChatId, change.User.Id)
+	if app.getMemberRole(change.ChatId, session.User.Id) != models.ROLE_OWNER ||
+		!isRoleValid || changedRole == "" || changedRole == models.ROLE_OWNER {
+		log.Println("User " + session.User.Username + " can't change role")
+		return
+	}
+	app.DB.SetGroupMemberRole(change.ChatId, change.User.Id, change.Role)
+	app.emitToGroup(change.ChatId, "/get-channel-members", app.getChannelMembers(change.ChatId))
+}
+
+func (app *ServerApp) processChannelRenaming(c socket.Channel, encryptedRenaming string) {
+	// owner and admins rename group. New title is sent to all members
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	renaming := models.ChannelRenaming{}
+	encrypt.Decrypt(session.SecretKey, encryptedRenaming, &renaming)
+
+	title := renaming.Title
+	process := models.PROCESS_RENAME_CHANNEL
+	if !models.IsModeratorRole(app.getMemberRole(renaming.ChatId, session.User.Id)) {
+		c.Emit("/error", models.NewError(process, models.ERROR_PERMISSION_DENIED,
+			"Only owner and admins can rename group."))
+		return
+	}
+	if channelError, ok := app.checkChannelTitle(process, title); !ok {
+		c.Emit("/error", channelError)
+		return
+	}
+	app.DB.RenameGroup(renaming.ChatId, title)
+	log.Println("Channel renamed to " + title)
+	channel, err := app.DB.GetGroupById(renaming.ChatId)
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	app.emitToGroup(renaming.ChatId, "/channel-renamed", channel)
+}
+
+func (app *ServerApp) processChannelInvitation(c socket.Channel,
+	encryptedInvitation string) {
+	// any member of group can invite user. Invited user answers in inbox
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	invitation := models.ChannelInvitation{}
+	encrypt.Decrypt(session.SecretKey, encryptedInvitation, &invitation)
+
+	process := models.PROCESS_INVITE_TO_CHANNEL
+	channelError := models.Error{}
+	user, err := app.DB.GetUserById(int(invitation.User.Id))
+	if invitation.ChatId >= 0 || !app.DB.IsGroupMember(invitation.ChatId, session.User.Id) {
+		channelError = models.NewError(process, models.ERROR_PERMISSION_DENIED,
+			"Users can be invited only to created groups.")
+	} else if utils.IsError(err) {
+		channelError = models.NewError(process, models.ERROR_USER_NOT_FOUND,
+			"User doesn't exist.")
+	} else if app.DB.IsGroupMember(invitation.ChatId, user.Id) {
+		channelError = models.NewError(process, models.ERROR_ALREADY_MEMBER,
+			"User "+user.Username+" is already member.", user.Username)
+	} else if app.DB.IsInvitationExist(invitation.ChatId, user.Id) {
+		channelError = models.NewError(process, models.ERROR_ALREADY_INVITED,
+			"User "+user.Username+" is already invited.", user.Username)
+	}
+	if channelError.Code != "" {
+		c.Emit("/error", channelError)
+		return
+	}
+	app.DB.AddInvitation(models.INVITATION_INVITE, invitation.ChatId, user.Id, session.User.Id)
+	app.emitInvitations(user.Id)
+}
+
+func (app *ServerApp) processJoinRequest(c socket.Channel, encryptedRequest string) {
+	// join request is answered by owner of group
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	request := models.JoinRequest{}
+	encrypt.Decrypt(session.SecretKey, encryptedRequest, &request)
+
+	process := models.PROCESS_JOIN_REQUEST
+	channelError := models.Error{}
+	channel, err := app.DB.GetGroupByTitle(request.Title)
+	if utils.IsError(err) {
+		channelError = models.NewError(process, models.ERROR_CHANNEL_NOT_FOUND,
+			"Channel "+request.Title+" doesn't exist.", request.Title)
+	} else if app.DB.IsGroupMember(channel.Id, session.User.Id) {
+		channelError = models.NewError(process, models.ERROR_ALREADY_MEMBER,
+			"You are already member of "+request.Title+".", request.Title)
+	} else if app.DB.IsInvitationExist(channel.Id, session.User.Id) {
+		channelError = models.NewError(process, models.ERROR_ALREADY_REQUESTED,
+			"Request to "+request.Title+" is already sent.", request.Title)
+	}
+	if channelError.Code != "" {
+		c.Emit("/error", channelError)
+		return
+	}
+	app.DB.AddInvitation(models.INVITATION_JOIN, channel.Id, session.User.Id, session.User.Id)
+	app.emitInvitations(channel.OwnerId)
+}
+
+func (app *ServerApp) processInvitationsRequest(c socket.Channel,
+	requestData models.InvitationsRequest) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	pack := models.InvitationsPack{Invitations: app.DB.GetInvitations(session.User.Id)}
+	c.Emit("/get-invitations", app.encryptFor(c.Id(), session.SecretKey, pack))
+}
+
+func (app *ServerApp) processInvitationAnswer(c socket.Channel, encryptedAnswer string) {
+	// accepted user becomes member and gets group in channels list
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	session, ok := app.Sessions[c.Id()]
+	if !ok {
+		return
+	}
+	answer := models.InvitationAnswer{}
+	encrypt.Decrypt(session.SecretKey, encryptedAnswer, &answer)
+	invitation, err := app.DB.GetInvitation(answer.Id)
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	canAnswer := invitation.User.Id == session.User.Id
+	if invitation.Kind == models.INVITATION_JOIN {
+		canAnswer = invitation.Channel.OwnerId == session.User.Id
+	}
+	if !canAnswer {
+		return
+	}
+	app.DB.DeleteInvitation(invitation.Id)
+	if answer.Accepted {
+		app.DB.AddGroupMember(invitation.Channel.Id, invitation.User.Id)
+		log.Println(invitation.User.Username + " joined channel " + invitation.Channel.Title)
+		app.EmitToUser(invitation.User.Id, "/channel-created", invitation.Channel)
+	}
+	app.emitInvitations(session.User.Id)
+}
+
+func (app *ServerApp) emitInvitations(userId int64) {
+	// sends invitations and join requests to all clients of user
+	pack := models.InvitationsPack{Invitations: app.DB.GetInvitations(userId)}
+	app.EmitToUser(userId, "/get-invitations", pack)
+}
+
+func (app *ServerApp) isOnline(userId int64) bool {
+	for _, session := range app.Sessions {
+		if session.User.Id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+func (app *ServerApp) PrintSessions() {
+	jsonSessions, err := json.MarshalIndent(app.Sessions, "", "    ")
+	if utils.IsError(err) {
+		log.Println(err)
+	}
+	fmt.Printf("Connected Users %d:\n", len(app.Sessions))
+	fmt.Println(string(jsonSessions))
+}
+
+func (app *ServerApp) trySaveNewChannel(msg models.Message) bool {
+	// return true if new channel was saved
+	db := app.DB
+	result := false
+	if !db.IsChannelExist(msg.User.Id, msg.ChatId) {
+		db.AddNewChannel(msg.User.Id, msg.ChatId)
+		result = true
+	}
+	// saves double row in order to receiver will too has ability
+	// to check new messages in this channel
+	if !db.IsChannelExist(msg.ChatId, msg.User.Id) {
+		db.AddNewChannel(msg.ChatId, msg.User.Id)
+		result = true
+	}
+	return result
+}
+
+func (app *ServerApp) createSession(socketId string, user models.User) models.Session {
+	newSession := models.Session{
+		User:      user,
+		SecretKey: uuid.NewV4(),
+		Presence:  models.PRESENCE_ACTIVE,
+		Acks:      make(map[int64]models.MessageAck)}
+
+	app.Sessions[socketId] = newSession
+	return newSession
+}
+
+func (app *ServerApp) removeSession(socketId string) {
+	delete(app.Sessions, socketId)
+}
+
+func (app *ServerApp) encryptFor(socketId string, key uuid.UUID, data interface{}) string {
+	// encodes data by MessagePack if client supports it
+	payloadCodec, ok := app.Codecs[socketId]
+	if !ok {
+		payloadCodec = codec.JSON
+	}
+	return encrypt.EncryptWith(payloadCodec, key, data)
+}
+
+func (app *ServerApp) EmitToUser(userId int64, method string, data interface{}) {
+	// emit if user is online. To all connected clients with this account.
+	// data will be encrypted.
+	for socketId, session := range app.Sessions {
+		if session.User.Id == userId {
+			channel, err := app.Server.GetChannel(socketId)
+			if !utils.IsError(err) {
+				channel.Emit(method, app.encryptFor(socketId, session.SecretKey, data))
+			} else {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+func (app *ServerApp) emitToChatMembers(method string, msg models.SavedMessage) {
+	// emit to all clients which can display message.
+	// data will be encrypted.
+	if msg.GetChatType() == "group" {
+		app.emitToGroup(msg.ChatId, method, msg)
+		return
+	}
+	app.EmitToUser(msg.User.Id, method, msg)
+	if msg.ChatId != msg.User.Id { // not notes
+		app.EmitToUser(msg.ChatId, method, msg)
+	}
+}
+
+func (app *ServerApp) EmitToAll(method string, data interface{}) {
+	// emit to all online clients.
+	// data will be encrypted.
+	for socketId, session := range app.Sessions {
+		channel, err := app.Server.GetChannel(socketId)
+		if !utils.IsError(err) {
+			channel.Emit(method, app.encryptFor(socketId, session.SecretKey, data))
+		} else {
+			log.Println(err)
+		}
+	}
+}
+
+func (app *ServerApp) emitToGroup(chatId int64, method string, data interface{}) {
+	// emit to online members of main or created group.
+	// data will be encrypted.
+	if chatId == utils.GROUP_CHAT_ID {
+		app.EmitToAll(method, data)
+		return
+	}
+	for _, userId := range app.DB.GetGroupMemberIds(chatId) {
+		app.EmitToUser(userId, method, data)
+	}
+}
+
+func (app *ServerApp) isChatMember(user models.User, chatId int64) bool {
+	// everybody is member of main channel and private chats.
+	// Created groups are available only for invited users
+	if chatId >= 0 {
+		return true
+	}
+	return app.DB.IsGroupMember(chatId, user.Id)
+}
+
+func (app *ServerApp) canReadMessage(user models.User, msg models.Message) bool {
+	// returns true if user is member of group or sender or recipient of message
+	if msg.GetChatType() == "group" {
+		return app.isChatMember(user, msg.ChatId)
+	}
+	return msg.User.Id == user.Id || msg.ChatId == user.Id
+}
+
+func isValid(username string) bool {
+	// username must not have spaces and be less than 20
+	if len(username) > 20 {
+		return false
+	}
+	for _, c := range username {
+		if c == ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+func main() {
+	serverApp := ServerApp{}
+	serverApp.Init()
+
+	defer serverApp.CloseDB()
+
+	serverApp.Run(utils.GetHostSettingsFromFile())
+}
@@ -0,0 +1,76 @@
+// main.go
+package main
+
+// example bot: repeats messages written to it. Without -channel it
+// answers personal messages and mentions in all channels
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"chat/chatclient"
+	"chat/models"
+	"chat/utils"
+)
+
+const RECONNECT_DELAY = 10 * time.Second
+
+func echo(bot *chatclient.Bot, msg models.SavedMessage) {
+	if err := bot.Reply(msg, msg.Text); utils.IsError(err) {
+		log.Println("Can't answer: " + err.Error())
+	}
+}
+
+func echoMentions(bot *chatclient.Bot, msg models.SavedMessage) {
+	// in group channels bot answers only messages addressed to it
+	if msg.GetChatType() == "group" &&
+		!strings.Contains(strings.ToLower(msg.Text), "@"+strings.ToLower(bot.User.Username)) {
+		return
+	}
+	echo(bot, msg)
+}
+
+func main() {
+	profileName := flag.String("profile", "", "server profile (active profile by default)")
+	username := flag.String("user", "", "account of bot")
+	password := flag.String("password", "",
+		"password (CHAT_PASSWORD environment variable is used by default)")
+	channelId := flag.Int64("channel", 0, "id of channel which bot repeats entirely")
+	flag.Parse()
+
+	settings := utils.GetSettingsFromFile()
+	if *profileName != "" {
+		if err := settings.SelectProfile(*profileName); utils.IsError(err) {
+			log.Fatal(err)
+		}
+	}
+	if *password == "" {
+		*password = os.Getenv("CHAT_PASSWORD")
+	}
+	if *username == "" || *password == "" {
+		fmt.Fprintln(os.Stderr, "Username and password are required.")
+		os.Exit(2)
+	}
+
+	bot := chatclient.NewBot(chatclient.GetAuthRequest(*username, *password))
+	bot.OnLogin(func(bot *chatclient.Bot) {
+		log.Println("Logged in as " + bot.User.Username)
+	})
+	if *channelId != 0 {
+		bot.Subscribe(*channelId, echo)
+	} else {
+		bot.OnMessage(echoMentions)
+	}
+	for {
+		err := bot.Run(settings.HostData)
+		if err != chatclient.ErrNotConnected {
+			log.Fatal(err)
+		}
+		log.Printf("Not connected, next attempt in %s", RECONNECT_DELAY)
+		time.Sleep(RECONNECT_DELAY)
+	}
+}
@@ -0,0 +1,198 @@
+// main.go
+package main
+
+// relays messages between channel of chat and Matrix room. By default
+// messages of chat are written by bridge account as "<username> text".
+// With -puppet token of application service is used, and each chat user
+// writes by own Matrix user @<prefix><username>
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"chat/chatclient"
+	"chat/models"
+	"chat/network"
+	"chat/utils"
+)
+
+const RECONNECT_DELAY = 10 * time.Second
+const SYNC_MIN_DELAY = time.Second
+const SYNC_MAX_DELAY = 5 * time.Minute
+
+type MatrixBridge struct {
+	Bot       *chatclient.Bot
+	Matrix    *MatrixClient
+	ChannelId int64            // bridged channel of chat
+	RoomId    string           // bridged Matrix room
+	Puppet    bool             // chat users write by users of application service
+	Prefix    string           // localpart prefix of puppets
+	puppets   map[int64]string // map: chat user id -> Matrix user ready to write
+}
+
+func (bridge *MatrixBridge) isPuppet(userId string) bool {
+	return strings.HasPrefix(userId, "@"+bridge.Prefix) &&
+		strings.HasSuffix(userId, ":"+bridge.Matrix.GetDomain())
+}
+
+func (bridge *MatrixBridge) getPuppet(user models.User) (string, error) {
+	// registers Matrix user for chat user and joins it to room once
+	if puppetId, ok := bridge.puppets[user.Id]; ok {
+		return puppetId, nil
+	}
+	localpart := bridge.Prefix + strings.ToLower(user.Username)
+	puppetId := "@" + localpart + ":" + bridge.Matrix.GetDomain()
+	if err := bridge.Matrix.RegisterUser(localpart); utils.IsError(err) {
+		return "", err
+	}
+	bridge.Matrix.SetDisplayName(puppetId, user.Username+" (chat)")
+	bridge.Matrix.Invite(bridge.RoomId, puppetId) // error if puppet is member already
+	if _, err := bridge.Matrix.JoinRoom(bridge.RoomId, puppetId); utils.IsError(err) {
+		return "", err
+	}
+	bridge.puppets[user.Id] = puppetId
+	return puppetId, nil
+}
+
+func getMessageText(msg models.SavedMessage) string {
+	text := msg.Text
+	if msg.HasAttachment() {
+		text = "[file] " + msg.Attachment.FileName
+	}
+	if msg.IsSticker() {
+		text = "[sticker] " + msg.Sticker
+	}
+	return text
+}
+
+func (bridge *MatrixBridge) relayToMatrix(bot *chatclient.Bot, msg models.SavedMessage) {
+	// puppet which can't be created is replaced by bridge account
+	text := getMessageText(msg)
+	if bridge.Puppet {
+		puppetId, err := bridge.getPuppet(msg.User)
+		if !utils.IsError(err) {
+			err = bridge.Matrix.SendText(bridge.RoomId, text, puppetId)
+		}
+		if !utils.IsError(err) {
+			return
+		}
+		log.Println("Can't write by puppet of " + msg.User.Username + ": " + err.Error())
+	}
+	err := bridge.Matrix.SendText(bridge.RoomId, "<"+msg.User.Username+"> "+text, "")
+	if utils.IsError(err) {
+		log.Println("Can't send to Matrix: " + err.Error())
+	}
+}
+
+func getMatrixName(userId string) string {
+	// localpart of @name:domain
+	return strings.SplitN(strings.TrimPrefix(userId, "@"), ":", 2)[0]
+}
+
+func (bridge *MatrixBridge) relayToChat(event MatrixEvent) {
+	// messages of bridge and puppets came from chat
+	if event.Sender == bridge.Matrix.UserId || bridge.isPuppet(event.Sender) {
+		return
+	}
+	text := "<" + getMatrixName(event.Sender) + "> " + event.Content.Body
+	if event.Content.MsgType == "m.emote" {
+		text = "* " + getMatrixName(event.Sender) + " " + event.Content.Body
+	}
+	if err := bridge.Bot.SendMessage(bridge.ChannelId, text); utils.IsError(err) {
+		log.Println("Can't send to chat: " + err.Error())
+	}
+}
+
+func (bridge *MatrixBridge) syncMatrix() {
+	// messages written before start aren't relayed
+	backoff := network.NewBackoff(SYNC_MIN_DELAY, SYNC_MAX_DELAY)
+	since := ""
+	for {
+		result, err := bridge.Matrix.Sync(bridge.RoomId, since)
+		if utils.IsError(err) {
+			delay := backoff.Next()
+			log.Printf("Can't sync with Matrix: %s, next attempt in %s", err, delay)
+			time.Sleep(delay)
+			continue
+		}
+		backoff.Reset()
+		if since != "" {
+			for _, event := range result.Rooms.Join[bridge.RoomId].Timeline.Events {
+				event := event
+				if event.Type == "m.room.message" {
+					bridge.Bot.Loop.Post(func() { bridge.relayToChat(event) })
+				}
+			}
+		}
+		since = result.NextBatch
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: matrix-bridge [options]")
+	fmt.Fprintln(os.Stderr, "Relays messages between channel of chat and Matrix room. Options:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	profileName := flag.String("profile", "", "server profile (active profile by default)")
+	username := flag.String("user", "", "chat account of bridge")
+	password := flag.String("password", "",
+		"password (CHAT_PASSWORD environment variable is used by default)")
+	channelId := flag.Int64("channel", utils.GROUP_CHAT_ID, "id of bridged chat channel")
+	homeserver := flag.String("homeserver", "", "url of Matrix homeserver, e.g. https://matrix.org")
+	token := flag.String("token", "",
+		"access token of Matrix account (MATRIX_TOKEN environment variable is used by default)")
+	room := flag.String("room", "", "id or alias of Matrix room")
+	puppet := flag.Bool("puppet", false, "token is of application service, chat users "+
+		"write by its users")
+	prefix := flag.String("puppet-prefix", "chat_", "localpart prefix of puppets")
+	flag.Usage = printUsage
+	flag.Parse()
+
+	settings := utils.GetSettingsFromFile()
+	if *profileName != "" {
+		if err := settings.SelectProfile(*profileName); utils.IsError(err) {
+			log.Fatal(err)
+		}
+	}
+	if *password == "" {
+		*password = os.Getenv("CHAT_PASSWORD")
+	}
+	if *token == "" {
+		*token = os.Getenv("MATRIX_TOKEN")
+	}
+	if *username == "" || *password == "" || *homeserver == "" || *token == "" || *room == "" {
+		fmt.Fprintln(os.Stderr, "Chat account, homeserver, token and room are required.")
+		os.Exit(2)
+	}
+
+	matrix := NewMatrixClient(*homeserver, *token)
+	if err := matrix.WhoAmI(); utils.IsError(err) {
+		log.Fatal(err)
+	}
+	roomId, err := matrix.JoinRoom(*room, "")
+	if utils.IsError(err) {
+		log.Fatal(err)
+	}
+	bridge := &MatrixBridge{Matrix: matrix, ChannelId: *channelId, RoomId: roomId,
+		Puppet: *puppet, Prefix: *prefix, puppets: make(map[int64]string)}
+	bridge.Bot = chatclient.NewBot(chatclient.GetAuthRequest(*username, *password))
+	bridge.Bot.Subscribe(bridge.ChannelId, bridge.relayToMatrix)
+	bridge.Bot.OnLogin(func(bot *chatclient.Bot) {
+		log.Printf("Relaying channel %d of %s to %s", bridge.ChannelId, bot.User.Username, roomId)
+	})
+	go bridge.syncMatrix()
+	for {
+		err := bridge.Bot.Run(settings.HostData)
+		if err != chatclient.ErrNotConnected {
+			log.Fatal(err)
+		}
+		log.Printf("Not connected, next attempt in %s", RECONNECT_DELAY)
+		time.Sleep(RECONNECT_DELAY)
+	}
+}
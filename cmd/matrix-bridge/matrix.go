@@ -0,0 +1,193 @@
+// matrix.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"chat/utils"
+)
+
+const MATRIX_API = "/_matrix/client/v3"
+const MATRIX_SYNC_TIMEOUT = 30 * time.Second
+const MATRIX_ERR_USER_IN_USE = "M_USER_IN_USE"
+
+// client of Matrix client-server API. Token of application service
+// can act as its users, they are passed by asUserId
+type MatrixClient struct {
+	HomeserverUrl string
+	AccessToken   string
+	UserId        string // account of token, e.g. @bridge:example.org
+	http          *http.Client
+	lastTxnId     int64
+}
+
+type MatrixError struct {
+	Code       string `json:"errcode"`
+	Message    string `json:"error"`
+	StatusCode int    `json:"-"`
+}
+
+func (err *MatrixError) Error() string {
+	return fmt.Sprintf("Matrix error %s (%d): %s", err.Code, err.StatusCode, err.Message)
+}
+
+type MatrixEvent struct {
+	Type    string `json:"type"`
+	Sender  string `json:"sender"`
+	EventId string `json:"event_id"`
+	Content struct {
+		MsgType string `json:"msgtype"`
+		Body    string `json:"body"`
+	} `json:"content"`
+}
+
+type MatrixSync struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []MatrixEvent `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+func NewMatrixClient(homeserverUrl string, accessToken string) *MatrixClient {
+	return &MatrixClient{HomeserverUrl: strings.TrimRight(homeserverUrl, "/"),
+		AccessToken: accessToken,
+		http:        &http.Client{Timeout: MATRIX_SYNC_TIMEOUT + 30*time.Second}}
+}
+
+func (matrix *MatrixClient) request(method string, path string, query url.Values,
+	body interface{}, result interface{}) error {
+	// sends json body and decodes json answer into result if it's not nil
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); utils.IsError(err) {
+			return err
+		}
+	}
+	requestUrl := matrix.HomeserverUrl + MATRIX_API + path
+	if len(query) > 0 {
+		requestUrl += "?" + query.Encode()
+	}
+	request, err := http.NewRequest(method, requestUrl, bytes.NewReader(data))
+	if utils.IsError(err) {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+matrix.AccessToken)
+	request.Header.Set("Content-Type", "application/json")
+	response, err := matrix.http.Do(request)
+	if utils.IsError(err) {
+		return err
+	}
+	defer response.Body.Close()
+	answer, err := ioutil.ReadAll(response.Body)
+	if utils.IsError(err) {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		matrixErr := &MatrixError{StatusCode: response.StatusCode}
+		json.Unmarshal(answer, matrixErr)
+		return matrixErr
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(answer, result)
+}
+
+func asUser(userId string) url.Values {
+	// empty user means account of token
+	query := url.Values{}
+	if userId != "" {
+		query.Set("user_id", userId)
+	}
+	return query
+}
+
+func (matrix *MatrixClient) WhoAmI() error {
+	var answer struct {
+		UserId string `json:"user_id"`
+	}
+	if err := matrix.request("GET", "/account/whoami", nil, nil, &answer); utils.IsError(err) {
+		return err
+	}
+	matrix.UserId = answer.UserId
+	return nil
+}
+
+func (matrix *MatrixClient) GetDomain() string {
+	// server name of account: @name:domain
+	if i := strings.Index(matrix.UserId, ":"); i >= 0 {
+		return matrix.UserId[i+1:]
+	}
+	return ""
+}
+
+func (matrix *MatrixClient) JoinRoom(roomIdOrAlias string, asUserId string) (string, error) {
+	// returns id of joined room
+	var answer struct {
+		RoomId string `json:"room_id"`
+	}
+	err := matrix.request("POST", "/join/"+url.PathEscape(roomIdOrAlias), asUser(asUserId),
+		struct{}{}, &answer)
+	return answer.RoomId, err
+}
+
+func (matrix *MatrixClient) Invite(roomId string, userId string) error {
+	return matrix.request("POST", "/rooms/"+url.PathEscape(roomId)+"/invite", nil,
+		map[string]string{"user_id": userId}, nil)
+}
+
+func (matrix *MatrixClient) RegisterUser(localpart string) error {
+	// registers user of application service. Existing user isn't an error
+	err := matrix.request("POST", "/register", nil, map[string]string{
+		"type": "m.login.application_service", "username": localpart}, nil)
+	if matrixErr, ok := err.(*MatrixError); ok && matrixErr.Code == MATRIX_ERR_USER_IN_USE {
+		return nil
+	}
+	return err
+}
+
+func (matrix *MatrixClient) SetDisplayName(userId string, displayName string) error {
+	return matrix.request("PUT", "/profile/"+url.PathEscape(userId)+"/displayname",
+		asUser(userId), map[string]string{"displayname": displayName}, nil)
+}
+
+func (matrix *MatrixClient) SendText(roomId string, text string, asUserId string) error {
+	// transaction id makes retried request idempotent
+	matrix.lastTxnId++
+	txnId := strconv.FormatInt(time.Now().UnixNano(), 36) + "." +
+		strconv.FormatInt(matrix.lastTxnId, 10)
+	return matrix.request("PUT", "/rooms/"+url.PathEscape(roomId)+"/send/m.room.message/"+txnId,
+		asUser(asUserId), map[string]string{"msgtype": "m.text", "body": text}, nil)
+}
+
+func (matrix *MatrixClient) Sync(roomId string, since string) (MatrixSync, error) {
+	// waits for new messages of room. Without since returns position
+	// after current messages at once
+	filter := fmt.Sprintf(`{"room":{"rooms":[%q],"timeline":{"types":["m.room.message"]}},`+
+		`"presence":{"types":[]},"account_data":{"types":[]}}`, roomId)
+	query := url.Values{"filter": {filter}}
+	if since != "" {
+		query.Set("since", since)
+		query.Set("timeout", strconv.FormatInt(MATRIX_SYNC_TIMEOUT.Milliseconds(), 10))
+	}
+	var result MatrixSync
+	err := matrix.request("GET", "/sync", query, nil, &result)
+	if err == nil && result.NextBatch == "" {
+		err = errors.New("Matrix server didn't return position of sync.")
+	}
+	return result, err
+}
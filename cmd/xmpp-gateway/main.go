@@ -0,0 +1,364 @@
+// main.go
+package main
+
+// gateway component of XMPP server which shows private chats of golang-chat
+// in Jabber clients. User of XMPP server sends "login <username> <password>"
+// to jid of component, then chat user "alice" is alice@<component domain>.
+// Session tokens of linked accounts are saved, so gateway keeps them after restart
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"chat/chatclient"
+	"chat/models"
+	"chat/network"
+	"chat/utils"
+)
+
+const ACCOUNTS_FILE = "xmpp-gateway.json"
+const RECONNECT_DELAY = 10 * time.Second
+const GATEWAY_HELP = "Commands: login <username> <password>, logout, help. " +
+	"Chat user <username> is <username>@%s."
+
+// chat account linked to jid of XMPP user
+type GatewayAccount struct {
+	Jid      string `json:"jid"` // bare jid
+	Username string `json:"username"`
+	Token    string `json:"token"` // session token for next login
+
+	password string                 // used once, until token is received
+	client   *chatclient.Client     // nil after logout
+	user     models.User            // logged in chat user
+	users    map[string]models.User // map: lowercase username -> known chat user
+	waiting  map[string][]string    // map: searched username -> texts to user
+}
+
+type XmppGateway struct {
+	Component *XmppComponent
+	Loop      *utils.EventLoop
+	HostData  utils.HostData
+	Accounts  map[string]*GatewayAccount // map: bare jid -> account
+}
+
+func (gateway *XmppGateway) loadAccounts() {
+	data, err := ioutil.ReadFile(ACCOUNTS_FILE)
+	if utils.IsError(err) {
+		return
+	}
+	var accounts []*GatewayAccount
+	if err := json.Unmarshal(data, &accounts); utils.IsError(err) {
+		log.Println("Can't read accounts: " + err.Error())
+		return
+	}
+	for _, account := range accounts {
+		gateway.Accounts[account.Jid] = account
+	}
+}
+
+func (gateway *XmppGateway) saveAccounts() {
+	// file has session tokens, so only owner can read it
+	var accounts []*GatewayAccount
+	for _, account := range gateway.Accounts {
+		if account.Token != "" {
+			accounts = append(accounts, account)
+		}
+	}
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(ACCOUNTS_FILE, data, 0600)
+	}
+	if utils.IsError(err) {
+		log.Println("Can't save accounts: " + err.Error())
+	}
+}
+
+func (gateway *XmppGateway) getUserJid(username string) string {
+	return strings.ToLower(username) + "@" + gateway.Component.Domain
+}
+
+func (gateway *XmppGateway) reply(jid string, text string) {
+	// answers of gateway come from jid of component
+	err := gateway.Component.SendMessage(gateway.Component.Domain, jid, text)
+	if utils.IsError(err) {
+		log.Println("Can't send to XMPP: " + err.Error())
+	}
+}
+
+func (gateway *XmppGateway) connectAccount(account *GatewayAccount) {
+	// logs in by token or password. Lost connection is opened again while
+	// account is linked
+	client := chatclient.NewClient()
+	client.SetDispatcher(gateway.Loop.Post)
+	account.client = client
+	account.users = make(map[string]models.User)
+	account.waiting = make(map[string][]string)
+	isCurrent := func() bool {
+		return gateway.Accounts[account.Jid] == account && account.client == client
+	}
+	client.SetOnConnection(func() {
+		if account.Token != "" {
+			client.LoginByToken(account.Token)
+		} else {
+			client.Login(chatclient.GetAuthRequest(account.Username, account.password))
+		}
+	})
+	client.SetOnLogin(func(authData models.SuccessfulAuth) {
+		isFirstLogin := account.password != ""
+		account.user, account.Token, account.password = authData.User, authData.SessionToken, ""
+		gateway.saveAccounts()
+		if isFirstLogin {
+			gateway.reply(account.Jid, "Logged in as "+authData.User.Username+". "+
+				fmt.Sprintf(GATEWAY_HELP, gateway.Component.Domain))
+		}
+	})
+	client.SetOnError(func(serverError models.Error) {
+		if !serverError.IsAuthError() {
+			gateway.reply(account.Jid, "Error: "+serverError.Description)
+			return
+		}
+		gateway.reply(account.Jid, "Can't log in: "+serverError.Description+
+			" Send login <username> <password> again.")
+		gateway.unlinkAccount(account)
+	})
+	client.SetOnTwoFactorRequired(func(models.TwoFactorRequired) {
+		gateway.reply(account.Jid, "Two-factor authentication can't be used by gateway.")
+		gateway.unlinkAccount(account)
+	})
+	client.SetOnDisconnection(func() {
+		if isCurrent() {
+			gateway.reconnectLater(account, client)
+		}
+	})
+	client.SetOnMessage(func(msg models.SavedMessage) {
+		gateway.processChatMessage(account, msg)
+	})
+	client.SetOnUsersFound(func(result models.UsersSearchResult) {
+		gateway.processUsersFound(account, result)
+	})
+	if err := client.Connect(network.ResolveHost(gateway.HostData)); utils.IsError(err) {
+		log.Println("Can't connect account " + account.Username + ": " + err.Error())
+		gateway.reconnectLater(account, client)
+	}
+}
+
+func (gateway *XmppGateway) reconnectLater(account *GatewayAccount, client *chatclient.Client) {
+	go func() {
+		time.Sleep(RECONNECT_DELAY)
+		gateway.Loop.Post(func() {
+			if gateway.Accounts[account.Jid] == account && account.client == client {
+				gateway.connectAccount(account)
+			}
+		})
+	}()
+}
+
+func (gateway *XmppGateway) unlinkAccount(account *GatewayAccount) {
+	if gateway.Accounts[account.Jid] == account {
+		delete(gateway.Accounts, account.Jid)
+	}
+	if account.client != nil {
+		account.client.Close()
+		account.client = nil
+	}
+	gateway.saveAccounts()
+}
+
+func (gateway *XmppGateway) processChatMessage(account *GatewayAccount, msg models.SavedMessage) {
+	// private messages of others come from jids of their authors. Messages
+	// sent from other clients of user aren't repeated
+	account.users[strings.ToLower(msg.User.Username)] = msg.User
+	if msg.GetChatType() != "private" || msg.User.Id == account.user.Id {
+		return
+	}
+	text := msg.Text
+	if msg.HasAttachment() {
+		text = "[file] " + msg.Attachment.FileName
+	}
+	if msg.IsSticker() {
+		text = "[sticker] " + msg.Sticker
+	}
+	err := gateway.Component.SendMessage(gateway.getUserJid(msg.User.Username), account.Jid, text)
+	if utils.IsError(err) {
+		log.Println("Can't send to XMPP: " + err.Error())
+	}
+}
+
+func (gateway *XmppGateway) processUsersFound(account *GatewayAccount,
+	result models.UsersSearchResult) {
+	query := strings.ToLower(result.Query)
+	texts := account.waiting[query]
+	delete(account.waiting, query)
+	for _, user := range result.Users {
+		if strings.ToLower(user.Username) == query {
+			account.users[query] = user
+		}
+	}
+	user, ok := account.users[query]
+	if !ok && len(texts) > 0 {
+		gateway.Component.SendMessage(gateway.getUserJid(query), account.Jid,
+			"User "+result.Query+" is not found.")
+		return
+	}
+	for _, text := range texts {
+		account.client.SendMessage(user.Id, text)
+	}
+}
+
+func (gateway *XmppGateway) processStanza(stanza interface{}) {
+	switch stanza := stanza.(type) {
+	case *XmppMessage:
+		if stanza.Type != "error" && strings.TrimSpace(stanza.Body) != "" {
+			gateway.processXmppMessage(stanza)
+		}
+	case *XmppPresence:
+		gateway.processPresence(stanza)
+	case *XmppIq:
+		gateway.processIq(stanza)
+	}
+}
+
+func (gateway *XmppGateway) processXmppMessage(msg *XmppMessage) {
+	// messages to jid of component are commands of gateway
+	jid := GetBareJid(msg.From)
+	username := GetJidNode(GetBareJid(msg.To))
+	if username == "" {
+		gateway.processCommand(jid, strings.TrimSpace(msg.Body))
+		return
+	}
+	account, ok := gateway.Accounts[jid]
+	if !ok || account.client == nil {
+		gateway.reply(jid, "Send login <username> <password> to "+gateway.Component.Domain+
+			" to write to chat users.")
+		return
+	}
+	if user, ok := account.users[username]; ok {
+		account.client.SendMessage(user.Id, msg.Body)
+		return
+	}
+	// user is searched on server, message waits for result
+	account.waiting[username] = append(account.waiting[username], msg.Body)
+	account.client.SearchUsers(username, 10)
+}
+
+func (gateway *XmppGateway) processCommand(jid string, command string) {
+	fields := strings.Fields(command)
+	switch {
+	case len(fields) == 3 && strings.ToLower(fields[0]) == "login":
+		if account, ok := gateway.Accounts[jid]; ok {
+			gateway.unlinkAccount(account)
+		}
+		account := &GatewayAccount{Jid: jid, Username: fields[1], password: fields[2]}
+		gateway.Accounts[jid] = account
+		gateway.connectAccount(account)
+	case len(fields) == 1 && strings.ToLower(fields[0]) == "logout":
+		account, ok := gateway.Accounts[jid]
+		if !ok {
+			gateway.reply(jid, "You are not logged in.")
+			return
+		}
+		if account.client != nil {
+			account.client.Logout(account.Token)
+		}
+		gateway.unlinkAccount(account)
+		gateway.reply(jid, "Logged out.")
+	default:
+		gateway.reply(jid, fmt.Sprintf(GATEWAY_HELP, gateway.Component.Domain))
+	}
+}
+
+func (gateway *XmppGateway) processPresence(presence *XmppPresence) {
+	// chat users can be added to roster, they are shown as available
+	from, to := GetBareJid(presence.To), presence.From
+	switch presence.Type {
+	case "subscribe":
+		gateway.Component.Send(XmppPresence{From: from, To: to, Type: "subscribed"})
+		gateway.Component.Send(XmppPresence{From: from, To: to})
+	case "probe", "":
+		gateway.Component.Send(XmppPresence{From: from, To: to})
+	}
+}
+
+func (gateway *XmppGateway) processIq(iq *XmppIq) {
+	// every iq request must be answered. Only service discovery is supported
+	if iq.Type != "get" && iq.Type != "set" {
+		return
+	}
+	if iq.Type == "get" && iq.Query.XMLName.Space == XMPP_DISCO_INFO_NS {
+		gateway.Component.SendRaw(fmt.Sprintf("<iq type='result' id='%s' from='%s' to='%s'>"+
+			"<query xmlns='%s'><identity category='gateway' type='golang-chat' "+
+			"name='golang-chat'/></query></iq>", xmlEscape(iq.Id), xmlEscape(iq.To),
+			xmlEscape(iq.From), XMPP_DISCO_INFO_NS))
+		return
+	}
+	gateway.Component.SendRaw(fmt.Sprintf("<iq type='error' id='%s' from='%s' to='%s'>"+
+		"<error type='cancel'><service-unavailable "+
+		"xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error></iq>",
+		xmlEscape(iq.Id), xmlEscape(iq.To), xmlEscape(iq.From)))
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: xmpp-gateway [options]")
+	fmt.Fprintln(os.Stderr, "Shows private chats in Jabber clients as external component "+
+		"of XMPP server. Options:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	profileName := flag.String("profile", "", "server profile (active profile by default)")
+	address := flag.String("xmpp", "localhost:5347", "address of XMPP server for components")
+	domain := flag.String("domain", "", "domain of component, e.g. chat.example.org")
+	secret := flag.String("secret", "",
+		"secret of component (XMPP_SECRET environment variable is used by default)")
+	flag.Usage = printUsage
+	flag.Parse()
+
+	settings := utils.GetSettingsFromFile()
+	if *profileName != "" {
+		if err := settings.SelectProfile(*profileName); utils.IsError(err) {
+			log.Fatal(err)
+		}
+	}
+	if *secret == "" {
+		*secret = os.Getenv("XMPP_SECRET")
+	}
+	if *domain == "" || *secret == "" {
+		fmt.Fprintln(os.Stderr, "Domain and secret of component are required.")
+		os.Exit(2)
+	}
+
+	gateway := &XmppGateway{Loop: utils.NewEventLoop(), HostData: settings.HostData,
+		Accounts: make(map[string]*GatewayAccount)}
+	gateway.loadAccounts()
+	go gateway.Loop.Run()
+	for {
+		component, err := DialXmppComponent(*address, strings.ToLower(*domain), *secret)
+		if utils.IsError(err) {
+			log.Printf("Can't connect to XMPP server (%s), next attempt in %s", err,
+				RECONNECT_DELAY)
+			time.Sleep(RECONNECT_DELAY)
+			continue
+		}
+		log.Println("Connected to XMPP server as " + component.Domain)
+		gateway.Loop.Invoke(func() {
+			gateway.Component = component
+			for _, account := range gateway.Accounts {
+				if account.client == nil {
+					gateway.connectAccount(account)
+				}
+			}
+		})
+		err = component.ReadStanzas(func(stanza interface{}) {
+			gateway.Loop.Post(func() { gateway.processStanza(stanza) })
+		})
+		component.Close()
+		log.Printf("XMPP connection was lost (%s), next attempt in %s", err, RECONNECT_DELAY)
+		time.Sleep(RECONNECT_DELAY)
+	}
+}
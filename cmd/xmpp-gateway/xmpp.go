@@ -0,0 +1,192 @@
+// xmpp.go
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"chat/utils"
+)
+
+const XMPP_DIAL_TIMEOUT = 15 * time.Second
+const XMPP_COMPONENT_NS = "jabber:component:accept"
+const XMPP_STREAM_NS = "http://etherx.jabber.org/streams"
+const XMPP_DISCO_INFO_NS = "http://jabber.org/protocol/disco#info"
+
+type XmppMessage struct {
+	XMLName xml.Name `xml:"message"`
+	From    string   `xml:"from,attr"`
+	To      string   `xml:"to,attr"`
+	Type    string   `xml:"type,attr,omitempty"` // chat, error or empty for normal message
+	Body    string   `xml:"body,omitempty"`
+}
+
+type XmppPresence struct {
+	XMLName xml.Name `xml:"presence"`
+	From    string   `xml:"from,attr"`
+	To      string   `xml:"to,attr"`
+	Type    string   `xml:"type,attr,omitempty"` // subscribe, probe, unavailable...
+}
+
+type XmppIq struct {
+	XMLName xml.Name `xml:"iq"`
+	From    string   `xml:"from,attr"`
+	To      string   `xml:"to,attr"`
+	Id      string   `xml:"id,attr"`
+	Type    string   `xml:"type,attr"`
+	Query   struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+func GetBareJid(jid string) string {
+	// jid without resource: user@domain/resource -> user@domain
+	return strings.ToLower(strings.SplitN(jid, "/", 2)[0])
+}
+
+func GetJidNode(jid string) string {
+	// user of user@domain, empty for jid of domain
+	if i := strings.Index(jid, "@"); i >= 0 {
+		return jid[:i]
+	}
+	return ""
+}
+
+// connection of external component (XEP-0114) to XMPP server. Server
+// routes to component all stanzas addressed to its domain and its users
+type XmppComponent struct {
+	Domain  string
+	conn    net.Conn
+	decoder *xml.Decoder
+	mutex   sync.Mutex // stanzas of goroutines aren't mixed
+}
+
+func DialXmppComponent(address string, domain string, secret string) (*XmppComponent, error) {
+	// opens stream and authenticates by sha1 of stream id and shared secret
+	conn, err := net.DialTimeout("tcp", address, XMPP_DIAL_TIMEOUT)
+	if utils.IsError(err) {
+		return nil, err
+	}
+	component := &XmppComponent{Domain: domain, conn: conn, decoder: xml.NewDecoder(conn)}
+	_, err = fmt.Fprintf(conn, "<stream:stream xmlns='%s' xmlns:stream='%s' to='%s'>",
+		XMPP_COMPONENT_NS, XMPP_STREAM_NS, xmlEscape(domain))
+	if err == nil {
+		err = component.handshake(secret)
+	}
+	if utils.IsError(err) {
+		conn.Close()
+		return nil, err
+	}
+	return component, nil
+}
+
+func xmlEscape(text string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(text))
+	return escaped.String()
+}
+
+func (component *XmppComponent) handshake(secret string) error {
+	streamId := ""
+	for {
+		token, err := component.decoder.Token()
+		if utils.IsError(err) {
+			return err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "stream":
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "id" {
+					streamId = attr.Value
+				}
+			}
+			hash := sha1.Sum([]byte(streamId + secret))
+			_, err = fmt.Fprintf(component.conn, "<handshake>%s</handshake>",
+				hex.EncodeToString(hash[:]))
+			if utils.IsError(err) {
+				return err
+			}
+		case "handshake":
+			return component.decoder.Skip()
+		case "error":
+			return errors.New("XMPP server refused component, check domain and secret.")
+		default:
+			component.decoder.Skip()
+		}
+	}
+}
+
+func (component *XmppComponent) Send(stanza interface{}) error {
+	data, err := xml.Marshal(stanza)
+	if utils.IsError(err) {
+		return err
+	}
+	component.mutex.Lock()
+	defer component.mutex.Unlock()
+	_, err = component.conn.Write(data)
+	return err
+}
+
+func (component *XmppComponent) SendMessage(from string, to string, text string) error {
+	return component.Send(XmppMessage{From: from, To: to, Type: "chat", Body: text})
+}
+
+func (component *XmppComponent) SendRaw(data string) error {
+	// for replies which have no struct, e.g. errors of iq
+	component.mutex.Lock()
+	defer component.mutex.Unlock()
+	_, err := io.WriteString(component.conn, data)
+	return err
+}
+
+func (component *XmppComponent) ReadStanzas(onStanza func(stanza interface{})) error {
+	// passes *XmppMessage, *XmppPresence and *XmppIq until stream is closed
+	for {
+		token, err := component.decoder.Token()
+		if utils.IsError(err) {
+			return err
+		}
+		if _, ok := token.(xml.EndElement); ok { // end of stream
+			return io.EOF
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		var stanza interface{}
+		switch start.Name.Local {
+		case "message":
+			stanza = &XmppMessage{}
+		case "presence":
+			stanza = &XmppPresence{}
+		case "iq":
+			stanza = &XmppIq{}
+		case "error":
+			return errors.New("XMPP server closed stream by error.")
+		default:
+			component.decoder.Skip()
+			continue
+		}
+		if err := component.decoder.DecodeElement(stanza, &start); utils.IsError(err) {
+			return err
+		}
+		onStanza(stanza)
+	}
+}
+
+func (component *XmppComponent) Close() {
+	component.SendRaw("</stream:stream>")
+	component.conn.Close()
+}
@@ -0,0 +1,50 @@
+// codec.go
+package codec
+
+import "encoding/json"
+
+// MessagePack never uses this byte and JSON can't start with it,
+// so encoding of payload is recognized by its first byte
+const MSGPACK_MARKER byte = 0xc1
+
+// encoding of payloads before encryption. Receiver decodes payload
+// of any codec, sender uses MessagePack only if other side supports it
+type Codec interface {
+	Marshal(object interface{}) ([]byte, error)
+	Unmarshal(data []byte, objectPointer interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(object interface{}) ([]byte, error) {
+	return json.Marshal(object)
+}
+
+func (jsonCodec) Unmarshal(data []byte, objectPointer interface{}) error {
+	return json.Unmarshal(data, objectPointer)
+}
+
+// fields are named by json tags, so models don't need other tags
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(object interface{}) ([]byte, error) {
+	return marshalMsgpack(object)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, objectPointer interface{}) error {
+	if len(data) == 0 || data[0] != MSGPACK_MARKER {
+		return errNotMsgpack
+	}
+	return unmarshalMsgpack(data[1:], objectPointer)
+}
+
+var JSON Codec = jsonCodec{}
+var MessagePack Codec = msgpackCodec{}
+
+func Detect(data []byte) Codec {
+	// returns codec which encoded data
+	if len(data) > 0 && data[0] == MSGPACK_MARKER {
+		return MessagePack
+	}
+	return JSON
+}
@@ -0,0 +1,687 @@
+// msgpack.go
+package codec
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// subset of MessagePack which is enough for models: nil, bool, numbers,
+// strings, binary, arrays and maps. Extension types aren't written or read.
+// Values are encoded like encoding/json does: structs are maps by json
+// names, embedded structs are flattened, omitempty and "-" are respected,
+// encoding.TextMarshaler values are strings
+
+var errNotMsgpack = errors.New("Payload isn't encoded by MessagePack")
+var errMsgpackTruncated = errors.New("MessagePack payload is truncated")
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// field of struct by its json name. Index leads through embedded structs
+type msgpackField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var structFields sync.Map // map: reflect.Type -> []msgpackField
+
+func getStructFields(structType reflect.Type) []msgpackField {
+	if fields, ok := structFields.Load(structType); ok {
+		return fields.([]msgpackField)
+	}
+	var fields []msgpackField
+	depths := make(map[string]int) // field of smaller depth hides deeper ones
+	var collect func(structType reflect.Type, index []int)
+	collect = func(structType reflect.Type, index []int) {
+		for i := 0; i < structType.NumField(); i++ {
+			field := structType.Field(i)
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, options := tag, ""
+			if comma := strings.Index(tag, ","); comma >= 0 {
+				name, options = tag[:comma], tag[comma+1:]
+			}
+			fieldIndex := append(append([]int{}, index...), i)
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+				collect(fieldType, fieldIndex)
+				continue
+			}
+			if field.PkgPath != "" { // unexported
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if depth, ok := depths[name]; ok {
+				if depth <= len(fieldIndex) {
+					continue
+				}
+				for j := range fields {
+					if fields[j].name == name {
+						fields = append(fields[:j], fields[j+1:]...)
+						break
+					}
+				}
+			}
+			depths[name] = len(fieldIndex)
+			fields = append(fields, msgpackField{name: name, index: fieldIndex,
+				omitEmpty: strings.Contains(","+options+",", ",omitempty,")})
+		}
+	}
+	collect(structType, nil)
+	structFields.Store(structType, fields)
+	return fields
+}
+
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool:
+		return !value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return value.IsNil()
+	}
+	return false
+}
+
+type msgpackEncoder struct {
+	data []byte
+}
+
+func marshalMsgpack(object interface{}) ([]byte, error) {
+	encoder := &msgpackEncoder{data: []byte{MSGPACK_MARKER}}
+	if err := encoder.encode(reflect.ValueOf(object)); err != nil {
+		return nil, err
+	}
+	return encoder.data, nil
+}
+
+func (e *msgpackEncoder) writeByte(b byte) {
+	e.data = append(e.data, b)
+}
+
+func (e *msgpackEncoder) writeUint(marker byte, value uint64, size int) {
+	e.data = append(e.data, marker)
+	for shift := (size - 1) * 8; shift >= 0; shift -= 8 {
+		e.data = append(e.data, byte(value>>uint(shift)))
+	}
+}
+
+func (e *msgpackEncoder) writeLength(length int, fixMarker byte, fixMax int,
+	marker8 byte, marker16 byte, marker32 byte) {
+	// fixMax is -1 for binary which has no fixed length form.
+	// marker8 is 0 for arrays and maps which have no 8-bit length
+	switch {
+	case length <= fixMax:
+		e.writeByte(fixMarker | byte(length))
+	case marker8 != 0 && length <= math.MaxUint8:
+		e.writeUint(marker8, uint64(length), 1)
+	case length <= math.MaxUint16:
+		e.writeUint(marker16, uint64(length), 2)
+	default:
+		e.writeUint(marker32, uint64(length), 4)
+	}
+}
+
+func (e *msgpackEncoder) encodeInt(value int64) {
+	switch {
+	case value >= 0:
+		e.encodeUint(uint64(value))
+	case value >= -32:
+		e.writeByte(byte(value))
+	case value >= math.MinInt8:
+		e.writeUint(0xd0, uint64(value), 1)
+	case value >= math.MinInt16:
+		e.writeUint(0xd1, uint64(value), 2)
+	case value >= math.MinInt32:
+		e.writeUint(0xd2, uint64(value), 4)
+	default:
+		e.writeUint(0xd3, uint64(value), 8)
+	}
+}
+
+func (e *msgpackEncoder) encodeUint(value uint64) {
+	switch {
+	case value <= 0x7f:
+		e.writeByte(byte(value))
+	case value <= math.MaxUint8:
+		e.writeUint(0xcc, value, 1)
+	case value <= math.MaxUint16:
+		e.writeUint(0xcd, value, 2)
+	case value <= math.MaxUint32:
+		e.writeUint(0xce, value, 4)
+	default:
+		e.writeUint(0xcf, value, 8)
+	}
+}
+
+func (e *msgpackEncoder) encodeString(text string) {
+	e.writeLength(len(text), 0xa0, 31, 0xd9, 0xda, 0xdb)
+	e.data = append(e.data, text...)
+}
+
+func (e *msgpackEncoder) encodeBytes(data []byte) {
+	e.writeLength(len(data), 0, -1, 0xc4, 0xc5, 0xc6)
+	e.data = append(e.data, data...)
+}
+
+func (e *msgpackEncoder) encode(value reflect.Value) error {
+	if !value.IsValid() {
+		e.writeByte(0xc0)
+		return nil
+	}
+	if value.Type().Implements(textMarshalerType) {
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			e.writeByte(0xc0)
+			return nil
+		}
+		text, err := value.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		e.encodeString(string(text))
+		return nil
+	}
+	switch value.Kind() {
+	case reflect.Bool:
+		if value.Bool() {
+			e.writeByte(0xc3)
+		} else {
+			e.writeByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Uintptr:
+		e.encodeUint(value.Uint())
+	case reflect.Float32:
+		e.writeUint(0xca, uint64(math.Float32bits(float32(value.Float()))), 4)
+	case reflect.Float64:
+		e.writeUint(0xcb, math.Float64bits(value.Float()), 8)
+	case reflect.String:
+		e.encodeString(value.String())
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			e.writeByte(0xc0)
+			return nil
+		}
+		return e.encode(value.Elem())
+	case reflect.Slice:
+		if value.IsNil() {
+			e.writeByte(0xc0)
+			return nil
+		}
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeBytes(value.Bytes())
+			return nil
+		}
+		return e.encodeArray(value)
+	case reflect.Array:
+		return e.encodeArray(value)
+	case reflect.Map:
+		if value.IsNil() {
+			e.writeByte(0xc0)
+			return nil
+		}
+		e.writeLength(value.Len(), 0x80, 15, 0, 0xde, 0xdf)
+		iterator := value.MapRange()
+		for iterator.Next() {
+			if err := e.encode(iterator.Key()); err != nil {
+				return err
+			}
+			if err := e.encode(iterator.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return e.encodeStruct(value)
+	default:
+		return errors.New("MessagePack can't encode " + value.Type().String())
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeArray(value reflect.Value) error {
+	e.writeLength(value.Len(), 0x90, 15, 0, 0xdc, 0xdd)
+	for i := 0; i < value.Len(); i++ {
+		if err := e.encode(value.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeStruct(value reflect.Value) error {
+	// fields inside nil embedded pointers are skipped
+	var names []string
+	var values []reflect.Value
+	for _, field := range getStructFields(value.Type()) {
+		fieldValue, ok := getField(value, field.index, false)
+		if !ok || field.omitEmpty && isEmptyValue(fieldValue) {
+			continue
+		}
+		names = append(names, field.name)
+		values = append(values, fieldValue)
+	}
+	e.writeLength(len(names), 0x80, 15, 0, 0xde, 0xdf)
+	for i, name := range names {
+		e.encodeString(name)
+		if err := e.encode(values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getField(value reflect.Value, index []int, allocate bool) (reflect.Value, bool) {
+	// follows embedded pointers. They are created if allocate is set
+	for i, fieldIndex := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				if !allocate {
+					return value, false
+				}
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(fieldIndex)
+	}
+	return value, true
+}
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func unmarshalMsgpack(data []byte, objectPointer interface{}) error {
+	value := reflect.ValueOf(objectPointer)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return errors.New("MessagePack decodes only to non-nil pointer")
+	}
+	decoder := &msgpackDecoder{data: data}
+	return decoder.decode(value.Elem())
+}
+
+func (d *msgpackDecoder) read(size int) ([]byte, error) {
+	if size < 0 || d.pos+size > len(d.data) {
+		return nil, errMsgpackTruncated
+	}
+	data := d.data[d.pos : d.pos+size]
+	d.pos += size
+	return data, nil
+}
+
+func (d *msgpackDecoder) readUint(size int) (uint64, error) {
+	data, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(data[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(data)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(data)), nil
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+func (d *msgpackDecoder) peek() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errMsgpackTruncated
+	}
+	return d.data[d.pos], nil
+}
+
+// kinds of values in payload
+const (
+	msgpackNil = iota
+	msgpackBool
+	msgpackInt
+	msgpackUint
+	msgpackFloat
+	msgpackString
+	msgpackBinary
+	msgpackArray
+	msgpackMap
+)
+
+// header of next value. Length is set for strings, binary, arrays and maps
+type msgpackHeader struct {
+	kind       int
+	length     int
+	boolValue  bool
+	intValue   int64
+	uintValue  uint64
+	floatValue float64
+}
+
+func (d *msgpackDecoder) readHeader() (msgpackHeader, error) {
+	marker, err := d.peek()
+	if err != nil {
+		return msgpackHeader{}, err
+	}
+	d.pos++
+	header := msgpackHeader{}
+	readLength := func(kind int, size int) (msgpackHeader, error) {
+		length, err := d.readUint(size)
+		header.kind, header.length = kind, int(length)
+		return header, err
+	}
+	switch {
+	case marker <= 0x7f:
+		return msgpackHeader{kind: msgpackUint, uintValue: uint64(marker)}, nil
+	case marker >= 0xe0:
+		return msgpackHeader{kind: msgpackInt, intValue: int64(int8(marker))}, nil
+	case marker >= 0x80 && marker <= 0x8f:
+		return msgpackHeader{kind: msgpackMap, length: int(marker & 0x0f)}, nil
+	case marker >= 0x90 && marker <= 0x9f:
+		return msgpackHeader{kind: msgpackArray, length: int(marker & 0x0f)}, nil
+	case marker >= 0xa0 && marker <= 0xbf:
+		return msgpackHeader{kind: msgpackString, length: int(marker & 0x1f)}, nil
+	}
+	switch marker {
+	case 0xc0:
+		return msgpackHeader{kind: msgpackNil}, nil
+	case 0xc2, 0xc3:
+		return msgpackHeader{kind: msgpackBool, boolValue: marker == 0xc3}, nil
+	case 0xc4, 0xc5, 0xc6:
+		return readLength(msgpackBinary, 1<<(marker-0xc4))
+	case 0xca:
+		bits, err := d.readUint(4)
+		return msgpackHeader{kind: msgpackFloat,
+			floatValue: float64(math.Float32frombits(uint32(bits)))}, err
+	case 0xcb:
+		bits, err := d.readUint(8)
+		return msgpackHeader{kind: msgpackFloat, floatValue: math.Float64frombits(bits)}, err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		value, err := d.readUint(1 << (marker - 0xcc))
+		return msgpackHeader{kind: msgpackUint, uintValue: value}, err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (marker - 0xd0)
+		value, err := d.readUint(size)
+		shift := uint(64 - size*8) // sign is extended from highest bit of value
+		return msgpackHeader{kind: msgpackInt, intValue: int64(value<<shift) >> shift}, err
+	case 0xd9, 0xda, 0xdb:
+		return readLength(msgpackString, 1<<(marker-0xd9))
+	case 0xdc, 0xdd:
+		return readLength(msgpackArray, 2<<(marker-0xdc))
+	case 0xde, 0xdf:
+		return readLength(msgpackMap, 2<<(marker-0xde))
+	}
+	return header, errors.New("MessagePack type isn't supported")
+}
+
+func (d *msgpackDecoder) decode(value reflect.Value) error {
+	// nil leaves value unchanged like null in JSON, except pointers,
+	// maps, slices and interfaces which become nil
+	marker, err := d.peek()
+	if err != nil {
+		return err
+	}
+	if marker == 0xc0 {
+		d.pos++
+		switch value.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			value.Set(reflect.Zero(value.Type()))
+		}
+		return nil
+	}
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return d.decode(value.Elem())
+	}
+	if value.Kind() == reflect.Interface && value.NumMethod() == 0 {
+		object, err := d.decodeAny()
+		if err == nil && object != nil {
+			value.Set(reflect.ValueOf(object))
+		}
+		return err
+	}
+	header, err := d.readHeader()
+	if err != nil {
+		return err
+	}
+	if value.CanAddr() && reflect.PtrTo(value.Type()).Implements(textUnmarshalerType) &&
+		header.kind == msgpackString {
+		text, err := d.read(header.length)
+		if err != nil {
+			return err
+		}
+		return value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(text)
+	}
+	return d.decodeWithHeader(value, header)
+}
+
+func (d *msgpackDecoder) decodeWithHeader(value reflect.Value, header msgpackHeader) error {
+	mismatch := errors.New("MessagePack value can't be decoded to " + value.Type().String())
+	switch value.Kind() {
+	case reflect.Bool:
+		if header.kind != msgpackBool {
+			return mismatch
+		}
+		value.SetBool(header.boolValue)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch header.kind {
+		case msgpackInt:
+			value.SetInt(header.intValue)
+		case msgpackUint:
+			value.SetInt(int64(header.uintValue))
+		case msgpackFloat:
+			value.SetInt(int64(header.floatValue))
+		default:
+			return mismatch
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Uintptr:
+		switch header.kind {
+		case msgpackInt:
+			value.SetUint(uint64(header.intValue))
+		case msgpackUint:
+			value.SetUint(header.uintValue)
+		case msgpackFloat:
+			value.SetUint(uint64(header.floatValue))
+		default:
+			return mismatch
+		}
+	case reflect.Float32, reflect.Float64:
+		switch header.kind {
+		case msgpackInt:
+			value.SetFloat(float64(header.intValue))
+		case msgpackUint:
+			value.SetFloat(float64(header.uintValue))
+		case msgpackFloat:
+			value.SetFloat(header.floatValue)
+		default:
+			return mismatch
+		}
+	case reflect.String:
+		if header.kind != msgpackString && header.kind != msgpackBinary {
+			return mismatch
+		}
+		data, err := d.read(header.length)
+		if err != nil {
+			return err
+		}
+		value.SetString(string(data))
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 &&
+			(header.kind == msgpackBinary || header.kind == msgpackString) {
+			data, err := d.read(header.length)
+			if err != nil {
+				return err
+			}
+			value.SetBytes(append([]byte{}, data...))
+			return nil
+		}
+		if header.kind != msgpackArray {
+			return mismatch
+		}
+		if header.length > len(d.data)-d.pos { // every item takes a byte at least
+			return errMsgpackTruncated
+		}
+		slice := reflect.MakeSlice(value.Type(), header.length, header.length)
+		for i := 0; i < header.length; i++ {
+			if err := d.decode(slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+	case reflect.Array:
+		if header.kind != msgpackArray {
+			return mismatch
+		}
+		for i := 0; i < header.length; i++ {
+			if i >= value.Len() {
+				if err := d.skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.decode(value.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if header.kind != msgpackMap {
+			return mismatch
+		}
+		if value.IsNil() {
+			value.Set(reflect.MakeMap(value.Type()))
+		}
+		for i := 0; i < header.length; i++ {
+			key := reflect.New(value.Type().Key()).Elem()
+			if err := d.decode(key); err != nil {
+				return err
+			}
+			item := reflect.New(value.Type().Elem()).Elem()
+			if err := d.decode(item); err != nil {
+				return err
+			}
+			value.SetMapIndex(key, item)
+		}
+	case reflect.Struct:
+		if header.kind != msgpackMap {
+			return mismatch
+		}
+		return d.decodeStruct(value, header.length)
+	default:
+		return mismatch
+	}
+	return nil
+}
+
+func (d *msgpackDecoder) decodeStruct(value reflect.Value, length int) error {
+	// unknown fields are skipped. Names are matched case-insensitively like in JSON
+	fields := getStructFields(value.Type())
+	for i := 0; i < length; i++ {
+		var name string
+		if err := d.decode(reflect.ValueOf(&name).Elem()); err != nil {
+			return err
+		}
+		var found *msgpackField
+		for j := range fields {
+			if fields[j].name == name {
+				found = &fields[j]
+				break
+			}
+			if found == nil && strings.EqualFold(fields[j].name, name) {
+				found = &fields[j]
+			}
+		}
+		if found == nil {
+			if err := d.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		field, _ := getField(value, found.index, true)
+		if err := d.decode(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *msgpackDecoder) skip() error {
+	_, err := d.decodeAny()
+	return err
+}
+
+func (d *msgpackDecoder) decodeAny() (interface{}, error) {
+	// returns values of types which encoding/json uses for interface{},
+	// but numbers keep their MessagePack types
+	header, err := d.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	switch header.kind {
+	case msgpackBool:
+		return header.boolValue, nil
+	case msgpackInt:
+		return header.intValue, nil
+	case msgpackUint:
+		return header.uintValue, nil
+	case msgpackFloat:
+		return header.floatValue, nil
+	case msgpackString:
+		data, err := d.read(header.length)
+		return string(data), err
+	case msgpackBinary:
+		data, err := d.read(header.length)
+		return append([]byte{}, data...), err
+	case msgpackArray:
+		if header.length > len(d.data)-d.pos {
+			return nil, errMsgpackTruncated
+		}
+		items := make([]interface{}, header.length)
+		for i := range items {
+			if items[i], err = d.decodeAny(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	case msgpackMap:
+		items := make(map[string]interface{})
+		for i := 0; i < header.length; i++ {
+			key, err := d.decodeAny()
+			if err != nil {
+				return nil, err
+			}
+			item, err := d.decodeAny()
+			if err != nil {
+				return nil, err
+			}
+			if text, ok := key.(string); ok {
+				items[text] = item
+			}
+		}
+		return items, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,111 @@
+// msgpack_test.go
+package codec
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/satori/go.uuid"
+
+	"chat/models"
+)
+
+func TestMsgpackEncoding(t *testing.T) {
+	// smallest formats of MessagePack specification
+	tests := []struct {
+		value   interface{}
+		encoded string
+	}{
+		{nil, "c0"},
+		{true, "c3"},
+		{false, "c2"},
+		{1, "01"},
+		{-1, "ff"},
+		{-33, "d0df"},
+		{200, "ccc8"},
+		{300, "cd012c"},
+		{70000, "ce00011170"},
+		{-40000, "d2ffff63c0"},
+		{1.5, "cb3ff8000000000000"},
+		{"a", "a161"},
+		{strings.Repeat("a", 32), "d920" + strings.Repeat("61", 32)},
+		{[]byte{1, 2}, "c4020102"},
+		{[]int{1, 2}, "920102"},
+		{map[string]int{"a": 1}, "81a16101"},
+	}
+	for _, test := range tests {
+		data, err := MessagePack.Marshal(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data[0] != MSGPACK_MARKER {
+			t.Fatalf("payload of %v doesn't start with marker", test.value)
+		}
+		if encoded := hex.EncodeToString(data[1:]); encoded != test.encoded {
+			t.Errorf("encoded %v = %s, want %s", test.value, encoded, test.encoded)
+		}
+	}
+}
+
+type embeddedFields struct {
+	Name string `json:"name"`
+}
+
+type testFields struct {
+	embeddedFields
+	Count   int    `json:"count,omitempty"`
+	Skipped string `json:"-"`
+	hidden  string
+}
+
+func TestMsgpackStructFields(t *testing.T) {
+	// names of json tags, flattened embedded struct and omitted fields
+	data, err := MessagePack.Marshal(testFields{embeddedFields{"x"}, 0, "skipped", "hidden"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded := hex.EncodeToString(data[1:]); encoded != "81a46e616d65a178" {
+		t.Errorf("encoded struct = %s, want map of name only", encoded)
+	}
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	msg := models.SavedMessage{Id: 42, Status: models.MESSAGE_STATE_READ}
+	msg.Message = models.Message{User: models.User{Id: 7, Username: "user"}, ChatId: -3,
+		Text: "привет", ReplyToId: 1 << 40, Sticker: "https://example.org/sticker.png"}
+	msg.Attachment = models.Attachment{Id: 5, FileName: "file.txt", Size: 1 << 20}
+	key := uuid.NewV4()
+	pack := struct {
+		Messages []models.SavedMessage `json:"messages"`
+		Key      uuid.UUID             `json:"key"`
+		Data     []byte                `json:"data"`
+	}{[]models.SavedMessage{msg, {}}, key, []byte{0, 255}}
+
+	data, err := MessagePack.Marshal(pack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := pack
+	decoded.Messages, decoded.Key, decoded.Data = nil, uuid.UUID{}, nil
+	if err := Detect(data).Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, pack) {
+		t.Errorf("decoded %+v, want %+v", decoded, pack)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	data, _ := JSON.Marshal(map[string]int{"a": 1})
+	if Detect(data) != JSON {
+		t.Error("JSON payload is detected as MessagePack")
+	}
+	if err := MessagePack.Unmarshal(data, &map[string]int{}); err == nil {
+		t.Error("JSON payload is decoded as MessagePack")
+	}
+	if _, err := MessagePack.Marshal(make(chan int)); err == nil {
+		t.Error("channel is encoded")
+	}
+}
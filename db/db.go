@@ -6,6 +6,7 @@ import (
 	"errors"
 	"log"
 	"strconv"
+	"strings"
 
 	sq "github.com/Masterminds/squirrel"
 	_ "github.com/mattn/go-sqlite3"
@@ -28,7 +29,13 @@ func (adapter *DatabaseAdapter) Close() {
 
 func (adapter *DatabaseAdapter) ConnectSqlite(dbName string) {
 	TABLES := []string{
-		"users (id INTEGER PRIMARY KEY, username VARCHAR(64), password_hash VARCHAR(256));",
+		`users (id INTEGER PRIMARY KEY,
+		 username VARCHAR(64),
+		 password_hash VARCHAR(256),
+		 avatar_hash VARCHAR(64) NOT NULL DEFAULT '',
+		 display_name VARCHAR(64) NOT NULL DEFAULT '',
+		 status_text TEXT NOT NULL DEFAULT '',
+		 totp_secret VARCHAR(64) NOT NULL DEFAULT '');`,
 
 		`failed_login (id INTEGER PRIMARY KEY, 
 		 user_id INTEGER NOT NULL, 
@@ -41,6 +48,11 @@ func (adapter *DatabaseAdapter) ConnectSqlite(dbName string) {
 		 user_id INTEGER NOT NULL,
 		 chat_id INTEGER NOT NULL,
 		 created_on INTEGER NOT NULL,
+		 edited_on INTEGER NOT NULL DEFAULT 0,
+		 status VARCHAR(16) NOT NULL DEFAULT 'sent',
+		 reply_to_id INTEGER NOT NULL DEFAULT 0,
+		 forwarded_from VARCHAR(64) NOT NULL DEFAULT '',
+		 sticker TEXT NOT NULL DEFAULT '',
 		 FOREIGN KEY (user_id) REFERENCES users(id));`,
 
 		`saved_channels
@@ -48,7 +60,93 @@ func (adapter *DatabaseAdapter) ConnectSqlite(dbName string) {
 		 user_id INTEGER NOT NULL,
 		 chat_id INTEGER NOT NULL,
 		 FOREIGN KEY (user_id) REFERENCES users(id),
-		 FOREIGN KEY (chat_id) REFERENCES users(id));`}
+		 FOREIGN KEY (chat_id) REFERENCES users(id));`,
+
+		`attachments
+		(id INTEGER PRIMARY KEY,
+		 message_id INTEGER NOT NULL,
+		 file_name TEXT NOT NULL,
+		 size INTEGER NOT NULL,
+		 path TEXT NOT NULL,
+		 FOREIGN KEY (message_id) REFERENCES messages(id));`,
+
+		`group_channels
+		(id INTEGER PRIMARY KEY,
+		 title VARCHAR(64) NOT NULL,
+		 owner_id INTEGER NOT NULL,
+		 FOREIGN KEY (owner_id) REFERENCES users(id));`,
+
+		`group_members
+		(id INTEGER PRIMARY KEY,
+		 group_id INTEGER NOT NULL,
+		 user_id INTEGER NOT NULL,
+		 role VARCHAR(16) NOT NULL DEFAULT 'member',
+		 FOREIGN KEY (group_id) REFERENCES group_channels(id),
+		 FOREIGN KEY (user_id) REFERENCES users(id));`,
+
+		`session_tokens
+		(id INTEGER PRIMARY KEY,
+		 token_hash VARCHAR(256) NOT NULL,
+		 user_id INTEGER NOT NULL,
+		 created_on INTEGER NOT NULL,
+		 FOREIGN KEY (user_id) REFERENCES users(id));`,
+
+		`reactions
+		(id INTEGER PRIMARY KEY,
+		 message_id INTEGER NOT NULL,
+		 user_id INTEGER NOT NULL,
+		 emoji VARCHAR(32) NOT NULL,
+		 FOREIGN KEY (message_id) REFERENCES messages(id),
+		 FOREIGN KEY (user_id) REFERENCES users(id));`,
+
+		`pinned_messages
+		(id INTEGER PRIMARY KEY,
+		 message_id INTEGER NOT NULL,
+		 user_id INTEGER NOT NULL,
+		 pinned_on INTEGER NOT NULL,
+		 FOREIGN KEY (message_id) REFERENCES messages(id),
+		 FOREIGN KEY (user_id) REFERENCES users(id));`,
+
+		`reports
+		(id INTEGER PRIMARY KEY,
+		 message_id INTEGER NOT NULL,
+		 user_id INTEGER NOT NULL,
+		 reason TEXT NOT NULL,
+		 created_on INTEGER NOT NULL,
+		 FOREIGN KEY (message_id) REFERENCES messages(id),
+		 FOREIGN KEY (user_id) REFERENCES users(id));`,
+
+		`blocked_users
+		(id INTEGER PRIMARY KEY,
+		 user_id INTEGER NOT NULL,
+		 blocked_user_id INTEGER NOT NULL,
+		 FOREIGN KEY (user_id) REFERENCES users(id),
+		 FOREIGN KEY (blocked_user_id) REFERENCES users(id));`,
+
+		`contacts
+		(id INTEGER PRIMARY KEY,
+		 user_id INTEGER NOT NULL,
+		 contact_id INTEGER NOT NULL,
+		 FOREIGN KEY (user_id) REFERENCES users(id),
+		 FOREIGN KEY (contact_id) REFERENCES users(id));`,
+
+		`channel_invitations
+		(id INTEGER PRIMARY KEY,
+		 kind VARCHAR(16) NOT NULL,
+		 group_id INTEGER NOT NULL,
+		 user_id INTEGER NOT NULL,
+		 from_user_id INTEGER NOT NULL,
+		 created_on INTEGER NOT NULL,
+		 FOREIGN KEY (group_id) REFERENCES group_channels(id),
+		 FOREIGN KEY (user_id) REFERENCES users(id),
+		 FOREIGN KEY (from_user_id) REFERENCES users(id));`,
+
+		`oauth_accounts
+		(id INTEGER PRIMARY KEY,
+		 provider VARCHAR(64) NOT NULL,
+		 subject VARCHAR(256) NOT NULL,
+		 user_id INTEGER NOT NULL,
+		 FOREIGN KEY (user_id) REFERENCES users(id));`}
 
 	db, err := sql.Open("sqlite3", dbName)
 	if utils.IsError(err) {
@@ -62,18 +160,86 @@ func (adapter *DatabaseAdapter) ConnectSqlite(dbName string) {
 			panic(err)
 		}
 	}
+	// columns added after first release
+	addColumnIfNotExists(db, "messages", "edited_on", "INTEGER NOT NULL DEFAULT 0")
+	addColumnIfNotExists(db, "messages", "status", "VARCHAR(16) NOT NULL DEFAULT 'sent'")
+	addColumnIfNotExists(db, "messages", "reply_to_id", "INTEGER NOT NULL DEFAULT 0")
+	addColumnIfNotExists(db, "messages", "forwarded_from", "VARCHAR(64) NOT NULL DEFAULT ''")
+	addColumnIfNotExists(db, "messages", "sticker", "TEXT NOT NULL DEFAULT ''")
+	addColumnIfNotExists(db, "users", "avatar_hash", "VARCHAR(64) NOT NULL DEFAULT ''")
+	addColumnIfNotExists(db, "users", "display_name", "VARCHAR(64) NOT NULL DEFAULT ''")
+	addColumnIfNotExists(db, "users", "status_text", "TEXT NOT NULL DEFAULT ''")
+	addColumnIfNotExists(db, "users", "totp_secret", "VARCHAR(64) NOT NULL DEFAULT ''")
+	addColumnIfNotExists(db, "group_members", "role", "VARCHAR(16) NOT NULL DEFAULT 'member'")
+	addColumnIfNotExists(db, "reports", "reported_user_id", "INTEGER NOT NULL DEFAULT 0")
+	addColumnIfNotExists(db, "reports", "category", "VARCHAR(16) NOT NULL DEFAULT 'other'")
+
 	adapter.dbFileName = dbName
 	adapter.DB = db
 	adapter.CommonKey = uuid.FromBytesOrNil([]byte(utils.COMMON_SECRET_KEY))
 }
 
-func (adapter *DatabaseAdapter) GetMessagesFromGroup() []models.SavedMessage {
-	// returns all messages from group channel
-	result := []models.SavedMessage{}
-	selectSql := sq.Select("messages.*, users.username").
+func addColumnIfNotExists(db *sql.DB, table string, column string, definition string) {
+	// adds column to table created by previous version of application
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if utils.IsError(err) {
+		panic(err)
+	}
+	for rows.Next() {
+		var id, notNull, primaryKey int
+		var name, columnType string
+		var defaultValue sql.NullString
+		err := rows.Scan(&id, &name, &columnType, &notNull, &defaultValue, &primaryKey)
+		if utils.IsError(err) {
+			panic(err)
+		}
+		if name == column {
+			rows.Close()
+			return
+		}
+	}
+	rows.Close()
+
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func selectMessages() sq.SelectBuilder {
+	// selects messages with author name and attachment info
+	return sq.Select("messages.id, messages.text, messages.user_id, messages.chat_id, " +
+		"messages.created_on, messages.edited_on, messages.status, users.username, " +
+		"IFNULL(attachments.id, 0), IFNULL(attachments.file_name, ''), " +
+		"IFNULL(attachments.size, 0), messages.reply_to_id, messages.forwarded_from, " +
+		"messages.sticker, EXISTS(SELECT 1 FROM pinned_messages WHERE message_id = messages.id)").
 		From("messages").
 		Join("users on messages.user_id = users.id").
-		Where("chat_id = ?", utils.GROUP_CHAT_ID)
+		LeftJoin("attachments on attachments.message_id = messages.id")
+}
+
+func paginateMessages(selectSql sq.SelectBuilder, beforeId int64, limit int) sq.SelectBuilder {
+	// selects last messages with id less than beforeId.
+	// Zero beforeId or limit means no restriction
+	if beforeId > 0 {
+		selectSql = selectSql.Where("messages.id < ?", beforeId)
+	}
+	if limit > 0 {
+		selectSql = selectSql.OrderBy("messages.id DESC").Limit(uint64(limit))
+	}
+	return selectSql
+}
+
+func reverseMessages(messages []models.SavedMessage) []models.SavedMessage {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages
+}
+
+func (adapter *DatabaseAdapter) queryMessages(selectSql sq.SelectBuilder) []models.SavedMessage {
+	// returns messages selected by selectMessages query with decrypted text
+	result := []models.SavedMessage{}
 	rows, err := selectSql.RunWith(adapter.DB).Query()
 	if utils.IsError(err) {
 		panic(err)
@@ -84,7 +250,9 @@ func (adapter *DatabaseAdapter) GetMessagesFromGroup() []models.SavedMessage {
 		msg := models.SavedMessage{}
 		encryptedText := ""
 		err := rows.Scan(&msg.Id, &encryptedText, &msg.User.Id, &msg.ChatId,
-			&msg.CreatedOn, &msg.User.Username)
+			&msg.CreatedOn, &msg.EditedOn, &msg.Status, &msg.User.Username, &msg.Attachment.Id,
+			&msg.Attachment.FileName, &msg.Attachment.Size, &msg.ReplyToId, &msg.ForwardedFrom,
+			&msg.Sticker, &msg.Pinned)
 		if utils.IsError(err) {
 			log.Println(err)
 			continue
@@ -93,46 +261,212 @@ func (adapter *DatabaseAdapter) GetMessagesFromGroup() []models.SavedMessage {
 		msg.Text = decryptedText
 		result = append(result, msg)
 	}
-
+	adapter.addReactions(result)
+	adapter.addReplyPreviews(result)
 	return result
 }
 
-func (adapter *DatabaseAdapter) GetMessagesFromPrivate(fromUserId int64, toUserId int64) []models.SavedMessage {
-	result := []models.SavedMessage{}
-
-	selectSql := sq.Select("messages.*, users.username").
+func (adapter *DatabaseAdapter) addReplyPreviews(messages []models.SavedMessage) {
+	// fills beginnings of messages which are replied by messages
+	var ids []int64
+	for _, msg := range messages {
+		if msg.IsReply() {
+			ids = append(ids, msg.ReplyToId)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	selectSql := sq.Select("messages.id, messages.text, users.id, users.username, " +
+		"IFNULL(attachments.file_name, '')").
 		From("messages").
 		Join("users on messages.user_id = users.id").
-		Where(sq.Or{sq.Eq{"user_id": fromUserId, "chat_id": toUserId},
-			sq.Eq{"user_id": toUserId, "chat_id": fromUserId}})
+		LeftJoin("attachments on attachments.message_id = messages.id").
+		Where(sq.Eq{"messages.id": ids})
 	rows, err := selectSql.RunWith(adapter.DB).Query()
 	if utils.IsError(err) {
 		panic(err)
 	}
 	defer rows.Close()
 
+	previews := make(map[int64]models.ReplyPreview) // map: message id -> preview
 	for rows.Next() {
-		msg := models.SavedMessage{}
-		encryptedText := ""
-		err := rows.Scan(&msg.Id, &encryptedText, &msg.User.Id, &msg.ChatId,
-			&msg.CreatedOn, &msg.User.Username)
+		var id int64
+		var encryptedText, fileName string
+		preview := models.ReplyPreview{}
+		err := rows.Scan(&id, &encryptedText, &preview.User.Id, &preview.User.Username, &fileName)
 		if utils.IsError(err) {
 			log.Println(err)
 			continue
 		}
-		decryptedText, _ := encrypt.DecryptText(adapter.CommonKey.Bytes(), encryptedText)
-		msg.Text = decryptedText
-		result = append(result, msg)
+		preview.Text, _ = encrypt.DecryptText(adapter.CommonKey.Bytes(), encryptedText)
+		if preview.Text == "" {
+			preview.Text = fileName
+		}
+		if text := []rune(preview.Text); len(text) > models.REPLY_PREVIEW_LENGTH {
+			preview.Text = string(text[:models.REPLY_PREVIEW_LENGTH])
+		}
+		previews[id] = preview
+	}
+	for i, msg := range messages {
+		if msg.IsReply() {
+			messages[i].ReplyTo = previews[msg.ReplyToId]
+		}
+	}
+}
+
+func (adapter *DatabaseAdapter) addReactions(messages []models.SavedMessage) {
+	// fills reactions of messages. Emoji are ordered by first reaction
+	indexes := make(map[int64]int) // map: message id -> index in messages
+	var ids []int64
+	for i, msg := range messages {
+		indexes[msg.Id] = i
+		ids = append(ids, msg.Id)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	selectSql := sq.Select("message_id, user_id, emoji").From("reactions").
+		Where(sq.Eq{"message_id": ids}).
+		OrderBy("id")
+	rows, err := selectSql.RunWith(adapter.DB).Query()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageId, userId int64
+		var emoji string
+		err := rows.Scan(&messageId, &userId, &emoji)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		msg := &messages[indexes[messageId]]
+		isAdded := false
+		for i := range msg.Reactions {
+			if msg.Reactions[i].Emoji == emoji {
+				msg.Reactions[i].UserIds = append(msg.Reactions[i].UserIds, userId)
+				isAdded = true
+			}
+		}
+		if !isAdded {
+			msg.Reactions = append(msg.Reactions,
+				models.Reaction{Emoji: emoji, UserIds: []int64{userId}})
+		}
+	}
+}
+
+func (adapter *DatabaseAdapter) GetMessagesFromGroup(chatId int64, beforeId int64,
+	limit int) []models.SavedMessage {
+	// returns messages from main or created group channel
+	selectSql := selectMessages().Where("chat_id = ?", chatId)
+	selectSql = paginateMessages(selectSql, beforeId, limit)
+	result := adapter.queryMessages(selectSql)
+
+	if limit > 0 {
+		return reverseMessages(result)
+	}
+	return result
+}
+
+func (adapter *DatabaseAdapter) GetMessagesFromPrivate(fromUserId int64, toUserId int64,
+	beforeId int64, limit int) []models.SavedMessage {
+	selectSql := selectMessages().
+		Where(sq.Or{sq.Eq{"user_id": fromUserId, "chat_id": toUserId},
+			sq.Eq{"user_id": toUserId, "chat_id": fromUserId}})
+	selectSql = paginateMessages(selectSql, beforeId, limit)
+	result := adapter.queryMessages(selectSql)
+
+	if limit > 0 {
+		return reverseMessages(result)
+	}
+	return result
+}
+
+func (adapter *DatabaseAdapter) GetMessagesAround(userId int64, chatId int64,
+	aroundId int64, limit int) []models.SavedMessage {
+	// returns message with aroundId and about limit/2 messages before and after it
+	result := adapter.GetMessagesFromChat(userId, chatId, aroundId+1, limit/2+1)
+
+	selectSql := selectMessages().Where("messages.id > ?", aroundId).
+		OrderBy("messages.id").Limit(uint64(limit / 2))
+	if chatId <= 0 {
+		selectSql = selectSql.Where("chat_id = ?", chatId)
+	} else {
+		selectSql = selectSql.
+			Where(sq.Or{sq.Eq{"user_id": userId, "chat_id": chatId},
+				sq.Eq{"user_id": chatId, "chat_id": userId}})
+	}
+	return append(result, adapter.queryMessages(selectSql)...)
+}
+
+func (adapter *DatabaseAdapter) GetPinnedMessages(userId int64,
+	chatId int64) []models.SavedMessage {
+	// returns pinned messages of chat in order of sending
+	selectSql := selectMessages().
+		Where("messages.id IN (SELECT message_id FROM pinned_messages)").
+		OrderBy("messages.id").Limit(uint64(models.MAX_PINNED_MESSAGES))
+	if chatId <= 0 {
+		selectSql = selectSql.Where("chat_id = ?", chatId)
+	} else {
+		selectSql = selectSql.
+			Where(sq.Or{sq.Eq{"user_id": userId, "chat_id": chatId},
+				sq.Eq{"user_id": chatId, "chat_id": userId}})
 	}
+	return adapter.queryMessages(selectSql)
+}
+
+func (adapter *DatabaseAdapter) SearchMessages(userId int64, query string,
+	limit int) []models.SavedMessage {
+	// returns newest messages available for user which contain query.
+	// Texts are encrypted so they are filtered after decryption
+	selectSql := selectMessages().
+		Where(sq.Or{
+			sq.Eq{"chat_id": utils.GROUP_CHAT_ID},
+			sq.Eq{"user_id": userId},
+			sq.Eq{"chat_id": userId},
+			sq.Expr("chat_id < 0 AND -chat_id IN "+
+				"(SELECT group_id FROM group_members WHERE user_id = ?)", userId)}).
+		OrderBy("messages.id DESC")
+	return filterMessagesByText(adapter.queryMessages(selectSql), query, limit)
+}
 
+func filterMessagesByText(messages []models.SavedMessage, query string,
+	limit int) []models.SavedMessage {
+	// returns first messages which contain query ignoring case.
+	// Attachments are found by file name
+	result := []models.SavedMessage{}
+	query = strings.ToLower(query)
+	for _, msg := range messages {
+		if len(result) == limit {
+			break
+		}
+		if strings.Contains(strings.ToLower(msg.Text), query) ||
+			strings.Contains(strings.ToLower(msg.Attachment.FileName), query) {
+			result = append(result, msg)
+		}
+	}
 	return result
 }
 
-func (adapter *DatabaseAdapter) GetMessagesFromChat(userId int64, chatId int64) []models.SavedMessage {
-	if chatId == 0 {
-		return adapter.GetMessagesFromGroup()
+func (adapter *DatabaseAdapter) GetMessageById(id int64) (models.SavedMessage, error) {
+	messages := adapter.queryMessages(selectMessages().Where("messages.id = ?", id))
+	if len(messages) == 0 {
+		return models.SavedMessage{}, errors.New("Message with id = " +
+			strconv.FormatInt(id, 10) + " does not exist.")
+	}
+	return messages[0], nil
+}
+
+func (adapter *DatabaseAdapter) GetMessagesFromChat(userId int64, chatId int64,
+	beforeId int64, limit int) []models.SavedMessage {
+	// returns page of chat history ordered by id
+	if chatId <= 0 {
+		return adapter.GetMessagesFromGroup(chatId, beforeId, limit)
 	}
-	return adapter.GetMessagesFromPrivate(userId, chatId)
+	return adapter.GetMessagesFromPrivate(userId, chatId, beforeId, limit)
 }
 
 func (adapter *DatabaseAdapter) GetUserById(id int) (models.User, error) {
@@ -188,6 +522,146 @@ func (adapter *DatabaseAdapter) GetChannels(userId int64) []models.Channel {
 		result = append(result, channel)
 	}
 
+	return append(result, adapter.getGroupChannels(userId)...)
+}
+
+func getGroupId(chatId int64) int64 {
+	// group channels have negative chat id
+	return -chatId
+}
+
+func (adapter *DatabaseAdapter) getGroupChannels(userId int64) []models.Channel {
+	// returns created groups in which user is member
+	result := []models.Channel{}
+
+	selectSql := sq.Select("group_channels.id, group_channels.title, group_channels.owner_id").
+		From("group_channels").
+		Join("group_members on group_members.group_id = group_channels.id").
+		Where("group_members.user_id = ?", userId)
+	rows, err := selectSql.RunWith(adapter.DB).Query()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		channel := models.Channel{}
+		var groupId int64
+		err := rows.Scan(&groupId, &channel.Title, &channel.OwnerId)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		channel.Id = getGroupId(groupId)
+		result = append(result, channel)
+	}
+	return result
+}
+
+func (adapter *DatabaseAdapter) AddNewGroup(title string, ownerId int64,
+	memberIds []int64) models.Channel {
+	// creates group channel. Owner becomes its member too
+	insertSql := sq.Insert("group_channels").Columns("title, owner_id").
+		Values(title, ownerId)
+	result, err := insertSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	groupId, err := result.LastInsertId()
+	if utils.IsError(err) {
+		panic(err)
+	}
+
+	addedIds := make(map[int64]bool)
+	for _, memberId := range append([]int64{ownerId}, memberIds...) {
+		if addedIds[memberId] {
+			continue
+		}
+		addedIds[memberId] = true
+		insertSql := sq.Insert("group_members").Columns("group_id, user_id").
+			Values(groupId, memberId)
+		_, err := insertSql.RunWith(adapter.DB).Exec()
+		if utils.IsError(err) {
+			panic(err)
+		}
+	}
+	return models.Channel{Id: getGroupId(groupId), Title: title, OwnerId: ownerId}
+}
+
+func (adapter *DatabaseAdapter) IsGroupExist(title string) bool {
+	selectSql := sq.Select("id").From("group_channels").Where("title = ?", title)
+	row := selectSql.RunWith(adapter.DB).QueryRow()
+	var id int64
+	err := row.Scan(&id)
+	return !utils.IsError(err)
+}
+
+func (adapter *DatabaseAdapter) GetGroupOwnerId(chatId int64) int64 {
+	// returns 0 if group doesn't exist
+	selectSql := sq.Select("owner_id").From("group_channels").
+		Where("id = ?", getGroupId(chatId))
+	row := selectSql.RunWith(adapter.DB).QueryRow()
+	var ownerId int64
+	row.Scan(&ownerId)
+	return ownerId
+}
+
+func (adapter *DatabaseAdapter) IsGroupMember(chatId int64, userId int64) bool {
+	selectSql := sq.Select("id").From("group_members").
+		Where("group_id = ? AND user_id = ?", getGroupId(chatId), userId)
+	row := selectSql.RunWith(adapter.DB).QueryRow()
+	var id int64
+	err := row.Scan(&id)
+	return !utils.IsError(err)
+}
+
+func (adapter *DatabaseAdapter) GetGroupMembers(chatId int64) []models.User {
+	// returns members of created group. All users are members of main channel
+	result := []models.User{}
+
+	selectSql := sq.Select("users.id, users.username").From("users")
+	if chatId != utils.GROUP_CHAT_ID {
+		selectSql = selectSql.
+			Join("group_members on group_members.user_id = users.id").
+			Where("group_members.group_id = ?", getGroupId(chatId))
+	}
+	rows, err := selectSql.RunWith(adapter.DB).Query()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user := models.User{}
+		err := rows.Scan(&user.Id, &user.Username)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		result = append(result, user)
+	}
+	return result
+}
+
+func (adapter *DatabaseAdapter) GetGroupMemberIds(chatId int64) []int64 {
+	var result []int64
+	selectSql := sq.Select("user_id").From("group_members").
+		Where("group_id = ?", getGroupId(chatId))
+	rows, err := selectSql.RunWith(adapter.DB).Query()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userId int64
+		err := rows.Scan(&userId)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		result = append(result, userId)
+	}
 	return result
 }
 
@@ -227,14 +701,60 @@ func (adapter *DatabaseAdapter) AddNewFailedLogin(userId int64) {
 	}
 }
 
+func (adapter *DatabaseAdapter) AddNewSessionToken(userId int64, tokenHash string) {
+	insertSql := sq.Insert("session_tokens").Columns("token_hash, user_id, created_on").
+		Values(tokenHash, userId, utils.GetTimestampNow())
+	_, err := insertSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) GetUserBySessionToken(tokenHash string,
+	minCreatedOn int64) (models.User, error) {
+	// returns owner of token created not earlier than minCreatedOn
+	user := models.User{}
+	selectSql := sq.Select("users.id, users.username").From("session_tokens").
+		Join("users on session_tokens.user_id = users.id").
+		Where(sq.Eq{"session_tokens.token_hash": tokenHash}).
+		Where("session_tokens.created_on >= ?", minCreatedOn)
+	row := selectSql.RunWith(adapter.DB).QueryRow()
+
+	err := row.Scan(&user.Id, &user.Username)
+	if utils.IsError(err) {
+		return models.User{}, errors.New("Session token is not valid. " + err.Error())
+	}
+	return user, nil
+}
+
+func (adapter *DatabaseAdapter) DeleteSessionToken(tokenHash string) {
+	deleteSql := sq.Delete("session_tokens").Where(sq.Eq{"token_hash": tokenHash})
+	_, err := deleteSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) DeleteUserSessionTokens(userId int64) {
+	// tokens of all devices become invalid, e.g. after password change
+	deleteSql := sq.Delete("session_tokens").Where(sq.Eq{"user_id": userId})
+	_, err := deleteSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
 func (adapter *DatabaseAdapter) AddNewMessage(msg models.Message) models.SavedMessage {
 	savedMessage := models.SavedMessage{Message: msg}
 	savedMessage.CreatedOn = utils.GetTimestampNow()
+	savedMessage.Status = models.MESSAGE_STATE_SENT
 
 	encryptedText, err := encrypt.EncryptText(adapter.CommonKey.Bytes(), msg.Text)
 
-	insertSql := sq.Insert("messages").Columns("chat_id, user_id, text, created_on").
-		Values(msg.ChatId, msg.User.Id, encryptedText, savedMessage.CreatedOn)
+	insertSql := sq.Insert("messages").
+		Columns("chat_id, user_id, text, created_on, reply_to_id, forwarded_from, sticker").
+		Values(msg.ChatId, msg.User.Id, encryptedText, savedMessage.CreatedOn, msg.ReplyToId,
+			msg.ForwardedFrom, msg.Sticker)
 
 	result, err := insertSql.RunWith(adapter.DB).Exec()
 	if utils.IsError(err) {
@@ -244,31 +764,217 @@ func (adapter *DatabaseAdapter) AddNewMessage(msg models.Message) models.SavedMe
 	if utils.IsError(err) {
 		log.Println(err)
 	}
-	return savedMessage
+	messages := []models.SavedMessage{savedMessage}
+	adapter.addReplyPreviews(messages)
+	return messages[0]
 }
 
-func (adapter *DatabaseAdapter) AddNewChannel(userId int64, chatId int64) {
-	// this function adds new user(user_id) to user(chat_id) private relationship
-	insertSql := sq.Insert("saved_channels").Columns("user_id, chat_id").
-		Values(userId, chatId)
-
-	_, err := insertSql.RunWith(adapter.DB).Exec()
+func (adapter *DatabaseAdapter) UpdateMessagesStatus(fromUserId int64, toUserId int64,
+	lastMessageId int64, status string) {
+	// sets status of private messages up to lastMessageId.
+	// Read messages stay read
+	updateSql := sq.Update("messages").Set("status", status).
+		Where(sq.Eq{"user_id": fromUserId, "chat_id": toUserId}).
+		Where("id <= ?", lastMessageId).
+		Where(sq.NotEq{"status": models.MESSAGE_STATE_READ})
+	if status == models.MESSAGE_STATE_DELIVERED {
+		updateSql = updateSql.Where(sq.Eq{"status": models.MESSAGE_STATE_SENT})
+	}
+	_, err := updateSql.RunWith(adapter.DB).Exec()
 	if utils.IsError(err) {
 		panic(err)
 	}
 }
 
-func (adapter *DatabaseAdapter) IsUserExist(username string) bool {
-	_, err := adapter.GetUserByName(username)
-	if !utils.IsError(err) {
-		return true
+func (adapter *DatabaseAdapter) GetUndeliveredMessages(toUserId int64) map[int64]int64 {
+	// returns map: sender id -> last message id not delivered to user
+	result := make(map[int64]int64)
+	selectSql := sq.Select("user_id, MAX(id)").From("messages").
+		Where(sq.Eq{"chat_id": toUserId, "status": models.MESSAGE_STATE_SENT}).
+		Where("user_id != ?", toUserId).
+		GroupBy("user_id")
+	rows, err := selectSql.RunWith(adapter.DB).Query()
+	if utils.IsError(err) {
+		panic(err)
 	}
-	return false
-}
+	defer rows.Close()
 
-func (adapter *DatabaseAdapter) IsChannelExist(userId, chatId int64) bool {
-	selectSql := sq.Select("*").From("saved_channels").
-		Where("user_id = ? AND chat_id = ?", userId, chatId)
+	for rows.Next() {
+		var senderId, lastMessageId int64
+		err := rows.Scan(&senderId, &lastMessageId)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		result[senderId] = lastMessageId
+	}
+	return result
+}
+
+func (adapter *DatabaseAdapter) UpdateMessageText(id int64, text string) int64 {
+	// saves new text of edited message. Returns edit timestamp
+	editedOn := utils.GetTimestampNow()
+	encryptedText, err := encrypt.EncryptText(adapter.CommonKey.Bytes(), text)
+
+	updateSql := sq.Update("messages").Set("text", encryptedText).
+		Set("edited_on", editedOn).Where("id = ?", id)
+	_, err = updateSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	return editedOn
+}
+
+func (adapter *DatabaseAdapter) DeleteMessage(id int64) []string {
+	// deletes message with its attachments.
+	// Returns paths of attached files which should be removed
+	var paths []string
+	selectSql := sq.Select("path").From("attachments").Where("message_id = ?", id)
+	rows, err := selectSql.RunWith(adapter.DB).Query()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	for rows.Next() {
+		path := ""
+		err := rows.Scan(&path)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	rows.Close()
+
+	_, err = sq.Delete("attachments").Where("message_id = ?", id).RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	_, err = sq.Delete("reactions").Where("message_id = ?", id).RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	_, err = sq.Delete("pinned_messages").Where("message_id = ?", id).RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	_, err = sq.Delete("reports").Where("message_id = ?", id).RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	_, err = sq.Delete("messages").Where("id = ?", id).RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	return paths
+}
+
+func (adapter *DatabaseAdapter) ToggleReaction(messageId int64, userId int64, emoji string) {
+	// adds reaction of user or removes it if it exists
+	deleteSql := sq.Delete("reactions").
+		Where(sq.Eq{"message_id": messageId, "user_id": userId, "emoji": emoji})
+	result, err := deleteSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	if deletedCount, _ := result.RowsAffected(); deletedCount > 0 {
+		return
+	}
+	insertSql := sq.Insert("reactions").Columns("message_id, user_id, emoji").
+		Values(messageId, userId, emoji)
+	_, err = insertSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) SetMessagePinned(messageId int64, userId int64, isPinned bool) {
+	// user is member who pinned message. Unpinned message has no row
+	_, err := sq.Delete("pinned_messages").Where("message_id = ?", messageId).
+		RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	if !isPinned {
+		return
+	}
+	insertSql := sq.Insert("pinned_messages").Columns("message_id, user_id, pinned_on").
+		Values(messageId, userId, utils.GetTimestampNow())
+	_, err = insertSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) AddNewReport(userId int64, report models.Report) {
+	// reports of users without message have message_id 0
+	insertSql := sq.Insert("reports").
+		Columns("message_id, user_id, reported_user_id, category, reason, created_on").
+		Values(report.MessageId, userId, report.UserId, report.Category, report.Comment,
+			utils.GetTimestampNow())
+	_, err := insertSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) AddNewAttachment(messageId int64,
+	attachment *models.Attachment, path string) {
+	// saves info about uploaded file. path is location of file on server
+	insertSql := sq.Insert("attachments").Columns("message_id, file_name, size, path").
+		Values(messageId, attachment.FileName, attachment.Size, path)
+
+	result, err := insertSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	attachment.Id, err = result.LastInsertId()
+	if utils.IsError(err) {
+		log.Println(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) GetAttachment(attachmentId int64) (models.Message, string, error) {
+	// returns message with attachment and path of file on server
+	msg := models.Message{}
+	path := ""
+	selectSql := sq.Select("messages.user_id, messages.chat_id, attachments.id, "+
+		"attachments.file_name, attachments.size, attachments.path").
+		From("attachments").
+		Join("messages on attachments.message_id = messages.id").
+		Where("attachments.id = ?", attachmentId)
+	row := selectSql.RunWith(adapter.DB).QueryRow()
+
+	err := row.Scan(&msg.User.Id, &msg.ChatId, &msg.Attachment.Id,
+		&msg.Attachment.FileName, &msg.Attachment.Size, &path)
+	if utils.IsError(err) {
+		return models.Message{}, "", errors.New("Attachment with id = " +
+			strconv.FormatInt(attachmentId, 10) + " does not exist. " + err.Error())
+	}
+	return msg, path, nil
+}
+
+func (adapter *DatabaseAdapter) AddNewChannel(userId int64, chatId int64) {
+	// this function adds new user(user_id) to user(chat_id) private relationship
+	insertSql := sq.Insert("saved_channels").Columns("user_id, chat_id").
+		Values(userId, chatId)
+
+	_, err := insertSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) IsUserExist(username string) bool {
+	_, err := adapter.GetUserByName(username)
+	if !utils.IsError(err) {
+		return true
+	}
+	return false
+}
+
+func (adapter *DatabaseAdapter) IsChannelExist(userId, chatId int64) bool {
+	selectSql := sq.Select("*").From("saved_channels").
+		Where("user_id = ? AND chat_id = ?", userId, chatId)
 	row := selectSql.RunWith(adapter.DB).QueryRow()
 	// next 3 variables are used only to enter Scan arguments
 	var tempId int
@@ -281,16 +987,447 @@ func (adapter *DatabaseAdapter) IsChannelExist(userId, chatId int64) bool {
 	return true
 }
 
-func (adapter *DatabaseAdapter) CheckUserPassword(username, passwordHash string) bool {
+func (adapter *DatabaseAdapter) GetUserPasswordHash(username string) string {
+	// returns salted hash or unsalted hash of old clients.
+	// Empty string if user doesn't exist
 	selectSql := sq.Select("password_hash").From("users").Where("username = ?", username)
 	row := selectSql.RunWith(adapter.DB).QueryRow()
 
 	savedPasswordHash := ""
 	err := row.Scan(&savedPasswordHash)
-	if !utils.IsError(err) && savedPasswordHash == passwordHash {
-		return true
+	if utils.IsError(err) {
+		return ""
 	}
-	return false
+	return savedPasswordHash
+}
+
+func (adapter *DatabaseAdapter) UpdateUserPasswordHash(userId int64, passwordHash string) {
+	updateSql := sq.Update("users").Set("password_hash", passwordHash).Where("id = ?", userId)
+	_, err := updateSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		log.Println(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) GetUserTotpSecret(userId int64) string {
+	// returns secret of one-time codes. Empty if two-factor auth is disabled
+	selectSql := sq.Select("totp_secret").From("users").Where("id = ?", userId)
+	secret := ""
+	err := selectSql.RunWith(adapter.DB).QueryRow().Scan(&secret)
+	if utils.IsError(err) {
+		return ""
+	}
+	return secret
+}
+
+func (adapter *DatabaseAdapter) SetUserTotpSecret(userId int64, secret string) {
+	// empty secret disables two-factor auth
+	updateSql := sq.Update("users").Set("totp_secret", secret).Where("id = ?", userId)
+	_, err := updateSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		log.Println(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) GetUserByOAuthAccount(provider string,
+	subject string) (models.User, error) {
+	// returns user who has logged in by account of provider before
+	user := models.User{}
+	selectSql := sq.Select("users.id, users.username").From("oauth_accounts").
+		Join("users on oauth_accounts.user_id = users.id").
+		Where(sq.Eq{"oauth_accounts.provider": provider, "oauth_accounts.subject": subject})
+	err := selectSql.RunWith(adapter.DB).QueryRow().Scan(&user.Id, &user.Username)
+	if utils.IsError(err) {
+		return models.User{}, errors.New("Account of provider is not linked. " + err.Error())
+	}
+	return user, nil
+}
+
+func (adapter *DatabaseAdapter) AddOAuthAccount(provider string, subject string, userId int64) {
+	insertSql := sq.Insert("oauth_accounts").Columns("provider, subject, user_id").
+		Values(provider, subject, userId)
+	_, err := insertSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) GetUserProfile(userId int64) (models.Profile, error) {
+	profile := models.Profile{}
+	selectSql := sq.Select("id, username, display_name, status_text").From("users").
+		Where("id = ?", userId)
+	row := selectSql.RunWith(adapter.DB).QueryRow()
+
+	err := row.Scan(&profile.User.Id, &profile.User.Username, &profile.DisplayName,
+		&profile.StatusText)
+	if utils.IsError(err) {
+		return models.Profile{}, errors.New("User with id = " +
+			strconv.FormatInt(userId, 10) + " does not exist. " + err.Error())
+	}
+	return profile, nil
+}
+
+func (adapter *DatabaseAdapter) UpdateUserProfile(profile models.Profile) {
+	updateSql := sq.Update("users").Set("display_name", profile.DisplayName).
+		Set("status_text", profile.StatusText).Where("id = ?", profile.User.Id)
+	_, err := updateSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) GetUserAvatarHash(userId int64) string {
+	// returns empty string if user has no avatar
+	selectSql := sq.Select("avatar_hash").From("users").Where("id = ?", userId)
+	row := selectSql.RunWith(adapter.DB).QueryRow()
+
+	avatarHash := ""
+	err := row.Scan(&avatarHash)
+	if utils.IsError(err) {
+		return ""
+	}
+	return avatarHash
+}
+
+func (adapter *DatabaseAdapter) UpdateUserAvatarHash(userId int64, avatarHash string) {
+	updateSql := sq.Update("users").Set("avatar_hash", avatarHash).Where("id = ?", userId)
+	_, err := updateSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) GetBlockedUsers(userId int64) []models.User {
+	// returns users which are blocked by user
+	result := []models.User{}
+	selectSql := sq.Select("users.id, users.username").From("users").
+		Join("blocked_users on blocked_users.blocked_user_id = users.id").
+		Where("blocked_users.user_id = ?", userId)
+	rows, err := selectSql.RunWith(adapter.DB).Query()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user := models.User{}
+		err := rows.Scan(&user.Id, &user.Username)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		result = append(result, user)
+	}
+	return result
+}
+
+func (adapter *DatabaseAdapter) UpdateBlockedUser(userId int64, blockedUserId int64, isBlocked bool) {
+	// adds blocked user to list of user or removes him from it
+	deleteSql := sq.Delete("blocked_users").
+		Where(sq.Eq{"user_id": userId, "blocked_user_id": blockedUserId})
+	_, err := deleteSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	if !isBlocked {
+		return
+	}
+	insertSql := sq.Insert("blocked_users").Columns("user_id, blocked_user_id").
+		Values(userId, blockedUserId)
+	_, err = insertSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) SearchUsers(query string, limit int) []models.User {
+	// returns users whose username or display name contains query ignoring case
+	result := []models.User{}
+	pattern := "%" + escapeLikePattern(query) + "%"
+	selectSql := sq.Select("id, username").From("users").
+		Where("username LIKE ? ESCAPE '\\' OR display_name LIKE ? ESCAPE '\\'", pattern, pattern).
+		OrderBy("username").
+		Limit(uint64(limit))
+	rows, err := selectSql.RunWith(adapter.DB).Query()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user := models.User{}
+		err := rows.Scan(&user.Id, &user.Username)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		result = append(result, user)
+	}
+	return result
+}
+
+func escapeLikePattern(text string) string {
+	// wildcards of query are searched as usual characters
+	replacer := strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+	return replacer.Replace(text)
+}
+
+func (adapter *DatabaseAdapter) GetContacts(userId int64) []models.User {
+	// returns contacts of user sorted by username
+	result := []models.User{}
+	selectSql := sq.Select("users.id, users.username").From("users").
+		Join("contacts on contacts.contact_id = users.id").
+		Where("contacts.user_id = ?", userId).
+		OrderBy("users.username")
+	rows, err := selectSql.RunWith(adapter.DB).Query()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user := models.User{}
+		err := rows.Scan(&user.Id, &user.Username)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		result = append(result, user)
+	}
+	return result
+}
+
+func (adapter *DatabaseAdapter) UpdateContact(userId int64, contactId int64, isContact bool) {
+	// adds contact to list of user or removes it from list
+	deleteSql := sq.Delete("contacts").
+		Where(sq.Eq{"user_id": userId, "contact_id": contactId})
+	_, err := deleteSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	if !isContact {
+		return
+	}
+	insertSql := sq.Insert("contacts").Columns("user_id, contact_id").
+		Values(userId, contactId)
+	_, err = insertSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) GetGroupByTitle(title string) (models.Channel, error) {
+	return adapter.getGroup(sq.Eq{"title": title})
+}
+
+func (adapter *DatabaseAdapter) GetGroupById(chatId int64) (models.Channel, error) {
+	return adapter.getGroup(sq.Eq{"id": getGroupId(chatId)})
+}
+
+func (adapter *DatabaseAdapter) getGroup(condition sq.Eq) (models.Channel, error) {
+	selectSql := sq.Select("id, title, owner_id").From("group_channels").Where(condition)
+	row := selectSql.RunWith(adapter.DB).QueryRow()
+	channel := models.Channel{}
+	var groupId int64
+	err := row.Scan(&groupId, &channel.Title, &channel.OwnerId)
+	channel.Id = getGroupId(groupId)
+	return channel, err
+}
+
+func (adapter *DatabaseAdapter) AddGroupMember(chatId int64, userId int64) {
+	if adapter.IsGroupMember(chatId, userId) {
+		return
+	}
+	insertSql := sq.Insert("group_members").Columns("group_id, user_id").
+		Values(getGroupId(chatId), userId)
+	_, err := insertSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) RemoveGroupMember(chatId int64, userId int64) {
+	deleteSql := sq.Delete("group_members").
+		Where(sq.Eq{"group_id": getGroupId(chatId), "user_id": userId})
+	_, err := deleteSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) GetGroupMemberRoles(chatId int64) map[int64]string {
+	// returns map: user id -> role. Owner has owner role instead of saved one
+	result := make(map[int64]string)
+	selectSql := sq.Select("group_members.user_id, group_members.role, group_channels.owner_id").
+		From("group_members").
+		Join("group_channels on group_channels.id = group_members.group_id").
+		Where("group_members.group_id = ?", getGroupId(chatId))
+	rows, err := selectSql.RunWith(adapter.DB).Query()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userId, ownerId int64
+		var role string
+		err := rows.Scan(&userId, &role, &ownerId)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		if userId == ownerId {
+			role = models.ROLE_OWNER
+		}
+		result[userId] = role
+	}
+	return result
+}
+
+func (adapter *DatabaseAdapter) SetGroupMemberRole(chatId int64, userId int64, role string) {
+	updateSql := sq.Update("group_members").Set("role", role).
+		Where(sq.Eq{"group_id": getGroupId(chatId), "user_id": userId})
+	_, err := updateSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) RenameGroup(chatId int64, title string) {
+	updateSql := sq.Update("group_channels").Set("title", title).
+		Where(sq.Eq{"id": getGroupId(chatId)})
+	_, err := updateSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) IsInvitationExist(chatId int64, userId int64) bool {
+	// checks pending invitation or join request of user to group
+	selectSql := sq.Select("id").From("channel_invitations").
+		Where("group_id = ? AND user_id = ?", getGroupId(chatId), userId)
+	row := selectSql.RunWith(adapter.DB).QueryRow()
+	var id int64
+	err := row.Scan(&id)
+	return !utils.IsError(err)
+}
+
+func (adapter *DatabaseAdapter) AddInvitation(kind string, chatId int64, userId int64,
+	fromUserId int64) {
+	insertSql := sq.Insert("channel_invitations").
+		Columns("kind, group_id, user_id, from_user_id, created_on").
+		Values(kind, getGroupId(chatId), userId, fromUserId, utils.GetTimestampNow())
+	_, err := insertSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func getInvitationsSelect() sq.SelectBuilder {
+	return sq.Select("channel_invitations.id, channel_invitations.kind, " +
+		"channel_invitations.created_on, group_channels.id, group_channels.title, " +
+		"group_channels.owner_id, users.id, users.username, from_users.id, from_users.username").
+		From("channel_invitations").
+		Join("group_channels on group_channels.id = channel_invitations.group_id").
+		Join("users on users.id = channel_invitations.user_id").
+		Join("users AS from_users on from_users.id = channel_invitations.from_user_id")
+}
+
+func scanInvitation(row sq.RowScanner) (models.Invitation, error) {
+	invitation := models.Invitation{}
+	var groupId int64
+	err := row.Scan(&invitation.Id, &invitation.Kind, &invitation.CreatedOn,
+		&groupId, &invitation.Channel.Title, &invitation.Channel.OwnerId,
+		&invitation.User.Id, &invitation.User.Username,
+		&invitation.FromUser.Id, &invitation.FromUser.Username)
+	invitation.Channel.Id = getGroupId(groupId)
+	return invitation, err
+}
+
+func (adapter *DatabaseAdapter) GetInvitations(userId int64) []models.Invitation {
+	// returns invitations of user and join requests to groups owned by user
+	result := []models.Invitation{}
+	selectSql := getInvitationsSelect().
+		Where("(channel_invitations.kind = ? AND channel_invitations.user_id = ?) OR "+
+			"(channel_invitations.kind = ? AND group_channels.owner_id = ?)",
+			models.INVITATION_INVITE, userId, models.INVITATION_JOIN, userId).
+		OrderBy("channel_invitations.id")
+	rows, err := selectSql.RunWith(adapter.DB).Query()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		result = append(result, invitation)
+	}
+	return result
+}
+
+func (adapter *DatabaseAdapter) GetInvitation(invitationId int64) (models.Invitation, error) {
+	selectSql := getInvitationsSelect().Where("channel_invitations.id = ?", invitationId)
+	return scanInvitation(selectSql.RunWith(adapter.DB).QueryRow())
+}
+
+func (adapter *DatabaseAdapter) DeleteInvitation(invitationId int64) {
+	deleteSql := sq.Delete("channel_invitations").Where(sq.Eq{"id": invitationId})
+	_, err := deleteSql.RunWith(adapter.DB).Exec()
+	if utils.IsError(err) {
+		panic(err)
+	}
+}
+
+func (adapter *DatabaseAdapter) DeleteUser(userId int64) []string {
+	// deletes user with his messages, private chats and group memberships.
+	// Returns paths of attached files which should be removed
+	var paths []string
+	userMessages := "message_id IN (SELECT id FROM messages WHERE user_id = ? OR chat_id = ?)"
+	selectSql := sq.Select("path").From("attachments").Where(userMessages, userId, userId)
+	rows, err := selectSql.RunWith(adapter.DB).Query()
+	if utils.IsError(err) {
+		panic(err)
+	}
+	for rows.Next() {
+		path := ""
+		err := rows.Scan(&path)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	rows.Close()
+
+	// private messages to user have his id as chat_id
+	deleteQueries := []sq.DeleteBuilder{
+		sq.Delete("attachments").Where(userMessages, userId, userId),
+		sq.Delete("reactions").Where(userMessages+" OR user_id = ?", userId, userId, userId),
+		sq.Delete("pinned_messages").Where(userMessages, userId, userId),
+		sq.Delete("reports").Where(userMessages+" OR user_id = ? OR reported_user_id = ?",
+			userId, userId, userId, userId),
+		sq.Delete("messages").Where("user_id = ? OR chat_id = ?", userId, userId),
+		sq.Delete("saved_channels").Where("user_id = ? OR chat_id = ?", userId, userId),
+		sq.Delete("group_members").Where(sq.Eq{"user_id": userId}),
+		sq.Delete("failed_login").Where(sq.Eq{"user_id": userId}),
+		sq.Delete("session_tokens").Where(sq.Eq{"user_id": userId}),
+		sq.Delete("oauth_accounts").Where(sq.Eq{"user_id": userId}),
+		sq.Delete("blocked_users").Where("user_id = ? OR blocked_user_id = ?", userId, userId),
+		sq.Delete("contacts").Where("user_id = ? OR contact_id = ?", userId, userId),
+		sq.Delete("channel_invitations").
+			Where("user_id = ? OR from_user_id = ?", userId, userId),
+		sq.Delete("users").Where(sq.Eq{"id": userId})}
+	for _, deleteSql := range deleteQueries {
+		_, err := deleteSql.RunWith(adapter.DB).Exec()
+		if utils.IsError(err) {
+			panic(err)
+		}
+	}
+	return paths
 }
 
 func (adapter *DatabaseAdapter) CountFailedLogin(userId int64) int {
@@ -0,0 +1,395 @@
+// messages_storage.go
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+
+	sq "github.com/Masterminds/squirrel"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/satori/go.uuid"
+
+	"chat/encrypt"
+	"chat/models"
+	"chat/utils"
+)
+
+// local cache of received messages (client side)
+type MessagesStorage struct {
+	dbFileName string
+	DB         *sql.DB
+	CommonKey  uuid.UUID
+}
+
+func (storage *MessagesStorage) Close() {
+	storage.DB.Close()
+}
+
+func (storage *MessagesStorage) ConnectSqlite(dbName string) {
+	// owner_id is id of logged in user, channel_id is id of opened chat
+	TABLES := []string{
+		`cached_messages
+		(id INTEGER NOT NULL,
+		 owner_id INTEGER NOT NULL,
+		 channel_id INTEGER NOT NULL,
+		 user_id INTEGER NOT NULL,
+		 username VARCHAR(64) NOT NULL,
+		 chat_id INTEGER NOT NULL,
+		 text TEXT NOT NULL,
+		 created_on INTEGER NOT NULL,
+		 attachment_id INTEGER NOT NULL DEFAULT 0,
+		 attachment_name TEXT NOT NULL DEFAULT '',
+		 attachment_size INTEGER NOT NULL DEFAULT 0,
+		 edited_on INTEGER NOT NULL DEFAULT 0,
+		 status VARCHAR(16) NOT NULL DEFAULT 'sent',
+		 reactions TEXT NOT NULL DEFAULT '',
+		 reply_to_id INTEGER NOT NULL DEFAULT 0,
+		 reply_to TEXT NOT NULL DEFAULT '',
+		 pinned BOOLEAN NOT NULL DEFAULT 0,
+		 forwarded_from VARCHAR(64) NOT NULL DEFAULT '',
+		 sticker TEXT NOT NULL DEFAULT '',
+		 PRIMARY KEY (owner_id, id));`,
+		`user_filters
+		(owner_id INTEGER NOT NULL,
+		 user_id INTEGER NOT NULL,
+		 username VARCHAR(64) NOT NULL,
+		 blocked BOOLEAN NOT NULL DEFAULT 0,
+		 muted BOOLEAN NOT NULL DEFAULT 0,
+		 PRIMARY KEY (owner_id, user_id));`,
+		`scheduled_messages
+		(id INTEGER PRIMARY KEY AUTOINCREMENT,
+		 owner_id INTEGER NOT NULL,
+		 chat_id INTEGER NOT NULL,
+		 chat_title TEXT NOT NULL,
+		 text TEXT NOT NULL,
+		 reply_to_id INTEGER NOT NULL DEFAULT 0,
+		 send_on INTEGER NOT NULL);`}
+
+	db, err := sql.Open("sqlite3", dbName)
+	if utils.IsError(err) {
+		panic(err)
+	}
+
+	for _, tableData := range TABLES {
+		_, err := db.Exec("CREATE TABLE IF NOT EXISTS " + tableData)
+		if utils.IsError(err) {
+			panic(err)
+		}
+	}
+	// columns added after first release
+	addColumnIfNotExists(db, "cached_messages", "attachment_id", "INTEGER NOT NULL DEFAULT 0")
+	addColumnIfNotExists(db, "cached_messages", "attachment_name", "TEXT NOT NULL DEFAULT ''")
+	addColumnIfNotExists(db, "cached_messages", "attachment_size", "INTEGER NOT NULL DEFAULT 0")
+	addColumnIfNotExists(db, "cached_messages", "edited_on", "INTEGER NOT NULL DEFAULT 0")
+	addColumnIfNotExists(db, "cached_messages", "status", "VARCHAR(16) NOT NULL DEFAULT 'sent'")
+	addColumnIfNotExists(db, "cached_messages", "reactions", "TEXT NOT NULL DEFAULT ''")
+	addColumnIfNotExists(db, "cached_messages", "reply_to_id", "INTEGER NOT NULL DEFAULT 0")
+	addColumnIfNotExists(db, "cached_messages", "reply_to", "TEXT NOT NULL DEFAULT ''")
+	addColumnIfNotExists(db, "cached_messages", "pinned", "BOOLEAN NOT NULL DEFAULT 0")
+	addColumnIfNotExists(db, "cached_messages", "forwarded_from",
+		"VARCHAR(64) NOT NULL DEFAULT ''")
+	addColumnIfNotExists(db, "cached_messages", "sticker", "TEXT NOT NULL DEFAULT ''")
+
+	storage.dbFileName = dbName
+	storage.DB = db
+	storage.CommonKey = uuid.FromBytesOrNil([]byte(utils.COMMON_SECRET_KEY))
+}
+
+func (storage *MessagesStorage) GetMessages(ownerId int64, channelId int64) []models.SavedMessage {
+	// returns cached messages of channel ordered by id
+	selectSql := selectCachedMessages().
+		Where(sq.Eq{"owner_id": ownerId, "channel_id": channelId}).
+		OrderBy("id")
+	return storage.queryMessages(selectSql)
+}
+
+func (storage *MessagesStorage) GetPinnedMessages(ownerId int64,
+	channelId int64) []models.SavedMessage {
+	// returns cached pinned messages. Server has older ones which aren't cached
+	selectSql := selectCachedMessages().
+		Where(sq.Eq{"owner_id": ownerId, "channel_id": channelId, "pinned": true}).
+		OrderBy("id")
+	return storage.queryMessages(selectSql)
+}
+
+func selectCachedMessages() sq.SelectBuilder {
+	return sq.Select("id, user_id, username, chat_id, text, created_on, " +
+		"attachment_id, attachment_name, attachment_size, edited_on, status, reactions, " +
+		"reply_to_id, reply_to, pinned, forwarded_from, sticker").
+		From("cached_messages")
+}
+
+func (storage *MessagesStorage) queryMessages(selectSql sq.SelectBuilder) []models.SavedMessage {
+	// returns messages selected by selectCachedMessages query with decrypted text
+	result := []models.SavedMessage{}
+	rows, err := selectSql.RunWith(storage.DB).Query()
+	if utils.IsError(err) {
+		log.Println(err)
+		return result
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		msg := models.SavedMessage{}
+		encryptedText := ""
+		reactions := ""
+		encryptedReplyTo := ""
+		err := rows.Scan(&msg.Id, &msg.User.Id, &msg.User.Username, &msg.ChatId,
+			&encryptedText, &msg.CreatedOn, &msg.Attachment.Id,
+			&msg.Attachment.FileName, &msg.Attachment.Size, &msg.EditedOn, &msg.Status,
+			&reactions, &msg.ReplyToId, &encryptedReplyTo, &msg.Pinned, &msg.ForwardedFrom,
+			&msg.Sticker)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		if reactions != "" { // reactions are saved as json
+			json.Unmarshal([]byte(reactions), &msg.Reactions)
+		}
+		if encryptedReplyTo != "" { // preview contains text, so it's encrypted
+			encrypt.Decrypt(storage.CommonKey, encryptedReplyTo, &msg.ReplyTo)
+		}
+		msg.Text, _ = encrypt.DecryptText(storage.CommonKey.Bytes(), encryptedText)
+		result = append(result, msg)
+	}
+	return result
+}
+
+func (storage *MessagesStorage) SearchMessages(ownerId int64, query string,
+	limit int) []models.SavedMessage {
+	// returns newest cached messages from all channels which contain query
+	selectSql := selectCachedMessages().
+		Where(sq.Eq{"owner_id": ownerId}).
+		OrderBy("id DESC")
+	return filterMessagesByText(storage.queryMessages(selectSql), query, limit)
+}
+
+func (storage *MessagesStorage) SaveMessage(ownerId int64, channelId int64,
+	msg models.SavedMessage) {
+	// adds message to cache or replaces saved one with same id
+	err := storage.insertMessage(storage.DB, ownerId, channelId, msg)
+	if utils.IsError(err) {
+		log.Println(err)
+	}
+}
+
+func (storage *MessagesStorage) UpdateMessagesStatus(ownerId int64, channelId int64,
+	lastMessageId int64, status string) {
+	// sets status of own messages in channel up to lastMessageId
+	updateSql := sq.Update("cached_messages").Set("status", status).
+		Where(sq.Eq{"owner_id": ownerId, "channel_id": channelId, "user_id": ownerId}).
+		Where("id <= ?", lastMessageId).
+		Where(sq.NotEq{"status": models.MESSAGE_STATE_READ})
+	if status == models.MESSAGE_STATE_DELIVERED {
+		updateSql = updateSql.Where(sq.Eq{"status": models.MESSAGE_STATE_SENT})
+	}
+	_, err := updateSql.RunWith(storage.DB).Exec()
+	if utils.IsError(err) {
+		log.Println(err)
+	}
+}
+
+func (storage *MessagesStorage) DeleteMessage(ownerId int64, id int64) {
+	deleteSql := sq.Delete("cached_messages").
+		Where(sq.Eq{"owner_id": ownerId, "id": id})
+	_, err := deleteSql.RunWith(storage.DB).Exec()
+	if utils.IsError(err) {
+		log.Println(err)
+	}
+}
+
+func (storage *MessagesStorage) SyncMessages(ownerId int64, channelId int64,
+	fromId int64, beforeId int64, messages []models.SavedMessage) {
+	// replaces cached channel history in range [fromId, beforeId)
+	// with history obtained from server. Zero beforeId means no upper bound
+	tx, err := storage.DB.Begin()
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	deleteSql := sq.Delete("cached_messages").
+		Where(sq.Eq{"owner_id": ownerId, "channel_id": channelId}).
+		Where("id >= ?", fromId)
+	if beforeId > 0 {
+		deleteSql = deleteSql.Where("id < ?", beforeId)
+	}
+	_, err = deleteSql.RunWith(tx).Exec()
+	if utils.IsError(err) {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+	for _, msg := range messages {
+		err = storage.insertMessage(tx, ownerId, channelId, msg)
+		if utils.IsError(err) {
+			log.Println(err)
+			tx.Rollback()
+			return
+		}
+	}
+	err = tx.Commit()
+	if utils.IsError(err) {
+		log.Println(err)
+	}
+}
+
+func (storage *MessagesStorage) ImportMessages(ownerId int64, channelId int64,
+	messages []models.SavedMessage) (int, error) {
+	// adds messages restored from exported history. Cached messages with
+	// same ids are kept. Returns count of added messages
+	tx, err := storage.DB.Begin()
+	if utils.IsError(err) {
+		return 0, err
+	}
+	count := 0
+	for _, msg := range messages {
+		insertSql, err := storage.getMessageInsert(ownerId, channelId, msg)
+		if utils.IsError(err) {
+			tx.Rollback()
+			return 0, err
+		}
+		result, err := insertSql.Options("OR IGNORE").RunWith(tx).Exec()
+		if utils.IsError(err) {
+			tx.Rollback()
+			return 0, err
+		}
+		if rows, err := result.RowsAffected(); !utils.IsError(err) {
+			count += int(rows)
+		}
+	}
+	return count, tx.Commit()
+}
+
+func (storage *MessagesStorage) GetUserFilters(ownerId int64) []models.UserFilter {
+	// returns users which are blocked or muted by owner
+	result := []models.UserFilter{}
+	selectSql := sq.Select("user_id, username, blocked, muted").
+		From("user_filters").
+		Where(sq.Eq{"owner_id": ownerId})
+	rows, err := selectSql.RunWith(storage.DB).Query()
+	if utils.IsError(err) {
+		log.Println(err)
+		return result
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		filter := models.UserFilter{}
+		err := rows.Scan(&filter.User.Id, &filter.User.Username, &filter.Blocked, &filter.Muted)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		result = append(result, filter)
+	}
+	return result
+}
+
+func (storage *MessagesStorage) SaveUserFilter(ownerId int64, filter models.UserFilter) {
+	// replaces saved filter. Filter without flags is removed
+	var err error
+	if !filter.Blocked && !filter.Muted {
+		deleteSql := sq.Delete("user_filters").
+			Where(sq.Eq{"owner_id": ownerId, "user_id": filter.User.Id})
+		_, err = deleteSql.RunWith(storage.DB).Exec()
+	} else {
+		insertSql := sq.Insert("user_filters").Options("OR REPLACE").
+			Columns("owner_id, user_id, username, blocked, muted").
+			Values(ownerId, filter.User.Id, filter.User.Username, filter.Blocked, filter.Muted)
+		_, err = insertSql.RunWith(storage.DB).Exec()
+	}
+	if utils.IsError(err) {
+		log.Println(err)
+	}
+}
+
+func (storage *MessagesStorage) AddScheduledMessage(ownerId int64,
+	msg models.ScheduledMessage) error {
+	encryptedText, err := encrypt.EncryptText(storage.CommonKey.Bytes(), msg.Text)
+	if utils.IsError(err) {
+		return err
+	}
+	insertSql := sq.Insert("scheduled_messages").
+		Columns("owner_id, chat_id, chat_title, text, reply_to_id, send_on").
+		Values(ownerId, msg.ChatId, msg.ChatTitle, encryptedText, msg.ReplyToId, msg.SendOn)
+	_, err = insertSql.RunWith(storage.DB).Exec()
+	return err
+}
+
+func (storage *MessagesStorage) GetScheduledMessages(ownerId int64) []models.ScheduledMessage {
+	// returns messages of owner which aren't sent yet, earliest first
+	result := []models.ScheduledMessage{}
+	selectSql := sq.Select("id, chat_id, chat_title, text, reply_to_id, send_on").
+		From("scheduled_messages").
+		Where(sq.Eq{"owner_id": ownerId}).
+		OrderBy("send_on, id")
+	rows, err := selectSql.RunWith(storage.DB).Query()
+	if utils.IsError(err) {
+		log.Println(err)
+		return result
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		msg := models.ScheduledMessage{}
+		encryptedText := ""
+		err := rows.Scan(&msg.Id, &msg.ChatId, &msg.ChatTitle, &encryptedText,
+			&msg.ReplyToId, &msg.SendOn)
+		if utils.IsError(err) {
+			log.Println(err)
+			continue
+		}
+		msg.Text, _ = encrypt.DecryptText(storage.CommonKey.Bytes(), encryptedText)
+		result = append(result, msg)
+	}
+	return result
+}
+
+func (storage *MessagesStorage) DeleteScheduledMessage(ownerId int64, id int64) {
+	deleteSql := sq.Delete("scheduled_messages").
+		Where(sq.Eq{"owner_id": ownerId, "id": id})
+	if _, err := deleteSql.RunWith(storage.DB).Exec(); utils.IsError(err) {
+		log.Println(err)
+	}
+}
+
+func (storage *MessagesStorage) insertMessage(runner sq.BaseRunner, ownerId int64,
+	channelId int64, msg models.SavedMessage) error {
+	insertSql, err := storage.getMessageInsert(ownerId, channelId, msg)
+	if utils.IsError(err) {
+		return err
+	}
+	_, err = insertSql.Options("OR REPLACE").RunWith(runner).Exec()
+	return err
+}
+
+func (storage *MessagesStorage) getMessageInsert(ownerId int64, channelId int64,
+	msg models.SavedMessage) (sq.InsertBuilder, error) {
+	// returns insert of message with encrypted texts
+	encryptedText, err := encrypt.EncryptText(storage.CommonKey.Bytes(), msg.Text)
+	if utils.IsError(err) {
+		return sq.InsertBuilder{}, err
+	}
+	reactions := ""
+	if len(msg.Reactions) > 0 {
+		data, err := json.Marshal(msg.Reactions)
+		if utils.IsError(err) {
+			return sq.InsertBuilder{}, err
+		}
+		reactions = string(data)
+	}
+	encryptedReplyTo := ""
+	if msg.IsReply() {
+		encryptedReplyTo = encrypt.Encrypt(storage.CommonKey, msg.ReplyTo)
+	}
+	insertSql := sq.Insert("cached_messages").
+		Columns("id, owner_id, channel_id, user_id, username, chat_id, text, created_on, "+
+			"attachment_id, attachment_name, attachment_size, edited_on, status, reactions, "+
+			"reply_to_id, reply_to, pinned, forwarded_from, sticker").
+		Values(msg.Id, ownerId, channelId, msg.User.Id, msg.User.Username,
+			msg.ChatId, encryptedText, msg.CreatedOn, msg.Attachment.Id,
+			msg.Attachment.FileName, msg.Attachment.Size, msg.EditedOn, msg.Status, reactions,
+			msg.ReplyToId, encryptedReplyTo, msg.Pinned, msg.ForwardedFrom, msg.Sticker)
+	return insertSql, nil
+}
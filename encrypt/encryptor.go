@@ -12,13 +12,14 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"io"
 	"log"
 	"strings"
 
 	"github.com/satori/go.uuid"
+
+	"chat/codec"
 )
 
 func addBase64Padding(value string) string {
@@ -100,11 +101,16 @@ func DecryptText(key []byte, text string) (string, error) {
 }
 
 func Encrypt(key uuid.UUID, object interface{}) string {
-	jsonString, err := json.Marshal(object)
+	return EncryptWith(codec.JSON, key, object)
+}
+
+func EncryptWith(payloadCodec codec.Codec, key uuid.UUID, object interface{}) string {
+	// object is encoded by codec before encryption
+	payload, err := payloadCodec.Marshal(object)
 	if err != nil {
 		log.Println(err)
 	}
-	result, err := EncryptText(key.Bytes(), string(jsonString))
+	result, err := EncryptText(key.Bytes(), string(payload))
 	if err != nil {
 		log.Println(err)
 	}
@@ -112,11 +118,12 @@ func Encrypt(key uuid.UUID, object interface{}) string {
 }
 
 func Decrypt(key uuid.UUID, encrypted string, objectPointer interface{}) {
-	jsonString, err := DecryptText(key.Bytes(), encrypted)
+	// codec is recognized by payload
+	payload, err := DecryptText(key.Bytes(), encrypted)
 	if err != nil {
 		log.Println(err)
 	}
-	err = json.Unmarshal([]byte(jsonString), objectPointer)
+	err = codec.Detect([]byte(payload)).Unmarshal([]byte(payload), objectPointer)
 	if err != nil {
 		log.Println(err)
 	}
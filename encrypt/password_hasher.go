@@ -3,10 +3,24 @@ package encrypt
 
 import (
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
+	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 )
 
+const PASSWORD_HASH_SCHEME = "pbkdf2-sha256"
+const PASSWORD_HASH_ITERATIONS int = 100000
+const PASSWORD_SALT_SIZE int = 16
+const PASSWORD_KEY_SIZE int = sha256.Size
+
+// unsalted hash sent by clients instead of password. Old servers saved
+// it as is. Also used for session tokens which are random and don't need salt
 func GetPasswordHash(password string) string {
 	hashedPassword := password
 	for i := 0; i < 10; i++ {
@@ -15,3 +29,82 @@ func GetPasswordHash(password string) string {
 	}
 	return hashedPassword
 }
+
+func pbkdf2(password []byte, salt []byte, iterations int, keySize int) []byte {
+	// PBKDF2 with HMAC-SHA256 (RFC 8018)
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := 1; len(key) < keySize; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keySize]
+}
+
+func IsClientPasswordHash(passwordHash string) bool {
+	// checks that client sent hash made by GetPasswordHash
+	decoded, err := hex.DecodeString(passwordHash)
+	return err == nil && len(decoded) == sha256.Size
+}
+
+func HashPassword(passwordHash string) (string, error) {
+	// returns salted hash of client hash in format scheme$iterations$salt$key
+	salt := make([]byte, PASSWORD_SALT_SIZE)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2([]byte(passwordHash), salt, PASSWORD_HASH_ITERATIONS, PASSWORD_KEY_SIZE)
+	return fmt.Sprintf("%s$%d$%s$%s", PASSWORD_HASH_SCHEME, PASSWORD_HASH_ITERATIONS,
+		hex.EncodeToString(salt), hex.EncodeToString(key)), nil
+}
+
+func IsLegacyPasswordHash(savedHash string) bool {
+	// hashes saved before salt was added have no scheme
+	return savedHash != "" && !strings.Contains(savedHash, "$")
+}
+
+func CheckPassword(passwordHash string, savedHash string) bool {
+	// compares client hash with salted hash or with client hash saved by old server
+	if IsLegacyPasswordHash(savedHash) {
+		return subtle.ConstantTimeCompare([]byte(passwordHash), []byte(savedHash)) == 1
+	}
+	salt, key, iterations, err := parsePasswordHash(savedHash)
+	if err != nil {
+		return false
+	}
+	passwordKey := pbkdf2([]byte(passwordHash), salt, iterations, len(key))
+	return subtle.ConstantTimeCompare(passwordKey, key) == 1
+}
+
+func parsePasswordHash(savedHash string) ([]byte, []byte, int, error) {
+	// returns salt, key and iterations count of hash
+	parts := strings.Split(savedHash, "$")
+	if len(parts) != 4 || parts[0] != PASSWORD_HASH_SCHEME {
+		return nil, nil, 0, errors.New("Unknown password hash scheme")
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return nil, nil, 0, errors.New("Wrong iterations count of password hash")
+	}
+	salt, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	key, err := hex.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return nil, nil, 0, errors.New("Wrong key of password hash")
+	}
+	return salt, key, iterations, nil
+}
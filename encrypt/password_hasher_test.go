@@ -0,0 +1,58 @@
+// password_hasher_test.go
+package encrypt
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestPbkdf2(t *testing.T) {
+	// vectors of PBKDF2-HMAC-SHA256 from RFC 7914 and RFC 6070 inputs
+	tests := []struct {
+		password   string
+		salt       string
+		iterations int
+		keySize    int
+		key        string
+	}{
+		{"password", "salt", 1, 32,
+			"120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 4096, 32,
+			"c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwd", "salt", 1, 64,
+			"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+				"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 40,
+			"348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+	}
+	for _, test := range tests {
+		key := pbkdf2([]byte(test.password), []byte(test.salt), test.iterations, test.keySize)
+		if hex.EncodeToString(key) != test.key {
+			t.Errorf("pbkdf2(%q, %q, %d) = %x, want %s", test.password, test.salt,
+				test.iterations, key, test.key)
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	passwordHash := GetPasswordHash("secret")
+	if !IsClientPasswordHash(passwordHash) || IsClientPasswordHash("secret") {
+		t.Error("client hash is not detected")
+	}
+	hash, err := HashPassword(passwordHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(passwordHash, hash) {
+		t.Error("correct password is not accepted")
+	}
+	if CheckPassword(GetPasswordHash("Secret"), hash) {
+		t.Error("wrong password is accepted")
+	}
+	if IsLegacyPasswordHash(hash) {
+		t.Error("salted hash is detected as legacy")
+	}
+	if !CheckPassword(passwordHash, GetPasswordHash("secret")) {
+		t.Error("legacy hash is not accepted")
+	}
+}
@@ -0,0 +1,39 @@
+// proof_of_work.go
+package encrypt
+
+import (
+	"crypto/sha256"
+	"math/bits"
+	"strconv"
+)
+
+// answer of challenge is number which gives sha256 of "nonce:answer"
+// with difficulty leading zero bits. Checking takes one hash, finding
+// takes about 2^difficulty hashes
+func getProofOfWorkHash(nonce string, answer string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(nonce + ":" + answer))
+}
+
+func countLeadingZeroBits(hash [sha256.Size]byte) int {
+	count := 0
+	for _, b := range hash {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+	return count
+}
+
+func SolveProofOfWork(nonce string, difficulty int) string {
+	for counter := int64(0); ; counter++ {
+		answer := strconv.FormatInt(counter, 10)
+		if countLeadingZeroBits(getProofOfWorkHash(nonce, answer)) >= difficulty {
+			return answer
+		}
+	}
+}
+
+func CheckProofOfWork(nonce string, answer string, difficulty int) bool {
+	return answer != "" && countLeadingZeroBits(getProofOfWorkHash(nonce, answer)) >= difficulty
+}
@@ -0,0 +1,79 @@
+// totp.go
+package encrypt
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+)
+
+// time-based one-time passwords of authenticator apps (RFC 6238)
+const TOTP_SECRET_SIZE int = 20
+const TOTP_PERIOD = 30 * time.Second
+const TOTP_DIGITS int = 6
+const TOTP_ALLOWED_SKEW int = 1 // codes of neighbour periods are accepted for clock drift
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateTotpSecret() (string, error) {
+	// returns base32 secret which is typed or scanned into app
+	secret := make([]byte, TOTP_SECRET_SIZE)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+func GetTotpUri(issuer string, username string, secret string) string {
+	// link of QR code scanned by authenticator app
+	label := url.PathEscape(issuer + ":" + username)
+	params := url.Values{"secret": {secret}, "issuer": {issuer}}
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+func GetTotpCode(secret string, now time.Time) (string, error) {
+	// HOTP (RFC 4226) of period which contains now
+	return getHotpCode(secret, now.Unix()/int64(TOTP_PERIOD/time.Second))
+}
+
+func getHotpCode(secret string, counter int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < TOTP_DIGITS; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTP_DIGITS, value%modulo), nil
+}
+
+func CheckTotpCode(secret string, code string, now time.Time) bool {
+	code = strings.ReplaceAll(code, " ", "")
+	counter := now.Unix() / int64(TOTP_PERIOD/time.Second)
+	for skew := -TOTP_ALLOWED_SKEW; skew <= TOTP_ALLOWED_SKEW; skew++ {
+		expected, err := getHotpCode(secret, counter+int64(skew))
+		if err != nil {
+			return false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return true
+		}
+	}
+	return false
+}
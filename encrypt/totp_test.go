@@ -0,0 +1,66 @@
+// totp_test.go
+package encrypt
+
+import (
+	"testing"
+	"time"
+)
+
+// secret "12345678901234567890" of test vectors in base32
+const RFC_TEST_SECRET = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestHotpCode(t *testing.T) {
+	// RFC 4226, appendix D
+	codes := []string{"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489"}
+	for counter, want := range codes {
+		code, err := getHotpCode(RFC_TEST_SECRET, int64(counter))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != want {
+			t.Errorf("getHotpCode(%d) = %s, want %s", counter, code, want)
+		}
+	}
+}
+
+func TestGetTotpCode(t *testing.T) {
+	// RFC 6238, appendix B for SHA1. RFC codes have 8 digits,
+	// apps show last 6 of them
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, test := range tests {
+		code, err := GetTotpCode(RFC_TEST_SECRET, time.Unix(test.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.code {
+			t.Errorf("GetTotpCode(%d) = %s, want %s", test.unix, code, test.code)
+		}
+	}
+}
+
+func TestCheckTotpCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	if !CheckTotpCode(RFC_TEST_SECRET, "050 471", now) {
+		t.Error("code with space is not accepted")
+	}
+	if !CheckTotpCode(RFC_TEST_SECRET, "050471", now.Add(TOTP_PERIOD)) {
+		t.Error("code of previous period is not accepted")
+	}
+	if CheckTotpCode(RFC_TEST_SECRET, "050471", now.Add(3*TOTP_PERIOD)) {
+		t.Error("outdated code is accepted")
+	}
+	if CheckTotpCode("not base32!", "050471", now) {
+		t.Error("code of invalid secret is accepted")
+	}
+}
@@ -7,7 +7,7 @@ require (
 	github.com/Masterminds/squirrel v1.4.0
 	github.com/google/uuid v1.1.2
 	github.com/googollee/go-socket.io v1.4.4 // indirect
-	github.com/gorilla/websocket v1.4.2 // indirect
+	github.com/gorilla/websocket v1.4.2
 	github.com/graarh/golang-socketio v0.0.0-20170510162725-2c44953b9b5f
 	github.com/mattn/go-sqlite3 v1.14.5
 	github.com/satori/go.uuid v1.2.0
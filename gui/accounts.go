@@ -0,0 +1,76 @@
+// accounts.go
+package gui
+
+import (
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+)
+
+func (gui *ChatGui) SetOnSwitchAccount(onSwitchAccount func(string)) {
+	gui.OnSwitchAccount = func(username string) {
+		gui.dispatch(func() { onSwitchAccount(username) })
+	}
+}
+
+func (gui *ChatGui) SetOnRemoveAccount(onRemoveAccount func(string)) {
+	gui.OnRemoveAccount = func(username string) {
+		gui.dispatch(func() { onRemoveAccount(username) })
+	}
+}
+
+func (gui *ChatGui) SetAccounts(accounts []string, activeAccount string) {
+	// saved accounts of active server
+	gui.Accounts = accounts
+	gui.ActiveAccount = activeAccount
+}
+
+func (gui *ChatGui) ShowAccountsDialog() {
+	// switches between saved accounts of server without restart
+	var accountsDialog dialog.Dialog
+	rows := container.NewVBox()
+	for _, account := range gui.Accounts {
+		account := account
+		caption := account
+		if account == gui.ActiveAccount {
+			caption = i18n.Tf("%s (active)", account)
+		}
+		switchButton := widget.NewButton(i18n.T("Switch"), func() {
+			accountsDialog.Hide()
+			gui.OnSwitchAccount(account)
+		})
+		if account == gui.ActiveAccount && gui.CurrentUser.Id != 0 {
+			switchButton.Disable()
+		}
+		removeButton := widget.NewButton(i18n.T("Remove"), func() {
+			accountsDialog.Hide()
+			gui.removeAccount(account)
+		})
+		rows.AddObject(container.NewHBox(widget.NewLabel(caption), layout.NewSpacer(),
+			switchButton, removeButton))
+	}
+	if len(gui.Accounts) == 0 {
+		rows.AddObject(widget.NewLabel(i18n.T("No saved accounts.")))
+	}
+	rows.AddObject(widget.NewButton(i18n.T("Add account"), func() {
+		accountsDialog.Hide()
+		gui.OnSwitchAccount("")
+	}))
+	scroller := widget.NewVScrollContainer(rows)
+	scroller.SetMinSize(fyne.NewSize(350, 200))
+	accountsDialog = dialog.NewCustom(i18n.T("Accounts"), i18n.T("Close"), scroller, gui.Window)
+	accountsDialog.Show()
+}
+
+func (gui *ChatGui) removeAccount(account string) {
+	dialog.ShowConfirm(i18n.T("Remove account"),
+		i18n.Tf("Remove saved account %s from this client?", account), func(result bool) {
+			if result {
+				gui.OnRemoveAccount(account)
+			}
+		}, gui.Window)
+}
@@ -0,0 +1,355 @@
+// auth_dialogs.go
+package gui
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"net/url"
+	"strings"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/canvas"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+	"chat/utils"
+)
+
+const AUTH_DIALOG_WIDTH int = 400
+
+func (gui *ChatGui) showFormPopup(title string, content fyne.CanvasObject,
+	submitText string, validate func() error, onSubmit func()) func() {
+	// shows modal form which can't be submitted while its data is invalid.
+	// Returned function validates data again and shows error under form
+	titleLabel := widget.NewLabel(title)
+	titleLabel.Wrapping = fyne.TextWrapWord
+	errorLabel := widget.NewLabel("")
+	errorLabel.Wrapping = fyne.TextWrapWord
+
+	var popup *widget.PopUp
+	submitButton := widget.NewButton(submitText, func() {
+		popup.Hide()
+		onSubmit()
+	})
+	cancelButton := widget.NewButton(i18n.T("Cancel"), func() {
+		popup.Hide()
+	})
+	update := func(showError bool) {
+		err := validate()
+		if utils.IsError(err) {
+			submitButton.Disable()
+		} else {
+			submitButton.Enable()
+		}
+		if utils.IsError(err) && showError {
+			errorLabel.SetText(err.Error())
+		} else {
+			errorLabel.SetText("")
+		}
+	}
+
+	buttons := container.NewHBox(submitButton, cancelButton)
+	popup = widget.NewModalPopUp(container.NewVBox(titleLabel, content, errorLabel, buttons),
+		gui.Window.Canvas())
+	update(false) // errors aren't shown before input
+	popup.Resize(fyne.NewSize(AUTH_DIALOG_WIDTH, popup.MinSize().Height))
+	popup.Show()
+	return func() {
+		update(true)
+	}
+}
+
+func (gui *ChatGui) ShowLoginDialog(title string) {
+	// creates and shows child window with login form
+	inputUsername := widget.NewEntry()
+	inputUsername.SetPlaceHolder(i18n.T("username"))
+	inputPassword := widget.NewPasswordEntry()
+	inputPassword.SetPlaceHolder(i18n.T("password"))
+
+	validate := func() error {
+		if inputUsername.Text == "" || inputPassword.Text == "" {
+			return errors.New(i18n.T("Enter username and password."))
+		}
+		return nil
+	}
+	// session of not remembered login ends on exit
+	rememberCheck := widget.NewCheck(i18n.T("Remember me"), nil)
+	rememberCheck.SetChecked(true)
+	content := container.NewVBox(inputUsername, inputPassword, rememberCheck)
+	if gui.RememberError != "" {
+		rememberCheck.SetChecked(false)
+		rememberCheck.Disable()
+		rememberLabel := widget.NewLabel(i18n.T(gui.RememberError))
+		rememberLabel.Wrapping = fyne.TextWrapWord
+		content.Add(rememberLabel)
+	}
+	for _, provider := range gui.OAuthProviders {
+		provider := provider
+		content.Add(widget.NewButton(i18n.Tf("Log in with %s", provider.Name), func() {
+			// login continues in browser, form isn't needed
+			if overlay := gui.Window.Canvas().Overlays().Top(); overlay != nil {
+				overlay.Hide()
+			}
+			if gui.OnOAuthLogin != nil {
+				gui.OnOAuthLogin(provider, rememberCheck.Checked)
+			}
+		}))
+	}
+	update := gui.showFormPopup(title, content, i18n.T("Login"), validate, func() {
+		gui.OnLoginSubmit(inputUsername.Text, inputPassword.Text, rememberCheck.Checked)
+	})
+	inputUsername.OnChanged = func(string) { update() }
+	inputPassword.OnChanged = func(string) { update() }
+	gui.Window.Canvas().Focus(inputUsername)
+}
+
+func (gui *ChatGui) SetRememberError(text string) {
+	// "Remember me" of login form is disabled and text is shown under it
+	gui.RememberError = text
+}
+
+func (gui *ChatGui) SetOnOAuthLogin(onOAuthLogin func(models.OAuthProvider, bool)) {
+	gui.OnOAuthLogin = func(provider models.OAuthProvider, remember bool) {
+		gui.dispatch(func() { onOAuthLogin(provider, remember) })
+	}
+}
+
+func (gui *ChatGui) SetOAuthProviders(providers []models.OAuthProvider) {
+	// buttons of providers are added to login form
+	gui.OAuthProviders = providers
+}
+
+func (gui *ChatGui) OpenUrl(link string) error {
+	// opens page in default browser of system
+	parsedUrl, err := url.Parse(link)
+	if utils.IsError(err) {
+		return err
+	}
+	return gui.App.OpenURL(parsedUrl)
+}
+
+func (gui *ChatGui) SetOnRequestChallenge(onRequestChallenge func()) {
+	gui.OnRequestChallenge = func() { gui.dispatch(onRequestChallenge) }
+}
+
+func (gui *ChatGui) SetRegistrationChallenge(challenge models.RegistrationChallenge) {
+	// shows received challenge in registration form. Proof of work is
+	// found by client, captcha is solved by user
+	if gui.ChallengeBox == nil {
+		return
+	}
+	gui.ChallengeBox.Objects = nil
+	switch challenge.Kind {
+	case models.CHALLENGE_CAPTCHA:
+		img, err := png.Decode(bytes.NewReader(challenge.Image))
+		if utils.IsError(err) {
+			gui.ShowError("Can't show captcha of server.")
+			return
+		}
+		captcha := canvas.NewImageFromImage(img)
+		captcha.FillMode = canvas.ImageFillOriginal
+		gui.challengeEntry = widget.NewEntry()
+		gui.challengeEntry.SetPlaceHolder(i18n.T("digits from image"))
+		gui.challengeEntry.OnChanged = func(string) { gui.updateRegistration() }
+		gui.ChallengeBox.Add(captcha)
+		gui.ChallengeBox.Add(gui.challengeEntry)
+	case models.CHALLENGE_POW:
+		gui.ChallengeBox.Add(widget.NewLabel(
+			i18n.T("Device will solve challenge of server, it takes a few seconds.")))
+	}
+	gui.challengePending = false
+	gui.ChallengeBox.Refresh()
+	if popup := gui.Window.Canvas().Overlays().Top(); popup != nil {
+		popup.Resize(fyne.NewSize(AUTH_DIALOG_WIDTH, popup.MinSize().Height))
+	}
+	gui.updateRegistration()
+}
+
+func (gui *ChatGui) ShowRegisterDialog(title string) {
+	// creates and shows child window with registration form.
+	// Data is checked by rules of server before sending
+	inputUsername := widget.NewEntry()
+	inputUsername.SetPlaceHolder(i18n.T("username"))
+	inputPassword := widget.NewPasswordEntry()
+	inputPassword.SetPlaceHolder(i18n.T("password"))
+	inputConfirm := widget.NewPasswordEntry()
+	inputConfirm.SetPlaceHolder(i18n.T("confirm password"))
+
+	strengthBar := widget.NewProgressBar()
+	strengthBar.Max = float64(utils.MAX_PASSWORD_STRENGTH)
+	strengthBar.TextFormatter = func() string {
+		strength := utils.GetPasswordStrength(inputPassword.Text)
+		return i18n.Tf("Password strength: %s", i18n.T(utils.PASSWORD_STRENGTH_NAMES[strength]))
+	}
+
+	validate := func() error {
+		err := utils.ValidateUsername(inputUsername.Text)
+		if !utils.IsError(err) {
+			err = utils.ValidatePassword(inputPassword.Text)
+		}
+		if !utils.IsError(err) && inputConfirm.Text != inputPassword.Text {
+			err = errors.New(i18n.T("Passwords don't match."))
+		}
+		if !utils.IsError(err) && gui.challengePending {
+			err = errors.New(i18n.T("Wait for challenge of server."))
+		}
+		if !utils.IsError(err) && gui.challengeEntry != nil &&
+			strings.TrimSpace(gui.challengeEntry.Text) == "" {
+			err = errors.New(i18n.T("Enter digits from image."))
+		}
+		return err
+	}
+
+	// server without challenges accepts registration at once
+	gui.ChallengeBox = container.NewVBox()
+	gui.challengeEntry = nil
+	gui.challengePending = gui.ServerFeatures[models.FEATURE_CHALLENGE] &&
+		gui.OnRequestChallenge != nil
+	if gui.challengePending {
+		gui.ChallengeBox.Add(widget.NewLabel(i18n.T("Loading challenge of server…")))
+	}
+	content := container.NewVBox(inputUsername, inputPassword, strengthBar, inputConfirm,
+		gui.ChallengeBox)
+	update := gui.showFormPopup(title, content, i18n.T("Register"), validate, func() {
+		answer := ""
+		if gui.challengeEntry != nil {
+			answer = strings.TrimSpace(gui.challengeEntry.Text)
+		}
+		gui.OnRegistratoinSubmit(inputUsername.Text, inputPassword.Text, answer)
+	})
+	gui.updateRegistration = update
+	if gui.challengePending {
+		gui.OnRequestChallenge()
+	}
+	inputUsername.OnChanged = func(string) { update() }
+	inputPassword.OnChanged = func(string) {
+		strengthBar.SetValue(float64(utils.GetPasswordStrength(inputPassword.Text)))
+		update()
+	}
+	inputConfirm.OnChanged = func(string) { update() }
+	gui.Window.Canvas().Focus(inputUsername)
+}
+
+func (gui *ChatGui) canChangeAccount() bool {
+	// account can be changed only by logged in user on server with this feature
+	if gui.CurrentUser.Id == 0 {
+		gui.ShowError("Please, log in first.")
+		return false
+	}
+	if !gui.ServerFeatures[models.FEATURE_ACCOUNT] {
+		gui.ShowError("Server doesn't support changes of account.")
+		return false
+	}
+	return true
+}
+
+func (gui *ChatGui) ShowEditProfileDialog() {
+	// edits display name and status which are shown to other users
+	if !gui.canChangeAccount() {
+		return
+	}
+	if !gui.ServerFeatures[models.FEATURE_PROFILES] {
+		gui.ShowError("Server doesn't support profiles.")
+		return
+	}
+	profile := gui.Profiles[gui.CurrentUser.Username]
+	inputDisplayName := widget.NewEntry()
+	inputDisplayName.SetPlaceHolder(i18n.Tf("display name (%s)", gui.CurrentUser.Username))
+	inputDisplayName.SetText(profile.DisplayName)
+	inputStatus := widget.NewEntry()
+	inputStatus.SetPlaceHolder(i18n.T("status"))
+	inputStatus.SetText(profile.StatusText)
+
+	validate := func() error {
+		return utils.ValidateProfile(inputDisplayName.Text, inputStatus.Text)
+	}
+	content := container.NewVBox(inputDisplayName, inputStatus)
+	update := gui.showFormPopup(i18n.T("Edit profile"), content, i18n.T("Save"), validate, func() {
+		if gui.OnSetProfile != nil {
+			gui.OnSetProfile(inputDisplayName.Text, inputStatus.Text)
+		}
+	})
+	inputDisplayName.OnChanged = func(string) { update() }
+	inputStatus.OnChanged = func(string) { update() }
+	gui.Window.Canvas().Focus(inputDisplayName)
+}
+
+func (gui *ChatGui) ShowChangePasswordDialog() {
+	// asks current password and new one. Server logs out all clients after change
+	if !gui.canChangeAccount() {
+		return
+	}
+	inputOldPassword := widget.NewPasswordEntry()
+	inputOldPassword.SetPlaceHolder(i18n.T("current password"))
+	inputPassword := widget.NewPasswordEntry()
+	inputPassword.SetPlaceHolder(i18n.T("new password"))
+	inputConfirm := widget.NewPasswordEntry()
+	inputConfirm.SetPlaceHolder(i18n.T("confirm new password"))
+
+	validate := func() error {
+		if inputOldPassword.Text == "" {
+			return errors.New(i18n.T("Enter current password."))
+		}
+		err := utils.ValidatePassword(inputPassword.Text)
+		if !utils.IsError(err) && inputConfirm.Text != inputPassword.Text {
+			err = errors.New(i18n.T("Passwords don't match."))
+		}
+		return err
+	}
+	content := container.NewVBox(inputOldPassword, inputPassword, inputConfirm)
+	update := gui.showFormPopup(i18n.T("Change password"), content, i18n.T("Change"), validate, func() {
+		if gui.OnChangePassword != nil {
+			gui.OnChangePassword(inputOldPassword.Text, inputPassword.Text)
+		}
+	})
+	inputOldPassword.OnChanged = func(string) { update() }
+	inputPassword.OnChanged = func(string) { update() }
+	inputConfirm.OnChanged = func(string) { update() }
+	gui.Window.Canvas().Focus(inputOldPassword)
+}
+
+func (gui *ChatGui) ShowDeleteAccountDialog() {
+	// asks password and confirmation of deletion which can't be undone
+	if !gui.canChangeAccount() {
+		return
+	}
+	inputPassword := widget.NewPasswordEntry()
+	inputPassword.SetPlaceHolder(i18n.T("password"))
+
+	validate := func() error {
+		if inputPassword.Text == "" {
+			return errors.New(i18n.T("Enter password."))
+		}
+		return nil
+	}
+	title := i18n.Tf("Delete account %s", gui.CurrentUser.Username)
+	update := gui.showFormPopup(title, inputPassword, i18n.T("Delete"), validate, func() {
+		dialog.ShowConfirm(i18n.T("Delete account"),
+			i18n.T("Delete account with all its messages and private chats? It can't be undone."),
+			func(confirmed bool) {
+				if confirmed && gui.OnDeleteAccount != nil {
+					gui.OnDeleteAccount(inputPassword.Text)
+				}
+			}, gui.Window)
+	})
+	inputPassword.OnChanged = func(string) { update() }
+	gui.Window.Canvas().Focus(inputPassword)
+}
+
+func (gui *ChatGui) logout() {
+	// logged out client shows login buttons like after start
+	if gui.CurrentUser.Id == 0 {
+		gui.ShowError("Please, log in first.")
+		return
+	}
+	dialog.ShowConfirm(i18n.T("Logout"), i18n.Tf("Log out from %s?", gui.CurrentUser.Username),
+		func(confirmed bool) {
+			if confirmed && gui.OnLogout != nil {
+				gui.OnLogout()
+			}
+		}, gui.Window)
+}
@@ -0,0 +1,161 @@
+// channel_list.go
+package gui
+
+import (
+	"fmt"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/canvas"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+
+	"chat/models"
+)
+
+type ChannelList struct {
+	container *fyne.Container
+	Titles    []string
+	Selected  string
+	Unread    map[string]int            // map: channel title -> unread messages count
+	Presence  map[string]string         // map: username -> presence state
+	Avatars   map[string]string         // map: username -> path of avatar
+	Profiles  map[string]models.Profile // map: username -> profile
+	OnSelect  func(title string)
+	// optional. Gets absolute position of right click
+	OnContextMenu func(title string, pos fyne.Position)
+	// optional. Called when unread counter of any channel is changed
+	OnUnreadChanged func()
+}
+
+// button of channel which also handles right click
+type channelButton struct {
+	widget.Button
+	TappedSecondaryFunc func(pos fyne.Position)
+}
+
+func newChannelButton(caption string, tapped func()) *channelButton {
+	button := &channelButton{}
+	button.Text = caption
+	button.OnTapped = tapped
+	button.ExtendBaseWidget(button)
+	return button
+}
+
+func (b *channelButton) TappedSecondary(ev *fyne.PointEvent) {
+	if b.TappedSecondaryFunc != nil {
+		b.TappedSecondaryFunc(ev.AbsolutePosition)
+	}
+}
+
+func NewChannelList(onSelect func(title string)) *ChannelList {
+	list := &ChannelList{
+		container: fyne.NewContainerWithLayout(layout.NewVBoxLayout()),
+		Unread:    make(map[string]int),
+		Presence:  make(map[string]string),
+		Avatars:   make(map[string]string),
+		Profiles:  make(map[string]models.Profile),
+		OnSelect:  onSelect}
+	return list
+}
+
+func (list *ChannelList) SetChannels(titles []string) {
+	list.Titles = titles
+	list.Refresh()
+}
+
+func (list *ChannelList) Append(title string) {
+	list.Titles = append(list.Titles, title)
+	list.Refresh()
+}
+
+func (list *ChannelList) Select(title string) {
+	// selects channel and clears its unread counter.
+	// OnSelect is called only if selection was changed
+	delete(list.Unread, title)
+	list.unreadChanged()
+	if list.Selected == title {
+		list.Refresh()
+		return
+	}
+	list.Selected = title
+	list.Refresh()
+
+	if list.OnSelect != nil {
+		list.OnSelect(title)
+	}
+}
+
+func (list *ChannelList) IncrementUnread(title string) {
+	list.Unread[title]++
+	list.Refresh()
+	list.unreadChanged()
+}
+
+func (list *ChannelList) ClearUnread() {
+	list.Unread = make(map[string]int)
+	list.unreadChanged()
+}
+
+func (list *ChannelList) GetUnreadCount() int {
+	// returns unread messages count of all channels
+	count := 0
+	for _, channelCount := range list.Unread {
+		count += channelCount
+	}
+	return count
+}
+
+func (list *ChannelList) unreadChanged() {
+	if list.OnUnreadChanged != nil {
+		list.OnUnreadChanged()
+	}
+}
+
+func (list *ChannelList) getCaption(title string) string {
+	// private channels are named by display name of user
+	caption := getDisplayName(list.Profiles, title)
+	count := list.Unread[title]
+	if count == 0 {
+		return caption
+	}
+	return fmt.Sprintf("%s  (%d)", caption, count)
+}
+
+func (list *ChannelList) GetContainer() *fyne.Container {
+	return list.container
+}
+
+func (list *ChannelList) Refresh() {
+	// rebuilds channel buttons. Selected channel is highlighted.
+	// Private channels have avatar, presence dot and status of user
+	var objects []fyne.CanvasObject
+	for _, title := range list.Titles {
+		channelTitle := title
+		button := newChannelButton(list.getCaption(title), func() {
+			list.Select(channelTitle)
+		})
+		if list.OnContextMenu != nil {
+			button.TappedSecondaryFunc = func(pos fyne.Position) {
+				list.OnContextMenu(channelTitle, pos)
+			}
+		}
+		button.Alignment = widget.ButtonAlignLeading
+		if title == list.Selected {
+			button.Importance = widget.HighImportance
+		} else {
+			button.Importance = widget.LowImportance
+		}
+		state, hasPresence := list.Presence[title]
+		avatarPath, hasAvatar := list.Avatars[title]
+		profile, hasProfile := list.Profiles[title]
+		if hasPresence || hasAvatar || hasProfile { // private channel with user
+			statusText := canvas.NewText(profile.StatusText, msgPendingTextColor)
+			objects = append(objects, widget.NewHBox(NewAvatarImage(avatarPath).GetContainer(),
+				NewStatusDot(state).GetContainer(), button, statusText))
+		} else {
+			objects = append(objects, button)
+		}
+	}
+	list.container.Objects = objects
+	list.container.Refresh()
+}
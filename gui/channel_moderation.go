@@ -0,0 +1,105 @@
+// channel_moderation.go
+package gui
+
+import (
+	"fyne.io/fyne"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+)
+
+func (gui *ChatGui) SetOnKickMember(onKickMember func(models.User)) {
+	gui.OnKickMember = func(user models.User) {
+		gui.dispatch(func() { onKickMember(user) })
+	}
+}
+
+func (gui *ChatGui) SetOnSetMemberRole(onSetMemberRole func(models.User, string)) {
+	gui.OnSetMemberRole = func(user models.User, role string) {
+		gui.dispatch(func() { onSetMemberRole(user, role) })
+	}
+}
+
+func (gui *ChatGui) SetOnRenameChannel(onRenameChannel func(string, string)) {
+	gui.OnRenameChannel = func(title string, newTitle string) {
+		gui.dispatch(func() { onRenameChannel(title, newTitle) })
+	}
+}
+
+func (gui *ChatGui) canModerate() bool {
+	// current user is owner or admin of opened group.
+	// Role is known from members of channel
+	role := gui.MemberList.GetRole(gui.CurrentUser.Id)
+	return gui.ServerFeatures[models.FEATURE_ROLES] && models.IsModeratorRole(role)
+}
+
+func (gui *ChatGui) ShowMemberMenu(member models.ChannelMember, pos fyne.Position) {
+	// owner manages admins and removes anybody, admins remove plain members
+	role := gui.MemberList.GetRole(gui.CurrentUser.Id)
+	if !gui.canModerate() || member.User.Id == gui.CurrentUser.Id {
+		return
+	}
+	var items []*fyne.MenuItem
+	if role == models.ROLE_OWNER && gui.OnSetMemberRole != nil {
+		if member.Role == models.ROLE_MEMBER {
+			items = append(items, fyne.NewMenuItem(i18n.T("Make admin"), func() {
+				gui.OnSetMemberRole(member.User, models.ROLE_ADMIN)
+			}))
+		} else if member.Role == models.ROLE_ADMIN {
+			items = append(items, fyne.NewMenuItem(i18n.T("Remove admin"), func() {
+				gui.OnSetMemberRole(member.User, models.ROLE_MEMBER)
+			}))
+		}
+	}
+	canKick := member.Role == models.ROLE_MEMBER ||
+		member.Role == models.ROLE_ADMIN && role == models.ROLE_OWNER
+	if canKick && gui.OnKickMember != nil {
+		items = append(items, fyne.NewMenuItem(i18n.T("Remove from group"), func() {
+			gui.kickMember(member.User)
+		}))
+	}
+	if len(items) == 0 {
+		return
+	}
+	widget.ShowPopUpMenuAtPosition(fyne.NewMenu("", items...), gui.Window.Canvas(), pos)
+}
+
+func (gui *ChatGui) kickMember(user models.User) {
+	dialog.ShowConfirm(i18n.T("Remove member"),
+		i18n.Tf("Remove %s from group?", user.Username), func(result bool) {
+			if result {
+				gui.OnKickMember(user)
+			}
+		}, gui.Window)
+}
+
+func (gui *ChatGui) ShowRenameChannelDialog(title string) {
+	inputTitle := widget.NewEntry()
+	inputTitle.SetText(title)
+	dialog.ShowCustomConfirm(i18n.Tf("Rename %s", title), i18n.T("Rename"), i18n.T("Cancel"),
+		inputTitle, func(result bool) {
+			if result && inputTitle.Text != title {
+				gui.OnRenameChannel(title, inputTitle.Text)
+			}
+		}, gui.Window)
+}
+
+func (gui *ChatGui) RenameChannel(title string, newTitle string) {
+	// keeps selection and unread counter of renamed channel
+	list := gui.ChannelsList
+	for i, listedTitle := range list.Titles {
+		if listedTitle == title {
+			list.Titles[i] = newTitle
+		}
+	}
+	if count, ok := list.Unread[title]; ok {
+		delete(list.Unread, title)
+		list.Unread[newTitle] = count
+	}
+	if list.Selected == title {
+		list.Selected = newTitle
+	}
+	list.Refresh()
+}
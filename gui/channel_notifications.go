@@ -0,0 +1,165 @@
+// channel_notifications.go
+package gui
+
+import (
+	"io"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/storage"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/utils"
+)
+
+const NOTIFICATIONS_DEFAULT_OPTION = "default"
+
+func (gui *ChatGui) SetChannelNotifications(options map[string]utils.ChannelNotifications) {
+	// options are saved by client, gui only shows them. map: channel title -> options
+	gui.ChannelNotifications = options
+}
+
+func (gui *ChatGui) SetOnSetChannelNotifications(
+	onSetChannelNotifications func(string, utils.ChannelNotifications)) {
+	gui.OnSetChannelNotifications = func(title string, options utils.ChannelNotifications) {
+		gui.dispatch(func() { onSetChannelNotifications(title, options) })
+	}
+}
+
+func (gui *ChatGui) setChannelNotifications(title string, options utils.ChannelNotifications) {
+	if gui.OnSetChannelNotifications != nil {
+		gui.OnSetChannelNotifications(title, options)
+	}
+}
+
+func (gui *ChatGui) ShowChannelMenu(title string, pos fyne.Position) {
+	// shows context menu of channel list. Muted channel has no notifications
+	options := gui.ChannelNotifications[title]
+	muteItem := fyne.NewMenuItem(i18n.T("Mute"), func() {
+		options.Notifications = utils.NOTIFICATIONS_OFF
+		gui.setChannelNotifications(title, options)
+	})
+	if options.Notifications == utils.NOTIFICATIONS_OFF {
+		muteItem = fyne.NewMenuItem(i18n.T("Unmute"), func() {
+			options.Notifications = ""
+			gui.setChannelNotifications(title, options)
+		})
+	}
+	items := []*fyne.MenuItem{muteItem, fyne.NewMenuItem(i18n.T("Notifications..."), func() {
+		gui.ShowChannelNotificationsDialog(title)
+	})}
+	if gui.OnExportChat != nil {
+		items = append(items, fyne.NewMenuItem(i18n.T("Export chat..."), func() {
+			gui.ShowExportChatDialog(title)
+		}))
+	}
+	if title == gui.ChannelsList.Selected && gui.canModerate() && gui.OnRenameChannel != nil {
+		items = append(items, fyne.NewMenuItem(i18n.T("Rename..."), func() {
+			gui.ShowRenameChannelDialog(title)
+		}))
+	}
+	if gui.hasInvitations() && gui.OnInviteToChannel != nil {
+		items = append(items, fyne.NewMenuItem(i18n.T("Invite user..."), func() {
+			gui.ShowInviteUserDialog(title)
+		}))
+	}
+	if gui.OnImportChat != nil {
+		items = append(items, fyne.NewMenuItem(i18n.T("Import chat..."), func() {
+			gui.ShowImportChatDialog(title)
+		}))
+	}
+	widget.ShowPopUpMenuAtPosition(fyne.NewMenu("", items...), gui.Window.Canvas(), pos)
+}
+
+func (gui *ChatGui) SetOnExportChat(onExportChat func(string, string, io.WriteCloser)) {
+	gui.OnExportChat = func(title string, format string, writer io.WriteCloser) {
+		gui.dispatch(func() { onExportChat(title, format, writer) })
+	}
+}
+
+func (gui *ChatGui) ShowExportChatDialog(title string) {
+	// chooses format of exported history, then asks where to save it
+	formats := widget.NewRadioGroup(utils.EXPORT_FORMATS, nil)
+	formats.SetSelected(utils.EXPORT_JSON)
+	dialog.ShowCustomConfirm(i18n.Tf("Export %s", title), i18n.T("Export"), i18n.T("Cancel"), formats,
+		func(result bool) {
+			if !result || formats.Selected == "" {
+				return
+			}
+			format := formats.Selected
+			dialog.ShowFileSave(func(writer fyne.URIWriteCloser, err error) {
+				if err != nil {
+					gui.ShowError(err.Error())
+					return
+				}
+				if writer == nil { // canceled
+					return
+				}
+				gui.OnExportChat(title, format, writer)
+			}, gui.Window)
+		}, gui.Window)
+}
+
+func (gui *ChatGui) SetOnImportChat(onImportChat func(string, io.ReadCloser, bool)) {
+	gui.OnImportChat = func(title string, reader io.ReadCloser, toNotes bool) {
+		gui.dispatch(func() { onImportChat(title, reader, toNotes) })
+	}
+}
+
+func (gui *ChatGui) ShowImportChatDialog(title string) {
+	// asks for chat exported to json. Imported messages can be copied to notes
+	notesCheck := widget.NewCheck(i18n.T("Copy messages to notes"), nil)
+	content := container.NewVBox(
+		widget.NewLabel(i18n.T("Messages of exported file are added to saved history.")), notesCheck)
+	dialog.ShowCustomConfirm(i18n.Tf("Import %s", title), i18n.T("Choose file"), i18n.T("Cancel"),
+		content, func(result bool) {
+			if !result {
+				return
+			}
+			toNotes := notesCheck.Checked
+			fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+				if err != nil {
+					gui.ShowError(err.Error())
+					return
+				}
+				if reader == nil { // canceled
+					return
+				}
+				gui.OnImportChat(title, reader, toNotes)
+			}, gui.Window)
+			fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+			fileDialog.Show()
+		}, gui.Window)
+}
+
+func (gui *ChatGui) ShowChannelNotificationsDialog(title string) {
+	// chooses notifications mode of channel. Default mode is set in settings
+	options := gui.ChannelNotifications[title]
+	modes := widget.NewRadioGroup([]string{NOTIFICATIONS_DEFAULT_OPTION, utils.NOTIFICATIONS_ALL,
+		utils.NOTIFICATIONS_MENTIONS, utils.NOTIFICATIONS_OFF}, nil)
+	modes.SetSelected(NOTIFICATIONS_DEFAULT_OPTION)
+	if options.Notifications != "" {
+		modes.SetSelected(options.Notifications)
+	}
+	hideUnreadCheck := widget.NewCheck(i18n.T("Hide unread count"), nil)
+	hideUnreadCheck.SetChecked(options.HideUnread)
+	noSoundCheck := widget.NewCheck(i18n.T("Disable sounds"), nil)
+	noSoundCheck.SetChecked(options.NoSound)
+
+	content := container.NewVBox(modes, hideUnreadCheck, noSoundCheck)
+	dialog.ShowCustomConfirm(i18n.Tf("Notifications of %s", title), i18n.T("Save"), i18n.T("Cancel"), content,
+		func(result bool) {
+			if !result || modes.Selected == "" {
+				return
+			}
+			options.Notifications = modes.Selected
+			if modes.Selected == NOTIFICATIONS_DEFAULT_OPTION {
+				options.Notifications = ""
+			}
+			options.HideUnread = hideUnreadCheck.Checked
+			options.NoSound = noSoundCheck.Checked
+			gui.setChannelNotifications(title, options)
+		}, gui.Window)
+}
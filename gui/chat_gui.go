@@ -2,62 +2,246 @@
 package gui
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
-
-	"log"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"fyne.io/fyne"
 	"fyne.io/fyne/app"
+	"fyne.io/fyne/canvas"
 	"fyne.io/fyne/container"
 	"fyne.io/fyne/dialog"
 	"fyne.io/fyne/layout"
+	"fyne.io/fyne/storage"
 	"fyne.io/fyne/widget"
 
+	"chat/i18n"
+	"chat/logger"
 	"chat/models"
+	"chat/utils"
 )
 
 const WIDTH int = 1280
 const HEIGHT int = 720
 
+const WINDOW_TITLE = "Golang chat"
 const GROUP_CHANNEL_TITLE = "MAIN"
 const NOTES_CHANNEL_TITLE = "NOTES"
 
+const TYPING_DISPLAY_TIMEOUT = 5 * time.Second
+const NOTIFICATION_SNIPPET_LENGTH int = 100
+const MAX_INPUT_ROWS int = 5 // longer messages are scrolled in input
+
 type ChatGui struct {
 	App                 fyne.App
 	Window              fyne.Window
 	LeftSideBar         *widget.Group
+	LeftSplit           *widget.SplitContainer // left sidebar and rest of window
+	RightSplit          *widget.SplitContainer // messenger and right sidebar
 	MessagesList        *MessageList
-	MessageListScroller *widget.ScrollContainer
+	MessageListScroller *MessageScroller
+
+	SendButton       *widget.Button
+	SendLaterButton  *widget.Button
+	MessageInput     *EnterEntry
+	SearchInput      *EnterEntry
+	AttachButton     *widget.Button
+	LoginButton      *widget.Button
+	RegisterButton   *widget.Button
+	LogoutButton     *widget.Button // enabled while user is logged in
+	ProfileInfo      *widget.Label
+	TypingLabel      *widget.Label
+	ChannelsList     *ChannelList
+	MemberList       *MemberList
+	MembersPanel     *widget.Accordion
+	ContactList      *ContactList
+	ContactsPanel    *widget.Accordion
+	QuickSwitcher    *QuickSwitcher
+	SuggestionPopup  *widget.PopUp
+	SearchResults    *SearchResults
+	ConnectionStatus *ConnectionStatus
+
+	CurrentUser    models.User
+	RecentChannels []string
+	KnownUsers     map[int64]models.User       // authors of received messages
+	Shortcuts      fyne.ShortcutHandler        // global shortcuts for focused widgets
+	TypingUsers    map[string]time.Time        // map: username -> last typing time
+	Presence       map[string]string           // map: username -> presence state
+	Avatars        map[string]string           // map: username -> path of cached avatar
+	Profiles       map[string]models.Profile   // map: username -> display name and status
+	ServerFeatures map[string]bool             // optional features supported by server
+	OAuthProviders []models.OAuthProvider      // login providers listed in hello of server
+	RememberError  string                      // why session can't be remembered, empty if it can
+	IsHidden       bool                        // window is closed, but chat stays connected
+	Commands       []utils.SlashCommand        // slash commands suggested while typed
+	UserFilters    map[int64]models.UserFilter // map: user id -> blocked or muted user
+	typingLock     sync.Mutex
+	settingInput   bool       // input text is changed by program, not by user
+	defaultTheme   fyne.Theme // theme chosen by fyne before settings are applied
+
+	// map: channel title -> notification options which differ from settings
+	ChannelNotifications map[string]utils.ChannelNotifications
+
+	ReplyBar       *fyne.Container // shown above input while reply is composed
+	ReplyLabel     *widget.Label
+	ReplyMessageId int64 // 0 if new message isn't reply
+
+	PinnedPanel    *widget.Accordion // hidden if opened channel has no pinned messages
+	PinnedList     *fyne.Container
+	PinnedMessages []models.SavedMessage
+	CanPin         bool // current user can pin messages of opened channel
+
+	NewMessagesButton *widget.Button // shown if messages arrived below view
+	NewMessagesCount  int
+
+	UserSearchDialog  dialog.Dialog   // nil if find user dialog is closed
+	UserSearchResults *fyne.Container // found users of opened dialog
+	UserSearchQuery   string          // results of other queries are skipped
+
+	RecordButton   *widget.Button
+	RecordingStart time.Time // zero if voice message isn't recorded
 
-	SendButton         *widget.Button
-	LoginButton        *widget.Button
-	RegisterButton     *widget.Button
-	ProfileInfo        *widget.Label
-	ChannelsRadioGroup *widget.RadioGroup
+	SendEnabled    bool          // send is allowed by client, may be paused by rate limit
+	RateLimitLabel *widget.Label // shown above input while messages can't be sent
+	RateLimitEnd   time.Time     // zero if server doesn't limit messages
+
+	InvitationsButton *widget.Button  // hidden if nothing waits for answer
+	InvitationsList   *fyne.Container // nil if inbox dialog is closed
+	Invitations       []models.Invitation
+
+	SpellChecker  *utils.SpellChecker // nil if spell checking is disabled
+	SpellingLabel *widget.Label
+
+	DebugLogWindow fyne.Window // nil if debug log is closed
+
+	Accounts      []string // saved accounts of active server
+	ActiveAccount string
+
+	ScheduledList     *fyne.Container // nil if dialog of scheduled messages is closed
+	ScheduledMessages []models.ScheduledMessage
+
+	DoNotDisturbCheck   *widget.Check
+	settingDoNotDisturb bool // check is changed by client, so toggle isn't reported
+
+	TwoFactorEnabled bool // of current account
+
+	ChallengeBox       *fyne.Container // content of challenge in last registration form
+	challengeEntry     *widget.Entry   // answer of captcha, nil for other challenges
+	challengePending   bool            // challenge is requested, but isn't received yet
+	updateRegistration func()          // validates registration form again
+
+	// callbacks are passed to it, so client handles them one by one with
+	// events of socket. They are called at once while it isn't set
+	Dispatch func(f func())
 
 	OnGroupChannelSelect func()
 	OnNotesChannelSelect func()
 	OnChannelSelect      func(channelTitle string)
 	OnUsernameSelect     func(models.User)
+	OnCreateChannel      func(title string, members []models.User)
+
+	OnSendClick          func(messageText string)
+	OnSendReply          func(messageText string, replyToId int64)
+	OnTyping             func()
+	OnLoadOlderMessages  func()
+	OnSearchMessages     func(query string)
+	OnSearchResultSelect func(msg models.SavedMessage)
+	OnAttachFile         func(reader io.ReadCloser, fileName string)
+	OnDownloadAttachment func(attachment models.Attachment, writer io.WriteCloser)
+	OnLoadImagePreview   func(ctx context.Context, msg models.SavedMessage, onLoaded func(path string))
+	OnLoadLinkPreview    func(ctx context.Context, msg models.SavedMessage, onLoaded func(models.LinkPreview))
+	OnEditMessage        func(messageId int64, text string)
+	OnDeleteMessage      func(messageId int64)
+	OnReact              func(messageId int64, emoji string)
+	OnPinMessage         func(messageId int64, isPinned bool)
+	OnReport             func(report models.Report)
+	OnForwardMessage     func(messageId int64, channelTitle string)
+	OnRetryMessage       func(localId int64)
 
-	OnSendClick func(messageText string)
+	OnLoginSubmit        func(username string, password string, remember bool)
+	OnRegistratoinSubmit func(username string, password string, answer string)
+	OnChangePassword     func(oldPassword string, newPassword string)
+	OnDeleteAccount      func(password string)
+	OnLogout             func()
+	OnSetAvatar          func(reader io.ReadCloser)
+	OnSetProfile         func(displayName string, statusText string)
+	OnUserShown          func(user models.User) // avatar and profile of user are needed
+	OnSetUserFilter      func(filter models.UserFilter)
+	OnSaveSettings       func(settings utils.Settings, reconnect bool)
+	OnTestConnection     func(hostData utils.HostData, onResult func(string, error))
+	OnSwitchServer       func(profileName string)
+	OnSwitchAccount      func(username string) // empty username adds account
+	OnRemoveAccount      func(username string)
+	OnRetryConnection    func()
+	OnSaveWindowState    func(state utils.WindowState)
+	OnClose              func()
 
-	OnLoginSubmit        func(username string, password string)
-	OnRegistratoinSubmit func(username string, password string)
+	OnSetChannelNotifications func(title string, options utils.ChannelNotifications)
+	OnExportChat              func(title string, format string, writer io.WriteCloser)
+	OnStartRecording          func() bool // false if recording can't be started
+	OnStopRecording           func()
+	OnPlayVoice               func(attachment models.Attachment, onFinished func())
+	OnStopVoice               func()
+	OnAddContact              func(user models.User)
+	OnRemoveContact           func(user models.User)
+	OnSearchUsers             func(query string)
+	OnImportChat              func(title string, reader io.ReadCloser, toNotes bool)
+	OnInviteToChannel         func(title string, user models.User)
+	OnJoinChannel             func(title string)
+	OnAnswerInvitation        func(invitationId int64, isAccepted bool)
+	OnKickMember              func(user models.User)
+	OnSetMemberRole           func(user models.User, role string)
+	OnRenameChannel           func(title string, newTitle string)
+	OnScheduleMessage         func(text string, replyToId int64, sendOn time.Time)
+	OnCancelScheduled         func(id int64)
+	OnLoadStickers            func(query string, onLoaded func([]models.Sticker))
+	OnSendSticker             func(sticker models.Sticker)
+	OnSetDoNotDisturb         func(enabled bool)
+	OnTwoFactorCodeSubmit     func(code string)
+	OnEnableTwoFactor         func(code string) // secret is requested by empty code
+	OnDisableTwoFactor        func(password string)
+	OnOAuthLogin              func(provider models.OAuthProvider, remember bool)
+	OnRequestChallenge        func()
 }
 
 func NewChatGui() *ChatGui {
+	return NewChatGuiWithApp(app.New())
+}
+
+func NewChatGuiWithApp(fyneApp fyne.App) *ChatGui {
+	// window is created in given app, so tests can use app of fyne test driver
 	gui := &ChatGui{}
+	gui.KnownUsers = make(map[int64]models.User)
+	gui.TypingUsers = make(map[string]time.Time)
+	gui.Presence = make(map[string]string)
+	gui.Avatars = make(map[string]string)
+	gui.Profiles = make(map[string]models.Profile)
+	gui.ServerFeatures = make(map[string]bool)
+	gui.UserFilters = make(map[int64]models.UserFilter)
+	gui.ChannelNotifications = make(map[string]utils.ChannelNotifications)
 
-	gui.App = app.New()
-	window := gui.App.NewWindow("Golang chat")
+	gui.App = fyneApp
+	gui.defaultTheme = gui.App.Settings().Theme()
+	window := gui.App.NewWindow(WINDOW_TITLE)
 	window.Resize(fyne.NewSize(WIDTH, HEIGHT))
 
 	window.SetContent(buildMainWindow(gui))
+	window.SetMainMenu(buildMainMenu(gui))
 	window.SetMaster()
+	window.SetOnClosed(gui.processClose)
+	window.SetCloseIntercept(gui.hideWindow)
 
 	gui.Window = window
+	buildShortcuts(gui)
 	return gui
 }
 
@@ -67,19 +251,185 @@ func (gui *ChatGui) SetCallbacks(
 	OnNotesChannelSelect func(),
 	OnChannelSelect func(string),
 	OnUsernameSelect func(models.User),
-	OnLoginSubmit func(string, string),
-	OnRegistratoinSubmit func(string, string)) {
-	gui.OnSendClick = OnSendClick
-	gui.OnGroupChannelSelect = OnGroupChannelSelect
-	gui.OnNotesChannelSelect = OnNotesChannelSelect
-	gui.OnChannelSelect = OnChannelSelect
-	gui.OnUsernameSelect = OnUsernameSelect
-	gui.OnLoginSubmit = OnLoginSubmit
-	gui.OnRegistratoinSubmit = OnRegistratoinSubmit
+	OnLoginSubmit func(string, string, bool),
+	OnRegistratoinSubmit func(string, string, string)) {
+	gui.OnSendClick = func(messageText string) {
+		gui.dispatch(func() { OnSendClick(messageText) })
+	}
+	gui.OnGroupChannelSelect = func() { gui.dispatch(OnGroupChannelSelect) }
+	gui.OnNotesChannelSelect = func() { gui.dispatch(OnNotesChannelSelect) }
+	gui.OnChannelSelect = func(channelTitle string) {
+		gui.dispatch(func() { OnChannelSelect(channelTitle) })
+	}
+	gui.OnUsernameSelect = func(user models.User) {
+		gui.dispatch(func() { OnUsernameSelect(user) })
+	}
+	gui.OnLoginSubmit = func(username string, password string, remember bool) {
+		gui.dispatch(func() { OnLoginSubmit(username, password, remember) })
+	}
+	gui.OnRegistratoinSubmit = func(username string, password string, answer string) {
+		gui.dispatch(func() { OnRegistratoinSubmit(username, password, answer) })
+	}
+}
+
+func (gui *ChatGui) SetDispatcher(dispatch func(f func())) {
+	// set before callbacks. Callbacks which return result or have to finish
+	// before window is closed are called directly
+	gui.Dispatch = dispatch
+}
+
+func (gui *ChatGui) dispatch(callback func()) {
+	if gui.Dispatch == nil {
+		callback()
+		return
+	}
+	gui.Dispatch(callback)
+}
+
+func (gui *ChatGui) SetOnTyping(onTyping func()) {
+	gui.OnTyping = func() { gui.dispatch(onTyping) }
+}
+
+func (gui *ChatGui) SetOnLoadOlderMessages(onLoadOlderMessages func()) {
+	gui.OnLoadOlderMessages = func() { gui.dispatch(onLoadOlderMessages) }
+}
+
+func (gui *ChatGui) SetOnAttachFile(onAttachFile func(io.ReadCloser, string)) {
+	gui.OnAttachFile = func(reader io.ReadCloser, fileName string) {
+		gui.dispatch(func() { onAttachFile(reader, fileName) })
+	}
+}
+
+func (gui *ChatGui) SetOnDownloadAttachment(
+	onDownloadAttachment func(models.Attachment, io.WriteCloser)) {
+	gui.OnDownloadAttachment = func(attachment models.Attachment, writer io.WriteCloser) {
+		gui.dispatch(func() { onDownloadAttachment(attachment, writer) })
+	}
+}
+
+func (gui *ChatGui) SetOnLoadImagePreview(
+	onLoadImagePreview func(context.Context, models.SavedMessage, func(string))) {
+	gui.OnLoadImagePreview = func(ctx context.Context, msg models.SavedMessage,
+		onLoaded func(string)) {
+		gui.dispatch(func() { onLoadImagePreview(ctx, msg, onLoaded) })
+	}
+}
+
+func (gui *ChatGui) SetOnLoadLinkPreview(
+	onLoadLinkPreview func(context.Context, models.SavedMessage, func(models.LinkPreview))) {
+	gui.OnLoadLinkPreview = func(ctx context.Context, msg models.SavedMessage,
+		onLoaded func(models.LinkPreview)) {
+		gui.dispatch(func() { onLoadLinkPreview(ctx, msg, onLoaded) })
+	}
+}
+
+func (gui *ChatGui) SetOnEditMessage(onEditMessage func(int64, string)) {
+	gui.OnEditMessage = func(messageId int64, text string) {
+		gui.dispatch(func() { onEditMessage(messageId, text) })
+	}
+}
+
+func (gui *ChatGui) SetOnDeleteMessage(onDeleteMessage func(int64)) {
+	gui.OnDeleteMessage = func(messageId int64) {
+		gui.dispatch(func() { onDeleteMessage(messageId) })
+	}
+}
+
+func (gui *ChatGui) SetOnReact(onReact func(int64, string)) {
+	gui.OnReact = func(messageId int64, emoji string) {
+		gui.dispatch(func() { onReact(messageId, emoji) })
+	}
+}
+
+func (gui *ChatGui) SetOnSendReply(onSendReply func(string, int64)) {
+	gui.OnSendReply = func(text string, replyToId int64) {
+		gui.dispatch(func() { onSendReply(text, replyToId) })
+	}
+}
+
+func (gui *ChatGui) SetOnCreateChannel(onCreateChannel func(string, []models.User)) {
+	gui.OnCreateChannel = func(title string, members []models.User) {
+		gui.dispatch(func() { onCreateChannel(title, members) })
+	}
+}
+
+func (gui *ChatGui) SetOnSearchMessages(onSearchMessages func(string)) {
+	gui.OnSearchMessages = func(query string) {
+		gui.dispatch(func() { onSearchMessages(query) })
+	}
+}
+
+func (gui *ChatGui) SetOnSearchResultSelect(onSearchResultSelect func(models.SavedMessage)) {
+	gui.OnSearchResultSelect = func(msg models.SavedMessage) {
+		gui.dispatch(func() { onSearchResultSelect(msg) })
+	}
+}
+
+func (gui *ChatGui) SetOnChangePassword(onChangePassword func(string, string)) {
+	gui.OnChangePassword = func(oldPassword string, newPassword string) {
+		gui.dispatch(func() { onChangePassword(oldPassword, newPassword) })
+	}
+}
+
+func (gui *ChatGui) SetOnDeleteAccount(onDeleteAccount func(string)) {
+	gui.OnDeleteAccount = func(password string) {
+		gui.dispatch(func() { onDeleteAccount(password) })
+	}
+}
+
+func (gui *ChatGui) SetOnLogout(onLogout func()) {
+	gui.OnLogout = func() { gui.dispatch(onLogout) }
+}
+
+func (gui *ChatGui) SetOnSetAvatar(onSetAvatar func(io.ReadCloser)) {
+	gui.OnSetAvatar = func(reader io.ReadCloser) {
+		gui.dispatch(func() { onSetAvatar(reader) })
+	}
+}
+
+func (gui *ChatGui) SetOnSetProfile(onSetProfile func(string, string)) {
+	gui.OnSetProfile = func(displayName string, statusText string) {
+		gui.dispatch(func() { onSetProfile(displayName, statusText) })
+	}
+}
+
+func (gui *ChatGui) SetOnUserShown(onUserShown func(models.User)) {
+	gui.OnUserShown = func(user models.User) {
+		gui.dispatch(func() { onUserShown(user) })
+	}
+}
+
+func (gui *ChatGui) SetOnSaveSettings(onSaveSettings func(utils.Settings, bool)) {
+	gui.OnSaveSettings = func(settings utils.Settings, reconnect bool) {
+		gui.dispatch(func() { onSaveSettings(settings, reconnect) })
+	}
+}
+
+func (gui *ChatGui) SetOnTestConnection(onTestConnection func(utils.HostData,
+	func(string, error))) {
+	gui.OnTestConnection = func(hostData utils.HostData, onResult func(string, error)) {
+		gui.dispatch(func() { onTestConnection(hostData, onResult) })
+	}
+}
+
+func (gui *ChatGui) SetOnSwitchServer(onSwitchServer func(string)) {
+	gui.OnSwitchServer = func(profileName string) {
+		gui.dispatch(func() { onSwitchServer(profileName) })
+	}
+}
+
+func (gui *ChatGui) SetOnRetryConnection(onRetryConnection func()) {
+	gui.OnRetryConnection = func() { gui.dispatch(onRetryConnection) }
 }
 
 func (gui *ChatGui) SetOnClose(onClose func()) {
-	gui.Window.SetOnClosed(onClose)
+	gui.OnClose = onClose
+}
+
+func (gui *ChatGui) AddShortcut(shortcut fyne.Shortcut, handler func(fyne.Shortcut)) {
+	// registers shortcut that works regardless of focused widget
+	gui.Window.Canvas().AddShortcut(shortcut, handler)
+	gui.Shortcuts.AddShortcut(shortcut, handler)
 }
 
 func (gui *ChatGui) ShowWindow() {
@@ -87,34 +437,180 @@ func (gui *ChatGui) ShowWindow() {
 	gui.Window.ShowAndRun()
 }
 
+func (gui *ChatGui) RaiseWindow() {
+	// brings main window to front, e.g. when notifier opens chat
+	gui.IsHidden = false
+	gui.Window.Show()
+	gui.Window.RequestFocus()
+}
+
+func (gui *ChatGui) hideWindow() {
+	// closed window is hidden, so messages are still received and notified.
+	// It's shown again by click of notification or by next start of client
+	err := utils.ShowDesktopNotification(WINDOW_TITLE, i18n.T("Chat keeps running. Click here or "+
+		"start it again to open window, quit it by menu Server → Quit."), gui.RaiseWindow)
+	if utils.IsError(err) {
+		logger.Warning("Can't show desktop notification, chat is quit: " + err.Error())
+		gui.quit()
+		return
+	}
+	gui.IsHidden = true
+	gui.Window.Hide()
+}
+
+func (gui *ChatGui) quit() {
+	// closing of master window calls handler of close and stops application
+	gui.Window.Close()
+}
+
 func (gui *ChatGui) ShowInfo(text string) {
-	dialog.ShowInformation("INFO", text, gui.Window)
+	dialog.ShowInformation(i18n.T("INFO"), i18n.T(text), gui.Window)
+}
+
+func (gui *ChatGui) ShowNotification(username string, text string) {
+	// sends system notification with beginning of message text.
+	// Account is named if several accounts are saved
+	snippet := getTextSnippet(text, NOTIFICATION_SNIPPET_LENGTH)
+	title := username
+	if len(gui.Accounts) > 1 {
+		title = i18n.Tf("%s to %s", username, gui.CurrentUser.Username)
+	}
+	if gui.IsHidden { // notification of system shows hidden window by click
+		err := utils.ShowDesktopNotification(title, snippet, gui.RaiseWindow)
+		if !utils.IsError(err) {
+			return
+		}
+		logger.Warning("Can't show desktop notification: " + err.Error())
+	}
+	gui.App.SendNotification(fyne.NewNotification(title, snippet))
+}
+
+func getTextSnippet(text string, maxLength int) string {
+	// returns beginning of message text with emoji
+	snippet := []rune(replaceEmojiShortcodes(text))
+	if len(snippet) > maxLength {
+		snippet = append(snippet[:maxLength], '…')
+	}
+	return string(snippet)
 }
 
 func (gui *ChatGui) ShowError(text string) {
 	// shows child window with error info
-	log.Println(text)
-	dialog.ShowError(errors.New(text), gui.Window)
+	logger.Warning(text)
+	dialog.ShowError(errors.New(i18n.T(text)), gui.Window)
 }
 
 func (gui *ChatGui) AddMessage(msg models.SavedMessage) {
-	gui.MessagesList.AddMessage(msg)
+	// view follows new messages only if it's at the bottom already.
+	// Otherwise button with count of new messages is shown
+	gui.KnownUsers[msg.User.Id] = msg.User
+	wasAtBottom := gui.MessageListScroller.IsAtBottom()
+	if !gui.MessagesList.AddMessage(msg) {
+		return // own message shown after ack of server or message replayed after reconnection
+	}
+	gui.HideTyping(msg.User.Username)
+	if wasAtBottom || msg.User.Id == gui.CurrentUser.Id {
+		gui.MessageListScroller.ScrollToBottom()
+		return
+	}
+	gui.NewMessagesCount++
+	gui.NewMessagesButton.SetText(i18n.Tf("%d new messages ↓", gui.NewMessagesCount))
+	gui.NewMessagesButton.Show()
+}
+
+func (gui *ChatGui) HideNewMessagesButton() {
+	gui.NewMessagesCount = 0
+	gui.NewMessagesButton.Hide()
+}
+
+func (gui *ChatGui) UpdateMessage(msg models.SavedMessage) {
+	// shows new version of displayed message
+	gui.MessagesList.UpdateMessage(msg)
+	gui.UpdatePinnedMessage(msg)
+}
+
+func (gui *ChatGui) UpdateMessagesStatus(lastMessageId int64, status string) {
+	// shows delivery status of own messages in opened chat
+	gui.MessagesList.UpdateMessagesStatus(lastMessageId, status)
+}
+
+func (gui *ChatGui) RemoveMessage(id int64) {
+	gui.MessagesList.RemoveMessage(id)
+	gui.UpdatePinnedMessage(models.SavedMessage{Id: id}) // deleted message isn't pinned
+}
+
+func (gui *ChatGui) AddQueuedMessage(msg models.QueuedMessage) {
+	// shows greyed message which is waiting for connection or ack of server
+	gui.MessagesList.AddQueuedMessage(msg)
+	if msg.State == models.MESSAGE_STATE_FAILED {
+		gui.UpdateQueuedMessage(msg)
+	}
+	gui.MessageListScroller.ScrollToBottom()
+}
+
+func (gui *ChatGui) UpdateQueuedMessage(msg models.QueuedMessage) {
+	// removes queued message after sending. Server sends it back
+	// as usual message. Failed message is shown with retry button
+	if msg.State == models.MESSAGE_STATE_SENT {
+		gui.MessagesList.RemoveQueuedMessage(msg.LocalId)
+		return
+	}
+	gui.MessagesList.SetQueuedFailed(msg.LocalId, msg.State == models.MESSAGE_STATE_FAILED,
+		func() {
+			if gui.OnRetryMessage != nil {
+				gui.OnRetryMessage(msg.LocalId)
+			}
+		})
+}
+
+func (gui *ChatGui) ConfirmQueuedMessage(localId int64, msg models.SavedMessage) {
+	// pending message becomes usual one with id and time given by server
+	gui.KnownUsers[msg.User.Id] = msg.User
+	gui.MessagesList.ConfirmQueuedMessage(localId, msg)
+}
+
+func (gui *ChatGui) SetOnRetryMessage(onRetryMessage func(int64)) {
+	gui.OnRetryMessage = func(localId int64) {
+		gui.dispatch(func() { onRetryMessage(localId) })
+	}
 }
 
 func (gui *ChatGui) SetMessages(messages []models.SavedMessage) {
+	for _, msg := range messages {
+		gui.KnownUsers[msg.User.Id] = msg.User
+	}
 	list := gui.MessagesList
 	list.Clear()
 	list.SetMessages(messages)
 	list.Refresh()
-	gui.MessageListScroller.ScrollToBottom()
+	gui.MessageListScroller.ScrollToBottom() // hides new messages button
+}
+
+func (gui *ChatGui) PrependMessages(messages []models.SavedMessage) {
+	// adds page of older messages keeping visible messages in place
+	for _, msg := range messages {
+		gui.KnownUsers[msg.User.Id] = msg.User
+	}
+	previousHeight := gui.MessagesList.GetContainer().MinSize().Height
+	gui.MessagesList.PrependMessages(messages)
+	gui.MessageListScroller.KeepOffsetAfterPrepend(previousHeight)
+}
+
+func (gui *ChatGui) ScrollToMessage(id int64) {
+	// shows displayed message at the top of messages list
+	object, ok := gui.MessagesList.GetMessageObject(id)
+	if ok {
+		gui.MessageListScroller.ScrollToObject(object)
+	}
 }
 
 func (gui *ChatGui) ClearMessages() {
 	gui.MessagesList.Clear()
+	gui.HideNewMessagesButton()
 }
 
 func (gui *ChatGui) AppendChannel(title string) {
-	gui.ChannelsRadioGroup.Append(title)
+	gui.ChannelsList.Append(title)
 }
 
 func (gui *ChatGui) SetChannels(channels []models.Channel) {
@@ -122,13 +618,132 @@ func (gui *ChatGui) SetChannels(channels []models.Channel) {
 	stringChannels = append(stringChannels, GROUP_CHANNEL_TITLE, NOTES_CHANNEL_TITLE)
 	for _, channel := range channels {
 		stringChannels = append(stringChannels, channel.Title)
+		if !channel.IsGroup() { // private channel with user
+			gui.KnownUsers[channel.Id] = models.User{Id: channel.Id, Username: channel.Title}
+		}
 	}
-	gui.ChannelsRadioGroup.Options = stringChannels
-	gui.ChannelsRadioGroup.Refresh()
+	if gui.ChannelsList.Selected == "" { // group channel is opened after login
+		gui.ChannelsList.Selected = GROUP_CHANNEL_TITLE
+	}
+	gui.ChannelsList.SetChannels(stringChannels)
 }
 
 func (gui *ChatGui) SelectChannel(title string) {
-	gui.ChannelsRadioGroup.SetSelected(title)
+	gui.ChannelsList.Select(title)
+}
+
+func (gui *ChatGui) SetChannelMembers(members []models.ChannelMember) {
+	// shows members panel of opened group channel.
+	// Members can be found in quick switcher
+	for _, member := range members {
+		gui.KnownUsers[member.User.Id] = member.User
+	}
+	gui.MemberList.SetMembers(members)
+	item := gui.MembersPanel.Items[0]
+	item.Title = i18n.Tf("Members (%d/%d online)", gui.MemberList.CountOnline(),
+		len(members))
+	gui.MembersPanel.Refresh()
+	gui.MembersPanel.Show()
+}
+
+func (gui *ChatGui) HideChannelMembers() {
+	gui.MemberList.SetMembers(nil)
+	gui.MembersPanel.Hide()
+}
+
+func (gui *ChatGui) SetPresence(username string, state string) {
+	// updates presence dots in channels, members and messages lists
+	gui.Presence[username] = state
+	gui.ChannelsList.Refresh()
+	gui.MemberList.Refresh()
+	gui.ContactList.Refresh()
+	gui.MessagesList.UpdatePresence(username)
+}
+
+func (gui *ChatGui) SetAvatar(username string, path string) {
+	// shows avatar in channels and messages lists. Empty path means no avatar
+	gui.Avatars[username] = path
+	gui.ChannelsList.Refresh()
+	gui.MessagesList.UpdateAvatar(username)
+}
+
+func (gui *ChatGui) SetProfile(profile models.Profile) {
+	// shows display name and status of user in channels and messages lists
+	username := profile.User.Username
+	gui.Profiles[username] = profile
+	gui.ChannelsList.Refresh()
+	gui.MessagesList.UpdateDisplayName(username)
+	if profile.User.Id == gui.CurrentUser.Id {
+		gui.SetProfileInfo(profile.GetDisplayName())
+	}
+}
+
+func (gui *ChatGui) refreshWindowTitle() {
+	// unread messages count is shown in title, so it's visible in taskbar
+	title := WINDOW_TITLE
+	if count := gui.ChannelsList.GetUnreadCount(); count > 0 {
+		title = fmt.Sprintf("(%d) %s", count, WINDOW_TITLE)
+	}
+	if gui.Window != nil { // channels list is created before window
+		gui.Window.SetTitle(title)
+	}
+}
+
+func (gui *ChatGui) IncrementUnread(title string) {
+	// increments unread counter of channel which is not opened
+	gui.ChannelsList.IncrementUnread(title)
+}
+
+func (gui *ChatGui) ShowTyping(username string) {
+	// shows that user is typing until TYPING_DISPLAY_TIMEOUT is expired
+	gui.typingLock.Lock()
+	gui.TypingUsers[username] = time.Now()
+	gui.typingLock.Unlock()
+
+	gui.refreshTyping()
+	time.AfterFunc(TYPING_DISPLAY_TIMEOUT, gui.refreshTyping)
+}
+
+func (gui *ChatGui) HideTyping(username string) {
+	gui.typingLock.Lock()
+	_, isTyping := gui.TypingUsers[username]
+	delete(gui.TypingUsers, username)
+	gui.typingLock.Unlock()
+
+	if isTyping {
+		gui.refreshTyping()
+	}
+}
+
+func (gui *ChatGui) ClearTyping() {
+	gui.typingLock.Lock()
+	gui.TypingUsers = make(map[string]time.Time)
+	gui.typingLock.Unlock()
+
+	gui.refreshTyping()
+}
+
+func (gui *ChatGui) refreshTyping() {
+	// removes expired typing users and updates typing label
+	gui.typingLock.Lock()
+	var usernames []string
+	for username, lastTime := range gui.TypingUsers {
+		if time.Since(lastTime) < TYPING_DISPLAY_TIMEOUT {
+			usernames = append(usernames, username)
+		} else {
+			delete(gui.TypingUsers, username)
+		}
+	}
+	gui.typingLock.Unlock()
+
+	sort.Strings(usernames)
+	text := ""
+	if len(usernames) == 1 {
+		text = i18n.Tf("%s is typing…", usernames[0])
+	} else if len(usernames) > 1 {
+		text = i18n.Tf("%s are typing…", strings.Join(usernames, ", "))
+	}
+	gui.TypingLabel.SetText(text)
 }
 
 func (gui *ChatGui) EnableLoginButtons() {
@@ -142,78 +757,425 @@ func (gui *ChatGui) DisableLoginButtons() {
 }
 
 func (gui *ChatGui) EnableSend() {
-	gui.SendButton.Enable()
+	gui.SendEnabled = true
+	if !gui.IsRateLimited() {
+		gui.SendButton.Enable()
+	}
+	gui.SendLaterButton.Enable()
+	if gui.ServerFeatures[models.FEATURE_ATTACHMENTS] {
+		gui.AttachButton.Enable()
+		gui.RecordButton.Enable()
+	}
 }
 
 func (gui *ChatGui) DisableSend() {
+	gui.SendEnabled = false
 	gui.SendButton.Disable()
+	gui.SendLaterButton.Disable()
+	gui.AttachButton.Disable()
+	gui.RecordButton.Disable()
+}
+
+func (gui *ChatGui) SetServerFeatures(features []string) {
+	// hides actions which server doesn't support
+	gui.ServerFeatures = make(map[string]bool)
+	for _, feature := range features {
+		gui.ServerFeatures[feature] = true
+	}
+	if gui.ServerFeatures[models.FEATURE_ATTACHMENTS] && gui.SendEnabled {
+		gui.AttachButton.Enable()
+		gui.RecordButton.Enable()
+	} else {
+		gui.AttachButton.Disable()
+		gui.RecordButton.Disable()
+	}
+}
+
+func (gui *ChatGui) ClearSession() {
+	// forgets data of previous server before connection to another one
+	gui.MessagesList.Clear()
+	gui.HideChannelMembers()
+	gui.HideContacts()
+	gui.SetInvitations(nil)
+	gui.SetScheduledMessages(nil)
+	gui.ClearRateLimit()
+	gui.ClearTyping()
+	gui.CancelReply()
+	gui.SetInputText("") // drafts belong to previous account
+	gui.SetPinnedMessages(nil)
+	gui.ProfileInfo.SetText("")
+	gui.SetCurrentUser(models.User{})
+	gui.KnownUsers = make(map[int64]models.User)
+	for username := range gui.Presence {
+		delete(gui.Presence, username) // map is shared with lists
+	}
+	for username := range gui.Avatars {
+		delete(gui.Avatars, username)
+	}
+	for username := range gui.Profiles {
+		delete(gui.Profiles, username)
+	}
+	gui.RecentChannels = nil
+	gui.ChannelsList.ClearUnread()
+	gui.ChannelsList.Selected = ""
+	gui.SetChannels(nil)
 }
 
 func (gui *ChatGui) SetProfileInfo(username string) {
-	gui.ProfileInfo.SetText("WELCOME, " + username)
+	gui.ProfileInfo.SetText(i18n.Tf("WELCOME, %s", username))
+}
+
+func (gui *ChatGui) SetMessageFilters(filters *utils.MessageMatcher) {
+	// messages matching collapse filters are shown after click.
+	// Hidden messages are removed by client
+	gui.MessagesList.Filters = filters
+}
+
+func (gui *ChatGui) SetOnRenderMessage(onRender func(models.SavedMessage) (string, bool)) {
+	// text of message is replaced by text of renderer. Renderer is called
+	// while messages are created, so it mustn't block
+	gui.MessagesList.OnRender = onRender
+}
+
+func (gui *ChatGui) RefreshMessage(id int64) {
+	gui.MessagesList.RefreshMessage(id)
+}
+
+func (gui *ChatGui) SetCurrentUser(user models.User) {
+	// own messages can be edited and deleted by current user
+	gui.CurrentUser = user
+	gui.MessagesList.CurrentUserId = user.Id
+	gui.MessagesList.CurrentUsername = user.Username
+	if user.Id == 0 {
+		gui.LogoutButton.Disable()
+	} else {
+		gui.LogoutButton.Enable()
+	}
 }
 
 func (gui *ChatGui) processSend(inputText string) {
-	if inputText != "" && !gui.SendButton.Disabled() {
+	if inputText == "" || gui.SendButton.Disabled() {
+		return
+	}
+	if gui.ReplyMessageId != 0 && gui.OnSendReply != nil {
+		gui.OnSendReply(inputText, gui.ReplyMessageId)
+		gui.CancelReply()
+	} else {
 		gui.OnSendClick(inputText)
-		gui.MessageListScroller.ScrollToBottom()
 	}
+	gui.MessageListScroller.ScrollToBottom()
+}
+
+func (gui *ChatGui) GetInputText() string {
+	return gui.MessageInput.Text
+}
+
+func (gui *ChatGui) SetInputText(text string) {
+	// restores draft of channel. Typing isn't reported
+	gui.settingInput = true
+	gui.MessageInput.SetText(text)
+	gui.settingInput = false
+}
+
+func (gui *ChatGui) StartReply(msg models.SavedMessage) {
+	// next sent message will quote msg until reply is canceled
+	gui.ReplyMessageId = msg.Id
+	text := msg.Text
+	if text == "" && msg.HasAttachment() {
+		text = msg.Attachment.FileName
+	}
+	gui.ReplyLabel.SetText(i18n.Tf("Reply to %s: %s", msg.User.Username,
+		getTextSnippet(text, models.REPLY_PREVIEW_LENGTH)))
+	gui.ReplyBar.Show()
+}
+
+func (gui *ChatGui) CancelReply() {
+	gui.ReplyMessageId = 0
+	gui.ReplyBar.Hide()
 }
 
 // -------- CHILD WINDOWS ----------
 
-func (gui *ChatGui) ShowLoginDialog(title string) {
-	// creates and shows child window with login form
-	inputUsername := widget.NewEntry()
-	inputUsername.SetPlaceHolder("username")
-	inputPassword := widget.NewPasswordEntry()
-	inputPassword.SetPlaceHolder("password")
+func (gui *ChatGui) ShowAttachDialog() {
+	// shows file picker. Chosen file is passed to OnAttachFile
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			gui.ShowError(err.Error())
+			return
+		}
+		if reader == nil { // canceled
+			return
+		}
+		gui.ShowSendFileConfirm(reader)
+	}, gui.Window)
+}
 
-	loginBox := widget.NewVBox(inputUsername, inputPassword)
-	loginBox.Resize(fyne.NewSize(400, 400))
+func (gui *ChatGui) pasteImage() bool {
+	// pasted image is sent as attachment. Text of clipboard is pasted by entry
+	if gui.Window.Clipboard().Content() != "" || gui.AttachButton.Disabled() {
+		return false
+	}
+	data, err := utils.ReadClipboardImage()
+	if utils.IsError(err) {
+		return false
+	}
+	gui.ShowSendImageConfirm(data, fmt.Sprintf("image-%s.png", time.Now().Format("20060102-150405")))
+	return true
+}
 
-	dialog.ShowCustomConfirm(title, "Ok", "Cancel", loginBox,
+func (gui *ChatGui) ShowSendImageConfirm(data []byte, fileName string) {
+	// shows preview of pasted image before upload
+	image := canvas.NewImageFromResource(fyne.NewStaticResource(fileName, data))
+	image.FillMode = canvas.ImageFillContain
+	image.SetMinSize(fyne.NewSize(IMAGE_PREVIEW_WIDTH, IMAGE_PREVIEW_HEIGHT))
+	caption := widget.NewLabel(fmt.Sprintf("%s (%s)", fileName, getReadableSize(int64(len(data)))))
+	content := container.NewVBox(image, caption)
+	dialog.ShowCustomConfirm(i18n.T("Send image"), i18n.T("Send"), i18n.T("Cancel"), content,
 		func(result bool) {
 			if result {
-				gui.OnLoginSubmit(inputUsername.Text, inputPassword.Text)
+				gui.OnAttachFile(ioutil.NopCloser(bytes.NewReader(data)), fileName)
+			}
+		}, gui.Window)
+}
+
+func (gui *ChatGui) ShowSendFileConfirm(reader fyne.URIReadCloser) {
+	// shows name and size of chosen file before upload. Size of file
+	// opened not from local disk isn't known
+	caption := reader.URI().Name()
+	path := strings.TrimPrefix(reader.URI().String(), "file://")
+	if info, err := os.Stat(path); reader.URI().Scheme() == "file" && !utils.IsError(err) {
+		caption = fmt.Sprintf("%s (%s)", caption, getReadableSize(info.Size()))
+	}
+	dialog.ShowConfirm(i18n.T("Send file"), i18n.Tf("Send %s?", caption), func(result bool) {
+		if !result {
+			reader.Close()
+			return
+		}
+		gui.OnAttachFile(reader, reader.URI().Name())
+	}, gui.Window)
+}
+
+func (gui *ChatGui) ShowSetAvatarDialog() {
+	// shows picker of images. Chosen image is passed to OnSetAvatar
+	if !gui.canChangeAccount() {
+		return
+	}
+	if !gui.ServerFeatures[models.FEATURE_AVATARS] {
+		gui.ShowError("Server doesn't support avatars.")
+		return
+	}
+	fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			gui.ShowError(err.Error())
+			return
+		}
+		if reader == nil { // canceled
+			return
+		}
+		gui.OnSetAvatar(reader)
+	}, gui.Window)
+	fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".png", ".jpg", ".jpeg", ".gif"}))
+	fileDialog.Show()
+}
+
+func (gui *ChatGui) ShowMessageMenu(msg models.SavedMessage, pos fyne.Position) {
+	// shows context menu with actions for message. Own messages are edited,
+	// other messages are reported and their authors are blocked or muted
+	var items []*fyne.MenuItem
+	if msg.Text != "" {
+		items = append(items, fyne.NewMenuItem(i18n.T("Copy text"), func() {
+			gui.Window.Clipboard().SetContent(msg.Text)
+		}))
+	}
+	if link := urlRegexp.FindString(msg.Text); link != "" {
+		items = append(items, fyne.NewMenuItem(i18n.T("Copy link"), func() {
+			gui.Window.Clipboard().SetContent(link)
+		}))
+	}
+	if gui.OnSendReply != nil && !gui.SendButton.Disabled() {
+		items = append(items, fyne.NewMenuItem("Reply", func() {
+			gui.StartReply(msg)
+		}))
+	}
+	if gui.ServerFeatures[models.FEATURE_FORWARDING] && gui.OnForwardMessage != nil &&
+		!msg.HasAttachment() && (msg.Text != "" || msg.IsSticker()) {
+		items = append(items, fyne.NewMenuItem(i18n.T("Forward"), func() {
+			gui.ShowForwardDialog(msg)
+		}))
+	}
+	if gui.CanPin && gui.ServerFeatures[models.FEATURE_PINS] && gui.OnPinMessage != nil {
+		pinTitle := i18n.T("Pin")
+		if msg.Pinned {
+			pinTitle = i18n.T("Unpin")
+		}
+		items = append(items, fyne.NewMenuItem(pinTitle, func() {
+			gui.OnPinMessage(msg.Id, !msg.Pinned)
+		}))
+	}
+	if gui.ServerFeatures[models.FEATURE_REACTIONS] {
+		items = append(items, fyne.NewMenuItem(i18n.T("React"), func() {
+			gui.ShowReactionPicker(msg, pos)
+		}))
+	}
+	if msg.User.Id != gui.CurrentUser.Id {
+		items = append(items, gui.getReportMenuItems(msg)...)
+		if len(items) > 0 {
+			items = append(items, fyne.NewMenuItemSeparator())
+		}
+		items = append(items, gui.getUserFilterMenuItems(msg.User)...)
+		items = append(items, gui.getContactMenuItems(msg.User)...)
+		if gui.canModerate() { // moderators delete messages of other members
+			items = append(items, gui.getDeleteMenuItem(msg))
+		}
+	} else if gui.ServerFeatures[models.FEATURE_EDITS] {
+		if !msg.HasAttachment() && !msg.IsSticker() {
+			items = append(items, fyne.NewMenuItem(i18n.T("Edit"), func() {
+				gui.ShowEditMessageDialog(msg)
+			}))
+		}
+		items = append(items, gui.getDeleteMenuItem(msg))
+	}
+	if len(items) == 0 {
+		return
+	}
+	widget.ShowPopUpMenuAtPosition(fyne.NewMenu("", items...), gui.Window.Canvas(), pos)
+}
+
+func (gui *ChatGui) getDeleteMenuItem(msg models.SavedMessage) *fyne.MenuItem {
+	return fyne.NewMenuItem(i18n.T("Delete"), func() {
+		dialog.ShowConfirm(i18n.T("Delete message"), i18n.T("Delete this message for everyone?"),
+			func(result bool) {
+				if result {
+					gui.OnDeleteMessage(msg.Id)
+				}
+			}, gui.Window)
+	})
+}
+
+func (gui *ChatGui) SetOnForwardMessage(onForwardMessage func(int64, string)) {
+	gui.OnForwardMessage = func(messageId int64, title string) {
+		gui.dispatch(func() { onForwardMessage(messageId, title) })
+	}
+}
+
+func (gui *ChatGui) ShowForwardDialog(msg models.SavedMessage) {
+	// forwarded message can be sent to any chat of channels list
+	titles := append([]string{}, gui.ChannelsList.Titles...)
+	chatSelect := widget.NewSelect(titles, nil)
+	dialog.ShowCustomConfirm(i18n.T("Forward message"), i18n.T("Forward"), i18n.T("Cancel"),
+		chatSelect, func(result bool) {
+			if result && chatSelect.Selected != "" {
+				gui.OnForwardMessage(msg.Id, chatSelect.Selected)
 			}
 		}, gui.Window)
 }
 
-func (gui *ChatGui) ShowRegisterDialog(title string) {
-	// creates and shows child window with registration form
-	inputUsername := widget.NewEntry()
-	inputUsername.SetPlaceHolder("username")
-	inputPassword := widget.NewPasswordEntry()
-	inputPassword.SetPlaceHolder("password")
+func (gui *ChatGui) ShowEditMessageDialog(msg models.SavedMessage) {
+	// creates and shows child window with message text form
+	input := widget.NewMultiLineEntry()
+	input.SetText(msg.Text)
 
-	loginBox := widget.NewVBox(inputUsername, inputPassword)
-	loginBox.Resize(fyne.NewSize(400, 400))
-	title = title + "\n- username must be less than 20.\n- username mustn't has spaces."
-	dialog.ShowCustomConfirm(title, "Ok", "Cancel", loginBox,
+	dialog.ShowCustomConfirm(i18n.T("Edit message"), i18n.T("Save"), i18n.T("Cancel"), input,
 		func(result bool) {
-			if result {
-				gui.OnRegistratoinSubmit(inputUsername.Text, inputPassword.Text)
+			if result && input.Text != "" && input.Text != msg.Text {
+				gui.OnEditMessage(msg.Id, input.Text)
 			}
 		}, gui.Window)
 }
 
+func (gui *ChatGui) ShowImageWindow(path string) {
+	// opens new window with full size image
+	image := canvas.NewImageFromFile(path)
+	image.FillMode = canvas.ImageFillOriginal
+	window := gui.App.NewWindow(filepath.Base(path))
+	window.SetContent(widget.NewScrollContainer(image))
+	window.Resize(fyne.NewSize(WIDTH*2/3, HEIGHT))
+	window.Show()
+}
+
+func (gui *ChatGui) ShowDownloadDialog(attachment models.Attachment) {
+	// asks where to save attached file
+	dialog.ShowFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			gui.ShowError(err.Error())
+			return
+		}
+		if writer == nil { // canceled
+			return
+		}
+		gui.OnDownloadAttachment(attachment, writer)
+	}, gui.Window)
+}
+
+func (gui *ChatGui) ShowCreateChannelDialog() {
+	// creates and shows child window with group name and members form
+	inputTitle := widget.NewEntry()
+	inputTitle.SetPlaceHolder(i18n.T("group name"))
+
+	var users []models.User
+	for _, user := range gui.KnownUsers {
+		if user.Id != gui.CurrentUser.Id {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	membersBox := widget.NewVBox()
+	checks := make([]*widget.Check, len(users))
+	for i, user := range users {
+		checks[i] = widget.NewCheck(user.Username, nil)
+		membersBox.Append(checks[i])
+	}
+	membersScroller := widget.NewVScrollContainer(membersBox)
+	membersScroller.SetMinSize(fyne.NewSize(300, 200))
+
+	form := widget.NewVBox(inputTitle, widget.NewLabel(i18n.T("Members:")), membersScroller)
+	title := i18n.T("New group\n- name must be less than 20.\n- name mustn't has spaces.")
+	dialog.ShowCustomConfirm(title, i18n.T("Create"), i18n.T("Cancel"), form,
+		func(result bool) {
+			if !result {
+				return
+			}
+			var members []models.User
+			for i, check := range checks {
+				if check.Checked {
+					members = append(members, users[i])
+				}
+			}
+			gui.OnCreateChannel(inputTitle.Text, members)
+		}, gui.Window)
+}
+
 // -------- BUILD ----------
 
 func buildLeftSidebar(gui *ChatGui) *widget.Group {
 	// creates login and register page
 	// sets login and register buttons callbacks
-	gui.LoginButton = widget.NewButton("Login", func() {
-		gui.ShowLoginDialog("Login")
+	gui.LoginButton = widget.NewButton(i18n.T("Login"), func() {
+		gui.ShowLoginDialog(i18n.T("Login"))
 	})
-	gui.RegisterButton = widget.NewButton("Register", func() {
-		gui.ShowRegisterDialog("Registration")
+	gui.RegisterButton = widget.NewButton(i18n.T("Register"), func() {
+		gui.ShowRegisterDialog(i18n.T("Registration"))
 	})
+	gui.LogoutButton = widget.NewButton(i18n.T("Logout"), gui.logout)
+	gui.LogoutButton.Disable()
 
 	gui.ProfileInfo = widget.NewLabel("")
+	gui.DoNotDisturbCheck = widget.NewCheck(i18n.T("Do not disturb"), gui.setDoNotDisturb)
+	settingsButton := widget.NewButton(i18n.T("Settings"), gui.ShowSettingsWindow)
+
+	gui.ContactList = NewContactList(func(user models.User) {
+		gui.OnUsernameSelect(user)
+	}, gui.removeContact)
+	gui.ContactList.Presence = gui.Presence
+	gui.ContactsPanel = widget.NewAccordion(widget.NewAccordionItem(i18n.T("Contacts"),
+		widget.NewVScrollContainer(gui.ContactList.GetContainer())))
+	gui.ContactsPanel.Hide() // shown if server keeps contacts
 
-	group := widget.NewGroup("Profile",
-		gui.LoginButton, gui.RegisterButton, gui.ProfileInfo)
+	group := widget.NewGroup(i18n.T("Profile"),
+		gui.LoginButton, gui.RegisterButton, gui.ProfileInfo, gui.DoNotDisturbCheck,
+		gui.LogoutButton, settingsButton, gui.ContactsPanel)
 	group.Resize(fyne.NewSize(400, HEIGHT))
 	return group
 }
@@ -222,35 +1184,162 @@ func buildCenter(gui *ChatGui) *widget.Group {
 	// creates messenger page: messages list and text input
 	messagesList := NewMessageList(func(user models.User) {
 		gui.OnUsernameSelect(user)
-	})
+	}, gui.ShowDownloadDialog)
+	messagesList.OnLoadImagePreview = func(ctx context.Context, msg models.SavedMessage,
+		onLoaded func(string)) {
+		if gui.OnLoadImagePreview != nil {
+			gui.OnLoadImagePreview(ctx, msg, onLoaded)
+		}
+	}
+	messagesList.OnLoadLinkPreview = func(ctx context.Context, msg models.SavedMessage,
+		onLoaded func(models.LinkPreview)) {
+		if gui.OnLoadLinkPreview != nil {
+			gui.OnLoadLinkPreview(ctx, msg, onLoaded)
+		}
+	}
+	messagesList.OnImageTap = gui.ShowImageWindow
+	messagesList.OnPlayVoice = func(attachment models.Attachment, onFinished func()) {
+		if gui.OnPlayVoice != nil {
+			gui.OnPlayVoice(attachment, onFinished)
+		}
+	}
+	messagesList.OnStopVoice = func() {
+		if gui.OnStopVoice != nil {
+			gui.OnStopVoice()
+		}
+	}
+	messagesList.OnMessageMenu = gui.ShowMessageMenu
+	messagesList.OnToggleTask = gui.toggleTask
+	messagesList.OnReact = func(msg models.SavedMessage, emoji string) {
+		if gui.OnReact != nil {
+			gui.OnReact(msg.Id, emoji)
+		}
+	}
+	messagesList.OnReplyTap = func(reply models.SavedMessage) {
+		// replied message is in same channel as reply
+		replied := reply
+		replied.Id = reply.ReplyToId
+		if gui.OnSearchResultSelect != nil {
+			gui.OnSearchResultSelect(replied)
+		}
+	}
+	messagesList.OnUserShown = func(user models.User) {
+		if gui.OnUserShown != nil {
+			gui.OnUserShown(user)
+		}
+	}
+	messagesList.Presence = gui.Presence
+	messagesList.Avatars = gui.Avatars
+	messagesList.Profiles = gui.Profiles
 	gui.MessagesList = messagesList
-	scroller := widget.NewScrollContainer(messagesList.GetContainer())
+	scroller := NewMessageScroller(messagesList.GetContainer(), func() {
+		if gui.OnLoadOlderMessages != nil {
+			gui.OnLoadOlderMessages()
+		}
+	})
 	scroller.SetMinSize(fyne.NewSize(500, 600))
+	scroller.SetOnBottomReached(gui.HideNewMessagesButton)
+	scroller.SetOnViewChanged(messagesList.ShowRowsInView)
 	gui.MessageListScroller = scroller
+	gui.NewMessagesButton = widget.NewButton("", scroller.ScrollToBottom)
+	gui.NewMessagesButton.Importance = widget.HighImportance
+	gui.NewMessagesButton.Hide()
+	// button floats over bottom right corner of messages
+	newMessagesBox := container.NewVBox(layout.NewSpacer(),
+		container.NewHBox(layout.NewSpacer(), gui.NewMessagesButton))
+
+	searchInput := NewEnterEntry()
+	searchInput.SetPlaceHolder(i18n.T("Search messages"))
+	searchInput.SetOnShortcut(gui.Shortcuts.TypedShortcut)
+	searchInput.SetOnEnter(func() {
+		if searchInput.Text != "" && gui.OnSearchMessages != nil {
+			gui.OnSearchMessages(searchInput.Text)
+		}
+	})
+	gui.SearchInput = searchInput
 
-	input := NewEnterEntry()
+	var mainContainer *fyne.Container
+	input := NewMultiLineEnterEntry()
+	inputScroller := widget.NewVScrollContainer(input)
+	resizeInput := func() {
+		// input grows with text up to MAX_INPUT_ROWS
+		height := input.MinSize().Height
+		if maxHeight := input.GetRowsHeight(MAX_INPUT_ROWS); height > maxHeight {
+			height = maxHeight
+		}
+		inputScroller.SetMinSize(fyne.NewSize(0, height))
+		if mainContainer != nil {
+			mainContainer.Refresh()
+		}
+	}
+	resizeInput()
 	input.SetOnEnter(func() {
 		gui.processSend(input.Text)
 		input.Clear()
 	})
-	input.SetPlaceHolder("Your message")
+	input.SetPlaceHolder(i18n.T("Your message (Shift+Enter for new line)"))
+	input.SetOnShortcut(gui.Shortcuts.TypedShortcut)
+	gui.MessageInput = input
+	input.OnChanged = func(text string) {
+		if text != "" && gui.OnTyping != nil && !gui.settingInput {
+			gui.OnTyping()
+		}
+		gui.UpdateMentionAutocomplete(input)
+		gui.UpdateCommandAutocomplete(input)
+		gui.updateSpelling()
+		resizeInput()
+	}
+	input.SetGetMenuItems(gui.getSpellingMenuItems)
+	input.SetOnPaste(gui.pasteImage)
+	gui.SpellingLabel = widget.NewLabel("")
+	gui.SpellingLabel.TextStyle = fyne.TextStyle{Italic: true}
+	gui.SpellingLabel.Hide()
 
-	gui.SendButton = widget.NewButton("Send", func() {
+	gui.SendButton = widget.NewButton(i18n.T("Send"), func() {
 		gui.processSend(input.Text)
 		input.Clear()
 	})
-	inputForm := widget.NewHBox(input, gui.SendButton)
+	gui.AttachButton = widget.NewButton(i18n.T("Attach"), gui.ShowAttachDialog)
+	var emojiButton *widget.Button
+	emojiButton = widget.NewButton("☺", func() {
+		gui.ShowEmojiPicker(input, emojiButton)
+	})
+	gui.RecordButton = widget.NewButton(i18n.T("Record"), gui.toggleRecording)
+	gui.SendLaterButton = widget.NewButton(i18n.T("Later"), gui.ShowSendLaterDialog)
+	inputForm := widget.NewHBox(inputScroller, emojiButton, gui.SendButton, gui.SendLaterButton,
+		gui.AttachButton, gui.RecordButton)
+
+	gui.ReplyLabel = widget.NewLabel("")
+	gui.ReplyLabel.TextStyle = fyne.TextStyle{Italic: true}
+	gui.ReplyBar = container.NewHBox(gui.ReplyLabel, widget.NewButton("×", gui.CancelReply))
+	gui.ReplyBar.Hide()
+
+	gui.TypingLabel = widget.NewLabel("")
+	gui.TypingLabel.TextStyle = fyne.TextStyle{Italic: true}
+
+	gui.RateLimitLabel = widget.NewLabel("")
+	gui.RateLimitLabel.TextStyle = fyne.TextStyle{Italic: true}
+	gui.RateLimitLabel.Hide()
+
+	gui.PinnedList = container.NewVBox()
+	pinnedScroller := widget.NewVScrollContainer(gui.PinnedList)
+	pinnedScroller.SetMinSize(fyne.NewSize(500, 100))
+	gui.PinnedPanel = widget.NewAccordion(widget.NewAccordionItem(i18n.T("Pinned"), pinnedScroller))
+	gui.PinnedPanel.Hide() // shown when channel has pinned messages
 
-	mainContainer := container.NewVBox(container.NewMax(scroller), widget.NewSeparator(), inputForm)
+	mainContainer = container.NewVBox(searchInput, gui.PinnedPanel,
+		container.NewMax(scroller, newMessagesBox), gui.TypingLabel,
+		widget.NewSeparator(), gui.ReplyBar, gui.RateLimitLabel, inputForm, gui.SpellingLabel)
 
-	return widget.NewGroup("Messenger", mainContainer)
+	return widget.NewGroup(i18n.T("Messenger"), mainContainer)
 }
 
 func buildRightSidebar(gui *ChatGui) *widget.Group {
-	// creates radio group with channel selecting callback
-	var stringChannels []string
-	radioGroup := widget.NewRadioGroup(stringChannels, func(changed string) {
-		fmt.Printf("Select channel = %s\n", changed)
+	// creates channels list with channel selecting callback
+	channelsList := NewChannelList(func(changed string) {
+		logger.Debugf("Select channel = %s", changed)
+		gui.CancelReply() // reply can't be sent to another channel
+		gui.rememberRecentChannel(changed)
 		if changed == GROUP_CHANNEL_TITLE {
 			gui.OnGroupChannelSelect()
 		} else if changed == NOTES_CHANNEL_TITLE {
@@ -259,9 +1348,54 @@ func buildRightSidebar(gui *ChatGui) *widget.Group {
 			gui.OnChannelSelect(changed)
 		}
 	})
-	radioGroup.Required = true
-	gui.ChannelsRadioGroup = radioGroup
-	return widget.NewGroup("Channels", radioGroup)
+	channelsList.Presence = gui.Presence
+	channelsList.Avatars = gui.Avatars
+	channelsList.Profiles = gui.Profiles
+	channelsList.OnContextMenu = gui.ShowChannelMenu
+	channelsList.OnUnreadChanged = gui.refreshWindowTitle
+	gui.ChannelsList = channelsList
+	newGroupButton := widget.NewButton(i18n.T("New group"), gui.ShowCreateChannelDialog)
+	gui.InvitationsButton = widget.NewButton(i18n.T("Invitations"), gui.ShowInvitationsDialog)
+	gui.InvitationsButton.Hide() // shown if inbox isn't empty
+
+	gui.MemberList = NewMemberList(func(user models.User) {
+		gui.OnUsernameSelect(user)
+	})
+	gui.MemberList.Presence = gui.Presence
+	gui.MemberList.OnContextMenu = gui.ShowMemberMenu
+	gui.MembersPanel = widget.NewAccordion(widget.NewAccordionItem(i18n.T("Members"),
+		widget.NewVScrollContainer(gui.MemberList.GetContainer())))
+	gui.MembersPanel.Hide() // shown for group channels only
+
+	return widget.NewGroup(i18n.T("Channels"), newGroupButton, gui.InvitationsButton,
+		widget.NewVScrollContainer(channelsList.GetContainer()), gui.MembersPanel)
+}
+
+func buildMainMenu(gui *ChatGui) *fyne.MainMenu {
+	serverMenu := fyne.NewMenu(i18n.T("Server"),
+		fyne.NewMenuItem(i18n.T("Switch server"), gui.ShowSwitchServerDialog),
+		fyne.NewMenuItem(i18n.T("Jump to channel"), gui.ShowQuickSwitcher),
+		fyne.NewMenuItem(i18n.T("Find user"), gui.ShowFindUserDialog),
+		fyne.NewMenuItem(i18n.T("Join group"), gui.ShowJoinChannelDialog),
+		fyne.NewMenuItem(i18n.T("Invitations"), gui.ShowInvitationsDialog),
+		fyne.NewMenuItem(i18n.T("Scheduled messages"), gui.ShowScheduledMessagesDialog),
+		fyne.NewMenuItem(i18n.T("Settings"), gui.ShowSettingsWindow),
+		fyne.NewMenuItemSeparator(),
+		// fyne replaces item without this label by own one, which doesn't call handler of close
+		fyne.NewMenuItem("Quit", gui.quit))
+	profileMenu := fyne.NewMenu(i18n.T("Profile"),
+		fyne.NewMenuItem(i18n.T("Edit profile"), gui.ShowEditProfileDialog),
+		fyne.NewMenuItem(i18n.T("Set avatar"), gui.ShowSetAvatarDialog),
+		fyne.NewMenuItem(i18n.T("Blocked users"), gui.ShowBlockedUsersDialog),
+		fyne.NewMenuItem(i18n.T("Change password"), gui.ShowChangePasswordDialog),
+		fyne.NewMenuItem(i18n.T("Two-factor authentication"), gui.ShowTwoFactorDialog),
+		fyne.NewMenuItem(i18n.T("Delete account"), gui.ShowDeleteAccountDialog),
+		fyne.NewMenuItem(i18n.T("Switch account"), gui.ShowAccountsDialog),
+		fyne.NewMenuItem(i18n.T("Logout"), gui.logout))
+	helpMenu := fyne.NewMenu(i18n.T("Help"),
+		fyne.NewMenuItem(i18n.T("Keyboard shortcuts"), gui.ShowShortcutsDialog),
+		fyne.NewMenuItem(i18n.T("Debug log"), gui.ShowDebugLogWindow))
+	return fyne.NewMainMenu(serverMenu, profileMenu, helpMenu)
 }
 
 func buildMainWindow(gui *ChatGui) *fyne.Container {
@@ -272,10 +1406,19 @@ func buildMainWindow(gui *ChatGui) *fyne.Container {
 	center := buildCenter(gui)
 
 	rightSideBar := buildRightSidebar(gui)
+	gui.ConnectionStatus = NewConnectionStatus(func() {
+		if gui.OnRetryConnection != nil {
+			gui.OnRetryConnection()
+		}
+	})
+	statusBar := gui.ConnectionStatus.GetContainer()
 	gui.DisableLoginButtons() // disable by default. Waiting successful connect
 	gui.DisableSend()
-	return fyne.NewContainerWithLayout(
-		layout.NewBorderLayout(nil, nil, leftSideBar, rightSideBar),
-		leftSideBar,
-		center, rightSideBar)
+	// sidebars have minimal widths until splits are dragged
+	gui.RightSplit = container.NewHSplit(center, rightSideBar)
+	gui.RightSplit.SetOffset(1)
+	gui.LeftSplit = container.NewHSplit(leftSideBar, gui.RightSplit)
+	gui.LeftSplit.SetOffset(0)
+	return fyne.NewContainerWithLayout(layout.NewBorderLayout(nil, statusBar, nil, nil),
+		statusBar, gui.LeftSplit)
 }
@@ -0,0 +1,99 @@
+// connection_status.go
+package gui
+
+import (
+	"time"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+)
+
+const LAST_SYNC_TIME_FORMAT = "15:04:05"
+
+// status bar with state of connection to server, latency of pings
+// and time of last data received from server
+type ConnectionStatus struct {
+	container    *fyne.Container
+	dot          *StatusDot // colored like presence: active, idle or offline
+	label        *widget.Label
+	retryButton  *widget.Button
+	latencyLabel *widget.Label // hidden until pong is received on current connection
+	syncLabel    *widget.Label // hidden until first sync
+}
+
+func NewConnectionStatus(onRetry func()) *ConnectionStatus {
+	status := &ConnectionStatus{
+		dot:          NewStatusDot(models.PRESENCE_OFFLINE),
+		label:        widget.NewLabel(i18n.T("Offline")),
+		retryButton:  widget.NewButton(i18n.T("Retry now"), onRetry),
+		latencyLabel: widget.NewLabel(""),
+		syncLabel:    widget.NewLabel("")}
+	status.latencyLabel.Hide()
+	status.syncLabel.Hide()
+	status.container = fyne.NewContainerWithLayout(layout.NewHBoxLayout(),
+		status.dot.GetContainer(), status.label, status.retryButton, layout.NewSpacer(),
+		status.latencyLabel, status.syncLabel)
+	return status
+}
+
+func (status *ConnectionStatus) set(state string, text string, canRetry bool) {
+	// latency is measured again after every connection
+	status.dot.SetState(state)
+	status.label.SetText(text)
+	if canRetry {
+		status.retryButton.Show()
+	} else {
+		status.retryButton.Hide()
+	}
+	if state != models.PRESENCE_ACTIVE {
+		status.latencyLabel.Hide()
+	}
+}
+
+func (status *ConnectionStatus) GetContainer() *fyne.Container {
+	return status.container
+}
+
+func (gui *ChatGui) SetConnected(host string) {
+	gui.ConnectionStatus.set(models.PRESENCE_ACTIVE, i18n.Tf("Connected to %s", host), false)
+}
+
+func (gui *ChatGui) SetConnecting(host string) {
+	gui.ConnectionStatus.set(models.PRESENCE_IDLE, i18n.Tf("Connecting to %s...", host), false)
+}
+
+func (gui *ChatGui) SetReconnecting(delay time.Duration, lastError string) {
+	// shows time before next connection attempt and reason of last failure
+	seconds := int((delay + time.Second - 1) / time.Second)
+	text := i18n.Tf("Reconnecting in %ds", seconds)
+	if lastError != "" {
+		text += " (" + lastError + ")"
+	}
+	gui.ConnectionStatus.set(models.PRESENCE_IDLE, text, true)
+}
+
+func (gui *ChatGui) SetOffline(lastError string) {
+	text := i18n.T("Offline")
+	if lastError != "" {
+		text += " (" + lastError + ")"
+	}
+	gui.ConnectionStatus.set(models.PRESENCE_OFFLINE, text, true)
+}
+
+func (gui *ChatGui) SetLatency(latency time.Duration) {
+	// round trip time of last ping
+	label := gui.ConnectionStatus.latencyLabel
+	label.SetText(i18n.Tf("Ping: %d ms", latency.Milliseconds()))
+	label.Show()
+}
+
+func (gui *ChatGui) SetLastSync(syncTime time.Time) {
+	// time is kept while client is offline, so user knows how old data is
+	label := gui.ConnectionStatus.syncLabel
+	label.SetText(i18n.Tf("Last sync: %s", syncTime.Format(LAST_SYNC_TIME_FORMAT)))
+	label.Show()
+}
@@ -0,0 +1,119 @@
+// contact_list.go
+package gui
+
+import (
+	"fyne.io/fyne"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+)
+
+// contacts are chosen by user, unlike channels which appear with messages
+type ContactList struct {
+	container *fyne.Container
+	Contacts  []models.User
+	Presence  map[string]string // map: username -> presence state
+	OnSelect  func(user models.User)
+	OnRemove  func(user models.User)
+}
+
+func NewContactList(onSelect func(user models.User), onRemove func(user models.User)) *ContactList {
+	list := &ContactList{
+		container: fyne.NewContainerWithLayout(layout.NewVBoxLayout()),
+		OnSelect:  onSelect,
+		OnRemove:  onRemove}
+	return list
+}
+
+func (list *ContactList) GetContainer() *fyne.Container {
+	return list.container
+}
+
+func (list *ContactList) IsContact(userId int64) bool {
+	for _, contact := range list.Contacts {
+		if contact.Id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+func (list *ContactList) Refresh() {
+	// rebuilds contact buttons with presence indicators.
+	// Tap on contact opens private chat
+	var objects []fyne.CanvasObject
+	for _, user := range list.Contacts {
+		user := user
+		button := widget.NewButton(user.Username, func() {
+			list.OnSelect(user)
+		})
+		button.Alignment = widget.ButtonAlignLeading
+		button.Importance = widget.LowImportance
+		removeButton := widget.NewButton("×", func() {
+			list.OnRemove(user)
+		})
+		removeButton.Importance = widget.LowImportance
+		dot := NewStatusDot(list.Presence[user.Username])
+		objects = append(objects, widget.NewHBox(dot.GetContainer(), button,
+			layout.NewSpacer(), removeButton))
+	}
+	list.container.Objects = objects
+	list.container.Refresh()
+}
+
+func (gui *ChatGui) SetOnAddContact(onAddContact func(models.User)) {
+	gui.OnAddContact = func(user models.User) {
+		gui.dispatch(func() { onAddContact(user) })
+	}
+}
+
+func (gui *ChatGui) SetOnRemoveContact(onRemoveContact func(models.User)) {
+	gui.OnRemoveContact = func(user models.User) {
+		gui.dispatch(func() { onRemoveContact(user) })
+	}
+}
+
+func (gui *ChatGui) SetContacts(contacts []models.User) {
+	// shows contacts panel. Contacts can be found in quick switcher
+	for _, contact := range contacts {
+		gui.KnownUsers[contact.Id] = contact
+	}
+	gui.ContactList.Contacts = contacts
+	gui.ContactList.Refresh()
+	gui.ContactsPanel.Items[0].Title = i18n.Tf("Contacts (%d)", len(contacts))
+	gui.ContactsPanel.Refresh()
+	gui.ContactsPanel.Show()
+}
+
+func (gui *ChatGui) HideContacts() {
+	gui.ContactList.Contacts = nil
+	gui.ContactList.Refresh()
+	gui.ContactsPanel.Hide()
+}
+
+func (gui *ChatGui) getContactMenuItems(user models.User) []*fyne.MenuItem {
+	// returns add or remove action for author of message
+	if !gui.ServerFeatures[models.FEATURE_CONTACTS] || gui.OnAddContact == nil {
+		return nil
+	}
+	if gui.ContactList.IsContact(user.Id) {
+		return []*fyne.MenuItem{fyne.NewMenuItem(i18n.T("Remove from contacts"), func() {
+			gui.removeContact(user)
+		})}
+	}
+	return []*fyne.MenuItem{fyne.NewMenuItem(i18n.T("Add to contacts"), func() {
+		gui.OnAddContact(user)
+	})}
+}
+
+func (gui *ChatGui) removeContact(user models.User) {
+	dialog.ShowConfirm(i18n.T("Remove contact"),
+		i18n.Tf("Remove %s from contacts?", user.Username), func(result bool) {
+			if result && gui.OnRemoveContact != nil {
+				gui.OnRemoveContact(user)
+			}
+		}, gui.Window)
+}
@@ -2,25 +2,59 @@
 package gui
 
 import (
+	"context"
+	"fmt"
 	"image/color"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
 	"fyne.io/fyne"
 	"fyne.io/fyne/canvas"
+	"fyne.io/fyne/driver/desktop"
 	"fyne.io/fyne/layout"
+	"fyne.io/fyne/theme"
 	"fyne.io/fyne/widget"
 
+	"chat/i18n"
 	"chat/models"
+	"chat/utils"
 )
 
 var separatorColor = color.RGBA{33, 150, 243, 255}
-var msgBodyColor = color.RGBA{125, 119, 119, 255}
-var msgStrokeColor = color.RGBA{80, 80, 80, 255}
+var msgBodyColor color.Color = color.RGBA{66, 66, 66, 255}
+var msgOwnBodyColor color.Color = color.RGBA{33, 150, 243, 255}
+var msgStrokeColor color.Color = color.RGBA{90, 90, 90, 255}
+var msgTextColor color.Color = color.White
+var msgPendingTextColor color.Color = color.RGBA{170, 170, 170, 255}
+var onlineColor = color.RGBA{76, 175, 80, 255}
+var idleColor = color.RGBA{255, 193, 7, 255}
+var offlineColor = color.RGBA{158, 158, 158, 255}
+var busyColor = color.RGBA{244, 67, 54, 255}
+var msgReadColor = color.RGBA{33, 150, 243, 255}
+var msgMentionColor color.Color = color.RGBA{150, 130, 90, 255}
+var msgFailedColor = color.RGBA{244, 67, 54, 255}
 
 const MAX_MSG_TEXT_LINE_LENGTH int = 71
+const IMAGE_PREVIEW_WIDTH int = 300
+const IMAGE_PREVIEW_HEIGHT int = 200
+const STATUS_DOT_SIZE int = 10
+const AVATAR_DISPLAY_SIZE int = 24
+const LINK_PREVIEW_IMAGE_SIZE int = 64
+const MAX_LINK_DESCRIPTION_LENGTH = 150 // longer description is cut
+
+const MESSAGE_TIME_FORMAT = "15:04"
+const MESSAGE_GROUP_INTERVAL int64 = 5 * 60 // seconds between grouped messages of author
+const DAY_SEPARATOR_FORMAT = "2 January 2006"
+const VISIBLE_ROWS_MARGIN = 1.0 // pages above and below view where messages are created
+
+var urlRegexp = regexp.MustCompile(`https?://\S+[^\s.,!?;:)\]'"]`)
 
 type tappableLabel struct {
 	widget.Label
-	TappedFunc func()
+	TappedFunc          func()
+	TappedSecondaryFunc func(pos fyne.Position) // optional. Gets absolute position
 }
 
 func NewTappableLabel(text string, tappedFunc func()) *tappableLabel {
@@ -37,12 +71,199 @@ func (t *tappableLabel) Tapped(_ *fyne.PointEvent) {
 	t.TappedFunc()
 }
 
-func (t *tappableLabel) TappedSecondary(_ *fyne.PointEvent) {
+func (t *tappableLabel) TappedSecondary(ev *fyne.PointEvent) {
+	if t.TappedSecondaryFunc != nil {
+		t.TappedSecondaryFunc(ev.AbsolutePosition)
+	}
+}
+
+// thumbnail of image which can be clicked to open full size
+type ImagePreview struct {
+	widget.BaseWidget
+	image      *canvas.Image
+	size       fyne.Size
+	TappedFunc func()
+}
+
+func NewImagePreview(path string, tappedFunc func()) *ImagePreview {
+	return newSizedImagePreview(path,
+		fyne.NewSize(IMAGE_PREVIEW_WIDTH, IMAGE_PREVIEW_HEIGHT), tappedFunc)
+}
+
+func newSizedImagePreview(path string, size fyne.Size, tappedFunc func()) *ImagePreview {
+	preview := &ImagePreview{size: size, TappedFunc: tappedFunc}
+	preview.image = newCachedImage(path)
+	preview.image.FillMode = canvas.ImageFillContain
+	preview.ExtendBaseWidget(preview)
+
+	return preview
+}
+
+func (p *ImagePreview) Tapped(_ *fyne.PointEvent) {
+	p.TappedFunc()
+}
+
+func (p *ImagePreview) TappedSecondary(_ *fyne.PointEvent) {
+}
+
+func (p *ImagePreview) CreateRenderer() fyne.WidgetRenderer {
+	return &imagePreviewRenderer{image: p.image, size: p.size}
+}
+
+type imagePreviewRenderer struct {
+	image *canvas.Image
+	size  fyne.Size
+}
+
+func (r *imagePreviewRenderer) Layout(size fyne.Size) {
+	r.image.Resize(size)
+}
+
+func (r *imagePreviewRenderer) MinSize() fyne.Size {
+	return r.size
+}
+
+func (r *imagePreviewRenderer) Refresh() {
+	canvas.Refresh(r.image)
+}
+
+func (r *imagePreviewRenderer) BackgroundColor() color.Color {
+	return color.Transparent
+}
+
+func (r *imagePreviewRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.image}
+}
+
+func (r *imagePreviewRenderer) Destroy() {
+}
+
+// transparent area under message content which handles right clicks
+type messageTapArea struct {
+	widget.BaseWidget
+	TappedSecondaryFunc func(pos fyne.Position) // gets absolute position
+}
+
+func newMessageTapArea() *messageTapArea {
+	area := &messageTapArea{}
+	area.ExtendBaseWidget(area)
+	return area
+}
+
+func (area *messageTapArea) TappedSecondary(ev *fyne.PointEvent) {
+	if area.TappedSecondaryFunc != nil {
+		area.TappedSecondaryFunc(ev.AbsolutePosition)
+	}
+}
+
+func (area *messageTapArea) CreateRenderer() fyne.WidgetRenderer {
+	return &messageTapAreaRenderer{background: canvas.NewRectangle(color.Transparent)}
+}
+
+type messageTapAreaRenderer struct {
+	background *canvas.Rectangle
+}
+
+func (r *messageTapAreaRenderer) Layout(size fyne.Size) {
+	r.background.Resize(size)
+}
+
+func (r *messageTapAreaRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(0, 0)
+}
+
+func (r *messageTapAreaRenderer) Refresh() {
+}
+
+func (r *messageTapAreaRenderer) BackgroundColor() color.Color {
+	return color.Transparent
+}
+
+func (r *messageTapAreaRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.background}
+}
+
+func (r *messageTapAreaRenderer) Destroy() {
+}
+
+// small colored circle showing user presence
+type StatusDot struct {
+	circle    *canvas.Circle
+	container *fyne.Container
+}
+
+func NewStatusDot(state string) *StatusDot {
+	dot := &StatusDot{circle: canvas.NewCircle(getPresenceColor(state))}
+	dot.container = fyne.NewContainerWithLayout(
+		layout.NewGridWrapLayout(fyne.NewSize(STATUS_DOT_SIZE, STATUS_DOT_SIZE)), dot.circle)
+	return dot
+}
+
+func (dot *StatusDot) SetState(state string) {
+	dot.circle.FillColor = getPresenceColor(state)
+	dot.circle.Refresh()
+}
+
+func (dot *StatusDot) GetContainer() *fyne.Container {
+	return dot.container
+}
+
+func getPresenceColor(state string) color.Color {
+	switch state {
+	case models.PRESENCE_ACTIVE:
+		return onlineColor
+	case models.PRESENCE_IDLE:
+		return idleColor
+	case models.PRESENCE_BUSY:
+		return busyColor
+	}
+	return offlineColor
+}
+
+// picture of user. Empty space is shown while avatar isn't loaded
+type AvatarImage struct {
+	image     *canvas.Image
+	container *fyne.Container
+}
+
+func NewAvatarImage(path string) *AvatarImage {
+	avatar := &AvatarImage{image: newCachedImage(path)}
+	avatar.image.FillMode = canvas.ImageFillContain
+	avatar.container = fyne.NewContainerWithLayout(
+		layout.NewGridWrapLayout(fyne.NewSize(AVATAR_DISPLAY_SIZE, AVATAR_DISPLAY_SIZE)),
+		avatar.image)
+	return avatar
+}
+
+func (avatar *AvatarImage) SetPath(path string) {
+	setImagePath(avatar.image, path)
+	avatar.image.Refresh()
+}
+
+func (avatar *AvatarImage) GetContainer() *fyne.Container {
+	return avatar.container
+}
+
+func getDisplayName(profiles map[string]models.Profile, username string) string {
+	// returns username while profile of user isn't loaded
+	profile, ok := profiles[username]
+	if !ok {
+		return username
+	}
+	return profile.GetDisplayName()
 }
 
 type EnterEntry struct {
 	widget.Entry
-	onEnter func()
+	onEnter      func()
+	onShortcut   func(fyne.Shortcut)
+	shiftPressed bool // Shift+Enter inserts new line into multi-line entry
+
+	onKey func(key *fyne.KeyEvent) bool // returns true if key is handled
+
+	getMenuItems func() []*fyne.MenuItem // items added above edit actions of context menu
+
+	onPaste func() bool // returns true if paste is handled, for example image is pasted
 }
 
 func NewEnterEntry() *EnterEntry {
@@ -52,6 +273,31 @@ func NewEnterEntry() *EnterEntry {
 	return entry
 }
 
+func NewMultiLineEnterEntry() *EnterEntry {
+	// Enter calls onEnter, Shift+Enter inserts new line
+	entry := &EnterEntry{}
+	entry.MultiLine = true
+	entry.ExtendBaseWidget(entry)
+
+	return entry
+}
+
+func (e *EnterEntry) GetRowsHeight(rows int) int {
+	// returns height of entry which shows rows of text
+	lineHeight := fyne.MeasureText("M", theme.TextSize(), fyne.TextStyle{}).Height
+	return lineHeight*rows + theme.Padding()*2
+}
+
+func (e *EnterEntry) MinSize() fyne.Size {
+	// multi-line entry grows with text from one line instead of three
+	size := e.Entry.MinSize()
+	if e.MultiLine {
+		rows := strings.Count(e.Text, "\n") + 1
+		size.Height = e.GetRowsHeight(rows)
+	}
+	return size
+}
+
 func (e *EnterEntry) Clear() {
 	e.Entry.SetText("")
 }
@@ -60,9 +306,88 @@ func (e *EnterEntry) SetOnEnter(onEnter func()) {
 	e.onEnter = onEnter
 }
 
+func (e *EnterEntry) SetOnShortcut(onShortcut func(fyne.Shortcut)) {
+	// custom shortcuts are passed to onShortcut
+	// because focused entry intercepts all shortcuts
+	e.onShortcut = onShortcut
+}
+
+func (e *EnterEntry) SetOnKey(onKey func(*fyne.KeyEvent) bool) {
+	// onKey is called before entry handles typed key
+	e.onKey = onKey
+}
+
+func (e *EnterEntry) SetGetMenuItems(getMenuItems func() []*fyne.MenuItem) {
+	e.getMenuItems = getMenuItems
+}
+
+func (e *EnterEntry) SetOnPaste(onPaste func() bool) {
+	e.onPaste = onPaste
+}
+
+func (e *EnterEntry) TappedSecondary(ev *fyne.PointEvent) {
+	// shows context menu of entry with additional items
+	var items []*fyne.MenuItem
+	if e.getMenuItems != nil {
+		items = e.getMenuItems()
+	}
+	if len(items) == 0 || e.Disabled() {
+		e.Entry.TappedSecondary(ev)
+		return
+	}
+	clipboard := fyne.CurrentApp().Driver().AllWindows()[0].Clipboard()
+	items = append(items, fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem(i18n.T("Cut"), func() {
+			e.Entry.TypedShortcut(&fyne.ShortcutCut{Clipboard: clipboard})
+		}),
+		fyne.NewMenuItem(i18n.T("Copy"), func() {
+			e.Entry.TypedShortcut(&fyne.ShortcutCopy{Clipboard: clipboard})
+		}),
+		fyne.NewMenuItem(i18n.T("Paste"), func() {
+			e.TypedShortcut(&fyne.ShortcutPaste{Clipboard: clipboard})
+		}),
+		fyne.NewMenuItem(i18n.T("Select all"), func() {
+			e.Entry.TypedShortcut(&fyne.ShortcutSelectAll{})
+		}))
+	canvas := fyne.CurrentApp().Driver().CanvasForObject(e)
+	widget.ShowPopUpMenuAtPosition(fyne.NewMenu("", items...), canvas, ev.AbsolutePosition)
+}
+
+func (e *EnterEntry) TypedShortcut(shortcut fyne.Shortcut) {
+	if _, ok := shortcut.(*desktop.CustomShortcut); ok && e.onShortcut != nil {
+		e.onShortcut(shortcut)
+		return
+	}
+	if _, ok := shortcut.(*fyne.ShortcutPaste); ok && e.onPaste != nil && e.onPaste() {
+		return
+	}
+	e.Entry.TypedShortcut(shortcut)
+}
+
+func (e *EnterEntry) KeyDown(key *fyne.KeyEvent) {
+	if key.Name == desktop.KeyShiftLeft || key.Name == desktop.KeyShiftRight {
+		e.shiftPressed = true
+	}
+	e.Entry.KeyDown(key)
+}
+
+func (e *EnterEntry) KeyUp(key *fyne.KeyEvent) {
+	if key.Name == desktop.KeyShiftLeft || key.Name == desktop.KeyShiftRight {
+		e.shiftPressed = false
+	}
+	e.Entry.KeyUp(key)
+}
+
 func (e *EnterEntry) TypedKey(key *fyne.KeyEvent) {
+	if e.onKey != nil && e.onKey(key) {
+		return
+	}
 	switch key.Name {
 	case fyne.KeyReturn:
+		if e.MultiLine && e.shiftPressed {
+			e.Entry.TypedKey(key)
+			return
+		}
 		e.onEnter()
 	default:
 		e.Entry.TypedKey(key)
@@ -70,7 +395,8 @@ func (e *EnterEntry) TypedKey(key *fyne.KeyEvent) {
 }
 
 func splitTextToLines(text string, maxLength int) []string {
-	if len(text) <= maxLength {
+	runes := []rune(text) // emoji and non-latin letters take several bytes
+	if len(runes) <= maxLength {
 		return []string{text}
 	}
 
@@ -79,10 +405,10 @@ func splitTextToLines(text string, maxLength int) []string {
 
 	for {
 		var s string
-		if len(text[offset:]) >= maxLength {
-			s = text[offset : offset+maxLength]
+		if len(runes[offset:]) >= maxLength {
+			s = string(runes[offset : offset+maxLength])
 		} else {
-			s = text[offset:]
+			s = string(runes[offset:])
 			return append(result, s)
 			break
 		}
@@ -92,65 +418,1001 @@ func splitTextToLines(text string, maxLength int) []string {
 	return result
 }
 
+type textSegment struct {
+	Text  string
+	IsUrl bool
+}
+
+func splitTextByUrls(text string) []textSegment {
+	var result []textSegment
+	offset := 0
+	for _, bounds := range urlRegexp.FindAllStringIndex(text, -1) {
+		if bounds[0] > offset {
+			result = append(result, textSegment{text[offset:bounds[0]], false})
+		}
+		result = append(result, textSegment{text[bounds[0]:bounds[1]], true})
+		offset = bounds[1]
+	}
+	if offset < len(text) {
+		result = append(result, textSegment{text[offset:], false})
+	}
+	return result
+}
+
+func newLinkedTextLines(text string, textColor color.Color,
+	maxLength int) []fyne.CanvasObject {
+	// splits text to lines where urls are hyperlinks opened in browser.
+	// Url is never split between lines
+	var lines []fyne.CanvasObject
+	var lineObjects []fyne.CanvasObject
+	lineLength := 0
+	flushLine := func() {
+		if len(lineObjects) > 0 {
+			lines = append(lines, widget.NewHBox(lineObjects...))
+		}
+		lineObjects = nil
+		lineLength = 0
+	}
+
+	for _, segment := range splitTextByUrls(text) {
+		runes := []rune(segment.Text)
+		if segment.IsUrl {
+			link, err := url.Parse(segment.Text)
+			if !utils.IsError(err) {
+				if lineLength > 0 && lineLength+len(runes) > maxLength {
+					flushLine()
+				}
+				lineObjects = append(lineObjects, widget.NewHyperlink(segment.Text, link))
+				lineLength += len(runes)
+				continue
+			}
+		}
+		for len(runes) > 0 {
+			if lineLength >= maxLength {
+				flushLine()
+			}
+			n := maxLength - lineLength
+			if n > len(runes) {
+				n = len(runes)
+			}
+			lineObjects = append(lineObjects, canvas.NewText(string(runes[:n]), textColor))
+			lineLength += n
+			runes = runes[n:]
+		}
+	}
+	flushLine()
+	return lines
+}
+
+type MessageScroller struct {
+	widget.ScrollContainer
+	onTopReached    func()
+	onBottomReached func()
+	onViewChanged   func(top int, bottom int) // called with visible part of content
+}
+
+func NewMessageScroller(content fyne.CanvasObject, onTopReached func()) *MessageScroller {
+	scroller := &MessageScroller{onTopReached: onTopReached}
+	scroller.Content = content
+	scroller.ExtendBaseWidget(scroller)
+
+	return scroller
+}
+
+func (s *MessageScroller) SetOnBottomReached(onBottomReached func()) {
+	s.onBottomReached = onBottomReached
+}
+
+func (s *MessageScroller) SetOnViewChanged(onViewChanged func(top int, bottom int)) {
+	s.onViewChanged = onViewChanged
+}
+
+func (s *MessageScroller) notifyViewChanged() {
+	if s.onViewChanged != nil {
+		s.onViewChanged(s.Offset.Y, s.Offset.Y+s.Size().Height)
+	}
+}
+
+func (s *MessageScroller) Scrolled(ev *fyne.ScrollEvent) {
+	s.ScrollContainer.Scrolled(ev)
+	s.notifyViewChanged()
+	if ev.DeltaY > 0 && s.Offset.Y == 0 && s.onTopReached != nil {
+		s.onTopReached()
+	}
+	if ev.DeltaY < 0 && s.IsAtBottom() && s.onBottomReached != nil {
+		s.onBottomReached()
+	}
+}
+
+func (s *MessageScroller) IsAtBottom() bool {
+	// returns true if last line of content is visible
+	return s.Offset.Y+s.Size().Height >= s.Content.MinSize().Height-theme.Padding()
+}
+
+func (s *MessageScroller) ScrollToBottom() {
+	// content is resized before scrolling because its size is updated
+	// only on next layout, so new messages would be left below view
+	contentSize := s.Content.MinSize()
+	if contentSize.Width < s.Size().Width {
+		contentSize.Width = s.Size().Width
+	}
+	s.Content.Resize(contentSize)
+	s.Offset.Y = 0
+	if contentSize.Height > s.Size().Height {
+		s.Offset.Y = contentSize.Height - s.Size().Height
+	}
+	s.Refresh()
+	s.notifyViewChanged()
+	if s.onBottomReached != nil {
+		s.onBottomReached()
+	}
+}
+
+func (s *MessageScroller) ScrollToObject(object fyne.CanvasObject) {
+	// moves view so object is at the top of it
+	s.Offset.Y = object.Position().Y
+	s.Refresh()
+	s.notifyViewChanged()
+}
+
+func (s *MessageScroller) ScrollByPages(pages int) {
+	// moves view by its height. Reaching top loads older messages
+	offset := s.Offset.Y + pages*s.Size().Height
+	if maxOffset := s.Content.MinSize().Height - s.Size().Height; offset > maxOffset {
+		offset = maxOffset
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	s.Offset.Y = offset
+	s.Refresh()
+	s.notifyViewChanged()
+	if pages < 0 && offset == 0 && s.onTopReached != nil {
+		s.onTopReached()
+	}
+	if pages > 0 && s.IsAtBottom() && s.onBottomReached != nil {
+		s.onBottomReached()
+	}
+}
+
+func (s *MessageScroller) KeepOffsetAfterPrepend(previousHeight int) {
+	// moves view down by height of prepended messages
+	s.Offset.Y += s.Content.MinSize().Height - previousHeight
+	s.Refresh()
+	s.notifyViewChanged()
+}
+
 type MessageObject struct {
-	container *fyne.Container
+	container     *fyne.Container // bubble aligned to left or right side
+	bubble        *fyne.Container // content over area which opens context menu
+	content       *fyne.Container
+	tapArea       *messageTapArea
+	body          *canvas.Rectangle // background of bubble
+	username      string
+	createdOn     int64
+	isOwn         bool
+	usernameLabel *tappableLabel // shows display name of user
+	header        *widget.Box
+	avatar        *AvatarImage
+	statusDot     *StatusDot
+	statusText    *canvas.Text // delivery status of own private message
+	status        string
+	failedRow     *widget.Box // shown under queued message which wasn't sent
+	messageId     int64       // 0 for queued message
+	cancelLoads   func()      // stops loading of previews when row is removed
 }
 
-func NewMessageObject(username string, text string, tappedUsername func()) *MessageObject {
-	messageObj := &MessageObject{}
+func NewMessageObject(username string, presence string, avatarPath string, text string,
+	textColor color.Color, tappedUsername func()) *MessageObject {
+	messageObj := &MessageObject{username: username}
 	mainContainer := fyne.NewContainerWithLayout(layout.NewVBoxLayout())
 	msgBody := canvas.NewRectangle(msgBodyColor)
-	msgBody.StrokeWidth = 3
+	msgBody.StrokeWidth = 1
 	msgBody.StrokeColor = msgStrokeColor
 
-	mainContainer.AddObject(msgBody)
-	mainContainer.AddObject(NewTappableLabel(username, tappedUsername))
+	messageObj.body = msgBody
+	messageObj.usernameLabel = NewTappableLabel(username, tappedUsername)
+	messageObj.avatar = NewAvatarImage(avatarPath)
+	messageObj.statusDot = NewStatusDot(presence)
+	messageObj.header = widget.NewHBox(messageObj.avatar.GetContainer(),
+		messageObj.statusDot.GetContainer(), messageObj.usernameLabel)
+	mainContainer.AddObject(messageObj.header)
 
-	lines := splitTextToLines(text, MAX_MSG_TEXT_LINE_LENGTH)
-	for _, textLine := range lines {
-		mainContainer.AddObject(canvas.NewText(textLine, color.White))
+	text = replaceEmojiShortcodes(text)
+	if urlRegexp.MatchString(text) {
+		for _, line := range newLinkedTextLines(text, textColor, MAX_MSG_TEXT_LINE_LENGTH) {
+			mainContainer.AddObject(line)
+		}
+	} else {
+		lines := splitTextToLines(text, MAX_MSG_TEXT_LINE_LENGTH)
+		for _, textLine := range lines {
+			mainContainer.AddObject(canvas.NewText(textLine, textColor))
+		}
 	}
 
-	messageObj.container = mainContainer
+	messageObj.content = mainContainer
+	messageObj.tapArea = newMessageTapArea()
+	messageObj.bubble = fyne.NewContainerWithLayout(layout.NewMaxLayout(),
+		messageObj.tapArea, msgBody,
+		fyne.NewContainerWithLayout(layout.NewPaddedLayout(), mainContainer))
+	messageObj.container = fyne.NewContainerWithLayout(layout.NewHBoxLayout(),
+		messageObj.bubble, layout.NewSpacer())
 	return messageObj
 }
 
+func (messageObj *MessageObject) SetOnContextMenu(onContextMenu func(pos fyne.Position)) {
+	// context menu is opened by right click or long press on message.
+	// Buttons of message keep their own actions
+	messageObj.usernameLabel.TappedSecondaryFunc = onContextMenu
+	messageObj.tapArea.TappedSecondaryFunc = onContextMenu
+}
+
+func (messageObj *MessageObject) SetDisplayName(displayName string) {
+	messageObj.usernameLabel.SetText(displayName)
+}
+
+func (messageObj *MessageObject) MarkOwn() {
+	// own messages are right-aligned with distinct color and without author
+	messageObj.isOwn = true
+	messageObj.body.FillColor = msgOwnBodyColor
+	messageObj.avatar.GetContainer().Hide()
+	messageObj.statusDot.GetContainer().Hide()
+	messageObj.usernameLabel.Hide()
+	messageObj.container.Objects = []fyne.CanvasObject{layout.NewSpacer(), messageObj.bubble}
+}
+
+func (messageObj *MessageObject) SetGrouped(grouped bool) {
+	// header isn't repeated for consecutive messages of same author
+	if grouped {
+		messageObj.header.Hide()
+	} else {
+		messageObj.header.Show()
+	}
+}
+
+func (messageObj *MessageObject) isGroupedWith(previous *MessageObject) bool {
+	// returns true if message continues group of previous message
+	if previous == nil || previous.username != messageObj.username {
+		return false
+	}
+	interval := messageObj.createdOn - previous.createdOn
+	return interval >= 0 && interval <= MESSAGE_GROUP_INTERVAL
+}
+
+func (messageObj *MessageObject) Highlight() {
+	// marks message which mentions current user
+	messageObj.body.FillColor = msgMentionColor
+	messageObj.usernameLabel.TextStyle = fyne.TextStyle{Bold: true}
+	messageObj.usernameLabel.Refresh()
+}
+
+func (messageObj *MessageObject) AddTime(createdOn int64) {
+	// shows sending time in local timezone after username
+	messageObj.createdOn = createdOn
+	createdTime := time.Unix(createdOn, 0).Local()
+	messageObj.header.Append(canvas.NewText(createdTime.Format(MESSAGE_TIME_FORMAT),
+		msgPendingTextColor))
+}
+
+func (messageObj *MessageObject) AddTaskCheck(done bool, onToggle func()) {
+	// puts checkbox before first line of task text
+	check := widget.NewCheck("", nil)
+	check.SetChecked(done)
+	check.OnChanged = func(bool) {
+		onToggle()
+	}
+	// objects: header, text lines...
+	objects := messageObj.content.Objects
+	objects[1] = widget.NewHBox(check, objects[1])
+	messageObj.content.Refresh()
+}
+
+func (messageObj *MessageObject) AddCollapsedMarker(onShow func()) {
+	// replaces text of message hidden by filter of user
+	showButton := widget.NewButton(i18n.T("Show anyway"), onShow)
+	showButton.Importance = widget.LowImportance
+	messageObj.content.AddObject(widget.NewHBox(
+		canvas.NewText(i18n.T("Message is hidden by filter"), msgPendingTextColor), showButton))
+}
+
+func (messageObj *MessageObject) AddEditedMarker() {
+	messageObj.content.AddObject(canvas.NewText(i18n.T("(edited)"), msgPendingTextColor))
+}
+
+func (messageObj *MessageObject) SetFailed(failed bool, onRetry func()) {
+	// queued message which server didn't acknowledge can be sent again by button
+	if messageObj.failedRow != nil {
+		messageObj.content.Remove(messageObj.failedRow)
+		messageObj.failedRow = nil
+	}
+	if failed {
+		messageObj.failedRow = widget.NewHBox(
+			canvas.NewText(i18n.T("Not sent"), msgFailedColor),
+			widget.NewButton(i18n.T("Retry"), onRetry))
+		messageObj.content.AddObject(messageObj.failedRow)
+	}
+	messageObj.content.Refresh()
+}
+
+func (messageObj *MessageObject) AddStatus(status string) {
+	messageObj.statusText = canvas.NewText("", msgPendingTextColor)
+	messageObj.content.AddObject(messageObj.statusText)
+	messageObj.SetStatus(status)
+}
+
+func (messageObj *MessageObject) SetStatus(status string) {
+	// shows sent, delivered or read status. Read status can't be changed
+	if messageObj.statusText == nil || messageObj.status == models.MESSAGE_STATE_READ {
+		return
+	}
+	messageObj.status = status
+	messageObj.statusText.Text = status
+	messageObj.statusText.Color = msgPendingTextColor
+	if status == models.MESSAGE_STATE_READ {
+		messageObj.statusText.Color = msgReadColor
+	}
+	messageObj.statusText.Refresh()
+}
+
+func (messageObj *MessageObject) AddAttachment(attachment models.Attachment,
+	onDownload func()) {
+	// adds file info with download button under message text
+	caption := fmt.Sprintf("%s (%s)", attachment.FileName, getReadableSize(attachment.Size))
+	downloadButton := widget.NewButton(i18n.T("Download"), onDownload)
+	messageObj.content.AddObject(widget.NewHBox(widget.NewLabel(caption), downloadButton))
+}
+
+func (messageObj *MessageObject) AddImagePreview(path string, onTap func()) {
+	messageObj.content.AddObject(NewImagePreview(path, onTap))
+}
+
+func (messageObj *MessageObject) AddLinkPreview(preview models.LinkPreview) {
+	// adds card with title, description and thumbnail of linked page.
+	// Title is hyperlink to page
+	link, err := url.Parse(preview.Url)
+	if utils.IsError(err) || preview.Title == "" && preview.Description == "" {
+		return
+	}
+	title := preview.Title
+	if title == "" {
+		title = link.Host
+	}
+	hyperlink := widget.NewHyperlink(title, link)
+	hyperlink.TextStyle = fyne.TextStyle{Bold: true}
+	info := widget.NewVBox(hyperlink)
+	description := []rune(preview.Description)
+	if len(description) > MAX_LINK_DESCRIPTION_LENGTH {
+		description = append(description[:MAX_LINK_DESCRIPTION_LENGTH], '…')
+	}
+	for _, line := range newLinkedTextLines(string(description), msgPendingTextColor,
+		MAX_MSG_TEXT_LINE_LENGTH) {
+		info.Append(line)
+	}
+	content := fyne.CanvasObject(info)
+	if preview.ImagePath != "" {
+		image := newCachedImage(preview.ImagePath)
+		image.FillMode = canvas.ImageFillContain
+		image.SetMinSize(fyne.NewSize(LINK_PREVIEW_IMAGE_SIZE, LINK_PREVIEW_IMAGE_SIZE))
+		content = widget.NewHBox(image, info)
+	}
+	messageObj.content.AddObject(widget.NewCard("", "", content))
+}
+
+func (messageObj *MessageObject) AddReplyPreview(preview models.ReplyPreview, onTap func()) {
+	// adds quote of replied message under username. Tap on quote jumps to message
+	caption := i18n.T("(deleted message)")
+	if preview.User.Username != "" {
+		caption = preview.User.Username + ": " + replaceEmojiShortcodes(preview.Text)
+	}
+	quote := NewTappableLabel("> "+caption, onTap)
+	quote.TextStyle = fyne.TextStyle{Italic: true}
+	// objects: header, text lines...
+	objects := messageObj.content.Objects
+	objects = append(objects[:1], append([]fyne.CanvasObject{quote}, objects[1:]...)...)
+	messageObj.content.Objects = objects
+}
+
+func (messageObj *MessageObject) AddForwardedMarker(author string) {
+	// shows author of original message under username
+	marker := canvas.NewText(i18n.Tf("Forwarded from %s", author), msgPendingTextColor)
+	marker.TextStyle = fyne.TextStyle{Italic: true}
+	objects := messageObj.content.Objects
+	objects = append(objects[:1], append([]fyne.CanvasObject{marker}, objects[1:]...)...)
+	messageObj.content.Objects = objects
+}
+
+func (messageObj *MessageObject) AddReactions(reactions []models.Reaction,
+	currentUserId int64, onTap func(emoji string)) {
+	// adds buttons with reaction counts. Reactions of current user are highlighted,
+	// tap adds or removes reaction
+	box := widget.NewHBox()
+	for _, reaction := range reactions {
+		emoji := reaction.Emoji
+		caption := fmt.Sprintf("%s %d", replaceEmojiShortcodes(emoji), len(reaction.UserIds))
+		button := widget.NewButton(caption, func() {
+			onTap(emoji)
+		})
+		button.Importance = widget.LowImportance
+		for _, userId := range reaction.UserIds {
+			if userId == currentUserId {
+				button.Importance = widget.HighImportance
+			}
+		}
+		box.Append(button)
+	}
+	messageObj.content.AddObject(box)
+}
+
+func getReadableSize(size int64) string {
+	// returns size in B, KB or MB
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+	if size < 1024*1024 {
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+}
+
+func (messageObj *MessageObject) stopLoads() {
+	// queued messages don't load previews
+	if messageObj.cancelLoads != nil {
+		messageObj.cancelLoads()
+	}
+}
+
 func (messageObj *MessageObject) getContainer() *fyne.Container {
 	return messageObj.container
 }
 
 type MessageList struct {
-	container        *fyne.Container
-	OnUsernameSelect func(user models.User)
+	container            *fyne.Container
+	OnUsernameSelect     func(user models.User)
+	OnAttachmentDownload func(attachment models.Attachment)
+	OnLoadImagePreview   func(ctx context.Context, msg models.SavedMessage, onLoaded func(path string))
+	OnLoadLinkPreview    func(ctx context.Context, msg models.SavedMessage, onLoaded func(models.LinkPreview))
+	OnImageTap           func(path string)
+	OnPlayVoice          func(attachment models.Attachment, onFinished func())
+	OnStopVoice          func()
+	OnMessageMenu        func(msg models.SavedMessage, pos fyne.Position)
+	OnReact              func(msg models.SavedMessage, emoji string)
+	OnReplyTap           func(reply models.SavedMessage)
+	OnToggleTask         func(msg models.SavedMessage)
+	OnRender             func(msg models.SavedMessage) (string, bool)
+	OnUserShown          func(user models.User)    // called for authors of created messages
+	Presence             map[string]string         // map: username -> presence state
+	Avatars              map[string]string         // map: username -> path of avatar
+	Profiles             map[string]models.Profile // map: username -> profile
+	CurrentUserId        int64                     // status is shown for own messages
+	CurrentUsername      string                    // mentions of user are highlighted
+	Filters              *utils.MessageMatcher     // nil if messages aren't filtered
+	messageObjects       map[int64]*MessageObject  // map: message id -> message
+	queuedObjects        map[int64]*MessageObject  // map: local id -> message
+
+	daySeparators map[string]fyne.CanvasObject // map: day -> label above its messages
+	lastDay       string                       // day of newest displayed message
+
+	savedMessages map[int64]models.SavedMessage // map: message id -> displayed message
+	expandedIds   map[int64]bool                // collapsed messages opened by user
+
+	containerObjects map[fyne.CanvasObject]*MessageObject // map: container -> message
+
+	// messages far from view are replaced by spacers of same height,
+	// so widgets exist only for rows near view
+	hiddenRows map[fyne.CanvasObject]int64 // map: spacer -> message id
+	spacers    map[int64]fyne.CanvasObject // map: message id -> spacer
 }
 
-func NewMessageList(OnUsernameSelect func(user models.User)) *MessageList {
+func NewMessageList(OnUsernameSelect func(user models.User),
+	OnAttachmentDownload func(attachment models.Attachment)) *MessageList {
 	list := &MessageList{
-		fyne.NewContainerWithLayout(layout.NewVBoxLayout()),
-		OnUsernameSelect}
+		container:            fyne.NewContainerWithLayout(layout.NewVBoxLayout()),
+		OnUsernameSelect:     OnUsernameSelect,
+		OnAttachmentDownload: OnAttachmentDownload,
+		Presence:             make(map[string]string),
+		Avatars:              make(map[string]string),
+		Profiles:             make(map[string]models.Profile),
+		messageObjects:       make(map[int64]*MessageObject),
+		queuedObjects:        make(map[int64]*MessageObject),
+		daySeparators:        make(map[string]fyne.CanvasObject),
+		savedMessages:        make(map[int64]models.SavedMessage),
+		expandedIds:          make(map[int64]bool),
+		containerObjects:     make(map[fyne.CanvasObject]*MessageObject),
+		hiddenRows:           make(map[fyne.CanvasObject]int64),
+		spacers:              make(map[int64]fyne.CanvasObject)}
 	return list
 }
 
 func (list *MessageList) Clear() {
+	for _, messageObject := range list.messageObjects {
+		messageObject.stopLoads()
+	}
 	var objects []fyne.CanvasObject
 
 	list.container.Objects = objects
+	list.messageObjects = make(map[int64]*MessageObject)
+	list.queuedObjects = make(map[int64]*MessageObject)
+	list.daySeparators = make(map[string]fyne.CanvasObject)
+	list.lastDay = ""
+	list.savedMessages = make(map[int64]models.SavedMessage)
+	list.expandedIds = make(map[int64]bool)
+	list.containerObjects = make(map[fyne.CanvasObject]*MessageObject)
+	list.hiddenRows = make(map[fyne.CanvasObject]int64)
+	list.spacers = make(map[int64]fyne.CanvasObject)
 	list.container.Refresh()
 }
 
-func (list *MessageList) AddMessage(msg models.SavedMessage) {
-	messageObject := NewMessageObject(msg.User.Username, msg.Text, func() {
-		list.OnUsernameSelect(msg.User)
+func getMessageDay(createdOn int64) string {
+	return time.Unix(createdOn, 0).Local().Format("2006-01-02")
+}
+
+func newDaySeparator(day string) fyne.CanvasObject {
+	// shows Today, Yesterday or date above messages of day
+	caption := day
+	now := time.Now()
+	if dayTime, err := time.ParseInLocation("2006-01-02", day, time.Local); err == nil {
+		caption = dayTime.Format(DAY_SEPARATOR_FORMAT)
+	}
+	if day == now.Format("2006-01-02") {
+		caption = i18n.T("Today")
+	} else if day == now.AddDate(0, 0, -1).Format("2006-01-02") {
+		caption = i18n.T("Yesterday")
+	}
+	return widget.NewLabelWithStyle(caption, fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+}
+
+func (list *MessageList) appendDaySeparator(day string) {
+	// adds separator if message of new day is added after displayed messages
+	if day == list.lastDay {
+		return
+	}
+	separator := newDaySeparator(day)
+	list.daySeparators[day] = separator
+	list.container.AddObject(separator)
+	list.lastDay = day
+}
+
+func (list *MessageList) isCollapsed(msg models.SavedMessage) bool {
+	// own messages aren't filtered
+	return list.Filters != nil && msg.User.Id != list.CurrentUserId && !list.expandedIds[msg.Id] &&
+		list.Filters.Match(msg.Text) == utils.FILTER_COLLAPSE
+}
+
+func (list *MessageList) newCollapsedMessageObject(msg models.SavedMessage) *MessageObject {
+	// shows only author and time of message matching filter. Its text,
+	// attachment and previews appear after click on button
+	messageObject := NewMessageObject(msg.User.Username, list.Presence[msg.User.Username],
+		list.Avatars[msg.User.Username], "", msgTextColor, func() {
+			list.OnUsernameSelect(msg.User)
+		})
+	messageObject.SetDisplayName(getDisplayName(list.Profiles, msg.User.Username))
+	messageObject.AddTime(msg.CreatedOn)
+	messageObject.AddCollapsedMarker(func() {
+		list.expandedIds[msg.Id] = true
+		list.UpdateMessage(msg)
 	})
+	if list.OnMessageMenu != nil {
+		messageObject.SetOnContextMenu(func(pos fyne.Position) {
+			list.OnMessageMenu(msg, pos)
+		})
+	}
+	messageObject.messageId = msg.Id
+	list.messageObjects[msg.Id] = messageObject
+	list.savedMessages[msg.Id] = msg
+	list.containerObjects[messageObject.container] = messageObject
+	return messageObject
+}
+
+func (list *MessageList) newSavedMessageObject(msg models.SavedMessage) *MessageObject {
+	if list.isCollapsed(msg) {
+		return list.newCollapsedMessageObject(msg)
+	}
+	// notes of current user starting with "[] " are shown as tasks
+	text, textColor := msg.Text, msgTextColor
+	if msg.IsSticker() { // name of sticker file isn't shown
+		text = ""
+	}
+	title, done, isTask := utils.ParseTask(msg.Text)
+	isTask = isTask && msg.ChatId == list.CurrentUserId && list.OnToggleTask != nil
+	if isTask {
+		text = title
+		if done {
+			textColor = msgPendingTextColor
+		}
+	} else if text != "" && list.OnRender != nil {
+		if rendered, ok := list.OnRender(msg); ok {
+			text = rendered
+		}
+	}
+	messageObject := NewMessageObject(msg.User.Username, list.Presence[msg.User.Username],
+		list.Avatars[msg.User.Username], text, textColor, func() {
+			list.OnUsernameSelect(msg.User)
+		})
+	if isTask {
+		messageObject.AddTaskCheck(done, func() {
+			list.OnToggleTask(msg)
+		})
+	}
+	if msg.IsForwarded() {
+		messageObject.AddForwardedMarker(getDisplayName(list.Profiles, msg.ForwardedFrom))
+	}
+	messageObject.SetDisplayName(getDisplayName(list.Profiles, msg.User.Username))
+	messageObject.AddTime(msg.CreatedOn)
+	if list.OnUserShown != nil {
+		list.OnUserShown(msg.User)
+	}
+	if msg.IsReply() {
+		messageObject.AddReplyPreview(msg.ReplyTo, func() {
+			if list.OnReplyTap != nil {
+				list.OnReplyTap(msg)
+			}
+		})
+	}
+	if msg.IsEdited() {
+		messageObject.AddEditedMarker()
+	}
+	if msg.User.Id == list.CurrentUserId {
+		messageObject.MarkOwn()
+	}
+	if msg.User.Id != list.CurrentUserId && list.CurrentUsername != "" &&
+		utils.IsMentioned(msg.Text, list.CurrentUsername) {
+		messageObject.Highlight()
+	}
+	isPrivate := msg.GetChatType() == "private" && msg.ChatId != msg.User.Id // not notes
+	if isPrivate && msg.User.Id == list.CurrentUserId {
+		messageObject.AddStatus(msg.Status)
+	}
+	if list.OnMessageMenu != nil {
+		messageObject.SetOnContextMenu(func(pos fyne.Position) {
+			list.OnMessageMenu(msg, pos)
+		})
+	}
+	duration, isVoice := utils.GetVoiceDuration(msg.Attachment.FileName)
+	if msg.HasAttachment() && isVoice && list.OnPlayVoice != nil {
+		messageObject.AddVoiceMessage(duration, func(onFinished func()) {
+			list.OnPlayVoice(msg.Attachment, onFinished)
+		}, list.OnStopVoice)
+	} else if msg.HasAttachment() && !msg.IsSticker() {
+		messageObject.AddAttachment(msg.Attachment, func() {
+			list.OnAttachmentDownload(msg.Attachment)
+		})
+	}
+	if len(msg.Reactions) > 0 && list.OnReact != nil {
+		messageObject.AddReactions(msg.Reactions, list.CurrentUserId, func(emoji string) {
+			list.OnReact(msg, emoji)
+		})
+	}
+	// previews are loaded only for rows near view. Loading is canceled
+	// when row is hidden or replaced
+	ctx, cancel := context.WithCancel(context.Background())
+	messageObject.cancelLoads = cancel
+	if list.OnLoadImagePreview != nil {
+		// preview is added when image is loaded
+		list.OnLoadImagePreview(ctx, msg, func(path string) {
+			if ctx.Err() != nil {
+				return
+			}
+			if msg.IsSticker() {
+				messageObject.AddSticker(path)
+			} else {
+				messageObject.AddImagePreview(path, func() {
+					list.OnImageTap(path)
+				})
+			}
+			list.Refresh()
+		})
+	}
+	if list.OnLoadLinkPreview != nil {
+		list.OnLoadLinkPreview(ctx, msg, func(preview models.LinkPreview) {
+			if ctx.Err() != nil {
+				return
+			}
+			messageObject.AddLinkPreview(preview)
+			list.Refresh()
+		})
+	}
+	messageObject.messageId = msg.Id
+	list.messageObjects[msg.Id] = messageObject
+	list.savedMessages[msg.Id] = msg
+	list.containerObjects[messageObject.container] = messageObject
+	return messageObject
+}
+
+func (list *MessageList) appendMessageObject(messageObject *MessageObject) {
+	// adds message after last displayed one. Day separator ends group of messages
+	var previous *MessageObject
+	if count := len(list.container.Objects); count > 0 {
+		previous = list.containerObjects[list.container.Objects[count-1]]
+	}
+	messageObject.SetGrouped(messageObject.isGroupedWith(previous))
 	list.container.AddObject(messageObject.container)
 }
 
+func (list *MessageList) updateGrouping() {
+	// shows headers of messages which start groups after insertion or removal
+	var previous *MessageObject
+	for _, object := range list.container.Objects {
+		messageObject, ok := list.containerObjects[object]
+		if ok {
+			messageObject.SetGrouped(messageObject.isGroupedWith(previous))
+		}
+		previous = messageObject // nil for separators and labels
+	}
+}
+
+func (list *MessageList) AddMessage(msg models.SavedMessage) bool {
+	// returns false if message with same id is displayed already.
+	// It's updated then instead of adding duplicate row
+	if _, ok := list.savedMessages[msg.Id]; ok {
+		list.UpdateMessage(msg)
+		return false
+	}
+	messageObject := list.newSavedMessageObject(msg)
+	list.appendDaySeparator(getMessageDay(msg.CreatedOn))
+	list.appendMessageObject(messageObject)
+	return true
+}
+
+func (list *MessageList) UpdateMessage(msg models.SavedMessage) {
+	// replaces displayed message with same id. Hidden row is created
+	// from new version when it's near view
+	if _, ok := list.spacers[msg.Id]; ok {
+		list.savedMessages[msg.Id] = msg
+		return
+	}
+	oldObject, ok := list.messageObjects[msg.Id]
+	if !ok { // not displayed
+		return
+	}
+	for i, object := range list.container.Objects {
+		if object == oldObject.container {
+			delete(list.containerObjects, oldObject.container)
+			oldObject.stopLoads()
+			list.container.Objects[i] = list.newSavedMessageObject(msg).container
+			break
+		}
+	}
+	list.updateGrouping()
+	list.container.Refresh()
+}
+
+func (list *MessageList) Redraw() {
+	// recreates displayed messages with colors of current theme
+	for _, msg := range list.savedMessages {
+		list.UpdateMessage(msg)
+	}
+}
+
+func (list *MessageList) UpdatePresence(username string) {
+	// recolors presence dots of displayed messages of user
+	state := list.Presence[username]
+	for _, messageObject := range list.messageObjects {
+		if messageObject.username == username {
+			messageObject.statusDot.SetState(state)
+		}
+	}
+	for _, messageObject := range list.queuedObjects {
+		if messageObject.username == username {
+			messageObject.statusDot.SetState(state)
+		}
+	}
+}
+
+func (list *MessageList) UpdateAvatar(username string) {
+	// replaces avatars of displayed messages of user
+	path := list.Avatars[username]
+	for _, messageObject := range list.messageObjects {
+		if messageObject.username == username {
+			messageObject.avatar.SetPath(path)
+		}
+	}
+	for _, messageObject := range list.queuedObjects {
+		if messageObject.username == username {
+			messageObject.avatar.SetPath(path)
+		}
+	}
+}
+
+func (list *MessageList) UpdateDisplayName(username string) {
+	displayName := getDisplayName(list.Profiles, username)
+	for _, messageObject := range list.messageObjects {
+		if messageObject.username == username {
+			messageObject.SetDisplayName(displayName)
+		}
+	}
+	for _, messageObject := range list.queuedObjects {
+		if messageObject.username == username {
+			messageObject.SetDisplayName(displayName)
+		}
+	}
+}
+
+func (list *MessageList) RefreshMessage(id int64) {
+	// creates displayed message again, e.g. after its text was rendered
+	if msg, ok := list.savedMessages[id]; ok {
+		list.UpdateMessage(msg)
+	}
+}
+
+func (list *MessageList) UpdateMessagesStatus(lastMessageId int64, status string) {
+	// sets status of displayed own messages up to lastMessageId
+	for id, messageObject := range list.messageObjects {
+		if id <= lastMessageId {
+			messageObject.SetStatus(status)
+		}
+	}
+	for id := range list.spacers {
+		if msg := list.savedMessages[id]; id <= lastMessageId && msg.User.Id == list.CurrentUserId {
+			msg.Status = status
+			list.savedMessages[id] = msg
+		}
+	}
+}
+
+func (list *MessageList) GetMessageObject(id int64) (fyne.CanvasObject, bool) {
+	// spacer is returned for hidden row, so view can be scrolled to it
+	if spacer, ok := list.spacers[id]; ok {
+		return spacer, true
+	}
+	messageObject, ok := list.messageObjects[id]
+	if !ok {
+		return nil, false
+	}
+	return messageObject.container, true
+}
+
+func (list *MessageList) RemoveMessage(id int64) {
+	if spacer, ok := list.spacers[id]; ok {
+		delete(list.spacers, id)
+		delete(list.hiddenRows, spacer)
+		delete(list.savedMessages, id)
+		list.container.Remove(spacer)
+		list.updateGrouping()
+		return
+	}
+	messageObject, ok := list.messageObjects[id]
+	if !ok { // not displayed
+		return
+	}
+	delete(list.messageObjects, id)
+	delete(list.savedMessages, id)
+	delete(list.containerObjects, messageObject.container)
+	messageObject.stopLoads()
+	list.container.Remove(messageObject.container)
+	list.updateGrouping()
+}
+
+func (list *MessageList) GetLastMessageOf(userId int64) (models.SavedMessage, bool) {
+	// returns newest displayed message of user
+	var lastMsg models.SavedMessage
+	for id, msg := range list.savedMessages {
+		if msg.User.Id == userId && id > lastMsg.Id {
+			lastMsg = msg
+		}
+	}
+	return lastMsg, lastMsg.Id != 0
+}
+
+func (list *MessageList) AddQueuedMessage(msg models.QueuedMessage) {
+	messageObject := NewMessageObject(msg.User.Username, list.Presence[msg.User.Username],
+		list.Avatars[msg.User.Username], msg.Text, msgPendingTextColor, func() {
+			list.OnUsernameSelect(msg.User)
+		})
+	messageObject.SetDisplayName(getDisplayName(list.Profiles, msg.User.Username))
+	messageObject.MarkOwn()
+	messageObject.createdOn = utils.GetTimestampNow()
+	list.queuedObjects[msg.LocalId] = messageObject
+	list.containerObjects[messageObject.container] = messageObject
+	list.appendDaySeparator(getMessageDay(messageObject.createdOn))
+	list.appendMessageObject(messageObject)
+}
+
+func (list *MessageList) SetQueuedFailed(localId int64, failed bool, onRetry func()) {
+	if messageObject, ok := list.queuedObjects[localId]; ok {
+		messageObject.SetFailed(failed, onRetry)
+	}
+}
+
+func (list *MessageList) ConfirmQueuedMessage(localId int64, msg models.SavedMessage) {
+	// replaces queued message in place, so it isn't moved before next messages
+	queuedObject, ok := list.queuedObjects[localId]
+	if !ok { // not displayed in current channel
+		return
+	}
+	delete(list.queuedObjects, localId)
+	delete(list.containerObjects, queuedObject.container)
+	for i, object := range list.container.Objects {
+		if object == queuedObject.container {
+			list.container.Objects[i] = list.newSavedMessageObject(msg).container
+			break
+		}
+	}
+	list.updateGrouping()
+	list.container.Refresh()
+}
+
+func (list *MessageList) RemoveQueuedMessage(localId int64) {
+	messageObject, ok := list.queuedObjects[localId]
+	if !ok { // not displayed in current channel
+		return
+	}
+	delete(list.queuedObjects, localId)
+	delete(list.containerObjects, messageObject.container)
+	list.container.Remove(messageObject.container)
+	list.updateGrouping()
+}
+
 func (list *MessageList) SetMessages(messages []models.SavedMessage) {
 	for _, msg := range messages {
 		list.AddMessage(msg)
 	}
 }
 
+func (list *MessageList) PrependMessages(messages []models.SavedMessage) {
+	// inserts older messages before displayed ones. Separator of oldest
+	// displayed day is moved above its prepended messages.
+	// Messages which are displayed already are skipped
+	var objects []fyne.CanvasObject
+	previousDay := ""
+	for _, msg := range messages {
+		if _, ok := list.savedMessages[msg.Id]; ok {
+			continue
+		}
+		messageObject := list.newSavedMessageObject(msg)
+		if day := getMessageDay(msg.CreatedOn); day != previousDay {
+			if separator, ok := list.daySeparators[day]; ok {
+				list.container.Remove(separator)
+			}
+			list.daySeparators[day] = newDaySeparator(day)
+			objects = append(objects, list.daySeparators[day])
+			previousDay = day
+			if list.lastDay == "" {
+				list.lastDay = day
+			}
+		}
+		objects = append(objects, messageObject.container)
+	}
+	list.container.Objects = append(objects, list.container.Objects...)
+	list.updateGrouping()
+	list.container.Refresh()
+}
+
+func (list *MessageList) ShowRowsInView(top int, bottom int) {
+	// creates messages near visible part of list and replaces other ones
+	// by spacers. Rows which weren't laid out yet are kept
+	margin := int(float64(bottom-top) * VISIBLE_ROWS_MARGIN)
+	top, bottom = top-margin, bottom+margin
+	changed := false
+	for i, object := range list.container.Objects {
+		position, size := object.Position(), object.Size()
+		isNear := position.Y+size.Height >= top && position.Y <= bottom
+		if id, ok := list.hiddenRows[object]; ok && isNear {
+			delete(list.hiddenRows, object)
+			delete(list.spacers, id)
+			list.container.Objects[i] = list.newSavedMessageObject(list.savedMessages[id]).container
+			changed = true
+			continue
+		}
+		messageObject, ok := list.containerObjects[object]
+		if !ok || isNear || messageObject.messageId == 0 || size.Height == 0 {
+			continue
+		}
+		spacer := canvas.NewRectangle(color.Transparent)
+		spacer.SetMinSize(fyne.NewSize(0, size.Height))
+		delete(list.containerObjects, object)
+		delete(list.messageObjects, messageObject.messageId)
+		messageObject.stopLoads()
+		list.hiddenRows[spacer] = messageObject.messageId
+		list.spacers[messageObject.messageId] = spacer
+		list.container.Objects[i] = spacer
+		changed = true
+	}
+	if changed {
+		list.updateGrouping()
+		list.container.Refresh()
+	}
+}
+
 func (list *MessageList) AddLabel(text string) {
 	list.container.AddObject(widget.NewLabel(text))
 }
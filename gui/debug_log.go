@@ -0,0 +1,47 @@
+// debug_log.go
+package gui
+
+import (
+	"strings"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/logger"
+)
+
+func (gui *ChatGui) ShowDebugLogWindow() {
+	// shows last lines of log. New lines are added while window is opened
+	if gui.DebugLogWindow != nil {
+		gui.DebugLogWindow.RequestFocus()
+		return
+	}
+	window := gui.App.NewWindow(i18n.T("Debug log"))
+	logText := widget.NewLabel(strings.Join(logger.GetHistory(), "\n"))
+	logText.TextStyle = fyne.TextStyle{Monospace: true}
+	scroller := widget.NewScrollContainer(logText)
+	logger.SetOnLine(func(string) {
+		logText.SetText(strings.Join(logger.GetHistory(), "\n"))
+		scroller.ScrollToBottom()
+	})
+
+	hint := i18n.Tf("Log files: %s", logger.GetLogsDir())
+	if !logger.IsVerbose() {
+		hint += "\n" + i18n.T("Run client with --verbose to log socket events.")
+	}
+	copyButton := widget.NewButton(i18n.T("Copy"), func() {
+		window.Clipboard().SetContent(logText.Text)
+	})
+	bottom := container.NewBorder(nil, nil, nil, copyButton, widget.NewLabel(hint))
+	window.SetContent(container.NewBorder(nil, bottom, nil, nil, scroller))
+	window.SetOnClosed(func() {
+		logger.SetOnLine(nil)
+		gui.DebugLogWindow = nil
+	})
+	window.Resize(fyne.NewSize(WIDTH*2/3, HEIGHT*2/3))
+	gui.DebugLogWindow = window
+	window.Show()
+	scroller.ScrollToBottom()
+}
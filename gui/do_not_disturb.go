@@ -0,0 +1,32 @@
+// do_not_disturb.go
+package gui
+
+import (
+	"chat/i18n"
+)
+
+func (gui *ChatGui) SetOnSetDoNotDisturb(onSetDoNotDisturb func(bool)) {
+	gui.OnSetDoNotDisturb = func(enabled bool) {
+		gui.dispatch(func() { onSetDoNotDisturb(enabled) })
+	}
+}
+
+func (gui *ChatGui) setDoNotDisturb(enabled bool) {
+	if !gui.settingDoNotDisturb && gui.OnSetDoNotDisturb != nil {
+		gui.OnSetDoNotDisturb(enabled)
+	}
+}
+
+func (gui *ChatGui) SetDoNotDisturb(enabled bool, scheduled bool) {
+	// shows mode chosen by user. Scheduled mode is shown in caption only,
+	// so turning check off doesn't fight with schedule
+	gui.settingDoNotDisturb = true
+	gui.DoNotDisturbCheck.SetChecked(enabled)
+	gui.settingDoNotDisturb = false
+	caption := i18n.T("Do not disturb")
+	if scheduled && !enabled {
+		caption = i18n.T("Do not disturb (scheduled)")
+	}
+	gui.DoNotDisturbCheck.Text = caption
+	gui.DoNotDisturbCheck.Refresh()
+}
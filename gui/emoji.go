@@ -0,0 +1,102 @@
+// emoji.go
+package gui
+
+import (
+	"regexp"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+)
+
+const EMOJI_PICKER_COLUMNS int = 8
+
+type emoji struct {
+	Shortcode string
+	Glyph     string
+}
+
+// emoji in order of picker
+var emojiList = []emoji{
+	{":smile:", "😄"}, {":grin:", "😁"}, {":joy:", "😂"}, {":wink:", "😉"},
+	{":blush:", "😊"}, {":heart_eyes:", "😍"}, {":kiss:", "😘"}, {":yum:", "😋"},
+	{":sunglasses:", "😎"}, {":thinking:", "🤔"}, {":neutral:", "😐"}, {":unamused:", "😒"},
+	{":sweat:", "😓"}, {":cry:", "😢"}, {":sob:", "😭"}, {":angry:", "😠"},
+	{":scream:", "😱"}, {":sleeping:", "😴"}, {":innocent:", "😇"}, {":smirk:", "😏"},
+	{":thumbsup:", "👍"}, {":thumbsdown:", "👎"}, {":ok_hand:", "👌"}, {":clap:", "👏"},
+	{":wave:", "👋"}, {":pray:", "🙏"}, {":muscle:", "💪"}, {":eyes:", "👀"},
+	{":heart:", "❤"}, {":broken_heart:", "💔"}, {":fire:", "🔥"}, {":star:", "⭐"},
+	{":sparkles:", "✨"}, {":tada:", "🎉"}, {":rocket:", "🚀"}, {":100:", "💯"},
+	{":check:", "✅"}, {":x:", "❌"}, {":warning:", "⚠"}, {":coffee:", "☕"},
+}
+
+var emojiByShortcode = buildEmojiMap()
+var shortcodeRegexp = regexp.MustCompile(`:[a-z0-9_+\-]+:`)
+
+func buildEmojiMap() map[string]string {
+	result := make(map[string]string)
+	for _, e := range emojiList {
+		result[e.Shortcode] = e.Glyph
+	}
+	return result
+}
+
+func replaceEmojiShortcodes(text string) string {
+	// replaces known :shortcodes: with emoji glyphs
+	return shortcodeRegexp.ReplaceAllStringFunc(text, func(shortcode string) string {
+		if glyph, ok := emojiByShortcode[shortcode]; ok {
+			return glyph
+		}
+		return shortcode
+	})
+}
+
+func (gui *ChatGui) ShowReactionPicker(msg models.SavedMessage, pos fyne.Position) {
+	// shows popup with emoji at position of context menu.
+	// Chosen emoji is added to reactions of message
+	var popup *widget.PopUp
+	grid := fyne.NewContainerWithLayout(layout.NewGridLayout(EMOJI_PICKER_COLUMNS))
+	for _, e := range emojiList {
+		shortcode := e.Shortcode
+		grid.AddObject(widget.NewButton(e.Glyph, func() {
+			popup.Hide()
+			if gui.OnReact != nil {
+				gui.OnReact(msg.Id, shortcode)
+			}
+		}))
+	}
+	popup = widget.NewPopUp(grid, gui.Window.Canvas())
+	popup.ShowAtPosition(pos)
+}
+
+func (gui *ChatGui) ShowEmojiPicker(input *EnterEntry, button fyne.CanvasObject) {
+	// shows popup with emoji above button.
+	// Shortcode of chosen emoji is appended to input, stickers are sent at once
+	var popup *widget.PopUp
+	grid := fyne.NewContainerWithLayout(layout.NewGridLayout(EMOJI_PICKER_COLUMNS))
+	for _, e := range emojiList {
+		shortcode := e.Shortcode
+		grid.AddObject(widget.NewButton(e.Glyph, func() {
+			input.SetText(input.Text + shortcode)
+			popup.Hide()
+			gui.Window.Canvas().Focus(input)
+		}))
+	}
+	content := fyne.CanvasObject(grid)
+	if gui.hasStickers() {
+		content = container.NewAppTabs(container.NewTabItem(i18n.T("Emoji"), grid),
+			container.NewTabItem(i18n.T("Stickers"), gui.newStickerPicker(func() {
+				popup.Hide()
+			})))
+	}
+	popup = widget.NewPopUp(content, gui.Window.Canvas())
+
+	buttonPos := fyne.CurrentApp().Driver().AbsolutePositionForObject(button)
+	popupSize := popup.MinSize()
+	popup.ShowAtPosition(fyne.NewPos(buttonPos.X+button.Size().Width-popupSize.Width,
+		buttonPos.Y-popupSize.Height))
+}
@@ -0,0 +1,48 @@
+// image_resources.go
+package gui
+
+import (
+	"io/ioutil"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/canvas"
+
+	"chat/utils"
+)
+
+const IMAGE_RESOURCES_CACHE_SIZE int64 = 32 * 1024 * 1024 // 32 MB
+
+// contents of recently shown images, so recreated rows don't read disk
+var imageResources = utils.NewLRUCache(IMAGE_RESOURCES_CACHE_SIZE)
+
+func loadImageResource(path string) fyne.Resource {
+	// returns nil if file can't be read. Files in cache don't change:
+	// names of avatars and cached images depend on their content or url
+	if value, ok := imageResources.Get(path); ok {
+		return value.(fyne.Resource)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	resource := fyne.NewStaticResource(path, data)
+	imageResources.Add(path, resource, int64(len(data)))
+	return resource
+}
+
+func setImagePath(image *canvas.Image, path string) {
+	// image is read from file by fyne if it isn't loaded to memory
+	image.File, image.Resource = path, nil
+	if path == "" {
+		return
+	}
+	if resource := loadImageResource(path); resource != nil {
+		image.File, image.Resource = "", resource
+	}
+}
+
+func newCachedImage(path string) *canvas.Image {
+	image := &canvas.Image{}
+	setImagePath(image, path)
+	return image
+}
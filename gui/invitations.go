@@ -0,0 +1,147 @@
+// invitations.go
+package gui
+
+import (
+	"sort"
+	"strings"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+)
+
+func (gui *ChatGui) SetOnInviteToChannel(onInviteToChannel func(string, models.User)) {
+	gui.OnInviteToChannel = func(title string, user models.User) {
+		gui.dispatch(func() { onInviteToChannel(title, user) })
+	}
+}
+
+func (gui *ChatGui) SetOnJoinChannel(onJoinChannel func(string)) {
+	gui.OnJoinChannel = func(title string) {
+		gui.dispatch(func() { onJoinChannel(title) })
+	}
+}
+
+func (gui *ChatGui) SetOnAnswerInvitation(onAnswerInvitation func(int64, bool)) {
+	gui.OnAnswerInvitation = func(invitationId int64, isAccepted bool) {
+		gui.dispatch(func() { onAnswerInvitation(invitationId, isAccepted) })
+	}
+}
+
+func (gui *ChatGui) hasInvitations() bool {
+	return gui.ServerFeatures[models.FEATURE_INVITATIONS] && gui.OnAnswerInvitation != nil
+}
+
+func (gui *ChatGui) SetInvitations(invitations []models.Invitation) {
+	// replaces inbox. Button above channels is shown while something waits for answer
+	gui.Invitations = invitations
+	if len(invitations) == 0 {
+		gui.InvitationsButton.Hide()
+	} else {
+		gui.InvitationsButton.SetText(i18n.Tf("Invitations (%d)", len(invitations)))
+		gui.InvitationsButton.Show()
+	}
+	gui.refreshInvitations()
+}
+
+func (gui *ChatGui) refreshInvitations() {
+	// rebuilds opened inbox dialog
+	if gui.InvitationsList == nil {
+		return
+	}
+	var objects []fyne.CanvasObject
+	for _, invitation := range gui.Invitations {
+		invitation := invitation
+		text := i18n.Tf("%s invites you to %s", invitation.FromUser.Username,
+			invitation.Channel.Title)
+		if invitation.Kind == models.INVITATION_JOIN {
+			text = i18n.Tf("%s asks to join %s", invitation.User.Username,
+				invitation.Channel.Title)
+		}
+		row := container.NewHBox(widget.NewLabel(text), layout.NewSpacer(),
+			widget.NewButton(i18n.T("Accept"), func() {
+				gui.OnAnswerInvitation(invitation.Id, true)
+			}),
+			widget.NewButton(i18n.T("Decline"), func() {
+				gui.OnAnswerInvitation(invitation.Id, false)
+			}))
+		objects = append(objects, row)
+	}
+	if len(objects) == 0 {
+		objects = append(objects, widget.NewLabel(i18n.T("No invitations.")))
+	}
+	gui.InvitationsList.Objects = objects
+	gui.InvitationsList.Refresh()
+}
+
+func (gui *ChatGui) ShowInvitationsDialog() {
+	// inbox with invitations to groups and join requests to own groups
+	if !gui.hasInvitations() {
+		gui.ShowError("Server doesn't support invitations.")
+		return
+	}
+	gui.InvitationsList = container.NewVBox()
+	gui.refreshInvitations()
+	scroller := widget.NewVScrollContainer(gui.InvitationsList)
+	scroller.SetMinSize(fyne.NewSize(400, 200))
+	inboxDialog := dialog.NewCustom(i18n.T("Invitations"), i18n.T("Close"), scroller, gui.Window)
+	inboxDialog.SetOnClosed(func() {
+		gui.InvitationsList = nil
+	})
+	inboxDialog.Show()
+}
+
+func (gui *ChatGui) ShowInviteUserDialog(title string) {
+	// invited user can be chosen among known users. Server skips members
+	var users []models.User
+	for _, user := range gui.KnownUsers {
+		if user.Id != gui.CurrentUser.Id {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	var usernames []string
+	for _, user := range users {
+		usernames = append(usernames, user.Username)
+	}
+	if len(usernames) == 0 {
+		gui.ShowInfo("No known users to invite. Find user first.")
+		return
+	}
+	userSelect := widget.NewSelect(usernames, nil)
+	dialog.ShowCustomConfirm(i18n.Tf("Invite to %s", title), i18n.T("Invite"), i18n.T("Cancel"),
+		userSelect, func(result bool) {
+			if !result || userSelect.Selected == "" {
+				return
+			}
+			for _, user := range users {
+				if user.Username == userSelect.Selected {
+					gui.OnInviteToChannel(title, user)
+				}
+			}
+		}, gui.Window)
+}
+
+func (gui *ChatGui) ShowJoinChannelDialog() {
+	// group with known title can be joined after owner accepts request
+	if !gui.hasInvitations() || gui.OnJoinChannel == nil {
+		gui.ShowError("Server doesn't support invitations.")
+		return
+	}
+	inputTitle := widget.NewEntry()
+	inputTitle.SetPlaceHolder(i18n.T("group name"))
+	dialog.ShowCustomConfirm(i18n.T("Join group"), i18n.T("Send request"), i18n.T("Cancel"),
+		inputTitle, func(result bool) {
+			title := strings.TrimSpace(inputTitle.Text)
+			if result && title != "" {
+				gui.OnJoinChannel(title)
+			}
+		}, gui.Window)
+}
@@ -0,0 +1,98 @@
+// member_list.go
+package gui
+
+import (
+	"sort"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+)
+
+type MemberList struct {
+	container     *fyne.Container
+	Members       []models.ChannelMember
+	Presence      map[string]string // map: username -> presence state
+	OnSelect      func(user models.User)
+	OnContextMenu func(member models.ChannelMember, pos fyne.Position) // optional
+}
+
+func NewMemberList(onSelect func(user models.User)) *MemberList {
+	list := &MemberList{
+		container: fyne.NewContainerWithLayout(layout.NewVBoxLayout()),
+		OnSelect:  onSelect}
+	return list
+}
+
+func (list *MemberList) SetMembers(members []models.ChannelMember) {
+	// shows online members first, both parts are sorted by username
+	sort.SliceStable(members, func(i, j int) bool {
+		if members[i].Online != members[j].Online {
+			return members[i].Online
+		}
+		return members[i].User.Username < members[j].User.Username
+	})
+	list.Members = members
+	list.Refresh()
+}
+
+func (list *MemberList) GetRole(userId int64) string {
+	// returns empty role if user isn't member
+	for _, member := range list.Members {
+		if member.User.Id == userId {
+			return member.Role
+		}
+	}
+	return ""
+}
+
+func (list *MemberList) CountOnline() int {
+	count := 0
+	for _, member := range list.Members {
+		if member.Online {
+			count++
+		}
+	}
+	return count
+}
+
+func (list *MemberList) GetContainer() *fyne.Container {
+	return list.container
+}
+
+func (list *MemberList) Refresh() {
+	// rebuilds member buttons with presence indicators.
+	// Owner and admins are marked after username
+	var objects []fyne.CanvasObject
+	for _, member := range list.Members {
+		member := member
+		user := member.User
+		caption := user.Username
+		if member.Role == models.ROLE_OWNER {
+			caption += " " + i18n.T("(owner)")
+		} else if member.Role == models.ROLE_ADMIN {
+			caption += " " + i18n.T("(admin)")
+		}
+		button := newChannelButton(caption, func() {
+			list.OnSelect(user)
+		})
+		if list.OnContextMenu != nil {
+			button.TappedSecondaryFunc = func(pos fyne.Position) {
+				list.OnContextMenu(member, pos)
+			}
+		}
+		button.Alignment = widget.ButtonAlignLeading
+		button.Importance = widget.LowImportance
+		state, ok := list.Presence[user.Username]
+		if !ok && member.Online {
+			state = models.PRESENCE_ACTIVE
+		}
+		dot := NewStatusDot(state)
+		objects = append(objects, widget.NewHBox(dot.GetContainer(), button))
+	}
+	list.container.Objects = objects
+	list.container.Refresh()
+}
@@ -0,0 +1,86 @@
+// mentions.go
+package gui
+
+import (
+	"sort"
+	"strings"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+)
+
+const MAX_MENTION_SUGGESTIONS int = 5
+
+func getMentionQuery(text string) (string, bool) {
+	// returns typed part of username if last word of text starts with @
+	words := strings.Fields(text)
+	if len(words) == 0 || strings.HasSuffix(text, " ") {
+		return "", false
+	}
+	lastWord := words[len(words)-1]
+	if !strings.HasPrefix(lastWord, "@") {
+		return "", false
+	}
+	return strings.TrimPrefix(lastWord, "@"), true
+}
+
+func (gui *ChatGui) getMentionSuggestions(query string) []string {
+	// returns sorted usernames of known users starting with query
+	var usernames []string
+	query = strings.ToLower(query)
+	for _, user := range gui.KnownUsers {
+		if user.Id == gui.CurrentUser.Id {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(user.Username), query) {
+			usernames = append(usernames, user.Username)
+		}
+	}
+	sort.Strings(usernames)
+	if len(usernames) > MAX_MENTION_SUGGESTIONS {
+		usernames = usernames[:MAX_MENTION_SUGGESTIONS]
+	}
+	return usernames
+}
+
+func (gui *ChatGui) UpdateMentionAutocomplete(input *EnterEntry) {
+	// shows usernames above input while @username is being typed.
+	// Chosen username replaces typed part of it
+	gui.HideSuggestions()
+	query, ok := getMentionQuery(input.Text)
+	if !ok {
+		return
+	}
+	usernames := gui.getMentionSuggestions(query)
+	if len(usernames) == 0 {
+		return
+	}
+
+	list := fyne.NewContainerWithLayout(layout.NewVBoxLayout())
+	for _, username := range usernames {
+		username := username
+		list.AddObject(widget.NewButton("@"+username, func() {
+			text := strings.TrimSuffix(input.Text, query)
+			gui.HideSuggestions()
+			input.SetText(text + username + " ")
+			gui.Window.Canvas().Focus(input)
+		}))
+	}
+	gui.showSuggestions(input, list)
+}
+
+func (gui *ChatGui) showSuggestions(input *EnterEntry, list fyne.CanvasObject) {
+	// popup above input is closed by Escape or by choice
+	popup := widget.NewPopUp(list, gui.Window.Canvas())
+	inputPos := fyne.CurrentApp().Driver().AbsolutePositionForObject(input)
+	popup.ShowAtPosition(fyne.NewPos(inputPos.X, inputPos.Y-popup.MinSize().Height))
+	gui.SuggestionPopup = popup
+}
+
+func (gui *ChatGui) HideSuggestions() {
+	if gui.SuggestionPopup != nil {
+		gui.SuggestionPopup.Hide()
+		gui.SuggestionPopup = nil
+	}
+}
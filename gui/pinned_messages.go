@@ -0,0 +1,129 @@
+// pinned_messages.go
+package gui
+
+import (
+	"sort"
+
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+	"chat/utils"
+)
+
+const PINNED_SNIPPET_LENGTH int = 80
+
+func (gui *ChatGui) SetOnPinMessage(onPinMessage func(int64, bool)) {
+	gui.OnPinMessage = func(messageId int64, isPinned bool) {
+		gui.dispatch(func() { onPinMessage(messageId, isPinned) })
+	}
+}
+
+func (gui *ChatGui) SetCanPin(canPin bool) {
+	// pin actions are shown only if current user can pin messages of opened channel
+	gui.CanPin = canPin
+	gui.refreshPinnedMessages()
+}
+
+func (gui *ChatGui) SetPinnedMessages(messages []models.SavedMessage) {
+	// replaces pinned messages of opened channel
+	gui.PinnedMessages = messages
+	gui.refreshPinnedMessages()
+}
+
+func (gui *ChatGui) UpdatePinnedMessage(msg models.SavedMessage) {
+	// adds pinned message of opened channel, replaces edited one
+	// or removes unpinned one. Messages are kept in order of sending
+	var messages []models.SavedMessage
+	isChanged := msg.Pinned
+	for _, pinnedMsg := range gui.PinnedMessages {
+		if pinnedMsg.Id == msg.Id {
+			isChanged = true
+			continue
+		}
+		messages = append(messages, pinnedMsg)
+	}
+	if !isChanged {
+		return
+	}
+	if msg.Pinned {
+		messages = append(messages, msg)
+		sort.Slice(messages, func(i, j int) bool {
+			return messages[i].Id < messages[j].Id
+		})
+	}
+	gui.SetPinnedMessages(messages)
+}
+
+func (gui *ChatGui) toggleTask(msg models.SavedMessage) {
+	// task is checked by edit of note. Message is redrawn when server confirms edit
+	if !gui.ServerFeatures[models.FEATURE_EDITS] || gui.OnEditMessage == nil {
+		gui.ShowError("Server doesn't support editing of messages.")
+		gui.MessagesList.UpdateMessage(msg) // reverts checkbox
+		return
+	}
+	gui.OnEditMessage(msg.Id, utils.ToggleTask(msg.Text))
+}
+
+func (gui *ChatGui) getPinnedOrder() []models.SavedMessage {
+	// pinned notes are task list: open tasks go first, then done ones and other notes
+	messages := append([]models.SavedMessage{}, gui.PinnedMessages...)
+	if gui.ChannelsList.Selected != NOTES_CHANNEL_TITLE {
+		return messages
+	}
+	getRank := func(msg models.SavedMessage) int {
+		_, done, isTask := utils.ParseTask(msg.Text)
+		if !isTask {
+			return 2
+		}
+		if done {
+			return 1
+		}
+		return 0
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return getRank(messages[i]) < getRank(messages[j])
+	})
+	return messages
+}
+
+func (gui *ChatGui) refreshPinnedMessages() {
+	// shows pinned messages above history. Tap on message jumps to it
+	gui.PinnedList.Objects = nil
+	isNotes := gui.ChannelsList.Selected == NOTES_CHANNEL_TITLE
+	for _, msg := range gui.getPinnedOrder() {
+		msg := msg
+		text := msg.Text
+		if text == "" && msg.HasAttachment() {
+			text = msg.Attachment.FileName
+		}
+		caption := msg.User.Username + ": " + getTextSnippet(text, PINNED_SNIPPET_LENGTH)
+		if title, done, isTask := utils.ParseTask(text); isNotes && isTask {
+			mark := "☐ "
+			if done {
+				mark = "☑ "
+			}
+			caption = mark + getTextSnippet(title, PINNED_SNIPPET_LENGTH)
+		}
+		row := widget.NewHBox(NewTappableLabel(caption, func() {
+			if gui.OnSearchResultSelect != nil {
+				gui.OnSearchResultSelect(msg)
+			}
+		}))
+		if gui.CanPin && gui.OnPinMessage != nil {
+			row.Append(widget.NewButton(i18n.T("Unpin"), func() {
+				gui.OnPinMessage(msg.Id, false)
+			}))
+		}
+		gui.PinnedList.AddObject(row)
+	}
+	gui.PinnedList.Refresh()
+
+	if len(gui.PinnedMessages) == 0 {
+		gui.PinnedPanel.Hide()
+		return
+	}
+	gui.PinnedPanel.Items[0].Title = i18n.Tf("Pinned (%d)", len(gui.PinnedMessages))
+	gui.PinnedPanel.Refresh()
+	gui.PinnedPanel.Show()
+}
@@ -0,0 +1,248 @@
+// quick_switcher.go
+package gui
+
+import (
+	"sort"
+	"strings"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+)
+
+const SWITCHER_WIDTH int = 400
+const SWITCHER_HEIGHT int = 300
+const MAX_RECENT_CHANNELS int = 5
+
+type switcherItem struct {
+	Title  string
+	User   models.User
+	IsUser bool // known user without opened channel
+}
+
+type switcherEntry struct {
+	widget.Entry
+	onEnter  func()
+	onEscape func()
+	onMove   func(delta int)
+}
+
+func newSwitcherEntry() *switcherEntry {
+	entry := &switcherEntry{}
+	entry.ExtendBaseWidget(entry)
+
+	return entry
+}
+
+func (e *switcherEntry) TypedKey(key *fyne.KeyEvent) {
+	switch key.Name {
+	case fyne.KeyReturn, fyne.KeyEnter:
+		e.onEnter()
+	case fyne.KeyEscape:
+		e.onEscape()
+	case fyne.KeyUp:
+		e.onMove(-1)
+	case fyne.KeyDown:
+		e.onMove(1)
+	default:
+		e.Entry.TypedKey(key)
+	}
+}
+
+type QuickSwitcher struct {
+	gui      *ChatGui
+	popup    *widget.PopUp
+	input    *switcherEntry
+	list     *widget.List
+	items    []switcherItem
+	selected int
+}
+
+func (gui *ChatGui) ShowQuickSwitcher() {
+	// creates and shows modal palette with fuzzy search
+	// over channels and known users
+	if gui.QuickSwitcher != nil { // already opened
+		return
+	}
+	switcher := &QuickSwitcher{gui: gui}
+
+	switcher.input = newSwitcherEntry()
+	switcher.input.SetPlaceHolder(i18n.T("Jump to channel or user"))
+	switcher.input.onEnter = switcher.submit
+	switcher.input.onEscape = switcher.Hide
+	switcher.input.onMove = switcher.moveSelection
+	switcher.input.OnChanged = switcher.filter
+
+	switcher.list = widget.NewList(
+		func() int {
+			return len(switcher.items)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, object fyne.CanvasObject) {
+			label := object.(*widget.Label)
+			label.TextStyle = fyne.TextStyle{Bold: id == switcher.selected}
+			label.SetText(switcher.items[id].getCaption())
+		})
+	switcher.list.OnSelected = func(id widget.ListItemID) {
+		switcher.selected = id
+		switcher.submit()
+	}
+
+	content := container.NewBorder(switcher.input, nil, nil, nil, switcher.list)
+	switcher.popup = widget.NewModalPopUp(content, gui.Window.Canvas())
+	switcher.popup.Resize(fyne.NewSize(SWITCHER_WIDTH, SWITCHER_HEIGHT))
+
+	gui.QuickSwitcher = switcher
+	switcher.filter("")
+	switcher.popup.Show()
+	gui.Window.Canvas().Focus(switcher.input)
+}
+
+func (switcher *QuickSwitcher) Hide() {
+	switcher.popup.Hide()
+	switcher.gui.QuickSwitcher = nil
+}
+
+func (switcher *QuickSwitcher) filter(query string) {
+	// refills items list by query. First item becomes selected
+	switcher.items = switcher.gui.getSwitcherItems(query)
+	switcher.selected = 0
+	switcher.list.Refresh()
+}
+
+func (switcher *QuickSwitcher) moveSelection(delta int) {
+	count := len(switcher.items)
+	if count == 0 {
+		return
+	}
+	switcher.selected = (switcher.selected + delta + count) % count
+	switcher.list.Refresh()
+}
+
+func (switcher *QuickSwitcher) submit() {
+	// opens selected channel and closes switcher
+	if switcher.selected >= len(switcher.items) {
+		return
+	}
+	item := switcher.items[switcher.selected]
+	gui := switcher.gui
+	switcher.Hide()
+
+	if item.IsUser {
+		if gui.OnUsernameSelect != nil {
+			gui.OnUsernameSelect(item.User)
+		}
+	} else {
+		gui.SelectChannel(item.Title)
+	}
+}
+
+func (item switcherItem) getCaption() string {
+	if item.IsUser {
+		return "@" + item.Title
+	}
+	return "# " + item.Title
+}
+
+func (gui *ChatGui) getSwitcherItems(query string) []switcherItem {
+	// returns channels and known users suitable for query.
+	// If query is empty recent channels are placed on top.
+	var items []switcherItem
+	channelTitles := gui.ChannelsList.Titles
+	if query == "" {
+		for _, title := range gui.RecentChannels {
+			if containsString(channelTitles, title) {
+				items = append(items, switcherItem{Title: title})
+			}
+		}
+	}
+	for _, title := range channelTitles {
+		if query == "" && containsString(gui.RecentChannels, title) {
+			continue // already added as recent
+		}
+		items = append(items, switcherItem{Title: title})
+	}
+	var users []models.User
+	for _, user := range gui.KnownUsers {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { // map order is random
+		return users[i].Username < users[j].Username
+	})
+	for _, user := range users {
+		if user.Id != gui.CurrentUser.Id && !containsString(channelTitles, user.Username) {
+			items = append(items, switcherItem{user.Username, user, true})
+		}
+	}
+	if query == "" {
+		return items
+	}
+
+	var matched []switcherItem
+	scores := make(map[string]int)
+	for _, item := range items {
+		if score, ok := getFuzzyScore(query, item.Title); ok {
+			scores[item.getCaption()] = score
+			matched = append(matched, item)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return scores[matched[i].getCaption()] > scores[matched[j].getCaption()]
+	})
+	return matched
+}
+
+func (gui *ChatGui) rememberRecentChannel(title string) {
+	// moves channel title to the beginning of recent channels list
+	recent := []string{title}
+	for _, t := range gui.RecentChannels {
+		if t != title && len(recent) < MAX_RECENT_CHANNELS {
+			recent = append(recent, t)
+		}
+	}
+	gui.RecentChannels = recent
+}
+
+func getFuzzyScore(query string, text string) (int, bool) {
+	// returns score and true if all query characters are found
+	// in text in the same order. Consecutive and leading matches
+	// have bigger score
+	query = strings.ToLower(query)
+	text = strings.ToLower(text)
+	score := 0
+	lastMatch := -1
+	queryRunes := []rune(query)
+	queryIndex := 0
+	for i, c := range []rune(text) {
+		if queryIndex == len(queryRunes) {
+			break
+		}
+		if c != queryRunes[queryIndex] {
+			continue
+		}
+		score++
+		if i == 0 {
+			score += 3
+		}
+		if lastMatch == i-1 {
+			score += 2
+		}
+		lastMatch = i
+		queryIndex++
+	}
+	return score, queryIndex == len(queryRunes)
+}
+
+func containsString(list []string, value string) bool {
+	for _, s := range list {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
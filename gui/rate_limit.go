@@ -0,0 +1,62 @@
+// rate_limit.go
+package gui
+
+import (
+	"math"
+	"time"
+
+	"chat/i18n"
+)
+
+const RATE_LIMIT_TIMER_INTERVAL = time.Second
+
+func (gui *ChatGui) IsRateLimited() bool {
+	return !gui.RateLimitEnd.IsZero()
+}
+
+func (gui *ChatGui) ShowRateLimit(delay time.Duration) {
+	// shows warning above input and disables send until cooldown expires
+	end := time.Now().Add(delay)
+	gui.RateLimitEnd = end
+	gui.SendButton.Disable()
+	gui.updateRateLimit(end)
+	gui.RateLimitLabel.Show()
+	go gui.trackRateLimit(end)
+}
+
+func (gui *ChatGui) trackRateLimit(end time.Time) {
+	// later limit replaces this one
+	for range time.Tick(RATE_LIMIT_TIMER_INTERVAL) {
+		if gui.RateLimitEnd != end {
+			return
+		}
+		if !gui.updateRateLimit(end) {
+			gui.RateLimitEnd = time.Time{}
+			gui.RateLimitLabel.Hide()
+			if gui.SendEnabled { // send could be disabled by logout meanwhile
+				gui.SendButton.Enable()
+			}
+			return
+		}
+	}
+}
+
+func (gui *ChatGui) updateRateLimit(end time.Time) bool {
+	// returns false if cooldown is expired
+	left := time.Until(end)
+	if left <= 0 {
+		return false
+	}
+	seconds := int(math.Ceil(left.Seconds()))
+	gui.RateLimitLabel.SetText(i18n.Tf("Slow down, retry in %ds", seconds))
+	return true
+}
+
+func (gui *ChatGui) ClearRateLimit() {
+	// limit of previous server doesn't apply to another one
+	gui.RateLimitEnd = time.Time{}
+	gui.RateLimitLabel.Hide()
+	if gui.SendEnabled {
+		gui.SendButton.Enable()
+	}
+}
@@ -0,0 +1,81 @@
+// reports.go
+package gui
+
+import (
+	"strings"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+	"chat/utils"
+)
+
+// titles of report categories shown in report dialog
+var REPORT_CATEGORY_TITLES = map[string]string{
+	models.REPORT_SPAM:    "Spam",
+	models.REPORT_ABUSE:   "Insults or harassment",
+	models.REPORT_ILLEGAL: "Illegal content",
+	models.REPORT_OTHER:   "Other",
+}
+
+func (gui *ChatGui) SetOnReport(onReport func(models.Report)) {
+	gui.OnReport = func(report models.Report) {
+		gui.dispatch(func() { onReport(report) })
+	}
+}
+
+func (gui *ChatGui) getReportMenuItems(msg models.SavedMessage) []*fyne.MenuItem {
+	// returns report actions for message of another user and its author
+	if gui.OnReport == nil || !gui.ServerFeatures[models.FEATURE_USER_REPORTS] {
+		return nil
+	}
+	return []*fyne.MenuItem{
+		fyne.NewMenuItem(i18n.T("Report"), func() {
+			gui.ShowReportDialog(i18n.Tf("Report message of %s", msg.User.Username),
+				models.Report{MessageId: msg.Id, UserId: msg.User.Id})
+		}),
+		fyne.NewMenuItem(i18n.Tf("Report %s", msg.User.Username), func() {
+			gui.ShowReportDialog(i18n.Tf("Report user %s", msg.User.Username),
+				models.Report{UserId: msg.User.Id})
+		}),
+	}
+}
+
+func (gui *ChatGui) ShowReportDialog(title string, report models.Report) {
+	// asks category and optional comment, then confirms sending to moderators
+	var titles []string
+	categories := make(map[string]string) // map: title -> category
+	for _, category := range models.GetReportCategories() {
+		categoryTitle := i18n.T(REPORT_CATEGORY_TITLES[category])
+		titles = append(titles, categoryTitle)
+		categories[categoryTitle] = category
+	}
+	categoryGroup := widget.NewRadioGroup(titles, nil)
+	inputComment := widget.NewMultiLineEntry()
+	inputComment.SetPlaceHolder(i18n.T("comment (optional)"))
+
+	dialog.ShowCustomConfirm(title, i18n.T("Report"), i18n.T("Cancel"),
+		container.NewVBox(categoryGroup, inputComment), func(result bool) {
+			if !result {
+				return
+			}
+			report.Category = categories[categoryGroup.Selected]
+			report.Comment = strings.TrimSpace(inputComment.Text)
+			if err := utils.ValidateReport(report); utils.IsError(err) {
+				gui.ShowError(err.Error())
+				return
+			}
+			dialog.ShowConfirm(i18n.T("Send report"),
+				i18n.Tf("Report will be sent to moderators of server as \"%s\". Send it?",
+					categoryGroup.Selected),
+				func(confirmed bool) {
+					if confirmed {
+						gui.OnReport(report)
+					}
+				}, gui.Window)
+		}, gui.Window)
+}
@@ -0,0 +1,128 @@
+// scheduled_messages.go
+package gui
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+	"chat/utils"
+)
+
+const SCHEDULE_DATE_FORMAT = "2006-01-02"
+const SCHEDULE_TIME_FORMAT = "15:04"
+const SCHEDULED_SNIPPET_LENGTH int = 60
+const DEFAULT_SCHEDULE_DELAY = time.Hour
+
+func (gui *ChatGui) SetOnScheduleMessage(onScheduleMessage func(string, int64, time.Time)) {
+	gui.OnScheduleMessage = func(text string, replyToId int64, sendOn time.Time) {
+		gui.dispatch(func() { onScheduleMessage(text, replyToId, sendOn) })
+	}
+}
+
+func (gui *ChatGui) SetOnCancelScheduled(onCancelScheduled func(int64)) {
+	gui.OnCancelScheduled = func(id int64) {
+		gui.dispatch(func() { onCancelScheduled(id) })
+	}
+}
+
+func (gui *ChatGui) SetScheduledMessages(messages []models.ScheduledMessage) {
+	// replaces list of messages waiting for sending
+	gui.ScheduledMessages = messages
+	gui.refreshScheduledMessages()
+}
+
+func parseScheduleTime(date string, clock string) (time.Time, error) {
+	sendOn, err := time.ParseInLocation(SCHEDULE_DATE_FORMAT+" "+SCHEDULE_TIME_FORMAT,
+		strings.TrimSpace(date)+" "+strings.TrimSpace(clock), time.Local)
+	if utils.IsError(err) {
+		return sendOn, errors.New(i18n.T("Date must be YYYY-MM-DD and time must be HH:MM."))
+	}
+	if !sendOn.After(time.Now()) {
+		return sendOn, errors.New(i18n.T("Time of sending must be in the future."))
+	}
+	return sendOn, nil
+}
+
+func (gui *ChatGui) ShowSendLaterDialog() {
+	// asks time of sending for text of input. Reply is sent later too
+	text := gui.MessageInput.Text
+	if text == "" || gui.OnScheduleMessage == nil {
+		return
+	}
+	defaultTime := time.Now().Add(DEFAULT_SCHEDULE_DELAY)
+	dateEntry := widget.NewEntry()
+	dateEntry.SetPlaceHolder(i18n.T("YYYY-MM-DD"))
+	dateEntry.SetText(defaultTime.Format(SCHEDULE_DATE_FORMAT))
+	timeEntry := widget.NewEntry()
+	timeEntry.SetPlaceHolder(i18n.T("HH:MM"))
+	timeEntry.SetText(defaultTime.Format(SCHEDULE_TIME_FORMAT))
+	form := widget.NewForm(
+		widget.NewFormItem(i18n.T("Date"), dateEntry),
+		widget.NewFormItem(i18n.T("Time"), timeEntry))
+
+	dialog.ShowCustomConfirm(i18n.T("Send later"), i18n.T("Schedule"), i18n.T("Cancel"), form,
+		func(result bool) {
+			if !result {
+				return
+			}
+			sendOn, err := parseScheduleTime(dateEntry.Text, timeEntry.Text)
+			if utils.IsError(err) {
+				dialog.ShowError(err, gui.Window)
+				return
+			}
+			gui.OnScheduleMessage(text, gui.ReplyMessageId, sendOn)
+			gui.CancelReply()
+			gui.MessageInput.Clear()
+		}, gui.Window)
+}
+
+func (gui *ChatGui) refreshScheduledMessages() {
+	// rebuilds opened dialog of scheduled messages
+	if gui.ScheduledList == nil {
+		return
+	}
+	var objects []fyne.CanvasObject
+	for _, msg := range gui.ScheduledMessages {
+		msg := msg
+		sendOn := time.Unix(msg.SendOn, 0).Local().Format(SCHEDULE_DATE_FORMAT + " " +
+			SCHEDULE_TIME_FORMAT)
+		caption := sendOn + " " + msg.ChatTitle + ": " +
+			getTextSnippet(msg.Text, SCHEDULED_SNIPPET_LENGTH)
+		row := container.NewHBox(widget.NewLabel(caption), layout.NewSpacer(),
+			widget.NewButton(i18n.T("Cancel"), func() {
+				gui.OnCancelScheduled(msg.Id)
+			}))
+		objects = append(objects, row)
+	}
+	if len(objects) == 0 {
+		objects = append(objects, widget.NewLabel(i18n.T("No scheduled messages.")))
+	}
+	gui.ScheduledList.Objects = objects
+	gui.ScheduledList.Refresh()
+}
+
+func (gui *ChatGui) ShowScheduledMessagesDialog() {
+	// messages of current user which wait for their time
+	if gui.OnCancelScheduled == nil {
+		return
+	}
+	gui.ScheduledList = container.NewVBox()
+	gui.refreshScheduledMessages()
+	scroller := widget.NewVScrollContainer(gui.ScheduledList)
+	scroller.SetMinSize(fyne.NewSize(450, 200))
+	scheduledDialog := dialog.NewCustom(i18n.T("Scheduled messages"), i18n.T("Close"),
+		scroller, gui.Window)
+	scheduledDialog.SetOnClosed(func() {
+		gui.ScheduledList = nil
+	})
+	scheduledDialog.Show()
+}
@@ -0,0 +1,83 @@
+// search.go
+package gui
+
+import (
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+)
+
+const SEARCH_RESULTS_WIDTH int = 500
+const SEARCH_RESULTS_HEIGHT int = 400
+const SEARCH_SNIPPET_LENGTH int = 60
+
+// popup with messages found by search query
+type SearchResults struct {
+	gui      *ChatGui
+	popup    *widget.PopUp
+	list     *widget.List
+	Query    string
+	Messages []models.SavedMessage
+}
+
+func (gui *ChatGui) ShowSearchResults(query string, messages []models.SavedMessage) {
+	// shows found messages. Results of same query replace displayed ones
+	if gui.SearchResults != nil && gui.SearchResults.Query == query {
+		gui.SearchResults.Messages = messages
+		gui.SearchResults.list.Refresh()
+		return
+	}
+	if gui.SearchResults != nil {
+		gui.SearchResults.Hide()
+	}
+	results := &SearchResults{gui: gui, Query: query, Messages: messages}
+
+	results.list = widget.NewList(
+		func() int {
+			return len(results.Messages)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, object fyne.CanvasObject) {
+			object.(*widget.Label).SetText(getSearchResultCaption(results.Messages[id]))
+		})
+	results.list.OnSelected = func(id widget.ListItemID) {
+		msg := results.Messages[id]
+		results.Hide()
+		if gui.OnSearchResultSelect != nil {
+			gui.OnSearchResultSelect(msg)
+		}
+	}
+
+	title := widget.NewLabel(i18n.Tf("Search: %s", query))
+	title.TextStyle = fyne.TextStyle{Bold: true}
+	closeButton := widget.NewButton(i18n.T("Close"), results.Hide)
+	content := container.NewBorder(title, closeButton, nil, nil, results.list)
+	results.popup = widget.NewModalPopUp(content, gui.Window.Canvas())
+	results.popup.Resize(fyne.NewSize(SEARCH_RESULTS_WIDTH, SEARCH_RESULTS_HEIGHT))
+
+	gui.SearchResults = results
+	results.popup.Show()
+}
+
+func (results *SearchResults) Hide() {
+	results.popup.Hide()
+	results.gui.SearchResults = nil
+}
+
+func getSearchResultCaption(msg models.SavedMessage) string {
+	// returns author and beginning of message text
+	text := msg.Text
+	if text == "" && msg.HasAttachment() {
+		text = msg.Attachment.FileName
+	}
+	snippet := []rune(replaceEmojiShortcodes(text))
+	if len(snippet) > SEARCH_SNIPPET_LENGTH {
+		snippet = append(snippet[:SEARCH_SNIPPET_LENGTH], '…')
+	}
+	return msg.User.Username + ": " + string(snippet)
+}
@@ -0,0 +1,46 @@
+// server_errors.go
+package gui
+
+import (
+	"strings"
+
+	"chat/i18n"
+	"chat/models"
+)
+
+// templates of localized messages by error code. Params of error are inserted in order
+var ERROR_TEXTS = map[string]string{
+	models.ERROR_OUTDATED_CLIENT: "Client is outdated: its password scheme is not supported. " +
+		"Please, update the client.",
+	models.ERROR_WRONG_USERNAME:       "Username is not correct.",
+	models.ERROR_WRONG_PASSWORD:       "Password is not correct. You can try again: %s times",
+	models.ERROR_WRONG_CODE:           "Code is not correct. You can try again: %s times",
+	models.ERROR_WRONG_ANSWER:         "Answer to registration challenge is not correct. Please, try again.",
+	models.ERROR_TOO_MANY_ATTEMPTS:    "You have entered wrong password more than %s times. Please, try again in 2 minutes.",
+	models.ERROR_SESSION_EXPIRED:      "Session has expired. Please, log in again.",
+	models.ERROR_USERNAME_EXISTS:      "Username %s already exists.",
+	models.ERROR_INVALID_CHANNEL_NAME: "Channel name %s is not valid.",
+	models.ERROR_CHANNEL_NAME_USED:    "Channel name %s is already used.",
+	models.ERROR_CHANNEL_NOT_FOUND:    "Channel %s doesn't exist.",
+	models.ERROR_USER_NOT_FOUND:       "User doesn't exist.",
+	models.ERROR_ALREADY_MEMBER:       "User %s is already member.",
+	models.ERROR_ALREADY_INVITED:      "User %s is already invited.",
+	models.ERROR_ALREADY_REQUESTED:    "Request to %s is already sent.",
+}
+
+func GetErrorText(serverError models.Error) string {
+	// errors without template explain rules of server in description
+	template, ok := ERROR_TEXTS[serverError.Code]
+	if serverError.Code == models.ERROR_ALREADY_MEMBER &&
+		serverError.Process == models.PROCESS_JOIN_REQUEST {
+		template = "You are already member of %s."
+	}
+	if !ok || strings.Count(template, "%s") != len(serverError.Params) {
+		return i18n.T(serverError.Description)
+	}
+	var params []interface{}
+	for _, param := range serverError.Params {
+		params = append(params, param)
+	}
+	return i18n.Tf(template, params...)
+}
@@ -0,0 +1,430 @@
+// settings_window.go
+package gui
+
+import (
+	"errors"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/theme"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/utils"
+)
+
+const ACCENT_DEFAULT_OPTION = "default"
+const SPELL_CHECK_OFF_OPTION = "off"
+const PROXY_NONE_OPTION = "none"
+const SETTINGS_WINDOW_WIDTH int = 450
+
+// map: name of accent color -> color
+var accentColors = map[string]color.Color{
+	"blue":   color.RGBA{33, 150, 243, 255},
+	"green":  color.RGBA{76, 175, 80, 255},
+	"orange": color.RGBA{255, 152, 0, 255},
+	"purple": color.RGBA{156, 39, 176, 255},
+	"red":    color.RGBA{244, 67, 54, 255},
+}
+
+// theme with text size and accent color chosen in settings
+type chatTheme struct {
+	fyne.Theme
+	textSize    int         // 0 for size of base theme
+	accentColor color.Color // nil for primary color of base theme
+}
+
+func (t *chatTheme) TextSize() int {
+	if t.textSize > 0 {
+		return t.textSize
+	}
+	return t.Theme.TextSize()
+}
+
+func (t *chatTheme) PrimaryColor() color.Color {
+	if t.accentColor != nil {
+		return t.accentColor
+	}
+	return t.Theme.PrimaryColor()
+}
+
+func (t *chatTheme) HyperlinkColor() color.Color {
+	return t.PrimaryColor()
+}
+
+func (t *chatTheme) FocusColor() color.Color {
+	if t.accentColor != nil {
+		return t.accentColor
+	}
+	return t.Theme.FocusColor()
+}
+
+func isDarkTheme(t fyne.Theme) bool {
+	// system theme is chosen by fyne, so its kind is found by background
+	r, g, b, _ := t.BackgroundColor().RGBA()
+	return r+g+b < 3*0x8000
+}
+
+func mixColors(c color.Color, with color.Color, ratio float64) color.Color {
+	// returns c mixed with ratio part of with
+	r1, g1, b1, _ := c.RGBA()
+	r2, g2, b2, _ := with.RGBA()
+	mix := func(x uint32, y uint32) uint8 {
+		return uint8((float64(x)*(1-ratio) + float64(y)*ratio) / 0x101)
+	}
+	return color.RGBA{mix(r1, r2), mix(g1, g2), mix(b1, b2), 255}
+}
+
+func setMessageColors(isDark bool, ownColor color.Color) {
+	// message colors are readable on background of theme.
+	// Bubbles of own messages are tinted with accent color
+	msgOwnBodyColor = mixColors(ownColor, color.White, 0.6)
+	if isDark {
+		msgOwnBodyColor = mixColors(ownColor, color.Black, 0.5)
+		msgBodyColor = color.RGBA{66, 66, 66, 255}
+		msgStrokeColor = color.RGBA{90, 90, 90, 255}
+		msgTextColor = color.White
+		msgPendingTextColor = color.RGBA{170, 170, 170, 255}
+		msgMentionColor = color.RGBA{150, 130, 90, 255}
+		return
+	}
+	msgBodyColor = color.RGBA{200, 200, 200, 255}
+	msgStrokeColor = color.RGBA{170, 170, 170, 255}
+	msgTextColor = color.RGBA{33, 33, 33, 255}
+	msgPendingTextColor = color.RGBA{110, 110, 110, 255}
+	msgMentionColor = color.RGBA{255, 213, 79, 255}
+}
+
+func (gui *ChatGui) ApplyAppearance(themeName string, accentColorName string, fontSize int) {
+	// sets theme, accent color and text size from settings.
+	// Displayed messages are recreated with new colors
+	baseTheme := gui.defaultTheme
+	switch themeName {
+	case utils.THEME_DARK:
+		baseTheme = theme.DarkTheme()
+	case utils.THEME_LIGHT:
+		baseTheme = theme.LightTheme()
+	}
+	appTheme := &chatTheme{Theme: baseTheme, textSize: fontSize,
+		accentColor: accentColors[accentColorName]}
+	setMessageColors(isDarkTheme(baseTheme), appTheme.PrimaryColor())
+	gui.App.Settings().SetTheme(appTheme)
+	gui.MessagesList.Redraw()
+}
+
+func (gui *ChatGui) ShowSettingsWindow() {
+	// shows window with options of settings file.
+	// Options which aren't shown (server certificates) stay unchanged
+	settings := utils.GetSettingsFromFile()
+	window := gui.App.NewWindow(i18n.T("Settings"))
+
+	hostEntry := widget.NewEntry()
+	hostEntry.Validator = utils.ValidateHost
+	portEntry := widget.NewEntry()
+	portEntry.SetPlaceHolder(i18n.T("auto (SRV record)"))
+	secureCheck := widget.NewCheck(i18n.T("Use TLS (wss://)"), nil)
+	caCertEntry := widget.NewEntry()
+	caCertEntry.SetPlaceHolder(i18n.T("system certificates"))
+	transportSelect := widget.NewSelect(
+		[]string{utils.TRANSPORT_SOCKETIO, utils.TRANSPORT_WEBSOCKET}, nil)
+	proxyTypeSelect := widget.NewSelect(
+		[]string{PROXY_NONE_OPTION, utils.PROXY_HTTP, utils.PROXY_SOCKS5}, nil)
+	proxyHostEntry := widget.NewEntry()
+	proxyPortEntry := widget.NewEntry()
+	proxyUsernameEntry := widget.NewEntry()
+	proxyUsernameEntry.SetPlaceHolder(i18n.T("without auth"))
+	proxyPasswordEntry := widget.NewPasswordEntry()
+	showHostData := func(hostData utils.HostData) {
+		hostEntry.SetText(hostData.Host)
+		portEntry.SetText("")
+		if hostData.Port > 0 {
+			portEntry.SetText(strconv.Itoa(hostData.Port))
+		}
+		secureCheck.SetChecked(hostData.Secure)
+		caCertEntry.SetText(hostData.CaCertFile)
+		transportSelect.SetSelected(utils.TRANSPORT_SOCKETIO)
+		if hostData.Transport != "" {
+			transportSelect.SetSelected(hostData.Transport)
+		}
+		proxy := hostData.Proxy
+		proxyTypeSelect.SetSelected(PROXY_NONE_OPTION)
+		if proxy.Type != "" {
+			proxyTypeSelect.SetSelected(proxy.Type)
+		}
+		proxyHostEntry.SetText(proxy.Host)
+		proxyPortEntry.SetText("")
+		if proxy.Port > 0 {
+			proxyPortEntry.SetText(strconv.Itoa(proxy.Port))
+		}
+		proxyUsernameEntry.SetText(proxy.Username)
+		proxyPasswordEntry.SetText(proxy.Password)
+	}
+	showHostData(settings.HostData)
+
+	// new name adds profile, name of saved profile shows its host
+	profileEntry := widget.NewSelectEntry(settings.GetProfileNames())
+	profileEntry.SetText(settings.ActiveProfile)
+	profileEntry.OnChanged = func(name string) {
+		for _, profile := range settings.Profiles {
+			if profile.Name == name {
+				showHostData(profile.HostData)
+			}
+		}
+	}
+
+	themeSelect := widget.NewSelect(
+		[]string{utils.THEME_SYSTEM, utils.THEME_DARK, utils.THEME_LIGHT}, nil)
+	themeSelect.SetSelected(utils.THEME_SYSTEM)
+	if settings.Theme != "" {
+		themeSelect.SetSelected(settings.Theme)
+	}
+	accentSelect := widget.NewSelect(
+		append([]string{ACCENT_DEFAULT_OPTION}, utils.ACCENT_COLORS...), nil)
+	accentSelect.SetSelected(ACCENT_DEFAULT_OPTION)
+	if settings.AccentColor != "" {
+		accentSelect.SetSelected(settings.AccentColor)
+	}
+	rememberWindowCheck := widget.NewCheck(i18n.T("Remember window size and last channel"), nil)
+	rememberWindowCheck.SetChecked(!settings.Window.Forget)
+	linkPreviewsCheck := widget.NewCheck(i18n.T("Show previews of links"), nil)
+	linkPreviewsCheck.SetChecked(settings.LinkPreviews)
+	stickersDirEntry := widget.NewEntry()
+	stickersDirEntry.SetPlaceHolder(i18n.T("directory with images"))
+	stickersDirEntry.SetText(settings.StickersDir)
+	stickersUrlEntry := widget.NewEntry()
+	stickersUrlEntry.SetPlaceHolder("https://example.com/stickers?q=%s")
+	stickersUrlEntry.SetText(settings.StickersUrl)
+	hideFiltersEntry := widget.NewMultiLineEntry()
+	hideFiltersEntry.SetPlaceHolder(i18n.T("word or /regular expression/ per line"))
+	hideFiltersEntry.SetText(utils.FormatMessageFilters(settings.MessageFilters, utils.FILTER_HIDE))
+	collapseFiltersEntry := widget.NewMultiLineEntry()
+	collapseFiltersEntry.SetPlaceHolder(i18n.T("word or /regular expression/ per line"))
+	collapseFiltersEntry.SetText(
+		utils.FormatMessageFilters(settings.MessageFilters, utils.FILTER_COLLAPSE))
+	fontSizeEntry := widget.NewEntry()
+	fontSizeEntry.SetPlaceHolder("default")
+	if settings.FontSize > 0 {
+		fontSizeEntry.SetText(strconv.Itoa(settings.FontSize))
+	}
+
+	notificationsSelect := widget.NewSelect([]string{utils.NOTIFICATIONS_ALL,
+		utils.NOTIFICATIONS_MENTIONS, utils.NOTIFICATIONS_OFF}, nil)
+	notificationsSelect.SetSelected(settings.Notifications)
+	mentionsCheck := widget.NewCheck(i18n.T("Notify about mentions in opened chat"), nil)
+	mentionsCheck.SetChecked(settings.MentionsInOpenChat)
+	dndFromEntry := widget.NewEntry()
+	dndFromEntry.SetPlaceHolder(i18n.T("HH:MM"))
+	dndFromEntry.SetText(settings.DndFrom)
+	dndToEntry := widget.NewEntry()
+	dndToEntry.SetPlaceHolder(i18n.T("HH:MM"))
+	dndToEntry.SetText(settings.DndTo)
+
+	soundsMutedCheck := widget.NewCheck(i18n.T("Mute sounds"), nil)
+	soundsMutedCheck.SetChecked(settings.SoundsMuted)
+	messageSoundEntry := widget.NewEntry()
+	messageSoundEntry.SetPlaceHolder(i18n.T("no sound"))
+	messageSoundEntry.SetText(settings.MessageSound)
+	mentionSoundEntry := widget.NewEntry()
+	mentionSoundEntry.SetPlaceHolder(i18n.T("message sound"))
+	mentionSoundEntry.SetText(settings.MentionSound)
+	quietFromEntry := widget.NewEntry()
+	quietFromEntry.SetPlaceHolder(i18n.T("HH:MM"))
+	quietFromEntry.SetText(settings.QuietHoursFrom)
+	quietToEntry := widget.NewEntry()
+	quietToEntry.SetPlaceHolder(i18n.T("HH:MM"))
+	quietToEntry.SetText(settings.QuietHoursTo)
+
+	languages := append([]string{SPELL_CHECK_OFF_OPTION}, utils.GetDictionaryLanguages()...)
+	if settings.SpellCheckLanguage != "" && !containsString(languages, settings.SpellCheckLanguage) {
+		languages = append(languages, settings.SpellCheckLanguage) // dictionary was removed
+	}
+	spellCheckSelect := widget.NewSelect(languages, nil)
+	languageSelect := widget.NewSelect(i18n.GetLanguages(), nil)
+	languageSelect.SetSelected(i18n.DEFAULT_LANGUAGE)
+	if settings.Language != "" {
+		languageSelect.SetSelected(settings.Language)
+	}
+	spellCheckSelect.SetSelected(SPELL_CHECK_OFF_OPTION)
+	if settings.SpellCheckLanguage != "" {
+		spellCheckSelect.SetSelected(settings.SpellCheckLanguage)
+	}
+
+	readSettings := func() (utils.Settings, error) {
+		// returns settings with values of form fields
+		result := settings
+		port := 0 // empty port is found by domain
+		var err error
+		if portEntry.Text != "" {
+			port, err = strconv.Atoi(portEntry.Text)
+			if utils.IsError(err) {
+				return result, errors.New(i18n.T("Port must be a number."))
+			}
+		}
+		result.FontSize = 0
+		if fontSizeEntry.Text != "" {
+			result.FontSize, err = strconv.Atoi(fontSizeEntry.Text)
+			if utils.IsError(err) {
+				return result, errors.New(i18n.T("Font size must be a number."))
+			}
+		}
+		hostData := result.HostData
+		hostData.Host = hostEntry.Text
+		hostData.Port = port
+		hostData.Secure = secureCheck.Checked
+		hostData.CaCertFile = caCertEntry.Text
+		hostData.Transport = transportSelect.Selected
+		if hostData.Transport == utils.TRANSPORT_SOCKETIO {
+			hostData.Transport = ""
+		}
+		hostData.Proxy = utils.ProxySettings{Host: proxyHostEntry.Text,
+			Username: proxyUsernameEntry.Text, Password: proxyPasswordEntry.Text}
+		if proxyTypeSelect.Selected != PROXY_NONE_OPTION {
+			hostData.Proxy.Type = proxyTypeSelect.Selected
+			hostData.Proxy.Port, err = strconv.Atoi(proxyPortEntry.Text)
+			if utils.IsError(err) {
+				return result, errors.New(i18n.T("Proxy port must be a number."))
+			}
+		}
+		// profiles are copied in order not to change settings if form is not valid
+		result.Profiles = append([]utils.ServerProfile{}, settings.Profiles...)
+		result.SetProfile(profileEntry.Text, hostData)
+		result.Theme = themeSelect.Selected
+		result.AccentColor = accentSelect.Selected
+		if result.AccentColor == ACCENT_DEFAULT_OPTION {
+			result.AccentColor = ""
+		}
+		result.Window.Forget = !rememberWindowCheck.Checked
+		result.LinkPreviews = linkPreviewsCheck.Checked
+		result.StickersDir = strings.TrimSpace(stickersDirEntry.Text)
+		result.StickersUrl = strings.TrimSpace(stickersUrlEntry.Text)
+		result.MessageFilters = append(
+			utils.ParseMessageFilters(hideFiltersEntry.Text, utils.FILTER_HIDE),
+			utils.ParseMessageFilters(collapseFiltersEntry.Text, utils.FILTER_COLLAPSE)...)
+		result.Notifications = notificationsSelect.Selected
+		result.MentionsInOpenChat = mentionsCheck.Checked
+		result.DndFrom = strings.TrimSpace(dndFromEntry.Text)
+		result.DndTo = strings.TrimSpace(dndToEntry.Text)
+		result.SoundSettings = utils.SoundSettings{
+			SoundsMuted:    soundsMutedCheck.Checked,
+			MessageSound:   messageSoundEntry.Text,
+			MentionSound:   mentionSoundEntry.Text,
+			QuietHoursFrom: quietFromEntry.Text,
+			QuietHoursTo:   quietToEntry.Text}
+		result.SpellCheckLanguage = spellCheckSelect.Selected
+		if result.SpellCheckLanguage == SPELL_CHECK_OFF_OPTION {
+			result.SpellCheckLanguage = ""
+		}
+		result.Language = languageSelect.Selected
+		if result.Language == i18n.DEFAULT_LANGUAGE {
+			result.Language = ""
+		}
+		return result, result.Validate()
+	}
+	save := func(reconnect bool) {
+		newSettings, err := readSettings()
+		if utils.IsError(err) {
+			dialog.ShowError(err, window)
+			return
+		}
+		window.Close()
+		if gui.OnSaveSettings != nil {
+			gui.OnSaveSettings(newSettings, reconnect)
+		}
+	}
+
+	form := widget.NewForm(
+		widget.NewFormItem(i18n.T("Server profile"), profileEntry),
+		widget.NewFormItem(i18n.T("Host"), hostEntry),
+		widget.NewFormItem(i18n.T("Port"), portEntry),
+		widget.NewFormItem("", secureCheck),
+		widget.NewFormItem(i18n.T("CA certificate"), caCertEntry),
+		widget.NewFormItem(i18n.T("Transport"), transportSelect),
+		widget.NewFormItem(i18n.T("Proxy"), proxyTypeSelect),
+		widget.NewFormItem(i18n.T("Proxy host"), proxyHostEntry),
+		widget.NewFormItem(i18n.T("Proxy port"), proxyPortEntry),
+		widget.NewFormItem(i18n.T("Proxy username"), proxyUsernameEntry),
+		widget.NewFormItem(i18n.T("Proxy password"), proxyPasswordEntry),
+		widget.NewFormItem(i18n.T("Language"), languageSelect),
+		widget.NewFormItem("", widget.NewLabel(i18n.T("Language is changed after restart."))),
+		widget.NewFormItem(i18n.T("Theme"), themeSelect),
+		widget.NewFormItem(i18n.T("Accent color"), accentSelect),
+		widget.NewFormItem(i18n.T("Font size"), fontSizeEntry),
+		widget.NewFormItem("", rememberWindowCheck),
+		widget.NewFormItem("", linkPreviewsCheck),
+		widget.NewFormItem("", widget.NewLabel(
+			i18n.T("Linked sites see your address when previews are loaded."))),
+		widget.NewFormItem(i18n.T("Sticker pack"), stickersDirEntry),
+		widget.NewFormItem(i18n.T("Sticker provider"), stickersUrlEntry),
+		widget.NewFormItem(i18n.T("Hide messages"), hideFiltersEntry),
+		widget.NewFormItem(i18n.T("Collapse messages"), collapseFiltersEntry),
+		widget.NewFormItem(i18n.T("Spell checking"), spellCheckSelect),
+		widget.NewFormItem(i18n.T("Notifications"), notificationsSelect),
+		widget.NewFormItem("", mentionsCheck),
+		widget.NewFormItem(i18n.T("Do not disturb"),
+			container.NewGridWithColumns(2, dndFromEntry, dndToEntry)),
+		widget.NewFormItem("", soundsMutedCheck),
+		widget.NewFormItem(i18n.T("Message sound"), messageSoundEntry),
+		widget.NewFormItem(i18n.T("Mention sound"), mentionSoundEntry),
+		widget.NewFormItem(i18n.T("Quiet hours"),
+			container.NewGridWithColumns(2, quietFromEntry, quietToEntry)))
+	var testButton *widget.Button
+	testButton = widget.NewButton(i18n.T("Test connection"), func() {
+		// checks host of form without saving it
+		newSettings, err := readSettings()
+		if utils.IsError(err) {
+			dialog.ShowError(err, window)
+			return
+		}
+		testButton.Disable()
+		gui.OnTestConnection(newSettings.HostData, func(result string, err error) {
+			testButton.Enable()
+			if utils.IsError(err) {
+				dialog.ShowError(err, window)
+				return
+			}
+			dialog.ShowInformation(i18n.T("Test connection"), result, window)
+		})
+	})
+	if gui.OnTestConnection == nil {
+		testButton.Hide()
+	}
+
+	buttons := widget.NewHBox(
+		testButton,
+		widget.NewButton(i18n.T("Save"), func() {
+			save(false)
+		}),
+		widget.NewButton(i18n.T("Save and reconnect"), func() {
+			save(true)
+		}),
+		widget.NewButton(i18n.T("Cancel"), window.Close))
+
+	window.SetContent(container.NewVBox(form, buttons))
+	window.Resize(fyne.NewSize(SETTINGS_WINDOW_WIDTH, 0))
+	window.Show()
+}
+
+func (gui *ChatGui) ShowServerPicker(title string, onChoose func(profileName string)) {
+	// shows dialog with server profiles. Active profile is selected
+	settings := utils.GetSettingsFromFile()
+	profiles := widget.NewRadioGroup(settings.GetProfileNames(), nil)
+	profiles.SetSelected(settings.ActiveProfile)
+	dialog.ShowCustomConfirm(title, i18n.T("Connect"), i18n.T("Cancel"), profiles,
+		func(result bool) {
+			if result && profiles.Selected != "" {
+				onChoose(profiles.Selected)
+			}
+		}, gui.Window)
+}
+
+func (gui *ChatGui) ShowSwitchServerDialog() {
+	gui.ShowServerPicker(i18n.T("Switch server"), func(profileName string) {
+		if gui.OnSwitchServer != nil {
+			gui.OnSwitchServer(profileName)
+		}
+	})
+}
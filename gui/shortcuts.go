@@ -0,0 +1,106 @@
+// shortcuts.go
+package gui
+
+import (
+	"fyne.io/fyne"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/driver/desktop"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+)
+
+type shortcutHelp struct {
+	Keys        string
+	Description string
+}
+
+var shortcutsHelp = []shortcutHelp{
+	{"Ctrl+K", "Jump to channel or user"},
+	{"Ctrl+F", "Search messages"},
+	{"Esc", "Close popup or dialog, cancel reply"},
+	{"Up", "Edit last own message (in empty input)"},
+	{"PageUp / PageDown", "Scroll messages"},
+	{"Shift+Enter", "New line in message"},
+}
+
+func buildShortcuts(gui *ChatGui) {
+	// sets main window shortcuts. Keys without modifiers are handled
+	// by focused inputs or by canvas if nothing is focused
+	gui.AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyK,
+		Modifier: desktop.ControlModifier}, func(_ fyne.Shortcut) {
+		gui.ShowQuickSwitcher()
+	})
+	gui.AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyF,
+		Modifier: desktop.ControlModifier}, func(_ fyne.Shortcut) {
+		gui.Window.Canvas().Focus(gui.SearchInput)
+	})
+	gui.Window.Canvas().SetOnTypedKey(func(key *fyne.KeyEvent) {
+		gui.handleKey(key)
+	})
+	gui.SearchInput.SetOnKey(gui.handleKey)
+	gui.MessageInput.SetOnKey(func(key *fyne.KeyEvent) bool {
+		if key.Name == fyne.KeyUp && gui.MessageInput.Text == "" {
+			return gui.editLastOwnMessage()
+		}
+		return gui.handleKey(key)
+	})
+}
+
+func (gui *ChatGui) handleKey(key *fyne.KeyEvent) bool {
+	// handles keys which work in any input. Returns false for other keys
+	switch key.Name {
+	case fyne.KeyEscape:
+		return gui.closeTopmost()
+	case fyne.KeyPageUp:
+		gui.MessageListScroller.ScrollByPages(-1)
+		return true
+	case fyne.KeyPageDown:
+		gui.MessageListScroller.ScrollByPages(1)
+		return true
+	}
+	return false
+}
+
+func (gui *ChatGui) closeTopmost() bool {
+	// closes popups and dialogs one by one, then cancels reply
+	if gui.SuggestionPopup != nil {
+		gui.HideSuggestions()
+		return true
+	}
+	if gui.QuickSwitcher != nil {
+		gui.QuickSwitcher.Hide()
+		return true
+	}
+	if overlay := gui.Window.Canvas().Overlays().Top(); overlay != nil {
+		overlay.Hide() // popups and dialogs remove themselves from overlays
+		return true
+	}
+	if gui.ReplyMessageId != 0 {
+		gui.CancelReply()
+		return true
+	}
+	return false
+}
+
+func (gui *ChatGui) editLastOwnMessage() bool {
+	// opens edit dialog of newest own message in opened channel
+	if !gui.ServerFeatures[models.FEATURE_EDITS] || gui.OnEditMessage == nil {
+		return false
+	}
+	msg, ok := gui.MessagesList.GetLastMessageOf(gui.CurrentUser.Id)
+	if !ok || msg.HasAttachment() {
+		return false
+	}
+	gui.ShowEditMessageDialog(msg)
+	return true
+}
+
+func (gui *ChatGui) ShowShortcutsDialog() {
+	form := widget.NewForm()
+	for _, help := range shortcutsHelp {
+		form.Append(help.Keys, widget.NewLabel(i18n.T(help.Description)))
+	}
+	dialog.ShowCustom(i18n.T("Keyboard shortcuts"), i18n.T("Close"), form, gui.Window)
+}
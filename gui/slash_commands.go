@@ -0,0 +1,62 @@
+// slash_commands.go
+package gui
+
+import (
+	"strings"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+	"chat/utils"
+)
+
+func (gui *ChatGui) SetCommands(commands []utils.SlashCommand) {
+	// commands are run by client, gui only suggests them
+	gui.Commands = commands
+}
+
+func (gui *ChatGui) UpdateCommandAutocomplete(input *EnterEntry) {
+	// shows commands above input while name of command is being typed.
+	// Chosen command replaces typed name, then its arguments are typed
+	query, ok := utils.GetCommandQuery(input.Text)
+	if !ok {
+		return
+	}
+	list := fyne.NewContainerWithLayout(layout.NewVBoxLayout())
+	for _, command := range gui.Commands {
+		command := command
+		if !strings.HasPrefix(command.Name, strings.ToLower(query)) {
+			continue
+		}
+		title := strings.TrimSpace("/" + command.Name + " " + command.Usage)
+		button := widget.NewButton(title+" — "+i18n.T(command.Description), func() {
+			gui.HideSuggestions()
+			input.SetText("/" + command.Name + " ")
+			gui.Window.Canvas().Focus(input)
+		})
+		button.Alignment = widget.ButtonAlignLeading
+		list.AddObject(button)
+	}
+	if len(list.Objects) == 0 {
+		return
+	}
+	gui.showSuggestions(input, list)
+}
+
+func (gui *ChatGui) GetProfile(username string) models.Profile {
+	// returns last received profile, empty if it wasn't loaded
+	return gui.Profiles[username]
+}
+
+func (gui *ChatGui) FindKnownUser(username string) (models.User, bool) {
+	// finds user among authors of messages, private chats, members and contacts
+	for _, user := range gui.KnownUsers {
+		if strings.EqualFold(user.Username, username) {
+			return user, true
+		}
+	}
+	return models.User{}, false
+}
@@ -0,0 +1,64 @@
+// spell_check.go
+package gui
+
+import (
+	"strings"
+
+	"fyne.io/fyne"
+
+	"chat/i18n"
+	"chat/utils"
+)
+
+const MAX_SPELLING_MENU_WORDS int = 5
+
+func (gui *ChatGui) SetSpellChecker(checker *utils.SpellChecker) {
+	// nil checker disables spell checking
+	gui.SpellChecker = checker
+	gui.updateSpelling()
+}
+
+func (gui *ChatGui) updateSpelling() {
+	// entry can't underline words, so misspelled words are listed under input
+	if gui.SpellChecker == nil {
+		gui.SpellingLabel.Hide()
+		return
+	}
+	misspelled := gui.SpellChecker.GetMisspelledWords(gui.MessageInput.Text)
+	if len(misspelled) == 0 {
+		gui.SpellingLabel.Hide()
+		return
+	}
+	gui.SpellingLabel.SetText(i18n.Tf("Misspelled: %s (right click input for suggestions)",
+		strings.Join(misspelled, ", ")))
+	gui.SpellingLabel.Show()
+}
+
+func (gui *ChatGui) getSpellingMenuItems() []*fyne.MenuItem {
+	// returns items with suggestions for misspelled words of input
+	if gui.SpellChecker == nil {
+		return nil
+	}
+	var items []*fyne.MenuItem
+	for _, word := range gui.SpellChecker.GetMisspelledWords(gui.MessageInput.Text) {
+		if len(items) == MAX_SPELLING_MENU_WORDS {
+			break
+		}
+		word := word
+		var suggestionItems []*fyne.MenuItem
+		for _, suggestion := range gui.SpellChecker.GetSuggestions(word) {
+			suggestion := suggestion
+			suggestionItems = append(suggestionItems, fyne.NewMenuItem(suggestion, func() {
+				text := utils.ReplaceWord(gui.MessageInput.Text, word, suggestion)
+				gui.MessageInput.SetText(text)
+			}))
+		}
+		item := fyne.NewMenuItem(i18n.Tf("%s (no suggestions)", word), nil)
+		if len(suggestionItems) > 0 {
+			item = fyne.NewMenuItem(word, nil)
+			item.ChildMenu = fyne.NewMenu("", suggestionItems...)
+		}
+		items = append(items, item)
+	}
+	return items
+}
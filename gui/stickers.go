@@ -0,0 +1,85 @@
+// stickers.go
+package gui
+
+import (
+	"strings"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+)
+
+const STICKER_SIZE int = 128
+const STICKER_PICKER_SIZE int = 64
+const STICKER_PICKER_COLUMNS int = 5
+const STICKER_PICKER_HEIGHT int = 280
+
+func (gui *ChatGui) SetOnLoadStickers(onLoadStickers func(string, func([]models.Sticker))) {
+	gui.OnLoadStickers = func(query string, onLoaded func([]models.Sticker)) {
+		gui.dispatch(func() { onLoadStickers(query, onLoaded) })
+	}
+}
+
+func (gui *ChatGui) SetOnSendSticker(onSendSticker func(models.Sticker)) {
+	gui.OnSendSticker = func(sticker models.Sticker) {
+		gui.dispatch(func() { onSendSticker(sticker) })
+	}
+}
+
+func (gui *ChatGui) hasStickers() bool {
+	return gui.ServerFeatures[models.FEATURE_STICKERS] && gui.OnLoadStickers != nil &&
+		gui.OnSendSticker != nil
+}
+
+func (gui *ChatGui) newStickerPicker(onPicked func()) fyne.CanvasObject {
+	// tab of emoji picker with stickers of local pack and provider.
+	// Query is sent to provider, local stickers are filtered by file name
+	grid := fyne.NewContainerWithLayout(layout.NewGridLayout(STICKER_PICKER_COLUMNS))
+	status := widget.NewLabel(i18n.T("Loading stickers..."))
+	query := ""
+	showStickers := func(stickers []models.Sticker) {
+		grid.Objects = nil
+		for _, sticker := range stickers {
+			sticker := sticker
+			size := fyne.NewSize(STICKER_PICKER_SIZE, STICKER_PICKER_SIZE)
+			grid.AddObject(newSizedImagePreview(sticker.Path, size, func() {
+				onPicked()
+				gui.OnSendSticker(sticker)
+			}))
+		}
+		status.SetText(i18n.T("No stickers found."))
+		status.Hidden = len(stickers) > 0
+		status.Refresh()
+		grid.Refresh()
+	}
+	search := func(text string) {
+		query = strings.TrimSpace(text)
+		currentQuery := query
+		gui.OnLoadStickers(currentQuery, func(stickers []models.Sticker) {
+			if query == currentQuery { // results of older queries are skipped
+				showStickers(stickers)
+			}
+		})
+	}
+	input := NewEnterEntry()
+	input.SetPlaceHolder(i18n.T("search stickers"))
+	input.SetOnEnter(func() {
+		search(input.Text)
+	})
+	search("")
+
+	scroller := container.NewVScroll(container.NewVBox(status, grid))
+	scroller.SetMinSize(fyne.NewSize(STICKER_PICKER_COLUMNS*(STICKER_PICKER_SIZE+4),
+		STICKER_PICKER_HEIGHT))
+	return container.NewBorder(input, nil, nil, nil, scroller)
+}
+
+func (messageObj *MessageObject) AddSticker(path string) {
+	// sticker is smaller than image preview and isn't opened by tap
+	size := fyne.NewSize(STICKER_SIZE, STICKER_SIZE)
+	messageObj.content.AddObject(newSizedImagePreview(path, size, func() {}))
+}
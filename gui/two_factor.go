@@ -0,0 +1,139 @@
+// two_factor.go
+package gui
+
+import (
+	"errors"
+	"strings"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/canvas"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/widget"
+
+	"chat/encrypt"
+	"chat/i18n"
+	"chat/models"
+	"chat/utils"
+)
+
+const QR_CODE_SIZE int = 240
+const QR_CODE_SCALE int = 6 // pixels of module in image
+
+func (gui *ChatGui) SetOnTwoFactorCodeSubmit(onTwoFactorCodeSubmit func(string)) {
+	gui.OnTwoFactorCodeSubmit = func(code string) {
+		gui.dispatch(func() { onTwoFactorCodeSubmit(code) })
+	}
+}
+
+func (gui *ChatGui) SetOnEnableTwoFactor(onEnableTwoFactor func(string)) {
+	gui.OnEnableTwoFactor = func(code string) {
+		gui.dispatch(func() { onEnableTwoFactor(code) })
+	}
+}
+
+func (gui *ChatGui) SetOnDisableTwoFactor(onDisableTwoFactor func(string)) {
+	gui.OnDisableTwoFactor = func(password string) {
+		gui.dispatch(func() { onDisableTwoFactor(password) })
+	}
+}
+
+func (gui *ChatGui) SetTwoFactorEnabled(enabled bool) {
+	gui.TwoFactorEnabled = enabled
+}
+
+func newCodeEntry() (*widget.Entry, func() error) {
+	// entry of one-time code and its validation. Spaces of apps are allowed
+	inputCode := widget.NewEntry()
+	inputCode.SetPlaceHolder(i18n.T("code from authenticator app"))
+	validate := func() error {
+		code := strings.ReplaceAll(inputCode.Text, " ", "")
+		if len(code) != encrypt.TOTP_DIGITS || strings.Trim(code, "0123456789") != "" {
+			return errors.New(i18n.Tf("Enter %d digits of code.", encrypt.TOTP_DIGITS))
+		}
+		return nil
+	}
+	return inputCode, validate
+}
+
+func (gui *ChatGui) ShowTwoFactorCodeDialog(title string) {
+	// asks code of authenticator app after password was accepted
+	inputCode, validate := newCodeEntry()
+	update := gui.showFormPopup(title, inputCode, i18n.T("Login"), validate, func() {
+		if gui.OnTwoFactorCodeSubmit != nil {
+			gui.OnTwoFactorCodeSubmit(inputCode.Text)
+		}
+	})
+	inputCode.OnChanged = func(string) { update() }
+	gui.Window.Canvas().Focus(inputCode)
+}
+
+func (gui *ChatGui) ShowTwoFactorDialog() {
+	// enabling starts with request of secret which is shown by ShowTwoFactorSecret
+	if !gui.canChangeAccount() {
+		return
+	}
+	if !gui.ServerFeatures[models.FEATURE_TWO_FACTOR] {
+		gui.ShowError("Server doesn't support two-factor authentication.")
+		return
+	}
+	if !gui.TwoFactorEnabled {
+		if gui.OnEnableTwoFactor != nil {
+			gui.OnEnableTwoFactor("")
+		}
+		return
+	}
+	inputPassword := widget.NewPasswordEntry()
+	inputPassword.SetPlaceHolder(i18n.T("password"))
+	validate := func() error {
+		if inputPassword.Text == "" {
+			return errors.New(i18n.T("Enter password."))
+		}
+		return nil
+	}
+	title := i18n.T("Two-factor authentication is enabled. " +
+		"Enter password to disable it.")
+	update := gui.showFormPopup(title, inputPassword, i18n.T("Disable"), validate, func() {
+		if gui.OnDisableTwoFactor != nil {
+			gui.OnDisableTwoFactor(inputPassword.Text)
+		}
+	})
+	inputPassword.OnChanged = func(string) { update() }
+	gui.Window.Canvas().Focus(inputPassword)
+}
+
+func (gui *ChatGui) ShowTwoFactorSecret(secret models.TwoFactorSecret) {
+	// shows QR code of secret for authenticator app and asks code made from it
+	modules, err := utils.EncodeQrCode([]byte(secret.Uri))
+	if utils.IsError(err) {
+		gui.ShowError(err.Error())
+		return
+	}
+	qrCode := canvas.NewImageFromImage(utils.GetQrCodeImage(modules, QR_CODE_SCALE))
+	qrCode.FillMode = canvas.ImageFillContain
+	qrCode.ScaleMode = canvas.ImageScalePixels
+	qrCode.SetMinSize(fyne.NewSize(QR_CODE_SIZE, QR_CODE_SIZE))
+	secretEntry := widget.NewEntry() // secret can be copied for manual input
+	secretEntry.SetText(secret.Secret)
+	secretEntry.OnChanged = func(string) { secretEntry.SetText(secret.Secret) }
+	inputCode, validate := newCodeEntry()
+
+	title := i18n.T("Scan QR code with authenticator app or enter secret, " +
+		"then enter code from app.")
+	content := container.NewVBox(qrCode, secretEntry, inputCode)
+	update := gui.showFormPopup(title, content, i18n.T("Enable"), validate, func() {
+		if gui.OnEnableTwoFactor != nil {
+			gui.OnEnableTwoFactor(inputCode.Text)
+		}
+	})
+	inputCode.OnChanged = func(string) { update() }
+	gui.Window.Canvas().Focus(inputCode)
+}
+
+func (gui *ChatGui) ShowTwoFactorStatus(enabled bool) {
+	gui.TwoFactorEnabled = enabled
+	if enabled {
+		gui.ShowInfo("Two-factor authentication is enabled. Codes of app are asked on login.")
+	} else {
+		gui.ShowInfo("Two-factor authentication is disabled.")
+	}
+}
@@ -0,0 +1,111 @@
+// user_filters.go
+package gui
+
+import (
+	"sort"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+)
+
+func (gui *ChatGui) SetUserFilters(filters map[int64]models.UserFilter) {
+	// filters are changed by client, gui only shows them
+	gui.UserFilters = filters
+}
+
+func (gui *ChatGui) SetOnSetUserFilter(onSetUserFilter func(models.UserFilter)) {
+	gui.OnSetUserFilter = func(filter models.UserFilter) {
+		gui.dispatch(func() { onSetUserFilter(filter) })
+	}
+}
+
+func (gui *ChatGui) setUserFilter(filter models.UserFilter) {
+	if gui.OnSetUserFilter != nil {
+		gui.OnSetUserFilter(filter)
+	}
+}
+
+func (gui *ChatGui) getUserFilterMenuItems(user models.User) []*fyne.MenuItem {
+	// returns block and mute actions for author of message
+	filter, ok := gui.UserFilters[user.Id]
+	if !ok {
+		filter = models.UserFilter{User: user}
+	}
+	blockTitle := i18n.Tf("Block %s", user.Username)
+	if filter.Blocked {
+		blockTitle = i18n.Tf("Unblock %s", user.Username)
+	}
+	muteTitle := i18n.Tf("Mute %s", user.Username)
+	if filter.Muted {
+		muteTitle = i18n.Tf("Unmute %s", user.Username)
+	}
+	return []*fyne.MenuItem{
+		fyne.NewMenuItem(blockTitle, func() {
+			if filter.Blocked {
+				filter.Blocked = false
+				gui.setUserFilter(filter)
+				return
+			}
+			dialog.ShowConfirm(i18n.T("Block user"),
+				i18n.Tf("Messages of %s won't be shown. Block this user?", user.Username),
+				func(result bool) {
+					if result {
+						filter.Blocked = true
+						gui.setUserFilter(filter)
+					}
+				}, gui.Window)
+		}),
+		fyne.NewMenuItem(muteTitle, func() {
+			filter.Muted = !filter.Muted
+			gui.setUserFilter(filter)
+		})}
+}
+
+func (gui *ChatGui) ShowBlockedUsersDialog() {
+	// shows blocked and muted users. Messages of blocked users can't be
+	// opened in context menu, so they are unblocked here
+	var filters []models.UserFilter
+	for _, filter := range gui.UserFilters {
+		filters = append(filters, filter)
+	}
+	if len(filters) == 0 {
+		dialog.ShowInformation(i18n.T("Blocked users"), i18n.T("There are no blocked or muted users."),
+			gui.Window)
+		return
+	}
+	sort.Slice(filters, func(i, j int) bool {
+		return filters[i].User.Username < filters[j].User.Username
+	})
+	form := widget.NewForm()
+	blockedChecks := make([]*widget.Check, len(filters))
+	mutedChecks := make([]*widget.Check, len(filters))
+	for i, filter := range filters {
+		blockedChecks[i] = widget.NewCheck(i18n.T("blocked"), nil)
+		blockedChecks[i].SetChecked(filter.Blocked)
+		mutedChecks[i] = widget.NewCheck(i18n.T("muted"), nil)
+		mutedChecks[i].SetChecked(filter.Muted)
+		form.Append(filter.User.Username, widget.NewHBox(blockedChecks[i], mutedChecks[i]))
+	}
+	scroller := widget.NewVScrollContainer(form)
+	scroller.SetMinSize(fyne.NewSize(300, 200))
+
+	dialog.ShowCustomConfirm(i18n.T("Blocked users"), i18n.T("Save"), i18n.T("Cancel"), scroller,
+		func(result bool) {
+			if !result {
+				return
+			}
+			for i, filter := range filters {
+				if filter.Blocked == blockedChecks[i].Checked &&
+					filter.Muted == mutedChecks[i].Checked {
+					continue
+				}
+				filter.Blocked = blockedChecks[i].Checked
+				filter.Muted = mutedChecks[i].Checked
+				gui.setUserFilter(filter)
+			}
+		}, gui.Window)
+}
@@ -0,0 +1,91 @@
+// user_search.go
+package gui
+
+import (
+	"strings"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+)
+
+func (gui *ChatGui) SetOnSearchUsers(onSearchUsers func(string)) {
+	gui.OnSearchUsers = func(query string) {
+		gui.dispatch(func() { onSearchUsers(query) })
+	}
+}
+
+func (gui *ChatGui) ShowFindUserDialog() {
+	// finds any registered user, not only authors of received messages
+	if !gui.ServerFeatures[models.FEATURE_USER_SEARCH] || gui.OnSearchUsers == nil {
+		gui.ShowError("Server doesn't support user search.")
+		return
+	}
+	input := NewEnterEntry()
+	input.SetPlaceHolder(i18n.T("username or display name"))
+	results := container.NewVBox()
+	search := func() {
+		query := strings.TrimSpace(input.Text)
+		if query == "" {
+			return
+		}
+		gui.UserSearchQuery = query
+		results.Objects = []fyne.CanvasObject{widget.NewLabel(i18n.T("Searching..."))}
+		results.Refresh()
+		gui.OnSearchUsers(query)
+	}
+	input.SetOnEnter(search)
+	searchBar := container.NewBorder(nil, nil, nil,
+		widget.NewButton(i18n.T("Search"), search), input)
+	scroller := widget.NewVScrollContainer(results)
+	scroller.SetMinSize(fyne.NewSize(350, 250))
+	content := fyne.NewContainerWithLayout(layout.NewBorderLayout(searchBar, nil, nil, nil),
+		searchBar, scroller)
+
+	searchDialog := dialog.NewCustom(i18n.T("Find user"), i18n.T("Close"), content, gui.Window)
+	searchDialog.SetOnClosed(func() {
+		gui.UserSearchResults = nil
+		gui.UserSearchDialog = nil
+	})
+	gui.UserSearchResults = results
+	gui.UserSearchDialog = searchDialog
+	searchDialog.Show()
+	gui.Window.Canvas().Focus(input)
+}
+
+func (gui *ChatGui) SetUserSearchResult(result models.UsersSearchResult) {
+	// shows found users in opened dialog. Results of older queries are skipped
+	if gui.UserSearchResults == nil || result.Query != gui.UserSearchQuery {
+		return
+	}
+	var objects []fyne.CanvasObject
+	for _, user := range result.Users {
+		user := user
+		gui.KnownUsers[user.Id] = user
+		row := container.NewHBox(widget.NewLabel(getDisplayName(gui.Profiles, user.Username)),
+			layout.NewSpacer(), widget.NewButton(i18n.T("Message"), func() {
+				gui.UserSearchDialog.Hide()
+				gui.OnUsernameSelect(user)
+			}))
+		if gui.ServerFeatures[models.FEATURE_CONTACTS] && gui.OnAddContact != nil &&
+			!gui.ContactList.IsContact(user.Id) {
+			var addButton *widget.Button
+			addButton = widget.NewButton(i18n.T("Add to contacts"), func() {
+				gui.OnAddContact(user)
+				addButton.Disable()
+			})
+			row.AddObject(addButton)
+		}
+		objects = append(objects, row)
+	}
+	if len(objects) == 0 {
+		objects = append(objects, widget.NewLabel(i18n.T("No users found.")))
+	}
+	gui.UserSearchResults.Objects = objects
+	gui.UserSearchResults.Refresh()
+}
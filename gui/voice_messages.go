@@ -0,0 +1,147 @@
+// voice_messages.go
+package gui
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/widget"
+
+	"chat/i18n"
+	"chat/models"
+	"chat/utils"
+)
+
+const VOICE_TIMER_INTERVAL = 500 * time.Millisecond
+
+func (gui *ChatGui) SetOnStartRecording(onStartRecording func() bool) {
+	gui.OnStartRecording = onStartRecording
+}
+
+func (gui *ChatGui) SetOnStopRecording(onStopRecording func()) {
+	gui.OnStopRecording = func() { gui.dispatch(onStopRecording) }
+}
+
+func (gui *ChatGui) SetOnPlayVoice(onPlayVoice func(models.Attachment, func())) {
+	gui.OnPlayVoice = func(attachment models.Attachment, onFinished func()) {
+		gui.dispatch(func() { onPlayVoice(attachment, onFinished) })
+	}
+}
+
+func (gui *ChatGui) SetOnStopVoice(onStopVoice func()) {
+	gui.OnStopVoice = func() { gui.dispatch(onStopVoice) }
+}
+
+func (gui *ChatGui) toggleRecording() {
+	// record button starts recording and sends voice message on second tap
+	if gui.IsRecording() {
+		gui.StopRecording()
+		return
+	}
+	if gui.OnStartRecording == nil || !gui.OnStartRecording() {
+		return
+	}
+	gui.RecordingStart = time.Now()
+	gui.RecordButton.Importance = widget.HighImportance
+	go gui.trackRecording(gui.RecordingStart)
+}
+
+func (gui *ChatGui) IsRecording() bool {
+	return !gui.RecordingStart.IsZero()
+}
+
+func (gui *ChatGui) StopRecording() {
+	if !gui.IsRecording() {
+		return
+	}
+	gui.RecordingStart = time.Time{}
+	gui.RecordButton.Importance = widget.MediumImportance
+	gui.RecordButton.SetText(i18n.T("Record"))
+	if gui.OnStopRecording != nil {
+		gui.OnStopRecording()
+	}
+}
+
+func (gui *ChatGui) trackRecording(start time.Time) {
+	// shows duration on record button. Long recording is stopped and sent
+	for range time.Tick(VOICE_TIMER_INTERVAL) {
+		if gui.RecordingStart != start {
+			return
+		}
+		duration := time.Since(start)
+		if duration >= utils.MAX_VOICE_DURATION {
+			gui.StopRecording()
+			return
+		}
+		gui.RecordButton.SetText(i18n.Tf("Stop %s", formatDuration(duration)))
+	}
+}
+
+func formatDuration(duration time.Duration) string {
+	seconds := int(duration / time.Second)
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// inline player of voice message. Player of system can't pause,
+// so stopped message is played from the beginning
+type VoiceMessage struct {
+	container *fyne.Container
+	button    *widget.Button
+	label     *widget.Label
+	duration  time.Duration
+	startTime time.Time // zero if message isn't playing
+	onPlay    func(onFinished func())
+	onStop    func()
+}
+
+func NewVoiceMessage(duration time.Duration, onPlay func(onFinished func()),
+	onStop func()) *VoiceMessage {
+	voice := &VoiceMessage{duration: duration, onPlay: onPlay, onStop: onStop}
+	voice.button = widget.NewButton(i18n.T("Play"), voice.toggle)
+	voice.label = widget.NewLabel(formatDuration(duration))
+	voice.container = container.NewHBox(voice.button, voice.label)
+	return voice
+}
+
+func (voice *VoiceMessage) toggle() {
+	if !voice.startTime.IsZero() {
+		voice.onStop()
+		return
+	}
+	start := time.Now()
+	voice.startTime = start
+	voice.button.SetText(i18n.T("Stop"))
+	go voice.trackPlaying(start)
+	voice.onPlay(func() {
+		if voice.startTime == start {
+			voice.setStopped()
+		}
+	})
+}
+
+func (voice *VoiceMessage) trackPlaying(start time.Time) {
+	for range time.Tick(VOICE_TIMER_INTERVAL) {
+		if voice.startTime != start {
+			return
+		}
+		elapsed := time.Since(start)
+		if elapsed > voice.duration {
+			elapsed = voice.duration
+		}
+		voice.label.SetText(formatDuration(elapsed) + " / " + formatDuration(voice.duration))
+	}
+}
+
+func (voice *VoiceMessage) setStopped() {
+	voice.startTime = time.Time{}
+	voice.button.SetText(i18n.T("Play"))
+	voice.label.SetText(formatDuration(voice.duration))
+}
+
+func (messageObj *MessageObject) AddVoiceMessage(duration time.Duration,
+	onPlay func(onFinished func()), onStop func()) {
+	// adds player under message text instead of file info
+	messageObj.content.AddObject(NewVoiceMessage(duration, onPlay, onStop).container)
+}
@@ -0,0 +1,44 @@
+// window_state.go
+package gui
+
+import (
+	"fyne.io/fyne"
+
+	"chat/utils"
+)
+
+func (gui *ChatGui) SetOnSaveWindowState(onSaveWindowState func(utils.WindowState)) {
+	gui.OnSaveWindowState = onSaveWindowState
+}
+
+func (gui *ChatGui) RestoreWindowState(state utils.WindowState) {
+	// applies state of previous run before window is shown.
+	// Position isn't restored: window manager places the window
+	if state.Width > 0 && state.Height > 0 {
+		gui.Window.Resize(fyne.NewSize(state.Width, state.Height))
+	}
+	gui.Window.SetFullScreen(state.FullScreen)
+	// minimal widths of sidebars are kept by splits
+	gui.LeftSplit.SetOffset(state.LeftSidebar)
+	gui.RightSplit.SetOffset(1 - state.RightSidebar)
+}
+
+func (gui *ChatGui) GetWindowState() utils.WindowState {
+	size := gui.Window.Canvas().Size()
+	return utils.WindowState{
+		Width:        size.Width,
+		Height:       size.Height,
+		FullScreen:   gui.Window.FullScreen(),
+		LeftSidebar:  gui.LeftSplit.Offset,
+		RightSidebar: 1 - gui.RightSplit.Offset}
+}
+
+func (gui *ChatGui) processClose() {
+	// state is saved before connection is closed
+	if gui.OnSaveWindowState != nil {
+		gui.OnSaveWindowState(gui.GetWindowState())
+	}
+	if gui.OnClose != nil {
+		gui.OnClose()
+	}
+}
@@ -0,0 +1,71 @@
+// i18n.go
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"chat/utils"
+)
+
+const LOCALES_DIR = "locales"
+const DEFAULT_LANGUAGE = "en" // texts of code are english, so it has no locale file
+
+var translations map[string]string // map: english text -> translated text
+var translationsLock sync.RWMutex
+
+func GetLanguages() []string {
+	// returns default language and languages of locale files
+	languages := []string{DEFAULT_LANGUAGE}
+	files, err := ioutil.ReadDir(LOCALES_DIR)
+	if utils.IsError(err) {
+		return languages
+	}
+	for _, file := range files {
+		if language := strings.TrimSuffix(file.Name(), ".json"); language != file.Name() &&
+			language != DEFAULT_LANGUAGE {
+			languages = append(languages, language)
+		}
+	}
+	sort.Strings(languages[1:])
+	return languages
+}
+
+func SetLanguage(language string) error {
+	// loads locale file of language. Texts without translation stay english
+	loaded := make(map[string]string)
+	if language != "" && language != DEFAULT_LANGUAGE {
+		data, err := ioutil.ReadFile(filepath.Join(LOCALES_DIR, language+".json"))
+		if utils.IsError(err) {
+			return err
+		}
+		err = json.Unmarshal(data, &loaded)
+		if utils.IsError(err) {
+			return err
+		}
+	}
+	translationsLock.Lock()
+	translations = loaded
+	translationsLock.Unlock()
+	return nil
+}
+
+func T(text string) string {
+	// returns translation of text of chosen language
+	translationsLock.RLock()
+	defer translationsLock.RUnlock()
+	if translated, ok := translations[text]; ok && translated != "" {
+		return translated
+	}
+	return text
+}
+
+func Tf(format string, args ...interface{}) string {
+	// formats translated format string, so words can be reordered in translation
+	return fmt.Sprintf(T(format), args...)
+}
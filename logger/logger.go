@@ -0,0 +1,186 @@
+// logger.go
+package logger
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const LEVEL_DEBUG = 0
+const LEVEL_INFO = 1
+const LEVEL_WARNING = 2
+const LEVEL_ERROR = 3
+
+var LEVEL_NAMES = []string{"DEBUG", "INFO", "WARNING", "ERROR"}
+
+const APP_DIR = "golang-chat" // directory of logs inside user config dir
+const LOGS_DIR = "logs"
+const MAX_LOG_SIZE int64 = 1 << 20 // log file is rotated when it's bigger
+const MAX_LOG_FILES = 3            // current file and rotated ones: name.log.1, name.log.2
+const HISTORY_SIZE = 1000          // lines kept in memory for debug window
+const TIME_FORMAT = "2006-01-02 15:04:05.000"
+
+// leveled log which writes lines to stderr and rotated file.
+// Last lines are kept for debug window
+type Logger struct {
+	mutex   sync.Mutex
+	Level   int
+	path    string   // empty if file isn't opened
+	file    *os.File // nil if file can't be written
+	size    int64
+	history []string
+	onLine  func(line string)
+}
+
+var std = &Logger{Level: LEVEL_INFO}
+
+// standard log of packages is written as info lines
+type stdWriter struct{}
+
+func (stdWriter) Write(data []byte) (int, error) {
+	std.write(LEVEL_INFO, strings.TrimRight(string(data), "\n"))
+	return len(data), nil
+}
+
+func GetLogsDir() string {
+	// logs are kept with other user data. Dir of app is used without config dir
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return LOGS_DIR
+	}
+	return filepath.Join(configDir, APP_DIR, LOGS_DIR)
+}
+
+func Init(name string, verbose bool) error {
+	// opens name.log in logs dir. Verbose mode writes debug lines too.
+	// Lines are written to stderr even if file can't be opened
+	std.mutex.Lock()
+	defer std.mutex.Unlock()
+	if verbose {
+		std.Level = LEVEL_DEBUG
+	}
+	log.SetFlags(0)
+	log.SetOutput(stdWriter{})
+
+	dir := GetLogsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	std.path = filepath.Join(dir, name+".log")
+	return std.openFile()
+}
+
+func (logger *Logger) openFile() error {
+	file, err := os.OpenFile(logger.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	logger.file = file
+	logger.size = info.Size()
+	return nil
+}
+
+func (logger *Logger) rotate() {
+	// shifts old files: name.log.1 -> name.log.2, oldest one is removed
+	logger.file.Close()
+	logger.file = nil
+	for i := MAX_LOG_FILES - 1; i > 0; i-- {
+		source := logger.path
+		if i > 1 {
+			source = fmt.Sprintf("%s.%d", logger.path, i-1)
+		}
+		os.Rename(source, fmt.Sprintf("%s.%d", logger.path, i))
+	}
+	if err := logger.openFile(); err != nil {
+		fmt.Fprintln(os.Stderr, "Can't open log file: "+err.Error())
+	}
+}
+
+func (logger *Logger) write(level int, text string) {
+	logger.mutex.Lock()
+	if level < logger.Level {
+		logger.mutex.Unlock()
+		return
+	}
+	line := time.Now().Format(TIME_FORMAT) + " " + LEVEL_NAMES[level] + " " + text
+	fmt.Fprintln(os.Stderr, line)
+	if logger.file != nil {
+		if logger.size+int64(len(line))+1 > MAX_LOG_SIZE {
+			logger.rotate()
+		}
+	}
+	if logger.file != nil {
+		written, _ := fmt.Fprintln(logger.file, line)
+		logger.size += int64(written)
+	}
+	logger.history = append(logger.history, line)
+	if len(logger.history) > HISTORY_SIZE {
+		logger.history = logger.history[len(logger.history)-HISTORY_SIZE:]
+	}
+	onLine := logger.onLine
+	logger.mutex.Unlock()
+	if onLine != nil { // callback can read history
+		onLine(line)
+	}
+}
+
+func IsVerbose() bool {
+	std.mutex.Lock()
+	defer std.mutex.Unlock()
+	return std.Level == LEVEL_DEBUG
+}
+
+func GetHistory() []string {
+	std.mutex.Lock()
+	defer std.mutex.Unlock()
+	return append([]string{}, std.history...)
+}
+
+func SetOnLine(onLine func(line string)) {
+	// callback is called with every written line. nil removes it
+	std.mutex.Lock()
+	defer std.mutex.Unlock()
+	std.onLine = onLine
+}
+
+func Debug(args ...interface{}) {
+	std.write(LEVEL_DEBUG, fmt.Sprint(args...))
+}
+
+func Debugf(format string, args ...interface{}) {
+	std.write(LEVEL_DEBUG, fmt.Sprintf(format, args...))
+}
+
+func Info(args ...interface{}) {
+	std.write(LEVEL_INFO, fmt.Sprint(args...))
+}
+
+func Infof(format string, args ...interface{}) {
+	std.write(LEVEL_INFO, fmt.Sprintf(format, args...))
+}
+
+func Warning(args ...interface{}) {
+	std.write(LEVEL_WARNING, fmt.Sprint(args...))
+}
+
+func Warningf(format string, args ...interface{}) {
+	std.write(LEVEL_WARNING, fmt.Sprintf(format, args...))
+}
+
+func Error(args ...interface{}) {
+	std.write(LEVEL_ERROR, fmt.Sprint(args...))
+}
+
+func Errorf(format string, args ...interface{}) {
+	std.write(LEVEL_ERROR, fmt.Sprintf(format, args...))
+}
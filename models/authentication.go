@@ -8,20 +8,117 @@ import (
 type Session struct {
 	User      User      `json:"user"`
 	SecretKey uuid.UUID `json:"secret_key"`
+	Presence  string    `json:"presence"`
+
+	Acks map[int64]MessageAck `json:"-"` // by local id, repeats aren't saved again. Under lock of server
 }
 
+// client sends unsalted hash of password (see encrypt.GetPasswordHash),
+// so password isn't exposed over insecure connection. Server saves salted
+// hash of it. Old clients sent hash without scheme, their requests are rejected
+const AUTH_SCHEME_SHA256 = "sha256"
+
 type AuthRequest struct {
 	Username     string `json:"username"`
 	PasswordHash string `json:"password_hash"`
+	Scheme       string `json:"scheme"`
+	Code         string `json:"code,omitempty"`   // one-time code if user has two-factor auth
+	Answer       string `json:"answer,omitempty"` // of registration challenge
+}
+
+// server can ask to solve challenge before registration, so bots
+// can't create accounts in bulk
+const CHALLENGE_POW = "pow"         // sha256 of "nonce:answer" must start with Difficulty zero bits
+const CHALLENGE_CAPTCHA = "captcha" // digits of Image are answer
+
+type RegistrationChallenge struct {
+	Kind       string `json:"kind"` // empty if registration is open
+	Nonce      string `json:"nonce,omitempty"`
+	Difficulty int    `json:"difficulty,omitempty"`
+	Image      []byte `json:"image,omitempty"` // png of captcha
+}
+
+// login by token saved after previous successful login
+type TokenAuthRequest struct {
+	Token string `json:"token"`
+}
+
+// provider of OAuth/OpenID Connect login listed in hello of server
+type OAuthProvider struct {
+	Name     string `json:"name"`
+	AuthUrl  string `json:"auth_url"` // authorization endpoint opened in browser
+	ClientId string `json:"client_id"`
+	Scope    string `json:"scope"`
+}
+
+// authorization code which browser passed to client on loopback port.
+// Server exchanges it with provider, verifier of PKCE proves that this
+// client has started the flow
+type OAuthLogin struct {
+	Provider     string `json:"provider"`
+	Code         string `json:"code"`
+	CodeVerifier string `json:"code_verifier"`
+	RedirectUri  string `json:"redirect_uri"`
+	TotpCode     string `json:"totp_code,omitempty"` // sent alone after /2fa-required, code of provider is used once
 }
 
 type SuccessfulAuth struct {
-	User      User      `json: "user"`
-	SecretKey uuid.UUID `json: "secret_key"`
-	CommonKey uuid.UUID `json: "common_key"`
+	User         User      `json: "user"`
+	SecretKey    uuid.UUID `json: "secret_key"`
+	CommonKey    uuid.UUID `json: "common_key"`
+	SessionToken string    `json:"session_token"` // for login without password
+	TwoFactor    bool      `json:"two_factor"`    // one-time codes are asked on login
+}
+
+// answer to login by correct password of user with two-factor auth.
+// Client sends same request again with code of authenticator app
+type TwoFactorRequired struct {
+	Username string `json:"username"`
+}
+
+// enables two-factor auth in two steps: request without code gets new
+// secret, request with code of app made from it saves the secret
+type TwoFactorEnabling struct {
+	Code string `json:"code"`
+}
+
+// secret of enabling sent to client which shows it as QR code
+type TwoFactorSecret struct {
+	Secret string `json:"secret"` // base32 for manual input
+	Uri    string `json:"uri"`    // otpauth:// link
+}
+
+// disables two-factor auth after password check
+type TwoFactorDisabling struct {
+	PasswordHash string `json:"password_hash"`
+	Scheme       string `json:"scheme"`
+}
+
+// sent to all clients of user when two-factor auth is enabled or disabled
+type TwoFactorStatus struct {
+	Enabled bool `json:"enabled"`
+}
+
+// changes password of logged in user. Passwords are sent like in AuthRequest
+type PasswordChange struct {
+	OldPasswordHash string `json:"old_password_hash"`
+	NewPasswordHash string `json:"new_password_hash"`
+	Scheme          string `json:"scheme"`
+}
+
+// deletes logged in user with his private chats after password check
+type AccountDeletion struct {
+	PasswordHash string `json:"password_hash"`
+	Scheme       string `json:"scheme"`
+}
+
+// sent to all clients of user when server closes his sessions.
+// Sockets stay connected, so clients can log in again
+type Logout struct {
+	Reason string `json:"reason"`
 }
 
-type AuthError struct {
-	Description string `json: "description"`
-	Process     string `json: "process"` // registration or login
+// sent by client which logs out itself. Token of its session is revoked
+type LogoutRequest struct {
+	SessionToken string `json:"session_token"` // empty if client has no saved token
 }
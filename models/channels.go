@@ -4,6 +4,13 @@ package models
 type Channel struct {
 	Id    int64  `json: "id"`
 	Title string `json: "title"`
+
+	OwnerId int64 `json:"owner_id"` // creator of group. 0 for main and private channels
+}
+
+// group channels have negative id. Zero id is main channel
+func (channel *Channel) IsGroup() bool {
+	return channel.Id <= 0
 }
 
 type ChannelsPack struct {
@@ -13,3 +20,89 @@ type ChannelsPack struct {
 type ChannelsRequest struct {
 	User User `json:"user"`
 }
+
+type ChannelCreation struct {
+	Title     string  `json:"title"`
+	MemberIds []int64 `json:"member_ids"` // creator is added automatically
+}
+
+const ROLE_OWNER = "owner" // creator of group, assigns admins
+const ROLE_ADMIN = "admin" // moderates group like owner, but can't change roles
+const ROLE_MEMBER = "member"
+
+type ChannelMember struct {
+	User   User   `json:"user"`
+	Online bool   `json:"online"`
+	Role   string `json:"role"` // empty for main channel
+}
+
+// any role can moderate group except plain member
+func IsModeratorRole(role string) bool {
+	return role == ROLE_OWNER || role == ROLE_ADMIN
+}
+
+type ChannelMembersRequest struct {
+	ChatId int64 `json:"chat_id"`
+}
+
+type ChannelMembersPack struct {
+	ChatId  int64           `json:"chat_id"`
+	Members []ChannelMember `json:"members"`
+}
+
+const INVITATION_INVITE = "invite" // member invited user to group
+const INVITATION_JOIN = "join"     // user asked owner to join group
+
+// sent by member of group to invite other user
+type ChannelInvitation struct {
+	ChatId int64 `json:"chat_id"`
+	User   User  `json:"user"`
+}
+
+// sent by user who wants to join group with known title
+type JoinRequest struct {
+	Title string `json:"title"`
+}
+
+// invitation is answered by invited user, join request by owner of group.
+// FromUser is member who invited or user who asked to join
+type Invitation struct {
+	Id        int64   `json:"id"`
+	Kind      string  `json:"kind"`
+	Channel   Channel `json:"channel"`
+	User      User    `json:"user"`
+	FromUser  User    `json:"from_user"`
+	CreatedOn int64   `json:"created_on"`
+}
+
+type InvitationsRequest struct {
+	User User `json:"user"`
+}
+
+// invitations and join requests which current user can answer
+type InvitationsPack struct {
+	Invitations []Invitation `json:"invitations"`
+}
+
+type InvitationAnswer struct {
+	Id       int64 `json:"id"`
+	Accepted bool  `json:"accepted"`
+}
+
+// removes member from group. Sent by owner or admin
+type MemberKick struct {
+	ChatId int64 `json:"chat_id"`
+	User   User  `json:"user"`
+}
+
+// makes member admin or plain member. Sent by owner
+type MemberRoleChange struct {
+	ChatId int64  `json:"chat_id"`
+	User   User   `json:"user"`
+	Role   string `json:"role"`
+}
+
+type ChannelRenaming struct {
+	ChatId int64  `json:"chat_id"`
+	Title  string `json:"title"`
+}
@@ -0,0 +1,63 @@
+// errors.go
+package models
+
+// codes of errors sent by server in /error event. Client shows localized
+// message by code and params, description is shown for unknown codes
+const ERROR_OUTDATED_CLIENT = "outdated-client"
+const ERROR_WRONG_USERNAME = "wrong-username"
+const ERROR_WRONG_PASSWORD = "wrong-password"       // params: remained attempts
+const ERROR_TOO_MANY_ATTEMPTS = "too-many-attempts" // params: limit of attempts
+const ERROR_SESSION_EXPIRED = "session-expired"
+const ERROR_WRONG_CODE = "wrong-code"             // one-time code, params: remained attempts
+const ERROR_OAUTH_FAILED = "oauth-failed"         // description explains reason
+const ERROR_WRONG_ANSWER = "wrong-answer"         // of registration challenge
+const ERROR_INVALID_USERNAME = "invalid-username" // description explains rule
+const ERROR_INVALID_PASSWORD = "invalid-password" // description explains rule
+const ERROR_USERNAME_EXISTS = "username-exists"   // params: username
+const ERROR_INVALID_AVATAR = "invalid-avatar"
+const ERROR_INVALID_PROFILE = "invalid-profile"
+const ERROR_INVALID_CHANNEL_NAME = "invalid-channel-name" // params: title
+const ERROR_CHANNEL_NAME_USED = "channel-name-used"       // params: title
+const ERROR_CHANNEL_NOT_FOUND = "channel-not-found"       // params: title
+const ERROR_USER_NOT_FOUND = "user-not-found"
+const ERROR_ALREADY_MEMBER = "already-member"       // params: username or title of join request
+const ERROR_ALREADY_INVITED = "already-invited"     // params: username
+const ERROR_ALREADY_REQUESTED = "already-requested" // params: title
+const ERROR_PERMISSION_DENIED = "permission-denied"
+const ERROR_INVALID_STICKER = "invalid-sticker"
+const ERROR_INTERNAL = "internal" // server failed, request can be repeated
+
+// requests which can fail. Client chooses recovery action by process
+const PROCESS_LOGIN = "login"
+const PROCESS_TOKEN_LOGIN = "token-login"
+const PROCESS_REGISTRATION = "registration"
+const PROCESS_CHANGE_PASSWORD = "change-password"
+const PROCESS_DELETE_ACCOUNT = "delete-account"
+const PROCESS_ENABLE_2FA = "enable-2fa"
+const PROCESS_DISABLE_2FA = "disable-2fa"
+const PROCESS_OAUTH_LOGIN = "oauth-login"
+const PROCESS_SET_AVATAR = "set-avatar"
+const PROCESS_SET_PROFILE = "set-profile"
+const PROCESS_CREATE_CHANNEL = "create-channel"
+const PROCESS_INVITE_TO_CHANNEL = "invite-to-channel"
+const PROCESS_JOIN_REQUEST = "join-request"
+const PROCESS_RENAME_CHANNEL = "rename-channel"
+const PROCESS_FORWARD_MESSAGE = "forward-message"
+const PROCESS_SEND_STICKER = "send-sticker"
+
+type Error struct {
+	Code        string   `json:"code"`
+	Process     string   `json:"process"`
+	Description string   `json:"description"` // english text for logs and old clients
+	Params      []string `json:"params"`      // values inserted into localized message
+}
+
+func NewError(process string, code string, description string, params ...string) Error {
+	return Error{Code: code, Process: process, Description: description, Params: params}
+}
+
+// login, token login and registration errors are answered without session
+func (err *Error) IsAuthError() bool {
+	return err.Process == PROCESS_LOGIN || err.Process == PROCESS_TOKEN_LOGIN ||
+		err.Process == PROCESS_REGISTRATION || err.Process == PROCESS_OAUTH_LOGIN
+}
@@ -0,0 +1,31 @@
+// files.go
+package models
+
+// file attached to message
+type Attachment struct {
+	Id       int64  `json:"id"`
+	FileName string `json:"file_name"`
+	Size     int64  `json:"size"` // bytes
+}
+
+// part of file uploaded to chat
+type FileUploadChunk struct {
+	UploadId string  `json:"upload_id"` // generated by client
+	Message  Message `json:"message"`   // recipient info. Text is file name
+	Offset   int64   `json:"offset"`
+	Data     []byte  `json:"data"`
+	IsLast   bool    `json:"is_last"`
+}
+
+type FileDownloadRequest struct {
+	AttachmentId int64 `json:"attachment_id"`
+}
+
+// part of file downloaded from server
+type FileDownloadChunk struct {
+	AttachmentId int64  `json:"attachment_id"`
+	Offset       int64  `json:"offset"`
+	Data         []byte `json:"data"`
+	IsLast       bool   `json:"is_last"`
+	Error        string `json:"error"` // not empty if file can't be sent
+}
@@ -0,0 +1,8 @@
+// heartbeat.go
+package models
+
+// sent by client periodically and returned by server as /pong
+type Ping struct {
+	Id     int64 `json:"id"`
+	SentOn int64 `json:"sent_on"` // unix time in milliseconds
+}
@@ -5,36 +5,237 @@ import (
 	"github.com/satori/go.uuid"
 )
 
+const MESSAGE_STATE_PENDING = "pending"
+const MESSAGE_STATE_SENT = "sent"
+const MESSAGE_STATE_DELIVERED = "delivered" // recipient received message
+const MESSAGE_STATE_READ = "read"           // recipient opened chat
+const MESSAGE_STATE_FAILED = "failed"       // queued message wasn't acknowledged by server
+
+const REPLY_PREVIEW_LENGTH int = 100 // characters of replied message sent with reply
+const MAX_PINNED_MESSAGES int = 50   // in one chat
+
 type Message struct {
-	User   User   `json:"user"`
-	ChatId int64  `json:"chat_id"`
-	Text   string `json:"text"`
+	User       User       `json:"user"`
+	ChatId     int64      `json:"chat_id"`
+	Text       string     `json:"text"`
+	Attachment Attachment `json:"attachment"`  // zero id if there is no file
+	ReplyToId  int64      `json:"reply_to_id"` // replied message of same chat. 0 if none
+
+	// forwarded message is sent by its id, server fills author of original
+	ForwardId     int64  `json:"forward_id"`
+	ForwardedFrom string `json:"forwarded_from"` // username, empty if message isn't forwarded
+
+	// sticker is shown as image instead of text: url of image from provider
+	// or name of sticker file sent as attachment. Empty for other messages
+	Sticker string `json:"sticker"`
+
+	// id generated by sender, server answers with /message-ack when message is saved.
+	// It isn't sent to other users
+	LocalId int64 `json:"local_id"`
+}
+
+func (msg *Message) IsSticker() bool {
+	return msg.Sticker != ""
+}
+
+func (msg *Message) IsForwarded() bool {
+	return msg.ForwardedFrom != ""
+}
+
+func (msg *Message) IsReply() bool {
+	return msg.ReplyToId != 0
 }
 
 func (msg *Message) GetChatType() string {
-	if msg.ChatId == 0 {
+	if msg.ChatId <= 0 { // main or created group
 		return "group"
 	}
 	return "private"
 }
 
+func (msg *Message) HasAttachment() bool {
+	return msg.Attachment.Id != 0
+}
+
 // message saved to db
 type SavedMessage struct {
 	Message
-	Id        int64 `json: "id"`
-	CreatedOn int64 `json:"created_on"`
+	Id        int64        `json: "id"`
+	CreatedOn int64        `json:"created_on"`
+	EditedOn  int64        `json:"edited_on"` // 0 if message wasn't edited
+	Status    string       `json:"status"`    // sent, delivered or read
+	Reactions []Reaction   `json:"reactions"`
+	ReplyTo   ReplyPreview `json:"reply_to"` // empty if replied message was deleted
+	Pinned    bool         `json:"pinned"`
+}
+
+// beginning of replied message which is shown above reply
+type ReplyPreview struct {
+	User User   `json:"user"`
+	Text string `json:"text"` // file name of attachment if message has no text
+}
+
+// metadata of page linked in message. It's loaded by client and isn't sent
+type LinkPreview struct {
+	Url         string
+	Title       string
+	Description string
+	ImagePath   string // cached thumbnail. Empty if page has no image
+}
+
+// sticker of picker. Stickers of provider are sent by url,
+// stickers of local pack are sent as files
+type Sticker struct {
+	Url  string // empty for sticker of local pack
+	Path string // file of local pack or cached image of provider
+}
+
+func (sticker *Sticker) IsLocal() bool {
+	return sticker.Url == ""
+}
+
+func (msg *SavedMessage) IsEdited() bool {
+	return msg.EditedOn != 0
+}
+
+// users who reacted to message with same emoji, in order of reactions
+type Reaction struct {
+	Emoji   string  `json:"emoji"` // shortcode of emoji
+	UserIds []int64 `json:"user_ids"`
+}
+
+// adds reaction of user to message or removes it if it was added before
+type MessageReaction struct {
+	MessageId int64  `json:"message_id"`
+	Emoji     string `json:"emoji"`
+}
+
+// pins message in chat or unpins it
+type MessagePin struct {
+	MessageId int64 `json:"message_id"`
+	Pinned    bool  `json:"pinned"`
+}
+
+// categories of reports in moderation queue of server
+const REPORT_SPAM = "spam"
+const REPORT_ABUSE = "abuse" // insults and harassment
+const REPORT_ILLEGAL = "illegal"
+const REPORT_OTHER = "other"
+
+func GetReportCategories() []string {
+	return []string{REPORT_SPAM, REPORT_ABUSE, REPORT_ILLEGAL, REPORT_OTHER}
+}
+
+// complaint about message or user. Author of message is reported user
+type Report struct {
+	MessageId int64  `json:"message_id,omitempty"` // 0 if user is reported without message
+	UserId    int64  `json:"user_id,omitempty"`
+	Category  string `json:"category"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+type MessageEditing struct {
+	Id   int64  `json:"id"`
+	Text string `json:"text"`
+}
+
+// recipient has read private chat up to message
+type MessageRead struct {
+	ChatId        int64 `json:"chat_id"`
+	LastMessageId int64 `json:"last_message_id"`
+}
+
+// new status of sender's messages in private chat up to LastMessageId
+type MessageStatusUpdate struct {
+	ChatId        int64  `json:"chat_id"`
+	LastMessageId int64  `json:"last_message_id"`
+	Status        string `json:"status"`
+}
+
+type MessageDeletion struct {
+	Id int64 `json:"id"`
+}
+
+// message waiting for connection to be sent
+type QueuedMessage struct {
+	Message
+	State string `json:"state"` // pending, sent or failed
+}
+
+// message which client sends at chosen time. It's kept in local cache only
+type ScheduledMessage struct {
+	Message
+	Id        int64  `json:"id"`
+	ChatTitle string `json:"chat_title"` // title of chat when message was scheduled
+	SendOn    int64  `json:"send_on"`    // unix time
 }
 
 type SavedMessagesPack struct {
 	Messages []SavedMessage `json:"messages"`
+	ChatId   int64          `json:"chat_id"`
+	BeforeId int64          `json:"before_id"` // 0 for latest messages
+	AroundId int64          `json:"around_id"` // not 0 if messages around it were requested
+}
+
+// user is editing message in chat
+type Typing struct {
+	User   User  `json:"user"`
+	ChatId int64 `json:"chat_id"`
+}
+
+func (typing *Typing) GetChatType() string {
+	if typing.ChatId <= 0 { // main or created group
+		return "group"
+	}
+	return "private"
 }
 
 type MessagesRequest struct {
+	ChatId   int64 `json:"chat_id"`
+	User     User  `json:"user"`
+	BeforeId int64 `json:"before_id"` // load messages older than this id
+	AroundId int64 `json:"around_id"` // load messages before and after this id
+	Limit    int   `json:"limit"`     // 0 means server default
+}
+
+type PinnedMessagesRequest struct {
 	ChatId int64 `json:"chat_id"`
-	User   User  `json:"user"`
+}
+
+// pinned messages of chat ordered by id
+type PinnedMessagesPack struct {
+	ChatId   int64          `json:"chat_id"`
+	Messages []SavedMessage `json:"messages"`
+}
+
+type MessagesSearchRequest struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit"` // 0 means server default
+}
+
+// messages available for user which contain query, newest first
+type MessagesSearchResult struct {
+	Query    string         `json:"query"`
+	Messages []SavedMessage `json:"messages"`
 }
 
 type MessagesSavingRequest struct {
 	Message   Message   `json:"message"`
 	SecretKey uuid.UUID `json:"secret_key"`
 }
+
+// sent by server instead of saving message when user sends too often.
+// Rejected message is returned, so client can restore its text
+// sent to sender after message is saved. Id and time of saved message
+// replace pending message of sender. Old servers send only local id
+type MessageAck struct {
+	LocalId   int64 `json:"local_id"`
+	ChatId    int64 `json:"chat_id"`
+	Id        int64 `json:"id"`
+	CreatedOn int64 `json:"created_on"`
+}
+
+type RateLimited struct {
+	RetryAfter int     `json:"retry_after"` // seconds
+	Message    Message `json:"message"`
+}
@@ -0,0 +1,60 @@
+// protocol.go
+package models
+
+// version 2: password is sent with hash scheme, see AuthRequest
+// version 3: errors are sent by /error event with codes, see Error
+const PROTOCOL_VERSION int = 3
+
+// optional features. Client doesn't use features which server doesn't support
+const FEATURE_EDITS = "edits" // editing and deletion of messages
+const FEATURE_ATTACHMENTS = "attachments"
+const FEATURE_SEARCH = "search"
+const FEATURE_READ_RECEIPTS = "read-receipts"
+const FEATURE_ACCOUNT = "account" // password change and account deletion
+const FEATURE_AVATARS = "avatars"
+const FEATURE_PROFILES = "profiles" // display names and status texts
+const FEATURE_BLOCKING = "blocking" // server keeps list of blocked users
+const FEATURE_REACTIONS = "reactions"
+const FEATURE_PINS = "pins"         // pinned messages of chats
+const FEATURE_CONTACTS = "contacts" // server keeps contact lists of users
+const FEATURE_USER_SEARCH = "user-search"
+const FEATURE_INVITATIONS = "invitations" // group invitations and join requests
+const FEATURE_ROLES = "roles"             // admins of groups and moderation actions
+const FEATURE_LOGOUT = "logout"           // client can close its session
+const FEATURE_FORWARDING = "forwarding"   // messages are forwarded to other chats
+const FEATURE_STICKERS = "stickers"
+const FEATURE_BUSY_PRESENCE = "busy-presence" // do not disturb mode is shown to others
+const FEATURE_MESSAGE_ACKS = "message-acks"   // saved messages are acknowledged to sender
+const FEATURE_COMPRESSION = "compression"     // long websocket messages are compressed
+const FEATURE_MSGPACK = "msgpack"             // encrypted payloads can be encoded by MessagePack
+const FEATURE_TWO_FACTOR = "2fa"              // one-time codes of authenticator apps on login
+const FEATURE_OAUTH = "oauth"                 // login by accounts of providers listed in hello
+const FEATURE_CHALLENGE = "challenge"         // registration challenge is requested before registration
+const FEATURE_USER_REPORTS = "user-reports"   // reports of messages and users have categories
+
+// sent by client after connection and answered by server
+type Hello struct {
+	Version  int      `json:"version"`
+	Features []string `json:"features"`
+
+	OAuthProviders []OAuthProvider `json:"oauth_providers,omitempty"` // sent by server only
+}
+
+func GetAllFeatures() []string {
+	return []string{FEATURE_EDITS, FEATURE_ATTACHMENTS, FEATURE_SEARCH, FEATURE_READ_RECEIPTS,
+		FEATURE_ACCOUNT, FEATURE_AVATARS, FEATURE_PROFILES, FEATURE_BLOCKING,
+		FEATURE_REACTIONS, FEATURE_PINS, FEATURE_CONTACTS,
+		FEATURE_USER_SEARCH, FEATURE_INVITATIONS, FEATURE_ROLES, FEATURE_LOGOUT,
+		FEATURE_FORWARDING, FEATURE_STICKERS, FEATURE_BUSY_PRESENCE,
+		FEATURE_MESSAGE_ACKS, FEATURE_COMPRESSION, FEATURE_MSGPACK, FEATURE_TWO_FACTOR,
+		FEATURE_OAUTH, FEATURE_CHALLENGE, FEATURE_USER_REPORTS}
+}
+
+func (hello *Hello) HasFeature(feature string) bool {
+	for _, supportedFeature := range hello.Features {
+		if supportedFeature == feature {
+			return true
+		}
+	}
+	return false
+}
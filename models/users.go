@@ -10,6 +10,91 @@ type User struct {
 	Username string `json: "username"`
 }
 
+const PRESENCE_ACTIVE = "active"
+const PRESENCE_IDLE = "idle"
+const PRESENCE_OFFLINE = "offline"
+const PRESENCE_BUSY = "busy" // do not disturb mode of user
+
+type Presence struct {
+	User  User   `json:"user"`
+	State string `json:"state"` // active, idle, busy or offline
+}
+
+// data which user shows to others. Empty fields weren't filled by user
+type Profile struct {
+	User        User   `json:"user"`
+	DisplayName string `json:"display_name"` // shown instead of username
+	StatusText  string `json:"status_text"`
+}
+
+func (profile *Profile) GetDisplayName() string {
+	if profile.DisplayName != "" {
+		return profile.DisplayName
+	}
+	return profile.User.Username
+}
+
+type ProfileRequest struct {
+	UserId int64 `json:"user_id"`
+}
+
+const AVATAR_SIZE int = 64 // width and height of avatar in pixels
+const MAX_AVATAR_DATA_SIZE int = 64 * 1024
+
+// png image of user. Data is sent only if it differs from cached one
+type Avatar struct {
+	User User   `json:"user"`
+	Hash string `json:"hash"` // empty if user has no avatar
+	Data []byte `json:"data"`
+}
+
+// avatar of current user resized by client to AVATAR_SIZE
+type AvatarUpload struct {
+	Data []byte `json:"data"`
+}
+
+type AvatarRequest struct {
+	UserId int64  `json:"user_id"`
+	Hash   string `json:"hash"` // hash of cached avatar or empty string
+}
+
+// settings of current user about messages of other user
+type UserFilter struct {
+	User    User `json:"user"`
+	Blocked bool `json:"blocked"` // messages of user aren't shown
+	Muted   bool `json:"muted"`   // messages of user don't cause notifications
+}
+
+// users blocked by current user which are saved by server
+type BlockedUsersPack struct {
+	Users []User `json:"users"`
+}
+
+// adds user to contacts of current user or removes him from them
+type ContactChange struct {
+	User User `json:"user"`
+}
+
+type ContactsRequest struct {
+	User User `json:"user"`
+}
+
+// contacts of current user sorted by username
+type ContactsPack struct {
+	Contacts []User `json:"contacts"`
+}
+
+type UsersSearchRequest struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit"` // 0 means server default
+}
+
+// users whose username or display name contains query, sorted by username
+type UsersSearchResult struct {
+	Query string `json:"query"`
+	Users []User `json:"users"`
+}
+
 type ConnectedUser struct {
 	Id        int64     `json: "id"`
 	Username  string    `json: "username"`
@@ -0,0 +1,110 @@
+// avatars.go
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"chat/models"
+	"chat/utils"
+)
+
+func EncodeAvatar(reader io.Reader) ([]byte, error) {
+	// crops image to square at its center, scales it to AVATAR_SIZE
+	// and encodes as png which is accepted by server
+	src, _, err := image.Decode(reader)
+	if utils.IsError(err) {
+		return nil, errors.New("Image format is not supported: " + err.Error())
+	}
+	bounds := src.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	if side == 0 {
+		return nil, errors.New("Image is empty")
+	}
+	left := bounds.Min.X + (bounds.Dx()-side)/2
+	top := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	size := models.AVATAR_SIZE
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			// pixel is average of source pixels which it covers
+			x0, x1 := left+x*side/size, left+(x+1)*side/size
+			y0, y1 := top+y*side/size, top+(y+1)*side/size
+			if x1 == x0 { // image is smaller than avatar
+				x1++
+			}
+			if y1 == y0 {
+				y1++
+			}
+			var r, g, b, a, count uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+pr, g+pg, b+pb, a+pa
+					count++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / count), uint16(g / count),
+				uint16(b / count), uint16(a / count)})
+		}
+	}
+
+	var buffer bytes.Buffer
+	err = png.Encode(&buffer, dst)
+	if utils.IsError(err) {
+		return nil, err
+	}
+	if buffer.Len() > models.MAX_AVATAR_DATA_SIZE {
+		return nil, errors.New("Avatar is too big")
+	}
+	return buffer.Bytes(), nil
+}
+
+func (cache *ImagesCache) GetAvatarPath(userId int64, hash string) string {
+	return filepath.Join(cache.Dir, fmt.Sprintf("avatar_%d_%s.png", userId, hash))
+}
+
+func (cache *ImagesCache) FindAvatar(userId int64) (string, string) {
+	// returns path and hash of cached avatar of user
+	// or empty strings if it isn't cached
+	pattern := cache.GetAvatarPath(userId, "*")
+	paths, err := filepath.Glob(pattern)
+	if utils.IsError(err) || len(paths) == 0 {
+		return "", ""
+	}
+	prefix := strings.TrimSuffix(filepath.Base(pattern), "*.png")
+	hash := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(paths[0]), prefix), ".png")
+	return paths[0], hash
+}
+
+func (cache *ImagesCache) SaveAvatar(avatar models.Avatar) (string, error) {
+	// replaces cached avatar of user and returns path of new one
+	if _, err := hex.DecodeString(avatar.Hash); utils.IsError(err) || avatar.Hash == "" {
+		return "", errors.New("Wrong hash of avatar: " + avatar.Hash)
+	}
+	if oldPath, _ := cache.FindAvatar(avatar.User.Id); oldPath != "" {
+		os.Remove(oldPath)
+	}
+	path := cache.GetAvatarPath(avatar.User.Id, avatar.Hash)
+	file, err := cache.CreateFile(path)
+	if utils.IsError(err) {
+		return "", err
+	}
+	_, err = file.Write(avatar.Data)
+	return path, file.Finish(err)
+}
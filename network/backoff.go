@@ -0,0 +1,44 @@
+// backoff.go
+package network
+
+import (
+	"math/rand"
+	"time"
+)
+
+// delays between reconnection attempts which grow twice
+// after each attempt up to Max
+type Backoff struct {
+	Min     time.Duration
+	Max     time.Duration
+	attempt int
+	random  *rand.Rand // seeded for each client in order to get different jitter
+}
+
+func NewBackoff(min time.Duration, max time.Duration) *Backoff {
+	return &Backoff{Min: min, Max: max,
+		random: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (b *Backoff) Next() time.Duration {
+	// returns delay before next attempt. Random jitter up to half
+	// of delay prevents clients from reconnecting at the same time
+	delay := b.Min
+	for i := 0; i < b.attempt && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	b.attempt++
+	jitter := time.Duration(b.random.Int63n(int64(delay/2) + 1))
+	return delay/2 + jitter
+}
+
+func (b *Backoff) Attempt() int {
+	return b.attempt
+}
+
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,75 @@
+// compression.go
+package network
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/graarh/golang-socketio/transport"
+
+	"chat/utils"
+)
+
+const COMPRESSION_THRESHOLD = 1024 // bytes. Shorter messages aren't worth compressing
+// set to request header of connection, so channel of socket.io can find it
+const CONNECTION_ID_HEADER = "X-Chat-Connection-Id"
+
+// websocket transport of server which negotiates permessage-deflate.
+// Messages to client are compressed after its /hello tells that it
+// supports compression
+type ServerWebsocketTransport struct {
+	transport.WebsocketTransport
+
+	mutex       sync.Mutex
+	lastId      int64
+	connections map[string]*websocketConnection // map: connection id -> connection
+}
+
+func GetServerWebsocketTransport() *ServerWebsocketTransport {
+	return &ServerWebsocketTransport{
+		WebsocketTransport: *transport.GetDefaultWebsocketTransport(),
+		connections:        make(map[string]*websocketConnection)}
+}
+
+func (wst *ServerWebsocketTransport) HandleConnection(
+	w http.ResponseWriter, r *http.Request) (transport.Connection, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Upgrade failed: "+transport.ErrorMethodNotAllowed.Error(), 503)
+		return nil, transport.ErrorMethodNotAllowed
+	}
+	upgrader := websocket.Upgrader{ReadBufferSize: wst.BufferSize,
+		WriteBufferSize: wst.BufferSize, EnableCompression: true,
+		CheckOrigin: func(r *http.Request) bool { return true }}
+	socket, err := upgrader.Upgrade(w, r, nil)
+	if utils.IsError(err) {
+		return nil, transport.ErrorHttpUpgradeFailed
+	}
+
+	connection := &websocketConnection{socket: socket, transport: &wst.WebsocketTransport}
+	wst.mutex.Lock()
+	wst.lastId++
+	id := strconv.FormatInt(wst.lastId, 10)
+	wst.connections[id] = connection
+	wst.mutex.Unlock()
+	connection.onClose = func() {
+		wst.mutex.Lock()
+		delete(wst.connections, id)
+		wst.mutex.Unlock()
+	}
+	// header is passed to channel after connection is handled.
+	// Value sent by client is replaced
+	r.Header.Set(CONNECTION_ID_HEADER, id)
+	return connection, nil
+}
+
+func (wst *ServerWebsocketTransport) SetCompression(requestHeader http.Header, enabled bool) {
+	// enables compression of connection with header from Channel.RequestHeader
+	wst.mutex.Lock()
+	connection, ok := wst.connections[requestHeader.Get(CONNECTION_ID_HEADER)]
+	wst.mutex.Unlock()
+	if ok {
+		connection.SetCompression(enabled)
+	}
+}
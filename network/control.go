@@ -0,0 +1,81 @@
+// control.go
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"chat/logger"
+	"chat/utils"
+)
+
+// running gui client listens on this local port, so other programs
+// of user (notifier) know that it's running and can ask it to open chat
+const CONTROL_ADDRESS = "127.0.0.1:3812"
+const CONTROL_TIMEOUT = time.Second
+const CONTROL_COMMAND_OPEN = "open"
+
+func ListenControl(onOpenChat func(chatId int64)) (net.Listener, error) {
+	// accepts commands in background until listener is closed.
+	// Fails if another client is already listening
+	listener, err := net.Listen("tcp", CONTROL_ADDRESS)
+	if utils.IsError(err) {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if utils.IsError(err) {
+				return
+			}
+			go readControlCommand(conn, onOpenChat)
+		}
+	}()
+	return listener, nil
+}
+
+func readControlCommand(conn net.Conn, onOpenChat func(chatId int64)) {
+	// reads single line "open <chat id>"
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(CONTROL_TIMEOUT))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if utils.IsError(err) {
+		return
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 2 || fields[0] != CONTROL_COMMAND_OPEN {
+		logger.Warning("Unknown control command: " + strings.TrimSpace(line))
+		return
+	}
+	chatId, err := strconv.ParseInt(fields[1], 10, 64)
+	if utils.IsError(err) {
+		logger.Warning("Wrong chat id in control command: " + fields[1])
+		return
+	}
+	onOpenChat(chatId)
+}
+
+func IsClientRunning() bool {
+	conn, err := net.DialTimeout("tcp", CONTROL_ADDRESS, CONTROL_TIMEOUT)
+	if utils.IsError(err) {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func OpenChatInClient(chatId int64) error {
+	// asks running gui client to show chat
+	conn, err := net.DialTimeout("tcp", CONTROL_ADDRESS, CONTROL_TIMEOUT)
+	if utils.IsError(err) {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(CONTROL_TIMEOUT))
+	_, err = fmt.Fprintf(conn, "%s %d\n", CONTROL_COMMAND_OPEN, chatId)
+	return err
+}
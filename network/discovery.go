@@ -0,0 +1,38 @@
+// discovery.go
+package network
+
+import (
+	"net"
+	"strings"
+
+	"chat/logger"
+	"chat/utils"
+)
+
+const SRV_SERVICE = "chat"
+const SRV_PROTO = "tcp"
+
+func ResolveHost(hostData utils.HostData) utils.HostData {
+	// empty port is found in _chat._tcp SRV record of domain.
+	// Default port is used if record is missing
+	if hostData.Port != 0 {
+		return hostData
+	}
+	hostData.Port = utils.DEFAULT_PORT
+	if net.ParseIP(hostData.Host) != nil {
+		return hostData
+	}
+	_, records, err := net.LookupSRV(SRV_SERVICE, SRV_PROTO, hostData.Host)
+	if utils.IsError(err) || len(records) == 0 {
+		logger.Debugf("No SRV record of %s, default port is used", hostData.Host)
+		return hostData
+	}
+	// records are sorted by priority and weight
+	record := records[0]
+	if target := strings.TrimSuffix(record.Target, "."); target != "" {
+		hostData.Host = target
+	}
+	hostData.Port = int(record.Port)
+	logger.Infof("SRV record of domain points to %s:%d", hostData.Host, hostData.Port)
+	return hostData
+}
@@ -0,0 +1,179 @@
+// images_cache.go
+package network
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chat/models"
+	"chat/utils"
+)
+
+const MAX_IMAGE_PREVIEW_SIZE int64 = 5 * 1024 * 1024  // 5 MB
+const MAX_IMAGES_CACHE_SIZE int64 = 100 * 1024 * 1024 // 100 MB
+const IMAGE_DOWNLOAD_TIMEOUT = 30 * time.Second
+
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".bmp"}
+var imageUrlRegexp = regexp.MustCompile(`(?i)https?://\S+\.(png|jpe?g|gif|bmp)(\?\S*)?`)
+
+// local directory with downloaded images for previews
+type ImagesCache struct {
+	Dir    string
+	client *http.Client
+
+	mutex    sync.Mutex
+	previews map[string]models.LinkPreview // loaded link previews by url
+}
+
+func NewImagesCache(dir string) *ImagesCache {
+	// creates cache directory and removes old files if cache is too big
+	cache := &ImagesCache{
+		Dir:      dir,
+		client:   &http.Client{Timeout: IMAGE_DOWNLOAD_TIMEOUT},
+		previews: make(map[string]models.LinkPreview)}
+	err := os.MkdirAll(dir, 0755)
+	if utils.IsError(err) {
+		panic(err)
+	}
+	cache.removeOldFiles()
+	return cache
+}
+
+func (cache *ImagesCache) SetProxy(proxyUrl *url.URL) {
+	// images of links are downloaded through proxy of chat server
+	if proxyUrl == nil {
+		cache.client.Transport = nil
+		return
+	}
+	cache.client.Transport = &http.Transport{Proxy: http.ProxyURL(proxyUrl)}
+}
+
+func IsImageFileName(fileName string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, imageExt := range imageExtensions {
+		if ext == imageExt {
+			return true
+		}
+	}
+	return false
+}
+
+func FindImageUrl(text string) string {
+	// returns first image url in text or empty string
+	return imageUrlRegexp.FindString(text)
+}
+
+func (cache *ImagesCache) GetAttachmentPath(attachment models.Attachment) string {
+	fileName := fmt.Sprintf("attachment_%d%s", attachment.Id,
+		strings.ToLower(filepath.Ext(attachment.FileName)))
+	return filepath.Join(cache.Dir, fileName)
+}
+
+func (cache *ImagesCache) GetUrlPath(url string) string {
+	hash := sha1.Sum([]byte(url))
+	ext := strings.ToLower(filepath.Ext(strings.SplitN(url, "?", 2)[0]))
+	return filepath.Join(cache.Dir, hex.EncodeToString(hash[:])+ext)
+}
+
+func (cache *ImagesCache) IsCached(path string) bool {
+	_, err := os.Stat(path)
+	return !utils.IsError(err)
+}
+
+func (cache *ImagesCache) CreateFile(path string) (*CachedFile, error) {
+	// returns file which appears in cache only after successful Finish
+	file, err := os.Create(path + ".part")
+	if utils.IsError(err) {
+		return nil, err
+	}
+	return &CachedFile{File: file, path: path}, nil
+}
+
+func (cache *ImagesCache) DownloadUrl(ctx context.Context, url string) (string, error) {
+	// saves image by url to cache and returns its path.
+	// Images bigger than MAX_IMAGE_PREVIEW_SIZE are not saved.
+	// Download is stopped when ctx is canceled
+	path := cache.GetUrlPath(url)
+	if cache.IsCached(path) {
+		return path, nil
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if utils.IsError(err) {
+		return "", err
+	}
+	response, err := cache.client.Do(request)
+	if utils.IsError(err) {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", errors.New("Can't download " + url + ": " + response.Status)
+	}
+	if response.ContentLength > MAX_IMAGE_PREVIEW_SIZE {
+		return "", errors.New("Image " + url + " is too big for preview")
+	}
+
+	file, err := cache.CreateFile(path)
+	if utils.IsError(err) {
+		return "", err
+	}
+	size, err := io.Copy(file, io.LimitReader(response.Body, MAX_IMAGE_PREVIEW_SIZE+1))
+	if !utils.IsError(err) && size > MAX_IMAGE_PREVIEW_SIZE {
+		err = errors.New("Image " + url + " is too big for preview")
+	}
+	return path, file.Finish(err)
+}
+
+func (cache *ImagesCache) removeOldFiles() {
+	// removes least recently modified files until cache fits MAX_IMAGES_CACHE_SIZE
+	files, err := ioutil.ReadDir(cache.Dir)
+	if utils.IsError(err) {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+	var totalSize int64 = 0
+	for _, file := range files {
+		totalSize += file.Size()
+		if totalSize > MAX_IMAGES_CACHE_SIZE || strings.HasSuffix(file.Name(), ".part") {
+			os.Remove(filepath.Join(cache.Dir, file.Name()))
+		}
+	}
+}
+
+// file which is being downloaded to cache
+type CachedFile struct {
+	*os.File
+	path string
+}
+
+func (file *CachedFile) Finish(downloadErr error) error {
+	// moves downloaded file to its path. If download failed file is removed
+	partPath := file.File.Name()
+	err := file.File.Close()
+	if !utils.IsError(downloadErr) && !utils.IsError(err) {
+		err = os.Rename(partPath, file.path)
+	}
+	if utils.IsError(downloadErr) {
+		err = downloadErr
+	}
+	if utils.IsError(err) {
+		os.Remove(partPath)
+	}
+	return err
+}
@@ -0,0 +1,180 @@
+// irc.go
+package network
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"chat/logger"
+	"chat/utils"
+)
+
+const IRC_DIAL_TIMEOUT = 15 * time.Second
+const IRC_MAX_TEXT_LENGTH int = 400 // bytes of text in line, rest of 512 is left for prefix
+const IRC_ERR_NICKNAMEINUSE = "433"
+const IRC_RPL_WELCOME = "001"
+
+// line of IRC protocol: ":prefix COMMAND param param :last param"
+type IrcMessage struct {
+	Prefix  string // nick!user@host of sender, empty for lines of server
+	Command string
+	Params  []string // last param can contain spaces
+}
+
+func (msg IrcMessage) GetNick() string {
+	return strings.SplitN(msg.Prefix, "!", 2)[0]
+}
+
+func (msg IrcMessage) GetParam(i int) string {
+	if i < len(msg.Params) {
+		return msg.Params[i]
+	}
+	return ""
+}
+
+func ParseIrcLine(line string) IrcMessage {
+	var msg IrcMessage
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, ":") {
+		fields := strings.SplitN(line[1:], " ", 2)
+		msg.Prefix, line = fields[0], ""
+		if len(fields) == 2 {
+			line = fields[1]
+		}
+	}
+	for line != "" {
+		if strings.HasPrefix(line, ":") {
+			msg.Params = append(msg.Params, line[1:])
+			break
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if msg.Command == "" {
+			msg.Command = strings.ToUpper(fields[0])
+		} else if fields[0] != "" {
+			msg.Params = append(msg.Params, fields[0])
+		}
+		line = ""
+		if len(fields) == 2 {
+			line = fields[1]
+		}
+	}
+	return msg
+}
+
+func FormatIrcLine(command string, params ...string) string {
+	// last param is written after colon, so it can contain spaces
+	line := command
+	for i, param := range params {
+		param = strings.NewReplacer("\r", " ", "\n", " ").Replace(param)
+		if i == len(params)-1 && (param == "" || strings.ContainsAny(param, " :")) {
+			param = ":" + param
+		}
+		line += " " + param
+	}
+	return line
+}
+
+func splitIrcText(text string) []string {
+	// returns lines of text which fit in IRC line, empty lines are skipped
+	var parts []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		for len(line) > IRC_MAX_TEXT_LENGTH {
+			end := IRC_MAX_TEXT_LENGTH
+			for end > 0 && !utf8.RuneStart(line[end]) {
+				end--
+			}
+			parts = append(parts, line[:end])
+			line = line[end:]
+		}
+		if strings.TrimSpace(line) != "" {
+			parts = append(parts, line)
+		}
+	}
+	return parts
+}
+
+// connection to IRC server. Pings of server are answered by connection
+type IrcConnection struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mutex  sync.Mutex // lines of goroutines aren't mixed
+	Nick   string     // changed if nick is taken
+}
+
+func DialIrc(address string, secure bool) (*IrcConnection, error) {
+	dialer := &net.Dialer{Timeout: IRC_DIAL_TIMEOUT}
+	var conn net.Conn
+	var err error
+	if secure {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, nil)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if utils.IsError(err) {
+		return nil, err
+	}
+	return &IrcConnection{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func (irc *IrcConnection) Send(command string, params ...string) error {
+	irc.mutex.Lock()
+	defer irc.mutex.Unlock()
+	logger.Debug("irc -> " + command)
+	_, err := irc.conn.Write([]byte(FormatIrcLine(command, params...) + "\r\n"))
+	return err
+}
+
+func (irc *IrcConnection) Register(nick string, realName string) error {
+	// server answers by welcome line after registration
+	irc.Nick = nick
+	if err := irc.Send("NICK", nick); utils.IsError(err) {
+		return err
+	}
+	return irc.Send("USER", nick, "0", "*", realName)
+}
+
+func (irc *IrcConnection) SendMessage(target string, text string) error {
+	// long and multi-line texts are sent by several lines
+	for _, line := range splitIrcText(text) {
+		if err := irc.Send("PRIVMSG", target, line); utils.IsError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (irc *IrcConnection) ReadMessages(onMessage func(msg IrcMessage)) error {
+	// reads lines until connection is closed. Taken nick is changed
+	// before registration finishes
+	for {
+		line, err := irc.reader.ReadString('\n')
+		if utils.IsError(err) {
+			return err
+		}
+		msg := ParseIrcLine(line)
+		logger.Debug("irc <- " + msg.Command)
+		switch msg.Command {
+		case "PING":
+			irc.Send("PONG", msg.Params...)
+		case "ERROR":
+			return errors.New("IRC server closed connection: " + msg.GetParam(0))
+		case IRC_ERR_NICKNAMEINUSE:
+			irc.Nick += "_"
+			irc.Send("NICK", irc.Nick)
+		default:
+			onMessage(msg)
+		}
+	}
+}
+
+func (irc *IrcConnection) Close() {
+	irc.Send("QUIT")
+	irc.conn.Close()
+}
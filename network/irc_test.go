@@ -0,0 +1,80 @@
+// irc_test.go
+package network
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseIrcLine(t *testing.T) {
+	tests := []struct {
+		line string
+		msg  IrcMessage
+	}{
+		{"PING :irc.example.org\r\n", IrcMessage{Command: "PING",
+			Params: []string{"irc.example.org"}}},
+		{":nick!user@host PRIVMSG #chat :hello: world\r\n", IrcMessage{Prefix: "nick!user@host",
+			Command: "PRIVMSG", Params: []string{"#chat", "hello: world"}}},
+		{":irc.example.org 001 bot :Welcome", IrcMessage{Prefix: "irc.example.org",
+			Command: "001", Params: []string{"bot", "Welcome"}}},
+		{"join  #a   #b", IrcMessage{Command: "JOIN", Params: []string{"#a", "#b"}}},
+		{"PRIVMSG #chat :", IrcMessage{Command: "PRIVMSG", Params: []string{"#chat", ""}}},
+		{":server", IrcMessage{Prefix: "server"}},
+		{"", IrcMessage{}},
+	}
+	for _, test := range tests {
+		if msg := ParseIrcLine(test.line); !reflect.DeepEqual(msg, test.msg) {
+			t.Errorf("ParseIrcLine(%q) = %+v, want %+v", test.line, msg, test.msg)
+		}
+	}
+	msg := ParseIrcLine(":nick!user@host QUIT")
+	if msg.GetNick() != "nick" || msg.GetParam(0) != "" {
+		t.Errorf("nick = %q, param = %q", msg.GetNick(), msg.GetParam(0))
+	}
+}
+
+func TestFormatIrcLine(t *testing.T) {
+	tests := []struct {
+		command string
+		params  []string
+		line    string
+	}{
+		{"NICK", []string{"bot"}, "NICK bot"},
+		{"PRIVMSG", []string{"#chat", "hello world"}, "PRIVMSG #chat :hello world"},
+		{"PRIVMSG", []string{"#chat", ":)"}, "PRIVMSG #chat ::)"},
+		{"PRIVMSG", []string{"#chat", "a\r\nQUIT"}, "PRIVMSG #chat :a  QUIT"},
+		{"PONG", []string{""}, "PONG :"},
+		{"QUIT", nil, "QUIT"},
+	}
+	for _, test := range tests {
+		line := FormatIrcLine(test.command, test.params...)
+		if line != test.line {
+			t.Errorf("FormatIrcLine(%q, %q) = %q, want %q", test.command, test.params, line,
+				test.line)
+		}
+		if parsed := ParseIrcLine(line); len(test.params) > 0 &&
+			parsed.GetParam(len(test.params)-1) != strings.NewReplacer("\r", " ", "\n", " ").
+				Replace(test.params[len(test.params)-1]) {
+			t.Errorf("last param of %q isn't parsed back", line)
+		}
+	}
+}
+
+func TestSplitIrcText(t *testing.T) {
+	parts := splitIrcText("first\r\n\n  \nsecond")
+	if !reflect.DeepEqual(parts, []string{"first", "second"}) {
+		t.Errorf("parts = %q", parts)
+	}
+	long := strings.Repeat("я", IRC_MAX_TEXT_LENGTH) // 2 bytes each
+	parts = splitIrcText(long)
+	if strings.Join(parts, "") != long {
+		t.Error("parts of long line don't make it")
+	}
+	for _, part := range parts {
+		if len(part) > IRC_MAX_TEXT_LENGTH || !utf8.ValidString(part) {
+			t.Errorf("part of %d bytes is too long or splits character", len(part))
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// link_preview.go
+package network
+
+import (
+	"context"
+	"errors"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"chat/models"
+	"chat/utils"
+)
+
+const MAX_LINK_PAGE_SIZE int64 = 512 * 1024 // meta tags are searched in beginning of page
+
+var linkUrlRegexp = regexp.MustCompile(`https?://\S+[^\s.,!?;:)\]'"]`)
+var metaTagRegexp = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+var metaAttributeRegexp = regexp.MustCompile(`(?is)(property|name|content)\s*=\s*("[^"]*"|'[^']*')`)
+var titleTagRegexp = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+func FindLinkUrl(text string) string {
+	// returns first url of text which isn't image. Images have their own previews
+	for _, link := range linkUrlRegexp.FindAllString(text, -1) {
+		if FindImageUrl(link) != link {
+			return link
+		}
+	}
+	return ""
+}
+
+func parseMetaTags(page string) map[string]string {
+	// returns content of meta tags by property or name, first tag wins
+	result := make(map[string]string)
+	for _, tag := range metaTagRegexp.FindAllString(page, -1) {
+		attributes := make(map[string]string)
+		for _, match := range metaAttributeRegexp.FindAllStringSubmatch(tag, -1) {
+			value := match[2][1 : len(match[2])-1] // without quotes
+			attributes[strings.ToLower(match[1])] = html.UnescapeString(value)
+		}
+		key := attributes["property"]
+		if key == "" {
+			key = attributes["name"]
+		}
+		key = strings.ToLower(key)
+		if _, ok := result[key]; key != "" && !ok {
+			result[key] = strings.TrimSpace(attributes["content"])
+		}
+	}
+	return result
+}
+
+func (cache *ImagesCache) LoadLinkPreview(ctx context.Context,
+	link string) (models.LinkPreview, error) {
+	// reads Open Graph tags of page. Thumbnail is downloaded to cache.
+	// Loaded previews are kept in memory until restart
+	cache.mutex.Lock()
+	preview, ok := cache.previews[link]
+	cache.mutex.Unlock()
+	if ok {
+		return preview, nil
+	}
+	preview = models.LinkPreview{Url: link}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if utils.IsError(err) {
+		return preview, err
+	}
+	response, err := cache.client.Do(request)
+	if utils.IsError(err) {
+		return preview, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return preview, errors.New("Can't load " + link + ": " + response.Status)
+	}
+	if !strings.Contains(response.Header.Get("Content-Type"), "html") {
+		return preview, errors.New("Page " + link + " isn't html")
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(response.Body, MAX_LINK_PAGE_SIZE))
+	if utils.IsError(err) {
+		return preview, err
+	}
+	page := string(data)
+	tags := parseMetaTags(page)
+	preview.Title = tags["og:title"]
+	if preview.Title == "" {
+		if match := titleTagRegexp.FindStringSubmatch(page); match != nil {
+			preview.Title = strings.TrimSpace(html.UnescapeString(match[1]))
+		}
+	}
+	preview.Description = tags["og:description"]
+	if preview.Description == "" {
+		preview.Description = tags["description"]
+	}
+	if imageUrl, err := response.Request.URL.Parse(tags["og:image"]); tags["og:image"] != "" &&
+		!utils.IsError(err) && (imageUrl.Scheme == "http" || imageUrl.Scheme == "https") {
+		// thumbnail isn't required for preview
+		preview.ImagePath, _ = cache.DownloadUrl(ctx, imageUrl.String())
+	}
+	cache.mutex.Lock()
+	cache.previews[link] = preview
+	cache.mutex.Unlock()
+	return preview, nil
+}
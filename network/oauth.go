@@ -0,0 +1,200 @@
+// oauth.go
+package network
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"chat/models"
+	"chat/utils"
+)
+
+// client receives authorization code by redirect of browser to loopback
+// port (RFC 8252), server exchanges it for identity of user
+const OAUTH_CALLBACK_PATH = "/callback"
+const OAUTH_LOGIN_TIMEOUT = 5 * time.Minute // user logs in at provider meanwhile
+const OAUTH_REQUEST_TIMEOUT = 10 * time.Second
+const MAX_OAUTH_RESPONSE_SIZE int64 = 64 * 1024
+const OAUTH_FINISHED_PAGE = "<html><body>Login is finished, you can return to chat.</body></html>"
+
+// user of provider returned by userinfo endpoint
+type OAuthIdentity struct {
+	Subject           string `json:"sub"` // id of user, it doesn't change
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email"`
+	Name              string `json:"name"`
+}
+
+// loopback listener which waits for redirect after login at provider
+type OAuthCallback struct {
+	RedirectUri string
+	State       string // random value which protects from codes of other flows
+	Verifier    string // code verifier of PKCE, its hash is sent in auth url
+	listener    net.Listener
+	codes       chan oauthResult
+}
+
+type oauthResult struct {
+	Code string
+	Err  error
+}
+
+func getRandomString() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func StartOAuthCallback() (*OAuthCallback, error) {
+	// listens on free port of loopback until code is received or flow is closed
+	state, err := getRandomString()
+	if utils.IsError(err) {
+		return nil, err
+	}
+	verifier, err := getRandomString()
+	if utils.IsError(err) {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if utils.IsError(err) {
+		return nil, err
+	}
+	callback := &OAuthCallback{
+		RedirectUri: "http://" + listener.Addr().String() + OAUTH_CALLBACK_PATH,
+		State:       state,
+		Verifier:    verifier,
+		listener:    listener,
+		codes:       make(chan oauthResult, 1)}
+	mux := http.NewServeMux()
+	mux.HandleFunc(OAUTH_CALLBACK_PATH, callback.processRedirect)
+	go http.Serve(listener, mux)
+	return callback, nil
+}
+
+func (callback *OAuthCallback) processRedirect(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("state") != callback.State {
+		http.Error(w, "Unknown login flow", http.StatusBadRequest)
+		return
+	}
+	result := oauthResult{Code: query.Get("code")}
+	if query.Get("error") != "" {
+		result.Err = errors.New("Provider refused login: " + query.Get("error"))
+	} else if result.Code == "" {
+		result.Err = errors.New("Provider didn't return code.")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, OAUTH_FINISHED_PAGE)
+	select {
+	case callback.codes <- result:
+	default: // only first redirect is used
+	}
+}
+
+func (callback *OAuthCallback) GetAuthUrl(provider models.OAuthProvider) string {
+	// page of provider opened in browser
+	challenge := sha256.Sum256([]byte(callback.Verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.ClientId},
+		"redirect_uri":          {callback.RedirectUri},
+		"scope":                 {provider.Scope},
+		"state":                 {callback.State},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"}}
+	separator := "?"
+	if strings.Contains(provider.AuthUrl, "?") {
+		separator = "&"
+	}
+	return provider.AuthUrl + separator + params.Encode()
+}
+
+func (callback *OAuthCallback) Wait(timeout time.Duration) (string, error) {
+	// returns authorization code. Listener is closed after it
+	defer callback.Close()
+	select {
+	case result := <-callback.codes:
+		return result.Code, result.Err
+	case <-time.After(timeout):
+		return "", errors.New("Login at provider has timed out.")
+	}
+}
+
+func (callback *OAuthCallback) Close() {
+	// cancels waiting flow
+	callback.listener.Close()
+	select {
+	case callback.codes <- oauthResult{Err: errors.New("Login was cancelled.")}:
+	default:
+	}
+}
+
+func ExchangeOAuthCode(provider utils.OAuthProviderSettings,
+	login models.OAuthLogin) (OAuthIdentity, error) {
+	// gets access token for code, then user by it. Used by server
+	identity := OAuthIdentity{}
+	client := &http.Client{Timeout: OAUTH_REQUEST_TIMEOUT}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {login.Code},
+		"redirect_uri":  {login.RedirectUri},
+		"client_id":     {provider.ClientId},
+		"code_verifier": {login.CodeVerifier}}
+	if provider.ClientSecret != "" {
+		form.Set("client_secret", provider.ClientSecret)
+	}
+	request, err := http.NewRequest("POST", provider.TokenUrl, strings.NewReader(form.Encode()))
+	if utils.IsError(err) {
+		return identity, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err = requestOAuthJson(client, request, &token); utils.IsError(err) {
+		return identity, err
+	}
+	if token.AccessToken == "" {
+		return identity, errors.New("Provider didn't return access token.")
+	}
+
+	request, err = http.NewRequest("GET", provider.UserInfoUrl, nil)
+	if utils.IsError(err) {
+		return identity, err
+	}
+	request.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	request.Header.Set("Accept", "application/json")
+	if err = requestOAuthJson(client, request, &identity); utils.IsError(err) {
+		return identity, err
+	}
+	if identity.Subject == "" {
+		return identity, errors.New("Provider didn't return id of user.")
+	}
+	return identity, nil
+}
+
+func requestOAuthJson(client *http.Client, request *http.Request, result interface{}) error {
+	response, err := client.Do(request)
+	if utils.IsError(err) {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Provider answered %s to %s", response.Status, request.URL.Path)
+	}
+	decoder := json.NewDecoder(io.LimitReader(response.Body, MAX_OAUTH_RESPONSE_SIZE))
+	return decoder.Decode(result)
+}
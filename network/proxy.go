@@ -0,0 +1,26 @@
+// proxy.go
+package network
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+
+	"chat/utils"
+)
+
+func GetProxyUrl(proxy utils.ProxySettings) (*url.URL, error) {
+	// returns nil for direct connection. Credentials are passed in url
+	if proxy.Type == "" {
+		return nil, nil
+	}
+	if err := proxy.Validate(); utils.IsError(err) {
+		return nil, err
+	}
+	proxyUrl := &url.URL{Scheme: proxy.Type,
+		Host: net.JoinHostPort(proxy.Host, strconv.Itoa(proxy.Port))}
+	if proxy.Username != "" {
+		proxyUrl.User = url.UserPassword(proxy.Username, proxy.Password)
+	}
+	return proxyUrl, nil
+}
@@ -0,0 +1,75 @@
+// stickers.go
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"chat/models"
+	"chat/utils"
+)
+
+const MAX_PROVIDER_STICKERS int = 30
+const MAX_PROVIDER_RESPONSE_SIZE int64 = 256 * 1024
+const STICKER_QUERY_PLACEHOLDER = "%s" // replaced by search query in url of provider
+
+func ListStickerPack(dir string, query string) ([]models.Sticker, error) {
+	// returns images of local pack whose file names contain query
+	var stickers []models.Sticker
+	files, err := ioutil.ReadDir(dir)
+	if utils.IsError(err) {
+		return stickers, err
+	}
+	query = strings.ToLower(query)
+	for _, file := range files {
+		path := filepath.Join(dir, file.Name())
+		if file.IsDir() || !strings.Contains(strings.ToLower(file.Name()), query) ||
+			utils.IsError(utils.ValidateStickerFile(file.Name(), file.Size())) {
+			continue
+		}
+		stickers = append(stickers, models.Sticker{Path: path})
+	}
+	return stickers, nil
+}
+
+func (cache *ImagesCache) SearchStickers(providerUrl string,
+	query string) ([]models.Sticker, error) {
+	// provider answers with json array of image urls. Query is inserted
+	// in place of %s, provider without placeholder returns same stickers
+	var stickers []models.Sticker
+	link := strings.Replace(providerUrl, STICKER_QUERY_PLACEHOLDER, url.QueryEscape(query), 1)
+	response, err := cache.client.Get(link)
+	if utils.IsError(err) {
+		return stickers, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return stickers, errors.New("Can't load stickers: " + response.Status)
+	}
+	var urls []string
+	decoder := json.NewDecoder(io.LimitReader(response.Body, MAX_PROVIDER_RESPONSE_SIZE))
+	if err := decoder.Decode(&urls); utils.IsError(err) {
+		return stickers, errors.New("Provider of stickers must return json array of urls")
+	}
+	for _, stickerUrl := range urls {
+		if len(stickers) == MAX_PROVIDER_STICKERS {
+			break
+		}
+		if utils.IsError(utils.ValidateStickerUrl(stickerUrl)) {
+			continue
+		}
+		path, err := cache.DownloadUrl(context.Background(), stickerUrl)
+		if utils.IsError(err) {
+			continue
+		}
+		stickers = append(stickers, models.Sticker{Url: stickerUrl, Path: path})
+	}
+	return stickers, nil
+}
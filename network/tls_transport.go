@@ -0,0 +1,139 @@
+// tls_transport.go
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graarh/golang-socketio/transport"
+
+	"chat/utils"
+)
+
+// websocket transport for wss:// connections with custom root certificates.
+// Default transport of socket.io can't connect through proxy or compress
+// messages, so this one is used for ws:// connections too
+type TlsWebsocketTransport struct {
+	transport.WebsocketTransport
+	TlsConfig  *tls.Config // nil for ws:// connections
+	ProxyUrl   *url.URL    // nil for direct connection
+	connection *websocketConnection
+}
+
+func GetTlsWebsocketTransport(caCertFile string) (*TlsWebsocketTransport, error) {
+	// returns transport with default params. Certificate from
+	// caCertFile is trusted in addition to system certificates
+	rootCAs, err := x509.SystemCertPool()
+	if utils.IsError(err) {
+		rootCAs = x509.NewCertPool()
+	}
+	if caCertFile != "" {
+		pemData, err := ioutil.ReadFile(caCertFile)
+		if utils.IsError(err) {
+			return nil, err
+		}
+		if !rootCAs.AppendCertsFromPEM(pemData) {
+			return nil, errors.New("Can't load CA certificate from " + caCertFile)
+		}
+	}
+
+	return &TlsWebsocketTransport{
+		WebsocketTransport: *transport.GetDefaultWebsocketTransport(),
+		TlsConfig:          &tls.Config{RootCAs: rootCAs}}, nil
+}
+
+func (wst *TlsWebsocketTransport) Connect(url string) (transport.Connection, error) {
+	// compression is offered to server, but messages are sent
+	// uncompressed until SetCompression
+	dialer := websocket.Dialer{TLSClientConfig: wst.TlsConfig, EnableCompression: true}
+	if wst.ProxyUrl != nil {
+		dialer.Proxy = http.ProxyURL(wst.ProxyUrl)
+	}
+	socket, _, err := dialer.Dial(url, wst.RequestHeader)
+	if utils.IsError(err) {
+		return nil, err
+	}
+
+	wst.connection = &websocketConnection{socket: socket, transport: &wst.WebsocketTransport}
+	return wst.connection, nil
+}
+
+func (wst *TlsWebsocketTransport) SetCompression(enabled bool) {
+	// used after server tells that it supports compression
+	if wst.connection != nil {
+		wst.connection.SetCompression(enabled)
+	}
+}
+
+// same as transport.WebsocketConnection which can't be created outside
+type websocketConnection struct {
+	socket     *websocket.Conn
+	transport  *transport.WebsocketTransport
+	compressed int32 // 1 if long messages are compressed
+	onClose    func()
+}
+
+func (wsc *websocketConnection) GetMessage() (string, error) {
+	wsc.socket.SetReadDeadline(time.Now().Add(wsc.transport.ReceiveTimeout))
+	msgType, reader, err := wsc.socket.NextReader()
+	if utils.IsError(err) {
+		return "", err
+	}
+
+	// only text messages are supported
+	if msgType != websocket.TextMessage {
+		return "", transport.ErrorBinaryMessage
+	}
+
+	data, err := ioutil.ReadAll(reader)
+	if utils.IsError(err) {
+		return "", transport.ErrorBadBuffer
+	}
+	if len(data) == 0 {
+		return "", transport.ErrorPacketWrong
+	}
+	return string(data), nil
+}
+
+func (wsc *websocketConnection) SetCompression(enabled bool) {
+	// long messages are sent compressed if permessage-deflate is negotiated.
+	// Connection is written in its own goroutine, so flag is atomic
+	var compressed int32
+	if enabled {
+		compressed = 1
+	}
+	atomic.StoreInt32(&wsc.compressed, compressed)
+}
+
+func (wsc *websocketConnection) WriteMessage(message string) error {
+	wsc.socket.SetWriteDeadline(time.Now().Add(wsc.transport.SendTimeout))
+	wsc.socket.EnableWriteCompression(atomic.LoadInt32(&wsc.compressed) == 1 &&
+		len(message) >= COMPRESSION_THRESHOLD)
+	writer, err := wsc.socket.NextWriter(websocket.TextMessage)
+	if utils.IsError(err) {
+		return err
+	}
+
+	if _, err := writer.Write([]byte(message)); utils.IsError(err) {
+		return err
+	}
+	return writer.Close()
+}
+
+func (wsc *websocketConnection) Close() {
+	wsc.socket.Close()
+	if wsc.onClose != nil {
+		wsc.onClose()
+	}
+}
+
+func (wsc *websocketConnection) PingParams() (time.Duration, time.Duration) {
+	return wsc.transport.PingInterval, wsc.transport.PingTimeout
+}
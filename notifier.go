@@ -0,0 +1,280 @@
+// notifier.go
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"chat/chatclient"
+	"chat/db"
+	"chat/models"
+	"chat/network"
+	"chat/utils"
+)
+
+const NOTIFIER_RECONNECT_MIN_DELAY = 5 * time.Second
+const NOTIFIER_RECONNECT_MAX_DELAY = 5 * time.Minute
+const NOTIFIER_SNIPPET_LENGTH int = 100
+
+// background helper which notifies about messages while gui client is
+// closed. Click on notification opens chat of message in gui client
+type NotifierApplication struct {
+	Loop        *utils.EventLoop
+	Client      *chatclient.Client
+	ProfileName string
+	AccountName string
+	Password    string // empty if session token of gui client is used only
+	ClientPath  string // executable of gui client
+	CurrentUser models.User
+	UserFilters map[int64]models.UserFilter // muted and blocked users of account
+}
+
+func newNotifierApplication() *NotifierApplication {
+	return &NotifierApplication{
+		Loop:        utils.NewEventLoop(),
+		UserFilters: make(map[int64]models.UserFilter)}
+}
+
+func (notifier *NotifierApplication) loadSessionToken() string {
+	// token is shared with gui client, so it's read before each login
+	return utils.LoadSessionToken(notifier.ProfileName, notifier.AccountName)
+}
+
+func (notifier *NotifierApplication) saveSessionToken(token string) {
+	// server replaces used token, so gui client gets new one from keyring
+	err := utils.SaveSessionToken(notifier.ProfileName, notifier.AccountName, token)
+	if utils.IsError(err) {
+		log.Println(err)
+	}
+}
+
+func (notifier *NotifierApplication) login(usePassword bool) error {
+	// token of gui client is used unless it has expired
+	if !usePassword {
+		if token := notifier.loadSessionToken(); token != "" {
+			return notifier.Client.LoginByToken(token)
+		}
+	}
+	if notifier.Password == "" {
+		return errors.New("No saved session. Log in with client or pass password to notifier.")
+	}
+	return notifier.Client.Login(chatclient.GetAuthRequest(notifier.AccountName,
+		notifier.Password))
+}
+
+func (notifier *NotifierApplication) connect(hostData utils.HostData) error {
+	// waits until connection is lost. Returns error if notifier can't work
+	disconnected := make(chan error, 1) // nil if connection was lost
+	finish := func(err error) {
+		select {
+		case disconnected <- err:
+		default:
+		}
+	}
+	var client *chatclient.Client
+	notifier.Loop.Invoke(func() {
+		// callbacks of closed client don't finish next connection
+		client = chatclient.NewClient()
+		client.SetDispatcher(notifier.Loop.Post)
+		notifier.Client = client
+		client.SetOnConnection(func() {
+			if err := notifier.login(false); utils.IsError(err) {
+				finish(err)
+			}
+		})
+		client.SetOnDisconnection(func() {
+			finish(nil)
+		})
+		client.SetOnError(func(serverError models.Error) {
+			if serverError.Process == models.PROCESS_TOKEN_LOGIN {
+				if err := notifier.login(true); utils.IsError(err) {
+					finish(err)
+				}
+			} else if serverError.IsAuthError() {
+				finish(errors.New(serverError.Description))
+			}
+		})
+		client.SetOnTwoFactorRequired(func(models.TwoFactorRequired) {
+			// codes can't be entered in background
+			finish(errors.New("Session has expired. Log in with client to enter one-time code."))
+		})
+		client.SetOnLogin(notifier.processSuccessfulLogin)
+		client.SetOnMessage(notifier.processNewMessage)
+	})
+
+	err := client.Connect(network.ResolveHost(hostData))
+	if utils.IsError(err) {
+		log.Println("Can't connect: " + err.Error())
+		return nil // server is unavailable, connection is tried again
+	}
+	defer client.Close()
+	return <-disconnected
+}
+
+func (notifier *NotifierApplication) processSuccessfulLogin(authData models.SuccessfulAuth) {
+	log.Println("Logged in as " + authData.User.Username)
+	notifier.CurrentUser = authData.User
+	if authData.SessionToken != "" {
+		notifier.saveSessionToken(authData.SessionToken)
+	}
+	// session of notifier doesn't make user online for others
+	notifier.Client.SendPresence(models.PRESENCE_OFFLINE)
+
+	// filters are saved by gui client in cache of account
+	var cache db.MessagesStorage
+	cache.ConnectSqlite(utils.GetMessagesCacheFile(notifier.ProfileName, notifier.AccountName))
+	defer cache.Close()
+	notifier.UserFilters = make(map[int64]models.UserFilter)
+	for _, filter := range cache.GetUserFilters(notifier.CurrentUser.Id) {
+		notifier.UserFilters[filter.User.Id] = filter
+	}
+}
+
+func (notifier *NotifierApplication) canNotify(msg models.SavedMessage) bool {
+	// applies notification settings of gui client. They are read for
+	// each message, so changes made in client are used at once
+	if msg.User.Id == notifier.CurrentUser.Id || notifier.UserFilters[msg.User.Id].Muted ||
+		notifier.UserFilters[msg.User.Id].Blocked {
+		return false
+	}
+	settings := utils.GetSettingsFromFile()
+	if err := settings.SelectProfile(notifier.ProfileName); utils.IsError(err) {
+		return false
+	}
+	if settings.IsDoNotDisturb(time.Now()) {
+		return false
+	}
+	mode := settings.GetChannelNotifications()[notifier.getChatId(msg)].Notifications
+	if mode == "" {
+		mode = settings.Notifications
+	}
+	isMentioned := utils.IsMentioned(msg.Text, notifier.CurrentUser.Username)
+	switch mode {
+	case utils.NOTIFICATIONS_OFF:
+		return false
+	case utils.NOTIFICATIONS_MENTIONS:
+		return isMentioned
+	}
+	return msg.GetChatType() == "private" || isMentioned
+}
+
+func (notifier *NotifierApplication) getChatId(msg models.SavedMessage) int64 {
+	return chatclient.GetMessageChannelId(msg.Message, notifier.CurrentUser.Id)
+}
+
+func (notifier *NotifierApplication) processNewMessage(msg models.SavedMessage) {
+	// running gui client shows notifications itself
+	if !notifier.canNotify(msg) || network.IsClientRunning() {
+		return
+	}
+	text := []rune(msg.Text)
+	if len(text) > NOTIFIER_SNIPPET_LENGTH {
+		text = append(text[:NOTIFIER_SNIPPET_LENGTH], '…')
+	}
+	chatId := notifier.getChatId(msg)
+	err := utils.ShowDesktopNotification(msg.User.Username, string(text), func() {
+		notifier.openChat(chatId)
+	})
+	if utils.IsError(err) {
+		log.Println("Can't show notification: " + err.Error())
+	}
+}
+
+func (notifier *NotifierApplication) openChat(chatId int64) {
+	// gui client is started if it was closed after notification
+	if network.IsClientRunning() {
+		if err := network.OpenChatInClient(chatId); utils.IsError(err) {
+			log.Println(err)
+		}
+		return
+	}
+	cmd := exec.Command(notifier.ClientPath, "-chat", strconv.FormatInt(chatId, 10))
+	if err := cmd.Start(); utils.IsError(err) {
+		log.Println("Can't start client: " + err.Error())
+		return
+	}
+	go cmd.Wait()
+}
+
+func (notifier *NotifierApplication) run(hostData utils.HostData) error {
+	// connects again with growing delays until notifier is stopped
+	backoff := network.NewBackoff(NOTIFIER_RECONNECT_MIN_DELAY, NOTIFIER_RECONNECT_MAX_DELAY)
+	for {
+		startedOn := time.Now()
+		if err := notifier.connect(hostData); utils.IsError(err) {
+			return err
+		}
+		if time.Since(startedOn) > NOTIFIER_RECONNECT_MAX_DELAY {
+			backoff.Reset() // connection worked for a while
+		}
+		delay := backoff.Next()
+		log.Printf("Next attempt to connect in %s", delay.Round(time.Second))
+		time.Sleep(delay)
+	}
+}
+
+func getDefaultClientPath() string {
+	// gui client is expected next to notifier
+	name := "client"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	executable, err := os.Executable()
+	if utils.IsError(err) {
+		return name
+	}
+	return filepath.Join(filepath.Dir(executable), name)
+}
+
+func printNotifierUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: notifier [options]")
+	fmt.Fprintln(os.Stderr, "Shows notifications about messages while client is closed. "+
+		"Session of client is used unless password is passed. Options:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	profileName := flag.String("profile", "", "server profile (active profile by default)")
+	username := flag.String("user", "", "account (active account of profile by default)")
+	password := flag.String("password", "",
+		"password (CHAT_PASSWORD environment variable is used by default)")
+	clientPath := flag.String("client", getDefaultClientPath(),
+		"client started by click on notification")
+	flag.Usage = printNotifierUsage
+	flag.Parse()
+
+	settings := utils.GetSettingsFromFile()
+	if *profileName != "" {
+		err := settings.SelectProfile(*profileName)
+		if utils.IsError(err) {
+			log.Fatal(err)
+		}
+	}
+	if *username == "" {
+		*username = settings.GetActiveAccount()
+	}
+	if *password == "" {
+		*password = os.Getenv("CHAT_PASSWORD")
+	}
+	if strings.TrimSpace(*username) == "" {
+		fmt.Fprintln(os.Stderr, "Account is unknown. Log in with client once or pass username.")
+		os.Exit(2)
+	}
+
+	notifier := newNotifierApplication()
+	notifier.ProfileName = settings.ActiveProfile
+	notifier.AccountName = *username
+	notifier.Password = *password
+	notifier.ClientPath = *clientPath
+	go notifier.Loop.Run()
+	log.Fatal(notifier.run(settings.HostData))
+}
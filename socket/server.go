@@ -0,0 +1,98 @@
+// server.go
+package socket
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/graarh/golang-socketio"
+	"github.com/graarh/golang-socketio/transport"
+)
+
+// accepts socket.io and plain websocket connections.
+// Same handlers are called for both
+type Server struct {
+	socketio  *gosocketio.Server
+	transport transport.Transport
+	handlers  map[string]handler // set before serving
+
+	mutex    sync.Mutex
+	lastId   int64
+	channels map[string]*websocketChannel // map: id -> plain websocket connection
+}
+
+func NewServer(tr transport.Transport) *Server {
+	// tr accepts websocket connections of both protocols
+	return &Server{socketio: gosocketio.NewServer(tr), transport: tr,
+		handlers: make(map[string]handler), channels: make(map[string]*websocketChannel)}
+}
+
+func (s *Server) On(event string, function interface{}) {
+	// handler is func(Channel) or func(Channel, data T)
+	s.handlers[event] = newHandler(function, 1)
+	s.socketio.On(event, adaptSocketioHandler(function, 1,
+		func(channel *gosocketio.Channel) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(socketioChannel{channel})}
+		}))
+}
+
+func (s *Server) GetChannel(id string) (Channel, error) {
+	s.mutex.Lock()
+	channel, ok := s.channels[id]
+	s.mutex.Unlock()
+	if ok {
+		return channel, nil
+	}
+	connection, err := s.socketio.GetChannel(id)
+	if err != nil {
+		return nil, ErrChannelNotFound
+	}
+	return socketioChannel{connection}, nil
+}
+
+func (s *Server) Handle(serveMux *http.ServeMux) {
+	// adds paths of both protocols
+	serveMux.Handle(SOCKETIO_PATH, s.socketio)
+	serveMux.HandleFunc(WEBSOCKET_PATH, s.serveWebsocket)
+}
+
+func (s *Server) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	// reads frames of connection until it's closed
+	conn, err := s.transport.HandleConnection(w, r)
+	if err != nil {
+		return
+	}
+	s.mutex.Lock()
+	s.lastId++
+	channel := &websocketChannel{websocketConn: newWebsocketConn(conn),
+		id: "ws-" + strconv.FormatInt(s.lastId, 10), header: r.Header}
+	s.channels[channel.id] = channel
+	s.mutex.Unlock()
+
+	channelArgs := []reflect.Value{reflect.ValueOf(channel)}
+	if handler, ok := s.handlers[EVENT_CONNECTION]; ok {
+		callHandler(handler, channelArgs, nil)
+	}
+	for {
+		frame, err := channel.read()
+		if err != nil {
+			break
+		}
+		if frame.Event == EVENT_PING {
+			channel.Emit(EVENT_PONG, nil)
+			continue
+		}
+		if handler, ok := s.handlers[frame.Event]; ok {
+			go callHandler(handler, channelArgs, frame.Data)
+		}
+	}
+	channel.Close()
+	s.mutex.Lock()
+	delete(s.channels, channel.id)
+	s.mutex.Unlock()
+	if handler, ok := s.handlers[EVENT_DISCONNECTION]; ok {
+		callHandler(handler, channelArgs, nil)
+	}
+}
@@ -0,0 +1,70 @@
+// socket.go
+package socket
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"chat/utils"
+)
+
+const SOCKETIO_PATH = "/socket.io/"
+const WEBSOCKET_PATH = "/ws/"
+
+// called when connection is opened or closed. Handlers get no arguments
+const EVENT_CONNECTION = "connection"
+const EVENT_DISCONNECTION = "disconnection"
+
+var ErrChannelNotFound = errors.New("channel is not connected")
+
+// connection of one client on server
+type Channel interface {
+	Id() string
+	Emit(event string, data interface{}) error
+	RequestHeader() http.Header // header of http request which opened connection
+	Close()
+}
+
+// connection to server. Handlers are set before Connect
+type Client interface {
+	// handler is func() or func(data T), data is decoded from json
+	On(event string, handler interface{})
+	Connect(url string) error
+	Emit(event string, data interface{}) error
+	Close()
+}
+
+func GetUrl(transportName string, host string, port int, secure bool) string {
+	// returns ws:// or wss:// url of transport on server. Empty name means socket.io
+	scheme := "ws://"
+	if secure {
+		scheme = "wss://"
+	}
+	url := scheme + host + ":" + strconv.Itoa(port)
+	if transportName == utils.TRANSPORT_WEBSOCKET {
+		return url + WEBSOCKET_PATH
+	}
+	return url + SOCKETIO_PATH + "?EIO=3&transport=websocket"
+}
+
+// handler with optional data argument after leading ones
+type handler struct {
+	function reflect.Value
+	dataType reflect.Type // nil if handler has no data argument
+}
+
+func newHandler(function interface{}, leadingArgs int) handler {
+	// panics if handler has wrong signature like socket.io does
+	value := reflect.ValueOf(function)
+	if value.Kind() != reflect.Func || value.Type().NumIn() < leadingArgs ||
+		value.Type().NumIn() > leadingArgs+1 {
+		panic("socket handler must be function with optional data argument")
+	}
+	result := handler{function: value}
+	if value.Type().NumIn() == leadingArgs+1 {
+		result.dataType = value.Type().In(leadingArgs)
+	}
+	return result
+}
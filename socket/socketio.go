@@ -0,0 +1,82 @@
+// socketio.go
+package socket
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/graarh/golang-socketio"
+	"github.com/graarh/golang-socketio/transport"
+
+	"chat/utils"
+)
+
+// connection of graarh/golang-socketio. Handlers of protocol don't depend on it
+type socketioChannel struct {
+	*gosocketio.Channel
+}
+
+type socketioClient struct {
+	transport transport.Transport
+	handlers  map[string]interface{}
+	client    *gosocketio.Client
+	mutex     sync.Mutex // handler of connection can emit before Connect returns
+}
+
+func NewClient(transportName string, tr transport.Transport) Client {
+	// tr opens websocket connection for both protocols
+	if transportName == utils.TRANSPORT_WEBSOCKET {
+		return newWebsocketClient(tr)
+	}
+	return &socketioClient{transport: tr, handlers: make(map[string]interface{})}
+}
+
+func adaptSocketioHandler(function interface{}, leadingArgs int,
+	getLeadingArgs func(channel *gosocketio.Channel) []reflect.Value) interface{} {
+	// returns handler with *gosocketio.Channel argument which socket.io expects
+	handler := newHandler(function, leadingArgs)
+	argTypes := []reflect.Type{reflect.TypeOf((*gosocketio.Channel)(nil))}
+	if handler.dataType != nil {
+		argTypes = append(argTypes, handler.dataType)
+	}
+	adapted := reflect.MakeFunc(reflect.FuncOf(argTypes, nil, false),
+		func(args []reflect.Value) []reflect.Value {
+			channel := args[0].Interface().(*gosocketio.Channel)
+			handler.function.Call(append(getLeadingArgs(channel), args[1:]...))
+			return nil
+		})
+	return adapted.Interface()
+}
+
+func (s *socketioClient) On(event string, handler interface{}) {
+	s.handlers[event] = adaptSocketioHandler(handler, 0,
+		func(*gosocketio.Channel) []reflect.Value { return nil })
+}
+
+func (s *socketioClient) Connect(url string) error {
+	client, err := gosocketio.Dial(url, s.transport)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.client = client
+	for event, handler := range s.handlers {
+		client.On(event, handler)
+	}
+	return nil
+}
+
+func (s *socketioClient) getClient() *gosocketio.Client {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.client
+}
+
+func (s *socketioClient) Emit(event string, data interface{}) error {
+	return s.getClient().Emit(event, data)
+}
+
+func (s *socketioClient) Close() {
+	s.getClient().Close()
+}
@@ -0,0 +1,173 @@
+// websocket.go
+package socket
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/graarh/golang-socketio/transport"
+)
+
+// events of plain websocket which keep idle connection alive. They
+// aren't passed to handlers
+const EVENT_PING = "ping"
+const EVENT_PONG = "pong"
+
+// text message of plain websocket transport. Data is json of emitted value
+type websocketFrame struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// plain websocket connection. Frames are written by several goroutines
+type websocketConn struct {
+	conn      transport.Connection
+	mutex     sync.Mutex
+	done      chan struct{} // closed with connection
+	closeOnce sync.Once
+}
+
+func newWebsocketConn(conn transport.Connection) *websocketConn {
+	return &websocketConn{conn: conn, done: make(chan struct{})}
+}
+
+func (c *websocketConn) Emit(event string, data interface{}) error {
+	frame := websocketFrame{Event: event}
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		frame.Data = encoded
+	}
+	message, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.conn.WriteMessage(string(message))
+}
+
+func (c *websocketConn) read() (websocketFrame, error) {
+	frame := websocketFrame{}
+	message, err := c.conn.GetMessage()
+	if err != nil {
+		return frame, err
+	}
+	return frame, json.Unmarshal([]byte(message), &frame)
+}
+
+func (c *websocketConn) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func callHandler(handler handler, leadingArgs []reflect.Value, data json.RawMessage) {
+	// data which can't be decoded is skipped
+	args := leadingArgs
+	if handler.dataType != nil {
+		value := reflect.New(handler.dataType)
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, value.Interface()); err != nil {
+				log.Println(err)
+				return
+			}
+		}
+		args = append(args, value.Elem())
+	}
+	handler.function.Call(args)
+}
+
+// client of plain websocket transport. Handlers of events are called in
+// own goroutines like in socket.io
+type websocketClient struct {
+	transport transport.Transport
+	handlers  map[string]handler
+	conn      *websocketConn
+}
+
+func newWebsocketClient(tr transport.Transport) *websocketClient {
+	return &websocketClient{transport: tr, handlers: make(map[string]handler)}
+}
+
+func (c *websocketClient) On(event string, function interface{}) {
+	c.handlers[event] = newHandler(function, 0)
+}
+
+func (c *websocketClient) Connect(url string) error {
+	conn, err := c.transport.Connect(url)
+	if err != nil {
+		return err
+	}
+	c.conn = newWebsocketConn(conn)
+	go c.readFrames()
+	go c.sendPings()
+	return nil
+}
+
+func (c *websocketClient) readFrames() {
+	// connection and disconnection handlers are called in this goroutine
+	if handler, ok := c.handlers[EVENT_CONNECTION]; ok {
+		callHandler(handler, nil, nil)
+	}
+	for {
+		frame, err := c.conn.read()
+		if err != nil {
+			break
+		}
+		if handler, ok := c.handlers[frame.Event]; ok {
+			go callHandler(handler, nil, frame.Data)
+		}
+	}
+	c.conn.Close()
+	if handler, ok := c.handlers[EVENT_DISCONNECTION]; ok {
+		callHandler(handler, nil, nil)
+	}
+}
+
+func (c *websocketClient) sendPings() {
+	// server answers pong, so reads of both sides don't time out
+	interval, _ := c.conn.conn.PingParams()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.conn.Emit(EVENT_PING, nil); err != nil {
+				return
+			}
+		case <-c.conn.done:
+			return
+		}
+	}
+}
+
+func (c *websocketClient) Emit(event string, data interface{}) error {
+	return c.conn.Emit(event, data)
+}
+
+func (c *websocketClient) Close() {
+	c.conn.Close()
+}
+
+// connection of plain websocket client on server
+type websocketChannel struct {
+	*websocketConn
+	id     string
+	header http.Header
+}
+
+func (c *websocketChannel) Id() string {
+	return c.id
+}
+
+func (c *websocketChannel) RequestHeader() http.Header {
+	return c.header
+}
@@ -71,7 +71,8 @@ func main() {
 
 		clients[x] = client
 	}
-	authData := models.AuthRequest{"q", encrypt.GetPasswordHash("q")}
+	authData := models.AuthRequest{Username: "q", PasswordHash: encrypt.GetPasswordHash("q"),
+		Scheme: models.AUTH_SCHEME_SHA256}
 	encryptedData := encrypt.Encrypt(key, authData)
 
 	command, err := reader.ReadString('\n')
@@ -0,0 +1,250 @@
+// test_server.go
+package testserver
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/graarh/golang-socketio"
+	"github.com/graarh/golang-socketio/transport"
+	"github.com/satori/go.uuid"
+
+	"chat/chatclient"
+	"chat/encrypt"
+	"chat/models"
+	"chat/utils"
+)
+
+const DEFAULT_PAGE_SIZE int = 50
+
+// in-process chat server for tests of client logic without real server.
+// Only main channel and private chats are supported. Users are registered
+// by first login, all data is kept in memory
+type TestServer struct {
+	HostData  utils.HostData // address for connection of chat client
+	CommonKey uuid.UUID
+
+	server     *gosocketio.Server
+	httpServer *http.Server
+	mutex      sync.Mutex // handlers of events are called concurrently
+
+	users         map[string]models.User // map: username -> user
+	passwords     map[string]string      // map: username -> password hash
+	sessions      map[string]*session    // map: socket id -> session
+	messages      []models.SavedMessage
+	lastMessageId int64
+}
+
+type session struct {
+	models.Session
+	channel *gosocketio.Channel
+}
+
+func Start() (*TestServer, error) {
+	// starts server on free local port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if utils.IsError(err) {
+		return nil, err
+	}
+	testServer := &TestServer{
+		HostData:  utils.HostData{Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port},
+		CommonKey: uuid.FromBytesOrNil([]byte(utils.COMMON_SECRET_KEY)),
+		users:     make(map[string]models.User),
+		passwords: make(map[string]string),
+		sessions:  make(map[string]*session)}
+
+	server := gosocketio.NewServer(transport.GetDefaultWebsocketTransport())
+	server.On(gosocketio.OnDisconnection, testServer.processDisconnection)
+	server.On(chatclient.EVENT_HELLO, testServer.processHello)
+	server.On(chatclient.EVENT_LOGIN, testServer.processLogin)
+	server.On(chatclient.EVENT_MESSAGE, testServer.processNewMessage)
+	server.On(chatclient.EVENT_GET_MESSAGES, testServer.processMessagesRequest)
+	server.On(chatclient.EVENT_GET_CHANNELS, testServer.processChannelsRequest)
+	testServer.server = server
+
+	serveMux := http.NewServeMux()
+	serveMux.Handle("/socket.io/", server)
+	testServer.httpServer = &http.Server{Handler: serveMux}
+	go testServer.httpServer.Serve(listener)
+	return testServer, nil
+}
+
+func (testServer *TestServer) Close() {
+	// stops listening and disconnects clients
+	testServer.httpServer.Close()
+	// disconnection handler locks mutex, so channels are closed without it
+	testServer.mutex.Lock()
+	var channels []*gosocketio.Channel
+	for _, session := range testServer.sessions {
+		channels = append(channels, session.channel)
+	}
+	testServer.mutex.Unlock()
+	for _, channel := range channels {
+		channel.Close()
+	}
+}
+
+func (testServer *TestServer) Messages() []models.SavedMessage {
+	// returns copy of all sent messages
+	testServer.mutex.Lock()
+	defer testServer.mutex.Unlock()
+	return append([]models.SavedMessage{}, testServer.messages...)
+}
+
+func (testServer *TestServer) processDisconnection(c *gosocketio.Channel) {
+	testServer.mutex.Lock()
+	defer testServer.mutex.Unlock()
+	delete(testServer.sessions, c.Id())
+}
+
+func (testServer *TestServer) processHello(c *gosocketio.Channel, clientHello models.Hello) {
+	// optional features aren't supported
+	c.Emit(chatclient.EVENT_HELLO, models.Hello{Version: models.PROTOCOL_VERSION})
+}
+
+func (testServer *TestServer) processLogin(c *gosocketio.Channel, encryptedAuthData string) {
+	// registers unknown user or checks password of known one
+	authData := models.AuthRequest{}
+	encrypt.Decrypt(testServer.CommonKey, encryptedAuthData, &authData)
+	testServer.mutex.Lock()
+	defer testServer.mutex.Unlock()
+
+	user, ok := testServer.users[authData.Username]
+	if !ok {
+		user = models.User{Id: int64(len(testServer.users) + 1), Username: authData.Username}
+		testServer.users[user.Username] = user
+		testServer.passwords[user.Username] = authData.PasswordHash
+	} else if testServer.passwords[user.Username] != authData.PasswordHash {
+		c.Emit(chatclient.EVENT_ERROR, models.NewError(models.PROCESS_LOGIN,
+			models.ERROR_WRONG_PASSWORD, "Password is not correct."))
+		return
+	}
+
+	newSession := &session{channel: c, Session: models.Session{
+		User:      user,
+		SecretKey: uuid.NewV4(),
+		Presence:  models.PRESENCE_ACTIVE}}
+	testServer.sessions[c.Id()] = newSession
+	authResult := models.SuccessfulAuth{User: user, SecretKey: newSession.SecretKey}
+	c.Emit(chatclient.EVENT_LOGIN, encrypt.Encrypt(testServer.CommonKey, authResult))
+}
+
+func (testServer *TestServer) getSession(c *gosocketio.Channel) (*session, error) {
+	session, ok := testServer.sessions[c.Id()]
+	if !ok {
+		return nil, errors.New("Client " + c.Id() + " is not logged in")
+	}
+	return session, nil
+}
+
+func (testServer *TestServer) processNewMessage(c *gosocketio.Channel, encryptedMessage string) {
+	// saves message and sends it to sender and recipients
+	testServer.mutex.Lock()
+	defer testServer.mutex.Unlock()
+	sender, err := testServer.getSession(c)
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	msg := models.Message{}
+	encrypt.Decrypt(sender.SecretKey, encryptedMessage, &msg)
+	msg.User = sender.User
+	if msg.ChatId < utils.GROUP_CHAT_ID {
+		log.Printf("Group %d is not supported by test server\n", msg.ChatId)
+		return
+	}
+
+	testServer.lastMessageId++
+	savedMessage := models.SavedMessage{
+		Message:   msg,
+		Id:        testServer.lastMessageId,
+		CreatedOn: utils.GetTimestampNow(),
+		Status:    models.MESSAGE_STATE_SENT}
+	testServer.messages = append(testServer.messages, savedMessage)
+
+	for _, session := range testServer.sessions {
+		if canReadMessage(session.User.Id, savedMessage) {
+			session.channel.Emit(chatclient.EVENT_MESSAGE,
+				encrypt.Encrypt(session.SecretKey, savedMessage))
+		}
+	}
+}
+
+func canReadMessage(userId int64, msg models.SavedMessage) bool {
+	// everybody reads main channel. Private messages are read by sender and recipient
+	return msg.ChatId == utils.GROUP_CHAT_ID || msg.User.Id == userId || msg.ChatId == userId
+}
+
+func (testServer *TestServer) processMessagesRequest(c *gosocketio.Channel,
+	request models.MessagesRequest) {
+	// sends last messages of chat which are older than BeforeId
+	testServer.mutex.Lock()
+	defer testServer.mutex.Unlock()
+	session, err := testServer.getSession(c)
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+	limit := request.Limit
+	if limit <= 0 {
+		limit = DEFAULT_PAGE_SIZE
+	}
+
+	var messages []models.SavedMessage
+	for _, msg := range testServer.messages {
+		isInChat := canReadMessage(session.User.Id, msg) &&
+			chatclient.GetMessageChannelId(msg.Message, session.User.Id) == request.ChatId
+		if isInChat && (request.BeforeId == 0 || msg.Id < request.BeforeId) {
+			messages = append(messages, msg)
+		}
+	}
+	if len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	pack := models.SavedMessagesPack{
+		Messages: messages,
+		ChatId:   request.ChatId,
+		BeforeId: request.BeforeId}
+	c.Emit(chatclient.EVENT_GET_MESSAGES, encrypt.Encrypt(session.SecretKey, pack))
+}
+
+func (testServer *TestServer) processChannelsRequest(c *gosocketio.Channel,
+	request models.ChannelsRequest) {
+	// sends private chats of user in order of first message
+	testServer.mutex.Lock()
+	defer testServer.mutex.Unlock()
+	session, err := testServer.getSession(c)
+	if utils.IsError(err) {
+		log.Println(err)
+		return
+	}
+
+	channels := []models.Channel{}
+	isAdded := make(map[int64]bool)
+	for _, msg := range testServer.messages {
+		if msg.GetChatType() == "group" || !canReadMessage(session.User.Id, msg) {
+			continue
+		}
+		partnerId := chatclient.GetMessageChannelId(msg.Message, session.User.Id)
+		if partnerId == session.User.Id || isAdded[partnerId] { // notes or known chat
+			continue
+		}
+		isAdded[partnerId] = true
+		channels = append(channels, models.Channel{Id: partnerId,
+			Title: testServer.getUsername(partnerId)})
+	}
+	pack := models.ChannelsPack{Channels: channels}
+	c.Emit(chatclient.EVENT_GET_CHANNELS, encrypt.Encrypt(session.SecretKey, pack))
+}
+
+func (testServer *TestServer) getUsername(userId int64) string {
+	for _, user := range testServer.users {
+		if user.Id == userId {
+			return user.Username
+		}
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+// captcha.go
+package utils
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+)
+
+const CAPTCHA_LENGTH int = 5
+const CAPTCHA_SCALE int = 5 // pixels of glyph dot
+const CAPTCHA_NOISE_LINES int = 4
+
+// digits which can't be confused with letters
+const CAPTCHA_DIGITS = "23456789"
+
+// 5x7 glyphs of CAPTCHA_DIGITS, one string per row
+var CAPTCHA_GLYPHS = map[byte][7]string{
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+}
+
+func GenerateCaptcha() (string, []byte, error) {
+	// returns text and png image where its digits are shifted and crossed by lines
+	var seed int64
+	if err := binary.Read(crand.Reader, binary.LittleEndian, &seed); IsError(err) {
+		return "", nil, err
+	}
+	random := rand.New(rand.NewSource(seed))
+	text := make([]byte, CAPTCHA_LENGTH)
+	for i := range text {
+		text[i] = CAPTCHA_DIGITS[random.Intn(len(CAPTCHA_DIGITS))]
+	}
+
+	glyphWidth := 7 * CAPTCHA_SCALE
+	width := (CAPTCHA_LENGTH + 1) * glyphWidth
+	height := 12 * CAPTCHA_SCALE
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(200 + random.Intn(56))
+	}
+	ink := color.Gray{Y: 40}
+	for i, digit := range text {
+		left := glyphWidth/2 + i*glyphWidth + random.Intn(CAPTCHA_SCALE*2) - CAPTCHA_SCALE
+		top := random.Intn(height - 7*CAPTCHA_SCALE)
+		slant := random.Intn(3) - 1 // rows are shifted to lean glyph
+		for row, line := range CAPTCHA_GLYPHS[digit] {
+			for column, dot := range line {
+				if dot != '#' {
+					continue
+				}
+				x := left + column*CAPTCHA_SCALE + slant*(3-row)*CAPTCHA_SCALE/3
+				y := top + row*CAPTCHA_SCALE
+				fillRect(img, x, y, CAPTCHA_SCALE, CAPTCHA_SCALE, ink)
+			}
+		}
+	}
+	for i := 0; i < CAPTCHA_NOISE_LINES; i++ {
+		drawLine(img, random.Intn(width), random.Intn(height), random.Intn(width),
+			random.Intn(height), ink)
+	}
+
+	var data bytes.Buffer
+	if err := png.Encode(&data, img); IsError(err) {
+		return "", nil, err
+	}
+	return string(text), data.Bytes(), nil
+}
+
+func fillRect(img *image.Gray, x int, y int, width int, height int, c color.Gray) {
+	for dy := 0; dy < height; dy++ {
+		for dx := 0; dx < width; dx++ {
+			img.SetGray(x+dx, y+dy, c) // points outside of image are skipped
+		}
+	}
+}
+
+func drawLine(img *image.Gray, x0 int, y0 int, x1 int, y1 int, c color.Gray) {
+	// Bresenham's line of 2 pixels width
+	dx, dy := absInt(x1-x0), -absInt(y1-y0)
+	stepX, stepY := 1, 1
+	if x0 > x1 {
+		stepX = -1
+	}
+	if y0 > y1 {
+		stepY = -1
+	}
+	err := dx + dy
+	for {
+		fillRect(img, x0, y0, 2, 2, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		doubled := 2 * err
+		if doubled >= dy {
+			err += dy
+			x0 += stepX
+		}
+		if doubled <= dx {
+			err += dx
+			y0 += stepY
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// chat_export.go
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"chat/models"
+)
+
+const EXPORT_JSON = "json"
+const EXPORT_HTML = "html"
+const EXPORT_TEXT = "text"
+
+var EXPORT_FORMATS = []string{EXPORT_JSON, EXPORT_HTML, EXPORT_TEXT}
+
+const EXPORT_TIME_FORMAT = "2006-01-02 15:04:05"
+
+// message of exported chat. Time is shown to reader,
+// CreatedOn and ids are needed to restore exported history
+type ExportedMessage struct {
+	Id         int64  `json:"id"`
+	Time       string `json:"time"`
+	CreatedOn  int64  `json:"created_on"`
+	AuthorId   int64  `json:"author_id"`
+	Author     string `json:"author"`
+	Text       string `json:"text"`
+	Attachment string `json:"attachment,omitempty"` // file name
+	Edited     bool   `json:"edited,omitempty"`
+	EditedOn   int64  `json:"edited_on,omitempty"`
+	ReplyToId  int64  `json:"reply_to_id,omitempty"`
+	ReplyTo    string `json:"reply_to,omitempty"` // beginning of replied message
+
+	ForwardedFrom string `json:"forwarded_from,omitempty"` // author of original message
+	Sticker       string `json:"sticker,omitempty"`        // url or file name of sticker
+}
+
+// ChatId is id of channel in channels list of user who exported chat
+type ExportedChat struct {
+	Title    string            `json:"title"`
+	ChatId   int64             `json:"chat_id"`
+	Messages []ExportedMessage `json:"messages"`
+}
+
+func newExportedChat(title string, chatId int64, messages []models.SavedMessage) ExportedChat {
+	chat := ExportedChat{Title: title, ChatId: chatId, Messages: []ExportedMessage{}}
+	for _, msg := range messages {
+		exported := ExportedMessage{
+			Id:        msg.Id,
+			Time:      time.Unix(msg.CreatedOn, 0).Local().Format(EXPORT_TIME_FORMAT),
+			CreatedOn: msg.CreatedOn,
+			AuthorId:  msg.User.Id,
+			Author:    msg.User.Username,
+			Text:      msg.Text,
+			Edited:    msg.IsEdited(),
+			EditedOn:  msg.EditedOn,
+			ReplyToId: msg.ReplyToId,
+
+			ForwardedFrom: msg.ForwardedFrom,
+			Sticker:       msg.Sticker,
+		}
+		if msg.HasAttachment() {
+			exported.Attachment = msg.Attachment.FileName
+		}
+		if msg.IsReply() && msg.ReplyTo.User.Username != "" {
+			exported.ReplyTo = msg.ReplyTo.User.Username + ": " + msg.ReplyTo.Text
+		}
+		chat.Messages = append(chat.Messages, exported)
+	}
+	return chat
+}
+
+func ExportChat(writer io.Writer, format string, title string, chatId int64,
+	messages []models.SavedMessage) error {
+	// writes messages ordered by id with timestamps and authors
+	chat := newExportedChat(title, chatId, messages)
+	switch format {
+	case EXPORT_JSON:
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(chat)
+	case EXPORT_HTML:
+		return exportHtml(writer, chat)
+	case EXPORT_TEXT:
+		return exportText(writer, chat)
+	}
+	return errors.New("Unknown export format " + format + ".")
+}
+
+func ImportChat(reader io.Reader) (ExportedChat, error) {
+	// reads chat exported to json. Messages without ids, authors or
+	// time and repeated ids are rejected, so broken file isn't imported partly
+	chat := ExportedChat{}
+	err := json.NewDecoder(reader).Decode(&chat)
+	if IsError(err) {
+		return chat, errors.New("File is not exported chat: " + err.Error())
+	}
+	if chat.Messages == nil {
+		return chat, errors.New("File has no messages list.")
+	}
+	ids := make(map[int64]bool)
+	for _, msg := range chat.Messages {
+		if msg.Id <= 0 || msg.AuthorId <= 0 || msg.Author == "" || msg.CreatedOn <= 0 {
+			return chat, fmt.Errorf("Message %d has no id, author or time.", msg.Id)
+		}
+		if ids[msg.Id] {
+			return chat, fmt.Errorf("Message %d is repeated.", msg.Id)
+		}
+		if msg.Sticker != "" && msg.Attachment == "" && IsError(ValidateStickerUrl(msg.Sticker)) {
+			return chat, fmt.Errorf("Message %d has wrong sticker url.", msg.Id)
+		}
+		ids[msg.Id] = true
+	}
+	return chat, nil
+}
+
+func (chat *ExportedChat) GetSavedMessages(chatId int64) []models.SavedMessage {
+	// returns imported messages of chat. Attachments are not exported,
+	// so only their names are kept in text. Reply previews are restored
+	// from replied messages found in the same file
+	var messages []models.SavedMessage
+	replied := make(map[int64]ExportedMessage)
+	for _, exported := range chat.Messages {
+		replied[exported.Id] = exported
+	}
+	for _, exported := range chat.Messages {
+		msg := models.SavedMessage{
+			Message: models.Message{
+				User:      models.User{Id: exported.AuthorId, Username: exported.Author},
+				ChatId:    chatId,
+				Text:      exported.Text,
+				ReplyToId: exported.ReplyToId,
+
+				ForwardedFrom: exported.ForwardedFrom,
+			},
+			Id:        exported.Id,
+			CreatedOn: exported.CreatedOn,
+			EditedOn:  exported.EditedOn,
+			Status:    models.MESSAGE_STATE_SENT,
+		}
+		if exported.Attachment != "" {
+			msg.Text = strings.TrimSpace(msg.Text + "\n[" + exported.Attachment + "]")
+		} else { // stickers of local pack are files, others are shown by url
+			msg.Sticker = exported.Sticker
+		}
+		if repliedMsg, ok := replied[exported.ReplyToId]; ok {
+			text := []rune(repliedMsg.Text)
+			if len(text) == 0 {
+				text = []rune(repliedMsg.Attachment)
+			}
+			if len(text) > models.REPLY_PREVIEW_LENGTH {
+				text = text[:models.REPLY_PREVIEW_LENGTH]
+			}
+			msg.ReplyTo = models.ReplyPreview{Text: string(text),
+				User: models.User{Id: repliedMsg.AuthorId, Username: repliedMsg.Author}}
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func (msg *ExportedMessage) GetNoteText() string {
+	// text of imported message copied to notes
+	text := fmt.Sprintf("[%s] %s: %s", msg.Time, msg.Author, msg.Text)
+	if msg.Attachment != "" {
+		text += " [file: " + msg.Attachment + "]"
+	}
+	return text
+}
+
+func exportText(writer io.Writer, chat ExportedChat) error {
+	// one line per message, continuation lines of text are indented
+	if _, err := fmt.Fprintf(writer, "%s\n\n", chat.Title); IsError(err) {
+		return err
+	}
+	for _, msg := range chat.Messages {
+		line := fmt.Sprintf("[%s] %s: %s", msg.Time, msg.Author, indentLines(msg.Text))
+		if msg.ReplyTo != "" {
+			line = fmt.Sprintf("[%s] %s (reply to %s): %s", msg.Time, msg.Author,
+				msg.ReplyTo, indentLines(msg.Text))
+		}
+		if msg.ForwardedFrom != "" {
+			line += " (forwarded from " + msg.ForwardedFrom + ")"
+		}
+		if msg.Attachment != "" {
+			line += " [file: " + msg.Attachment + "]"
+		} else if msg.Sticker != "" {
+			line += " [sticker: " + msg.Sticker + "]"
+		}
+		if msg.Edited {
+			line += " (edited)"
+		}
+		if _, err := fmt.Fprintln(writer, line); IsError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func indentLines(text string) string {
+	var result []rune
+	for _, c := range text {
+		result = append(result, c)
+		if c == '\n' {
+			result = append(result, []rune("    ")...)
+		}
+	}
+	return string(result)
+}
+
+func exportHtml(writer io.Writer, chat ExportedChat) error {
+	// standalone page, all user texts are escaped
+	title := html.EscapeString(chat.Title)
+	_, err := fmt.Fprintf(writer, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n"+
+		"<title>%s</title>\n<style>\n"+
+		"body { font-family: sans-serif; }\n"+
+		".time { color: gray; }\n"+
+		".author { font-weight: bold; }\n"+
+		".reply { color: gray; border-left: 2px solid gray; padding-left: 4px; }\n"+
+		".text { white-space: pre-wrap; }\n"+
+		"</style>\n</head>\n<body>\n<h1>%s</h1>\n", title, title)
+	if IsError(err) {
+		return err
+	}
+	for _, msg := range chat.Messages {
+		text := "<div class=\"message\">\n<span class=\"time\">" + html.EscapeString(msg.Time) +
+			"</span> <span class=\"author\">" + html.EscapeString(msg.Author) + "</span>\n"
+		if msg.ReplyTo != "" {
+			text += "<div class=\"reply\">" + html.EscapeString(msg.ReplyTo) + "</div>\n"
+		}
+		if msg.ForwardedFrom != "" {
+			text += "<div class=\"time\">forwarded from " + html.EscapeString(msg.ForwardedFrom) +
+				"</div>\n"
+		}
+		text += "<div class=\"text\">" + html.EscapeString(msg.Text) + "</div>\n"
+		if msg.Attachment != "" {
+			text += "<div class=\"attachment\">" + html.EscapeString(msg.Attachment) + "</div>\n"
+		} else if msg.Sticker != "" {
+			text += "<div class=\"attachment\">sticker " + html.EscapeString(msg.Sticker) +
+				"</div>\n"
+		}
+		if msg.Edited {
+			text += "<div class=\"time\">(edited)</div>\n"
+		}
+		if _, err := io.WriteString(writer, text+"</div>\n"); IsError(err) {
+			return err
+		}
+	}
+	_, err = io.WriteString(writer, "</body>\n</html>\n")
+	return err
+}
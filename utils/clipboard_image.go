@@ -0,0 +1,46 @@
+// clipboard_image.go
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+var PNG_SIGNATURE = []byte("\x89PNG\r\n\x1a\n")
+
+// clipboard of fyne contains text only, so images are read
+// by clipboard tools of system. Each command writes png to stdout
+func getClipboardImageCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pngpaste", "-"}}
+	case "windows":
+		return [][]string{{"powershell", "-sta", "-c",
+			"Add-Type -AssemblyName System.Windows.Forms; " +
+				"$image = [Windows.Forms.Clipboard]::GetImage(); " +
+				"if ($image) { $stream = New-Object IO.MemoryStream; " +
+				"$image.Save($stream, [Drawing.Imaging.ImageFormat]::Png); " +
+				"$output = [Console]::OpenStandardOutput(); " +
+				"$output.Write($stream.ToArray(), 0, $stream.Length) }"}}
+	}
+	return [][]string{
+		{"wl-paste", "--no-newline", "--type", "image/png"},
+		{"xclip", "-selection", "clipboard", "-target", "image/png", "-out"},
+	}
+}
+
+func ReadClipboardImage() ([]byte, error) {
+	// returns png image from clipboard. Error if clipboard has no image
+	for _, command := range getClipboardImageCommands() {
+		if _, err := exec.LookPath(command[0]); IsError(err) {
+			continue
+		}
+		data, err := exec.Command(command[0], command[1:]...).Output()
+		if !IsError(err) && bytes.HasPrefix(data, PNG_SIGNATURE) {
+			return data, nil
+		}
+	}
+	return nil, errors.New("Clipboard has no image.")
+}
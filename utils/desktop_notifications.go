@@ -0,0 +1,85 @@
+// desktop_notifications.go
+package utils
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// printed by notification command when user clicks notification
+const NOTIFICATION_CLICKED = "default"
+
+// notifications of programs without window (notifier) are sent by tools of
+// system. Command waits until notification is closed and prints
+// NOTIFICATION_CLICKED if it was clicked
+func getNotificationCommand(title string, text string) (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		// notification center doesn't report clicks of scripts
+		return exec.Command("osascript", "-e", "display notification "+
+			quoteAppleScript(text)+" with title "+quoteAppleScript(title)), nil
+	case "windows":
+		return getPowershellCommand("Add-Type -AssemblyName System.Windows.Forms; "+
+			"$icon = New-Object Windows.Forms.NotifyIcon; "+
+			"$icon.Icon = [Drawing.SystemIcons]::Information; "+
+			"$icon.BalloonTipTitle = $env:CHAT_TITLE; "+
+			"$icon.BalloonTipText = $env:CHAT_TEXT; "+
+			"$icon.Visible = $true; "+
+			"Register-ObjectEvent $icon BalloonTipClicked -SourceIdentifier clicked | Out-Null; "+
+			"$icon.ShowBalloonTip(10000); "+
+			"if (Wait-Event -SourceIdentifier clicked -Timeout 10) { '"+NOTIFICATION_CLICKED+"' }; "+
+			"$icon.Dispose()", "CHAT_TITLE="+title, "CHAT_TEXT="+text), nil
+	}
+	if _, err := exec.LookPath("notify-send"); IsError(err) {
+		return nil, errors.New("No notification tool found (notify-send).")
+	}
+	return exec.Command("notify-send", "--app-name=Chat",
+		"--action="+NOTIFICATION_CLICKED+"=Open", title, text), nil
+}
+
+func quoteAppleScript(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
+
+func quotePowershell(text string) string {
+	// powershell ends quoted string by typographic quotes too
+	return "'" + strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019",
+		"\u201a", "\u201a\u201a", "\u201b", "\u201b\u201b").Replace(text) + "'"
+}
+
+func getPowershellCommand(script string, env ...string) *exec.Cmd {
+	// data of user is passed by environment variables ($env:NAME) and
+	// never becomes part of script
+	cmd := exec.Command("powershell", "-NoProfile", "-c", script)
+	cmd.Env = append(os.Environ(), env...)
+	return cmd
+}
+
+func ShowDesktopNotification(title string, text string, onClick func()) error {
+	// shows notification in background. onClick is called in goroutine
+	// of notification if user clicks it
+	cmd, err := getNotificationCommand(title, text)
+	if IsError(err) {
+		return err
+	}
+	var output strings.Builder
+	cmd.Stdout = &output
+	if err = cmd.Start(); IsError(err) {
+		return err
+	}
+	go func() {
+		// old notify-send can't wait for actions. It fails and
+		// notification is shown without them
+		if err := cmd.Wait(); IsError(err) && runtime.GOOS == "linux" {
+			exec.Command("notify-send", "--app-name=Chat", title, text).Run()
+			return
+		}
+		if strings.TrimSpace(output.String()) == NOTIFICATION_CLICKED && onClick != nil {
+			onClick()
+		}
+	}()
+	return nil
+}
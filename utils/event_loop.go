@@ -0,0 +1,52 @@
+// event_loop.go
+package utils
+
+import "sync"
+
+// runs posted functions one by one in single goroutine, so state changed
+// by them isn't accessed concurrently. Queue isn't limited: posted function
+// can post another one without blocking
+type EventLoop struct {
+	mutex sync.Mutex
+	cond  *sync.Cond
+	queue []func()
+}
+
+func NewEventLoop() *EventLoop {
+	loop := &EventLoop{}
+	loop.cond = sync.NewCond(&loop.mutex)
+	return loop
+}
+
+func (loop *EventLoop) Post(f func()) {
+	// f is called after previously posted functions
+	loop.mutex.Lock()
+	loop.queue = append(loop.queue, f)
+	loop.mutex.Unlock()
+	loop.cond.Signal()
+}
+
+func (loop *EventLoop) Invoke(f func()) {
+	// posts f and waits until it's done. Functions of loop can't call it
+	done := make(chan bool)
+	loop.Post(func() {
+		defer close(done)
+		f()
+	})
+	<-done
+}
+
+func (loop *EventLoop) Run() {
+	// calls posted functions, never returns
+	for {
+		loop.mutex.Lock()
+		for len(loop.queue) == 0 {
+			loop.cond.Wait()
+		}
+		f := loop.queue[0]
+		loop.queue[0] = nil
+		loop.queue = loop.queue[1:]
+		loop.mutex.Unlock()
+		f()
+	}
+}
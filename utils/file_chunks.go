@@ -0,0 +1,55 @@
+// file_chunks.go
+package utils
+
+import (
+	"errors"
+	"io"
+)
+
+const MAX_PENDING_CHUNKS int = 64 // chunks which came before previous ones
+
+// handlers of events are called in own goroutines, so chunks of file can
+// come out of order. Early chunks wait until chunks before them are written
+type ChunkWriter struct {
+	Writer  io.Writer
+	Size    int64            // bytes written in order
+	end     int64            // size of file, -1 until last chunk comes
+	pending map[int64][]byte // map: offset -> data of early chunk
+}
+
+func NewChunkWriter(writer io.Writer) *ChunkWriter {
+	return &ChunkWriter{Writer: writer, end: -1, pending: make(map[int64][]byte)}
+}
+
+func (chunks *ChunkWriter) Write(offset int64, data []byte, isLast bool) (bool, error) {
+	// returns true when all chunks till last one are written
+	chunkEnd := offset + int64(len(data))
+	if _, ok := chunks.pending[offset]; ok || offset < chunks.Size ||
+		chunks.end >= 0 && (isLast || chunkEnd > chunks.end) {
+		return false, errors.New("Chunk of file is repeated or beyond its end.")
+	}
+	if len(chunks.pending) >= MAX_PENDING_CHUNKS {
+		return false, errors.New("Too many chunks of file came out of order.")
+	}
+	if isLast {
+		for pendingOffset := range chunks.pending {
+			if pendingOffset >= chunkEnd {
+				return false, errors.New("Chunk of file is beyond its end.")
+			}
+		}
+		chunks.end = chunkEnd
+	}
+	chunks.pending[offset] = data
+	for {
+		data, ok := chunks.pending[chunks.Size]
+		if !ok {
+			break
+		}
+		delete(chunks.pending, chunks.Size)
+		if _, err := chunks.Writer.Write(data); IsError(err) {
+			return false, err
+		}
+		chunks.Size += int64(len(data))
+	}
+	return chunks.Size == chunks.end, nil
+}
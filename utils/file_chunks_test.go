@@ -0,0 +1,57 @@
+// file_chunks_test.go
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestChunkWriterOrder(t *testing.T) {
+	// chunks of "abcdefg" by 3 bytes come in any order
+	orders := [][]int{{0, 1, 2}, {2, 1, 0}, {1, 2, 0}, {0, 2, 1}}
+	offsets := []int64{0, 3, 6}
+	parts := []string{"abc", "def", "g"}
+	for _, order := range orders {
+		var output bytes.Buffer
+		chunks := NewChunkWriter(&output)
+		for i, index := range order {
+			isFinished, err := chunks.Write(offsets[index], []byte(parts[index]), index == 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isFinished != (i == len(order)-1) {
+				t.Errorf("order %v: finished = %v after %d chunks", order, isFinished, i+1)
+			}
+		}
+		if output.String() != "abcdefg" {
+			t.Errorf("order %v: written %q", order, output.String())
+		}
+	}
+}
+
+func TestChunkWriterEmptyLastChunk(t *testing.T) {
+	// file of whole chunks ends by empty chunk
+	var output bytes.Buffer
+	chunks := NewChunkWriter(&output)
+	if isFinished, err := chunks.Write(3, nil, true); isFinished || err != nil {
+		t.Fatalf("finished = %v, err = %v before first chunk", isFinished, err)
+	}
+	if isFinished, err := chunks.Write(0, []byte("abc"), false); !isFinished || err != nil {
+		t.Fatalf("finished = %v, err = %v after all chunks", isFinished, err)
+	}
+}
+
+func TestChunkWriterWrongChunks(t *testing.T) {
+	chunks := NewChunkWriter(&bytes.Buffer{})
+	chunks.Write(0, []byte("abc"), false)
+	if _, err := chunks.Write(0, []byte("abc"), false); err == nil {
+		t.Error("repeated chunk is accepted")
+	}
+	chunks.Write(6, []byte("g"), true)
+	if _, err := chunks.Write(7, []byte("h"), false); err == nil {
+		t.Error("chunk after end is accepted")
+	}
+	if _, err := chunks.Write(3, []byte("def"), true); err == nil {
+		t.Error("second last chunk is accepted")
+	}
+}
@@ -0,0 +1,155 @@
+// keyring.go
+package utils
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const KEYRING_SERVICE = "Chat"
+
+// credential manager of windows is called from powershell by its api
+const WINCRED_TYPE = `
+using System;
+using System.ComponentModel;
+using System.Runtime.InteropServices;
+using System.Text;
+
+public static class ChatCredentials {
+	[StructLayout(LayoutKind.Sequential, CharSet = CharSet.Unicode)]
+	struct CREDENTIAL {
+		public int Flags;
+		public int Type;
+		public string TargetName;
+		public string Comment;
+		public System.Runtime.InteropServices.ComTypes.FILETIME LastWritten;
+		public int CredentialBlobSize;
+		public IntPtr CredentialBlob;
+		public int Persist;
+		public int AttributeCount;
+		public IntPtr Attributes;
+		public string TargetAlias;
+		public string UserName;
+	}
+
+	[DllImport("advapi32.dll", CharSet = CharSet.Unicode, SetLastError = true)]
+	static extern bool CredWrite(ref CREDENTIAL credential, int flags);
+	[DllImport("advapi32.dll", CharSet = CharSet.Unicode, SetLastError = true)]
+	static extern bool CredRead(string target, int type, int flags, out IntPtr credential);
+	[DllImport("advapi32.dll", CharSet = CharSet.Unicode, SetLastError = true)]
+	static extern bool CredDelete(string target, int type, int flags);
+	[DllImport("advapi32.dll")]
+	static extern void CredFree(IntPtr credential);
+
+	public static void Write(string target, string secret) {
+		byte[] blob = Encoding.Unicode.GetBytes(secret);
+		CREDENTIAL credential = new CREDENTIAL();
+		credential.Type = 1; // generic
+		credential.Persist = 2; // local machine
+		credential.TargetName = target;
+		credential.UserName = target;
+		credential.CredentialBlobSize = blob.Length;
+		credential.CredentialBlob = Marshal.AllocHGlobal(blob.Length);
+		try {
+			Marshal.Copy(blob, 0, credential.CredentialBlob, blob.Length);
+			if (!CredWrite(ref credential, 0)) throw new Win32Exception();
+		} finally {
+			Marshal.FreeHGlobal(credential.CredentialBlob);
+		}
+	}
+
+	public static string Read(string target) {
+		IntPtr pointer;
+		if (!CredRead(target, 1, 0, out pointer)) return null;
+		try {
+			CREDENTIAL credential = (CREDENTIAL)Marshal.PtrToStructure(pointer, typeof(CREDENTIAL));
+			return Marshal.PtrToStringUni(credential.CredentialBlob, credential.CredentialBlobSize / 2);
+		} finally {
+			CredFree(pointer);
+		}
+	}
+
+	public static void Delete(string target) {
+		CredDelete(target, 1, 0);
+	}
+}`
+
+// secrets are passed to keyring tools by stdin where it's possible,
+// so they aren't seen in arguments of processes
+func getKeyringCommand(action string, key string) (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		switch action {
+		case "set": // security reads password from prompt of stdin with -w as last option
+			return exec.Command("security", "add-generic-password", "-U",
+				"-s", KEYRING_SERVICE, "-a", key, "-w"), nil
+		case "get":
+			return exec.Command("security", "find-generic-password",
+				"-s", KEYRING_SERVICE, "-a", key, "-w"), nil
+		}
+		return exec.Command("security", "delete-generic-password",
+			"-s", KEYRING_SERVICE, "-a", key), nil
+	case "windows":
+		script := map[string]string{
+			"set": "[ChatCredentials]::Write($env:CHAT_TARGET, [Console]::In.ReadToEnd())",
+			"get": "$secret = [ChatCredentials]::Read($env:CHAT_TARGET); " +
+				"if ($secret -eq $null) { exit 1 }; [Console]::Out.Write($secret)",
+			"delete": "[ChatCredentials]::Delete($env:CHAT_TARGET)"}[action]
+		return getPowershellCommand("Add-Type -TypeDefinition "+quotePowershell(WINCRED_TYPE)+"; "+
+			script, "CHAT_TARGET="+KEYRING_SERVICE+"/"+key), nil
+	}
+	// secret service of gnome keyring or kwallet by libsecret
+	if _, err := exec.LookPath("secret-tool"); IsError(err) {
+		return nil, errors.New("Keyring is not available: secret-tool is not found.")
+	}
+	switch action {
+	case "set":
+		return exec.Command("secret-tool", "store", "--label="+KEYRING_SERVICE+" session of "+key,
+			"service", KEYRING_SERVICE, "account", key), nil
+	case "get":
+		return exec.Command("secret-tool", "lookup", "service", KEYRING_SERVICE, "account", key), nil
+	}
+	return exec.Command("secret-tool", "clear", "service", KEYRING_SERVICE, "account", key), nil
+}
+
+func SetKeyringSecret(key string, secret string) error {
+	// saves secret in keyring of system: keychain, secret service or credential manager
+	cmd, err := getKeyringCommand("set", key)
+	if IsError(err) {
+		return err
+	}
+	input := secret
+	if runtime.GOOS == "darwin" {
+		input = secret + "\n" + secret + "\n" // prompt asks password twice
+	}
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.CombinedOutput()
+	if IsError(err) {
+		return errors.New("Can't save secret in keyring: " + strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func GetKeyringSecret(key string) (string, error) {
+	// returns error if keyring has no secret or isn't available
+	cmd, err := getKeyringCommand("get", key)
+	if IsError(err) {
+		return "", err
+	}
+	output, err := cmd.Output()
+	secret := strings.TrimRight(string(output), "\r\n")
+	if IsError(err) || secret == "" {
+		return "", errors.New("Keyring has no secret of " + key)
+	}
+	return secret, nil
+}
+
+func DeleteKeyringSecret(key string) {
+	// missing secret isn't error
+	cmd, err := getKeyringCommand("delete", key)
+	if !IsError(err) {
+		cmd.Run()
+	}
+}
@@ -0,0 +1,70 @@
+// lru_cache.go
+package utils
+
+import (
+	"container/list"
+	"sync"
+)
+
+// values by key limited by total size. Least recently used values
+// are removed when new one doesn't fit
+type LRUCache struct {
+	mutex    sync.Mutex
+	capacity int64
+	size     int64
+	order    *list.List               // front is most recently used entry
+	entries  map[string]*list.Element // map: key -> element of order
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+	size  int64
+}
+
+func NewLRUCache(capacity int64) *LRUCache {
+	return &LRUCache{capacity: capacity, order: list.New(),
+		entries: make(map[string]*list.Element)}
+}
+
+func (cache *LRUCache) Get(key string) (interface{}, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	return element.Value.(*lruEntry).value, true
+}
+
+func (cache *LRUCache) Add(key string, value interface{}, size int64) {
+	// replaces value with same key. Value bigger than capacity isn't kept
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if element, ok := cache.entries[key]; ok {
+		cache.removeElement(element)
+	}
+	if size > cache.capacity {
+		return
+	}
+	for cache.size+size > cache.capacity {
+		cache.removeElement(cache.order.Back())
+	}
+	cache.entries[key] = cache.order.PushFront(&lruEntry{key: key, value: value, size: size})
+	cache.size += size
+}
+
+func (cache *LRUCache) Remove(key string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if element, ok := cache.entries[key]; ok {
+		cache.removeElement(element)
+	}
+}
+
+func (cache *LRUCache) removeElement(element *list.Element) {
+	entry := cache.order.Remove(element).(*lruEntry)
+	delete(cache.entries, entry.key)
+	cache.size -= entry.size
+}
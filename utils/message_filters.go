@@ -0,0 +1,114 @@
+// message_filters.go
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const FILTER_HIDE = "hide"         // matching messages aren't shown
+const FILTER_COLLAPSE = "collapse" // matching messages are shown by "Show anyway" button
+const MAX_MESSAGE_FILTERS int = 100
+
+// word or regular expression of messages which user doesn't want to read
+type MessageFilter struct {
+	Pattern  string `json:"pattern"`
+	IsRegexp bool   `json:"regexp"` // otherwise pattern is word matched without case
+	Action   string `json:"action"` // hide or collapse
+}
+
+func (filter MessageFilter) compile() (*regexp.Regexp, error) {
+	if filter.IsRegexp {
+		return regexp.Compile(filter.Pattern)
+	}
+	// \b knows only ascii letters, so words of other alphabets are bounded here
+	return regexp.Compile(`(?i)(^|[^\p{L}\p{N}_])` + regexp.QuoteMeta(filter.Pattern) +
+		`($|[^\p{L}\p{N}_])`)
+}
+
+func (filter MessageFilter) Validate() error {
+	if strings.TrimSpace(filter.Pattern) == "" {
+		return errors.New("Pattern of message filter is empty.")
+	}
+	if filter.Action != FILTER_HIDE && filter.Action != FILTER_COLLAPSE {
+		return errors.New("Unknown action of message filter: " + filter.Action)
+	}
+	if _, err := filter.compile(); IsError(err) {
+		return errors.New("Pattern of message filter is not valid: " + err.Error())
+	}
+	return nil
+}
+
+// compiled filters which are checked for each shown message
+type MessageMatcher struct {
+	patterns []*regexp.Regexp
+	actions  []string
+}
+
+func NewMessageMatcher(filters []MessageFilter) *MessageMatcher {
+	// invalid filters are skipped, settings are validated before saving
+	matcher := &MessageMatcher{}
+	for _, filter := range filters {
+		if IsError(filter.Validate()) {
+			continue
+		}
+		pattern, _ := filter.compile()
+		matcher.patterns = append(matcher.patterns, pattern)
+		matcher.actions = append(matcher.actions, filter.Action)
+	}
+	return matcher
+}
+
+func (matcher *MessageMatcher) Match(text string) string {
+	// returns action of matching filter, empty if text matches no filter.
+	// Hiding wins over collapsing
+	action := ""
+	if text == "" {
+		return action
+	}
+	for i, pattern := range matcher.patterns {
+		if !pattern.MatchString(text) {
+			continue
+		}
+		if matcher.actions[i] == FILTER_HIDE {
+			return FILTER_HIDE
+		}
+		action = matcher.actions[i]
+	}
+	return action
+}
+
+func FormatMessageFilters(filters []MessageFilter, action string) string {
+	// returns filters with action one per line. Regular expressions are
+	// written between slashes: /pattern/
+	var lines []string
+	for _, filter := range filters {
+		if filter.Action != action {
+			continue
+		}
+		if filter.IsRegexp {
+			lines = append(lines, "/"+filter.Pattern+"/")
+		} else {
+			lines = append(lines, filter.Pattern)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func ParseMessageFilters(text string, action string) []MessageFilter {
+	// reads lines written by FormatMessageFilters, empty lines are skipped
+	var filters []MessageFilter
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		filter := MessageFilter{Pattern: line, Action: action}
+		if len(line) > 2 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") {
+			filter.Pattern, filter.IsRegexp = line[1:len(line)-1], true
+		}
+		filters = append(filters, filter)
+	}
+	return filters
+}
@@ -0,0 +1,358 @@
+// plugins.go
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"chat/models"
+)
+
+const PLUGINS_DIR = "plugins"
+const PLUGIN_TIMEOUT = 2 * time.Second
+const PLUGIN_QUEUE_SIZE int = 64 // requests waiting for plugin to read them
+const MAX_PLUGIN_LINE int = 1 << 20
+const RENDERED_CACHE_SIZE int64 = 1 << 20 // bytes of rendered texts
+
+const PLUGIN_COMMAND = "command"
+const PLUGIN_RENDER = "render"
+const PLUGIN_NOTIFICATION = "notification"
+
+// plugin is executable of PLUGINS_DIR which talks to client by lines of
+// json on stdin and stdout. First line written by plugin is its manifest,
+// then plugin answers each request by line with same id. Notifications
+// have id 0 and aren't answered. Stderr of plugin goes to stderr of client
+type PluginManifest struct {
+	Name          string          `json:"name"`
+	Commands      []PluginCommand `json:"commands"`      // slash commands of input box
+	Renderers     []string        `json:"renderers"`     // regexps of texts which plugin renders
+	Notifications bool            `json:"notifications"` // plugin gets notified messages
+}
+
+type PluginCommand struct {
+	Name        string `json:"name"`
+	Usage       string `json:"usage"`
+	Description string `json:"description"`
+}
+
+type PluginRequest struct {
+	Id      int64                `json:"id"`
+	Type    string               `json:"type"`              // command, render or notification
+	Name    string               `json:"name"`              // name of command
+	Text    string               `json:"text"`              // arguments of command or text
+	Message *models.SavedMessage `json:"message,omitempty"` // notified message
+}
+
+type PluginResponse struct {
+	Id    int64  `json:"id"`
+	Text  string `json:"text"`  // message sent by command or rendered text
+	Error string `json:"error"` // shown to user when command fails
+}
+
+type Plugin struct {
+	Manifest  PluginManifest
+	renderers []*regexp.Regexp
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	queue     chan []byte   // lines of requests written by writeRequests
+	done      chan struct{} // closed with plugin
+	mutex     sync.Mutex
+	lastId    int64
+	pending   map[int64]chan PluginResponse // map: request id -> waiting caller
+}
+
+func StartPlugin(path string) (*Plugin, error) {
+	// runs executable and reads its manifest. Plugin works in its own dir,
+	// so relative path would be resolved from it
+	path, err := filepath.Abs(path)
+	if IsError(err) {
+		return nil, err
+	}
+	plugin := &Plugin{cmd: exec.Command(path), pending: make(map[int64]chan PluginResponse),
+		queue: make(chan []byte, PLUGIN_QUEUE_SIZE), done: make(chan struct{})}
+	plugin.cmd.Dir = filepath.Dir(path)
+	plugin.cmd.Stderr = os.Stderr
+	stdin, err := plugin.cmd.StdinPipe()
+	if IsError(err) {
+		return nil, err
+	}
+	stdout, err := plugin.cmd.StdoutPipe()
+	if IsError(err) {
+		return nil, err
+	}
+	if err := plugin.cmd.Start(); IsError(err) {
+		return nil, err
+	}
+	plugin.stdin = stdin
+	go plugin.writeRequests()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 4096), MAX_PLUGIN_LINE)
+	manifestRead := make(chan error, 1)
+	go func() {
+		if !scanner.Scan() {
+			manifestRead <- errors.New("Plugin exited without manifest.")
+			return
+		}
+		manifestRead <- json.Unmarshal(scanner.Bytes(), &plugin.Manifest)
+	}()
+	select {
+	case err = <-manifestRead:
+	case <-time.After(PLUGIN_TIMEOUT):
+		err = errors.New("Plugin didn't write manifest.")
+	}
+	if err == nil {
+		err = plugin.compileRenderers()
+	}
+	if IsError(err) {
+		plugin.Close()
+		return nil, err
+	}
+	if plugin.Manifest.Name == "" {
+		plugin.Manifest.Name = filepath.Base(path)
+	}
+	go plugin.readResponses(scanner)
+	return plugin, nil
+}
+
+func (plugin *Plugin) compileRenderers() error {
+	for _, pattern := range plugin.Manifest.Renderers {
+		renderer, err := regexp.Compile(pattern)
+		if IsError(err) {
+			return errors.New("Pattern of renderer is not valid: " + err.Error())
+		}
+		plugin.renderers = append(plugin.renderers, renderer)
+	}
+	return nil
+}
+
+func (plugin *Plugin) readResponses(scanner *bufio.Scanner) {
+	// lines which aren't json or answer no waiting request are skipped.
+	// Requests of exited plugin fail by timeout
+	for scanner.Scan() {
+		var response PluginResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); IsError(err) {
+			continue
+		}
+		plugin.mutex.Lock()
+		answer, ok := plugin.pending[response.Id]
+		delete(plugin.pending, response.Id)
+		plugin.mutex.Unlock()
+		if ok {
+			answer <- response
+		}
+	}
+}
+
+func (plugin *Plugin) writeRequests() {
+	// only this goroutine writes to stdin, so plugin which doesn't read
+	// it blocks nobody. Lines which can't be written are dropped
+	for {
+		select {
+		case line := <-plugin.queue:
+			plugin.stdin.Write(line)
+		case <-plugin.done:
+			return
+		}
+	}
+}
+
+func (plugin *Plugin) write(request PluginRequest) error {
+	// request isn't waited for if queue of plugin is full
+	data, err := json.Marshal(request)
+	if IsError(err) {
+		return err
+	}
+	select {
+	case plugin.queue <- append(data, '\n'):
+		return nil
+	default:
+		return errors.New("Plugin " + plugin.Manifest.Name + " doesn't read requests.")
+	}
+}
+
+func (plugin *Plugin) call(request PluginRequest) (string, error) {
+	// waits for answer of plugin not longer than PLUGIN_TIMEOUT
+	answer := make(chan PluginResponse, 1)
+	plugin.mutex.Lock()
+	plugin.lastId++
+	request.Id = plugin.lastId
+	plugin.pending[request.Id] = answer
+	err := plugin.write(request)
+	plugin.mutex.Unlock()
+	if err == nil {
+		select {
+		case response := <-answer:
+			if response.Error != "" {
+				return "", errors.New(response.Error)
+			}
+			return response.Text, nil
+		case <-time.After(PLUGIN_TIMEOUT):
+			err = errors.New("Plugin " + plugin.Manifest.Name + " didn't answer.")
+		}
+	}
+	plugin.mutex.Lock()
+	delete(plugin.pending, request.Id)
+	plugin.mutex.Unlock()
+	return "", err
+}
+
+func (plugin *Plugin) RunCommand(name string, args string) (string, error) {
+	return plugin.call(PluginRequest{Type: PLUGIN_COMMAND, Name: name, Text: args})
+}
+
+func (plugin *Plugin) CanRender(text string) bool {
+	for _, renderer := range plugin.renderers {
+		if renderer.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+func (plugin *Plugin) Render(text string) (string, error) {
+	return plugin.call(PluginRequest{Type: PLUGIN_RENDER, Text: text})
+}
+
+func (plugin *Plugin) Notify(msg models.SavedMessage) error {
+	return plugin.write(PluginRequest{Type: PLUGIN_NOTIFICATION, Text: msg.Text, Message: &msg})
+}
+
+func (plugin *Plugin) Close() {
+	// plugin can't save anything after stdin is closed, so it's killed
+	close(plugin.done)
+	plugin.stdin.Close()
+	plugin.cmd.Process.Kill()
+	go plugin.cmd.Wait()
+}
+
+// plugins started by client. Texts are rendered in background, results
+// are cached by text, so rendering doesn't block gui
+type Plugins struct {
+	plugins   []*Plugin
+	rendered  *LRUCache // map: text -> rendered text, empty if rendering failed
+	mutex     sync.Mutex
+	rendering map[string]bool // texts which are being rendered
+}
+
+func isExecutable(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		extension := strings.ToLower(filepath.Ext(info.Name()))
+		return extension == ".exe" || extension == ".bat" || extension == ".cmd"
+	}
+	return info.Mode()&0111 != 0
+}
+
+func LoadPlugins(dir string) *Plugins {
+	// starts executables of dir. Missing dir means that there are no plugins,
+	// plugins which can't start are logged and skipped
+	plugins := &Plugins{rendered: NewLRUCache(RENDERED_CACHE_SIZE),
+		rendering: make(map[string]bool)}
+	files, err := ioutil.ReadDir(dir)
+	if IsError(err) {
+		return plugins
+	}
+	for _, info := range files {
+		if !isExecutable(info) {
+			continue
+		}
+		plugin, err := StartPlugin(filepath.Join(dir, info.Name()))
+		if IsError(err) {
+			log.Println("Can't start plugin " + info.Name() + ": " + err.Error())
+			continue
+		}
+		log.Println("Started plugin " + plugin.Manifest.Name)
+		plugins.plugins = append(plugins.plugins, plugin)
+	}
+	return plugins
+}
+
+func (plugins *Plugins) Close() {
+	for _, plugin := range plugins.plugins {
+		plugin.Close()
+	}
+}
+
+func (plugins *Plugins) GetCommands() []SlashCommand {
+	// commands of plugins are run by plugins, their messages are sent to opened chat
+	var commands []SlashCommand
+	for _, plugin := range plugins.plugins {
+		plugin := plugin
+		for _, command := range plugin.Manifest.Commands {
+			name := command.Name
+			commands = append(commands, SlashCommand{Name: name, Usage: command.Usage,
+				Description: command.Description,
+				Run: func(args string) (string, error) {
+					return plugin.RunCommand(name, args)
+				}})
+		}
+	}
+	return commands
+}
+
+func (plugins *Plugins) Render(text string, onRendered func()) (string, bool) {
+	// returns text rendered by first plugin whose renderer matches it.
+	// Text which isn't rendered yet is passed to plugin, and onRendered is
+	// called after that, so message can be shown again
+	if cached, ok := plugins.rendered.Get(text); ok {
+		rendered := cached.(string)
+		return rendered, rendered != ""
+	}
+	for _, plugin := range plugins.plugins {
+		if !plugin.CanRender(text) {
+			continue
+		}
+		plugins.mutex.Lock()
+		isRendering := plugins.rendering[text]
+		plugins.rendering[text] = true
+		plugins.mutex.Unlock()
+		if !isRendering {
+			go plugins.render(plugin, text, onRendered)
+		}
+		break
+	}
+	return "", false
+}
+
+func (plugins *Plugins) render(plugin *Plugin, text string, onRendered func()) {
+	// failed rendering is cached too, so text is shown as it is
+	rendered, err := plugin.Render(text)
+	if IsError(err) {
+		log.Println("Can't render message: " + err.Error())
+		rendered = ""
+	}
+	plugins.rendered.Add(text, rendered, int64(len(text)+len(rendered)))
+	plugins.mutex.Lock()
+	delete(plugins.rendering, text)
+	plugins.mutex.Unlock()
+	if rendered != "" {
+		onRendered()
+	}
+}
+
+func (plugins *Plugins) Notify(msg models.SavedMessage) {
+	// passes notified message to plugins which handle notifications
+	for _, plugin := range plugins.plugins {
+		if !plugin.Manifest.Notifications {
+			continue
+		}
+		if err := plugin.Notify(msg); IsError(err) {
+			log.Println("Can't notify plugin " + plugin.Manifest.Name + ": " + err.Error())
+		}
+	}
+}
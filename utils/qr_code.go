@@ -0,0 +1,461 @@
+// qr_code.go
+package utils
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// QR code of byte mode with error correction level M. Versions up to 10
+// hold 213 bytes which is enough for otpauth:// links
+const QR_MAX_VERSION int = 10
+const QR_QUIET_ZONE int = 4 // light modules around code
+
+// blocks of version for level M: error correction codewords of each block,
+// count and data codewords of blocks of first and second groups
+type qrVersionBlocks struct {
+	EcCodewords  int
+	Group1Blocks int
+	Group1Data   int
+	Group2Blocks int
+	Group2Data   int
+}
+
+var QR_VERSION_BLOCKS = []qrVersionBlocks{
+	{}, // versions start from 1
+	{10, 1, 16, 0, 0},
+	{16, 1, 28, 0, 0},
+	{26, 1, 44, 0, 0},
+	{18, 2, 32, 0, 0},
+	{24, 2, 43, 0, 0},
+	{16, 4, 27, 0, 0},
+	{18, 4, 31, 0, 0},
+	{22, 2, 38, 2, 39},
+	{22, 3, 36, 2, 37},
+	{26, 4, 43, 1, 44},
+}
+
+var QR_ALIGNMENT_POSITIONS = [][]int{
+	{}, {},
+	{6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+func (blocks qrVersionBlocks) dataCodewords() int {
+	return blocks.Group1Blocks*blocks.Group1Data + blocks.Group2Blocks*blocks.Group2Data
+}
+
+// modules of code: true for dark ones. Function modules (finders, timing,
+// format) aren't masked
+type qrCode struct {
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func EncodeQrCode(data []byte) ([][]bool, error) {
+	// returns modules of smallest version which holds data, rows first
+	version := 1
+	for ; version <= QR_MAX_VERSION; version++ {
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+len(data)*8 <= QR_VERSION_BLOCKS[version].dataCodewords()*8 {
+			break
+		}
+	}
+	if version > QR_MAX_VERSION {
+		return nil, errors.New("Text is too long for QR code.")
+	}
+	codewords := addQrErrorCorrection(getQrDataCodewords(data, version), version)
+
+	code := newQrCode(version)
+	code.drawFunctionPatterns(version)
+	code.drawCodewords(codewords)
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		code.applyMask(mask)
+		code.drawFormatBits(mask)
+		if penalty := code.getPenalty(); bestPenalty < 0 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		code.applyMask(mask) // masks are xor, so second one restores modules
+	}
+	code.applyMask(bestMask)
+	code.drawFormatBits(bestMask)
+	return code.modules, nil
+}
+
+func GetQrCodeImage(modules [][]bool, scale int) image.Image {
+	// draws modules as squares of scale pixels with quiet zone around
+	size := (len(modules) + 2*QR_QUIET_ZONE) * scale
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			row, column := y/scale-QR_QUIET_ZONE, x/scale-QR_QUIET_ZONE
+			isDark := row >= 0 && column >= 0 && row < len(modules) &&
+				column < len(modules) && modules[row][column]
+			if isDark {
+				img.SetGray(x, y, color.Gray{0})
+			} else {
+				img.SetGray(x, y, color.Gray{255})
+			}
+		}
+	}
+	return img
+}
+
+// bits written from most significant one
+type qrBitBuffer struct {
+	bytes []byte
+	count int
+}
+
+func (buffer *qrBitBuffer) put(value int, length int) {
+	for i := length - 1; i >= 0; i-- {
+		if buffer.count%8 == 0 {
+			buffer.bytes = append(buffer.bytes, 0)
+		}
+		if (value>>uint(i))&1 == 1 {
+			buffer.bytes[buffer.count/8] |= 0x80 >> uint(buffer.count%8)
+		}
+		buffer.count++
+	}
+}
+
+func getQrDataCodewords(data []byte, version int) []byte {
+	// mode, length and data followed by terminator and pad codewords
+	capacity := QR_VERSION_BLOCKS[version].dataCodewords()
+	buffer := qrBitBuffer{}
+	buffer.put(0x4, 4) // byte mode
+	if version >= 10 {
+		buffer.put(len(data), 16)
+	} else {
+		buffer.put(len(data), 8)
+	}
+	for _, b := range data {
+		buffer.put(int(b), 8)
+	}
+	terminator := capacity*8 - buffer.count
+	if terminator > 4 {
+		terminator = 4
+	}
+	buffer.put(0, terminator)
+	if buffer.count%8 != 0 {
+		buffer.put(0, 8-buffer.count%8)
+	}
+	for i := 0; len(buffer.bytes) < capacity; i++ {
+		if i%2 == 0 {
+			buffer.bytes = append(buffer.bytes, 0xec)
+		} else {
+			buffer.bytes = append(buffer.bytes, 0x11)
+		}
+	}
+	return buffer.bytes
+}
+
+func multiplyGf256(x byte, y byte) byte {
+	// product in GF(2^8) with polynomial x^8 + x^4 + x^3 + x^2 + 1
+	var result byte
+	for i := 7; i >= 0; i-- {
+		carry := result & 0x80
+		result <<= 1
+		if carry != 0 {
+			result ^= 0x1d
+		}
+		if (y>>uint(i))&1 == 1 {
+			result ^= x
+		}
+	}
+	return result
+}
+
+func getReedSolomonGenerator(degree int) []byte {
+	// coefficients of (x - a^0)(x - a^1)...(x - a^(degree-1)) except leading one
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	var root byte = 1
+	for i := 0; i < degree; i++ {
+		for j := 0; j < degree; j++ {
+			result[j] = multiplyGf256(result[j], root)
+			if j+1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = multiplyGf256(root, 0x02)
+	}
+	return result
+}
+
+func getReedSolomonRemainder(data []byte, generator []byte) []byte {
+	result := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coefficient := range generator {
+			result[i] ^= multiplyGf256(coefficient, factor)
+		}
+	}
+	return result
+}
+
+func addQrErrorCorrection(data []byte, version int) []byte {
+	// splits data into blocks and interleaves their codewords
+	// followed by interleaved error correction codewords
+	blocks := QR_VERSION_BLOCKS[version]
+	generator := getReedSolomonGenerator(blocks.EcCodewords)
+	var dataBlocks, ecBlocks [][]byte
+	offset := 0
+	for i := 0; i < blocks.Group1Blocks+blocks.Group2Blocks; i++ {
+		length := blocks.Group1Data
+		if i >= blocks.Group1Blocks {
+			length = blocks.Group2Data
+		}
+		block := data[offset : offset+length]
+		offset += length
+		dataBlocks = append(dataBlocks, block)
+		ecBlocks = append(ecBlocks, getReedSolomonRemainder(block, generator))
+	}
+	var result []byte
+	for i := 0; i < blocks.Group1Data || i < blocks.Group2Data; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < blocks.EcCodewords; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+func newQrCode(version int) *qrCode {
+	size := version*4 + 17
+	code := &qrCode{size: size}
+	code.modules = make([][]bool, size)
+	code.isFunction = make([][]bool, size)
+	for i := range code.modules {
+		code.modules[i] = make([]bool, size)
+		code.isFunction[i] = make([]bool, size)
+	}
+	return code
+}
+
+func (code *qrCode) setFunction(x int, y int, isDark bool) {
+	code.modules[y][x] = isDark
+	code.isFunction[y][x] = true
+}
+
+func (code *qrCode) drawFunctionPatterns(version int) {
+	// format bits are reserved here and drawn after masking
+	for i := 0; i < code.size; i++ {
+		code.setFunction(6, i, i%2 == 0)
+		code.setFunction(i, 6, i%2 == 0)
+	}
+	for _, center := range [][2]int{{3, 3}, {code.size - 4, 3}, {3, code.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := center[0]+dx, center[1]+dy
+				if x >= 0 && y >= 0 && x < code.size && y < code.size {
+					distance := maxInt(absInt(dx), absInt(dy))
+					code.setFunction(x, y, distance != 2 && distance != 4)
+				}
+			}
+		}
+	}
+	positions := QR_ALIGNMENT_POSITIONS[version]
+	last := len(positions) - 1
+	for i, y := range positions {
+		for j, x := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue // finder is there
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					code.setFunction(x+dx, y+dy, maxInt(absInt(dx), absInt(dy)) != 1)
+				}
+			}
+		}
+	}
+	code.drawFormatBits(0)
+	if version >= 7 {
+		remainder := version
+		for i := 0; i < 12; i++ {
+			remainder = (remainder << 1) ^ ((remainder >> 11) * 0x1f25)
+		}
+		bits := version<<12 | remainder
+		for i := 0; i < 18; i++ {
+			isDark := (bits>>uint(i))&1 == 1
+			a, b := code.size-11+i%3, i/3
+			code.setFunction(a, b, isDark)
+			code.setFunction(b, a, isDark)
+		}
+	}
+}
+
+func (code *qrCode) drawFormatBits(mask int) {
+	// level M has zero bits, so data of format is mask only
+	remainder := mask
+	for i := 0; i < 10; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 9) * 0x537)
+	}
+	bits := (mask<<10 | remainder) ^ 0x5412
+	bit := func(i int) bool { return (bits>>uint(i))&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		code.setFunction(8, i, bit(i))
+	}
+	code.setFunction(8, 7, bit(6))
+	code.setFunction(8, 8, bit(7))
+	code.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		code.setFunction(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		code.setFunction(code.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		code.setFunction(8, code.size-15+i, bit(i))
+	}
+	code.setFunction(8, code.size-8, true) // always dark module
+}
+
+func (code *qrCode) drawCodewords(codewords []byte) {
+	// fills columns pairs in zigzag from bottom right corner.
+	// Column of vertical timing pattern is skipped
+	i := 0
+	for right := code.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vertical := 0; vertical < code.size; vertical++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vertical
+				if (right+1)&2 == 0 { // upward
+					y = code.size - 1 - vertical
+				}
+				if !code.isFunction[y][x] && i < len(codewords)*8 {
+					code.modules[y][x] = (codewords[i/8]>>uint(7-i%8))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func isQrMasked(mask int, x int, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (y/2+x/3)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	}
+	return ((x+y)%2+x*y%3)%2 == 0
+}
+
+func (code *qrCode) applyMask(mask int) {
+	for y := 0; y < code.size; y++ {
+		for x := 0; x < code.size; x++ {
+			if !code.isFunction[y][x] && isQrMasked(mask, x, y) {
+				code.modules[y][x] = !code.modules[y][x]
+			}
+		}
+	}
+}
+
+func (code *qrCode) getPenalty() int {
+	// penalty rules of standard: runs, 2x2 blocks, finder-like
+	// patterns and balance of dark modules
+	penalty := 0
+	get := func(x int, y int, isColumn bool) bool {
+		if isColumn {
+			return code.modules[x][y]
+		}
+		return code.modules[y][x]
+	}
+	finderLike := []bool{true, false, true, true, true, false, true}
+	for _, isColumn := range []bool{false, true} {
+		for y := 0; y < code.size; y++ {
+			run := 1
+			for x := 1; x <= code.size; x++ {
+				if x < code.size && get(x, y, isColumn) == get(x-1, y, isColumn) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += 3 + run - 5
+				}
+				run = 1
+			}
+			for x := 0; x+7 <= code.size; x++ {
+				matches := true
+				for i, isDark := range finderLike {
+					matches = matches && get(x+i, y, isColumn) == isDark
+				}
+				if matches && (code.isLightRun(x-4, y, isColumn, get) ||
+					code.isLightRun(x+7, y, isColumn, get)) {
+					penalty += 40
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < code.size; y++ {
+		for x := 0; x < code.size; x++ {
+			if code.modules[y][x] {
+				dark++
+			}
+			if x+1 < code.size && y+1 < code.size {
+				isDark := code.modules[y][x]
+				if code.modules[y][x+1] == isDark && code.modules[y+1][x] == isDark &&
+					code.modules[y+1][x+1] == isDark {
+					penalty += 3
+				}
+			}
+		}
+	}
+	total := code.size * code.size
+	penalty += absInt(dark*100/total-50) / 5 * 10
+	return penalty
+}
+
+func (code *qrCode) isLightRun(start int, y int, isColumn bool,
+	get func(int, int, bool) bool) bool {
+	// four light modules. Modules outside of code are light
+	for x := start; x < start+4; x++ {
+		if x >= 0 && x < code.size && get(x, y, isColumn) {
+			return false
+		}
+	}
+	return true
+}
+
+func absInt(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,138 @@
+// qr_code_test.go
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestQrErrorCorrection(t *testing.T) {
+	// version 1-M example of "HELLO WORLD" from QR code specification tutorials
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ec := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	codewords := addQrErrorCorrection(data, 1)
+	if !bytes.Equal(codewords, append(append([]byte{}, data...), ec...)) {
+		t.Errorf("codewords = %v, want data followed by %v", codewords, ec)
+	}
+}
+
+func TestQrDataCodewords(t *testing.T) {
+	// byte mode, length 5, "hello", terminator and pad codewords
+	want := []byte{0x40, 0x56, 0x86, 0x56, 0xc6, 0xc6, 0xf0,
+		0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec}
+	if codewords := getQrDataCodewords([]byte("hello"), 1); !bytes.Equal(codewords, want) {
+		t.Errorf("codewords = %x, want %x", codewords, want)
+	}
+}
+
+func TestQrVersions(t *testing.T) {
+	// capacities of byte mode with level M
+	tests := []struct {
+		length int
+		size   int
+	}{{14, 21}, {15, 25}, {84, 37}, {85, 41}, {213, 57}}
+	for _, test := range tests {
+		modules, err := EncodeQrCode([]byte(strings.Repeat("a", test.length)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(modules) != test.size || len(modules[0]) != test.size {
+			t.Errorf("size of code of %d bytes is %d, want %d", test.length, len(modules),
+				test.size)
+		}
+	}
+	if _, err := EncodeQrCode([]byte(strings.Repeat("a", 214))); err == nil {
+		t.Error("too long text is encoded")
+	}
+}
+
+// format bits of level M for masks 0-7 from table of specification
+var QR_FORMATS_M = []int{0x5412, 0x5125, 0x5e7c, 0x5b4b, 0x45f9, 0x40ce, 0x4f97, 0x4aa0}
+
+func isQrMaskedBySpec(mask int, row int, column int) bool {
+	switch mask {
+	case 0:
+		return (row+column)%2 == 0
+	case 1:
+		return row%2 == 0
+	case 2:
+		return column%3 == 0
+	case 3:
+		return (row+column)%3 == 0
+	case 4:
+		return (row/2+column/3)%2 == 0
+	case 5:
+		return row*column%2+row*column%3 == 0
+	case 6:
+		return (row*column%2+row*column%3)%2 == 0
+	}
+	return ((row+column)%2+row*column%3)%2 == 0
+}
+
+func TestQrCodeReadBack(t *testing.T) {
+	// reads version 1 code like scanner: format, then codewords in zigzag
+	data := []byte("otpauth://x")
+	modules, err := EncodeQrCode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := 0
+	for i := 0; i <= 5; i++ {
+		if modules[i][8] {
+			format |= 1 << uint(i)
+		}
+	}
+	for i, module := range []bool{modules[7][8], modules[8][8], modules[8][7]} {
+		if module {
+			format |= 1 << uint(6+i)
+		}
+	}
+	for i := 9; i < 15; i++ {
+		if modules[8][14-i] {
+			format |= 1 << uint(i)
+		}
+	}
+	mask := -1
+	for i, bits := range QR_FORMATS_M {
+		if bits == format {
+			mask = i
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("format bits %015b aren't format of level M", format)
+	}
+
+	isFunction := func(row int, column int) bool {
+		return row <= 8 && (column <= 8 || column >= 13) || row >= 13 && column <= 8 ||
+			row == 6 || column == 6
+	}
+	var bits []bool
+	for right := 20; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for i := 0; i < 21; i++ {
+			row := i
+			if upward {
+				row = 20 - i
+			}
+			for column := right; column >= right-1; column-- {
+				if !isFunction(row, column) {
+					bits = append(bits, modules[row][column] != isQrMaskedBySpec(mask, row, column))
+				}
+			}
+		}
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	want := addQrErrorCorrection(getQrDataCodewords(data, 1), 1)
+	if !bytes.Equal(codewords, want) {
+		t.Errorf("codewords of code = %v, want %v", codewords, want)
+	}
+}
@@ -0,0 +1,91 @@
+// session_files.go
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/satori/go.uuid"
+
+	"chat/encrypt"
+)
+
+const MESSAGES_CACHE_FILE = "messages_cache.db"
+const SESSION_TOKEN_FILE = "session.dat"
+
+func GetMessagesCacheFile(profileName string, accountName string) string {
+	// messages of different servers and accounts are cached in different files.
+	// File without account is used before first login
+	if accountName != "" {
+		return "messages_cache_" + getProfileHash(accountName+"@"+profileName) + ".db"
+	}
+	if profileName == DEFAULT_PROFILE_NAME {
+		return MESSAGES_CACHE_FILE
+	}
+	return "messages_cache_" + getProfileHash(profileName) + ".db"
+}
+
+func GetSessionTokenFile(profileName string, accountName string) string {
+	// tokens are issued by servers of profiles to accounts
+	if accountName != "" {
+		return "session_" + getProfileHash(accountName+"@"+profileName) + ".dat"
+	}
+	if profileName == DEFAULT_PROFILE_NAME {
+		return SESSION_TOKEN_FILE
+	}
+	return "session_" + getProfileHash(profileName) + ".dat"
+}
+
+func getSessionTokenKey(profileName string, accountName string) string {
+	// name of secret in keyring which user can recognize
+	if accountName != "" {
+		return accountName + "@" + profileName
+	}
+	return profileName
+}
+
+func LoadSessionToken(profileName string, accountName string) string {
+	// returns token from keyring or from file of old versions.
+	// Empty if session isn't saved
+	token, err := GetKeyringSecret(getSessionTokenKey(profileName, accountName))
+	if !IsError(err) {
+		return token
+	}
+	encryptedToken, err := ioutil.ReadFile(GetSessionTokenFile(profileName, accountName))
+	if IsError(err) {
+		return ""
+	}
+	commonKey := uuid.FromBytesOrNil([]byte(COMMON_SECRET_KEY))
+	token, err = encrypt.DecryptText(commonKey.Bytes(), string(encryptedToken))
+	if IsError(err) {
+		log.Println(err)
+		return ""
+	}
+	return token
+}
+
+func SaveSessionToken(profileName string, accountName string, token string) error {
+	// token is kept in keyring only. Common key is known to everybody, so
+	// file of old versions is removed and isn't written without keyring
+	os.Remove(GetSessionTokenFile(profileName, accountName))
+	err := SetKeyringSecret(getSessionTokenKey(profileName, accountName), token)
+	if IsError(err) {
+		return errors.New("Session can't be remembered without keyring. " + err.Error())
+	}
+	return nil
+}
+
+func RemoveSessionToken(profileName string, accountName string) {
+	DeleteKeyringSecret(getSessionTokenKey(profileName, accountName))
+	os.Remove(GetSessionTokenFile(profileName, accountName))
+}
+
+func getProfileHash(profileName string) string {
+	// returns part of file name which is safe for any profile name
+	hash := sha1.Sum([]byte(profileName))
+	return hex.EncodeToString(hash[:8])
+}
@@ -3,44 +3,474 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"time"
+
+	"chat/models"
 )
 
+const SETTINGS_FILE = "settings.json"
 const DEFAULT_HOST = "localhost"
 const DEFAULT_PORT = 3811
+const DEFAULT_PROFILE_NAME = "default"
+
+const THEME_SYSTEM = "system"
+const THEME_DARK = "dark"
+const THEME_LIGHT = "light"
+const MIN_FONT_SIZE = 8
+const MAX_FONT_SIZE = 32
+
+// names of accent colors of theme
+var ACCENT_COLORS = []string{"blue", "green", "orange", "purple", "red"}
+
+const NOTIFICATIONS_ALL = "all"
+const NOTIFICATIONS_MENTIONS = "mentions"
+const NOTIFICATIONS_OFF = "off"
+
+const PROXY_HTTP = "http" // HTTP CONNECT
+const PROXY_SOCKS5 = "socks5"
+
+// protocol of connection to server. Server accepts both
+const TRANSPORT_SOCKETIO = "socket.io" // default
+const TRANSPORT_WEBSOCKET = "websocket"
+
+// proxy server used by client. Tor is used as socks5 proxy
+type ProxySettings struct {
+	Type     string `json:"type"` // http, socks5 or empty for direct connection
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"` // empty if proxy doesn't need auth
+	Password string `json:"password"`
+}
 
 type HostData struct {
-	Host string `json:"host"`
-	Port int    `json:"port"`
+	Host       string        `json:"host"`
+	Port       int           `json:"port"`         // 0: client looks up SRV record of host
+	Secure     bool          `json:"secure"`       // use wss:// and https
+	CaCertFile string        `json:"ca_cert_file"` // additional CA certificate for client
+	CertFile   string        `json:"cert_file"`    // server certificate
+	KeyFile    string        `json:"key_file"`     // server private key
+	Proxy      ProxySettings `json:"proxy"`        // used by client only
+	Transport  string        `json:"transport"`    // socket.io (default) or websocket. Client only
+}
+
+type NotificationSettings struct {
+	Notifications string `json:"notifications"` // all, mentions or off
+	// notify about mentions in opened chat too
+	MentionsInOpenChat bool `json:"mentions_in_open_chat"`
+	// do not disturb mode is toggled by user or scheduled from DndFrom
+	// till DndTo (HH:MM). Empty values disable schedule
+	DoNotDisturb bool   `json:"do_not_disturb"`
+	DndFrom      string `json:"dnd_from"`
+	DndTo        string `json:"dnd_to"`
+}
+
+func (notifications NotificationSettings) IsDoNotDisturb(now time.Time) bool {
+	// all notifications and sounds are suppressed in do not disturb mode
+	return notifications.DoNotDisturb ||
+		isInTimeRange(notifications.DndFrom, notifications.DndTo, now)
+}
+
+func (notifications NotificationSettings) Validate() error {
+	if !isNotificationsMode(notifications.Notifications) {
+		return errors.New("Unknown notifications mode: " + notifications.Notifications)
+	}
+	if (notifications.DndFrom == "") != (notifications.DndTo == "") {
+		return errors.New("Both beginning and end of do not disturb schedule must be set.")
+	}
+	for _, value := range []string{notifications.DndFrom, notifications.DndTo} {
+		if _, err := time.Parse(QUIET_HOURS_FORMAT, value); value != "" && IsError(err) {
+			return fmt.Errorf("Do not disturb schedule must be in HH:MM format: %s", value)
+		}
+	}
+	return nil
+}
+
+// notification options of one chat. Empty Notifications means global mode
+type ChannelNotifications struct {
+	Notifications string `json:"notifications"` // all, mentions, off or empty
+	HideUnread    bool   `json:"hide_unread"`   // unread messages aren't counted
+	NoSound       bool   `json:"no_sound"`      // sounds aren't played for chat
+}
+
+// named server saved in settings
+type ServerProfile struct {
+	Name string `json:"name"`
+	HostData
+	Channels map[int64]ChannelNotifications `json:"channels,omitempty"` // map: chat id -> options
+
+	Accounts      []string `json:"accounts,omitempty"`       // usernames with saved sessions
+	ActiveAccount string   `json:"active_account,omitempty"` // empty before first login
+
+	LastChannel int64 `json:"last_channel,omitempty"` // chat which was opened on exit
+}
+
+// provider of OAuth login configured on server. Public part is sent to
+// clients in hello, secret and endpoints of exchange stay on server
+type OAuthProviderSettings struct {
+	models.OAuthProvider
+	TokenUrl     string `json:"token_url"`
+	UserInfoUrl  string `json:"userinfo_url"`  // OpenID Connect endpoint returning sub of user
+	ClientSecret string `json:"client_secret"` // empty for public client which uses PKCE only
+}
+
+// main window on exit. Zero values mean default size
+type WindowState struct {
+	Width      int  `json:"width"`
+	Height     int  `json:"height"`
+	FullScreen bool `json:"full_screen"`
+	// widths of sidebars as parts of window, 0 for minimal width
+	LeftSidebar  float64 `json:"left_sidebar"`
+	RightSidebar float64 `json:"right_sidebar"`
+	Forget       bool    `json:"forget"` // window opens with default size on main channel
+}
+
+// all options of settings file
+type Settings struct {
+	HostData // host of active profile
+	NotificationSettings
+	SoundSettings
+	Theme         string          `json:"theme"`     // system, dark or light. Empty for system theme
+	FontSize      int             `json:"font_size"` // 0 for default size
+	Profiles      []ServerProfile `json:"profiles"`
+	ActiveProfile string          `json:"active_profile"`
+
+	AccentColor string `json:"accent_color"` // one of ACCENT_COLORS. Empty for color of theme
+
+	SpellCheckLanguage string `json:"spell_check_language"` // dictionary name. Empty disables checking
+	Language           string `json:"language"`             // language of gui. Empty for english
+
+	Window WindowState `json:"window"`
+
+	LinkPreviews bool `json:"link_previews"` // pages of links are requested, so sites see address of user
+
+	StickersDir string `json:"stickers_dir"` // local pack of images. Empty if there is no pack
+	StickersUrl string `json:"stickers_url"` // provider returning json array of image urls
+
+	MessageFilters []MessageFilter `json:"message_filters,omitempty"` // applied to messages of others
+
+	OAuthProviders []OAuthProviderSettings `json:"oauth_providers,omitempty"` // used by server only
+	// pow or captcha which server asks before registration. Empty for open registration
+	RegistrationChallenge string `json:"registration_challenge,omitempty"`
+}
+
+func GetDefaultSettings() Settings {
+	hostData := HostData{Host: DEFAULT_HOST, Port: DEFAULT_PORT}
+	return Settings{
+		HostData:             hostData,
+		NotificationSettings: NotificationSettings{Notifications: NOTIFICATIONS_ALL},
+		Profiles:             []ServerProfile{{Name: DEFAULT_PROFILE_NAME, HostData: hostData}},
+		ActiveProfile:        DEFAULT_PROFILE_NAME}
+}
+
+func (settings *Settings) GetProfileNames() []string {
+	var names []string
+	for _, profile := range settings.Profiles {
+		names = append(names, profile.Name)
+	}
+	return names
+}
+
+func (settings *Settings) SelectProfile(name string) error {
+	// makes profile active. Its host is used for connection
+	for _, profile := range settings.Profiles {
+		if profile.Name == name {
+			settings.HostData = profile.HostData
+			settings.ActiveProfile = name
+			return nil
+		}
+	}
+	return errors.New("Server profile " + name + " does not exist.")
+}
+
+func (settings *Settings) SetProfile(name string, hostData HostData) {
+	// replaces host of profile or adds new profile and makes it active
+	settings.HostData = hostData
+	settings.ActiveProfile = name
+	for i, profile := range settings.Profiles {
+		if profile.Name == name {
+			settings.Profiles[i].HostData = hostData
+			return
+		}
+	}
+	settings.Profiles = append(settings.Profiles, ServerProfile{Name: name, HostData: hostData})
 }
 
-func saveDefaultHostSettings() {
-	defaultHostData := HostData{DEFAULT_HOST, DEFAULT_PORT}
-	jsonByteData, err := json.Marshal(defaultHostData)
+func (settings *Settings) getActiveProfile() *ServerProfile {
+	for i := range settings.Profiles {
+		if settings.Profiles[i].Name == settings.ActiveProfile {
+			return &settings.Profiles[i]
+		}
+	}
+	return nil
+}
+
+func (settings *Settings) GetAccounts() []string {
+	// returns saved accounts of active profile
+	if profile := settings.getActiveProfile(); profile != nil {
+		return profile.Accounts
+	}
+	return nil
+}
+
+func (settings *Settings) GetActiveAccount() string {
+	if profile := settings.getActiveProfile(); profile != nil {
+		return profile.ActiveAccount
+	}
+	return ""
+}
+
+func (settings *Settings) SetActiveAccount(username string) {
+	// makes account of active profile active. Unknown account is added
+	profile := settings.getActiveProfile()
+	if profile == nil {
+		return
+	}
+	profile.ActiveAccount = username
+	for _, account := range profile.Accounts {
+		if account == username {
+			return
+		}
+	}
+	profile.Accounts = append(profile.Accounts, username)
+}
+
+func (settings *Settings) RemoveAccount(username string) {
+	// forgets account of active profile
+	profile := settings.getActiveProfile()
+	if profile == nil {
+		return
+	}
+	var accounts []string
+	for _, account := range profile.Accounts {
+		if account != username {
+			accounts = append(accounts, account)
+		}
+	}
+	profile.Accounts = accounts
+	if profile.ActiveAccount == username {
+		profile.ActiveAccount = ""
+	}
+}
+
+func (settings *Settings) GetLastChannel() int64 {
+	if profile := settings.getActiveProfile(); profile != nil {
+		return profile.LastChannel
+	}
+	return GROUP_CHAT_ID
+}
+
+func (settings *Settings) SetLastChannel(chatId int64) {
+	// chat ids are different on each server
+	if profile := settings.getActiveProfile(); profile != nil {
+		profile.LastChannel = chatId
+	}
+}
+
+func (settings *Settings) GetChannelNotifications() map[int64]ChannelNotifications {
+	// returns options of chats of active profile. Chats ids are different on each server
+	result := make(map[int64]ChannelNotifications)
+	for _, profile := range settings.Profiles {
+		if profile.Name == settings.ActiveProfile {
+			for chatId, options := range profile.Channels {
+				result[chatId] = options
+			}
+		}
+	}
+	return result
+}
+
+func (settings *Settings) SetChannelNotifications(chatId int64, options ChannelNotifications) {
+	// saves options of chat of active profile. Default options are removed
+	for i, profile := range settings.Profiles {
+		if profile.Name != settings.ActiveProfile {
+			continue
+		}
+		if options.Notifications == "" && !options.HideUnread && !options.NoSound {
+			delete(profile.Channels, chatId)
+			return
+		}
+		if profile.Channels == nil {
+			settings.Profiles[i].Channels = make(map[int64]ChannelNotifications)
+		}
+		settings.Profiles[i].Channels[chatId] = options
+	}
+}
+
+func isNotificationsMode(mode string) bool {
+	switch mode {
+	case NOTIFICATIONS_ALL, NOTIFICATIONS_MENTIONS, NOTIFICATIONS_OFF:
+		return true
+	}
+	return false
+}
+
+func isAccentColor(name string) bool {
+	for _, accentColor := range ACCENT_COLORS {
+		if accentColor == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (hostData HostData) Validate() error {
+	if err := ValidateHost(hostData.Host); IsError(err) {
+		return err
+	}
+	if hostData.Port < 0 || hostData.Port > 65535 {
+		return errors.New("Port must be between 1 and 65535.")
+	}
+	switch hostData.Transport {
+	case "", TRANSPORT_SOCKETIO, TRANSPORT_WEBSOCKET:
+	default:
+		return errors.New("Unknown transport: " + hostData.Transport)
+	}
+	return hostData.Proxy.Validate()
+}
+
+func (proxy ProxySettings) Validate() error {
+	switch proxy.Type {
+	case "":
+		return nil
+	case PROXY_HTTP, PROXY_SOCKS5:
+	default:
+		return errors.New("Unknown proxy type: " + proxy.Type)
+	}
+	if proxy.Host == "" {
+		return errors.New("Proxy host must not be empty.")
+	}
+	if proxy.Port <= 0 || proxy.Port > 65535 {
+		return errors.New("Proxy port must be between 1 and 65535.")
+	}
+	return nil
+}
+
+func (settings Settings) Validate() error {
+	// returns error with description of first incorrect option
+	err := settings.HostData.Validate()
 	if IsError(err) {
-		log.Println(err)
+		return err
+	}
+	for _, profile := range settings.Profiles {
+		if profile.Name == "" {
+			return errors.New("Profile name must not be empty.")
+		}
+		err = profile.HostData.Validate()
+		if IsError(err) {
+			return errors.New("Profile " + profile.Name + ": " + err.Error())
+		}
+		for _, options := range profile.Channels {
+			if options.Notifications != "" && !isNotificationsMode(options.Notifications) {
+				return errors.New("Profile " + profile.Name +
+					": unknown notifications mode: " + options.Notifications)
+			}
+		}
 	}
-	err = ioutil.WriteFile("settings.json", jsonByteData, 0644)
+	err = settings.NotificationSettings.Validate()
 	if IsError(err) {
-		log.Println(err)
+		return err
+	}
+	switch settings.Theme {
+	case "", THEME_SYSTEM, THEME_DARK, THEME_LIGHT:
+	default:
+		return errors.New("Unknown theme: " + settings.Theme)
+	}
+	if settings.AccentColor != "" && !isAccentColor(settings.AccentColor) {
+		return errors.New("Unknown accent color: " + settings.AccentColor)
+	}
+	err = settings.SoundSettings.Validate()
+	if IsError(err) {
+		return err
+	}
+	window := settings.Window
+	if window.Width < 0 || window.Height < 0 {
+		return errors.New("Window size must not be negative.")
+	}
+	if window.LeftSidebar < 0 || window.LeftSidebar > 1 ||
+		window.RightSidebar < 0 || window.RightSidebar > 1 {
+		return errors.New("Sidebar widths must be between 0 and 1.")
+	}
+	if settings.StickersUrl != "" && IsError(ValidateStickerUrl(settings.StickersUrl)) {
+		return errors.New("Provider of stickers must be http or https url.")
+	}
+	if len(settings.MessageFilters) > MAX_MESSAGE_FILTERS {
+		return fmt.Errorf("There can be at most %d message filters.", MAX_MESSAGE_FILTERS)
+	}
+	for _, filter := range settings.MessageFilters {
+		if err := filter.Validate(); IsError(err) {
+			return err
+		}
+	}
+	switch settings.RegistrationChallenge {
+	case "", models.CHALLENGE_POW, models.CHALLENGE_CAPTCHA:
+	default:
+		return errors.New("Unknown registration challenge: " + settings.RegistrationChallenge)
+	}
+	for _, provider := range settings.OAuthProviders {
+		if provider.Name == "" || provider.AuthUrl == "" || provider.TokenUrl == "" ||
+			provider.UserInfoUrl == "" || provider.ClientId == "" {
+			return errors.New("Login provider must have name, client id and urls of endpoints.")
+		}
+	}
+	if settings.FontSize != 0 &&
+		(settings.FontSize < MIN_FONT_SIZE || settings.FontSize > MAX_FONT_SIZE) {
+		return fmt.Errorf("Font size must be between %d and %d.", MIN_FONT_SIZE, MAX_FONT_SIZE)
 	}
+	return nil
 }
 
-func GetHostDataFromSettingsFile() (string, int) {
-	// returns (host, port)
-	f, err := ioutil.ReadFile("settings.json")
+func SaveSettings(settings Settings) error {
+	jsonByteData, err := json.MarshalIndent(settings, "", "    ")
 	if IsError(err) {
-		saveDefaultHostSettings()
-		return DEFAULT_HOST, DEFAULT_PORT
+		return err
 	}
+	return ioutil.WriteFile(SETTINGS_FILE, jsonByteData, 0644)
+}
 
-	hostData := HostData{}
-	err = json.Unmarshal([]byte(f), &hostData)
+func GetSettingsFromFile() Settings {
+	// returns saved settings. Default settings are saved
+	// if file doesn't exist or can't be parsed
+	settings := GetDefaultSettings()
+	settings.Profiles = nil // profiles are created from host of old files
+	f, err := ioutil.ReadFile(SETTINGS_FILE)
+	if !IsError(err) {
+		err = json.Unmarshal([]byte(f), &settings)
+	}
 	if IsError(err) {
-		saveDefaultHostSettings()
-		return DEFAULT_HOST, DEFAULT_PORT
+		log.Println(err)
+		settings = GetDefaultSettings()
+		err = SaveSettings(settings)
+		if IsError(err) {
+			log.Println(err)
+		}
+		return settings
 	}
 
+	if !isNotificationsMode(settings.Notifications) {
+		settings.Notifications = NOTIFICATIONS_ALL
+	}
+	if len(settings.Profiles) == 0 { // file of version without profiles
+		settings.SetProfile(DEFAULT_PROFILE_NAME, settings.HostData)
+	}
+	return settings
+}
+
+func GetHostDataFromSettingsFile() (string, int) {
+	// returns (host, port)
+	hostData := GetHostSettingsFromFile()
 	return hostData.Host, hostData.Port
 }
+
+func GetHostSettingsFromFile() HostData {
+	// returns host data with connection security options
+	return GetSettingsFromFile().HostData
+}
+
+func GetNotificationSettingsFromFile() NotificationSettings {
+	// returns notification settings. All notifications are enabled by default
+	return GetSettingsFromFile().NotificationSettings
+}
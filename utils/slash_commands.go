@@ -0,0 +1,71 @@
+// slash_commands.go
+package utils
+
+import (
+	"sort"
+	"strings"
+)
+
+// command typed in input box instead of message, e.g. "/msg user text".
+// Text starting with "//" isn't command, it's sent with one slash
+type SlashCommand struct {
+	Name        string // without slash
+	Usage       string // arguments shown in autocomplete, e.g. "<username> <text>"
+	Description string
+	// returns text of message which is sent to opened chat, empty text sends nothing
+	Run func(args string) (string, error)
+}
+
+type CommandRegistry struct {
+	commands map[string]SlashCommand // map: name -> command
+}
+
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{commands: make(map[string]SlashCommand)}
+}
+
+func (registry *CommandRegistry) Register(command SlashCommand) {
+	// command with same name is replaced
+	registry.commands[strings.ToLower(command.Name)] = command
+}
+
+func (registry *CommandRegistry) Find(name string) (SlashCommand, bool) {
+	command, ok := registry.commands[strings.ToLower(name)]
+	return command, ok
+}
+
+func (registry *CommandRegistry) Complete(prefix string) []SlashCommand {
+	// returns commands starting with prefix sorted by name
+	var commands []SlashCommand
+	prefix = strings.ToLower(prefix)
+	for name, command := range registry.commands {
+		if strings.HasPrefix(name, prefix) {
+			commands = append(commands, command)
+		}
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+func ParseSlashCommand(text string) (string, string, bool) {
+	// returns name and arguments of command. Text of message returns false
+	if !strings.HasPrefix(text, "/") || strings.HasPrefix(text, "//") {
+		return "", "", false
+	}
+	name, args := strings.TrimPrefix(text, "/"), ""
+	if i := strings.IndexAny(name, " \n\t"); i >= 0 {
+		name, args = name[:i], strings.TrimSpace(name[i+1:])
+	}
+	return name, args, name != ""
+}
+
+func GetCommandQuery(text string) (string, bool) {
+	// returns typed part of command name while its arguments aren't started
+	if !strings.HasPrefix(text, "/") || strings.HasPrefix(text, "//") ||
+		strings.ContainsAny(text, " \n\t") {
+		return "", false
+	}
+	return strings.TrimPrefix(text, "/"), true
+}
@@ -0,0 +1,105 @@
+// sounds.go
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+const QUIET_HOURS_FORMAT = "15:04"
+
+// options of sounds of incoming messages
+type SoundSettings struct {
+	SoundsMuted  bool   `json:"sounds_muted"`
+	MessageSound string `json:"message_sound"` // path of sound file. Empty for no sound
+	MentionSound string `json:"mention_sound"` // empty for message sound
+	// sounds aren't played from QuietHoursFrom till QuietHoursTo (HH:MM).
+	// Empty values disable quiet hours
+	QuietHoursFrom string `json:"quiet_hours_from"`
+	QuietHoursTo   string `json:"quiet_hours_to"`
+}
+
+func (sounds SoundSettings) GetSound(isMention bool) string {
+	// returns path of sound for message. Empty path means no sound
+	if isMention && sounds.MentionSound != "" {
+		return sounds.MentionSound
+	}
+	return sounds.MessageSound
+}
+
+func (sounds SoundSettings) IsQuietTime(now time.Time) bool {
+	// returns true if now is in quiet hours. Quiet hours can end on the next day
+	return isInTimeRange(sounds.QuietHoursFrom, sounds.QuietHoursTo, now)
+}
+
+func isInTimeRange(fromValue string, toValue string, now time.Time) bool {
+	// range of HH:MM values. Empty or incorrect values mean no range
+	if fromValue == "" || toValue == "" {
+		return false
+	}
+	from, err := time.Parse(QUIET_HOURS_FORMAT, fromValue)
+	if IsError(err) {
+		return false
+	}
+	to, err := time.Parse(QUIET_HOURS_FORMAT, toValue)
+	if IsError(err) {
+		return false
+	}
+	minutes := now.Hour()*60 + now.Minute()
+	fromMinutes := from.Hour()*60 + from.Minute()
+	toMinutes := to.Hour()*60 + to.Minute()
+	if fromMinutes <= toMinutes {
+		return minutes >= fromMinutes && minutes < toMinutes
+	}
+	return minutes >= fromMinutes || minutes < toMinutes
+}
+
+func (sounds SoundSettings) Validate() error {
+	if (sounds.QuietHoursFrom == "") != (sounds.QuietHoursTo == "") {
+		return errors.New("Both beginning and end of quiet hours must be set.")
+	}
+	for _, value := range []string{sounds.QuietHoursFrom, sounds.QuietHoursTo} {
+		if _, err := time.Parse(QUIET_HOURS_FORMAT, value); value != "" && IsError(err) {
+			return fmt.Errorf("Quiet hours must be in HH:MM format: %s", value)
+		}
+	}
+	return nil
+}
+
+func getSoundPlayerCommand(path string) (*exec.Cmd, error) {
+	// sound is played by player of system, so no audio library is needed
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("afplay", path), nil
+	case "windows":
+		return getPowershellCommand("(New-Object Media.SoundPlayer $env:CHAT_SOUND).PlaySync()",
+			"CHAT_SOUND="+path), nil
+	}
+	for _, player := range []string{"paplay", "aplay"} {
+		if _, err := exec.LookPath(player); !IsError(err) {
+			return exec.Command(player, path), nil
+		}
+	}
+	return nil, errors.New("No sound player found (paplay or aplay).")
+}
+
+func PlaySound(path string) error {
+	// plays sound file and waits for the end of it
+	cmd, err := StartSound(path)
+	if IsError(err) {
+		return err
+	}
+	return cmd.Wait()
+}
+
+func StartSound(path string) (*exec.Cmd, error) {
+	// starts playing sound file. Playing is stopped by killing of process
+	cmd, err := getSoundPlayerCommand(path)
+	if IsError(err) {
+		return nil, err
+	}
+	return cmd, cmd.Start()
+}
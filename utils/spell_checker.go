@@ -0,0 +1,220 @@
+// spell_checker.go
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const MAX_SPELLING_SUGGESTIONS = 5
+const MAX_SUGGESTION_DISTANCE = 2
+
+// dictionaries are word lists (one word per line) or hunspell .dic files
+// named by language, for example dictionaries/en_US.dic
+var DICTIONARY_DIRS = []string{"dictionaries", "/usr/share/hunspell", "/usr/share/myspell"}
+var DICTIONARY_EXTENSIONS = []string{".dic", ".txt"}
+
+type SpellChecker struct {
+	words map[string]bool // lowercase words of dictionary
+}
+
+func GetDictionaryLanguages() []string {
+	// returns names of dictionaries found in DICTIONARY_DIRS
+	var languages []string
+	found := make(map[string]bool)
+	for _, dir := range DICTIONARY_DIRS {
+		files, err := ioutil.ReadDir(dir)
+		if IsError(err) {
+			continue
+		}
+		for _, file := range files {
+			extension := filepath.Ext(file.Name())
+			language := strings.TrimSuffix(file.Name(), extension)
+			if isDictionaryExtension(extension) && !found[language] {
+				found[language] = true
+				languages = append(languages, language)
+			}
+		}
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+func isDictionaryExtension(extension string) bool {
+	for _, dictionaryExtension := range DICTIONARY_EXTENSIONS {
+		if extension == dictionaryExtension {
+			return true
+		}
+	}
+	return false
+}
+
+func getDictionaryPath(language string) (string, error) {
+	for _, dir := range DICTIONARY_DIRS {
+		for _, extension := range DICTIONARY_EXTENSIONS {
+			path := filepath.Join(dir, language+extension)
+			if _, err := os.Stat(path); !IsError(err) {
+				return path, nil
+			}
+		}
+	}
+	return "", errors.New("Dictionary " + language + " is not found.")
+}
+
+func LoadSpellChecker(language string) (*SpellChecker, error) {
+	// reads dictionary of language. Affix flags of hunspell words
+	// and count of words in the first line are skipped
+	path, err := getDictionaryPath(language)
+	if IsError(err) {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if IsError(err) {
+		return nil, err
+	}
+	defer file.Close()
+
+	checker := &SpellChecker{words: make(map[string]bool)}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if index := strings.Index(word, "/"); index >= 0 {
+			word = word[:index]
+		}
+		if word != "" && !unicode.IsDigit([]rune(word)[0]) {
+			checker.words[strings.ToLower(word)] = true
+		}
+	}
+	return checker, scanner.Err()
+}
+
+func isWordRune(c rune) bool {
+	return unicode.IsLetter(c) || c == '\''
+}
+
+func getWords(text string) []string {
+	// returns words of text. Mentions, links and words
+	// with digits aren't checked
+	var words []string
+	for _, token := range strings.Fields(text) {
+		if strings.HasPrefix(token, "@") || strings.HasPrefix(token, ":") ||
+			strings.Contains(token, "://") || strings.ContainsAny(token, "0123456789") {
+			continue
+		}
+		words = append(words, strings.FieldsFunc(token, func(c rune) bool {
+			return !isWordRune(c)
+		})...)
+	}
+	return words
+}
+
+func (checker *SpellChecker) IsCorrect(word string) bool {
+	word = strings.Trim(strings.ToLower(word), "'")
+	return len([]rune(word)) < 2 || checker.words[word]
+}
+
+func (checker *SpellChecker) GetMisspelledWords(text string) []string {
+	// returns unique misspelled words in order of appearance
+	var misspelled []string
+	found := make(map[string]bool)
+	for _, word := range getWords(text) {
+		if !checker.IsCorrect(word) && !found[strings.ToLower(word)] {
+			found[strings.ToLower(word)] = true
+			misspelled = append(misspelled, word)
+		}
+	}
+	return misspelled
+}
+
+func (checker *SpellChecker) GetSuggestions(word string) []string {
+	// returns closest words of dictionary by edit distance.
+	// Capitalized word gets capitalized suggestions
+	isCapitalized := unicode.IsUpper([]rune(word)[0])
+	word = strings.ToLower(word)
+	wordLength := len([]rune(word))
+	distances := make(map[string]int)
+	var suggestions []string
+	for dictionaryWord := range checker.words {
+		lengthDiff := len([]rune(dictionaryWord)) - wordLength
+		if lengthDiff > MAX_SUGGESTION_DISTANCE || lengthDiff < -MAX_SUGGESTION_DISTANCE {
+			continue
+		}
+		distance := getEditDistance(word, dictionaryWord)
+		if distance <= MAX_SUGGESTION_DISTANCE {
+			distances[dictionaryWord] = distance
+			suggestions = append(suggestions, dictionaryWord)
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if distances[suggestions[i]] != distances[suggestions[j]] {
+			return distances[suggestions[i]] < distances[suggestions[j]]
+		}
+		return suggestions[i] < suggestions[j]
+	})
+	if len(suggestions) > MAX_SPELLING_SUGGESTIONS {
+		suggestions = suggestions[:MAX_SPELLING_SUGGESTIONS]
+	}
+	for i, suggestion := range suggestions {
+		if isCapitalized {
+			runes := []rune(suggestion)
+			suggestions[i] = string(unicode.ToUpper(runes[0])) + string(runes[1:])
+		}
+	}
+	return suggestions
+}
+
+func getEditDistance(a string, b string) int {
+	// returns Levenshtein distance between words
+	runesA := []rune(a)
+	runesB := []rune(b)
+	previous := make([]int, len(runesB)+1)
+	current := make([]int, len(runesB)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(runesA); i++ {
+		current[0] = i
+		for j := 1; j <= len(runesB); j++ {
+			cost := 1
+			if runesA[i-1] == runesB[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(runesB)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func ReplaceWord(text string, word string, replacement string) string {
+	// replaces whole word occurrences only, so parts of other words stay unchanged
+	runes := []rune(text)
+	wordRunes := []rune(word)
+	var result []rune
+	for i := 0; i < len(runes); {
+		isStart := i == 0 || !isWordRune(runes[i-1])
+		end := i + len(wordRunes)
+		if isStart && end <= len(runes) && string(runes[i:end]) == word &&
+			(end == len(runes) || !isWordRune(runes[end])) {
+			result = append(result, []rune(replacement)...)
+			i = end
+			continue
+		}
+		result = append(result, runes[i])
+		i++
+	}
+	return string(result)
+}
@@ -2,16 +2,67 @@
 package utils
 
 import (
+	"strings"
 	"time"
+	"unicode"
 )
 
 const GROUP_CHAT_ID int64 = 0
 const COMMON_SECRET_KEY string = "HCT4yhsyz24iMCQsZDKV"
+const FILE_CHUNK_SIZE int = 64 * 1024              // bytes of file sent in one event
+const MAX_ATTACHMENT_SIZE int64 = 20 * 1024 * 1024 // 20 MB
+
+// notes starting with these prefixes are shown as tasks
+const TASK_PREFIX = "[] "
+const DONE_TASK_PREFIX = "[x] "
 
 func GetTimestampNow() int64 {
 	return time.Now().Unix()
 }
 
+func IsMentioned(text string, username string) bool {
+	// returns true if text contains @username followed by
+	// space, punctuation or end of text
+	mention := "@" + username
+	for {
+		index := strings.Index(text, mention)
+		if index < 0 {
+			return false
+		}
+		text = text[index+len(mention):]
+		if text == "" {
+			return true
+		}
+		next := []rune(text)[0]
+		if unicode.IsSpace(next) || strings.ContainsRune(".,!?:;)", next) {
+			return true
+		}
+	}
+}
+
+func ParseTask(text string) (string, bool, bool) {
+	// returns (title, done, isTask)
+	if strings.HasPrefix(text, TASK_PREFIX) {
+		return strings.TrimPrefix(text, TASK_PREFIX), false, true
+	}
+	if strings.HasPrefix(text, DONE_TASK_PREFIX) {
+		return strings.TrimPrefix(text, DONE_TASK_PREFIX), true, true
+	}
+	return text, false, false
+}
+
+func ToggleTask(text string) string {
+	// returns text of task with changed state. Other texts are unchanged
+	title, done, isTask := ParseTask(text)
+	if !isTask {
+		return text
+	}
+	if done {
+		return TASK_PREFIX + title
+	}
+	return DONE_TASK_PREFIX + title
+}
+
 func IsError(err error) bool {
 	if err != nil {
 		return true
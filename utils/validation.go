@@ -0,0 +1,180 @@
+// validation.go
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"chat/models"
+)
+
+const MIN_USERNAME_LENGTH int = 3
+const MAX_USERNAME_LENGTH int = 20
+const MIN_PASSWORD_LENGTH int = 6
+const LONG_PASSWORD_LENGTH int = 10
+const MAX_PASSWORD_STRENGTH int = 4
+const MAX_DISPLAY_NAME_LENGTH int = 32
+const MAX_STATUS_TEXT_LENGTH int = 100
+const MAX_REACTION_LENGTH int = 32
+const MAX_REPORT_REASON_LENGTH int = 500
+const MAX_HOSTNAME_LENGTH int = 253
+const MAX_STICKER_URL_LENGTH int = 2048
+const MAX_STICKER_SIZE int64 = 1024 * 1024 // file of local sticker pack
+
+var STICKER_EXTENSIONS = []string{".png", ".gif", ".jpg", ".jpeg"}
+
+var PASSWORD_STRENGTH_NAMES = []string{"very weak", "weak", "fair", "good", "strong"}
+
+var reactionRegexp = regexp.MustCompile(`^:[a-z0-9_+\-]+:$`)
+var hostnameLabelRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?$`)
+
+func ValidateUsername(username string) error {
+	// username consists of letters, digits and _ - . characters
+	length := utf8.RuneCountInString(username)
+	if length < MIN_USERNAME_LENGTH || length > MAX_USERNAME_LENGTH {
+		return fmt.Errorf("Username must have from %d to %d characters.",
+			MIN_USERNAME_LENGTH, MAX_USERNAME_LENGTH)
+	}
+	for _, c := range username {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && !strings.ContainsRune("_-.", c) {
+			return errors.New("Username can contain only letters, digits and _ - . characters.")
+		}
+	}
+	return nil
+}
+
+func ValidateHost(host string) error {
+	// host is ip address or domain name of letters, digits and hyphens
+	if host == "" {
+		return errors.New("Host must not be empty.")
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	hostname := strings.TrimSuffix(host, ".")
+	if len(hostname) > MAX_HOSTNAME_LENGTH {
+		return errors.New("Host name is too long.")
+	}
+	for _, label := range strings.Split(hostname, ".") {
+		if !hostnameLabelRegexp.MatchString(label) {
+			return errors.New("Host must be ip address or domain name.")
+		}
+	}
+	return nil
+}
+
+func ValidatePassword(password string) error {
+	if utf8.RuneCountInString(password) < MIN_PASSWORD_LENGTH {
+		return fmt.Errorf("Password must have at least %d characters.", MIN_PASSWORD_LENGTH)
+	}
+	return nil
+}
+
+func ValidateProfile(displayName string, statusText string) error {
+	// both fields are optional. Display name is one line
+	if utf8.RuneCountInString(displayName) > MAX_DISPLAY_NAME_LENGTH {
+		return fmt.Errorf("Display name must have at most %d characters.",
+			MAX_DISPLAY_NAME_LENGTH)
+	}
+	if strings.ContainsAny(displayName, "\n\r\t") {
+		return errors.New("Display name must be one line.")
+	}
+	if utf8.RuneCountInString(statusText) > MAX_STATUS_TEXT_LENGTH {
+		return fmt.Errorf("Status must have at most %d characters.", MAX_STATUS_TEXT_LENGTH)
+	}
+	return nil
+}
+
+func ValidateReaction(emoji string) error {
+	// reactions are emoji shortcodes like :thumbsup:
+	if len(emoji) > MAX_REACTION_LENGTH || !reactionRegexp.MatchString(emoji) {
+		return errors.New("Reaction must be emoji shortcode.")
+	}
+	return nil
+}
+
+func ValidateStickerUrl(link string) error {
+	// stickers of provider are images shown by url
+	parsed, err := url.Parse(link)
+	if len(link) > MAX_STICKER_URL_LENGTH || IsError(err) || parsed.Host == "" ||
+		parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return errors.New("Sticker must be http or https url of image.")
+	}
+	return nil
+}
+
+func ValidateStickerFile(fileName string, size int64) error {
+	// stickers of local pack are sent as small images
+	if size > MAX_STICKER_SIZE {
+		return fmt.Errorf("Sticker must be at most %d KB.", MAX_STICKER_SIZE/1024)
+	}
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, stickerExt := range STICKER_EXTENSIONS {
+		if ext == stickerExt {
+			return nil
+		}
+	}
+	return errors.New("Sticker must be png, gif or jpeg image.")
+}
+
+func ValidateReport(report models.Report) error {
+	// comment is optional, category tells reason
+	isKnown := false
+	for _, category := range models.GetReportCategories() {
+		isKnown = isKnown || report.Category == category
+	}
+	if !isKnown {
+		return errors.New("Choose category of report.")
+	}
+	if utf8.RuneCountInString(report.Comment) > MAX_REPORT_REASON_LENGTH {
+		return fmt.Errorf("Comment must have at most %d characters.", MAX_REPORT_REASON_LENGTH)
+	}
+	return nil
+}
+
+func GetPasswordStrength(password string) int {
+	// returns score from 0 to MAX_PASSWORD_STRENGTH for length
+	// and kinds of used characters
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+	kinds := 0
+	for _, hasKind := range []bool{hasLower, hasUpper, hasDigit, hasSymbol} {
+		if hasKind {
+			kinds++
+		}
+	}
+
+	strength := 0
+	length := utf8.RuneCountInString(password)
+	if length >= MIN_PASSWORD_LENGTH {
+		strength++
+	}
+	if length >= LONG_PASSWORD_LENGTH {
+		strength++
+	}
+	if kinds >= 2 {
+		strength++
+	}
+	if kinds >= 3 {
+		strength++
+	}
+	return strength
+}
@@ -0,0 +1,103 @@
+// voice.go
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+const MAX_VOICE_DURATION = 2 * time.Minute
+const VOICE_FILE_PREFIX = "voice-"
+
+// duration is kept in file name, so it's shown before file is downloaded
+var voiceFileNameRegexp = regexp.MustCompile(`^voice-\d{8}-\d{6}-(\d+)s\.wav$`)
+
+// voice message which is being recorded to wav file
+type VoiceRecorder struct {
+	Path      string
+	StartTime time.Time
+	cmd       *exec.Cmd
+}
+
+func GetVoiceFileName(startTime time.Time, duration time.Duration) string {
+	return fmt.Sprintf("%s%s-%ds.wav", VOICE_FILE_PREFIX, startTime.Format("20060102-150405"),
+		int(duration.Round(time.Second)/time.Second))
+}
+
+func GetVoiceDuration(fileName string) (time.Duration, bool) {
+	// returns duration of voice message. False if file isn't voice message
+	match := voiceFileNameRegexp.FindStringSubmatch(fileName)
+	if match == nil {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(match[1])
+	if IsError(err) {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func getRecorderCommand(path string) (*exec.Cmd, error) {
+	// voice is recorded by sound tools of system like sounds are played.
+	// Mono 16 kHz keeps longest message much smaller than MAX_ATTACHMENT_SIZE.
+	// Recording stops after MAX_VOICE_DURATION
+	seconds := strconv.Itoa(int(MAX_VOICE_DURATION / time.Second))
+	commands := [][]string{
+		{"arecord", "-q", "-f", "S16_LE", "-r", "16000", "-c", "1", "-t", "wav", "-d", seconds, path},
+		{"rec", "-q", "-r", "16000", "-c", "1", "-b", "16", path, "trim", "0", seconds},
+	}
+	if runtime.GOOS == "windows" {
+		commands = [][]string{{"sox", "-q", "-t", "waveaudio", "default",
+			"-r", "16000", "-c", "1", "-b", "16", path, "trim", "0", seconds}}
+	}
+	for _, command := range commands {
+		if _, err := exec.LookPath(command[0]); !IsError(err) {
+			return exec.Command(command[0], command[1:]...), nil
+		}
+	}
+	return nil, errors.New("No sound recorder found (arecord or sox).")
+}
+
+func StartRecording(path string) (*VoiceRecorder, error) {
+	cmd, err := getRecorderCommand(path)
+	if IsError(err) {
+		return nil, err
+	}
+	err = cmd.Start()
+	if IsError(err) {
+		return nil, err
+	}
+	return &VoiceRecorder{Path: path, StartTime: time.Now(), cmd: cmd}, nil
+}
+
+func (recorder *VoiceRecorder) Stop() (time.Duration, error) {
+	// finishes recording and returns its duration. Interrupted recorders
+	// write end of wav file, so exit error after interruption is expected.
+	// Recorder may be already finished after MAX_VOICE_DURATION
+	duration := time.Since(recorder.StartTime)
+	if duration > MAX_VOICE_DURATION {
+		duration = MAX_VOICE_DURATION
+	}
+	if runtime.GOOS == "windows" {
+		recorder.cmd.Process.Kill()
+	} else {
+		recorder.cmd.Process.Signal(os.Interrupt)
+	}
+	recorder.cmd.Wait()
+	if _, err := os.Stat(recorder.Path); IsError(err) {
+		return 0, errors.New("Voice message is not recorded.")
+	}
+	return duration, nil
+}
+
+func (recorder *VoiceRecorder) Cancel() {
+	recorder.cmd.Process.Kill()
+	recorder.cmd.Wait()
+	os.Remove(recorder.Path)
+}